@@ -0,0 +1,85 @@
+import path from "node:path";
+
+// Command line options, e.g.
+//   poe --resume                 reopen the last session for the current directory
+//   poe --session "bug triage"   open a session by name or id
+//   poe ~/src/project --resume   same, for another project directory
+//   poe -p "question"            answer one prompt on stdout and exit (see headless.ts)
+//   poe -p -m ollama/llama3 < f  same, with the prompt on stdin and a chosen model
+//   poe -p -- "-v vs --verbose?" a prompt that starts with "-" goes after --
+//   poe --json -p "list ..."     answer with validated JSON (see json-output.ts)
+//   poe --safe                   start with default settings, tools and hooks off
+//   poe index docs               add ./docs to the current directory's retrieval index
+//   poe attach <url>             watch a session shared with /observe, read-only
+export interface LaunchOptions {
+    directory: string | null;
+    session: string | null;
+    resume: boolean;
+    print: string | null; // Headless prompt; "" when -p was given without one (stdin only)
+    model: string | null; // "providerId/modelId", model id or name for the headless answer
+    json: boolean; // Headless answer in JSON output mode, with a fix requested if it isn't valid
+    index: string | null; // Path to add to the project's retrieval index (see rag/)
+    attach: string | null; // Observer URL printed by /observe
+    safe: boolean; // Defaults only, to rule out customizations (see main.ts)
+}
+
+/**
+ * Parse the app's own arguments. Electron and Chromium switches are ignored,
+ * as is the script path when running unpackaged.
+ */
+export function parseLaunchOptions(argv: string[], isPackaged: boolean, cwd: string): LaunchOptions {
+    const args = argv.slice(isPackaged ? 1 : 2);
+    const options: LaunchOptions = { directory: null, session: null, resume: false, print: null, model: null, json: false, index: null, attach: null, safe: false };
+
+    // "poe index <path>" is a command of its own, for the project in the current directory
+    if (args[0] === "index") {
+        options.index = args[1] ?? ".";
+        options.directory = cwd;
+        return options;
+    }
+    if (args[0] === "attach") {
+        options.attach = args[1] ?? "";
+        return options;
+    }
+
+    for (let i = 0; i < args.length; i++) {
+        const arg = args[i];
+        if (arg === "--") {
+            // Everything after -- is the prompt, even if it looks like an option
+            if (options.print === "") {
+                options.print = args.slice(i + 1).join(" ");
+            }
+            break;
+        }
+        if (arg === "--resume") {
+            options.resume = true;
+        } else if (arg === "--safe") {
+            options.safe = true;
+        } else if (arg === "--session") {
+            options.session = args[++i] ?? null;
+        } else if (arg.startsWith("--session=")) {
+            options.session = arg.substring("--session=".length);
+        } else if (arg === "-p" || arg === "--print") {
+            // The prompt is optional, piped input works on its own
+            const next = args[i + 1];
+            options.print = next !== undefined && !next.startsWith("-") ? args[++i] : "";
+        } else if (arg.startsWith("--print=")) {
+            options.print = arg.substring("--print=".length);
+        } else if (arg === "--json") {
+            options.json = true;
+        } else if (arg === "-m" || arg === "--model") {
+            options.model = args[++i] ?? null;
+        } else if (arg.startsWith("--model=")) {
+            options.model = arg.substring("--model=".length);
+        } else if (!arg.startsWith("-") && !options.directory) {
+            options.directory = path.resolve(cwd, arg);
+        }
+    }
+
+    // Resuming without a directory means the directory poe was started from
+    if ((options.resume || options.session) && !options.directory) {
+        options.directory = cwd;
+    }
+
+    return options;
+}
@@ -0,0 +1,53 @@
+// Environment variable references in config values, so secrets and hosts don't
+// have to be written into providers.yaml:
+//
+// providers:
+//   - id: openai
+//     apiKey: ${OPENAI_API_KEY}
+//   - id: ollama
+//     baseURL: ${OLLAMA_HOST:-http://localhost:11434}
+//
+// "$${" escapes a literal "${".
+
+const REFERENCE_PATTERN = /\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}/g;
+
+/**
+ * Replace ${VAR} and ${VAR:-default} in a string. Unset variables without a
+ * default become empty and are reported through `missing`.
+ */
+export function interpolateString(value: string, env: NodeJS.ProcessEnv, missing?: Set<string>): string {
+    return value.replace(REFERENCE_PATTERN, (match, escape: string, name: string, fallback: string | undefined) => {
+        if (escape) {
+            return match.substring(1);
+        }
+        const resolved = env[name];
+        if (resolved !== undefined && resolved !== "") {
+            return resolved;
+        }
+        if (fallback !== undefined) {
+            return fallback;
+        }
+        missing?.add(name);
+        return "";
+    });
+}
+
+/**
+ * Interpolate every string in a parsed config (objects and arrays are copied)
+ */
+export function interpolateEnv<T>(config: T, env: NodeJS.ProcessEnv = process.env, missing?: Set<string>): T {
+    if (typeof config === "string") {
+        return interpolateString(config, env, missing) as T;
+    }
+    if (Array.isArray(config)) {
+        return config.map(item => interpolateEnv(item, env, missing)) as T;
+    }
+    if (config && typeof config === "object") {
+        const result: Record<string, unknown> = {};
+        for (const [key, value] of Object.entries(config)) {
+            result[key] = interpolateEnv(value, env, missing);
+        }
+        return result as T;
+    }
+    return config;
+}
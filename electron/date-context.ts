@@ -0,0 +1,38 @@
+import type { ChatMessage } from "./providers/types";
+import { appendToSystemPrompt } from "./system-prompt";
+
+// The current date and timezone, added to the system prompt of every chat
+// request so the model can answer "what day is it" and reason about "yesterday"
+// without the date being written into the user's messages. On unless the
+// dateContext preference (set with /date) is false. Only the date: the time
+// would change the system prompt every minute and defeat prompt caching.
+
+function utcOffset(date: Date): string {
+    const minutes = -date.getTimezoneOffset();
+    const sign = minutes < 0 ? "-" : "+";
+    const hours = String(Math.floor(Math.abs(minutes) / 60)).padStart(2, "0");
+    return `UTC${sign}${hours}:${String(Math.abs(minutes) % 60).padStart(2, "0")}`;
+}
+
+export function describeCurrentDate(date: Date = new Date()): string {
+    const formatted = new Intl.DateTimeFormat("en-US", {
+        weekday: "long",
+        year: "numeric",
+        month: "long",
+        day: "numeric",
+    }).format(date);
+    const timeZone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+    return `The current date is ${formatted} (${timeZone ? `${timeZone}, ` : ""}${utcOffset(date)}).`;
+}
+
+/**
+ * Add the current date to the first system message, or as a new system message
+ * when there is none
+ */
+export function withDateContext(messages: ChatMessage[], enabled: unknown): ChatMessage[] {
+    if (enabled === false) {
+        return messages;
+    }
+
+    return appendToSystemPrompt(messages, describeCurrentDate());
+}
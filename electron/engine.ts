@@ -0,0 +1,366 @@
+import { providerRegistry } from "./providers/ProviderRegistry";
+import { getModelCapabilities } from "./providers/capabilities";
+import { applyThinkingFormat } from "./providers/thinking";
+import type { RequestRetryOptions } from "./providers/retry";
+import type { ChatChunk, ChatMessage, ChatProvider, GenerationOptions, ProviderRequest, ToolDefinition } from "./providers/types";
+import { rateLimiter, estimateTokens } from "./rate-limiter";
+import { withResponseLanguage } from "./response-language";
+import { withDateContext } from "./date-context";
+import { responseLengthOptions, withResponseLength } from "./response-length";
+import { retrieve, withRetrievedContext, type IndexStore, type RagSettings } from "./rag";
+import { createLogger } from "./logger";
+import { JSON_FIX_ATTEMPTS, JsonStreamValidator, jsonFixRequest, parseJsonOutputMode, withJsonOutput, type JsonOutputMode } from "./json-output";
+import { formatToolResultForModel } from "../src/utils/codeFence";
+import { isLocalProvider } from "../src/utils/modelUtils";
+
+// The chat request pipeline without the window: provider lookup, offline mode,
+// the /date, /length and /lang context, retrieval from the project index, rate
+// limits, request and empty-response retries, thinking extraction and JSON
+// output mode (see json-output.ts). The chat-send-message handler and headless
+// mode both drive conversations through it, and anything else in the main
+// process can too:
+//
+//   const engine = createEngine({ loadConfig, readPreference, loadRequestRetry });
+//   const stream = await engine.chat(messages, { provider: "ollama", model: "llama3" });
+//   for await (const chunk of stream) { ... }
+//
+// chat() rejects when the request can't be made (unknown provider, offline
+// mode); once it resolves, problems arrive in the stream. Tool calls come back
+// as tool_call chunks for the caller to run and answer with tool messages in
+// the next request; the engine never runs tools itself. Hooks (hooks.yaml)
+// and tool execution stay in the renderer, so headless mode runs neither.
+// preview() goes through the same steps up to sending and returns the
+// request instead.
+
+const log = createLogger("chat");
+
+export interface EngineDependencies {
+    // Load providers and rate limits from the config directory
+    loadConfig: () => Promise<void>;
+    readPreference: (key: string) => Promise<unknown>;
+    loadRequestRetry: () => Promise<RequestRetryOptions>;
+    // Project indexes for retrieval; without a store requests get no excerpts
+    ragStore?: IndexStore;
+}
+
+// Messages as the renderer keeps them: tool results may be raw JSON, and
+// images without data (saved to a temp file) are only for display
+export type EngineMessage = Omit<ChatMessage, "timestamp" | "images"> & {
+    timestamp?: number;
+    images?: Array<{ mimeType: string; data?: string }>;
+};
+
+export interface EngineStatus {
+    status: "throttled" | "retrying" | "adapted"; // adapted: something was left out that the model can't take
+    message: string;
+}
+
+export interface EngineChatOptions {
+    provider: string;
+    model: string;
+    tools?: ToolDefinition[]; // Dropped for models without tool support
+    projectPath?: string; // Retrieval from the project's index, when it has one
+    options?: GenerationOptions; // Set with /set
+    signal?: AbortSignal;
+    onStatus?: (status: EngineStatus) => void;
+    json?: JsonOutputMode | null; // Overrides the jsonOutput preference; null turns it off
+}
+
+// The request chat() would send first, with the provider's key masked
+export interface EngineRequestPreview extends ProviderRequest {
+    leftOut: string[]; // What the model can't take, left out of the request
+}
+
+export interface Engine {
+    chat(messages: EngineMessage[], options: EngineChatOptions): Promise<AsyncGenerator<ChatChunk>>;
+    preview(messages: EngineMessage[], options: Omit<EngineChatOptions, "onStatus">): Promise<EngineRequestPreview>;
+}
+
+// Lowest-priority Ollama options: the /length preset's limit and the seed pinned with /seed default
+async function readDefaultGenerationOptions(deps: EngineDependencies): Promise<GenerationOptions | undefined> {
+    const seed = await deps.readPreference("seed");
+    const options: GenerationOptions = {
+        ...responseLengthOptions(await deps.readPreference("responseLength")),
+        ...(typeof seed === "number" && { seed }),
+    };
+    return Object.keys(options).length > 0 ? options : undefined;
+}
+
+// Retry settings for empty completions, from the "emptyResponseRetry" preference
+async function loadEmptyResponseRetry(deps: EngineDependencies): Promise<{ attempts: number; nudge: string }> {
+    const defaults = {
+        attempts: 1,
+        nudge: "Your previous response was empty. Please answer the last message.",
+    };
+    const value = (await deps.readPreference("emptyResponseRetry")) as { attempts?: number; nudge?: string } | null;
+    return {
+        attempts: typeof value?.attempts === "number" ? Math.max(0, value.attempts) : defaults.attempts,
+        nudge: value?.nudge || defaults.nudge,
+    };
+}
+
+/**
+ * Convert messages to provider format, fencing code and logs in tool results,
+ * and add the current date and the /length and /lang instructions if set
+ */
+async function prepareMessages(messages: EngineMessage[], deps: EngineDependencies): Promise<ChatMessage[]> {
+    // Tool call arguments by id, used as hints when formatting tool results
+    const toolCallArgs = new Map<string, Record<string, unknown>>();
+    for (const m of messages) {
+        for (const tc of m.tool_calls || []) {
+            try {
+                toolCallArgs.set(tc.id, JSON.parse(tc.function.arguments));
+            } catch {
+                // Arguments aren't needed to format the result
+            }
+        }
+    }
+
+    const converted: ChatMessage[] = messages.map(m => ({
+        role: m.role,
+        content: m.role === "tool" && m.content
+            ? formatToolResultForModel(m.content, m.tool_call_id ? toolCallArgs.get(m.tool_call_id) : undefined)
+            : m.content || "",
+        tool_calls: m.tool_calls,
+        tool_call_id: m.tool_call_id,
+        timestamp: m.timestamp || Date.now(),
+        thinking: m.thinking,
+        // Images on tool results are for display only; attachments go to the model
+        images: m.role === "user"
+            ? m.images?.filter((image): image is { mimeType: string; data: string } => !!image.data)
+            : undefined,
+    }));
+
+    return withResponseLanguage(
+        withResponseLength(
+            withDateContext(converted, await deps.readPreference("dateContext")),
+            await deps.readPreference("responseLength"),
+        ),
+        await deps.readPreference("responseLanguage"),
+    );
+}
+
+// Everything a request is made from, up to the rate limiter
+interface PreparedRequest {
+    provider: ChatProvider;
+    messages: ChatMessage[];
+    tools?: ToolDefinition[];
+    thinking: boolean;
+    defaultOptions?: GenerationOptions;
+    jsonMode: JsonOutputMode | null;
+    leftOut: string[];
+}
+
+function maskKey(text: string, key: string | undefined): string {
+    return key ? text.split(key).join("<api key>") : text;
+}
+
+export function createEngine(deps: EngineDependencies): Engine {
+    async function prepare(messages: EngineMessage[], params: EngineChatOptions): Promise<PreparedRequest> {
+        const { provider: providerId, model, tools, projectPath, signal } = params;
+        await deps.loadConfig();
+
+        const provider = providerRegistry.getProvider(providerId);
+        if (!provider) {
+            throw new Error(`Provider ${providerId} not found or not enabled`);
+        }
+        if ((await deps.readPreference("offlineMode")) === true && !isLocalProvider(provider.getConfig())) {
+            throw new Error(`Offline mode is on: ${providerId} is not a local Ollama provider`);
+        }
+
+        // Leave out what the model can't take (see capabilities.ts)
+        const capabilities = await getModelCapabilities(provider, model);
+        const leftOut: string[] = [];
+        if (!capabilities.tools && tools && tools.length > 0) {
+            leftOut.push("tools");
+        }
+        const toolsToSend = capabilities.tools ? tools : undefined;
+        const requestThinking = capabilities.thinking !== false;
+        if (!requestThinking && provider.getThinkingFormat(model) === "native") {
+            leftOut.push("thinking");
+        }
+        const defaultOptions = await readDefaultGenerationOptions(deps);
+
+        const jsonMode = params.json !== undefined ? params.json : parseJsonOutputMode(await deps.readPreference("jsonOutput"));
+        let providerMessages = withJsonOutput(await prepareMessages(messages, deps), jsonMode);
+        if (capabilities.images === false && providerMessages.some(m => m.images && m.images.length > 0)) {
+            leftOut.push("images");
+            providerMessages = providerMessages.map(m => ({ ...m, images: undefined }));
+        }
+
+        // Excerpts from the project index for a new question (not for tool rounds)
+        const lastMessage = providerMessages[providerMessages.length - 1];
+        if (deps.ragStore && projectPath && lastMessage?.role === "user") {
+            try {
+                const hits = await retrieve(deps.ragStore, projectPath, lastMessage.content, (await deps.readPreference("rag")) as RagSettings | null, signal);
+                providerMessages = withRetrievedContext(providerMessages, hits);
+                if (hits.length > 0) {
+                    log.debug("Retrieved context", { chunks: hits.map(h => `${h.chunk.file}:${h.chunk.startLine} (${h.score.toFixed(2)})`) });
+                }
+            } catch (error) {
+                log.warn("Retrieval failed, sending without context", { error });
+            }
+        }
+
+        return { provider, messages: providerMessages, tools: toolsToSend, thinking: requestThinking, defaultOptions, jsonMode, leftOut };
+    }
+
+    return {
+        async chat(messages, params) {
+            const { provider: providerId, model, options, signal, onStatus } = params;
+            const { provider, messages: providerMessages, tools: toolsToSend, thinking: requestThinking, defaultOptions, jsonMode, leftOut } = await prepare(messages, params);
+            if (leftOut.length > 0) {
+                log.event("model", "Left out what the model can't take", { provider: providerId, model, leftOut });
+                onStatus?.({
+                    status: "adapted",
+                    message: `Left out ${leftOut.join(", ").replace(/, ([^,]*)$/, " and $1")}: ${model} doesn't support ${leftOut.length === 1 ? "it" : "them"}`,
+                });
+            }
+
+            // Queue behind the provider's rate limits before sending
+            const estimatedTokens = estimateTokens(providerMessages);
+            await rateLimiter.acquireProvider(providerId, estimatedTokens, signal, (waitMs) => {
+                log.event("rate-limit", "Rate limited, waiting", { provider: providerId, waitMs });
+                onStatus?.({
+                    status: "throttled",
+                    message: `Rate limited by ${providerId}, waiting ${Math.ceil(waitMs / 1000)}s…`,
+                });
+            });
+
+            const emptyRetry = await loadEmptyResponseRetry(deps);
+            const requestRetry: RequestRetryOptions = {
+                ...(await deps.loadRequestRetry()),
+                onRetry: ({ attempt, maxAttempts, delayMs, reason }) => {
+                    log.event("retry", "Request failed, retrying", { provider: providerId, reason, delayMs, attempt: attempt + 1, maxAttempts }, "warn");
+                    onStatus?.({
+                        status: "retrying",
+                        message: `${providerId} request failed (${reason}), retrying in ${Math.ceil(delayMs / 1000)}s (${attempt + 1}/${maxAttempts})…`,
+                    });
+                },
+            };
+
+            async function* stream(): AsyncGenerator<ChatChunk> {
+                let attemptMessages = providerMessages;
+                let emptyRetries = 0;
+                let jsonFixes = 0;
+                for (;;) {
+                    // Split out thinking per the model's configured format
+                    const chunks = applyThinkingFormat(provider.streamChat({
+                        model,
+                        messages: attemptMessages,
+                        tools: toolsToSend,
+                        signal,
+                        retry: requestRetry,
+                        options,
+                        defaultOptions,
+                        json: !!jsonMode,
+                        thinking: requestThinking,
+                    }), provider.getThinkingFormat(model), provider.getOutputParserOptions(model, options));
+
+                    // "done" is held back until we know the response wasn't empty,
+                    // and in JSON output mode so is the content, until it's checked
+                    const json = jsonMode ? new JsonStreamValidator() : null;
+                    let producedOutput = false;
+                    let calledTools = false;
+                    let doneChunk: ChatChunk | null = null;
+                    // An attempt that may still be retried as empty holds back its
+                    // thinking and status chunks until it produces something
+                    const held: ChatChunk[] = [];
+                    for await (const chunk of chunks) {
+                        if (chunk.type === "usage" && chunk.usage) {
+                            rateLimiter.recordProviderTokens(providerId, estimatedTokens, chunk.usage.total_tokens);
+                        }
+                        if ((chunk.type === "content" && chunk.content.trim()) || chunk.type === "tool_call") {
+                            producedOutput = true;
+                        }
+                        if (chunk.type === "tool_call") {
+                            calledTools = true;
+                        }
+                        if (chunk.type === "status") {
+                            log.event("model", chunk.message, { provider: providerId, model });
+                        }
+                        if (chunk.type === "done") {
+                            doneChunk = chunk;
+                            continue;
+                        }
+                        if (!producedOutput && emptyRetries < emptyRetry.attempts) {
+                            held.push(chunk);
+                            continue;
+                        }
+                        yield* held.splice(0);
+                        if (json && chunk.type === "content") {
+                            json.push(chunk.content);
+                            continue;
+                        }
+                        yield chunk;
+                    }
+
+                    // Some local models occasionally finish without producing anything
+                    if (doneChunk && !producedOutput && emptyRetries < emptyRetry.attempts) {
+                        emptyRetries++;
+                        log.event("retry", "Empty response, retrying", { provider: providerId, model, attempt: emptyRetries, maxAttempts: emptyRetry.attempts }, "warn");
+                        onStatus?.({ status: "retrying", message: `Model returned an empty response, retrying (${emptyRetries}/${emptyRetry.attempts})…` });
+                        attemptMessages = [
+                            ...providerMessages,
+                            { role: "user", content: emptyRetry.nudge, timestamp: Date.now() },
+                        ];
+                        continue;
+                    }
+                    yield* held.splice(0);
+
+                    // A reply with tool calls isn't the final answer, so it isn't checked
+                    if (json && json.text.trim() && !calledTools) {
+                        const check = json.finish();
+                        if (!check.ok && jsonMode === "fix" && doneChunk && jsonFixes < JSON_FIX_ATTEMPTS) {
+                            jsonFixes++;
+                            log.event("retry", "Reply is not valid JSON, asking for a fix", { provider: providerId, model, error: check.error }, "warn");
+                            onStatus?.({ status: "retrying", message: "Reply wasn't valid JSON, asking the model to fix it…" });
+                            attemptMessages = [
+                                ...providerMessages,
+                                { role: "assistant", content: json.text, timestamp: Date.now() },
+                                jsonFixRequest(check.error ?? "invalid JSON"),
+                            ];
+                            continue;
+                        }
+                        if (check.repaired) {
+                            log.info("Repaired JSON reply", { provider: providerId, model });
+                        }
+                        yield { type: "content", content: check.text };
+                        if (!check.ok) {
+                            yield { type: "error", error: `Reply is not valid JSON: ${check.error}` };
+                            return;
+                        }
+                    } else if (json && json.text) {
+                        yield { type: "content", content: json.text };
+                    }
+
+                    if (doneChunk) {
+                        yield doneChunk;
+                    }
+                    return;
+                }
+            }
+
+            return stream();
+        },
+
+        async preview(messages, params) {
+            const prepared = await prepare(messages, params);
+            const request = prepared.provider.buildRequest({
+                model: params.model,
+                messages: prepared.messages,
+                tools: prepared.tools,
+                options: params.options,
+                defaultOptions: prepared.defaultOptions,
+                json: !!prepared.jsonMode,
+                thinking: prepared.thinking,
+            });
+            const apiKey = prepared.provider.getConfig().apiKey;
+            return {
+                url: maskKey(request.url, apiKey),
+                body: JSON.parse(maskKey(JSON.stringify(request.body), apiKey)),
+                leftOut: prepared.leftOut,
+            };
+        },
+    };
+}
@@ -0,0 +1,282 @@
+import { providerRegistry } from "./providers/ProviderRegistry";
+import type { RequestRetryOptions } from "./providers/retry";
+import type { LaunchOptions } from "./cli";
+import { createEngine } from "./engine";
+import { indexPath, resolveEmbedder, type IndexStore, type RagSettings } from "./rag";
+import type { ObservedMessage } from "./observer-server";
+import { findModelByRef, isLocalProvider } from "../src/utils/modelUtils";
+import type { ProviderConfig } from "../src/types/chat";
+
+// One-shot mode for scripts and pipelines, started with -p; no window is opened:
+//
+//   echo "question" | poe -p
+//   poe -p "summarize this" < file.txt
+//   poe -p "explain" -m ollama/llama3 < error.log
+//
+// The prompt and piped input are sent together as one user message. The answer
+// streams to stdout and everything else goes to stderr. Tools are not offered,
+// since no one is there to approve them. With --json the answer is printed once
+// it has been checked as JSON, and the exit status is 1 if it never was.
+//
+// "poe index <path>" runs here too: it adds files to the project's retrieval
+// index, reporting progress on stderr and a summary on stdout. So does
+// "poe attach <url>", which prints a session shared with /observe as it happens.
+
+export const EXIT_OK = 0;
+export const EXIT_FAILED = 1; // The provider or request failed
+export const EXIT_USAGE = 2; // No prompt, or no usable model
+export const EXIT_INTERRUPTED = 130;
+
+export interface HeadlessDependencies {
+    // Load providers and rate limits from the config directory
+    loadConfig: () => Promise<void>;
+    readPreference: (key: string) => Promise<unknown>;
+    loadRequestRetry: () => Promise<RequestRetryOptions>;
+}
+
+async function readStdin(): Promise<string> {
+    if (process.stdin.isTTY) {
+        return "";
+    }
+    const chunks: Buffer[] = [];
+    for await (const chunk of process.stdin) {
+        chunks.push(typeof chunk === "string" ? Buffer.from(chunk) : chunk);
+    }
+    return Buffer.concat(chunks).toString("utf-8");
+}
+
+function fail(message: string, code: number): number {
+    process.stderr.write(`poe: ${message}\n`);
+    return code;
+}
+
+/**
+ * Answer the prompt from the command line and stdin. Resolves to the exit code.
+ */
+export async function runHeadless(options: LaunchOptions, deps: HeadlessDependencies): Promise<number> {
+    const input = await readStdin();
+    const prompt = [options.print, input.trim()].filter(Boolean).join("\n\n");
+    if (!prompt) {
+        return fail('no prompt given. Usage: poe -p "prompt" or echo "prompt" | poe -p', EXIT_USAGE);
+    }
+
+    await deps.loadConfig();
+    const offline = (await deps.readPreference("offlineMode")) === true;
+    const configs = providerRegistry.getAllProviders().map(p => p.getConfig()) as ProviderConfig[];
+
+    // Same default as the window: the first enabled provider with a chat model
+    const selection = options.model
+        ? findModelByRef(configs, options.model)
+        : configs
+            .filter(p => p.enabled && (!offline || isLocalProvider(p)))
+            .map(provider => ({ provider, model: provider.models.find(m => m.type === "chat") }))
+            .find(s => s.model) ?? null;
+    if (!selection?.model) {
+        return fail(options.model ? `model "${options.model}" not found` : "no enabled provider has a chat model", EXIT_USAGE);
+    }
+    const providerId = selection.provider.id;
+    const modelId = selection.model.id;
+    if (offline && !isLocalProvider(selection.provider)) {
+        return fail(`offline mode is on: ${providerId} is not a local Ollama provider`, EXIT_USAGE);
+    }
+
+    const provider = providerRegistry.getProvider(providerId);
+    if (!provider) {
+        return fail(`provider ${providerId} not found or not enabled`, EXIT_USAGE);
+    }
+
+    const controller = new AbortController();
+    const onInterrupt = () => controller.abort();
+    process.once("SIGINT", onInterrupt);
+
+    let endsWithNewline = true;
+    try {
+        // The same request pipeline as the window, minus tools and retrieval
+        const stream = await createEngine(deps).chat([{ role: "user", content: prompt }], {
+            provider: providerId,
+            model: modelId,
+            signal: controller.signal,
+            onStatus: ({ message }) => process.stderr.write(`poe: ${message}\n`),
+            ...(options.json && { json: "fix" as const }),
+        });
+        for await (const chunk of stream) {
+            if (chunk.type === "content" && chunk.content) {
+                process.stdout.write(chunk.content);
+                endsWithNewline = chunk.content.endsWith("\n");
+            } else if (chunk.type === "error") {
+                throw new Error(chunk.error);
+            }
+        }
+    } catch (error) {
+        if (controller.signal.aborted) {
+            return EXIT_INTERRUPTED;
+        }
+        return fail(error instanceof Error ? error.message : "Unknown error", EXIT_FAILED);
+    } finally {
+        process.removeListener("SIGINT", onInterrupt);
+        if (!endsWithNewline) {
+            process.stdout.write("\n");
+        }
+    }
+
+    return controller.signal.aborted ? EXIT_INTERRUPTED : EXIT_OK;
+}
+
+/**
+ * Index the path from "poe index <path>" for the project in the current directory.
+ * Resolves to the exit code.
+ */
+export async function runIndex(options: LaunchOptions, deps: HeadlessDependencies & { store: IndexStore }): Promise<number> {
+    const projectPath = options.directory || process.cwd();
+    await deps.loadConfig();
+    const settings = (await deps.readPreference("rag")) as RagSettings | null;
+
+    const controller = new AbortController();
+    const onInterrupt = () => controller.abort();
+    process.once("SIGINT", onInterrupt);
+    try {
+        const embedder = resolveEmbedder(settings);
+        process.stderr.write(`poe: indexing ${options.index} with ${embedder.model}\n`);
+        const result = await indexPath(deps.store, embedder, projectPath, options.index || ".", {
+            chunkSize: settings?.chunkSize,
+            signal: controller.signal,
+            onProgress: ({ file, filesDone, filesTotal }) => {
+                if (process.stderr.isTTY) {
+                    process.stderr.write(`\r\x1b[K[${filesDone + 1}/${filesTotal}] ${file}`);
+                }
+            },
+        });
+        if (process.stderr.isTTY) {
+            process.stderr.write("\r\x1b[K");
+        }
+        for (const skipped of result.skipped) {
+            process.stderr.write(`poe: skipped ${skipped}\n`);
+        }
+        process.stdout.write(`Indexed ${result.files} files (${result.embedded} new or changed, ${result.removed} removed); ${result.chunks} chunks in the index\n`);
+        // Every changed file failing usually means the embedding model is unreachable
+        return result.skipped.length > 0 && result.embedded === 0 ? EXIT_FAILED : EXIT_OK;
+    } catch (error) {
+        if (controller.signal.aborted) {
+            return EXIT_INTERRUPTED;
+        }
+        return fail(error instanceof Error ? error.message : "Unknown error", EXIT_FAILED);
+    } finally {
+        process.removeListener("SIGINT", onInterrupt);
+    }
+}
+
+// Observer URLs serve a page at / and the event stream at /events
+function observerEventsUrl(input: string): URL {
+    const url = new URL(input);
+    url.pathname = "/events";
+    return url;
+}
+
+function printObservedHistory(messages: ObservedMessage[]) {
+    for (const message of messages) {
+        if (message.role === "system") {
+            continue;
+        }
+        if (message.content) {
+            const text = message.role === "tool" && message.content.length > 500
+                ? `${message.content.substring(0, 500)}…`
+                : message.content;
+            process.stdout.write(`\n[${message.role}]\n${text}\n`);
+        }
+        for (const toolCall of message.tool_calls || []) {
+            process.stdout.write(`\n[tool call] ${toolCall.function.name} ${toolCall.function.arguments}\n`);
+        }
+    }
+}
+
+/**
+ * Follow a session shared with /observe until the server goes away or Ctrl+C.
+ * Resolves to the exit code.
+ */
+export async function runAttach(options: LaunchOptions): Promise<number> {
+    let url: URL;
+    try {
+        url = observerEventsUrl(options.attach || "");
+    } catch {
+        return fail('attach needs the URL printed by /observe. Usage: poe attach "http://127.0.0.1:7878/?token=..."', EXIT_USAGE);
+    }
+
+    const controller = new AbortController();
+    const onInterrupt = () => controller.abort();
+    process.once("SIGINT", onInterrupt);
+    try {
+        const response = await fetch(url, { headers: { Accept: "text/event-stream" }, signal: controller.signal });
+        if (!response.ok || !response.body) {
+            return fail(response.status === 403 ? "the observer rejected the token" : `observer returned HTTP ${response.status}`, EXIT_FAILED);
+        }
+        process.stderr.write("poe: attached read-only, Ctrl+C to detach\n");
+
+        // Whether an assistant response is being printed
+        let streaming = false;
+        // What each message looked like when it was last printed, and the reply
+        // streamed since the last snapshot, so snapshots only add what is new
+        let shown: string[] = [];
+        let streamed = "";
+        const decoder = new TextDecoder();
+        let buffer = "";
+        for await (const chunk of response.body) {
+            buffer += decoder.decode(chunk, { stream: true });
+            let boundary: number;
+            while ((boundary = buffer.indexOf("\n\n")) !== -1) {
+                const frame = buffer.substring(0, boundary);
+                buffer = buffer.substring(boundary + 2);
+                const name = frame.match(/^event: (.*)$/m)?.[1];
+                const data = frame.match(/^data: (.*)$/m)?.[1];
+                if (!name || !data) {
+                    continue;
+                }
+                const payload = JSON.parse(data);
+                if (name === "history") {
+                    const messages: ObservedMessage[] = payload.messages;
+                    const signatures = messages.map(message => JSON.stringify(message));
+                    let start = 0;
+                    while (start < shown.length && start < signatures.length && shown[start] === signatures[start]) {
+                        start++;
+                    }
+                    if (start < shown.length) {
+                        process.stderr.write("poe: the conversation was edited, showing it again from the change\n");
+                    }
+                    // The reply that was just streamed is already on screen
+                    printObservedHistory(messages.slice(start).filter(message =>
+                        !(streamed && message.role === "assistant" && message.content === streamed)));
+                    shown = signatures;
+                    streamed = "";
+                    continue;
+                }
+                if (payload.type === "content") {
+                    if (!streaming) {
+                        process.stdout.write("\n[assistant]\n");
+                        streaming = true;
+                    }
+                    process.stdout.write(payload.content);
+                    streamed += payload.content;
+                } else if (payload.type === "tool_call") {
+                    process.stdout.write(`\n[tool call] ${payload.tool_call.function.name}\n`);
+                } else if (payload.type === "status") {
+                    process.stderr.write(`poe: ${payload.message}\n`);
+                } else if (payload.type === "error") {
+                    process.stderr.write(`poe: error: ${payload.error}\n`);
+                } else if (payload.type === "done" || payload.type === "cancelled") {
+                    if (streaming) {
+                        process.stdout.write("\n");
+                    }
+                    streaming = false;
+                }
+            }
+        }
+        process.stderr.write("poe: the observed session ended\n");
+        return EXIT_OK;
+    } catch (error) {
+        if (controller.signal.aborted) {
+            return EXIT_INTERRUPTED;
+        }
+        return fail(error instanceof Error ? error.message : "Unknown error", EXIT_FAILED);
+    } finally {
+        process.removeListener("SIGINT", onInterrupt);
+    }
+}
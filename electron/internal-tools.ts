@@ -1,17 +1,21 @@
 import { readFile, writeFile, mkdir, readdir, stat, rename, rm } from 'node:fs/promises';
 import { join, dirname, relative, isAbsolute, resolve, sep } from 'node:path';
-import { exec } from 'node:child_process';
+import { execFile, spawn } from 'node:child_process';
 import { promisify } from 'node:util';
-import { existsSync } from 'node:fs';
+import { existsSync, lstatSync, realpathSync } from 'node:fs';
+import { IS_WINDOWS, detectLineEnding, getBashShell, killProcessTree, withLineEnding } from './platform';
 
-const execAsync = promisify(exec);
+const execFileAsync = promisify(execFile);
 
 /**
  * Validates and resolves a path to ensure it's within the project directory.
  * Path must start with / to be relative to project root.
  */
-function resolveProjectPath(inputPath: string, projectRoot: string): string {
-  // Ensure path starts with /
+export function resolveProjectPath(inputPath: string, projectRoot: string): string {
+  // Ensure path starts with / (models on Windows sometimes send \ instead)
+  if (IS_WINDOWS && inputPath.startsWith('\\')) {
+    inputPath = '/' + inputPath.substring(1);
+  }
   if (!inputPath.startsWith('/')) {
     throw new Error(`Path must start with / (representing project root). Got: ${inputPath}`);
   }
@@ -26,13 +30,66 @@ function resolveProjectPath(inputPath: string, projectRoot: string): string {
     throw new Error(`Path is outside project directory: ${inputPath}`);
   }
 
+  // A symlink inside the project must not lead out of it. Paths that don't
+  // exist yet are checked through their nearest existing parent. lstat finds
+  // a dangling symlink too, which existsSync would skip, letting a write
+  // create its target wherever it points.
+  const isPresent = (path: string) => {
+    try {
+      lstatSync(path);
+      return true;
+    } catch {
+      return false;
+    }
+  };
+  let existing = absolutePath;
+  while (!isPresent(existing) && dirname(existing) !== existing) {
+    existing = dirname(existing);
+  }
+  let realExisting: string;
+  try {
+    realExisting = realpathSync(existing);
+  } catch {
+    throw new Error(`Path is a symlink to something that doesn't exist: ${inputPath}`);
+  }
+  const realRelative = relative(realpathSync(projectRoot), realExisting);
+  if (realRelative.startsWith('..') || isAbsolute(realRelative)) {
+    throw new Error(`Path resolves outside project directory through a symlink: ${inputPath}`);
+  }
+
   return absolutePath;
 }
 
+// Size limits for file tools, so one call can't flood the context or write
+// something unreasonable
+const MAX_FULL_READ_BYTES = 1024 * 1024; // whole-file reads; larger files need offset/limit
+const MAX_READ_FILE_BYTES = 50 * 1024 * 1024; // never load files bigger than this
+const MAX_READ_OUTPUT_BYTES = 256 * 1024; // lines returned in one call
+const MAX_WRITE_BYTES = 5 * 1024 * 1024;
+
+function formatSize(bytes: number): string {
+  return bytes >= 1024 * 1024 ? `${(bytes / (1024 * 1024)).toFixed(1)} MB` : `${Math.ceil(bytes / 1024)} KB`;
+}
+
+/**
+ * Read a text file for the tools, refusing oversized and binary files
+ */
+async function readTextFile(absolutePath: string, displayPath: string, maxBytes: number): Promise<string> {
+  const { size } = await stat(absolutePath);
+  if (size > maxBytes) {
+    throw new Error(`File is too large (${formatSize(size)}, limit ${formatSize(maxBytes)}): ${displayPath}`);
+  }
+  const buffer = await readFile(absolutePath);
+  if (buffer.subarray(0, 8000).includes(0)) {
+    throw new Error(`File appears to be binary: ${displayPath}`);
+  }
+  return buffer.toString('utf-8');
+}
+
 /**
  * Converts absolute path back to project-relative path (with leading /)
  */
-function toProjectPath(absolutePath: string, projectRoot: string): string {
+export function toProjectPath(absolutePath: string, projectRoot: string): string {
   const rel = relative(projectRoot, absolutePath);
   return '/' + rel.replace(/\\/g, '/');
 }
@@ -55,26 +112,46 @@ export async function handleRead(params: ReadParams) {
       };
     }
 
-    const content = await readFile(absolutePath, 'utf-8');
-    const lines = content.split('\n');
+    const ranged = params.offset !== undefined || params.limit !== undefined;
+    const { size } = await stat(absolutePath);
+    if (!ranged && size > MAX_FULL_READ_BYTES) {
+      return {
+        success: false,
+        error: `File is too large to read at once (${formatSize(size)}, limit ${formatSize(MAX_FULL_READ_BYTES)}). Use offset and limit to read part of it: ${params.file_path}`,
+      };
+    }
+
+    const content = await readTextFile(absolutePath, params.file_path, MAX_READ_FILE_BYTES);
+    const lines = content.split(/\r?\n/);
 
     const offset = params.offset || 0;
     const limit = params.limit || lines.length;
 
     const selectedLines = lines.slice(offset, offset + limit);
 
-    // Format with line numbers like cat -n
-    const numberedLines = selectedLines.map((line, idx) => {
-      const lineNum = offset + idx + 1;
-      return `${lineNum.toString().padStart(6, ' ')}\t${line}`;
-    }).join('\n');
+    // Format with line numbers like cat -n, stopping at the output limit
+    const numbered: string[] = [];
+    let outputBytes = 0;
+    for (let idx = 0; idx < selectedLines.length; idx++) {
+      const line = `${(offset + idx + 1).toString().padStart(6, ' ')}\t${selectedLines[idx]}`;
+      outputBytes += Buffer.byteLength(line) + 1;
+      if (outputBytes > MAX_READ_OUTPUT_BYTES && numbered.length > 0) {
+        break;
+      }
+      numbered.push(line);
+    }
+    const truncated = numbered.length < selectedLines.length;
 
     return {
       success: true,
-      content: numberedLines,
+      content: numbered.join('\n'),
       total_lines: lines.length,
-      lines_returned: selectedLines.length,
+      lines_returned: numbered.length,
       offset,
+      ...(truncated && {
+        truncated: true,
+        note: `Output limit reached; continue with offset ${offset + numbered.length}`,
+      }),
     };
   } catch (error) {
     return {
@@ -84,6 +161,26 @@ export async function handleRead(params: ReadParams) {
   }
 }
 
+/**
+ * The whole of a file without line numbers, for /apply to diff a proposed
+ * version against. A file that doesn't exist yet reads as empty.
+ */
+export async function handleReadRaw(params: { projectPath: string; file_path: string }) {
+  try {
+    const absolutePath = resolveProjectPath(params.file_path, params.projectPath);
+    if (!existsSync(absolutePath)) {
+      return { success: true, content: '', exists: false };
+    }
+    const content = await readTextFile(absolutePath, params.file_path, MAX_WRITE_BYTES);
+    return { success: true, content, exists: true };
+  } catch (error) {
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : 'Unknown error',
+    };
+  }
+}
+
 export interface WriteParams {
   projectPath: string;
   file_path: string;
@@ -94,6 +191,14 @@ export async function handleWrite(params: WriteParams) {
   try {
     const absolutePath = resolveProjectPath(params.file_path, params.projectPath);
 
+    const bytes = Buffer.byteLength(params.content);
+    if (bytes > MAX_WRITE_BYTES) {
+      return {
+        success: false,
+        error: `Content is too large to write (${formatSize(bytes)}, limit ${formatSize(MAX_WRITE_BYTES)})`,
+      };
+    }
+
     // Read old content if file exists (for diff display)
     let oldContent: string | null = null;
     if (existsSync(absolutePath)) {
@@ -105,18 +210,23 @@ export async function handleWrite(params: WriteParams) {
       }
     }
 
+    // Models write \n; keep the CRLF line endings of a file being replaced
+    const content = oldContent !== null && detectLineEnding(oldContent) === '\r\n'
+      ? withLineEnding(params.content, '\r\n')
+      : params.content;
+
     // Create directory if it doesn't exist
     const dir = dirname(absolutePath);
     await mkdir(dir, { recursive: true });
 
-    await writeFile(absolutePath, params.content, 'utf-8');
+    await writeFile(absolutePath, content, 'utf-8');
 
     return {
       success: true,
       file_path: params.file_path,
-      bytes_written: Buffer.from(params.content).length,
+      bytes_written: Buffer.from(content).length,
       old_content: oldContent,
-      new_content: params.content,
+      new_content: content,
     };
   } catch (error) {
     return {
@@ -145,10 +255,15 @@ export async function handleEdit(params: EditParams) {
       };
     }
 
-    const content = await readFile(absolutePath, 'utf-8');
+    const content = await readTextFile(absolutePath, params.file_path, MAX_WRITE_BYTES);
+
+    // Strings from the model use \n; match and write them with the file's own line endings
+    const eol = detectLineEnding(content);
+    const oldString = withLineEnding(params.old_string, eol);
+    const newString = withLineEnding(params.new_string, eol);
 
     // Count occurrences
-    const regex = new RegExp(params.old_string.replace(/[.*+?^${}()|[\]\\]/g, '\\$&'), 'g');
+    const regex = new RegExp(oldString.replace(/[.*+?^${}()|[\]\\]/g, '\\$&'), 'g');
     const matches = content.match(regex);
     const occurrences = matches ? matches.length : 0;
 
@@ -166,9 +281,10 @@ export async function handleEdit(params: EditParams) {
       };
     }
 
+    // Replacer functions keep "$&" and friends in the new text literal
     const newContent = params.replace_all
-      ? content.replace(regex, params.new_string)
-      : content.replace(params.old_string, params.new_string);
+      ? content.replace(regex, () => newString)
+      : content.replace(oldString, () => newString);
 
     await writeFile(absolutePath, newContent, 'utf-8');
 
@@ -386,29 +502,31 @@ export async function handleGrep(params: GrepParams) {
     } else if (outputMode === 'content') {
       args.push('-n'); // Show line numbers
       if (params.context_before) {
-        args.push(`-B ${params.context_before}`);
+        args.push('-B', String(params.context_before));
       }
       if (params.context_after) {
-        args.push(`-A ${params.context_after}`);
+        args.push('-A', String(params.context_after));
       }
     }
 
     // Glob pattern
     if (params.glob) {
-      args.push(`--glob '${params.glob}'`);
+      args.push('--glob', params.glob);
     }
 
     // Exclude common directories
-    args.push("--glob '!node_modules/**'");
-    args.push("--glob '!.git/**'");
+    args.push('--glob', '!node_modules/**');
+    args.push('--glob', '!.git/**');
 
-    const grepCommand = `rg ${args.join(' ')} '${params.pattern}' '${searchPath}'`;
+    // Arguments are passed straight to rg, not through a shell, so quoting
+    // works the same on Windows and patterns can't run commands
+    args.push('--', params.pattern, searchPath);
 
     try {
-      const { stdout } = await execAsync(grepCommand);
+      const { stdout } = await execFileAsync('rg', args, { windowsHide: true });
 
       if (outputMode === 'files_with_matches') {
-        const files = stdout.trim().split('\n').filter(Boolean).map(f => toProjectPath(f, params.projectPath));
+        const files = stdout.trim().split(/\r?\n/).filter(Boolean).map(f => toProjectPath(f, params.projectPath));
         return {
           success: true,
           files,
@@ -450,34 +568,97 @@ export interface BashParams {
   command: string;
   description?: string;
   timeout?: number;
+  env?: Record<string, string>; // Session environment from /env
+  // Called with output as it arrives, for showing progress while the command runs
+  onOutput?: (stream: 'stdout' | 'stderr', chunk: string) => void;
+  // Aborted when the tool call is cancelled or times out in the renderer
+  signal?: AbortSignal;
 }
 
+const BASH_MAX_BUFFER = 10 * 1024 * 1024; // 10MB per stream
+
 export async function handleBash(params: BashParams) {
-  try {
-    const timeout = Math.min(params.timeout || 120000, 600000); // Default 2 min, max 10 min
+  const timeout = Math.min(params.timeout || 120000, 600000); // Default 2 min, max 10 min
+
+  return new Promise<Record<string, unknown>>((resolvePromise) => {
+    let stdout = '';
+    let stderr = '';
+    let failure: string | null = null;
 
-    const { stdout, stderr } = await execAsync(params.command, {
+    const child = spawn(params.command, {
       cwd: params.projectPath,
-      timeout,
-      maxBuffer: 10 * 1024 * 1024, // 10MB
+      env: params.env ? { ...process.env, ...params.env } : process.env,
+      shell: getBashShell(),
+      windowsHide: true,
     });
 
-    return {
-      success: true,
-      stdout: stdout || '',
-      stderr: stderr || '',
-      command: params.command,
+    const kill = (reason: string) => {
+      if (!failure) {
+        failure = reason;
+        killProcessTree(child);
+      }
     };
-  } catch (error: any) {
-    return {
-      success: false,
-      error: error.message || 'Unknown error',
-      stdout: error.stdout || '',
-      stderr: error.stderr || '',
-      command: params.command,
-      exit_code: error.code,
+    const timer = setTimeout(() => kill(`Command timed out after ${timeout}ms`), timeout);
+    const onAbort = () => kill('Command cancelled');
+    if (params.signal?.aborted) {
+      onAbort();
+    } else {
+      params.signal?.addEventListener('abort', onAbort, { once: true });
+    }
+
+    // The limit is in bytes, so count the raw data rather than string length
+    const received = { stdout: 0, stderr: 0 };
+    const collect = (stream: 'stdout' | 'stderr') => (data: Buffer) => {
+      const chunk = data.toString();
+      if (stream === 'stdout') {
+        stdout += chunk;
+      } else {
+        stderr += chunk;
+      }
+      received[stream] += data.byteLength;
+      if (received[stream] > BASH_MAX_BUFFER) {
+        kill(`Command output exceeded ${BASH_MAX_BUFFER} bytes`);
+        return;
+      }
+      params.onOutput?.(stream, chunk);
     };
-  }
+    child.stdout.on('data', collect('stdout'));
+    child.stderr.on('data', collect('stderr'));
+
+    child.on('error', (error) => {
+      clearTimeout(timer);
+      params.signal?.removeEventListener('abort', onAbort);
+      resolvePromise({
+        success: false,
+        error: error.message || 'Unknown error',
+        stdout,
+        stderr,
+        command: params.command,
+      });
+    });
+
+    child.on('close', (code, signal) => {
+      clearTimeout(timer);
+      params.signal?.removeEventListener('abort', onAbort);
+      if (code === 0 && !failure) {
+        resolvePromise({
+          success: true,
+          stdout,
+          stderr,
+          command: params.command,
+        });
+        return;
+      }
+      resolvePromise({
+        success: false,
+        error: failure || `Command failed with ${code !== null ? `exit code ${code}` : `signal ${signal}`}: ${params.command}`,
+        stdout,
+        stderr,
+        command: params.command,
+        exit_code: code ?? undefined,
+      });
+    });
+  });
 }
 
 export interface LsParams {
@@ -0,0 +1,165 @@
+import type { ChatMessage } from "./providers/types";
+import { appendToSystemPrompt } from "./system-prompt";
+
+// JSON output mode, for replies that a program will parse. Set with the
+// jsonOutput preference (/json) or `poe -p --json`:
+//
+//   "on"   ask for JSON, and check and repair the reply before showing it
+//   "fix"  the same, and when the reply still isn't valid JSON, ask the model
+//          to correct it once before giving up
+//
+// The reply is held back while it streams. JsonStreamValidator follows its
+// structure as chunks arrive, so at the end it knows whether the reply was cut
+// off inside a string, object or array, and which closers would finish it.
+// Prose or code fences around the JSON are dropped. Only a reply that parses
+// is surfaced as content; otherwise the raw text is followed by an error.
+
+export type JsonOutputMode = "on" | "fix";
+
+export const JSON_FIX_ATTEMPTS = 1;
+
+const INSTRUCTION = "Respond with a single valid JSON object or array and nothing else: no explanation, no comments and no code fences.";
+
+export function parseJsonOutputMode(value: unknown): JsonOutputMode | null {
+    return value === "on" || value === "fix" ? value : null;
+}
+
+/**
+ * Add the JSON instruction to the first system message, or as a new system
+ * message when there is none
+ */
+export function withJsonOutput(messages: ChatMessage[], mode: JsonOutputMode | null): ChatMessage[] {
+    if (!mode) {
+        return messages;
+    }
+    return appendToSystemPrompt(messages, INSTRUCTION);
+}
+
+/**
+ * The follow-up that asks the model to correct a reply that isn't valid JSON
+ */
+export function jsonFixRequest(error: string): ChatMessage {
+    return {
+        role: "user",
+        content: `Your last reply was not valid JSON (${error}). Reply again with only the corrected JSON: no explanation and no code fences.`,
+        timestamp: Date.now(),
+    };
+}
+
+export interface JsonCheck {
+    ok: boolean;
+    text: string; // The JSON when ok, otherwise the raw reply
+    repaired: boolean; // Surrounding text dropped, or closers and commas fixed
+    error?: string;
+}
+
+const parseError = (text: string): string | null => {
+    try {
+        JSON.parse(text);
+        return null;
+    } catch (error) {
+        return error instanceof Error ? error.message : "invalid JSON";
+    }
+};
+
+// Commas before a closer, which JSON doesn't allow but models often write
+const withoutTrailingCommas = (text: string): string => text.replace(/,(\s*[}\]])/g, "$1");
+
+export class JsonStreamValidator {
+    private raw = "";
+    private closers: string[] = []; // What each open object or array needs, innermost last
+    private inString = false;
+    private escaped = false;
+    private start = -1; // Where the top-level object or array begins
+    private end = -1; // Just past where it closes
+
+    get text(): string {
+        return this.raw;
+    }
+
+    push(chunk: string) {
+        const offset = this.raw.length;
+        this.raw += chunk;
+        if (this.end >= 0) {
+            return;
+        }
+
+        for (let i = 0; i < chunk.length; i++) {
+            const c = chunk[i];
+            if (this.start < 0) {
+                // Skip any preamble up to the first bracket
+                if (c === "{" || c === "[") {
+                    this.start = offset + i;
+                    this.closers.push(c === "{" ? "}" : "]");
+                }
+                continue;
+            }
+            if (this.inString) {
+                if (this.escaped) {
+                    this.escaped = false;
+                } else if (c === "\\") {
+                    this.escaped = true;
+                } else if (c === "\"") {
+                    this.inString = false;
+                }
+                continue;
+            }
+            if (c === "\"") {
+                this.inString = true;
+            } else if (c === "{" || c === "[") {
+                this.closers.push(c === "{" ? "}" : "]");
+            } else if (c === "}" || c === "]") {
+                if (this.closers[this.closers.length - 1] === c) {
+                    this.closers.pop();
+                }
+                if (this.closers.length === 0) {
+                    this.end = offset + i + 1;
+                    return;
+                }
+            }
+        }
+    }
+
+    /**
+     * Check the whole reply once it has finished, repairing what can be repaired
+     */
+    finish(): JsonCheck {
+        const trimmed = this.raw.trim();
+        if (this.start < 0) {
+            // No object or array; a bare value is still JSON
+            const error = parseError(trimmed);
+            return error ? { ok: false, text: this.raw, repaired: false, error } : { ok: true, text: trimmed, repaired: false };
+        }
+
+        let candidate: string;
+        if (this.end >= 0) {
+            candidate = this.raw.substring(this.start, this.end);
+        } else {
+            // Cut off: finish the open string, drop a dangling comma or key, then close what's open
+            candidate = this.raw.substring(this.start);
+            if (this.inString) {
+                // A lone backslash at the end escapes nothing yet
+                candidate = `${this.escaped ? candidate.slice(0, -1) : candidate}"`;
+            }
+            candidate = candidate
+                .replace(/\s+$/, "")
+                .replace(/,?\s*"(?:[^"\\]|\\.)*"\s*:$/, "")
+                .replace(/,$/, "");
+            if (this.closers[this.closers.length - 1] === "}") {
+                // A key with no colon yet
+                candidate = candidate.replace(/,\s*"(?:[^"\\]|\\.)*"$/, "").replace(/\{\s*"(?:[^"\\]|\\.)*"$/, "{");
+            }
+            candidate += [...this.closers].reverse().join("");
+        }
+
+        const error = parseError(candidate);
+        if (!error) {
+            return { ok: true, text: candidate, repaired: candidate !== trimmed };
+        }
+        const fixed = withoutTrailingCommas(candidate);
+        if (fixed !== candidate && !parseError(fixed)) {
+            return { ok: true, text: fixed, repaired: true };
+        }
+        return { ok: false, text: this.raw, repaired: false, error: this.end < 0 ? `cut off: ${error}` : error };
+    }
+}
@@ -0,0 +1,281 @@
+import { appendFile, mkdir, readdir, unlink } from "node:fs/promises";
+import path from "node:path";
+import { format } from "node:util";
+import { ENGINE_EVENT_KINDS, type EngineEventKind } from "../src/types/chat";
+
+// Leveled, structured logging for the main process. Entries go to the terminal
+// as text or JSON lines, to a daily file in the config directory's logs/, and
+// to a buffer the app's /debug pane reads. The existing console calls are
+// routed through it by captureConsole: console.log is debug, console.info is
+// info, console.warn warn and console.error error.
+//
+// Configured by the "logging" preference:
+//
+//   { "level": "info", "format": "text", "file": true, "fileLevel": "info" }
+//
+// The file stays at info unless fileLevel asks for more: console.log calls
+// carry tool arguments and message text, which shouldn't sit on disk by default.
+//
+// POE_LOG_LEVEL overrides the terminal level, e.g. POE_LOG_LEVEL=debug poe.
+//
+// Entries logged with event() are also engine events: what the engine did
+// that a user may want to know about without the debug noise, kept apart for
+// the /events pane.
+
+export type LogLevel = "debug" | "info" | "warn" | "error";
+
+const LEVELS: Record<LogLevel, number> = { debug: 10, info: 20, warn: 30, error: 40 };
+const MEMORY_ENTRIES = 1000;
+const MEMORY_EVENTS = 300;
+const LOG_FILE_DAYS = 7;
+
+export interface LogEntry {
+    time: string; // ISO timestamp
+    level: LogLevel;
+    scope: string; // Part of the app, e.g. "chat" or "mcp"
+    message: string;
+    fields?: Record<string, unknown>;
+    event?: EngineEventKind;
+}
+
+export interface Logger {
+    debug(message: string, fields?: Record<string, unknown>): void;
+    info(message: string, fields?: Record<string, unknown>): void;
+    warn(message: string, fields?: Record<string, unknown>): void;
+    error(message: string, fields?: Record<string, unknown>): void;
+    event(kind: EngineEventKind, message: string, fields?: Record<string, unknown>, level?: LogLevel): void;
+    child(scope: string): Logger;
+}
+
+export interface LogSink {
+    level: LogLevel;
+    write(entry: LogEntry): void;
+}
+
+export interface LoggingSettings {
+    level?: LogLevel; // Terminal output (default info)
+    format?: "text" | "json";
+    file?: boolean; // Write logs/poe-YYYY-MM-DD.log (default true)
+    fileLevel?: LogLevel; // default info
+}
+
+export function isLogLevel(value: unknown): value is LogLevel {
+    return typeof value === "string" && value in LEVELS;
+}
+
+export function isEngineEventKind(value: unknown): value is EngineEventKind {
+    return typeof value === "string" && ENGINE_EVENT_KINDS.includes(value as EngineEventKind);
+}
+
+// Console methods from before captureConsole, so sinks don't feed themselves
+const originalConsole = {
+    log: console.log.bind(console),
+    error: console.error.bind(console),
+};
+
+const sinks = new Set<LogSink>();
+
+export function addLogSink(sink: LogSink): () => void {
+    sinks.add(sink);
+    return () => {
+        sinks.delete(sink);
+    };
+}
+
+function emit(entry: LogEntry) {
+    for (const sink of sinks) {
+        if (LEVELS[entry.level] >= LEVELS[sink.level]) {
+            try {
+                sink.write(entry);
+            } catch {
+                // A broken sink must not break the code that logged
+            }
+        }
+    }
+}
+
+// Errors don't survive JSON.stringify; keep what's useful about them
+function serializeFields(fields: Record<string, unknown>): Record<string, unknown> {
+    const result: Record<string, unknown> = {};
+    for (const [key, value] of Object.entries(fields)) {
+        result[key] = value instanceof Error ? { name: value.name, message: value.message, stack: value.stack } : value;
+    }
+    return result;
+}
+
+export function createLogger(scope: string): Logger {
+    const write = (level: LogLevel, message: string, fields?: Record<string, unknown>, event?: EngineEventKind) => {
+        emit({
+            time: new Date().toISOString(),
+            level,
+            scope,
+            message,
+            ...(fields && Object.keys(fields).length > 0 && { fields: serializeFields(fields) }),
+            ...(event && { event }),
+        });
+    };
+    const log = (level: LogLevel) => (message: string, fields?: Record<string, unknown>) => write(level, message, fields);
+    return {
+        debug: log("debug"),
+        info: log("info"),
+        warn: log("warn"),
+        error: log("error"),
+        event: (kind, message, fields, level = "info") => write(level, message, fields, kind),
+        child: (name: string) => createLogger(`${scope}:${name}`),
+    };
+}
+
+// "14:03:07.412 WARN  chat  Empty response, retrying attempt=1"
+export function formatLogEntry(entry: LogEntry): string {
+    const time = entry.time.substring(11, 23);
+    const fields = entry.fields
+        ? Object.entries(entry.fields).map(([key, value]) => `${key}=${typeof value === "string" ? value : JSON.stringify(value)}`).join(" ")
+        : "";
+    return `${time} ${entry.level.toUpperCase().padEnd(5)} ${entry.scope}  ${entry.message}${fields ? ` ${fields}` : ""}`;
+}
+
+// Terminal output. Headless runs keep stdout for the answer, so everything goes to stderr there.
+const terminalSink: LogSink & { format: "text" | "json"; stderrOnly: boolean } = {
+    level: "info",
+    format: "text",
+    stderrOnly: false,
+    write(entry) {
+        const line = this.format === "json" ? JSON.stringify(entry) : formatLogEntry(entry);
+        if (this.stderrOnly || LEVELS[entry.level] >= LEVELS.warn) {
+            originalConsole.error(line);
+        } else {
+            originalConsole.log(line);
+        }
+    },
+};
+
+// The last entries at any level, for the /debug pane, and the last engine
+// events apart from them, so debug output doesn't push events out
+const recentEntries: LogEntry[] = [];
+const recentEvents: LogEntry[] = [];
+const memorySink: LogSink = {
+    level: "debug",
+    write(entry) {
+        recentEntries.push(entry);
+        if (recentEntries.length > MEMORY_ENTRIES) {
+            recentEntries.splice(0, recentEntries.length - MEMORY_ENTRIES);
+        }
+        if (entry.event) {
+            recentEvents.push(entry);
+            if (recentEvents.length > MEMORY_EVENTS) {
+                recentEvents.splice(0, recentEvents.length - MEMORY_EVENTS);
+            }
+        }
+    },
+};
+
+export function recentLogEntries(): LogEntry[] {
+    return [...recentEntries];
+}
+
+export function recentEngineEvents(): LogEntry[] {
+    return [...recentEvents];
+}
+
+// JSON lines, one file per day; writes are chained so entries stay in order
+class FileSink implements LogSink {
+    level: LogLevel = "info";
+    private pending: Promise<void> = Promise.resolve();
+    private ready: Promise<void>;
+
+    constructor(private directory: string) {
+        this.ready = mkdir(directory, { recursive: true }).then(() => this.prune()).catch(() => undefined);
+    }
+
+    get currentFile(): string {
+        return path.join(this.directory, `poe-${new Date().toISOString().substring(0, 10)}.log`);
+    }
+
+    write(entry: LogEntry) {
+        const line = `${JSON.stringify(entry)}\n`;
+        const file = this.currentFile;
+        this.pending = this.pending
+            .then(() => this.ready)
+            .then(() => appendFile(file, line, "utf-8"))
+            .catch(error => originalConsole.error("Failed to write log file:", error));
+    }
+
+    flush(): Promise<void> {
+        return this.pending;
+    }
+
+    private async prune() {
+        const cutoff = new Date(Date.now() - LOG_FILE_DAYS * 24 * 60 * 60 * 1000).toISOString().substring(0, 10);
+        for (const name of await readdir(this.directory)) {
+            const day = name.match(/^poe-(\d{4}-\d{2}-\d{2})\.log$/)?.[1];
+            if (day && day < cutoff) {
+                await unlink(path.join(this.directory, name)).catch(() => undefined);
+            }
+        }
+    }
+}
+
+let fileSink: FileSink | null = null;
+let removeFileSink: (() => void) | null = null;
+
+/**
+ * Start logging with the defaults: terminal at info, memory buffer at debug.
+ * `logDirectory` is where log files go once configureLogging allows them.
+ */
+export function initLogging(options: { headless: boolean; logDirectory: string }) {
+    terminalSink.stderrOnly = options.headless;
+    addLogSink(terminalSink);
+    addLogSink(memorySink);
+    fileSink = new FileSink(options.logDirectory);
+    applyTerminalLevel(undefined);
+}
+
+function applyTerminalLevel(level: unknown) {
+    const override = process.env.POE_LOG_LEVEL?.toLowerCase();
+    terminalSink.level = isLogLevel(override) ? override : isLogLevel(level) ? level : "info";
+}
+
+/**
+ * Apply the "logging" preference, read once the app is ready
+ */
+export function configureLogging(settings: LoggingSettings | null) {
+    applyTerminalLevel(settings?.level);
+    terminalSink.format = settings?.format === "json" ? "json" : "text";
+
+    if (!fileSink) {
+        return;
+    }
+    fileSink.level = isLogLevel(settings?.fileLevel) ? settings.fileLevel : "info";
+    if (settings?.file === false) {
+        removeFileSink?.();
+        removeFileSink = null;
+    } else if (!removeFileSink) {
+        // Include what was logged during startup, before the preference was read
+        for (const entry of recentEntries) {
+            if (LEVELS[entry.level] >= LEVELS[fileSink.level]) {
+                fileSink.write(entry);
+            }
+        }
+        removeFileSink = addLogSink(fileSink);
+    }
+}
+
+export function currentLogFile(): string | null {
+    return fileSink && removeFileSink ? fileSink.currentFile : null;
+}
+
+export function flushLogs(): Promise<void> {
+    return fileSink?.flush() ?? Promise.resolve();
+}
+
+/**
+ * Send console.log/info/warn/error through `logger`, so calls that predate it
+ * get levels and reach every sink
+ */
+export function captureConsole(logger: Logger) {
+    console.log = (...args: unknown[]) => logger.debug(format(...args));
+    console.debug = console.log;
+    console.info = (...args: unknown[]) => logger.info(format(...args));
+    console.warn = (...args: unknown[]) => logger.warn(format(...args));
+    console.error = (...args: unknown[]) => logger.error(format(...args));
+}
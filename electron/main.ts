@@ -1,15 +1,30 @@
-import { app, BrowserWindow, ipcMain, dialog, Menu } from "electron";
+import { app, BrowserWindow, ipcMain, dialog, Menu, clipboard, shell } from "electron";
 import { fileURLToPath } from "node:url";
 import path from "node:path";
-import { homedir } from "node:os";
-import { existsSync, statSync, mkdirSync, readdirSync } from "node:fs";
-import { readFile, writeFile, unlink } from "node:fs/promises";
+import { homedir, tmpdir } from "node:os";
+import { existsSync, statSync, mkdirSync, readdirSync, readFileSync, copyFileSync, rmSync } from "node:fs";
+import { readFile, writeFile, appendFile, unlink, mkdir } from "node:fs/promises";
 import { spawn } from "node:child_process";
-import { createHash } from "node:crypto";
+import { createHash, randomUUID } from "node:crypto";
 import yaml from "js-yaml";
-import { mcpManager } from "./mcp-manager";
+import { mcpManager, type MCPElicitHandler, type MCPServerConfig } from "./mcp-manager";
 import { providerRegistry } from "./providers/ProviderRegistry";
-import type { ChatMessage as ProviderChatMessage, ToolCall, ToolResult } from "./providers/types";
+import { getModelCapabilities } from "./providers/capabilities";
+import { applyThinkingFormat } from "./providers/thinking";
+import { DEFAULT_REQUEST_RETRY, type RequestRetryOptions } from "./providers/retry";
+import { rateLimiter, estimateTokens, type RateLimitConfig } from "./rate-limiter";
+import { parseLaunchOptions, type LaunchOptions } from "./cli";
+import { runAttach, runHeadless, runIndex } from "./headless";
+import { createEngine, type EngineMessage } from "./engine";
+import { interpolateEnv } from "./config-env";
+import { IS_WINDOWS, expandHome, withLineEnding } from "./platform";
+import { createTranscriptArchive, type TranscriptRecord, type TranscriptSinkConfig } from "./transcript";
+import type { ChatMessage as ProviderChatMessage, ChatChunk, GenerationOptions, ToolDefinition } from "./providers/types";
+import { createChatEventStamper, type ChatEvent, type ChatEventPayload } from "../src/types/events";
+import { isLocalProvider, isLocalURL } from "../src/utils/modelUtils";
+import { ATTACHMENT_EXTENSIONS, checkAttachmentSize } from "../src/utils/attachments";
+import { parsePromptTemplate } from "../src/utils/promptTemplates";
+import { DEFAULT_KEY_BINDINGS, resolveKeyBindings, toAccelerator } from "../src/utils/keyBindings";
 import {
   handleRead,
   handleWrite,
@@ -21,12 +36,18 @@ import {
   handleMove,
   handleRm,
   handleMkdir,
+  handleReadRaw,
+  resolveProjectPath,
 } from "./internal-tools";
+import { listWatches, startWatch, stopWatch, stopWatchesFor, type WatchParams } from "./path-watcher";
+import { handleWebSearch, type WebSearchConfig } from "./web-search";
+import { handleFetchUrl } from "./url-reader";
+import { IndexStore, indexPath, ragStatus, resolveEmbedder, type RagSettings } from "./rag";
+import { DEFAULT_OBSERVER_PORT, ObserverServer, type ObservedMessage } from "./observer-server";
+import { addLogSink, captureConsole, configureLogging, createLogger, currentLogFile, flushLogs, initLogging, isEngineEventKind, recentEngineEvents, recentLogEntries, type LoggingSettings } from "./logger";
 
 const __dirname = path.dirname(fileURLToPath(import.meta.url));
 
-const CONFIG_DIR_NAME = "poe";
-
 process.env.APP_ROOT = path.join(__dirname, "..");
 
 export const VITE_DEV_SERVER_URL = process.env["VITE_DEV_SERVER_URL"];
@@ -42,6 +63,40 @@ process.env.VITE_PUBLIC = VITE_DEV_SERVER_URL
 let win: BrowserWindow | null;
 let currentStreamAbortController: AbortController | null = null;
 
+// Handed to the renderer once, so a reload doesn't reopen the session again
+let pendingLaunchOptions: LaunchOptions | null = parseLaunchOptions(process.argv, app.isPackaged, process.cwd());
+
+// poe --safe starts from defaults to rule out customizations: the config
+// directory is a throwaway one holding only a copy of the providers, so no
+// tools config, hooks, MCP servers, prompts or preferences are loaded and
+// nothing changed during the run reaches the real one. Tools are also never
+// offered to the model (see chat-send-message).
+const safeMode = pendingLaunchOptions?.safe === true;
+const CONFIG_DIR_NAME = safeMode ? `poe-safe-${process.pid}` : "poe";
+if (safeMode) {
+  const userConfigDir = path.join(homedir(), ".config", "poe");
+  const safeConfigDir = path.join(homedir(), ".config", CONFIG_DIR_NAME);
+  mkdirSync(safeConfigDir, { recursive: true });
+  for (const filename of ["providers.yaml", "providers.json"]) {
+    if (existsSync(path.join(userConfigDir, filename))) {
+      copyFileSync(path.join(userConfigDir, filename), path.join(safeConfigDir, filename));
+      break;
+    }
+  }
+  // app.exit() skips the quit events, so clean up on process exit
+  process.on("exit", () => rmSync(safeConfigDir, { recursive: true, force: true }));
+  console.error(`Safe mode: starting with default settings (config in ${safeConfigDir}, removed on exit)`);
+}
+
+// poe -p, poe index and poe attach write to stdout without opening a window, so logging moves to stderr
+const headlessOptions = pendingLaunchOptions?.print != null || pendingLaunchOptions?.index != null || pendingLaunchOptions?.attach != null
+  ? pendingLaunchOptions
+  : null;
+initLogging({ headless: headlessOptions !== null, logDirectory: path.join(homedir(), ".config", CONFIG_DIR_NAME, "logs") });
+captureConsole(createLogger("main"));
+const chatLog = createLogger("chat");
+const toolLog = createLogger("tools");
+
 function createWindow() {
   win = new BrowserWindow({
     width: 1200,
@@ -54,6 +109,20 @@ function createWindow() {
     },
   });
 
+  // Cmd/Ctrl+Q, the title bar button and /quit all end up here
+  win.on("close", (event) => {
+    if (closeConfirmed) {
+      return;
+    }
+    event.preventDefault();
+    confirmClose().then((confirmed) => {
+      if (confirmed && win) {
+        closeConfirmed = true;
+        win.close();
+      }
+    });
+  });
+
   if (VITE_DEV_SERVER_URL) {
     win.loadURL(VITE_DEV_SERVER_URL);
   } else {
@@ -61,6 +130,28 @@ function createWindow() {
   }
 }
 
+// The confirmQuit preference decides when closing asks first: "always",
+// "generating" (default, only while a response is streaming) or "never"
+let closeConfirmed = false;
+
+async function confirmClose(): Promise<boolean> {
+  const setting = ((await readPreference("confirmQuit")) as string | null) ?? "generating";
+  const generating = currentStreamAbortController !== null;
+  if (setting === "never" || (setting !== "always" && !generating) || !win) {
+    return true;
+  }
+
+  const { response } = await dialog.showMessageBox(win, {
+    type: "question",
+    buttons: ["Quit", "Cancel"],
+    defaultId: 1,
+    cancelId: 1,
+    message: generating ? "A response is still being generated. Quit anyway?" : "Quit POE?",
+    detail: generating ? "Press Esc to cancel the response and keep the window open." : undefined,
+  });
+  return response === 0;
+}
+
 function launchNewInstance() {
   console.log("Launching new instance of the application");
 
@@ -85,7 +176,35 @@ function launchNewInstance() {
   }
 }
 
-app.whenReady().then(() => {
+app.whenReady().then(async () => {
+  readPreference("logging").then(settings => configureLogging(settings as LoggingSettings | null));
+
+  if (headlessOptions) {
+    app.dock?.hide();
+    const deps = {
+      loadConfig: async () => {
+        await loadProviders();
+        await loadRateLimits();
+      },
+      readPreference,
+      loadRequestRetry,
+    };
+    const run = headlessOptions.attach != null
+      ? runAttach(headlessOptions)
+      : headlessOptions.index != null
+      ? runIndex(headlessOptions, { ...deps, store: ragStore })
+      : runHeadless(headlessOptions, deps);
+    run.then((code) => {
+      // Let piped output and the log file drain before exiting
+      flushLogs().then(() => process.stdout.write("", () => app.exit(code)));
+    });
+    return;
+  }
+
+  // Quit follows the keyBindings preference; the renderer reads the rest
+  const { bindings } = resolveKeyBindings(await readPreference("keyBindings"));
+  const quitAccelerator = toAccelerator(bindings.quit) ?? toAccelerator(DEFAULT_KEY_BINDINGS.quit)!;
+
   // Create application menu
   const template: Electron.MenuItemConstructorOptions[] = [
     {
@@ -99,7 +218,7 @@ app.whenReady().then(() => {
         { type: "separator" },
         {
           label: "Quit",
-          accelerator: "CmdOrCtrl+Q",
+          accelerator: quitAccelerator,
           click: () => app.quit(),
         },
       ],
@@ -142,7 +261,7 @@ app.whenReady().then(() => {
         { role: "hideOthers" },
         { role: "unhide" },
         { type: "separator" },
-        { role: "quit" },
+        { role: "quit", accelerator: quitAccelerator },
       ],
     });
   }
@@ -155,10 +274,15 @@ app.whenReady().then(() => {
 
 app.on("window-all-closed", async () => {
   await mcpManager.stopAll();
+  await transcriptArchive.flush();
+  await flushLogs();
   app.quit();
 });
 
 app.on("activate", () => {
+  if (headlessOptions) {
+    return;
+  }
   // On macOS, clicking the dock icon should always launch a new instance
   launchNewInstance();
 });
@@ -202,6 +326,18 @@ ipcMain.handle("database-demo", async () => {
   return await demoVectorDatabase();
 });
 
+ipcMain.handle("get-launch-options", async () => {
+  console.log("Received get-launch-options");
+  const options = pendingLaunchOptions;
+  pendingLaunchOptions = null;
+  return options;
+});
+
+ipcMain.handle("get-safe-mode", async () => {
+  console.log("Received get-safe-mode");
+  return safeMode;
+});
+
 // Directory selection IPC handlers
 ipcMain.handle("select-directory", async () => {
   console.log("Received select-directory");
@@ -218,14 +354,405 @@ ipcMain.handle("select-directory", async () => {
   return result.filePaths[0];
 });
 
-ipcMain.handle("expand-path", async (_, inputPath: string) => {
-  console.log("Received expand-path:", inputPath);
+// Export IPC handlers
+ipcMain.handle(
+  "export-save-file",
+  async (
+    _,
+    defaultName: string,
+    content: string,
+    filters: Array<{ name: string; extensions: string[] }>,
+    filePath?: string,
+    baseDir?: string,
+  ) => {
+    console.log("Received export-save-file:", filePath || defaultName);
+    try {
+      if (filePath) {
+        const target = path.resolve(baseDir || app.getPath("documents"), expandHome(filePath));
+        await mkdir(path.dirname(target), { recursive: true });
+        await writeFile(target, content, "utf-8");
+        return { success: true, filePath: target, error: null };
+      }
+
+      const options = {
+        defaultPath: path.join(app.getPath("documents"), defaultName),
+        filters,
+      };
+      const result = win
+        ? await dialog.showSaveDialog(win, options)
+        : await dialog.showSaveDialog(options);
+
+      if (result.canceled || !result.filePath) {
+        return { success: true, filePath: null, error: null };
+      }
+
+      await writeFile(result.filePath, content, "utf-8");
+      return { success: true, filePath: result.filePath, error: null };
+    } catch (error) {
+      console.error("Failed to save export:", error);
+      return {
+        success: false,
+        filePath: null,
+        error: error instanceof Error ? error.message : "Unknown error",
+      };
+    }
+  },
+);
+
+// The run-command hooks in hooks.yaml, read here rather than taken from the
+// renderer, so only a command the user configured can be started
+async function configuredHookCommands(): Promise<Set<string>> {
+  const commands = new Set<string>();
+  const hooksPath = path.join(homedir(), ".config", CONFIG_DIR_NAME, "hooks.yaml");
+  if (!existsSync(hooksPath)) {
+    return commands;
+  }
+  const collect = (value: unknown) => {
+    if (typeof value === "string") {
+      if (value.startsWith("run-command:")) {
+        commands.add(value.substring("run-command:".length));
+      }
+    } else if (value && typeof value === "object") {
+      Object.values(value).forEach(collect);
+    }
+  };
+  collect(yaml.load(await readFile(hooksPath, "utf-8")));
+  return commands;
+}
+
+// Commands from hooks.yaml, e.g. notify-send for a long request. They run in
+// the background; a command still going after a minute is killed. Input, such
+// as a finished turn as JSON, is written to the command's stdin. A command
+// that would run in a project only does so once the workspace is trusted.
+ipcMain.handle("hook-run-command", async (_, command: string, options: { cwd?: string; env?: Record<string, string>; input?: string }) => {
+  console.log("Received hook-run-command:", command);
+  try {
+    if (!(await configuredHookCommands()).has(command)) {
+      throw new Error("Only run-command hooks from hooks.yaml can be run");
+    }
+    if (options.cwd && !isWorkspaceTrusted(options.cwd)) {
+      throw new Error("Hook commands don't run in a workspace that isn't trusted. Trust it with /trust yes.");
+    }
+    const child = spawn(command, {
+      shell: true,
+      cwd: options.cwd || homedir(),
+      env: { ...process.env, ...options.env },
+      stdio: [options.input !== undefined ? "pipe" : "ignore", "ignore", "ignore"],
+      windowsHide: true,
+    });
+    if (child.stdin) {
+      // A command that doesn't read its input closes the pipe early
+      child.stdin.on("error", () => {});
+      child.stdin.end(options.input);
+    }
+    const timer = setTimeout(() => child.kill(), 60_000);
+    child.on("exit", () => clearTimeout(timer));
+    child.on("error", (error) => {
+      clearTimeout(timer);
+      console.error("Hook command failed:", command, error);
+    });
+    return { success: true, error: null };
+  } catch (error) {
+    console.error("Failed to run hook command:", error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// Files written by hooks, e.g. save-turn notes. Relative paths are from the
+// project, once it is trusted; anything else must be in the notes directory
+// under the config directory (relative paths too, without a project).
+ipcMain.handle("hook-append-file", async (_, filePath: string, content: string, cwd?: string) => {
+  console.log("Received hook-append-file:", filePath);
+  try {
+    const expanded = expandHome(filePath);
+    let target: string;
+    if (cwd && !path.isAbsolute(expanded)) {
+      if (!isWorkspaceTrusted(cwd)) {
+        throw new Error("Hooks don't write to a workspace that isn't trusted. Trust it with /trust yes.");
+      }
+      target = resolveProjectPath(`/${expanded}`, cwd);
+    } else {
+      const notesDir = path.join(homedir(), ".config", CONFIG_DIR_NAME, "notes");
+      const inNotes = path.relative(notesDir, path.resolve(notesDir, expanded));
+      if (inNotes.startsWith("..") || path.isAbsolute(inNotes)) {
+        throw new Error(`Hooks only write to files in the project or in ${notesDir}: ${filePath}`);
+      }
+      await mkdir(notesDir, { recursive: true });
+      target = resolveProjectPath(`/${inNotes}`, notesDir);
+    }
+    await mkdir(path.dirname(target), { recursive: true });
+    await appendFile(target, content, "utf-8");
+    return { success: true, filePath: target, error: null };
+  } catch (error) {
+    console.error("Failed to append hook file:", error);
+    return {
+      success: false,
+      filePath: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// Copy through the main process so it works without focus or clipboard permission.
+// Windows programs such as Notepad expect CRLF line breaks in pasted text.
+ipcMain.handle("clipboard-write-text", async (_, text: string) => {
+  console.log("Received clipboard-write-text:", text.length, "chars");
+  try {
+    clipboard.writeText(IS_WINDOWS ? withLineEnding(text, "\r\n") : text);
+    return { success: true, error: null };
+  } catch (error) {
+    console.error("Failed to write clipboard:", error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// For {{clipboard}} in /prompt templates
+ipcMain.handle("clipboard-read-text", async () => {
+  console.log("Received clipboard-read-text");
+  try {
+    return { success: true, text: clipboard.readText(), error: null };
+  } catch (error) {
+    console.error("Failed to read clipboard:", error);
+    return {
+      success: false,
+      text: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// Images that can't be shown inline (unsupported type or too large) are written
+// to a temp file so the transcript can point at them
+ipcMain.handle("image-save-temp", async (_, data: string, mimeType: string) => {
+  console.log("Received image-save-temp:", mimeType);
+  try {
+    const dir = path.join(tmpdir(), "poe-images");
+    mkdirSync(dir, { recursive: true });
+    const subtype = mimeType.split("/")[1]?.split("+")[0] || "bin";
+    const filePath = path.join(dir, `${randomUUID()}.${subtype.replace(/[^a-z0-9]/gi, "")}`);
+    await writeFile(filePath, Buffer.from(data, "base64"));
+    return { success: true, filePath, error: null };
+  } catch (error) {
+    console.error("Failed to save image:", error);
+    return {
+      success: false,
+      filePath: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
 
-  if (inputPath.startsWith("~")) {
-    return path.join(homedir(), inputPath.slice(1));
+// An image attached with /attach, read as base64. Relative paths are from the project.
+ipcMain.handle("image-read-file", async (_, projectPath: string | null, filePath: string) => {
+  console.log("Received image-read-file:", filePath);
+  try {
+    const target = path.resolve(projectPath || homedir(), expandHome(filePath));
+    const mimeType = ATTACHMENT_EXTENSIONS[path.extname(target).toLowerCase()];
+    if (!mimeType) {
+      throw new Error(`Not a supported image type (${Object.keys(ATTACHMENT_EXTENSIONS).join(", ")}): ${filePath}`);
+    }
+    const sizeError = checkAttachmentSize(statSync(target).size);
+    if (sizeError) {
+      throw new Error(sizeError);
+    }
+    const data = (await readFile(target)).toString("base64");
+    return { success: true, image: { mimeType, data, path: target }, error: null };
+  } catch (error) {
+    console.error("Failed to read image:", error);
+    return {
+      success: false,
+      image: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
   }
+});
+
+// Code blocks saved with /snip. File names can come from the reply, so they
+// are relative paths kept inside a trusted project, like the write tool's.
+// Unless overwrite is set nothing is written when any of the files exists,
+// so the user can confirm first.
+ipcMain.handle(
+  "snippet-write-files",
+  async (_, projectPath: string | null, files: Array<{ path: string; content: string }>, overwrite: boolean) => {
+    console.log("Received snippet-write-files:", files.length, "files");
+    try {
+      if (!projectPath) {
+        throw new Error("Open a project folder before saving code blocks");
+      }
+      if (!isWorkspaceTrusted(projectPath)) {
+        throw new Error("Code blocks aren't saved in a workspace that isn't trusted. Trust it with /trust yes.");
+      }
+      const targets = files.map((file) => {
+        if (file.path.startsWith("~") || path.isAbsolute(file.path) || path.win32.isAbsolute(file.path)) {
+          throw new Error(`Code blocks are saved under the project, use a relative path: ${file.path}`);
+        }
+        return { ...file, target: resolveProjectPath(`/${file.path}`, projectPath) };
+      });
+      const existing = targets.filter((file) => existsSync(file.target)).map((file) => file.path);
+      if (!overwrite && existing.length > 0) {
+        return { success: true, written: [], existing, error: null };
+      }
+
+      for (const file of targets) {
+        await mkdir(path.dirname(file.target), { recursive: true });
+        await writeFile(file.target, file.content, "utf-8");
+      }
+      return { success: true, written: targets.map((file) => file.target), existing, error: null };
+    } catch (error) {
+      console.error("Failed to write snippets:", error);
+      return {
+        success: false,
+        written: [],
+        existing: [],
+        error: error instanceof Error ? error.message : "Unknown error",
+      };
+    }
+  },
+);
+
+// Piper TTS: text on stdin, WAV written to a temp file and returned as base64
+ipcMain.handle(
+  "tts-synthesize",
+  async (_, text: string, options: { binary?: string; model: string; rate?: number }) => {
+    console.log("Received tts-synthesize:", text.length, "chars");
+    const outputFile = path.join(tmpdir(), `poe-tts-${randomUUID()}.wav`);
+    try {
+      const args = ["--model", expandHome(options.model), "--output_file", outputFile];
+      if (options.rate && options.rate > 0) {
+        // piper's length scale is the inverse of speaking rate
+        args.push("--length_scale", String(1 / options.rate));
+      }
+
+      await new Promise<void>((resolve, reject) => {
+        const child = spawn(expandHome(options.binary || "piper"), args, { stdio: ["pipe", "ignore", "pipe"], windowsHide: true });
+        let stderr = "";
+        child.stderr.on("data", (data) => {
+          stderr += data.toString();
+        });
+        child.on("error", reject);
+        child.on("close", (code) => {
+          if (code === 0) {
+            resolve();
+          } else {
+            reject(new Error(`piper exited with code ${code}: ${stderr.trim().split("\n").pop() || "no output"}`));
+          }
+        });
+        child.stdin.end(text);
+      });
+
+      const audio = await readFile(outputFile);
+      return { success: true, audio: audio.toString("base64"), error: null };
+    } catch (error) {
+      console.error("Failed to synthesize speech:", error);
+      return {
+        success: false,
+        audio: null,
+        error: error instanceof Error ? error.message : "Unknown error",
+      };
+    } finally {
+      unlink(outputFile).catch(() => undefined);
+    }
+  },
+);
+
+// Speech to text for voice input: a local whisper.cpp binary, or any
+// OpenAI-compatible /audio/transcriptions endpoint
+ipcMain.handle(
+  "stt-transcribe",
+  async (
+    _,
+    audio: string,
+    settings: {
+      backend?: "whisper-cpp" | "http";
+      language?: string;
+      whisperCpp?: { binary?: string; model?: string };
+      http?: { url?: string; model?: string; apiKey?: string };
+    },
+  ) => {
+    console.log("Received stt-transcribe:", settings.backend || "whisper-cpp");
+    const wav = Buffer.from(audio, "base64");
+
+    try {
+      if (settings.backend === "http") {
+        if (!settings.http?.url) {
+          throw new Error("Set stt.http.url to a transcription endpoint");
+        }
+        if ((await readPreference("offlineMode")) === true && !isLocalURL(settings.http.url)) {
+          throw new Error(`Offline mode is on: ${settings.http.url} is not a local transcription endpoint`);
+        }
+        const form = new FormData();
+        form.append("file", new Blob([wav], { type: "audio/wav" }), "speech.wav");
+        form.append("model", settings.http.model || "whisper-1");
+        if (settings.language) {
+          form.append("language", settings.language);
+        }
+        const response = await fetch(settings.http.url, {
+          method: "POST",
+          headers: settings.http.apiKey ? { Authorization: `Bearer ${settings.http.apiKey}` } : undefined,
+          body: form,
+        });
+        if (!response.ok) {
+          throw new Error(`Transcription endpoint returned ${response.status}: ${await response.text()}`);
+        }
+        const data = (await response.json()) as { text?: string };
+        return { success: true, text: data.text || "", error: null };
+      }
+
+      if (!settings.whisperCpp?.model) {
+        throw new Error("Set stt.whisperCpp.model to a whisper.cpp model (ggml-*.bin)");
+      }
+      const inputFile = path.join(tmpdir(), `poe-stt-${randomUUID()}.wav`);
+      await writeFile(inputFile, wav);
+      try {
+        const args = ["-m", expandHome(settings.whisperCpp.model), "-f", inputFile, "-nt", "-np"];
+        if (settings.language) {
+          args.push("-l", settings.language);
+        }
+        const text = await new Promise<string>((resolve, reject) => {
+          const child = spawn(expandHome(settings.whisperCpp?.binary || "whisper-cli"), args, { windowsHide: true });
+          let stdout = "";
+          let stderr = "";
+          child.stdout.on("data", (data) => {
+            stdout += data.toString();
+          });
+          child.stderr.on("data", (data) => {
+            stderr += data.toString();
+          });
+          child.on("error", reject);
+          child.on("close", (code) => {
+            if (code === 0) {
+              resolve(stdout);
+            } else {
+              reject(new Error(`whisper.cpp exited with code ${code}: ${stderr.trim().split("\n").pop() || "no output"}`));
+            }
+          });
+        });
+        // whisper.cpp prints one line per segment
+        return { success: true, text: text.split("\n").map((line) => line.trim()).filter(Boolean).join(" "), error: null };
+      } finally {
+        unlink(inputFile).catch(() => undefined);
+      }
+    } catch (error) {
+      console.error("Failed to transcribe audio:", error);
+      return {
+        success: false,
+        text: null,
+        error: error instanceof Error ? error.message : "Unknown error",
+      };
+    }
+  },
+);
+
+ipcMain.handle("expand-path", async (_, inputPath: string) => {
+  console.log("Received expand-path:", inputPath);
 
-  return inputPath;
+  return expandHome(inputPath);
 });
 
 ipcMain.handle("validate-directory", async (_, dirPath: string) => {
@@ -365,6 +892,26 @@ ipcMain.handle("config-read", async (_, filename: string) => {
   }
 });
 
+// Providers as the registry sees them (environment references resolved), for
+// the renderer's provider list; settings edit the raw file through config-read
+ipcMain.handle("config-read-providers", async () => {
+  console.log("Received config-read-providers");
+  try {
+    const providers = await readProvidersConfig();
+    if (!providers) {
+      return { success: false, providers: [], error: "File does not exist" };
+    }
+    return { success: true, providers, error: null };
+  } catch (error) {
+    console.error("Failed to read providers:", error);
+    return {
+      success: false,
+      providers: [],
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
 ipcMain.handle("config-write", async (_, filename: string, content: string) => {
   try {
     const configDir = path.join(homedir(), ".config", CONFIG_DIR_NAME);
@@ -474,6 +1021,7 @@ ipcMain.handle(
     isCustomName?: boolean,
     providerId?: string,
     modelId?: string,
+    settings?: { env?: Record<string, string>; systemPrompt?: string; options?: GenerationOptions },
   ) => {
     console.log(
       "Received session-save for project:",
@@ -502,15 +1050,29 @@ ipcMain.handle(
         `${sanitizedPath}_${sessionId}.json`,
       );
 
+      // Reasoning stays on screen but is dropped from disk when the user opted out
+      const thinkingPref = (await readPreference("thinking")) as { saveToHistory?: boolean } | null;
+      const savedMessages = thinkingPref?.saveToHistory === false
+        ? messages.map((message) => {
+            if (!message || typeof message !== "object") {
+              return message;
+            }
+            const copy = { ...(message as Record<string, unknown>) };
+            delete copy.thinking;
+            return copy;
+          })
+        : messages;
+
       const sessionData = {
         sessionId,
         projectPath,
         lastModified: new Date().toISOString(),
-        messages,
+        messages: savedMessages,
         name: sessionName || "",
         isCustomName: isCustomName || false,
         providerId: providerId || null,
         modelId: modelId || null,
+        settings: settings || {},
       };
 
       await writeFile(
@@ -586,6 +1148,7 @@ ipcMain.handle(
         isCustomName: sessionData.isCustomName || false,
         providerId: sessionData.providerId || null,
         modelId: sessionData.modelId || null,
+        settings: sessionData.settings || {},
         error: null,
       };
     } catch (error) {
@@ -599,6 +1162,15 @@ ipcMain.handle(
   },
 );
 
+// Session environment for tool processes, kept per project window
+const toolEnvByProject = new Map<string, Record<string, string>>();
+
+ipcMain.handle("tool-env-set", async (_, projectPath: string, env: Record<string, string>) => {
+  console.log("Received tool-env-set:", projectPath, Object.keys(env));
+  toolEnvByProject.set(projectPath, env);
+  return { success: true, error: null };
+});
+
 ipcMain.handle("session-list", async (_, projectPath: string) => {
   console.log("Received session-list for project:", projectPath);
 
@@ -882,6 +1454,21 @@ ipcMain.handle("recent-projects-clear", async () => {
   }
 });
 
+// Read a single preference in the main process (null if unset or unreadable)
+async function readPreference(key: string): Promise<unknown> {
+  try {
+    const prefsFile = path.join(homedir(), ".config", CONFIG_DIR_NAME, "preferences.json");
+    if (!existsSync(prefsFile)) {
+      return null;
+    }
+    const prefs = JSON.parse(await readFile(prefsFile, "utf-8"));
+    return prefs[key] ?? null;
+  } catch (error) {
+    console.error("Failed to read preference:", error);
+    return null;
+  }
+}
+
 // User preferences IPC handlers
 ipcMain.handle("preferences-get", async (_, key: string) => {
   console.log("Received preferences-get:", key);
@@ -941,6 +1528,62 @@ ipcMain.handle("preferences-set", async (_, key: string, value: unknown) => {
   }
 });
 
+// Sent messages, recalled with the up arrow and Ctrl+R in the input box. One
+// JSON string per line in ~/.config/poe/history, so multi-line prompts survive.
+const INPUT_HISTORY_LIMIT = 1000;
+
+async function readInputHistory(): Promise<string[]> {
+  const historyFile = path.join(homedir(), ".config", CONFIG_DIR_NAME, "history");
+  if (!existsSync(historyFile)) {
+    return [];
+  }
+  const content = await readFile(historyFile, "utf-8");
+  const entries: string[] = [];
+  for (const line of content.split("\n")) {
+    if (!line.trim()) {
+      continue;
+    }
+    try {
+      const entry = JSON.parse(line);
+      if (typeof entry === "string") {
+        entries.push(entry);
+      }
+    } catch {
+      // Skip lines from a partial write
+    }
+  }
+  return entries;
+}
+
+ipcMain.handle("input-history-read", async () => {
+  console.log("Received input-history-read");
+  try {
+    return { success: true, entries: await readInputHistory(), error: null };
+  } catch (error) {
+    console.error("Failed to read input history:", error);
+    return { success: false, entries: [], error: error instanceof Error ? error.message : "Unknown error" };
+  }
+});
+
+ipcMain.handle("input-history-add", async (_, entry: string) => {
+  console.log("Received input-history-add");
+  try {
+    const configDir = path.join(homedir(), ".config", CONFIG_DIR_NAME);
+    if (!existsSync(configDir)) {
+      mkdirSync(configDir, { recursive: true });
+    }
+    // Re-sending an earlier prompt moves it to the end instead of repeating it
+    const entries = (await readInputHistory()).filter(e => e !== entry);
+    entries.push(entry);
+    const kept = entries.slice(-INPUT_HISTORY_LIMIT);
+    await writeFile(path.join(configDir, "history"), kept.map(e => JSON.stringify(e)).join("\n") + "\n", "utf-8");
+    return { success: true, error: null };
+  } catch (error) {
+    console.error("Failed to save input history:", error);
+    return { success: false, error: error instanceof Error ? error.message : "Unknown error" };
+  }
+});
+
 // Helper function to create a safe filename from project path
 function getProjectConfigPath(projectPath: string, filename: string): string {
   // Create a hash-based identifier from the project path to avoid collisions
@@ -950,19 +1593,107 @@ function getProjectConfigPath(projectPath: string, filename: string): string {
   return path.join(projectConfigDir, filename);
 }
 
-// Project MCP override IPC handlers
-ipcMain.handle("project-mcp-overrides-read", async (_, projectPath: string) => {
+// Workspace trust: whether tools that write files or run processes may be used
+// in a project directory. Decisions live in the user's config rather than the
+// project, so nothing checked into a repository can mark itself trusted. A
+// decision for a directory covers the directories below it, unless one of them
+// has its own.
+type WorkspaceTrust = "trusted" | "restricted";
+
+function getWorkspaceTrustPath(): string {
+  return path.join(homedir(), ".config", CONFIG_DIR_NAME, "trusted-workspaces.json");
+}
+
+function readWorkspaceTrustDecisions(): Record<string, WorkspaceTrust> {
   try {
-    const overridesFile = getProjectConfigPath(projectPath, "mcp-overrides.json");
+    const data = JSON.parse(readFileSync(getWorkspaceTrustPath(), "utf-8"));
+    return data && typeof data.workspaces === "object" ? data.workspaces : {};
+  } catch {
+    return {};
+  }
+}
 
-    if (!existsSync(overridesFile)) {
-      return { success: true, content: null, error: null };
+// The decision that applies to projectPath and the directory it was made for
+function getWorkspaceTrust(projectPath: string): { trust: WorkspaceTrust | null; decidedFor: string | null } {
+  const decisions = readWorkspaceTrustDecisions();
+  let directory = path.resolve(projectPath);
+  while (true) {
+    const trust = decisions[directory];
+    if (trust === "trusted" || trust === "restricted") {
+      return { trust, decidedFor: directory };
+    }
+    const parent = path.dirname(directory);
+    if (parent === directory) {
+      return { trust: null, decidedFor: null };
     }
+    directory = parent;
+  }
+}
 
-    const content = await readFile(overridesFile, "utf-8");
-    return { success: true, content, error: null };
-  } catch (error) {
-    console.error("Failed to read project MCP overrides:", error);
+function isWorkspaceTrusted(projectPath: string): boolean {
+  return getWorkspaceTrust(projectPath).trust === "trusted";
+}
+
+function untrustedWorkspaceResult(toolName: string) {
+  return {
+    success: false,
+    error: `The ${toolName} tool is disabled because this workspace isn't trusted. The user can trust it with /trust yes.`,
+  };
+}
+
+ipcMain.handle("workspace-trust-get", async (_, projectPath: string) => {
+  try {
+    return { success: true, ...getWorkspaceTrust(projectPath), error: null };
+  } catch (error) {
+    console.error("Failed to read workspace trust:", error);
+    return {
+      success: false,
+      trust: null,
+      decidedFor: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// trust null forgets the decision made for projectPath itself
+ipcMain.handle("workspace-trust-set", async (_, projectPath: string, trust: WorkspaceTrust | null) => {
+  console.log("Received workspace-trust-set:", projectPath, trust);
+  try {
+    const decisions = readWorkspaceTrustDecisions();
+    const directory = path.resolve(projectPath);
+    if (trust === "trusted" || trust === "restricted") {
+      decisions[directory] = trust;
+    } else {
+      delete decisions[directory];
+    }
+    const file = getWorkspaceTrustPath();
+    await mkdir(path.dirname(file), { recursive: true });
+    await writeFile(file, JSON.stringify({ workspaces: decisions }, null, 2), "utf-8");
+    return { success: true, ...getWorkspaceTrust(projectPath), error: null };
+  } catch (error) {
+    console.error("Failed to write workspace trust:", error);
+    return {
+      success: false,
+      trust: null,
+      decidedFor: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// Project MCP override IPC handlers
+ipcMain.handle("project-mcp-overrides-read", async (_, projectPath: string) => {
+  try {
+    const overridesFile = getProjectConfigPath(projectPath, "mcp-overrides.json");
+
+    if (!existsSync(overridesFile)) {
+      return { success: true, content: null, error: null };
+    }
+
+    const content = await readFile(overridesFile, "utf-8");
+    return { success: true, content, error: null };
+  } catch (error) {
+    console.error("Failed to read project MCP overrides:", error);
     return {
       success: false,
       content: null,
@@ -993,6 +1724,8 @@ ipcMain.handle("project-mcp-overrides-write", async (_, projectPath: string, con
 });
 
 // Project context mode IPC handlers
+const CONTEXT_MODES = ["rolling", "halt", "summarize"];
+
 ipcMain.handle("project-context-mode-read", async (_, projectPath: string) => {
   try {
     const contextModeFile = getProjectConfigPath(projectPath, "context-mode.json");
@@ -1004,7 +1737,7 @@ ipcMain.handle("project-context-mode-read", async (_, projectPath: string) => {
 
     const content = await readFile(contextModeFile, "utf-8");
     const config = JSON.parse(content);
-    const mode = config.mode === "halt" ? "halt" : "rolling";
+    const mode = CONTEXT_MODES.includes(config.mode) ? config.mode : "rolling";
     return { success: true, mode, error: null };
   } catch (error) {
     console.error("Failed to read project context mode:", error);
@@ -1027,7 +1760,7 @@ ipcMain.handle("project-context-mode-write", async (_, projectPath: string, mode
     }
 
     // Validate mode
-    const validMode = mode === "halt" ? "halt" : "rolling";
+    const validMode = CONTEXT_MODES.includes(mode) ? mode : "rolling";
     const config = { mode: validMode };
 
     await writeFile(contextModeFile, JSON.stringify(config, null, 2), "utf-8");
@@ -1051,69 +1784,43 @@ ipcMain.handle(
       model: string;
       messages: unknown[];
       tools?: unknown[];
+      projectPath?: string; // Retrieval from the project's index, when it has one
+      options?: GenerationOptions; // Set with /set
     },
   ) => {
-    console.log("Received chat-send-message:", params.provider, params.model);
+    chatLog.debug("Received chat-send-message", {
+      provider: params.provider,
+      model: params.model,
+      messages: params.messages.length,
+      tools: params.tools?.length ?? 0,
+    });
+
+    const stampEvent = createChatEventStamper(`req-${Date.now()}`);
+    const sendEvent = (payload: ChatEventPayload) => {
+      const stamped = stampEvent(payload);
+      event.sender.send("chat-chunk", stamped);
+      publishToObserver(event.sender, stamped);
+    };
 
     try {
-      const { provider: providerId, model, messages, tools } = params;
+      const { provider: providerId, model, messages, tools, projectPath, options } = params;
 
       // Create new AbortController for this request
       currentStreamAbortController = new AbortController();
 
-      // Ensure providers are loaded
-      await loadProviders();
-
-      // Get provider from registry
-      const provider = providerRegistry.getProvider(providerId);
-      if (!provider) {
-        throw new Error(`Provider ${providerId} not found or not enabled`);
-      }
-
-      // Check if model supports tools
-      const capabilities = provider.getCapabilities();
-      const toolsToSend = capabilities.supportsTools ? tools : undefined;
-
-      if (!capabilities.supportsTools && tools && tools.length > 0) {
-        console.log(
-          `Provider ${providerId} does not support tools, tools will not be sent`,
-        );
-      }
-
-      // Convert messages to provider format
-      const providerMessages: ProviderChatMessage[] = (messages as any[]).map(m => ({
-        role: m.role,
-        content: m.content || '',
-        tool_calls: m.tool_calls,
-        tool_call_id: m.tool_call_id,
-        timestamp: m.timestamp || Date.now(),
-        thinking: m.thinking,
-      }));
-
-      // Create tool execution callback
-      const onToolCall = async (toolCall: ToolCall): Promise<ToolResult> => {
-        // Send individual tool call to frontend for immediate display and execution
-        event.sender.send("chat-chunk", {
-          type: "tool_call",
-          tool_call: toolCall,
-        });
-
-        // Tool execution happens in frontend, we just acknowledge here
-        return { success: true, content: 'Tool execution handled by frontend' };
-      };
-
-      // Stream the chat
-      const streamGenerator = provider.streamChat({
+      const stream = await engine.chat(messages as EngineMessage[], {
+        provider: providerId,
         model,
-        messages: providerMessages,
-        tools: toolsToSend as any,
+        tools: safeMode ? undefined : tools as ToolDefinition[] | undefined,
+        projectPath,
+        options,
         signal: currentStreamAbortController.signal,
-        onToolCall,
+        onStatus: ({ status, message }) => sendEvent({ type: "status", status, message }),
       });
 
-      // Process stream and send chunks to frontend
-      for await (const chunk of streamGenerator) {
-        event.sender.send("chat-chunk", chunk);
+      // Tool calls reach the frontend through the stream itself; execution happens there
+      for await (const chunk of stream) {
+        sendEvent(toChatEventPayload(chunk));
       }
 
       return {
@@ -1124,10 +1831,16 @@ ipcMain.handle(
         },
       };
     } catch (error) {
-      console.error("Failed to send chat message:", error);
+      // Cancelled before the provider started streaming
+      if (error instanceof Error && error.name === "AbortError") {
+        sendEvent({ type: "cancelled" });
+        return { success: true };
+      }
 
-      // Send error chunk to frontend
-      event.sender.send("chat-chunk", {
+      chatLog.error("Failed to send chat message", { provider: params.provider, model: params.model, error });
+
+      // Send error event to frontend
+      sendEvent({
         type: "error",
         error: error instanceof Error ? error.message : "Unknown error",
       });
@@ -1142,6 +1855,125 @@ ipcMain.handle(
   },
 );
 
+// The request chat-send-message would make for these messages, without sending it (/preview)
+ipcMain.handle(
+  "chat-preview",
+  async (
+    _,
+    params: {
+      provider: string;
+      model: string;
+      messages: unknown[];
+      tools?: unknown[];
+      projectPath?: string;
+      options?: GenerationOptions;
+    },
+  ) => {
+    chatLog.debug("Received chat-preview", {
+      provider: params.provider,
+      model: params.model,
+      messages: params.messages.length,
+      tools: params.tools?.length ?? 0,
+    });
+    try {
+      const preview = await engine.preview(params.messages as EngineMessage[], {
+        provider: params.provider,
+        model: params.model,
+        tools: safeMode ? undefined : params.tools as ToolDefinition[] | undefined,
+        projectPath: params.projectPath,
+        options: params.options,
+      });
+      return { success: true, preview, error: null };
+    } catch (error) {
+      chatLog.error("Failed to preview chat request", { provider: params.provider, model: params.model, error });
+      return {
+        success: false,
+        preview: null,
+        error: error instanceof Error ? error.message : "Unknown error",
+      };
+    }
+  },
+);
+
+// Map provider stream chunks onto the versioned chat event schema
+function toChatEventPayload(chunk: ChatChunk): ChatEventPayload {
+  if (chunk.type === "tool_call") {
+    return { type: "tool_call", tool_call: chunk.toolCall };
+  }
+  if (chunk.type === "done") {
+    return { type: "done", done_reason: chunk.reason };
+  }
+  return chunk;
+}
+
+// One-off completion for internal requests such as context summaries. It runs
+// beside the chat stream rather than through it, so chat-cancel doesn't stop it.
+ipcMain.handle(
+  "chat-complete",
+  async (
+    _,
+    params: {
+      provider: string;
+      model: string;
+      messages: Array<{ role: ProviderChatMessage["role"]; content: string }>;
+    },
+  ) => {
+    console.log("Received chat-complete:", params.provider, params.model);
+
+    try {
+      await loadProviders();
+
+      const provider = providerRegistry.getProvider(params.provider);
+      if (!provider) {
+        throw new Error(`Provider ${params.provider} not found or not enabled`);
+      }
+      if ((await readPreference("offlineMode")) === true && !isLocalProvider(provider.getConfig())) {
+        throw new Error(`Offline mode is on: ${params.provider} is not a local Ollama provider`);
+      }
+
+      const providerMessages: ProviderChatMessage[] = params.messages.map(m => ({
+        role: m.role,
+        content: m.content,
+        timestamp: Date.now(),
+      }));
+
+      const estimatedTokens = estimateTokens(providerMessages);
+      await loadRateLimits();
+      await rateLimiter.acquireProvider(params.provider, estimatedTokens);
+
+      let content = "";
+      const stream = applyThinkingFormat(provider.streamChat({
+        model: params.model,
+        messages: providerMessages,
+        retry: {
+          ...(await loadRequestRetry()),
+          onRetry: ({ attempt, maxAttempts, delayMs, reason }) => {
+            console.log(`Request to ${params.provider} failed (${reason}), retrying in ${delayMs}ms (${attempt + 1}/${maxAttempts})`);
+          },
+        },
+      }), provider.getThinkingFormat(params.model), provider.getOutputParserOptions(params.model));
+      for await (const chunk of stream) {
+        if (chunk.type === "content") {
+          content += chunk.content;
+        } else if (chunk.type === "usage" && chunk.usage) {
+          rateLimiter.recordProviderTokens(params.provider, estimatedTokens, chunk.usage.total_tokens);
+        } else if (chunk.type === "error") {
+          throw new Error(chunk.error);
+        }
+      }
+
+      return { success: true, content: content.trim(), error: null };
+    } catch (error) {
+      console.error("Failed to complete chat:", error);
+      return {
+        success: false,
+        content: "",
+        error: error instanceof Error ? error.message : "Unknown error",
+      };
+    }
+  },
+);
+
 ipcMain.handle("chat-cancel", async () => {
   console.log("Received chat-cancel");
   if (currentStreamAbortController) {
@@ -1152,6 +1984,34 @@ ipcMain.handle("chat-cancel", async () => {
   return { success: false, error: "No active stream to cancel" };
 });
 
+// Load a model ahead of the first message (warmUpModel preference). Providers
+// that don't load models on demand have nothing to do.
+ipcMain.handle("chat-warm-up", async (_, params: {
+  provider: string;
+  model: string;
+}) => {
+  console.log("Received chat-warm-up:", params.provider, params.model);
+  try {
+    await loadProviders();
+    const provider = providerRegistry.getProvider(params.provider);
+    if (!provider) {
+      throw new Error(`Provider ${params.provider} not found or not enabled`);
+    }
+    if (!provider.warmUp) {
+      return { success: true, warmed: false };
+    }
+    await provider.warmUp(params.model);
+    return { success: true, warmed: true };
+  } catch (error) {
+    console.error("Failed to warm up model:", error);
+    return {
+      success: false,
+      warmed: false,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
 // Get context length for a model
 ipcMain.handle("chat-get-context-length", async (_, params: {
   provider: string;
@@ -1180,510 +2040,286 @@ ipcMain.handle("chat-get-context-length", async (_, params: {
   }
 });
 
-// Load providers into registry from config
-async function loadProviders() {
+// What a model can take, as the engine sees it (see providers/capabilities.ts)
+ipcMain.handle("chat-model-capabilities", async (_, params: {
+  provider: string;
+  model: string;
+  refresh?: boolean;
+}) => {
+  console.log("Received chat-model-capabilities:", params.provider, params.model);
   try {
-    const configDir = path.join(homedir(), ".config", CONFIG_DIR_NAME);
-    const yamlPath = path.join(configDir, "providers.yaml");
-    const jsonPath = path.join(configDir, "providers.json");
-    
-    let configContent: string | null = null;
-    let configPath: string | null = null;
-
-    // Prefer YAML, fall back to JSON
-    if (existsSync(yamlPath)) {
-      configPath = yamlPath;
-      configContent = await readFile(yamlPath, "utf-8");
-    } else if (existsSync(jsonPath)) {
-      // Try to migrate JSON to YAML
-      await migrateJsonToYaml(jsonPath, yamlPath);
-      if (existsSync(yamlPath)) {
-        configPath = yamlPath;
-        configContent = await readFile(yamlPath, "utf-8");
-      } else {
-        // Fall back to JSON
-        configPath = jsonPath;
-        configContent = await readFile(jsonPath, "utf-8");
-      }
-    }
+    await loadProviders();
 
-    if (!configContent || !configPath) {
-      return;
+    const provider = providerRegistry.getProvider(params.provider);
+    if (!provider) {
+      throw new Error(`Provider ${params.provider} not found or not enabled`);
     }
 
-    const providersData = parseConfig(configContent, configPath) as { providers?: unknown[] };
-
-    if (providersData.providers && Array.isArray(providersData.providers)) {
-      providerRegistry.updateProviders(providersData.providers as any);
-    }
+    const capabilities = await getModelCapabilities(provider, params.model, params.refresh);
+    return { success: true, capabilities, error: null };
   } catch (error) {
-    console.error("Failed to load providers:", error);
+    console.error("Failed to get model capabilities:", error);
+    return {
+      success: false,
+      capabilities: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
   }
-}
+});
 
-// Legacy API functions (kept for backward compatibility during transition)
-async function callOllamaAPI(
-  providerConfig: { baseURL: string },
-  model: string,
-  messages: unknown[],
-  tools: unknown[] | undefined,
-  event: Electron.IpcMainInvokeEvent,
-) {
-  const url = `${providerConfig.baseURL}/api/chat`;
-
-  // Build a map of tool_call_id to tool_name for tool result messages
-  const toolCallMap = new Map<string, string>();
-  messages.forEach((m: Record<string, unknown>) => {
-    if (m.tool_calls && Array.isArray(m.tool_calls)) {
-      m.tool_calls.forEach(
-        (tc: { id?: string; function?: { name?: string } }) => {
-          if (tc.id && tc.function?.name) {
-            toolCallMap.set(tc.id, tc.function.name);
-          }
-        },
-      );
+// List the models a provider's server offers
+ipcMain.handle("chat-list-models", async (_, params: { provider: string }) => {
+  console.log("Received chat-list-models:", params.provider);
+  try {
+    await loadProviders();
+
+    const provider = providerRegistry.getProvider(params.provider);
+    if (!provider) {
+      throw new Error(`Provider ${params.provider} not found or not enabled`);
     }
-  });
 
-  // Clean messages - only include fields that Ollama expects
-  const cleanedMessages = messages.map((m: Record<string, unknown>) => {
-    const cleaned: Record<string, unknown> = {
-      role: m.role,
+    const models = await provider.getModels();
+    return { success: true, models, error: null };
+  } catch (error) {
+    console.error("Failed to list models:", error);
+    return {
+      success: false,
+      models: [],
+      error: error instanceof Error ? error.message : "Unknown error",
     };
+  }
+});
 
-    // Only add content if it's not empty or if there are no tool calls
-    // When there are tool calls, content can be omitted
-    if (
-      m.content &&
-      (typeof m.content !== "string" || m.content.trim() !== "")
-    ) {
-      cleaned.content = m.content;
-    } else if (!m.tool_calls) {
-      // Always include content for non-tool-call messages, even if empty
-      cleaned.content = m.content || "";
-    }
-
-    // Handle tool calls - strip id and type fields for Ollama, convert arguments to object
-    if (m.tool_calls && Array.isArray(m.tool_calls)) {
-      cleaned.tool_calls = m.tool_calls.map(
-        (tc: {
-          function: {
-            name: string;
-            arguments: string | Record<string, unknown>;
-          };
-        }) => ({
-          function: {
-            name: tc.function.name,
-            arguments:
-              typeof tc.function.arguments === "string"
-                ? JSON.parse(tc.function.arguments)
-                : tc.function.arguments,
-          },
-        }),
-      );
-    }
+// Read the providers config with environment references resolved; null when
+// there is no config file or it has no providers list
+async function readProvidersConfig(): Promise<unknown[] | null> {
+  const configDir = path.join(homedir(), ".config", CONFIG_DIR_NAME);
+  const yamlPath = path.join(configDir, "providers.yaml");
+  const jsonPath = path.join(configDir, "providers.json");
+  
+  let configContent: string | null = null;
+  let configPath: string | null = null;
 
-    // For tool result messages, use tool_name instead of tool_call_id
-    if (m.role === "tool" && m.tool_call_id) {
-      const toolName = toolCallMap.get(m.tool_call_id as string);
-      if (toolName) {
-        cleaned.tool_name = toolName;
-      }
+  // Prefer YAML, fall back to JSON
+  if (existsSync(yamlPath)) {
+    configPath = yamlPath;
+    configContent = await readFile(yamlPath, "utf-8");
+  } else if (existsSync(jsonPath)) {
+    // Try to migrate JSON to YAML
+    await migrateJsonToYaml(jsonPath, yamlPath);
+    if (existsSync(yamlPath)) {
+      configPath = yamlPath;
+      configContent = await readFile(yamlPath, "utf-8");
+    } else {
+      // Fall back to JSON
+      configPath = jsonPath;
+      configContent = await readFile(jsonPath, "utf-8");
     }
+  }
 
-    return cleaned;
-  });
-
-  const requestBody: Record<string, unknown> = {
-    model,
-    messages: cleanedMessages,
-    stream: true,
-  };
-
-  if (tools && tools.length > 0) {
-    requestBody.tools = tools;
+  if (!configContent || !configPath) {
+    return null;
   }
 
-  console.log(
-    "Sending to Ollama:",
-    JSON.stringify(
-      {
-        model,
-        messages: cleanedMessages,
-        tools: tools?.length || 0,
-      },
-      null,
-      2,
-    ),
+  // Values may reference environment variables (${OPENAI_API_KEY}); the registry
+  // and the renderer see the resolved values, the file on disk keeps the references
+  const missing = new Set<string>();
+  const providersData = interpolateEnv(
+    parseConfig(configContent, configPath) as { providers?: unknown[] },
+    process.env,
+    missing,
   );
+  if (missing.size > 0) {
+    console.warn(`providers config references unset environment variables: ${Array.from(missing).join(", ")}`);
+  }
 
-  const response = await fetch(url, {
-    method: "POST",
-    headers: { "Content-Type": "application/json" },
-    body: JSON.stringify(requestBody),
-    signal: currentStreamAbortController?.signal,
-  });
+  return Array.isArray(providersData.providers) ? providersData.providers : null;
+}
 
-  if (!response.ok) {
-    throw new Error(`Ollama API error: ${response.statusText}`);
+// Load providers into registry from config
+async function loadProviders() {
+  try {
+    const providers = await readProvidersConfig();
+    if (providers) {
+      providerRegistry.updateProviders(providers as any);
+    }
+  } catch (error) {
+    console.error("Failed to load providers:", error);
   }
+}
 
-  // Stream the response
-  const reader = response.body?.getReader();
-  if (!reader) {
-    throw new Error("No response body");
+// Load rate limits from config; a missing file means no limits
+async function loadRateLimits() {
+  try {
+    const yamlPath = path.join(homedir(), ".config", CONFIG_DIR_NAME, "rate-limits.yaml");
+    if (!existsSync(yamlPath)) {
+      rateLimiter.configure({});
+      return;
+    }
+    const content = await readFile(yamlPath, "utf-8");
+    rateLimiter.configure((parseConfig(content, yamlPath) as RateLimitConfig) || {});
+  } catch (error) {
+    console.error("Failed to load rate limits:", error);
   }
+}
 
-  const decoder = new TextDecoder();
-  let fullMessage = "";
-  let toolCalls: unknown[] = [];
+const transcriptArchive = createTranscriptArchive(path.join(homedir(), ".config", CONFIG_DIR_NAME, "transcripts"));
+let transcriptSinksMtime: number | null = null;
 
+// Load transcript sinks when the config file changes; sinks keep their buffers otherwise
+async function loadTranscriptSinks() {
   try {
-    while (true) {
-      const { done, value } = await reader.read();
-      if (done) break;
-
-      const chunk = decoder.decode(value);
-      const lines = chunk.split("\n").filter((line) => line.trim());
-
-      for (const line of lines) {
-        try {
-          const data = JSON.parse(line);
-
-          if (data.message?.content) {
-            fullMessage += data.message.content;
-            event.sender.send("chat-chunk", {
-              type: "content",
-              content: data.message.content,
-            });
-          }
-
-          if (data.message?.tool_calls) {
-            // Normalize Ollama tool calls to match expected format
-            // Ollama format: { function: { name: "...", arguments: {...} } }
-            // Expected format: { id: "...", type: "function", function: { name: "...", arguments: "..." } }
-            toolCalls = data.message.tool_calls.map(
-              (
-                tc: {
-                  id?: string;
-                  function: {
-                    name: string;
-                    arguments: string | Record<string, unknown>;
-                  };
-                },
-                index: number,
-              ) => ({
-                id: tc.id || `call_${Date.now()}_${index}`,
-                type: "function",
-                function: {
-                  name: tc.function.name,
-                  arguments:
-                    typeof tc.function.arguments === "string"
-                      ? tc.function.arguments
-                      : JSON.stringify(tc.function.arguments),
-                },
-              }),
-            );
-            event.sender.send("chat-chunk", {
-              type: "tool_calls",
-              tool_calls: toolCalls,
-            });
-          }
-
-          if (data.done) {
-            event.sender.send("chat-chunk", { type: "done", done: true });
-          }
-        } catch (parseError) {
-          console.error("Failed to parse chunk:", parseError);
-        }
-      }
+    const yamlPath = path.join(homedir(), ".config", CONFIG_DIR_NAME, "transcript-sinks.yaml");
+    const mtime = existsSync(yamlPath) ? statSync(yamlPath).mtimeMs : 0;
+    if (mtime === transcriptSinksMtime) {
+      return;
     }
-  } catch (error: unknown) {
-    if (error instanceof Error && error.name === "AbortError") {
-      console.log("Stream aborted by user");
-      event.sender.send("chat-chunk", { type: "cancelled" });
-      throw error;
+    transcriptSinksMtime = mtime;
+    if (!mtime) {
+      await transcriptArchive.configure([]);
+      return;
     }
-    throw error;
-  } finally {
-    reader.releaseLock();
+    const content = await readFile(yamlPath, "utf-8");
+    const config = interpolateEnv((parseConfig(content, yamlPath) as { sinks?: TranscriptSinkConfig[] }) || {});
+    await transcriptArchive.configure(Array.isArray(config.sinks) ? config.sinks : []);
+  } catch (error) {
+    console.error("Failed to load transcript sinks:", error);
   }
+}
 
+ipcMain.handle("transcript-record", async (_, record: Omit<TranscriptRecord, "recordedAt">) => {
+  await loadTranscriptSinks();
+  if (!transcriptArchive.isEnabled()) {
+    return { success: true, error: null };
+  }
+  try {
+    await transcriptArchive.record({ ...record, recordedAt: new Date().toISOString() });
+    return { success: true, error: null };
+  } catch (error) {
+    console.error("Failed to record transcript event:", error);
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// Backoff for connection errors and 5xx responses, from the "requestRetry" preference
+async function loadRequestRetry(): Promise<RequestRetryOptions> {
+  const value = (await readPreference("requestRetry")) as Partial<RequestRetryOptions> | null;
   return {
-    success: true,
-    message: {
-      role: "assistant",
-      content: fullMessage,
-      tool_calls: toolCalls.length > 0 ? toolCalls : undefined,
-    },
+    maxAttempts: typeof value?.maxAttempts === "number" ? Math.max(1, value.maxAttempts) : DEFAULT_REQUEST_RETRY.maxAttempts,
+    baseDelayMs: typeof value?.baseDelayMs === "number" ? Math.max(0, value.baseDelayMs) : DEFAULT_REQUEST_RETRY.baseDelayMs,
+    maxDelayMs: typeof value?.maxDelayMs === "number" ? Math.max(0, value.maxDelayMs) : DEFAULT_REQUEST_RETRY.maxDelayMs,
   };
 }
 
-async function callLMStudioAPI(
-  providerConfig: { baseURL: string; apiKey?: string },
-  model: string,
-  messages: unknown[],
-  tools: unknown[] | undefined,
-  event: Electron.IpcMainInvokeEvent,
-) {
-  const url = `${providerConfig.baseURL}/v1/chat/completions`;
-
-  // Clean up messages to avoid LM Studio issues
-  // Remove assistant messages with duplicate tool_call_ids (retries)
-  const seenToolCallIds = new Set<string>();
-  const cleanedMessages = messages.filter((m: Record<string, unknown>) => {
-    // If it's an assistant message with tool_calls, check for duplicates
-    if (m.role === "assistant" && m.tool_calls && Array.isArray(m.tool_calls)) {
-      const toolCallIds = m.tool_calls.map((tc: any) => tc.id);
-
-      // Check if any of these tool call IDs have been seen before
-      const hasDuplicate = toolCallIds.some((id) => seenToolCallIds.has(id));
-
-      if (hasDuplicate) {
-        // Skip this message - it's a retry with the same tool_call_id
-        return false;
-      }
-
-      // Add these IDs to the seen set
-      toolCallIds.forEach((id) => seenToolCallIds.add(id));
-    }
-
-    return true;
+// Out-of-request status events (tool throttling) share the chat-chunk channel
+const stampToolStatusEvent = createChatEventStamper("tools");
+
+// Wait for a tool's rate limit, telling the renderer while it is throttled.
+// `signal` is the tool call's, so cancelling it also stops the wait.
+async function acquireToolSlot(sender: Electron.WebContents, toolName: string, signal?: AbortSignal) {
+  await loadRateLimits();
+  await rateLimiter.acquireTool(toolName, signal, (waitMs) => {
+    toolLog.event("rate-limit", "Tool rate limited, waiting", { tool: toolName, waitMs });
+    const stamped = stampToolStatusEvent({
+      type: "status",
+      status: "throttled",
+      message: `Tool ${toolName} is rate limited, waiting ${Math.ceil(waitMs / 1000)}s…`,
+    });
+    sender.send("chat-chunk", stamped);
+    publishToObserver(sender, stamped);
   });
+}
 
-  const requestBody: Record<string, unknown> = {
-    model,
-    messages: cleanedMessages.map((m: Record<string, unknown>) => {
-      const msg: Record<string, unknown> = {
-        role: m.role,
-      };
-
-      // Content is required for most roles, but might be null for assistant with tool_calls
-      // LM Studio requires content to be a string or null, not undefined
-      if (m.content !== undefined && m.content !== null && m.content !== "") {
-        msg.content = m.content;
-      } else if (m.role === "assistant" && m.tool_calls) {
-        // Assistant messages with tool_calls can have null content
-        msg.content = null;
-      } else if (m.role === "tool") {
-        // Tool messages must have content
-        msg.content = m.content || "";
-      } else {
-        // Default to empty string for other roles
-        msg.content = m.content || "";
-      }
-
-      // Only include tool_calls if it exists
-      if (m.tool_calls) {
-        msg.tool_calls = m.tool_calls;
-      }
-      // Only include tool_call_id if it exists (for tool role)
-      if (m.tool_call_id) {
-        msg.tool_call_id = m.tool_call_id;
-      }
-      // Only include name if it exists (for tool role)
-      if (m.name) {
-        msg.name = m.name;
-      }
-
-      return msg;
-    }),
-    stream: true,
-    stream_options: {
-      include_usage: true,
-    },
-  };
+// Tool calls that can run for a long time (bash, MCP), by toolCallId, so the
+// renderer can stop them when the user cancels or the tool's timeout passes
+const runningToolCalls = new Map<string, AbortController>();
 
-  if (tools && tools.length > 0) {
-    requestBody.tools = tools;
-    // Only add tool_choice on initial requests, not continuations
-    // Continuations already have tool context in the message history
-    const hasToolResults = cleanedMessages.some(
-      (m: Record<string, unknown>) => m.role === "tool",
-    );
-    if (!hasToolResults) {
-      requestBody.tool_choice = "auto";
-    }
+// Track a tool call until `run` settles; untracked calls can't be cancelled
+async function withToolCancellation<T>(toolCallId: string | undefined, run: (signal?: AbortSignal) => Promise<T>): Promise<T> {
+  if (!toolCallId) {
+    return await run();
   }
-
-  const headers: Record<string, string> = {
-    "Content-Type": "application/json",
-  };
-  if (providerConfig.apiKey) {
-    headers.Authorization = `Bearer ${providerConfig.apiKey}`;
+  const controller = new AbortController();
+  runningToolCalls.set(toolCallId, controller);
+  try {
+    return await run(controller.signal);
+  } finally {
+    if (runningToolCalls.get(toolCallId) === controller) {
+      runningToolCalls.delete(toolCallId);
+    }
   }
+}
 
-  // Log the request for debugging
-  console.log(
-    "LM Studio request:",
-    JSON.stringify(
-      {
-        url,
-        model,
-        messagesCount: requestBody.messages?.length,
-        hasTools: !!requestBody.tools,
-        toolsCount: requestBody.tools?.length || 0,
-      },
-      null,
-      2,
-    ),
-  );
+// Questions to the user from tools running in the main process, by request id,
+// waiting for the renderer's answer (null when dismissed)
+const pendingToolInput = new Map<string, (answer: string | null) => void>();
+let toolInputRequestId = 0;
 
-  // Log full request body for debugging tool call issues
-  if (process.env.DEBUG_LMS) {
-    console.log(
-      "Full LM Studio request body:",
-      JSON.stringify(requestBody, null, 2),
-    );
+function askUserFromTool(sender: Electron.WebContents, toolCallId: string, question: string, choices?: string[]): Promise<string | null> {
+  if (sender.isDestroyed()) {
+    return Promise.resolve(null);
   }
-
-  const response = await fetch(url, {
-    method: "POST",
-    headers,
-    body: JSON.stringify(requestBody),
-    signal: currentStreamAbortController?.signal,
+  const requestId = `tool-input-${++toolInputRequestId}`;
+  return new Promise((resolve) => {
+    pendingToolInput.set(requestId, resolve);
+    sender.send("tool-input-request", { requestId, toolCallId, question, ...(choices && { choices }) });
   });
+}
 
-  if (!response.ok) {
-    let errorDetails = response.statusText;
-    try {
-      const errorBody = await response.text();
-      if (errorBody) {
-        errorDetails += ` - ${errorBody}`;
+// Answer an MCP elicitation by asking for each field of its schema in turn
+function elicitFromUser(sender: Electron.WebContents, toolCallId: string): MCPElicitHandler {
+  return async ({ message, requestedSchema }) => {
+    const fields = Object.entries(requestedSchema?.properties || {});
+    const content: Record<string, unknown> = {};
+    for (const [name, field] of fields) {
+      const label = field.title || name;
+      const question = fields.length === 1 ? message : `${message}\n\n${label}${field.description ? ` (${field.description})` : ""}`;
+      const choices = field.enum ?? (field.type === "boolean" ? ["yes", "no"] : undefined);
+      const answer = await askUserFromTool(sender, toolCallId, question, choices);
+      if (answer === null) {
+        return { action: "decline" };
+      }
+      if (field.type === "boolean") {
+        content[name] = answer === "yes";
+      } else if ((field.type === "number" || field.type === "integer") && answer.trim() !== "" && !Number.isNaN(Number(answer))) {
+        content[name] = Number(answer);
+      } else {
+        content[name] = answer;
       }
-    } catch (e) {
-      // Ignore error reading body
     }
-    console.error(`LM Studio API error (${response.status}):`, errorDetails);
-    console.error("Request body:", JSON.stringify(requestBody, null, 2));
-    throw new Error(`LM Studio API error: ${errorDetails}`);
-  }
+    return { action: "accept", content };
+  };
+}
 
-  // Stream the response (SSE format)
-  const reader = response.body?.getReader();
-  if (!reader) {
-    throw new Error("No response body");
+ipcMain.handle("tool-input-respond", async (_, requestId: string, answer: string | null) => {
+  console.log("Received tool-input-respond:", requestId);
+  const resolve = pendingToolInput.get(requestId);
+  if (!resolve) {
+    return { success: false, error: "Question is no longer open" };
   }
+  pendingToolInput.delete(requestId);
+  resolve(answer);
+  return { success: true };
+});
 
-  const decoder = new TextDecoder();
-  let fullMessage = "";
-  let toolCalls: Array<{
-    id?: string;
-    type?: string;
-    function?: {
-      name?: string;
-      arguments?: string;
-    };
-  }> = [];
-
-  try {
-    while (true) {
-      const { done, value } = await reader.read();
-      if (done) break;
-
-      const chunk = decoder.decode(value);
-      const lines = chunk
-        .split("\n")
-        .filter((line) => line.trim() && line.startsWith("data:"));
-
-      for (const line of lines) {
-        const data = line.replace(/^data: /, "");
-        if (data === "[DONE]") {
-          event.sender.send("chat-chunk", { type: "done", done: true });
-          continue;
-        }
-
-        try {
-          const parsed = JSON.parse(data);
-          const delta = parsed.choices?.[0]?.delta;
-
-          if (delta?.content) {
-            fullMessage += delta.content;
-            event.sender.send("chat-chunk", {
-              type: "content",
-              content: delta.content,
-            });
-          }
-
-          // Handle tool_calls streaming (OpenAI format)
-          // Tool calls come as deltas with indices that need to be accumulated
-          if (delta?.tool_calls) {
-            for (const toolCallDelta of delta.tool_calls) {
-              const index = toolCallDelta.index;
-
-              // Initialize the tool call at this index if it doesn't exist
-              if (!toolCalls[index]) {
-                toolCalls[index] = {
-                  id: toolCallDelta.id || `call_${index}`,
-                  type: toolCallDelta.type || "function",
-                  function: {
-                    name: "",
-                    arguments: "",
-                  },
-                };
-              }
-
-              // Accumulate the function name
-              if (toolCallDelta.function?.name) {
-                toolCalls[index].function!.name = toolCallDelta.function.name;
-              }
-
-              // Accumulate the arguments
-              if (toolCallDelta.function?.arguments) {
-                toolCalls[index].function!.arguments =
-                  (toolCalls[index].function!.arguments || "") +
-                  toolCallDelta.function.arguments;
-              }
-            }
-
-            // Send the accumulated tool calls
-            event.sender.send("chat-chunk", {
-              type: "tool_calls",
-              tool_calls: toolCalls.filter((tc) => tc !== undefined),
-            });
-          }
-
-          // Handle usage information (when stream_options.include_usage is true)
-          if (parsed.usage) {
-            event.sender.send("chat-chunk", {
-              type: "usage",
-              usage: {
-                prompt_tokens: parsed.usage.prompt_tokens,
-                completion_tokens: parsed.usage.completion_tokens,
-                total_tokens: parsed.usage.total_tokens,
-              },
-            });
-          }
-        } catch (parseError) {
-          console.error("Failed to parse SSE chunk:", parseError);
-        }
-      }
-    }
-  } catch (error: unknown) {
-    if (error instanceof Error && error.name === "AbortError") {
-      console.log("Stream aborted by user");
-      event.sender.send("chat-chunk", { type: "cancelled" });
-      throw error;
-    }
-    throw error;
-  } finally {
-    reader.releaseLock();
+ipcMain.handle("tool-cancel", async (_, toolCallId: string) => {
+  console.log("Received tool-cancel:", toolCallId);
+  const controller = runningToolCalls.get(toolCallId);
+  if (!controller) {
+    return { success: false, error: "Tool call is not running" };
   }
-
-  return {
-    success: true,
-    message: {
-      role: "assistant",
-      content: fullMessage,
-      tool_calls: toolCalls.length > 0 ? toolCalls : undefined,
-    },
-  };
-}
+  controller.abort();
+  runningToolCalls.delete(toolCallId);
+  return { success: true };
+});
 
 ipcMain.handle(
   "execute-tool",
   async (_, toolName: string, params: Record<string, unknown>) => {
-    console.log("Received execute-tool:", toolName, params);
+    console.log("Received execute-tool:", toolName, Object.keys(params || {}));
 
     // For now, tools execute in renderer
     // This handler is for future main-process tools
@@ -1769,6 +2405,17 @@ ipcMain.handle("prompts-write", async (_, name: string, content: string) => {
   }
 });
 
+// Open a prompt in the system's editor for .md files
+ipcMain.handle("prompts-open", async (_, name: string) => {
+  console.log("Received prompts-open:", name);
+  const promptPath = path.join(homedir(), ".config", CONFIG_DIR_NAME, "prompts", `${name}.md`);
+  if (!existsSync(promptPath)) {
+    return { success: false, error: "Prompt does not exist" };
+  }
+  const error = await shell.openPath(promptPath);
+  return error ? { success: false, error } : { success: true, error: null };
+});
+
 ipcMain.handle("prompts-delete", async (_, name: string) => {
   try {
     const promptPath = path.join(
@@ -1791,17 +2438,66 @@ ipcMain.handle("prompts-delete", async (_, name: string) => {
   }
 });
 
+// Message templates for /prompt, in their own directory so they don't show up
+// among the system prompts (see src/utils/promptTemplates.ts)
+const templatesDir = () => path.join(homedir(), ".config", CONFIG_DIR_NAME, "templates");
+
+ipcMain.handle("templates-list", async () => {
+  console.log("Received templates-list");
+  try {
+    const dir = templatesDir();
+    if (!existsSync(dir)) {
+      return { success: true, templates: [], directory: dir, error: null };
+    }
+
+    const templates = await Promise.all(
+      readdirSync(dir)
+        .filter((file) => file.endsWith(".md"))
+        .sort()
+        .map(async (file) => {
+          const { description } = parsePromptTemplate(await readFile(path.join(dir, file), "utf-8"));
+          return { name: file.slice(0, -".md".length), description };
+        }),
+    );
+    return { success: true, templates, directory: dir, error: null };
+  } catch (error) {
+    console.error("Failed to list templates:", error);
+    return {
+      success: false,
+      templates: [],
+      directory: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+ipcMain.handle("templates-read", async (_, name: string) => {
+  console.log("Received templates-read:", name);
+  try {
+    const templatePath = path.join(templatesDir(), `${name}.md`);
+    if (path.dirname(templatePath) !== templatesDir() || !existsSync(templatePath)) {
+      return { success: false, template: null, error: `No template named ${name}` };
+    }
+
+    const template = parsePromptTemplate(await readFile(templatePath, "utf-8"));
+    return { success: true, template, error: null };
+  } catch (error) {
+    console.error("Failed to read template:", error);
+    return {
+      success: false,
+      template: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
 // MCP IPC handlers
 ipcMain.handle(
   "mcp-start-server",
   async (
     _,
     name: string,
-    config: {
-      command: string;
-      args: string[];
-      env?: Record<string, string>;
-    },
+    config: MCPServerConfig,
   ) => {
     console.log("Received mcp-start-server:", name);
     try {
@@ -1836,11 +2532,7 @@ ipcMain.handle(
   async (
     _,
     name: string,
-    config: {
-      command: string;
-      args: string[];
-      env?: Record<string, string>;
-    },
+    config: MCPServerConfig,
   ) => {
     console.log("Received mcp-restart-server:", name);
     try {
@@ -1859,14 +2551,19 @@ ipcMain.handle(
 ipcMain.handle(
   "mcp-call-tool",
   async (
-    _,
+    event,
     serverName: string,
     toolName: string,
     args: Record<string, unknown>,
+    toolCallId?: string,
   ) => {
-    console.log("Received mcp-call-tool:", serverName, toolName, args);
+    // Argument values can hold secrets the renderer masks, so only their names are logged
+    console.log("Received mcp-call-tool:", serverName, toolName, Object.keys(args || {}));
     try {
-      const result = await mcpManager.callTool(serverName, toolName, args);
+      const result = await withToolCancellation(toolCallId, async (signal) => {
+        await acquireToolSlot(event.sender, `${serverName}__${toolName}`, signal);
+        return await mcpManager.callTool(serverName, toolName, args, signal, toolCallId ? elicitFromUser(event.sender, toolCallId) : undefined);
+      });
       return { success: true, result, error: null };
     } catch (error) {
       console.error("Failed to call MCP tool:", error);
@@ -1908,45 +2605,94 @@ ipcMain.handle(
 );
 
 // Internal tool IPC handlers
-ipcMain.handle("internal-tool-read", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-read", async (event, projectPath: string, params) => {
   console.log("Received internal-tool-read:", projectPath, params.file_path);
+  await acquireToolSlot(event.sender, "read");
   return await handleRead({ projectPath, ...params });
 });
 
+// Not a tool: /apply reads the file it will replace, within the same project bounds
+ipcMain.handle("apply-read-file", async (_, projectPath: string, filePath: string) => {
+  console.log("Received apply-read-file:", projectPath, filePath);
+  return await handleReadRaw({ projectPath, file_path: filePath });
+});
+
 ipcMain.handle(
   "internal-tool-write",
-  async (_, projectPath: string, params) => {
+  async (event, projectPath: string, params) => {
     console.log("Received internal-tool-write:", projectPath, params.file_path);
+    if (!isWorkspaceTrusted(projectPath)) {
+      return untrustedWorkspaceResult("write");
+    }
+    await acquireToolSlot(event.sender, "write");
     return await handleWrite({ projectPath, ...params });
   },
 );
 
-ipcMain.handle("internal-tool-edit", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-edit", async (event, projectPath: string, params) => {
   console.log("Received internal-tool-edit:", projectPath, params.file_path);
+  if (!isWorkspaceTrusted(projectPath)) {
+    return untrustedWorkspaceResult("edit");
+  }
+  await acquireToolSlot(event.sender, "edit");
   return await handleEdit({ projectPath, ...params });
 });
 
-ipcMain.handle("internal-tool-glob", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-glob", async (event, projectPath: string, params) => {
   console.log("Received internal-tool-glob:", projectPath, params.pattern);
+  await acquireToolSlot(event.sender, "find");
   return await handleGlob({ projectPath, ...params });
 });
 
-ipcMain.handle("internal-tool-grep", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-grep", async (event, projectPath: string, params) => {
   console.log("Received internal-tool-grep:", projectPath, params.pattern);
+  await acquireToolSlot(event.sender, "grep");
   return await handleGrep({ projectPath, ...params });
 });
 
-ipcMain.handle("internal-tool-bash", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-bash", async (event, projectPath: string, params, toolCallId?: string) => {
   console.log("Received internal-tool-bash:", projectPath, params.command);
-  return await handleBash({ projectPath, ...params });
+  if (!isWorkspaceTrusted(projectPath)) {
+    return untrustedWorkspaceResult("bash");
+  }
+  try {
+    return await withToolCancellation(toolCallId, async (signal) => {
+      await acquireToolSlot(event.sender, "bash", signal);
+      // The session env is added here so it never passes through the model
+      return await handleBash({
+        projectPath,
+        ...params,
+        env: toolEnvByProject.get(projectPath),
+        signal,
+        // Live output for the transcript; the model gets the full result at the end
+        onOutput: toolCallId
+          ? (stream, chunk) => {
+              if (!event.sender.isDestroyed()) {
+                event.sender.send("tool-output", { toolCallId, stream, chunk });
+              }
+            }
+          : undefined,
+      });
+    });
+  } catch (error) {
+    // Cancelled while waiting for the rate limit
+    return {
+      success: false,
+      error: error instanceof Error ? error.message : "Unknown error",
+      stdout: "",
+      stderr: "",
+      command: params.command,
+    };
+  }
 });
 
-ipcMain.handle("internal-tool-ls", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-ls", async (event, projectPath: string, params) => {
   console.log("Received internal-tool-ls:", projectPath, params.path || "/");
+  await acquireToolSlot(event.sender, "ls");
   return await handleLs({ projectPath, ...params });
 });
 
-ipcMain.handle("internal-tool-move", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-move", async (event, projectPath: string, params) => {
   console.log(
     "Received internal-tool-move:",
     projectPath,
@@ -1954,18 +2700,264 @@ ipcMain.handle("internal-tool-move", async (_, projectPath: string, params) => {
     "->",
     params.destination_path,
   );
+  if (!isWorkspaceTrusted(projectPath)) {
+    return untrustedWorkspaceResult("move");
+  }
+  await acquireToolSlot(event.sender, "move");
   return await handleMove({ projectPath, ...params });
 });
 
-ipcMain.handle("internal-tool-rm", async (_, projectPath: string, params) => {
+ipcMain.handle("internal-tool-rm", async (event, projectPath: string, params) => {
   console.log("Received internal-tool-rm:", projectPath, params.path);
+  if (!isWorkspaceTrusted(projectPath)) {
+    return untrustedWorkspaceResult("rm");
+  }
+  await acquireToolSlot(event.sender, "rm");
   return await handleRm({ projectPath, ...params });
 });
 
 ipcMain.handle(
   "internal-tool-mkdir",
-  async (_, projectPath: string, params) => {
+  async (event, projectPath: string, params) => {
     console.log("Received internal-tool-mkdir:", projectPath, params.path);
+    if (!isWorkspaceTrusted(projectPath)) {
+      return untrustedWorkspaceResult("mkdir");
+    }
+    await acquireToolSlot(event.sender, "mkdir");
     return await handleMkdir({ projectPath, ...params });
   },
 );
+
+ipcMain.handle("internal-tool-web-search", async (event, params, toolCallId?: string) => {
+  console.log("Received internal-tool-web-search:", params.query);
+  await acquireToolSlot(event.sender, "web_search");
+  const config = (await readPreference("webSearch")) as WebSearchConfig | null;
+  return await withToolCancellation(toolCallId, (signal) => handleWebSearch({ ...params, config, signal }));
+});
+
+ipcMain.handle("internal-tool-fetch-url", async (event, params, toolCallId?: string) => {
+  console.log("Received internal-tool-fetch-url:", params.url);
+  await acquireToolSlot(event.sender, "fetch_url");
+  return await withToolCancellation(toolCallId, (signal) => handleFetchUrl({ ...params, signal }));
+});
+
+// /debug pane: the recent log entries, then each new one while it's open.
+// These handlers don't log their calls, or the pane would fill with its own requests.
+const logFollowers = new Map<number, () => void>();
+
+ipcMain.handle("logs-recent", async () => {
+  return { success: true, entries: recentLogEntries(), file: currentLogFile(), error: null };
+});
+
+ipcMain.handle("logs-follow", async (event, follow: boolean) => {
+  const sender = event.sender;
+  logFollowers.get(sender.id)?.();
+  logFollowers.delete(sender.id);
+  if (follow) {
+    const remove = addLogSink({
+      level: "debug",
+      write: (entry) => {
+        if (!sender.isDestroyed()) {
+          sender.send("log-entry", entry);
+        }
+      },
+    });
+    logFollowers.set(sender.id, remove);
+    sender.once("destroyed", () => {
+      remove();
+      logFollowers.delete(sender.id);
+    });
+  }
+  return { success: true, error: null };
+});
+
+// /events pane: engine events are few, so every window gets them as they
+// happen and asks for the earlier ones when the pane opens
+addLogSink({
+  level: "debug",
+  write: (entry) => {
+    if (entry.event) {
+      for (const window of BrowserWindow.getAllWindows()) {
+        if (!window.webContents.isDestroyed()) {
+          window.webContents.send("engine-event", entry);
+        }
+      }
+    }
+  },
+});
+
+ipcMain.handle("engine-events-recent", async () => {
+  console.log("Received engine-events-recent");
+  return { success: true, events: recentEngineEvents(), error: null };
+});
+
+// Events from the renderer's side of the engine: tool result cache hits and hooks
+const rendererLog = createLogger("renderer");
+
+ipcMain.handle("engine-event-record", async (_, params: {
+  kind: string;
+  scope: string;
+  message: string;
+  fields?: Record<string, unknown>;
+}) => {
+  console.log("Received engine-event-record:", params.kind);
+  if (!isEngineEventKind(params.kind)) {
+    return { success: false, error: `Unknown event kind: ${params.kind}` };
+  }
+  rendererLog.child(params.scope).event(params.kind, params.message, params.fields);
+  return { success: true, error: null };
+});
+
+// The /observe server and the window whose session it shows
+let observer: { server: ObserverServer; ownerId: number } | null = null;
+
+function publishToObserver(sender: Electron.WebContents, chatEvent: ChatEvent) {
+  if (observer && observer.ownerId === sender.id) {
+    observer.server.publishEvent(chatEvent);
+  }
+}
+
+ipcMain.handle("observer-start", async (event, port?: number) => {
+  console.log("Received observer-start:", port ?? "default port");
+  try {
+    if (observer) {
+      if (observer.ownerId !== event.sender.id) {
+        throw new Error("Another window is already being observed; stop it there first");
+      }
+      return { success: true, url: observer.server.url, error: null };
+    }
+    const server = new ObserverServer();
+    const url = await server.start(port);
+    const ownerId = event.sender.id;
+    observer = { server, ownerId };
+    event.sender.once("destroyed", () => {
+      if (observer?.ownerId === ownerId) {
+        observer.server.stop().catch(() => undefined);
+        observer = null;
+      }
+    });
+    return { success: true, url, error: null };
+  } catch (error) {
+    const message = error instanceof Error ? error.message : "Unknown error";
+    return { success: false, url: null, error: message.includes("EADDRINUSE") ? `Port ${port ?? DEFAULT_OBSERVER_PORT} is already in use` : message };
+  }
+});
+
+ipcMain.handle("observer-stop", async (event) => {
+  console.log("Received observer-stop");
+  if (!observer || observer.ownerId !== event.sender.id) {
+    return { success: true, stopped: false, error: null };
+  }
+  await observer.server.stop();
+  observer = null;
+  return { success: true, stopped: true, error: null };
+});
+
+ipcMain.handle("observer-status", async (event) => {
+  console.log("Received observer-status");
+  const owned = observer && observer.ownerId === event.sender.id ? observer : null;
+  return {
+    success: true,
+    running: !!owned,
+    url: owned?.server.url ?? null,
+    observers: owned?.server.observerCount ?? 0,
+    error: null,
+  };
+});
+
+// Sent by the renderer whenever the conversation changes, with tool arguments masked
+ipcMain.handle("observer-publish", async (event, messages: ObservedMessage[]) => {
+  if (observer && observer.ownerId === event.sender.id) {
+    observer.server.publishHistory(messages);
+  }
+  return { success: true, error: null };
+});
+
+// Project indexes for retrieval, built with "poe index <path>" or /rag index
+const ragStore = new IndexStore(path.join(homedir(), ".config", CONFIG_DIR_NAME, "rag"));
+
+// The chat request pipeline, shared by chat-send-message and headless mode
+const engine = createEngine({
+  loadConfig: async () => {
+    await loadProviders();
+    await loadRateLimits();
+  },
+  readPreference,
+  loadRequestRetry,
+  ragStore,
+});
+
+ipcMain.handle("rag-status", async (_event, projectPath: string) => {
+  console.log("Received rag-status:", projectPath);
+  try {
+    const status = await ragStatus(ragStore, projectPath, (await readPreference("rag")) as RagSettings | null);
+    return { success: true, status, error: null };
+  } catch (error) {
+    return { success: false, status: null, error: error instanceof Error ? error.message : "Unknown error" };
+  }
+});
+
+ipcMain.handle("rag-index", async (_event, projectPath: string, targetPath: string) => {
+  console.log("Received rag-index:", projectPath, targetPath);
+  try {
+    await loadProviders();
+    const settings = (await readPreference("rag")) as RagSettings | null;
+    const result = await indexPath(ragStore, resolveEmbedder(settings), projectPath, targetPath, {
+      chunkSize: settings?.chunkSize,
+    });
+    return { success: true, result, error: null };
+  } catch (error) {
+    return { success: false, result: null, error: error instanceof Error ? error.message : "Unknown error" };
+  }
+});
+
+ipcMain.handle("rag-clear", async (_event, projectPath: string) => {
+  console.log("Received rag-clear:", projectPath);
+  try {
+    return { success: true, removed: await ragStore.remove(projectPath), error: null };
+  } catch (error) {
+    return { success: false, removed: false, error: error instanceof Error ? error.message : "Unknown error" };
+  }
+});
+
+// Windows whose watches are stopped when they close
+const watchOwners = new Set<number>();
+
+ipcMain.handle("watch-start", async (event, projectPath: string, params: Omit<WatchParams, "projectPath">) => {
+  console.log("Received watch-start:", projectPath, params.path);
+  try {
+    const sender = event.sender;
+    if (!watchOwners.has(sender.id)) {
+      watchOwners.add(sender.id);
+      const ownerId = sender.id;
+      sender.once("destroyed", () => {
+        watchOwners.delete(ownerId);
+        stopWatchesFor(ownerId);
+      });
+    }
+    const watch = await startWatch(sender.id, { ...params, projectPath }, (watchEvent) => {
+      if (!sender.isDestroyed()) {
+        sender.send("watch-event", watchEvent);
+      }
+    });
+    return { success: true, watch, error: null };
+  } catch (error) {
+    return {
+      success: false,
+      watch: null,
+      error: error instanceof Error ? error.message : "Unknown error",
+    };
+  }
+});
+
+// Stops one of the window's watches by id, or all of them with "all"
+ipcMain.handle("watch-stop", async (event, watchId: string) => {
+  console.log("Received watch-stop:", watchId);
+  const stopped = watchId === "all" ? stopWatchesFor(event.sender.id) : Number(stopWatch(watchId, event.sender.id));
+  return { success: stopped > 0, stopped, error: stopped > 0 ? null : `No watch ${watchId}` };
+});
+
+ipcMain.handle("watch-list", async (event) => {
+  console.log("Received watch-list");
+  return listWatches(event.sender.id);
+});
@@ -1,15 +1,29 @@
-import { spawn, ChildProcess } from "child_process";
+import { ChildProcess } from "child_process";
 import { EventEmitter } from "events";
+import { interpolateEnv } from "./config-env";
+import { killProcessTree, spawnCommand } from "./platform";
+import { SSETransport } from "./mcp-sse";
+import { createLogger } from "./logger";
+
+const log = createLogger("mcp");
 
 type MCPServerState = 'starting' | 'running' | 'stopping' | 'stopped' | 'failed';
 
-interface MCPServerConfig {
-    command: string;
-    args: string[];
+// Local servers are spawned from `command` and spoken to over stdio; remote
+// servers are reached at `url` over HTTP+SSE.
+export interface MCPServerConfig {
+    command?: string;
+    args?: string[];
     env?: Record<string, string>;
     projectPath?: string;
+    transport?: 'stdio' | 'sse';
+    url?: string;
+    headers?: Record<string, string>; // ${VAR} references are resolved from the environment
 }
 
+const isRemoteConfig = (config: MCPServerConfig): boolean =>
+    config.transport === 'sse' || (!config.transport && !!config.url);
+
 interface MCPToolInfo {
     name: string;
     description: string;
@@ -20,6 +34,28 @@ interface MCPToolInfo {
     };
 }
 
+// An elicitation/create request: the server asks the user for input while it
+// handles a tool call, with a flat JSON schema describing the answer
+export interface MCPElicitRequest {
+    message: string;
+    requestedSchema: {
+        type: "object";
+        properties: Record<string, {
+            type?: string;
+            title?: string;
+            description?: string;
+            enum?: string[];
+        }>;
+        required?: string[];
+    };
+}
+
+export type MCPElicitResult =
+    | { action: "accept"; content: Record<string, unknown> }
+    | { action: "decline" | "cancel" };
+
+export type MCPElicitHandler = (request: MCPElicitRequest) => Promise<MCPElicitResult>;
+
 interface MCPServerStatus {
     name: string;
     state: MCPServerState;
@@ -32,6 +68,7 @@ interface MCPServerStatus {
 
 class MCPServer extends EventEmitter {
     private process: ChildProcess | null = null;
+    private sse: SSETransport | null = null;
     private messageId = 0;
     private pendingRequests: Map<
         number,
@@ -45,6 +82,8 @@ class MCPServer extends EventEmitter {
     private state: MCPServerState = 'stopped';
     private errorMessage?: string;
     private startedAt?: Date;
+    // Handlers of the tool calls in progress, by the id of their tools/call request
+    private elicitHandlers = new Map<number, MCPElicitHandler>();
 
     constructor(
         public name: string,
@@ -54,7 +93,7 @@ class MCPServer extends EventEmitter {
     }
 
     async start(): Promise<void> {
-        if (this.process) {
+        if (this.process || this.sse) {
             throw new Error(`Server ${this.name} is already running`);
         }
 
@@ -62,9 +101,19 @@ class MCPServer extends EventEmitter {
         this.errorMessage = undefined;
         this.startedAt = new Date();
 
+        if (isRemoteConfig(this.config)) {
+            return this.connectRemote();
+        }
+        if (!this.config.command) {
+            this.state = 'failed';
+            this.errorMessage = 'No command or url configured';
+            throw new Error(`Server ${this.name} has no command or url configured`);
+        }
+        const args = this.config.args || [];
+
         console.log(`Starting MCP server: ${this.name}`);
         console.log(
-            `Command: ${this.config.command} ${this.config.args.join(" ")}`,
+            `Command: ${this.config.command} ${args.join(" ")}`,
         );
         if (this.config.projectPath) {
             console.log(`Working directory: ${this.config.projectPath}`);
@@ -89,7 +138,7 @@ class MCPServer extends EventEmitter {
             }
         }
 
-        this.process = spawn(this.config.command, this.config.args, {
+        this.process = spawnCommand(this.config.command, args, {
             env,
             stdio: ["pipe", "pipe", "pipe"],
             cwd: this.config.projectPath, // Set working directory to project path
@@ -103,9 +152,14 @@ class MCPServer extends EventEmitter {
         });
 
         this.process.on("exit", (code, signal) => {
-            console.log(
-                `MCP server ${this.name} exited with code ${code}, signal ${signal}`,
-            );
+            // Exits after stop() are expected; others lose the server's tools
+            if (this.state === 'stopping') {
+                console.log(
+                    `MCP server ${this.name} exited with code ${code}, signal ${signal}`,
+                );
+            } else {
+                log.event("connection", "MCP server exited", { server: this.name, code, signal }, "warn");
+            }
             this.process = null;
             if (this.state === 'running' || this.state === 'starting') {
                 this.state = 'stopped';
@@ -125,11 +179,48 @@ class MCPServer extends EventEmitter {
             });
         }
 
-        // Initialize the server with timeout
+        await this.handshake(() => this.initialize());
+    }
+
+    private async connectRemote(): Promise<void> {
+        const url = this.config.url;
+        if (!url) {
+            this.state = 'failed';
+            this.errorMessage = 'No url configured';
+            throw new Error(`Server ${this.name} uses the sse transport but has no url configured`);
+        }
+
+        console.log(`Connecting to MCP server ${this.name} at ${url}`);
+
+        const sse = new SSETransport(url, interpolateEnv(this.config.headers || {}));
+        this.sse = sse;
+
+        sse.on("message", (message) => this.handleMessage(message));
+        sse.on("close", () => {
+            if (this.sse !== sse) {
+                return;
+            }
+            log.event("connection", "MCP server closed its event stream", { server: this.name }, "warn");
+            this.sse = null;
+            this.rejectPending("Connection closed");
+            if (this.state === 'running' || this.state === 'starting') {
+                this.state = 'stopped';
+            }
+            this.emit("exit", { code: null, signal: null });
+        });
+
+        await this.handshake(async () => {
+            await sse.connect();
+            await this.initialize();
+        });
+    }
+
+    // Initialize the server with timeout and load its tools, cleaning up on failure
+    private async handshake(initialize: () => Promise<void>): Promise<void> {
         try {
             const initTimeout = 60000; // 60 second timeout
             await Promise.race([
-                this.initialize(),
+                initialize(),
                 new Promise((_, reject) =>
                     setTimeout(() => reject(new Error('Server initialization timed out after 60 seconds')), initTimeout)
                 )
@@ -139,22 +230,46 @@ class MCPServer extends EventEmitter {
             await this.loadTools();
 
             this.state = 'running';
-            console.log(`MCP server ${this.name} is now running`);
+            log.event("connection", "MCP server connected", { server: this.name, tools: this.tools.length });
         } catch (error) {
             this.state = 'failed';
             this.errorMessage = error instanceof Error ? error.message : 'Unknown error';
-            console.error(`Failed to start MCP server ${this.name}:`, this.errorMessage);
+            log.event("connection", "MCP server failed to start", { server: this.name, error: this.errorMessage }, "error");
 
-            // Clean up the process
+            // Clean up the process or connection
             if (this.process) {
-                this.process.kill('SIGTERM');
+                killProcessTree(this.process);
                 this.process = null;
             }
+            if (this.sse) {
+                const sse = this.sse;
+                this.sse = null;
+                sse.close();
+            }
 
             throw error;
         }
     }
 
+    private rejectPending(reason: string): void {
+        for (const [, { reject }] of this.pendingRequests.entries()) {
+            reject(new Error(reason));
+        }
+        this.pendingRequests.clear();
+    }
+
+    // Send one JSON-RPC message over whichever transport the server uses
+    private async write(message: unknown): Promise<void> {
+        if (this.sse) {
+            await this.sse.send(message);
+            return;
+        }
+        if (!this.process || !this.process.stdin) {
+            throw new Error(`Server ${this.name} is not running`);
+        }
+        this.process.stdin.write(JSON.stringify(message) + "\n");
+    }
+
     private handleStdout(data: string): void {
         this.buffer += data;
         const lines = this.buffer.split("\n");
@@ -178,9 +293,17 @@ class MCPServer extends EventEmitter {
 
     private handleMessage(message: {
         id?: number;
+        method?: string;
+        params?: unknown;
         result?: unknown;
         error?: unknown;
     }): void {
+        if (message.method !== undefined) {
+            if (message.id !== undefined) {
+                this.handleServerRequest(message.id, message.method, message.params);
+            }
+            return;
+        }
         if (message.id !== undefined) {
             const pending = this.pendingRequests.get(message.id);
             if (pending) {
@@ -194,15 +317,56 @@ class MCPServer extends EventEmitter {
         }
     }
 
+    // Requests the server sends us. Only elicitation is supported.
+    private async handleServerRequest(id: number, method: string, params: unknown): Promise<void> {
+        let response: Record<string, unknown>;
+        if (method === "elicitation/create") {
+            const handler = this.elicitHandlerFor(params);
+            try {
+                const result = handler ? await handler(params as MCPElicitRequest) : { action: "decline" };
+                response = { jsonrpc: "2.0", id, result };
+            } catch (error) {
+                response = {
+                    jsonrpc: "2.0",
+                    id,
+                    error: { code: -32603, message: error instanceof Error ? error.message : "Elicitation failed" },
+                };
+            }
+        } else {
+            response = { jsonrpc: "2.0", id, error: { code: -32601, message: `Method not found: ${method}` } };
+        }
+        await this.write(response).catch((error) => {
+            console.error(`Failed to answer ${method} from ${this.name}:`, error);
+        });
+    }
+
+    // The call an elicitation belongs to is named by the progress token sent with
+    // tools/call. Servers that leave it out are only answered when a single call
+    // is running, so a question never reaches another call's user prompt.
+    private elicitHandlerFor(params: unknown): MCPElicitHandler | undefined {
+        const meta = (params as { _meta?: { progressToken?: unknown; relatedRequestId?: unknown } } | undefined)?._meta;
+        const related = Number(meta?.progressToken ?? meta?.relatedRequestId);
+        if (this.elicitHandlers.has(related)) {
+            return this.elicitHandlers.get(related);
+        }
+        if (this.elicitHandlers.size === 1) {
+            return this.elicitHandlers.values().next().value;
+        }
+        return undefined;
+    }
+
+    // timeoutMs of 0 waits until the response arrives or `signal` is aborted
     private async sendRequest(
         method: string,
         params?: unknown,
+        options: { timeoutMs?: number; signal?: AbortSignal; id?: number } = {},
     ): Promise<unknown> {
-        if (!this.process || !this.process.stdin) {
+        const { timeoutMs = 30000, signal } = options;
+        if (!this.sse && (!this.process || !this.process.stdin)) {
             throw new Error(`Server ${this.name} is not running`);
         }
 
-        const requestId = ++this.messageId;
+        const requestId = options.id ?? ++this.messageId;
         const request = {
             jsonrpc: "2.0",
             id: requestId,
@@ -211,25 +375,55 @@ class MCPServer extends EventEmitter {
         };
 
         return new Promise((resolve, reject) => {
-            this.pendingRequests.set(requestId, { resolve, reject });
+            if (signal?.aborted) {
+                reject(new Error(`Request cancelled for ${method}`));
+                return;
+            }
 
-            const timeout = setTimeout(() => {
+            const timeout = timeoutMs > 0
+                ? setTimeout(() => {
+                    this.pendingRequests.delete(requestId);
+                    signal?.removeEventListener("abort", onAbort);
+                    reject(new Error(`Request timeout for ${method}`));
+                }, timeoutMs)
+                : undefined;
+
+            // Tell the server to stop working on it; the reply, if any, is ignored
+            const onAbort = () => {
+                if (!this.pendingRequests.has(requestId)) {
+                    return;
+                }
                 this.pendingRequests.delete(requestId);
-                reject(new Error(`Request timeout for ${method}`));
-            }, 30000); // 30 second timeout
+                clearTimeout(timeout);
+                reject(new Error(`Request cancelled for ${method}`));
+                this.write({
+                    jsonrpc: "2.0",
+                    method: "notifications/cancelled",
+                    params: { requestId, reason: "Cancelled by the client" },
+                }).catch(() => undefined);
+            };
+            signal?.addEventListener("abort", onAbort, { once: true });
 
             this.pendingRequests.set(requestId, {
                 resolve: (value) => {
                     clearTimeout(timeout);
+                    signal?.removeEventListener("abort", onAbort);
                     resolve(value);
                 },
                 reject: (error) => {
                     clearTimeout(timeout);
+                    signal?.removeEventListener("abort", onAbort);
                     reject(error);
                 },
             });
 
-            this.process!.stdin!.write(JSON.stringify(request) + "\n");
+            this.write(request).catch((error) => {
+                const pending = this.pendingRequests.get(requestId);
+                if (pending) {
+                    this.pendingRequests.delete(requestId);
+                    pending.reject(error instanceof Error ? error : new Error(String(error)));
+                }
+            });
         });
     }
 
@@ -239,6 +433,7 @@ class MCPServer extends EventEmitter {
                 protocolVersion: "2024-11-05",
                 capabilities: {
                     tools: {},
+                    elicitation: {},
                 },
                 clientInfo: {
                     name: "POE",
@@ -247,13 +442,10 @@ class MCPServer extends EventEmitter {
             });
 
             // Send initialized notification
-            if (this.process && this.process.stdin) {
-                const notification = {
-                    jsonrpc: "2.0",
-                    method: "notifications/initialized",
-                };
-                this.process.stdin.write(JSON.stringify(notification) + "\n");
-            }
+            await this.write({
+                jsonrpc: "2.0",
+                method: "notifications/initialized",
+            });
         } catch (error) {
             console.error(`Failed to initialize MCP server ${this.name}:`, error);
             throw error;
@@ -276,23 +468,44 @@ class MCPServer extends EventEmitter {
         }
     }
 
+    // No fixed timeout: the renderer applies the tool's own and aborts `signal`.
+    // `onElicit` answers the server's questions to the user during the call.
     async callTool(
         name: string,
         args: Record<string, unknown>,
+        signal?: AbortSignal,
+        onElicit?: MCPElicitHandler,
     ): Promise<unknown> {
+        const requestId = ++this.messageId;
+        if (onElicit) {
+            this.elicitHandlers.set(requestId, onElicit);
+        }
         try {
             const result = await this.sendRequest("tools/call", {
                 name,
                 arguments: args,
-            });
+                _meta: { progressToken: requestId },
+            }, { timeoutMs: 0, signal, id: requestId });
             return result;
         } catch (error) {
             console.error(`Failed to call tool ${name} on ${this.name}:`, error);
             throw error;
+        } finally {
+            this.elicitHandlers.delete(requestId);
         }
     }
 
     async stop(): Promise<void> {
+        if (this.sse) {
+            console.log(`Disconnecting MCP server: ${this.name}`);
+            const sse = this.sse;
+            this.sse = null;
+            this.rejectPending("Server stopped");
+            sse.close();
+            this.state = 'stopped';
+            return;
+        }
+
         if (!this.process) {
             this.state = 'stopped';
             return;
@@ -302,16 +515,13 @@ class MCPServer extends EventEmitter {
         console.log(`Stopping MCP server: ${this.name}`);
 
         // Clear pending requests
-        for (const [, { reject }] of this.pendingRequests.entries()) {
-            reject(new Error("Server stopped"));
-        }
-        this.pendingRequests.clear();
+        this.rejectPending("Server stopped");
 
         return new Promise((resolve) => {
             const timeout = setTimeout(() => {
                 if (this.process) {
                     console.log(`Force killing MCP server: ${this.name}`);
-                    this.process.kill("SIGKILL");
+                    killProcessTree(this.process, "SIGKILL");
                 }
                 this.state = 'stopped';
                 resolve();
@@ -324,11 +534,14 @@ class MCPServer extends EventEmitter {
                 resolve();
             });
 
-            this.process!.kill("SIGTERM");
+            killProcessTree(this.process!);
         });
     }
 
     isRunning(): boolean {
+        if (this.sse) {
+            return this.sse.isConnected();
+        }
         return this.process !== null && !this.process.killed;
     }
 
@@ -408,6 +621,8 @@ class MCPManager {
         serverName: string,
         toolName: string,
         args: Record<string, unknown>,
+        signal?: AbortSignal,
+        onElicit?: MCPElicitHandler,
     ): Promise<unknown> {
         const server = this.servers.get(serverName);
         if (!server) {
@@ -418,7 +633,7 @@ class MCPManager {
             throw new Error(`Server ${serverName} is not running`);
         }
 
-        return await server.callTool(toolName, args);
+        return await server.callTool(toolName, args, signal, onElicit);
     }
 
     getServerStatus(name: string): MCPServerStatus | null {
@@ -485,13 +700,15 @@ class MCPManager {
             const server = this.servers.get(name);
             const newConf = newConfig[name];
 
-            // Simple check: compare command, args, and projectPath
+            // Simple check: compare command, args, url, and projectPath
             // If they differ, restart the server
             if (server) {
                 const oldConfig = (server as any).config; // Access private config
                 const configChanged =
                     oldConfig.command !== newConf.command ||
                     JSON.stringify(oldConfig.args) !== JSON.stringify(newConf.args) ||
+                    oldConfig.url !== newConf.url ||
+                    JSON.stringify(oldConfig.headers) !== JSON.stringify(newConf.headers) ||
                     oldConfig.projectPath !== newConf.projectPath;
 
                 if (configChanged) {
@@ -0,0 +1,142 @@
+import { EventEmitter } from "events";
+
+// Client side of the MCP HTTP+SSE transport (protocol 2024-11-05). The server
+// streams JSON-RPC messages as "message" events on a long-lived GET, after first
+// sending an "endpoint" event with the URL that requests are POSTed to.
+//
+// Events: "message" (parsed JSON-RPC message), "close" (stream ended or failed).
+
+export class SSETransport extends EventEmitter {
+    private controller: AbortController | null = null;
+    private endpoint: string | null = null;
+    private closed = false;
+
+    constructor(
+        private url: string,
+        private headers: Record<string, string> = {},
+    ) {
+        super();
+    }
+
+    /**
+     * Open the event stream and wait for the server to announce its endpoint
+     */
+    async connect(): Promise<void> {
+        this.controller = new AbortController();
+        this.closed = false;
+
+        const response = await fetch(this.url, {
+            headers: { ...this.headers, Accept: "text/event-stream" },
+            signal: this.controller.signal,
+        });
+        if (!response.ok || !response.body) {
+            throw new Error(`SSE connection failed: ${response.status} ${response.statusText}`);
+        }
+
+        const endpointReady = new Promise<void>((resolve, reject) => {
+            this.once("endpoint", resolve);
+            this.once("close", () => reject(new Error("SSE stream closed before the server sent its endpoint")));
+        });
+
+        this.readStream(response.body).catch((error) => {
+            if (!this.closed) {
+                console.error(`SSE stream from ${this.url} failed:`, error);
+            }
+        }).finally(() => this.handleClose());
+
+        await endpointReady;
+    }
+
+    private async readStream(body: ReadableStream<Uint8Array>): Promise<void> {
+        const reader = body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = "";
+
+        for (;;) {
+            const { done, value } = await reader.read();
+            if (done) {
+                return;
+            }
+            buffer += decoder.decode(value, { stream: true }).replace(/\r\n?/g, "\n");
+
+            let separator = buffer.indexOf("\n\n");
+            while (separator !== -1) {
+                this.handleEvent(buffer.substring(0, separator));
+                buffer = buffer.substring(separator + 2);
+                separator = buffer.indexOf("\n\n");
+            }
+        }
+    }
+
+    private handleEvent(block: string): void {
+        let event = "message";
+        const data: string[] = [];
+        for (const line of block.split("\n")) {
+            if (line.startsWith(":")) {
+                continue; // Comment / keep-alive
+            }
+            const colon = line.indexOf(":");
+            const field = colon === -1 ? line : line.substring(0, colon);
+            const value = colon === -1 ? "" : line.substring(colon + 1).replace(/^ /, "");
+            if (field === "event") {
+                event = value;
+            } else if (field === "data") {
+                data.push(value);
+            }
+        }
+        if (data.length === 0) {
+            return;
+        }
+
+        const payload = data.join("\n");
+        if (event === "endpoint") {
+            this.endpoint = new URL(payload, this.url).toString();
+            this.emit("endpoint");
+            return;
+        }
+        if (event === "message") {
+            try {
+                this.emit("message", JSON.parse(payload));
+            } catch (error) {
+                console.error(`Failed to parse SSE message from ${this.url}:`, payload, error);
+            }
+        }
+    }
+
+    private handleClose(): void {
+        const wasOpen = this.controller !== null;
+        this.controller = null;
+        this.endpoint = null;
+        if (wasOpen) {
+            this.emit("close");
+        }
+    }
+
+    /**
+     * POST one JSON-RPC message. Responses arrive on the event stream.
+     */
+    async send(message: unknown): Promise<void> {
+        if (!this.endpoint) {
+            throw new Error("SSE transport is not connected");
+        }
+
+        const response = await fetch(this.endpoint, {
+            method: "POST",
+            headers: { ...this.headers, "Content-Type": "application/json" },
+            body: JSON.stringify(message),
+        });
+        if (!response.ok) {
+            const body = await response.text().catch(() => "");
+            throw new Error(`MCP request failed: ${response.status} ${response.statusText}${body ? ` - ${body}` : ""}`);
+        }
+    }
+
+    close(): void {
+        this.closed = true;
+        this.controller?.abort();
+    }
+
+    isConnected(): boolean {
+        return this.controller !== null && this.endpoint !== null;
+    }
+}
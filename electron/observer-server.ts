@@ -0,0 +1,193 @@
+import { createServer, type IncomingMessage, type Server, type ServerResponse } from "node:http";
+import { randomBytes } from "node:crypto";
+import type { ChatEvent } from "../src/types/events";
+
+// Read-only view of a live session for someone else to watch, started with
+// /observe. Observers open the page in a browser or run "poe attach <url>" in
+// a terminal; both read the same Server-Sent Events stream:
+//
+//   event: history   the conversation so far (tool arguments already masked)
+//   event: chat      each chat event as in docs/CHAT_EVENTS.md
+//
+// Nothing can be sent back. The server listens on localhost and every request
+// needs the random token from the URL.
+
+export const DEFAULT_OBSERVER_PORT = 7878;
+const KEEPALIVE_MS = 15000;
+
+export interface ObservedMessage {
+    role: string;
+    content: string;
+    thinking?: string;
+    tool_calls?: Array<{ id: string; function: { name: string; arguments: string } }>;
+    tool_call_id?: string;
+}
+
+const OBSERVER_PAGE = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>POE observer</title>
+<style>
+  body { margin: 0; background: #1e1e2e; color: #cdd6f4; font: 14px/1.6 -apple-system, "Segoe UI", Roboto, sans-serif; }
+  header { padding: 8px 16px; border-bottom: 1px solid rgba(205, 214, 244, 0.1); color: rgba(205, 214, 244, 0.6); font-size: 12px; }
+  main { max-width: 900px; margin: 0 auto; padding: 16px; }
+  .message { margin: 12px 0; padding: 8px 12px; border-radius: 6px; white-space: pre-wrap; word-break: break-word; }
+  .user { background: #313244; }
+  .assistant { border-left: 3px solid #89b4fa; }
+  .tool { border-left: 3px solid #a6e3a1; font-family: monospace; font-size: 12px; color: rgba(205, 214, 244, 0.8); max-height: 240px; overflow: auto; }
+  .thinking { color: rgba(205, 214, 244, 0.5); font-style: italic; }
+  .role { font-size: 11px; text-transform: uppercase; color: rgba(205, 214, 244, 0.5); }
+  .status { color: #f9e2af; font-size: 12px; }
+</style>
+</head>
+<body>
+<header id="state">Connecting…</header>
+<main id="messages"></main>
+<script>
+  const list = document.getElementById("messages");
+  const state = document.getElementById("state");
+  let live = null;
+
+  function bubble(role, text, extra) {
+    const el = document.createElement("div");
+    el.className = "message " + role + (extra ? " " + extra : "");
+    const label = document.createElement("div");
+    label.className = "role";
+    label.textContent = role;
+    const body = document.createElement("div");
+    body.textContent = text;
+    el.append(label, body);
+    list.append(el);
+    return body;
+  }
+
+  const source = new EventSource("events" + location.search);
+  source.onopen = () => { state.textContent = "Watching (read-only)"; };
+  source.onerror = () => { state.textContent = "Disconnected, retrying…"; };
+  source.addEventListener("history", (e) => {
+    const { messages } = JSON.parse(e.data);
+    list.textContent = "";
+    live = null;
+    for (const m of messages) {
+      if (m.role === "system") continue;
+      if (m.content) bubble(m.role, m.content);
+      for (const tc of m.tool_calls || []) bubble("tool", tc.function.name + " " + tc.function.arguments);
+    }
+    window.scrollTo(0, document.body.scrollHeight);
+  });
+  source.addEventListener("chat", (e) => {
+    const event = JSON.parse(e.data);
+    if (event.type === "content" || event.type === "thinking") {
+      if (!live) live = { content: bubble("assistant", ""), thinking: null };
+      if (event.type === "thinking") {
+        if (!live.thinking) live.thinking = bubble("assistant", "", "thinking");
+        live.thinking.textContent += event.thinking;
+      } else {
+        live.content.textContent += event.content;
+      }
+      window.scrollTo(0, document.body.scrollHeight);
+    } else if (event.type === "tool_call") {
+      bubble("tool", event.tool_call.function.name + " …");
+    } else if (event.type === "status") {
+      state.textContent = event.message;
+    } else if (event.type === "done" || event.type === "error" || event.type === "cancelled") {
+      state.textContent = event.type === "error" ? "Error: " + event.error : "Watching (read-only)";
+      live = null;
+    }
+  });
+</script>
+</body>
+</html>
+`;
+
+export class ObserverServer {
+    private server: Server | null = null;
+    private clients = new Set<ServerResponse>();
+    private history: ObservedMessage[] = [];
+    private keepalive: ReturnType<typeof setInterval> | null = null;
+    readonly token = randomBytes(16).toString("hex");
+    url = "";
+
+    async start(port: number = DEFAULT_OBSERVER_PORT, host = "127.0.0.1"): Promise<string> {
+        const server = createServer((req, res) => this.handle(req, res));
+        await new Promise<void>((resolve, reject) => {
+            server.once("error", reject);
+            server.listen(port, host, () => {
+                server.removeListener("error", reject);
+                resolve();
+            });
+        });
+        this.server = server;
+        this.keepalive = setInterval(() => {
+            this.clients.forEach(client => client.write(": keepalive\n\n"));
+        }, KEEPALIVE_MS);
+        this.url = `http://${host}:${port}/?token=${this.token}`;
+        return this.url;
+    }
+
+    async stop(): Promise<void> {
+        if (this.keepalive) {
+            clearInterval(this.keepalive);
+            this.keepalive = null;
+        }
+        this.clients.forEach(client => client.end());
+        this.clients.clear();
+        const server = this.server;
+        this.server = null;
+        if (server) {
+            await new Promise<void>(resolve => server.close(() => resolve()));
+        }
+    }
+
+    get observerCount(): number {
+        return this.clients.size;
+    }
+
+    publishHistory(messages: ObservedMessage[]) {
+        this.history = messages;
+        this.broadcast("history", { messages });
+    }
+
+    publishEvent(event: ChatEvent) {
+        // Arguments stream before the renderer can mask them; they arrive masked with the next history
+        const payload = event.type === "tool_call"
+            ? { ...event, tool_call: { ...event.tool_call, function: { ...event.tool_call.function, arguments: "" } } }
+            : event;
+        this.broadcast("chat", payload);
+    }
+
+    private broadcast(name: string, data: unknown) {
+        const frame = `event: ${name}\ndata: ${JSON.stringify(data)}\n\n`;
+        this.clients.forEach(client => client.write(frame));
+    }
+
+    private handle(req: IncomingMessage, res: ServerResponse) {
+        const url = new URL(req.url || "/", "http://localhost");
+        if (req.method !== "GET") {
+            res.writeHead(405, { Allow: "GET" }).end();
+            return;
+        }
+        if (url.searchParams.get("token") !== this.token) {
+            res.writeHead(403, { "Content-Type": "text/plain" }).end("Missing or wrong token\n");
+            return;
+        }
+
+        if (url.pathname === "/") {
+            res.writeHead(200, { "Content-Type": "text/html; charset=utf-8" }).end(OBSERVER_PAGE);
+            return;
+        }
+        if (url.pathname === "/events") {
+            res.writeHead(200, {
+                "Content-Type": "text/event-stream",
+                "Cache-Control": "no-cache",
+                Connection: "keep-alive",
+            });
+            res.write(`event: history\ndata: ${JSON.stringify({ messages: this.history })}\n\n`);
+            this.clients.add(res);
+            req.on("close", () => this.clients.delete(res));
+            return;
+        }
+        res.writeHead(404, { "Content-Type": "text/plain" }).end("Not found\n");
+    }
+}
@@ -0,0 +1,196 @@
+import { watch, type FSWatcher } from "node:fs";
+import { open, stat } from "node:fs/promises";
+import { join } from "node:path";
+import { resolveProjectPath, toProjectPath } from "./internal-tools";
+
+// Watches started by the watch_path tool or /watch. A change is reported to the
+// window that started the watch after a short quiet period, with the lines
+// appended to a watched file, so "tell me when the build log shows an error"
+// can be answered without polling.
+
+const DEBOUNCE_MS = 500;
+const MAX_EXCERPT_BYTES = 8 * 1024;
+const MAX_WATCHES_PER_WINDOW = 10;
+
+export interface WatchParams {
+    projectPath: string;
+    path: string; // Project path starting with /
+    pattern?: string; // Regex; only appended lines (files) or names (directories) that match are reported
+    react?: boolean; // Ask the model to respond to each change
+    instructions?: string; // What the model should do when it reacts
+}
+
+export interface WatchInfo {
+    id: string;
+    path: string;
+    isDirectory: boolean;
+    pattern?: string;
+    react: boolean;
+    instructions?: string;
+    startedAt: string;
+}
+
+export interface WatchEvent {
+    watch: WatchInfo;
+    files: string[]; // Changed paths, relative to the project
+    excerpt?: string; // Appended lines of a watched file (only the matching ones with a pattern)
+}
+
+interface ActiveWatch {
+    info: WatchInfo;
+    ownerId: number;
+    watcher: FSWatcher;
+    absolutePath: string;
+    regex: RegExp | null;
+    size: number; // Of a watched file, to read only what was appended
+    changed: Set<string>;
+    timer: ReturnType<typeof setTimeout> | null;
+}
+
+let nextId = 0;
+const watches = new Map<string, ActiveWatch>();
+
+async function readAppended(active: ActiveWatch): Promise<string> {
+    const { size } = await stat(active.absolutePath);
+    // Truncated or rewritten: start over
+    const from = size < active.size ? 0 : active.size;
+    active.size = size;
+    if (size === from) {
+        return "";
+    }
+    const start = Math.max(from, size - MAX_EXCERPT_BYTES);
+    const handle = await open(active.absolutePath, "r");
+    try {
+        const buffer = Buffer.alloc(size - start);
+        await handle.read(buffer, 0, buffer.length, start);
+        return buffer.toString("utf-8");
+    } finally {
+        await handle.close();
+    }
+}
+
+async function flush(active: ActiveWatch, emit: (event: WatchEvent) => void): Promise<void> {
+    active.timer = null;
+    const files = Array.from(active.changed);
+    active.changed.clear();
+
+    if (!active.info.isDirectory) {
+        let appended: string;
+        try {
+            appended = await readAppended(active);
+        } catch {
+            return; // Deleted or unreadable between the change and now
+        }
+        const lines = appended.split("\n").filter(line => line.trim());
+        const matched = active.regex ? lines.filter(line => active.regex!.test(line)) : lines;
+        if (active.regex && matched.length === 0) {
+            return;
+        }
+        emit({ watch: active.info, files: [active.info.path], ...(matched.length > 0 && { excerpt: matched.join("\n") }) });
+        return;
+    }
+
+    const matched = active.regex ? files.filter(file => active.regex!.test(file)) : files;
+    if (matched.length > 0) {
+        emit({ watch: active.info, files: matched });
+    }
+}
+
+/**
+ * Start watching a file or directory in the project. `emit` is called with each
+ * batch of changes until the watch is stopped.
+ */
+export async function startWatch(ownerId: number, params: WatchParams, emit: (event: WatchEvent) => void): Promise<WatchInfo> {
+    const owned = Array.from(watches.values()).filter(w => w.ownerId === ownerId);
+    if (owned.length >= MAX_WATCHES_PER_WINDOW) {
+        throw new Error(`At most ${MAX_WATCHES_PER_WINDOW} paths can be watched at once; stop one first`);
+    }
+
+    const absolutePath = resolveProjectPath(params.path, params.projectPath);
+    const stats = await stat(absolutePath).catch(() => null);
+    if (!stats) {
+        throw new Error(`Path not found: ${params.path}`);
+    }
+    let regex: RegExp | null = null;
+    if (params.pattern) {
+        try {
+            regex = new RegExp(params.pattern, "i");
+        } catch (error) {
+            throw new Error(`Invalid pattern: ${error instanceof Error ? error.message : params.pattern}`);
+        }
+    }
+
+    const info: WatchInfo = {
+        id: `watch-${++nextId}`,
+        path: toProjectPath(absolutePath, params.projectPath),
+        isDirectory: stats.isDirectory(),
+        ...(params.pattern && { pattern: params.pattern }),
+        react: params.react === true,
+        ...(params.instructions && { instructions: params.instructions }),
+        startedAt: new Date().toISOString(),
+    };
+
+    // Recursive watching is supported on macOS and Windows, and on Linux since Node 20
+    const watcher = watch(absolutePath, { recursive: info.isDirectory, persistent: false });
+    const active: ActiveWatch = {
+        info,
+        ownerId,
+        watcher,
+        absolutePath,
+        regex,
+        size: stats.isFile() ? stats.size : 0,
+        changed: new Set(),
+        timer: null,
+    };
+    watcher.on("change", (_eventType, fileName) => {
+        const name = fileName ? fileName.toString() : "";
+        active.changed.add(info.isDirectory && name
+            ? toProjectPath(join(absolutePath, name), params.projectPath)
+            : info.path);
+        if (active.timer) {
+            clearTimeout(active.timer);
+        }
+        active.timer = setTimeout(() => {
+            flush(active, emit).catch(error => console.error(`Watch ${info.id} failed:`, error));
+        }, DEBOUNCE_MS);
+    });
+    watcher.on("error", (error) => {
+        console.error(`Watch ${info.id} on ${info.path} stopped:`, error);
+        stopWatch(info.id);
+    });
+
+    watches.set(info.id, active);
+    console.log(`Watching ${info.path} (${info.id})`);
+    return info;
+}
+
+/**
+ * Stop one watch. With `ownerId`, only if that window started it.
+ */
+export function stopWatch(id: string, ownerId?: number): boolean {
+    const active = watches.get(id);
+    if (!active || (ownerId !== undefined && active.ownerId !== ownerId)) {
+        return false;
+    }
+    if (active.timer) {
+        clearTimeout(active.timer);
+    }
+    active.watcher.close();
+    watches.delete(id);
+    return true;
+}
+
+/**
+ * Stop every watch a window started, when asked to or when it closes
+ */
+export function stopWatchesFor(ownerId: number): number {
+    const owned = Array.from(watches.values()).filter(w => w.ownerId === ownerId);
+    owned.forEach(w => stopWatch(w.info.id));
+    return owned.length;
+}
+
+export function listWatches(ownerId: number): WatchInfo[] {
+    return Array.from(watches.values())
+        .filter(w => w.ownerId === ownerId)
+        .map(w => w.info);
+}
@@ -0,0 +1,45 @@
+// Run with: npm test (node --test, with type stripping; needs Node 22.6 or later)
+import { test } from "node:test";
+import assert from "node:assert/strict";
+import path from "node:path";
+import { homedir } from "node:os";
+import { IS_WINDOWS, detectLineEnding, expandHome, quoteForCmd, spawnCommand, withLineEnding } from "./platform.ts";
+
+test("expandHome accepts either separator after ~", () => {
+    assert.equal(expandHome("~"), homedir());
+    assert.equal(expandHome("~/models"), path.join(homedir(), "models"));
+    assert.equal(expandHome("~\\models"), path.join(homedir(), "models"));
+    assert.equal(expandHome("~user/models"), "~user/models");
+    assert.equal(expandHome("/tmp/models"), "/tmp/models");
+});
+
+test("quoteForCmd quotes arguments cmd.exe would split or interpret", () => {
+    assert.equal(quoteForCmd("plain"), "plain");
+    assert.equal(quoteForCmd(""), '""');
+    assert.equal(quoteForCmd("two words"), '"two words"');
+    assert.equal(quoteForCmd("a&b"), '"a&b"');
+    assert.equal(quoteForCmd('say "hi"'), '"say ""hi"""');
+});
+
+test("detectLineEnding reads the first line break", () => {
+    assert.equal(detectLineEnding("a\r\nb\nc"), "\r\n");
+    assert.equal(detectLineEnding("a\nb\r\nc"), "\n");
+    assert.equal(detectLineEnding("no breaks"), "\n");
+});
+
+test("withLineEnding converts every line break", () => {
+    assert.equal(withLineEnding("a\nb\r\nc", "\r\n"), "a\r\nb\r\nc");
+    assert.equal(withLineEnding("a\r\nb\nc", "\n"), "a\nb\nc");
+});
+
+test("spawnCommand passes arguments through cmd.exe unchanged", { skip: !IS_WINDOWS }, async () => {
+    const args = ["two words", 'say "hi"', "a&b|c", ""];
+    const child = spawnCommand(process.execPath, ["-e", "console.log(JSON.stringify(process.argv.slice(1)))", ...args], {});
+    let stdout = "";
+    child.stdout?.on("data", (data: Buffer) => {
+        stdout += data.toString();
+    });
+    const code = await new Promise<number | null>((resolve) => child.on("close", resolve));
+    assert.equal(code, 0);
+    assert.deepEqual(JSON.parse(stdout), args);
+});
@@ -0,0 +1,101 @@
+import path from "node:path";
+import { homedir } from "node:os";
+import { existsSync } from "node:fs";
+import { spawn, type ChildProcess, type SpawnOptions } from "node:child_process";
+
+// Differences between Windows and Unix that the main process has to paper over
+
+export const IS_WINDOWS = process.platform === "win32";
+
+/**
+ * Expand a leading "~" to the home directory. Accepts either separator after it,
+ * so "~\\models" works on Windows; "~user" forms are left alone.
+ */
+export function expandHome(inputPath: string): string {
+    if (inputPath === "~") {
+        return homedir();
+    }
+    if (inputPath.startsWith("~/") || inputPath.startsWith("~\\")) {
+        return path.join(homedir(), inputPath.slice(2));
+    }
+    return inputPath;
+}
+
+const CMD_SPECIAL_CHARS = /[\s"&|<>^%()]/;
+
+// cmd.exe doesn't know backslash escapes; a quote inside quotes is doubled
+export function quoteForCmd(arg: string): string {
+    return arg === "" || CMD_SPECIAL_CHARS.test(arg) ? `"${arg.replace(/"/g, '""')}"` : arg;
+}
+
+/**
+ * Spawn a program by name the way a terminal would. On Windows, commands such as
+ * npx and uvx are .cmd shims that can only be started through cmd.exe, so the
+ * command line goes through the shell with its arguments quoted, without
+ * opening a console window.
+ */
+export function spawnCommand(command: string, args: string[], options: SpawnOptions): ChildProcess {
+    if (IS_WINDOWS) {
+        return spawn([command, ...args].map(quoteForCmd).join(" "), {
+            ...options,
+            shell: true,
+            windowsHide: true,
+        });
+    }
+    return spawn(command, args, options);
+}
+
+let bashShell: string | true | undefined;
+
+/**
+ * Shell for the bash tool. Models write bash, so on Windows Git Bash is used when
+ * it is installed; otherwise commands fall back to cmd.exe.
+ */
+export function getBashShell(): string | true {
+    if (bashShell !== undefined) {
+        return bashShell;
+    }
+    bashShell = true;
+    if (IS_WINDOWS) {
+        const candidates = [
+            process.env.ProgramFiles && path.join(process.env.ProgramFiles, "Git", "bin", "bash.exe"),
+            process.env["ProgramFiles(x86)"] && path.join(process.env["ProgramFiles(x86)"], "Git", "bin", "bash.exe"),
+            process.env.LOCALAPPDATA && path.join(process.env.LOCALAPPDATA, "Programs", "Git", "bin", "bash.exe"),
+        ];
+        bashShell = candidates.find((candidate): candidate is string => !!candidate && existsSync(candidate)) ?? true;
+    }
+    return bashShell;
+}
+
+/**
+ * Stop a child process and everything it started. On Windows a shell or .cmd
+ * shim is the process we spawned, and killing it leaves the real program running,
+ * so the whole tree is ended with taskkill.
+ */
+export function killProcessTree(child: ChildProcess, signal: NodeJS.Signals = "SIGTERM"): void {
+    if (IS_WINDOWS && child.pid !== undefined) {
+        const killer = spawn("taskkill", ["/pid", String(child.pid), "/T", "/F"], {
+            stdio: "ignore",
+            windowsHide: true,
+        });
+        killer.on("error", () => child.kill(signal));
+        return;
+    }
+    child.kill(signal);
+}
+
+/**
+ * Line ending used by existing file content, so edits can keep it
+ */
+export function detectLineEnding(content: string): "\r\n" | "\n" {
+    const crlf = content.indexOf("\r\n");
+    const lf = content.indexOf("\n");
+    return crlf !== -1 && crlf === lf - 1 ? "\r\n" : "\n";
+}
+
+/**
+ * Convert every line break in `text` to `eol`
+ */
+export function withLineEnding(text: string, eol: "\r\n" | "\n"): string {
+    return text.replace(/\r?\n/g, eol);
+}
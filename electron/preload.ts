@@ -30,6 +30,14 @@ const electronAPI = {
     console.log("Calling database-demo");
     return ipcRenderer.invoke("database-demo");
   },
+  getLaunchOptions: () => {
+    console.log("Calling get-launch-options");
+    return ipcRenderer.invoke("get-launch-options");
+  },
+  getSafeMode: () => {
+    console.log("Calling get-safe-mode");
+    return ipcRenderer.invoke("get-safe-mode");
+  },
   // Directory selection functions
   selectDirectory: () => {
     console.log("Calling select-directory");
@@ -47,6 +55,50 @@ const electronAPI = {
     console.log("Calling change-working-directory");
     return ipcRenderer.invoke("change-working-directory", dirPath);
   },
+  // Export functions
+  exportSaveFile: (defaultName: string, content: string, filters: Array<{ name: string; extensions: string[] }>, filePath?: string, baseDir?: string) => {
+    console.log("Calling export-save-file");
+    return ipcRenderer.invoke("export-save-file", defaultName, content, filters, filePath, baseDir);
+  },
+  transcriptRecord: (record: Record<string, unknown>) => {
+    return ipcRenderer.invoke("transcript-record", record);
+  },
+  clipboardWriteText: (text: string) => {
+    console.log("Calling clipboard-write-text");
+    return ipcRenderer.invoke("clipboard-write-text", text);
+  },
+  clipboardReadText: () => {
+    console.log("Calling clipboard-read-text");
+    return ipcRenderer.invoke("clipboard-read-text");
+  },
+  hookRunCommand: (command: string, options: { cwd?: string; env?: Record<string, string>; input?: string }) => {
+    console.log("Calling hook-run-command");
+    return ipcRenderer.invoke("hook-run-command", command, options);
+  },
+  hookAppendFile: (filePath: string, content: string, cwd?: string) => {
+    console.log("Calling hook-append-file");
+    return ipcRenderer.invoke("hook-append-file", filePath, content, cwd);
+  },
+  imageSaveTemp: (data: string, mimeType: string) => {
+    console.log("Calling image-save-temp");
+    return ipcRenderer.invoke("image-save-temp", data, mimeType);
+  },
+  imageReadFile: (projectPath: string | null, filePath: string) => {
+    console.log("Calling image-read-file");
+    return ipcRenderer.invoke("image-read-file", projectPath, filePath);
+  },
+  snippetWriteFiles: (projectPath: string | null, files: Array<{ path: string; content: string }>, overwrite: boolean) => {
+    console.log("Calling snippet-write-files");
+    return ipcRenderer.invoke("snippet-write-files", projectPath, files, overwrite);
+  },
+  ttsSynthesize: (text: string, options: { binary?: string; model: string; rate?: number }) => {
+    console.log("Calling tts-synthesize");
+    return ipcRenderer.invoke("tts-synthesize", text, options);
+  },
+  sttTranscribe: (audio: string, settings: Record<string, unknown>) => {
+    console.log("Calling stt-transcribe");
+    return ipcRenderer.invoke("stt-transcribe", audio, settings);
+  },
   // Config file functions
   configRead: (filename: string) => {
     return ipcRenderer.invoke("config-read", filename);
@@ -54,6 +106,10 @@ const electronAPI = {
   configWrite: (filename: string, content: string) => {
     return ipcRenderer.invoke("config-write", filename, content);
   },
+  configReadProviders: () => {
+    console.log("Calling config-read-providers");
+    return ipcRenderer.invoke("config-read-providers");
+  },
   configInitDefaults: (filename: string, template: string) => {
     console.log("Calling config-init-defaults");
     return ipcRenderer.invoke("config-init-defaults", filename, template);
@@ -67,6 +123,15 @@ const electronAPI = {
     console.log("Calling project-mcp-overrides-write");
     return ipcRenderer.invoke("project-mcp-overrides-write", projectPath, content);
   },
+  // Workspace trust functions
+  workspaceTrustGet: (projectPath: string) => {
+    console.log("Calling workspace-trust-get");
+    return ipcRenderer.invoke("workspace-trust-get", projectPath);
+  },
+  workspaceTrustSet: (projectPath: string, trust: "trusted" | "restricted" | null) => {
+    console.log("Calling workspace-trust-set");
+    return ipcRenderer.invoke("workspace-trust-set", projectPath, trust);
+  },
   // Project context mode functions
   projectContextModeRead: (projectPath: string) => {
     console.log("Calling project-context-mode-read");
@@ -82,14 +147,34 @@ const electronAPI = {
     model: string;
     messages: unknown[];
     tools?: unknown[];
+    projectPath?: string;
+    options?: Record<string, number>;
   }) => {
     console.log("Calling chat-send-message");
     return ipcRenderer.invoke("chat-send-message", params);
   },
+  chatPreview: (params: {
+    provider: string;
+    model: string;
+    messages: unknown[];
+    tools?: unknown[];
+    projectPath?: string;
+    options?: Record<string, number>;
+  }) => {
+    console.log("Calling chat-preview");
+    return ipcRenderer.invoke("chat-preview", params);
+  },
   chatCancel: () => {
     console.log("Calling chat-cancel");
     return ipcRenderer.invoke("chat-cancel");
   },
+  chatWarmUp: (params: {
+    provider: string;
+    model: string;
+  }) => {
+    console.log("Calling chat-warm-up");
+    return ipcRenderer.invoke("chat-warm-up", params);
+  },
   chatGetContextLength: (params: {
     provider: string;
     model: string;
@@ -97,6 +182,22 @@ const electronAPI = {
     console.log("Calling chat-get-context-length");
     return ipcRenderer.invoke("chat-get-context-length", params);
   },
+  chatModelCapabilities: (params: {
+    provider: string;
+    model: string;
+    refresh?: boolean;
+  }) => {
+    console.log("Calling chat-model-capabilities");
+    return ipcRenderer.invoke("chat-model-capabilities", params);
+  },
+  chatComplete: (params: { provider: string; model: string; messages: Array<{ role: string; content: string }> }) => {
+    console.log("Calling chat-complete");
+    return ipcRenderer.invoke("chat-complete", params);
+  },
+  chatListModels: (params: { provider: string }) => {
+    console.log("Calling chat-list-models");
+    return ipcRenderer.invoke("chat-list-models", params);
+  },
   onChatChunk: (callback: (chunk: unknown) => void) => {
     ipcRenderer.on("chat-chunk", (_, chunk) => callback(chunk));
   },
@@ -112,14 +213,18 @@ const electronAPI = {
   },
 
   // Session storage functions
-  sessionSave: (projectPath: string, sessionId: string, messages: unknown[], sessionName?: string, isCustomName?: boolean, providerId?: string, modelId?: string) => {
+  sessionSave: (projectPath: string, sessionId: string, messages: unknown[], sessionName?: string, isCustomName?: boolean, providerId?: string, modelId?: string, settings?: { env?: Record<string, string>; systemPrompt?: string; options?: Record<string, number> }) => {
     console.log("Calling session-save");
-    return ipcRenderer.invoke("session-save", projectPath, sessionId, messages, sessionName, isCustomName, providerId, modelId);
+    return ipcRenderer.invoke("session-save", projectPath, sessionId, messages, sessionName, isCustomName, providerId, modelId, settings);
   },
   sessionLoad: (projectPath: string, sessionId: string) => {
     console.log("Calling session-load");
     return ipcRenderer.invoke("session-load", projectPath, sessionId);
   },
+  toolEnvSet: (projectPath: string, env: Record<string, string>) => {
+    console.log("Calling tool-env-set");
+    return ipcRenderer.invoke("tool-env-set", projectPath, env);
+  },
   sessionList: (projectPath: string) => {
     console.log("Calling session-list");
     return ipcRenderer.invoke("session-list", projectPath);
@@ -160,8 +265,24 @@ const electronAPI = {
     console.log("Calling preferences-set");
     return ipcRenderer.invoke("preferences-set", key, value);
   },
+  inputHistoryRead: () => {
+    console.log("Calling input-history-read");
+    return ipcRenderer.invoke("input-history-read");
+  },
+  inputHistoryAdd: (entry: string) => {
+    console.log("Calling input-history-add");
+    return ipcRenderer.invoke("input-history-add", entry);
+  },
 
   // Prompt management functions
+  templatesList: () => {
+    console.log("Calling templates-list");
+    return ipcRenderer.invoke("templates-list");
+  },
+  templatesRead: (name: string) => {
+    console.log("Calling templates-read");
+    return ipcRenderer.invoke("templates-read", name);
+  },
   promptsList: () => {
     return ipcRenderer.invoke("prompts-list");
   },
@@ -174,6 +295,9 @@ const electronAPI = {
   promptsDelete: (name: string) => {
     return ipcRenderer.invoke("prompts-delete", name);
   },
+  promptsOpen: (name: string) => {
+    return ipcRenderer.invoke("prompts-open", name);
+  },
 
   // MCP functions
   mcpStartServer: (name: string, config: {
@@ -196,9 +320,9 @@ const electronAPI = {
     console.log("Calling mcp-restart-server");
     return ipcRenderer.invoke("mcp-restart-server", name, config);
   },
-  mcpCallTool: (serverName: string, toolName: string, args: Record<string, unknown>) => {
+  mcpCallTool: (serverName: string, toolName: string, args: Record<string, unknown>, toolCallId?: string) => {
     console.log("Calling mcp-call-tool");
-    return ipcRenderer.invoke("mcp-call-tool", serverName, toolName, args);
+    return ipcRenderer.invoke("mcp-call-tool", serverName, toolName, args, toolCallId);
   },
   mcpGetServerStatus: (name: string) => {
     console.log("Calling mcp-get-server-status");
@@ -227,6 +351,10 @@ const electronAPI = {
     console.log("Calling internal-tool-read");
     return ipcRenderer.invoke("internal-tool-read", projectPath, params);
   },
+  applyReadFile: (projectPath: string, filePath: string) => {
+    console.log("Calling apply-read-file");
+    return ipcRenderer.invoke("apply-read-file", projectPath, filePath);
+  },
   internalToolWrite: (projectPath: string, params: {
     file_path: string;
     content: string;
@@ -266,9 +394,109 @@ const electronAPI = {
     command: string;
     description?: string;
     timeout?: number;
-  }) => {
+  }, toolCallId?: string) => {
     console.log("Calling internal-tool-bash");
-    return ipcRenderer.invoke("internal-tool-bash", projectPath, params);
+    return ipcRenderer.invoke("internal-tool-bash", projectPath, params, toolCallId);
+  },
+  toolCancel: (toolCallId: string) => {
+    console.log("Calling tool-cancel");
+    return ipcRenderer.invoke("tool-cancel", toolCallId);
+  },
+  onToolInputRequest: (callback: (request: { requestId: string; toolCallId: string; question: string; choices?: string[] }) => void) => {
+    const listener = (_: unknown, request: { requestId: string; toolCallId: string; question: string; choices?: string[] }) => callback(request);
+    ipcRenderer.on("tool-input-request", listener);
+    return () => {
+      ipcRenderer.removeListener("tool-input-request", listener);
+    };
+  },
+  toolInputRespond: (requestId: string, answer: string | null) => {
+    console.log("Calling tool-input-respond");
+    return ipcRenderer.invoke("tool-input-respond", requestId, answer);
+  },
+  observerStart: (port?: number) => {
+    console.log("Calling observer-start");
+    return ipcRenderer.invoke("observer-start", port);
+  },
+  observerStop: () => {
+    console.log("Calling observer-stop");
+    return ipcRenderer.invoke("observer-stop");
+  },
+  observerStatus: () => {
+    console.log("Calling observer-status");
+    return ipcRenderer.invoke("observer-status");
+  },
+  // Called on every change to the conversation, so it isn't logged
+  observerPublish: (messages: unknown[]) => {
+    return ipcRenderer.invoke("observer-publish", messages);
+  },
+  ragStatus: (projectPath: string) => {
+    console.log("Calling rag-status");
+    return ipcRenderer.invoke("rag-status", projectPath);
+  },
+  ragIndex: (projectPath: string, targetPath: string) => {
+    console.log("Calling rag-index");
+    return ipcRenderer.invoke("rag-index", projectPath, targetPath);
+  },
+  ragClear: (projectPath: string) => {
+    console.log("Calling rag-clear");
+    return ipcRenderer.invoke("rag-clear", projectPath);
+  },
+  watchStart: (projectPath: string, params: {
+    path: string;
+    pattern?: string;
+    react?: boolean;
+    instructions?: string;
+  }) => {
+    console.log("Calling watch-start");
+    return ipcRenderer.invoke("watch-start", projectPath, params);
+  },
+  watchStop: (watchId: string) => {
+    console.log("Calling watch-stop");
+    return ipcRenderer.invoke("watch-stop", watchId);
+  },
+  watchList: () => {
+    console.log("Calling watch-list");
+    return ipcRenderer.invoke("watch-list");
+  },
+  logsRecent: () => {
+    return ipcRenderer.invoke("logs-recent");
+  },
+  logsFollow: (follow: boolean) => {
+    return ipcRenderer.invoke("logs-follow", follow);
+  },
+  onLogEntry: (callback: (entry: unknown) => void) => {
+    const listener = (_: unknown, entry: unknown) => callback(entry);
+    ipcRenderer.on("log-entry", listener);
+    return () => {
+      ipcRenderer.removeListener("log-entry", listener);
+    };
+  },
+  engineEventsRecent: () => {
+    return ipcRenderer.invoke("engine-events-recent");
+  },
+  engineEventRecord: (params: { kind: string; scope: string; message: string; fields?: Record<string, unknown> }) => {
+    return ipcRenderer.invoke("engine-event-record", params);
+  },
+  onEngineEvent: (callback: (entry: unknown) => void) => {
+    const listener = (_: unknown, entry: unknown) => callback(entry);
+    ipcRenderer.on("engine-event", listener);
+    return () => {
+      ipcRenderer.removeListener("engine-event", listener);
+    };
+  },
+  onWatchEvent: (callback: (event: unknown) => void) => {
+    const listener = (_: unknown, event: unknown) => callback(event);
+    ipcRenderer.on("watch-event", listener);
+    return () => {
+      ipcRenderer.removeListener("watch-event", listener);
+    };
+  },
+  onToolOutput: (callback: (output: { toolCallId: string; stream: "stdout" | "stderr"; chunk: string }) => void) => {
+    const listener = (_: unknown, output: { toolCallId: string; stream: "stdout" | "stderr"; chunk: string }) => callback(output);
+    ipcRenderer.on("tool-output", listener);
+    return () => {
+      ipcRenderer.removeListener("tool-output", listener);
+    };
   },
   internalToolLs: (projectPath: string, params: {
     path?: string;
@@ -298,6 +526,21 @@ const electronAPI = {
     console.log("Calling internal-tool-mkdir");
     return ipcRenderer.invoke("internal-tool-mkdir", projectPath, params);
   },
+  internalToolWebSearch: (params: {
+    query: string;
+    max_results?: number;
+  }, toolCallId?: string) => {
+    console.log("Calling internal-tool-web-search");
+    return ipcRenderer.invoke("internal-tool-web-search", params, toolCallId);
+  },
+  internalToolFetchUrl: (params: {
+    url: string;
+    max_length?: number;
+    timeout?: number;
+  }, toolCallId?: string) => {
+    console.log("Calling internal-tool-fetch-url");
+    return ipcRenderer.invoke("internal-tool-fetch-url", params, toolCallId);
+  },
 };
 
 contextBridge.exposeInMainWorld("electronAPI", electronAPI);
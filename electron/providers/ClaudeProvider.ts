@@ -1,4 +1,19 @@
-import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall } from './types';
+import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall, ProviderRequest } from './types';
+import { fetchWithRetry } from './retry';
+
+const ANTHROPIC_VERSION = '2023-06-01';
+const DEFAULT_CONTEXT_LENGTH = 200000;
+// The Messages API requires max_tokens; used when no num_predict is set
+const DEFAULT_MAX_TOKENS = 8192;
+
+// stop_reason values, mapped to the done reasons the other providers report
+const STOP_REASONS: Record<string, string> = {
+    end_turn: 'stop',
+    stop_sequence: 'stop',
+    tool_use: 'stop',
+    max_tokens: 'length',
+    refusal: 'refusal',
+};
 
 export class ClaudeProvider extends ChatProvider {
     getCapabilities(): ProviderCapabilities {
@@ -10,8 +25,41 @@ export class ClaudeProvider extends ChatProvider {
         };
     }
 
+    private get headers(): Record<string, string> {
+        return {
+            "Content-Type": "application/json",
+            "x-api-key": this.config.apiKey ?? '',
+            "anthropic-version": ANTHROPIC_VERSION,
+        };
+    }
+
     async getModels(): Promise<ModelConfig[]> {
-        return this.config.models;
+        if (!this.config.apiKey) {
+            return this.config.models;
+        }
+
+        // Without the API (offline, a bad key) the configured models are still usable
+        try {
+            const response = await fetch(`${this.config.baseURL}/models?limit=100`, { headers: this.headers });
+            if (!response.ok) {
+                throw new Error(`Anthropic API error: ${await this.errorMessage(response)}`);
+            }
+
+            const data = await response.json() as { data?: Array<{ id: string; display_name?: string }> };
+            return (data.data || []).map(model => {
+                const configured = this.config.models.find(m => m.id === model.id);
+                return configured ?? {
+                    id: model.id,
+                    name: model.display_name || model.id,
+                    type: 'chat',
+                    contextLength: DEFAULT_CONTEXT_LENGTH,
+                    supportsTools: true,
+                };
+            });
+        } catch (error) {
+            console.error('Failed to list Claude models, using the configured ones:', error);
+            return this.config.models;
+        }
     }
 
     async getContextLength(model: string): Promise<number> {
@@ -19,33 +67,30 @@ export class ClaudeProvider extends ChatProvider {
         if (modelConfig?.contextLength) {
             return modelConfig.contextLength;
         }
-        // Default context lengths for known Claude models
-        if (model.includes('claude-3-5-sonnet') || model.includes('claude-3-opus')) return 200000;
-        if (model.includes('claude-3-haiku')) return 200000;
-        return 200000; // fallback
+        // Every current Claude model has a 200k window
+        return DEFAULT_CONTEXT_LENGTH;
     }
 
-    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
-        if (!this.config.apiKey) {
-            yield { type: 'error', error: 'Anthropic API key not configured' };
-            return;
-        }
-
+    buildRequest(params: StreamChatParams): ProviderRequest {
         const url = `${this.config.baseURL}/messages`;
 
-        // Extract system message
-        const systemMessage = params.messages.find(m => m.role === 'system');
+        // Anthropic takes the system prompt as a separate field
+        const system = params.messages
+            .filter(m => m.role === 'system' && m.content)
+            .map(m => m.content)
+            .join('\n\n');
         const messages = this.convertMessagesToClaudeFormat(params.messages);
+        const modelConfig = this.config.models.find(m => m.id === params.model);
 
         const requestBody: Record<string, unknown> = {
             model: params.model,
             messages,
-            max_tokens: 8192,
             stream: true,
+            ...this.buildSampling(modelConfig, params),
         };
 
-        if (systemMessage?.content) {
-            requestBody.system = systemMessage.content;
+        if (system) {
+            requestBody.system = system;
         }
 
         if (params.tools && params.tools.length > 0) {
@@ -56,21 +101,27 @@ export class ClaudeProvider extends ChatProvider {
             }));
         }
 
+        return { url, body: requestBody };
+    }
+
+    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
+        if (!this.config.apiKey) {
+            yield { type: 'error', error: 'Anthropic API key not configured' };
+            return;
+        }
+
+        const { url, body: requestBody } = this.buildRequest(params);
+
         try {
-            const response = await fetch(url, {
+            const response = await fetchWithRetry(url, {
                 method: "POST",
-                headers: {
-                    "Content-Type": "application/json",
-                    "x-api-key": this.config.apiKey,
-                    "anthropic-version": "2023-06-01",
-                },
+                headers: this.headers,
                 body: JSON.stringify(requestBody),
                 signal: params.signal,
-            });
+            }, params.retry);
 
             if (!response.ok) {
-                const errorText = await response.text();
-                yield { type: 'error', error: `Anthropic API error (${response.status}): ${errorText}` };
+                yield { type: 'error', error: `Anthropic API error (${response.status}): ${await this.errorMessage(response)}` };
                 return;
             }
 
@@ -83,6 +134,8 @@ export class ClaudeProvider extends ChatProvider {
             const decoder = new TextDecoder();
             let buffer = '';
             let currentToolCall: Partial<ToolCall> | null = null;
+            let promptTokens = 0;
+            let stopReason: string | undefined;
 
             while (true) {
                 const { done, value } = await reader.read();
@@ -117,6 +170,8 @@ export class ClaudeProvider extends ChatProvider {
                             case 'content_block_delta':
                                 if (data.delta?.type === 'text_delta') {
                                     yield { type: 'content', content: data.delta.text };
+                                } else if (data.delta?.type === 'thinking_delta') {
+                                    yield { type: 'thinking', thinking: data.delta.thinking };
                                 } else if (data.delta?.type === 'input_json_delta') {
                                     if (currentToolCall?.function) {
                                         currentToolCall.function.arguments += data.delta.partial_json;
@@ -126,6 +181,10 @@ export class ClaudeProvider extends ChatProvider {
 
                             case 'content_block_stop':
                                 if (currentToolCall && currentToolCall.id && currentToolCall.function) {
+                                    // Tools without parameters stream no input at all
+                                    if (!currentToolCall.function.arguments.trim()) {
+                                        currentToolCall.function.arguments = '{}';
+                                    }
                                     const toolCall = currentToolCall as ToolCall;
                                     yield { type: 'tool_call', toolCall };
 
@@ -141,35 +200,38 @@ export class ClaudeProvider extends ChatProvider {
                                 }
                                 break;
 
-                            case 'message_delta':
-                                if (data.usage) {
-                                    yield {
-                                        type: 'usage',
-                                        usage: {
-                                            prompt_tokens: 0,
-                                            completion_tokens: data.usage.output_tokens || 0,
-                                            total_tokens: data.usage.output_tokens || 0,
-                                        }
-                                    };
-                                }
+                            case 'message_start':
+                                promptTokens = (data.message?.usage?.input_tokens || 0)
+                                    + (data.message?.usage?.cache_read_input_tokens || 0)
+                                    + (data.message?.usage?.cache_creation_input_tokens || 0);
                                 break;
 
-                            case 'message_start':
-                                if (data.message?.usage) {
+                            case 'message_delta':
+                                if (data.delta?.stop_reason) {
+                                    stopReason = STOP_REASONS[data.delta.stop_reason] ?? data.delta.stop_reason;
+                                }
+                                // output_tokens is the running total for the message
+                                if (data.usage) {
+                                    const completionTokens = data.usage.output_tokens || 0;
                                     yield {
                                         type: 'usage',
                                         usage: {
-                                            prompt_tokens: data.message.usage.input_tokens || 0,
-                                            completion_tokens: 0,
-                                            total_tokens: data.message.usage.input_tokens || 0,
+                                            prompt_tokens: promptTokens,
+                                            completion_tokens: completionTokens,
+                                            total_tokens: promptTokens + completionTokens,
                                         }
                                     };
                                 }
                                 break;
 
                             case 'message_stop':
-                                yield { type: 'done' };
-                                break;
+                                yield { type: 'done', reason: stopReason };
+                                return;
+
+                            case 'error':
+                                // Errors after the stream started, e.g. overloaded_error
+                                yield { type: 'error', error: `Anthropic API error: ${data.error?.message || data.error?.type || 'Unknown error'}` };
+                                return;
                         }
                     } catch (parseError) {
                         console.error("Failed to parse Claude SSE chunk:", parseError);
@@ -186,63 +248,129 @@ export class ClaudeProvider extends ChatProvider {
         }
     }
 
+    /**
+     * Generation options that the Messages API also has. num_predict becomes
+     * max_tokens, which is required, so "no limit" (-1) falls back to the default.
+     */
+    private buildSampling(modelConfig: ModelConfig | undefined, params: StreamChatParams): Record<string, unknown> {
+        const options = { ...params.defaultOptions, ...modelConfig?.options, ...params.options };
+        const sampling: Record<string, unknown> = {
+            max_tokens: this.maxOutputTokens(params.model, params) ?? DEFAULT_MAX_TOKENS,
+        };
+        if (options.temperature !== undefined) {
+            sampling.temperature = options.temperature;
+        }
+        if (options.top_p !== undefined) {
+            sampling.top_p = options.top_p;
+        }
+        if (options.top_k !== undefined) {
+            sampling.top_k = options.top_k;
+        }
+        // The API rejects stop sequences that are only whitespace
+        const stop = this.stopSequences(params.model, params).filter(sequence => sequence.trim());
+        if (stop.length > 0) {
+            sampling.stop_sequences = stop;
+        }
+        return sampling;
+    }
+
+    // The message from an error response body, e.g. {"type":"error","error":{"message":"..."}}
+    private async errorMessage(response: Response): Promise<string> {
+        const text = await response.text();
+        try {
+            const data = JSON.parse(text);
+            return data.error?.message || text;
+        } catch {
+            return text || response.statusText;
+        }
+    }
+
+    /**
+     * Convert chat history to Messages API turns: assistant tool calls become
+     * tool_use blocks, and the results that follow become tool_result blocks in
+     * one user turn. Roles have to alternate, so consecutive messages that map to
+     * the same role are merged.
+     */
     private convertMessagesToClaudeFormat(messages: ChatMessage[]): Record<string, unknown>[] {
-        const claudeMessages: Record<string, unknown>[] = [];
+        const claudeMessages: { role: 'user' | 'assistant'; content: Record<string, unknown>[] }[] = [];
+
+        const append = (role: 'user' | 'assistant', blocks: Record<string, unknown>[]) => {
+            if (blocks.length === 0) {
+                return;
+            }
+            const last = claudeMessages[claudeMessages.length - 1];
+            if (last && last.role === role) {
+                last.content.push(...blocks);
+            } else {
+                claudeMessages.push({ role, content: blocks });
+            }
+        };
 
         for (const msg of messages) {
             // Skip system messages (handled separately)
             if (msg.role === 'system') continue;
 
-            const content: (string | Record<string, unknown>)[] = [];
+            if (msg.role === 'tool') {
+                if (msg.tool_call_id) {
+                    append('user', [{
+                        type: 'tool_result',
+                        tool_use_id: msg.tool_call_id,
+                        content: msg.content,
+                    }]);
+                } else if (msg.content) {
+                    append('user', [{ type: 'text', text: msg.content }]);
+                }
+                continue;
+            }
 
-            // Add text content
+            const blocks: Record<string, unknown>[] = [];
+            if (msg.role === 'user' && msg.images) {
+                for (const image of msg.images) {
+                    blocks.push({
+                        type: 'image',
+                        source: { type: 'base64', media_type: image.mimeType, data: image.data },
+                    });
+                }
+            }
             if (msg.content) {
-                content.push({
-                    type: 'text',
-                    text: msg.content,
-                });
+                blocks.push({ type: 'text', text: msg.content });
             }
 
             // Handle tool calls (assistant requesting tool use)
-            if (msg.tool_calls && msg.tool_calls.length > 0) {
+            if (msg.role === 'assistant' && msg.tool_calls) {
                 for (const toolCall of msg.tool_calls) {
-                    content.push({
+                    blocks.push({
                         type: 'tool_use',
                         id: toolCall.id,
                         name: toolCall.function.name,
-                        input: typeof toolCall.function.arguments === 'string'
-                            ? JSON.parse(toolCall.function.arguments)
-                            : toolCall.function.arguments,
+                        input: this.parseToolInput(toolCall.function.arguments),
                     });
                 }
             }
 
-            // Handle tool results
-            if (msg.role === 'tool' && msg.tool_call_id) {
-                claudeMessages.push({
-                    role: 'user',
-                    content: [{
-                        type: 'tool_result',
-                        tool_use_id: msg.tool_call_id,
-                        content: msg.content,
-                    }]
-                });
-                continue;
-            }
+            append(msg.role, blocks);
+        }
 
-            if (content.length > 0) {
-                // Collapse single text content to string
-                const finalContent = content.length === 1 && typeof content[0] === 'object' && 'type' in content[0] && content[0].type === 'text'
-                    ? (content[0] as { text: string }).text
-                    : content;
+        // Collapse single text content to string
+        return claudeMessages.map(({ role, content }) => ({
+            role,
+            content: content.length === 1 && content[0].type === 'text' ? content[0].text : content,
+        }));
+    }
 
-                claudeMessages.push({
-                    role: msg.role,
-                    content: finalContent,
-                });
-            }
+    // tool_use input must be an object; arguments that aren't valid JSON are sent as-is under "input"
+    private parseToolInput(args: string | Record<string, unknown>): Record<string, unknown> {
+        if (typeof args !== 'string') {
+            return args;
+        }
+        if (!args.trim()) {
+            return {};
+        }
+        try {
+            const parsed = JSON.parse(args);
+            return parsed && typeof parsed === 'object' && !Array.isArray(parsed) ? parsed : { input: parsed };
+        } catch {
+            return { input: args };
         }
-
-        return claudeMessages;
     }
 }
@@ -1,4 +1,5 @@
-import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall } from './types';
+import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall, ProviderRequest } from './types';
+import { fetchWithRetry } from './retry';
 
 export class GeminiProvider extends ChatProvider {
     getCapabilities(): ProviderCapabilities {
@@ -26,22 +27,28 @@ export class GeminiProvider extends ChatProvider {
         return 32768; // fallback
     }
 
-    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
-        if (!this.config.apiKey) {
-            yield { type: 'error', error: 'Gemini API key not configured' };
-            return;
-        }
-
+    buildRequest(params: StreamChatParams): ProviderRequest {
         const url = `${this.config.baseURL}/models/${params.model}:streamGenerateContent?key=${this.config.apiKey}&alt=sse`;
 
         const contents = this.convertMessagesToGeminiFormat(params.messages);
         const systemInstruction = this.extractSystemInstruction(params.messages);
 
+        const generationConfig: Record<string, unknown> = {
+            temperature: 0.7,
+        };
+        const maxTokens = this.maxOutputTokens(params.model, params);
+        if (maxTokens) {
+            generationConfig.maxOutputTokens = maxTokens;
+        }
+        // Gemini takes up to five
+        const stop = this.stopSequences(params.model, params).slice(0, 5);
+        if (stop.length > 0) {
+            generationConfig.stopSequences = stop;
+        }
+
         const requestBody: Record<string, unknown> = {
             contents,
-            generationConfig: {
-                temperature: 0.7,
-            },
+            generationConfig,
         };
 
         if (systemInstruction) {
@@ -58,13 +65,24 @@ export class GeminiProvider extends ChatProvider {
             }];
         }
 
+        return { url, body: requestBody };
+    }
+
+    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
+        if (!this.config.apiKey) {
+            yield { type: 'error', error: 'Gemini API key not configured' };
+            return;
+        }
+
+        const { url, body: requestBody } = this.buildRequest(params);
+
         try {
-            const response = await fetch(url, {
+            const response = await fetchWithRetry(url, {
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
                 body: JSON.stringify(requestBody),
                 signal: params.signal,
-            });
+            }, params.retry);
 
             if (!response.ok) {
                 const errorText = await response.text();
@@ -180,6 +198,13 @@ export class GeminiProvider extends ChatProvider {
                 parts.push({ text: msg.content });
             }
 
+            // Add attached images
+            if (msg.role === 'user' && msg.images) {
+                for (const image of msg.images) {
+                    parts.push({ inlineData: { mimeType: image.mimeType, data: image.data } });
+                }
+            }
+
             // Handle tool calls (assistant calling functions)
             if (msg.tool_calls && msg.tool_calls.length > 0) {
                 for (const toolCall of msg.tool_calls) {
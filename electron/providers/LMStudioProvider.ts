@@ -1,6 +1,11 @@
-import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall } from './types';
+import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall, ProviderRequest } from './types';
+import { fetchWithRetry } from './retry';
 
 export class LMStudioProvider extends ChatProvider {
+    // Used in error messages; subclasses for other OpenAI-compatible servers override it
+    protected apiName = 'LM Studio';
+    protected maxStopSequences = Infinity; // How many stop sequences the server takes
+
     getCapabilities(): ProviderCapabilities {
         return {
             supportsTools: true,
@@ -11,7 +16,25 @@ export class LMStudioProvider extends ChatProvider {
     }
 
     async getModels(): Promise<ModelConfig[]> {
-        return this.config.models;
+        const response = await fetch(`${this.config.baseURL}/api/v0/models`);
+        if (!response.ok) {
+            throw new Error(`${this.apiName} API error: ${response.statusText}`);
+        }
+
+        const data = await response.json() as {
+            data?: Array<{ id: string; type?: string; max_context_length?: number; arch?: string; quantization?: string }>;
+        };
+        return (data.data || []).map(model => {
+            const configured = this.config.models.find(m => m.id === model.id);
+            const details = { quantization: model.quantization || undefined, family: model.arch || undefined };
+            return configured ? { ...configured, details } : {
+                id: model.id,
+                name: model.id,
+                type: model.type === 'embeddings' ? 'embedding' : 'chat',
+                contextLength: model.max_context_length || 0,
+                details,
+            };
+        });
     }
 
     async getContextLength(model: string): Promise<number> {
@@ -20,7 +43,7 @@ export class LMStudioProvider extends ChatProvider {
             const response = await fetch(url);
 
             if (!response.ok) {
-                throw new Error(`LM Studio API error: ${response.statusText}`);
+                throw new Error(`${this.apiName} API error: ${response.statusText}`);
             }
 
             const data = await response.json();
@@ -40,8 +63,17 @@ export class LMStudioProvider extends ChatProvider {
         }
     }
 
-    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
-        const url = `${this.config.baseURL}/v1/chat/completions`;
+    protected getChatCompletionsURL(): string {
+        return `${this.config.baseURL}/v1/chat/completions`;
+    }
+
+    // LM Studio only takes a JSON schema, so JSON output mode relies on the instruction alone
+    protected jsonResponseFormat(): Record<string, unknown> | null {
+        return null;
+    }
+
+    buildRequest(params: StreamChatParams): ProviderRequest {
+        const url = this.getChatCompletionsURL();
 
         // Clean messages and remove duplicates
         const cleanedMessages = this.cleanMessagesForLMStudio(params.messages);
@@ -55,6 +87,20 @@ export class LMStudioProvider extends ChatProvider {
             },
         };
 
+        const maxTokens = this.maxOutputTokens(params.model, params);
+        if (maxTokens) {
+            requestBody.max_tokens = maxTokens;
+        }
+        const stop = this.stopSequences(params.model, params).slice(0, this.maxStopSequences);
+        if (stop.length > 0) {
+            requestBody.stop = stop;
+        }
+
+        const responseFormat = params.json ? this.jsonResponseFormat() : null;
+        if (responseFormat) {
+            requestBody.response_format = responseFormat;
+        }
+
         if (params.tools && params.tools.length > 0) {
             requestBody.tools = params.tools;
 
@@ -65,6 +111,12 @@ export class LMStudioProvider extends ChatProvider {
             }
         }
 
+        return { url, body: requestBody };
+    }
+
+    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
+        const { url, body: requestBody } = this.buildRequest(params);
+
         const headers: Record<string, string> = {
             "Content-Type": "application/json",
         };
@@ -73,12 +125,12 @@ export class LMStudioProvider extends ChatProvider {
             headers.Authorization = `Bearer ${this.config.apiKey}`;
         }
 
-        const response = await fetch(url, {
+        const response = await fetchWithRetry(url, {
             method: "POST",
             headers,
             body: JSON.stringify(requestBody),
             signal: params.signal,
-        });
+        }, params.retry);
 
         if (!response.ok) {
             let errorDetails = response.statusText;
@@ -90,7 +142,7 @@ export class LMStudioProvider extends ChatProvider {
             } catch (e) {
                 // Ignore error reading body
             }
-            yield { type: 'error', error: `LM Studio API error: ${errorDetails}` };
+            yield { type: 'error', error: `${this.apiName} API error: ${errorDetails}` };
             return;
         }
 
@@ -131,6 +183,12 @@ export class LMStudioProvider extends ChatProvider {
                         const parsed = JSON.parse(data);
                         const delta = parsed.choices?.[0]?.delta;
 
+                        // Reasoning models report thinking separately when the server parses it
+                        const reasoning = delta?.reasoning_content ?? delta?.reasoning;
+                        if (reasoning) {
+                            yield { type: 'thinking', thinking: reasoning };
+                        }
+
                         if (delta?.content) {
                             yield { type: 'content', content: delta.content };
                         }
@@ -259,6 +317,17 @@ export class LMStudioProvider extends ChatProvider {
                 msg.content = m.content || "";
             }
 
+            // Images go alongside the text as content parts, as data URLs
+            if (m.role === "user" && m.images && m.images.length > 0) {
+                msg.content = [
+                    ...(m.content ? [{ type: "text", text: m.content }] : []),
+                    ...m.images.map((image) => ({
+                        type: "image_url",
+                        image_url: { url: `data:${image.mimeType};base64,${image.data}` },
+                    })),
+                ];
+            }
+
             // Include other fields as needed
             if (m.tool_calls) {
                 msg.tool_calls = m.tool_calls;
@@ -1,4 +1,9 @@
-import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall } from './types';
+import { ChatProvider, ChatChunk, StreamChatParams, ProviderCapabilities, ModelConfig, ProviderConfig, ChatMessage, ToolCall, GenerationOptions, DetectedCapabilities, ModelDetails, ProviderRequest } from './types';
+import { renderPrompt } from './promptFormat';
+import { fetchWithRetry } from './retry';
+
+// Ollama treats an untagged model name as name:latest
+const withTag = (name: string) => name.includes(":") ? name : `${name}:latest`;
 
 export class OllamaProvider extends ChatProvider {
     getCapabilities(): ProviderCapabilities {
@@ -11,31 +16,44 @@ export class OllamaProvider extends ChatProvider {
     }
 
     async getModels(): Promise<ModelConfig[]> {
-        return this.config.models;
+        const response = await fetch(`${this.config.baseURL}/api/tags`);
+        if (!response.ok) {
+            throw new Error(`Ollama API error: ${await this.readErrorMessage(response)}`);
+        }
+
+        const data = await response.json() as {
+            models?: Array<{
+                name: string;
+                size?: number;
+                details?: { parameter_size?: string; quantization_level?: string; family?: string };
+            }>;
+        };
+        return (data.models || []).map(tag => {
+            const configured = this.config.models.find(m => withTag(m.id) === withTag(tag.name));
+            const details: ModelDetails = {
+                size: tag.size,
+                parameterSize: tag.details?.parameter_size || undefined,
+                quantization: tag.details?.quantization_level || undefined,
+                family: tag.details?.family || undefined,
+            };
+            return configured ? { ...configured, details } : {
+                id: tag.name,
+                name: tag.name,
+                type: 'chat',
+                contextLength: 0,
+                details,
+            };
+        });
     }
 
     async getContextLength(model: string): Promise<number> {
         try {
-            const url = `${this.config.baseURL}/api/show`;
-            const response = await fetch(url, {
-                method: "POST",
-                headers: { "Content-Type": "application/json" },
-                body: JSON.stringify({ model }),
-            });
-
-            if (!response.ok) {
-                throw new Error(`Ollama API error: ${response.statusText}`);
-            }
-
-            const data = await response.json();
+            const data = await this.showModel(model);
 
             // Try to find context_length in model_info
-            if (data.model_info) {
-                for (const key in data.model_info) {
-                    if (key.endsWith('.context_length')) {
-                        return data.model_info[key];
-                    }
-                }
+            const contextLength = this.modelInfoContextLength(data);
+            if (contextLength) {
+                return contextLength;
             }
 
             // Fallback to config value
@@ -50,7 +68,62 @@ export class OllamaProvider extends ChatProvider {
         }
     }
 
-    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
+    // Newer Ollama servers list capabilities ("tools", "vision", "thinking");
+    // older servers only show them in the template and projector info
+    async detectCapabilities(model: string, signal?: AbortSignal): Promise<DetectedCapabilities> {
+        const data = await this.showModel(model, signal);
+        const contextLength = this.modelInfoContextLength(data);
+        if (Array.isArray(data.capabilities)) {
+            return {
+                tools: data.capabilities.includes("tools"),
+                thinking: data.capabilities.includes("thinking"),
+                images: data.capabilities.includes("vision"),
+                contextLength,
+            };
+        }
+        return {
+            tools: typeof data.template === "string" ? data.template.includes(".Tools") : undefined,
+            images: data.projector_info ? true : undefined,
+            contextLength,
+        };
+    }
+
+    private async showModel(model: string, signal?: AbortSignal): Promise<any> {
+        const response = await fetch(`${this.config.baseURL}/api/show`, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ model }),
+            signal,
+        });
+
+        if (!response.ok) {
+            throw new Error(`Ollama API error: ${response.statusText}`);
+        }
+
+        return response.json();
+    }
+
+    private modelInfoContextLength(data: any): number | undefined {
+        for (const key in data?.model_info ?? {}) {
+            if (key.endsWith('.context_length') && typeof data.model_info[key] === "number") {
+                return data.model_info[key];
+            }
+        }
+        return undefined;
+    }
+
+    // Models that behave poorly under their chat template go through /api/generate
+    private generateModel(model: string): ModelConfig | undefined {
+        const modelConfig = this.config.models.find(m => m.id === model);
+        return modelConfig && (modelConfig.api === 'generate' || modelConfig.template) ? modelConfig : undefined;
+    }
+
+    buildRequest(params: StreamChatParams): ProviderRequest {
+        const generateModel = this.generateModel(params.model);
+        if (generateModel) {
+            return this.buildGenerateRequest(params, generateModel);
+        }
+
         const url = `${this.config.baseURL}/api/chat`;
 
         // Build tool call map for tool result messages
@@ -69,87 +142,327 @@ export class OllamaProvider extends ChatProvider {
             requestBody.tools = params.tools;
         }
 
-        const response = await fetch(url, {
+        // Constrained to JSON, a model can't answer with tool calls; the
+        // instruction in the system prompt still asks for JSON
+        if (params.json && !requestBody.tools) {
+            requestBody.format = 'json';
+        }
+
+        const options = this.buildOptions(params);
+        if (options) {
+            requestBody.options = options;
+        }
+
+        // Ask Ollama to separate reasoning into message.thinking
+        if (params.thinking !== false && this.getThinkingFormat(params.model) === 'native') {
+            requestBody.think = true;
+        }
+
+        return { url, body: requestBody };
+    }
+
+    async* streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk> {
+        const generateModel = this.generateModel(params.model);
+        if (generateModel) {
+            yield* this.streamGenerate(params, generateModel);
+            return;
+        }
+
+        const { url, body: requestBody } = this.buildRequest(params);
+
+        // Cold models can take a long time to produce the first token
+        if (!(await this.isModelLoaded(params.model))) {
+            yield { type: 'status', status: 'loading_model', message: `Loading ${params.model} into memory…` };
+        }
+
+        const response = await fetchWithRetry(url, {
             method: "POST",
             headers: { "Content-Type": "application/json" },
             body: JSON.stringify(requestBody),
             signal: params.signal,
-        });
+        }, params.retry);
+
+        if (!response.ok) {
+            yield { type: 'error', error: await this.readErrorMessage(response) };
+            return;
+        }
+
+        try {
+            for await (const data of this.readJsonLines(response)) {
+                // Ollama reports failures mid-stream as {"error": "..."}
+                if (data.error) {
+                    yield { type: 'error', error: `Ollama error: ${data.error}` };
+                    return;
+                }
+
+                if (data.message?.thinking) {
+                    yield { type: 'thinking', thinking: data.message.thinking };
+                }
+
+                if (data.message?.content) {
+                    yield { type: 'content', content: data.message.content };
+                }
+
+                if (data.message?.tool_calls) {
+                    for (const toolCallData of data.message.tool_calls) {
+                        const toolCall: ToolCall = {
+                            id: toolCallData.id || this.createToolCallId(),
+                            type: "function",
+                            function: {
+                                name: toolCallData.function.name,
+                                arguments: typeof toolCallData.function.arguments === "string"
+                                    ? toolCallData.function.arguments
+                                    : JSON.stringify(toolCallData.function.arguments),
+                            },
+                        };
+
+                        yield { type: 'tool_call', toolCall };
+
+                        // Execute tool immediately if callback provided
+                        if (params.onToolCall) {
+                            try {
+                                await params.onToolCall(toolCall);
+                            } catch (error) {
+                                console.error('Tool execution error:', error);
+                            }
+                        }
+                    }
+                }
+
+                if (data.done) {
+                    const usage = this.usageChunk(data);
+                    if (usage) {
+                        yield usage;
+                    }
+                    // done_reason is "stop", "length" or "load" (model loaded with no prompt)
+                    yield { type: 'done', reason: data.done_reason };
+                }
+            }
+        } catch (error: unknown) {
+            yield this.streamErrorChunk(error);
+        }
+    }
+
+    // Completion-style request to /api/generate
+    private buildGenerateRequest(params: StreamChatParams, modelConfig: ModelConfig): ProviderRequest {
+        const url = `${this.config.baseURL}/api/generate`;
+
+        const requestBody: Record<string, unknown> = {
+            model: params.model,
+            stream: true,
+        };
+
+        if (modelConfig.raw) {
+            // We are the template: the whole conversation goes into the prompt
+            requestBody.raw = true;
+            requestBody.prompt = renderPrompt(params.messages, modelConfig.promptFormat);
+        } else {
+            const system = params.messages.filter(m => m.role === 'system').map(m => m.content).join('\n\n');
+            if (system) {
+                requestBody.system = system;
+            }
+            requestBody.prompt = renderPrompt(params.messages.filter(m => m.role !== 'system'), modelConfig.promptFormat);
+            if (modelConfig.template) {
+                requestBody.template = modelConfig.template;
+            }
+        }
+
+        if (params.json) {
+            requestBody.format = 'json';
+        }
+
+        // The generate API takes images for the whole prompt
+        const images = params.messages.flatMap(m => m.images ?? []).map(image => image.data);
+        if (images.length > 0) {
+            requestBody.images = images;
+        }
+
+        const options = this.buildOptions(params);
+        if (options) {
+            requestBody.options = options;
+        }
+
+        if (params.thinking !== false && this.getThinkingFormat(params.model) === 'native') {
+            requestBody.think = true;
+        }
+
+        return { url, body: requestBody };
+    }
+
+    // Completion-style streaming through /api/generate, for models that
+    // behave poorly under their chat template
+    private async* streamGenerate(params: StreamChatParams, modelConfig: ModelConfig): AsyncGenerator<ChatChunk> {
+        if (params.tools && params.tools.length > 0) {
+            console.log(`Model ${params.model} uses the generate API, tools will not be sent`);
+        }
+
+        const { url, body: requestBody } = this.buildGenerateRequest(params, modelConfig);
+
+        if (!(await this.isModelLoaded(params.model))) {
+            yield { type: 'status', status: 'loading_model', message: `Loading ${params.model} into memory…` };
+        }
+
+        const response = await fetchWithRetry(url, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(requestBody),
+            signal: params.signal,
+        }, params.retry);
 
         if (!response.ok) {
-            yield { type: 'error', error: `Ollama API error: ${response.statusText}` };
+            yield { type: 'error', error: await this.readErrorMessage(response) };
             return;
         }
 
+        try {
+            for await (const data of this.readJsonLines(response)) {
+                if (data.error) {
+                    yield { type: 'error', error: `Ollama error: ${data.error}` };
+                    return;
+                }
+
+                if (data.thinking) {
+                    yield { type: 'thinking', thinking: data.thinking };
+                }
+
+                if (data.response) {
+                    yield { type: 'content', content: data.response };
+                }
+
+                if (data.done) {
+                    const usage = this.usageChunk(data);
+                    if (usage) {
+                        yield usage;
+                    }
+                    yield { type: 'done', reason: data.done_reason };
+                }
+            }
+        } catch (error: unknown) {
+            yield this.streamErrorChunk(error);
+        }
+    }
+
+    // Parse a newline-delimited JSON stream. Lines can be split across reads,
+    // so the trailing partial line is kept for the next read.
+    private async* readJsonLines(response: Response): AsyncGenerator<any> {
         const reader = response.body?.getReader();
         if (!reader) {
-            yield { type: 'error', error: 'No response body' };
-            return;
+            throw new Error('No response body');
         }
 
         const decoder = new TextDecoder();
+        let buffer = "";
 
         try {
             while (true) {
                 const { done, value } = await reader.read();
                 if (done) break;
 
-                const chunk = decoder.decode(value);
-                const lines = chunk.split("\n").filter((line) => line.trim());
+                buffer += decoder.decode(value, { stream: true });
+                const lines = buffer.split("\n");
+                buffer = lines.pop() ?? "";
 
-                for (const line of lines) {
+                for (const line of lines.filter((l) => l.trim())) {
                     try {
-                        const data = JSON.parse(line);
-
-                        if (data.message?.content) {
-                            yield { type: 'content', content: data.message.content };
-                        }
-
-                        if (data.message?.tool_calls) {
-                            for (const toolCallData of data.message.tool_calls) {
-                                const toolCall: ToolCall = {
-                                    id: toolCallData.id || this.createToolCallId(),
-                                    type: "function",
-                                    function: {
-                                        name: toolCallData.function.name,
-                                        arguments: typeof toolCallData.function.arguments === "string"
-                                            ? toolCallData.function.arguments
-                                            : JSON.stringify(toolCallData.function.arguments),
-                                    },
-                                };
-
-                                yield { type: 'tool_call', toolCall };
-
-                                // Execute tool immediately if callback provided
-                                if (params.onToolCall) {
-                                    try {
-                                        await params.onToolCall(toolCall);
-                                    } catch (error) {
-                                        console.error('Tool execution error:', error);
-                                    }
-                                }
-                            }
-                        }
-
-                        if (data.done) {
-                            yield { type: 'done' };
-                        }
+                        yield JSON.parse(line);
                     } catch (parseError) {
                         console.error("Failed to parse chunk:", parseError);
                     }
                 }
             }
-        } catch (error: unknown) {
-            if (error instanceof Error && error.name === "AbortError") {
-                yield { type: 'cancelled' };
-            } else {
-                yield { type: 'error', error: error instanceof Error ? error.message : 'Unknown error' };
+
+            if (buffer.trim()) {
+                console.error("Stream ended with incomplete chunk:", buffer);
             }
         } finally {
             reader.releaseLock();
         }
     }
 
+    // Ollama options: the preference defaults, then the model's from config, then the
+    // session's, plus stop sequences
+    private buildOptions(params: StreamChatParams): Record<string, unknown> | null {
+        const options: Record<string, unknown> = { ...this.generationOptions(params.model, params) };
+        const stop = this.stopSequences(params.model, params);
+        if (stop.length > 0) {
+            options.stop = stop;
+        } else {
+            delete options.stop;
+        }
+        return Object.keys(options).length > 0 ? options : null;
+    }
+
+    // The final chunk carries token counts and timings in nanoseconds
+    private usageChunk(data: any): ChatChunk | null {
+        if (typeof data.eval_count !== "number") {
+            return null;
+        }
+        const promptTokens = data.prompt_eval_count || 0;
+        const toMs = (ns: unknown) => typeof ns === "number" ? Math.round(ns / 1e6) : undefined;
+        return {
+            type: 'usage',
+            usage: {
+                prompt_tokens: promptTokens,
+                completion_tokens: data.eval_count,
+                total_tokens: promptTokens + data.eval_count,
+                eval_duration_ms: toMs(data.eval_duration),
+                prompt_eval_duration_ms: toMs(data.prompt_eval_duration),
+                total_duration_ms: toMs(data.total_duration),
+            },
+        };
+    }
+
+    private streamErrorChunk(error: unknown): ChatChunk {
+        if (error instanceof Error && error.name === "AbortError") {
+            return { type: 'cancelled' };
+        }
+        return { type: 'error', error: error instanceof Error ? error.message : 'Unknown error' };
+    }
+
+    // A generate request with no prompt only loads the model
+    async warmUp(model: string): Promise<void> {
+        if (await this.isModelLoaded(model)) {
+            return;
+        }
+        const response = await fetch(`${this.config.baseURL}/api/generate`, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ model, prompt: "", stream: false }),
+        });
+        if (!response.ok) {
+            throw new Error(await this.readErrorMessage(response));
+        }
+    }
+
+    // Check /api/ps to see whether the model is already resident in memory
+    private async isModelLoaded(model: string): Promise<boolean> {
+        try {
+            const response = await fetch(`${this.config.baseURL}/api/ps`);
+            if (!response.ok) {
+                return true;
+            }
+            const data = await response.json();
+            return (data.models || []).some((m: { name?: string; model?: string }) =>
+                withTag(m.name || m.model || "") === withTag(model)
+            );
+        } catch {
+            // Don't report a loading state if we can't tell
+            return true;
+        }
+    }
+
+    private async readErrorMessage(response: Response): Promise<string> {
+        try {
+            const data = await response.json();
+            if (data?.error) {
+                return `Ollama API error: ${data.error}`;
+            }
+        } catch {
+            // Body wasn't JSON, fall back to the status text
+        }
+        return `Ollama API error: ${response.statusText}`;
+    }
+
     private buildToolCallMap(messages: ChatMessage[]): Map<string, string> {
         const toolCallMap = new Map<string, string>();
         messages.forEach((m) => {
@@ -189,6 +502,11 @@ export class OllamaProvider extends ChatProvider {
                 }));
             }
 
+            // Ollama takes bare base64 image data, without a MIME type
+            if (m.images && m.images.length > 0) {
+                cleaned.images = m.images.map(image => image.data);
+            }
+
             // For tool result messages, use tool_name instead of tool_call_id
             if (m.role === "tool" && m.tool_call_id) {
                 const toolName = toolCallMap.get(m.tool_call_id);
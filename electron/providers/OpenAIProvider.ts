@@ -0,0 +1,35 @@
+import { ModelConfig } from './types';
+import { LMStudioProvider } from './LMStudioProvider';
+
+// Any OpenAI-compatible chat completions endpoint: OpenAI itself, vLLM,
+// llama.cpp server, LiteLLM or a custom gateway. Streaming and tool calls
+// use the same wire format as LM Studio.
+export class OpenAIProvider extends LMStudioProvider {
+    protected apiName = 'OpenAI-compatible';
+    protected maxStopSequences = 4; // OpenAI's limit; other servers take at least as many
+
+    protected getChatCompletionsURL(): string {
+        // Accept both "https://host" and "https://host/v1" as the base URL
+        const baseURL = this.config.baseURL.replace(/\/+$/, '');
+        return baseURL.endsWith('/v1')
+            ? `${baseURL}/chat/completions`
+            : `${baseURL}/v1/chat/completions`;
+    }
+
+    protected jsonResponseFormat(): Record<string, unknown> | null {
+        return { type: 'json_object' };
+    }
+
+    async getModels(): Promise<ModelConfig[]> {
+        return this.config.models;
+    }
+
+    async getContextLength(model: string): Promise<number> {
+        // There is no standard endpoint for context length, so it must be configured
+        const modelConfig = this.config.models.find(m => m.id === model);
+        if (modelConfig?.contextLength) {
+            return modelConfig.contextLength;
+        }
+        throw new Error(`Failed to get context length: set contextLength for ${model} in providers config`);
+    }
+}
@@ -3,31 +3,34 @@ import { OllamaProvider } from './OllamaProvider';
 import { LMStudioProvider } from './LMStudioProvider';
 import { GeminiProvider } from './GeminiProvider';
 import { ClaudeProvider } from './ClaudeProvider';
+import { OpenAIProvider } from './OpenAIProvider';
+
+// Builds a provider instance from its config entry
+export type ProviderFactory = (config: ProviderConfig) => ChatProvider;
 
 export class ProviderRegistry {
     private providers = new Map<string, ChatProvider>();
+    private factories = new Map<string, ProviderFactory>();
+
+    /**
+     * Register a backend for a provider `type`. New backends plug in here
+     * without changes to the chat handlers.
+     */
+    registerProviderType(type: string, factory: ProviderFactory): void {
+        this.factories.set(type, factory);
+    }
+
+    getProviderTypes(): string[] {
+        return Array.from(this.factories.keys());
+    }
 
     registerProvider(config: ProviderConfig): void {
-        let provider: ChatProvider;
-
-        switch (config.type) {
-            case 'ollama':
-                provider = new OllamaProvider(config);
-                break;
-            case 'lmstudio':
-                provider = new LMStudioProvider(config);
-                break;
-            case 'gemini':
-                provider = new GeminiProvider(config);
-                break;
-            case 'claude':
-                provider = new ClaudeProvider(config);
-                break;
-            default:
-                throw new Error(`Unknown provider type: ${config.type}`);
+        const factory = this.factories.get(config.type);
+        if (!factory) {
+            throw new Error(`Unknown provider type: ${config.type}`);
         }
 
-        this.providers.set(config.id, provider);
+        this.providers.set(config.id, factory(config));
     }
 
     getProvider(id: string): ChatProvider | undefined {
@@ -45,7 +48,12 @@ export class ProviderRegistry {
         // Register new providers
         for (const config of configs) {
             if (config.enabled) {
-                this.registerProvider(config);
+                try {
+                    this.registerProvider(config);
+                } catch (error) {
+                    // One bad entry shouldn't take down the other providers
+                    console.error(`Failed to register provider ${config.id}:`, error);
+                }
             }
         }
     }
@@ -53,3 +61,10 @@ export class ProviderRegistry {
 
 // Global provider registry instance
 export const providerRegistry = new ProviderRegistry();
+
+// Built-in backends (Ollama is the default in the generated config)
+providerRegistry.registerProviderType('ollama', config => new OllamaProvider(config));
+providerRegistry.registerProviderType('lmstudio', config => new LMStudioProvider(config));
+providerRegistry.registerProviderType('openai', config => new OpenAIProvider(config));
+providerRegistry.registerProviderType('gemini', config => new GeminiProvider(config));
+providerRegistry.registerProviderType('claude', config => new ClaudeProvider(config));
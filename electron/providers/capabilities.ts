@@ -0,0 +1,61 @@
+import type { ChatProvider, DetectedCapabilities } from './types';
+
+// What each model can take: tool definitions, a request for separate
+// reasoning, images, and how long its context is. Servers that report it
+// (Ollama's /api/show) are asked once per model; supportsTools,
+// supportsThinking, supportsImages and contextLength on the model's entry in
+// the provider config override what they say. The engine uses this to leave
+// out what a model can't handle instead of letting the request fail.
+
+export interface ModelCapabilities {
+    tools: boolean;
+    thinking: boolean | null; // null when neither the server nor the config says
+    images: boolean | null;
+    contextLength: number | null;
+    detected: boolean; // Whether the server reported any of it
+}
+
+const detections = new Map<string, Promise<DetectedCapabilities | null>>();
+// A server that doesn't answer mustn't hold up every request to the model
+const DETECTION_TIMEOUT_MS = 10_000;
+
+function detect(provider: ChatProvider, model: string, refresh: boolean): Promise<DetectedCapabilities | null> {
+    if (!provider.detectCapabilities) {
+        return Promise.resolve(null);
+    }
+    const config = provider.getConfig();
+    const key = `${config.id}\n${config.baseURL}\n${model}`;
+    const cached = detections.get(key);
+    if (cached && !refresh) {
+        return cached;
+    }
+
+    const detection: Promise<DetectedCapabilities | null> = provider.detectCapabilities(model, AbortSignal.timeout(DETECTION_TIMEOUT_MS)).catch((error) => {
+        // Not cached, so the next request asks again once the server is back;
+        // a refresh may already have replaced this detection
+        console.warn(`Couldn't detect capabilities of ${config.id}/${model}:`, error instanceof Error ? error.message : error);
+        if (detections.get(key) === detection) {
+            detections.delete(key);
+        }
+        return null;
+    });
+    detections.set(key, detection);
+    return detection;
+}
+
+/**
+ * The capabilities of a provider's model. With refresh, the server is asked
+ * again, e.g. after the model was pulled anew.
+ */
+export async function getModelCapabilities(provider: ChatProvider, model: string, refresh: boolean = false): Promise<ModelCapabilities> {
+    const configured = provider.getConfig().models.find(m => m.id === model);
+    const detected = await detect(provider, model, refresh);
+    return {
+        // A provider without tool support never gets them, whatever the config says
+        tools: provider.getCapabilities().supportsTools && (configured?.supportsTools ?? detected?.tools ?? true),
+        thinking: configured?.supportsThinking ?? detected?.thinking ?? null,
+        images: configured?.supportsImages ?? detected?.images ?? null,
+        contextLength: configured?.contextLength || detected?.contextLength || null,
+        detected: !!detected && Object.values(detected).some(value => value !== undefined),
+    };
+}
@@ -0,0 +1,39 @@
+import { ChatMessage, PromptFormat } from './types';
+
+// Flattening of chat history into a single prompt for completion-style APIs
+// (Ollama /api/generate), where the provider doesn't apply a chat template
+// or the model's own template doesn't suit it.
+
+function renderPlain(messages: ChatMessage[]): string {
+    const labels: Record<ChatMessage['role'], string> = {
+        system: 'System',
+        user: 'User',
+        assistant: 'Assistant',
+        tool: 'Tool',
+    };
+    const turns = messages
+        .filter(m => m.content)
+        .map(m => `${labels[m.role]}: ${m.content.trim()}`);
+    return `${turns.join('\n\n')}\n\nAssistant:`;
+}
+
+function renderChatML(messages: ChatMessage[]): string {
+    const turns = messages
+        .filter(m => m.content)
+        .map(m => `<|im_start|>${m.role}\n${m.content.trim()}<|im_end|>`);
+    return `${turns.join('\n')}\n<|im_start|>assistant\n`;
+}
+
+/**
+ * Render messages as one prompt string. System messages are included, so
+ * callers that pass the system prompt separately should filter them out first.
+ */
+export function renderPrompt(messages: ChatMessage[], format: PromptFormat = 'plain'): string {
+    switch (format) {
+        case 'chatml':
+            return renderChatML(messages);
+        case 'plain':
+        default:
+            return renderPlain(messages);
+    }
+}
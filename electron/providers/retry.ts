@@ -0,0 +1,80 @@
+// Retries for requests that fail before a response starts streaming: the server
+// could not be reached, or answered with a 5xx. Errors after streaming began are
+// not retried, since part of the answer has already been shown.
+
+export interface RequestRetryOptions {
+    maxAttempts: number; // Including the first request; 1 disables retries
+    baseDelayMs: number; // Doubled after every failed attempt
+    maxDelayMs: number;
+    // Called before waiting for the next attempt
+    onRetry?: (info: { attempt: number; maxAttempts: number; delayMs: number; reason: string }) => void;
+}
+
+export const DEFAULT_REQUEST_RETRY: RequestRetryOptions = {
+    maxAttempts: 3,
+    baseDelayMs: 1000,
+    maxDelayMs: 30000,
+};
+
+function sleep(ms: number, signal?: AbortSignal): Promise<void> {
+    return new Promise((resolve, reject) => {
+        if (signal?.aborted) {
+            reject(signal.reason);
+            return;
+        }
+        const onAbort = () => {
+            clearTimeout(timer);
+            reject(signal?.reason);
+        };
+        const timer = setTimeout(() => {
+            signal?.removeEventListener("abort", onAbort);
+            resolve();
+        }, ms);
+        signal?.addEventListener("abort", onAbort, { once: true });
+    });
+}
+
+function describeFailure(error: unknown): string {
+    const cause = error instanceof Error ? (error.cause as { code?: string } | undefined) : undefined;
+    if (cause?.code) {
+        return cause.code; // ECONNREFUSED, ECONNRESET, ...
+    }
+    return error instanceof Error ? error.message : "request failed";
+}
+
+/**
+ * fetch() that retries connection errors and 5xx responses with exponential
+ * backoff. The last failure is returned (or thrown) as fetch would have, so
+ * callers report it the same way as without retries.
+ */
+export async function fetchWithRetry(
+    url: string,
+    init: RequestInit,
+    options: RequestRetryOptions = DEFAULT_REQUEST_RETRY,
+): Promise<Response> {
+    const signal = init.signal ?? undefined;
+    const maxAttempts = Math.max(1, options.maxAttempts);
+
+    for (let attempt = 1; ; attempt++) {
+        let reason: string;
+        try {
+            const response = await fetch(url, init);
+            if (response.status < 500 || attempt >= maxAttempts) {
+                return response;
+            }
+            reason = `HTTP ${response.status}`;
+            await response.body?.cancel();
+        } catch (error) {
+            if (signal?.aborted || attempt >= maxAttempts) {
+                throw error;
+            }
+            reason = describeFailure(error);
+        }
+
+        // Jitter keeps several windows from retrying against a restarted server in lockstep
+        const backoff = Math.min(options.baseDelayMs * 2 ** (attempt - 1), options.maxDelayMs);
+        const delayMs = Math.round(backoff * (0.5 + Math.random() * 0.5));
+        options.onRetry?.({ attempt, maxAttempts, delayMs, reason });
+        await sleep(delayMs, signal);
+    }
+}
@@ -0,0 +1,249 @@
+import type { ChatChunk, ThinkingFormat, ThinkingTags } from './types';
+
+// Output parsers split a model's streamed text into thinking and answer. Which
+// one applies is set per model in the providers config (`thinking`, with
+// `thinkingTags` for models that use their own tags), never guessed from the
+// model name. Markers may be split across chunks, so anything that could be
+// the start of one is held back until the next chunk arrives.
+
+export interface OutputParser {
+    push(text: string): ChatChunk[];
+    flush(): ChatChunk[];
+}
+
+export interface OutputParserOptions {
+    tags?: ThinkingTags;
+    // The model's stop sequences: servers that don't apply them let them
+    // through, so output from one on is dropped here
+    stop?: string[];
+}
+
+const DEFAULT_TAGS: ThinkingTags = { open: '<think>', close: '</think>' };
+
+// The longest end of `text` that could be the beginning of one of the markers
+function partialMarkerLength(text: string, markers: string[]): number {
+    let longest = 0;
+    for (const marker of markers) {
+        for (let length = Math.min(marker.length - 1, text.length); length > longest; length--) {
+            if (marker.startsWith(text.slice(-length))) {
+                longest = length;
+                break;
+            }
+        }
+    }
+    return longest;
+}
+
+// The first of the markers in `text`, and where it is
+function findMarker(text: string, markers: string[]): { marker: string; index: number } | null {
+    let found: { marker: string; index: number } | null = null;
+    for (const marker of markers) {
+        const index = text.indexOf(marker);
+        if (index >= 0 && (!found || index < found.index)) {
+            found = { marker, index };
+        }
+    }
+    return found;
+}
+
+function emit(chunks: ChatChunk[], text: string, thinking: boolean) {
+    if (!text) return;
+    const last = chunks[chunks.length - 1];
+    if (thinking && last?.type === 'thinking') {
+        last.thinking += text;
+    } else if (!thinking && last?.type === 'content') {
+        last.content += text;
+    } else {
+        chunks.push(thinking ? { type: 'thinking', thinking: text } : { type: 'content', content: text });
+    }
+}
+
+// Splits streamed content on <think>...</think> or the model's own tags. With
+// startInThinking the output begins inside the thinking block, for templates
+// that put the opening tag in the prompt.
+export class ThinkTagParser implements OutputParser {
+    private inThinking: boolean;
+    private pending = '';
+
+    constructor(private tags: ThinkingTags = DEFAULT_TAGS) {
+        this.inThinking = !!tags.startInThinking;
+    }
+
+    push(text: string): ChatChunk[] {
+        this.pending += text;
+        const chunks: ChatChunk[] = [];
+
+        while (this.pending) {
+            const tag = this.inThinking ? this.tags.close : this.tags.open;
+            const index = this.pending.indexOf(tag);
+
+            if (index >= 0) {
+                emit(chunks, this.pending.slice(0, index), this.inThinking);
+                this.pending = this.pending.slice(index + tag.length);
+                this.inThinking = !this.inThinking;
+                continue;
+            }
+
+            // Hold back a suffix that might be the beginning of the tag
+            const keep = partialMarkerLength(this.pending, [tag]);
+            emit(chunks, this.pending.slice(0, this.pending.length - keep), this.inThinking);
+            this.pending = this.pending.slice(this.pending.length - keep);
+            break;
+        }
+
+        return chunks;
+    }
+
+    flush(): ChatChunk[] {
+        const chunks: ChatChunk[] = [];
+        emit(chunks, this.pending, this.inThinking);
+        this.pending = '';
+        return chunks;
+    }
+}
+
+const HARMONY_START = '<|start|>';
+const HARMONY_CHANNEL = '<|channel|>';
+const HARMONY_CONSTRAIN = '<|constrain|>';
+const HARMONY_MESSAGE = '<|message|>';
+const HARMONY_ENDS = ['<|end|>', '<|return|>', '<|call|>'];
+const HARMONY_MARKERS = [HARMONY_START, HARMONY_CHANNEL, HARMONY_CONSTRAIN, HARMONY_MESSAGE, ...HARMONY_ENDS];
+
+// OpenAI's Harmony format (gpt-oss), when the server passes the channel markers
+// through: <|channel|>analysis<|message|>...<|end|><|start|>assistant<|channel|>final<|message|>...
+// The analysis channel, and commentary addressed to a tool, are thinking;
+// other channels, and text outside any message, are the answer.
+export class HarmonyParser implements OutputParser {
+    private state: 'body' | 'header' | 'channel' = 'body';
+    private channel = 'final';
+    private header = '';
+    private pending = '';
+
+    push(text: string): ChatChunk[] {
+        this.pending += text;
+        const chunks: ChatChunk[] = [];
+
+        while (this.pending) {
+            const found = findMarker(this.pending, HARMONY_MARKERS);
+            if (!found) {
+                const keep = partialMarkerLength(this.pending, HARMONY_MARKERS);
+                this.take(chunks, this.pending.slice(0, this.pending.length - keep));
+                this.pending = this.pending.slice(this.pending.length - keep);
+                break;
+            }
+
+            this.take(chunks, this.pending.slice(0, found.index));
+            this.pending = this.pending.slice(found.index + found.marker.length);
+            if (found.marker === HARMONY_CHANNEL) {
+                this.state = 'channel';
+                this.header = '';
+            } else if (found.marker === HARMONY_MESSAGE) {
+                // "commentary to=functions.lookup" names the channel, then the recipient
+                this.channel = this.header.includes('to=') ? 'tool' : this.header.trim().split(/\s+/)[0] || 'final';
+                this.state = 'body';
+            } else {
+                // A new message header, a constraint such as "json", or the end of a message
+                this.state = found.marker === HARMONY_CONSTRAIN ? 'channel' : 'header';
+            }
+        }
+
+        return chunks;
+    }
+
+    flush(): ChatChunk[] {
+        const chunks: ChatChunk[] = [];
+        this.take(chunks, this.pending);
+        this.pending = '';
+        return chunks;
+    }
+
+    private take(chunks: ChatChunk[], text: string) {
+        if (this.state === 'body') {
+            emit(chunks, text, this.channel === 'analysis' || this.channel === 'tool');
+        } else if (this.state === 'channel') {
+            this.header += text;
+        }
+        // Header text (the role) is dropped
+    }
+}
+
+// Parsers for the formats that read thinking out of the content
+const OUTPUT_PARSERS: Partial<Record<ThinkingFormat, (options: OutputParserOptions) => OutputParser>> = {
+    tags: options => new ThinkTagParser(options.tags),
+    harmony: () => new HarmonyParser(),
+};
+
+// Drops content from the first stop sequence on
+class StopSequenceFilter {
+    private pending = '';
+    private stopped = false;
+
+    constructor(private stop: string[]) {}
+
+    push(text: string): string {
+        if (this.stopped) return '';
+        this.pending += text;
+        const found = findMarker(this.pending, this.stop);
+        if (found) {
+            this.stopped = true;
+            const before = this.pending.slice(0, found.index);
+            this.pending = '';
+            return before;
+        }
+        const keep = partialMarkerLength(this.pending, this.stop);
+        const ready = this.pending.slice(0, this.pending.length - keep);
+        this.pending = this.pending.slice(this.pending.length - keep);
+        return ready;
+    }
+
+    flush(): string {
+        const rest = this.stopped ? '' : this.pending;
+        this.pending = '';
+        return rest;
+    }
+}
+
+/**
+ * Apply a model's thinking format to a provider stream.
+ *
+ * - auto: keep native thinking chunks and also parse thinking tags in content
+ * - native: keep native thinking chunks, leave content untouched
+ * - tags: parse thinking tags in content
+ * - harmony: parse Harmony channels in content
+ * - none: drop thinking entirely and leave content untouched
+ */
+export async function* applyThinkingFormat(
+    stream: AsyncGenerator<ChatChunk>,
+    format: ThinkingFormat = 'auto',
+    options: OutputParserOptions = {},
+): AsyncGenerator<ChatChunk> {
+    const factory = OUTPUT_PARSERS[format === 'auto' ? 'tags' : format];
+    const parser = factory ? factory(options) : null;
+    const stop = options.stop?.filter(Boolean).length ? new StopSequenceFilter(options.stop.filter(Boolean)) : null;
+
+    const parse = (text: string): ChatChunk[] => {
+        if (!text) return [];
+        return parser ? parser.push(text) : [{ type: 'content', content: text }];
+    };
+    const flush = (): ChatChunk[] => [...parse(stop?.flush() ?? ''), ...(parser?.flush() ?? [])];
+
+    for await (const chunk of stream) {
+        if (chunk.type === 'thinking' && format === 'none') {
+            continue;
+        }
+
+        if (chunk.type === 'content') {
+            yield* parse(stop ? stop.push(chunk.content) : chunk.content);
+            continue;
+        }
+
+        // Anything still held back belongs before the end of the stream
+        if (chunk.type === 'done' || chunk.type === 'tool_call') {
+            yield* flush();
+        }
+
+        yield chunk;
+    }
+
+    yield* flush();
+}
@@ -1,8 +1,15 @@
+import type { RequestRetryOptions } from './retry';
+import type { OutputParserOptions } from './thinking';
+
 // Provider abstraction types
 export interface TokenUsage {
     prompt_tokens: number;
     completion_tokens: number;
     total_tokens: number;
+    // Timings, from providers that report them (Ollama)
+    eval_duration_ms?: number; // Generating the completion
+    prompt_eval_duration_ms?: number; // Processing the prompt
+    total_duration_ms?: number; // Including loading the model
 }
 
 export interface ProviderCapabilities {
@@ -12,6 +19,54 @@ export interface ProviderCapabilities {
     maxContextLength?: number;
 }
 
+// What a server reports about one of its models (see capabilities.ts)
+export interface DetectedCapabilities {
+    tools?: boolean;
+    thinking?: boolean;
+    images?: boolean;
+    contextLength?: number;
+}
+
+// How a model reports its reasoning: a separate API field, inline <think> tags,
+// both, or Harmony channels (see thinking.ts)
+export type ThinkingFormat = 'auto' | 'native' | 'tags' | 'harmony' | 'none';
+
+// Tags around inline reasoning, for models that don't use <think>
+export interface ThinkingTags {
+    open: string;
+    close: string;
+    startInThinking?: boolean; // The template opens the block in the prompt, so output starts inside it
+}
+
+// Which Ollama endpoint a model is served through
+export type ModelApi = 'chat' | 'generate';
+
+// How chat history is flattened into a single prompt for the generate API
+export type PromptFormat = 'plain' | 'chatml';
+
+// Sampling and context options, sent to Ollama as `options`. The other
+// providers take what their APIs also have: num_predict as their output
+// token limit and stop as their stop sequences (Claude also the sampling ones).
+export interface GenerationOptions {
+    temperature?: number;
+    top_p?: number;
+    top_k?: number;
+    min_p?: number;
+    num_ctx?: number;
+    num_predict?: number;
+    repeat_penalty?: number;
+    seed?: number;
+    stop?: string[]; // Added to the model's stop sequences
+}
+
+// What the server says about an installed model, for the model picker
+export interface ModelDetails {
+    size?: number; // Bytes on disk
+    parameterSize?: string; // e.g. "8.0B"
+    quantization?: string; // e.g. "Q4_K_M"
+    family?: string;
+}
+
 export interface ModelConfig {
     id: string;
     name: string;
@@ -19,6 +74,19 @@ export interface ModelConfig {
     contextLength: number;
     embeddingDimension?: number | null;
     supportsTools?: boolean;
+    supportsThinking?: boolean;
+    supportsImages?: boolean;
+    thinking?: ThinkingFormat;
+    thinkingTags?: ThinkingTags; // For the tags and auto formats; default <think></think>
+    api?: ModelApi;
+    raw?: boolean; // generate API: send the prompt verbatim, without any template
+    // Ollama (Go) template replacing the model's own. /api/chat has no template
+    // parameter, so models with a template are served through the generate API.
+    template?: string;
+    stop?: string[]; // Stop sequences, sent as options.stop
+    promptFormat?: PromptFormat;
+    options?: GenerationOptions; // Defaults for this model; /set values in a session win
+    details?: ModelDetails; // From the server's model list, not the config
 }
 
 export interface ChatMessage {
@@ -28,6 +96,13 @@ export interface ChatMessage {
     tool_call_id?: string;
     timestamp: number;
     thinking?: string;
+    images?: MessageImage[]; // Attached by the user, for multimodal models
+}
+
+// A base64-encoded image sent along with a user message
+export interface MessageImage {
+    mimeType: string;
+    data: string;
 }
 
 export interface ToolCall {
@@ -63,7 +138,8 @@ export type ChatChunk =
     | { type: 'tool_call'; toolCall: ToolCall }
     | { type: 'usage'; usage: TokenUsage }
     | { type: 'thinking'; thinking: string }
-    | { type: 'done' }
+    | { type: 'status'; status: 'loading_model'; message: string }
+    | { type: 'done'; reason?: string }
     | { type: 'error'; error: string }
     | { type: 'cancelled' };
 
@@ -73,6 +149,17 @@ export interface StreamChatParams {
     tools?: ToolDefinition[];
     signal?: AbortSignal;
     onToolCall?: (toolCall: ToolCall) => Promise<ToolResult>;
+    retry?: RequestRetryOptions; // Defaults to DEFAULT_REQUEST_RETRY
+    options?: GenerationOptions; // Set with /set for the session
+    defaultOptions?: GenerationOptions; // From preferences (/seed default), below the model's own
+    json?: boolean; // JSON output mode: use the provider's JSON format where it has one
+    thinking?: boolean; // false: don't ask for separate reasoning, for models without it
+}
+
+// What streamChat sends: the endpoint and the JSON body
+export interface ProviderRequest {
+    url: string;
+    body: Record<string, unknown>;
 }
 
 export interface ProviderConfig {
@@ -94,8 +181,49 @@ export abstract class ChatProvider {
 
     abstract getCapabilities(): ProviderCapabilities;
     abstract streamChat(params: StreamChatParams): AsyncGenerator<ChatChunk>;
+    // The request streamChat would make, without making it (/preview)
+    abstract buildRequest(params: StreamChatParams): ProviderRequest;
+    // Models the server offers; configured entries are returned for models it also lists
     abstract getModels(): Promise<ModelConfig[]>;
     abstract getContextLength(model: string): Promise<number>;
+    // What the server says a model can do, for servers that report it
+    detectCapabilities?(model: string, signal?: AbortSignal): Promise<DetectedCapabilities>;
+    // Load the model into memory ahead of the first message, for servers that load on demand
+    warmUp?(model: string): Promise<void>;
+
+    getConfig(): ProviderConfig {
+        return this.config;
+    }
+
+    getThinkingFormat(model: string): ThinkingFormat {
+        return this.config.models.find(m => m.id === model)?.thinking ?? 'auto';
+    }
+
+    // Tags and stop sequences for splitting the model's output (see thinking.ts)
+    getOutputParserOptions(model: string, options?: GenerationOptions): OutputParserOptions {
+        const modelConfig = this.config.models.find(m => m.id === model);
+        const stop = this.stopSequences(model, { options });
+        return { tags: modelConfig?.thinkingTags, stop: stop.length > 0 ? stop : undefined };
+    }
+
+    // Generation options: the preference defaults, then the model's from config, then the session's
+    protected generationOptions(model: string, params: Pick<StreamChatParams, 'options' | 'defaultOptions'>): GenerationOptions {
+        const modelConfig = this.config.models.find(m => m.id === model);
+        return { ...params.defaultOptions, ...modelConfig?.options, ...params.options };
+    }
+
+    // The model's stop sequences, then those from its options or the session, once each
+    protected stopSequences(model: string, params: Pick<StreamChatParams, 'options' | 'defaultOptions'>): string[] {
+        const modelConfig = this.config.models.find(m => m.id === model);
+        const stop = [...(modelConfig?.stop ?? []), ...(this.generationOptions(model, params).stop ?? [])];
+        return [...new Set(stop.filter(sequence => sequence.length > 0))];
+    }
+
+    // The output token limit (num_predict), when one is set; -1 means none
+    protected maxOutputTokens(model: string, params: Pick<StreamChatParams, 'options' | 'defaultOptions'>): number | undefined {
+        const limit = this.generationOptions(model, params).num_predict;
+        return limit !== undefined && limit > 0 ? limit : undefined;
+    }
 
     // Helper methods
     protected normalizeMessages(messages: ChatMessage[]): ChatMessage[] {
@@ -0,0 +1,67 @@
+import { providerRegistry } from "../providers/ProviderRegistry";
+import type { ProviderConfig } from "../../src/types/chat";
+
+// Embeddings come from an Ollama provider in providers.yaml. The "rag"
+// preference picks one; otherwise the first enabled Ollama provider is used,
+// with its first embedding model or nomic-embed-text:
+//
+//   { "provider": "ollama", "model": "nomic-embed-text", "topK": 4, "minScore": 0.35 }
+
+const DEFAULT_EMBEDDING_MODEL = "nomic-embed-text";
+const EMBED_TIMEOUT_MS = 60000;
+
+export interface RagSettings {
+    enabled?: boolean; // Retrieval for chat requests; on when the project has an index
+    provider?: string;
+    model?: string;
+    topK?: number;
+    minScore?: number;
+    chunkSize?: number; // Characters per chunk when indexing
+}
+
+export interface Embedder {
+    model: string; // "providerId/modelId", stored with the index
+    embed(text: string, signal?: AbortSignal): Promise<Float32Array>;
+}
+
+export function resolveEmbedder(settings: RagSettings | null): Embedder {
+    const configs = providerRegistry.getAllProviders().map(p => p.getConfig()) as ProviderConfig[];
+    const provider = settings?.provider
+        ? configs.find(p => p.id === settings.provider)
+        : configs.find(p => p.enabled && p.type === "ollama");
+    if (!provider) {
+        throw new Error(settings?.provider
+            ? `Embedding provider ${settings.provider} not found`
+            : "No enabled Ollama provider to compute embeddings with; set rag.provider");
+    }
+    if (provider.type !== "ollama") {
+        throw new Error(`Embeddings need an Ollama provider, ${provider.id} is ${provider.type}`);
+    }
+
+    const modelId = settings?.model
+        || provider.models.find(m => m.type === "embedding")?.id
+        || DEFAULT_EMBEDDING_MODEL;
+    const endpoint = new URL(provider.config?.embeddingEndpoint || "/api/embeddings", provider.baseURL).toString();
+
+    return {
+        model: `${provider.id}/${modelId}`,
+        async embed(text, signal) {
+            const timeout = AbortSignal.timeout(EMBED_TIMEOUT_MS);
+            const response = await fetch(endpoint, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ model: modelId, prompt: text }),
+                signal: signal ? AbortSignal.any([signal, timeout]) : timeout,
+            });
+            if (!response.ok) {
+                const detail = await response.text().catch(() => "");
+                throw new Error(`Embedding with ${modelId} failed: HTTP ${response.status}${detail ? ` ${detail}` : ""}`);
+            }
+            const data = (await response.json()) as { embedding?: number[] };
+            if (!data.embedding || data.embedding.length === 0) {
+                throw new Error(`${modelId} returned no embedding; is it an embedding model?`);
+            }
+            return Float32Array.from(data.embedding);
+        },
+    };
+}
@@ -0,0 +1,85 @@
+import type { ChatMessage } from "../providers/types";
+import { resolveEmbedder, type RagSettings } from "./embeddings";
+import { IndexStore, searchIndex, type SearchHit } from "./store";
+import { appendToSystemPrompt } from "../system-prompt";
+
+export * from "./embeddings";
+export * from "./ingest";
+export * from "./store";
+
+// Retrieval for chat requests: when the project has an index, the latest user
+// message is embedded and the closest chunks are added to the system prompt,
+// like the /lang and /date context, so they never become part of the history.
+
+const DEFAULT_TOP_K = 4;
+const DEFAULT_MIN_SCORE = 0.35;
+
+export interface RagStatus {
+    indexed: boolean;
+    enabled: boolean;
+    model: string | null;
+    files: number;
+    chunks: number;
+    dimension: number;
+    updatedAt: string | null;
+    sizeBytes: number;
+}
+
+export async function ragStatus(store: IndexStore, projectPath: string, settings: RagSettings | null): Promise<RagStatus> {
+    const data = await store.load(projectPath);
+    return {
+        indexed: !!data,
+        enabled: settings?.enabled !== false,
+        model: data?.model ?? null,
+        files: data ? Object.keys(data.files).length : 0,
+        chunks: data?.chunks.length ?? 0,
+        dimension: data?.dimension ?? 0,
+        updatedAt: data?.updatedAt ?? null,
+        sizeBytes: data ? await store.sizeOnDisk(projectPath) : 0,
+    };
+}
+
+/**
+ * The indexed chunks closest to `query`, or none when the project has no index
+ * or retrieval is turned off
+ */
+export async function retrieve(
+    store: IndexStore,
+    projectPath: string,
+    query: string,
+    settings: RagSettings | null,
+    signal?: AbortSignal,
+): Promise<SearchHit[]> {
+    if (settings?.enabled === false || !query.trim()) {
+        return [];
+    }
+    const data = await store.load(projectPath);
+    if (!data || data.chunks.length === 0) {
+        return [];
+    }
+    const embedder = resolveEmbedder(settings);
+    if (embedder.model !== data.model) {
+        throw new Error(`The index was built with ${data.model}, not ${embedder.model}; run poe index again`);
+    }
+    const vector = await embedder.embed(query, signal);
+    return searchIndex(
+        data,
+        vector,
+        typeof settings?.topK === "number" ? Math.max(1, settings.topK) : DEFAULT_TOP_K,
+        typeof settings?.minScore === "number" ? settings.minScore : DEFAULT_MIN_SCORE,
+    );
+}
+
+/**
+ * Add retrieved chunks to the first system message, or as a new system message
+ * when there is none
+ */
+export function withRetrievedContext(messages: ChatMessage[], hits: SearchHit[]): ChatMessage[] {
+    if (hits.length === 0) {
+        return messages;
+    }
+
+    const excerpts = hits.map(({ chunk }, i) => `[${i + 1}] ${chunk.file}:${chunk.startLine}-${chunk.endLine}\n\`\`\`\n${chunk.text}\n\`\`\``);
+    const context = `Excerpts from the project's files that may help with the latest message. Use them if relevant and mention the file when you do:\n\n${excerpts.join("\n\n")}`;
+    return appendToSystemPrompt(messages, context);
+}
@@ -0,0 +1,193 @@
+import { open, readdir, readFile, stat } from "node:fs/promises";
+import path from "node:path";
+import type { Embedder } from "./embeddings";
+import type { IndexedChunk, IndexStore, ProjectIndexData } from "./store";
+
+// Indexing for `poe index <path>` and /rag index: text files under the path are
+// split into overlapping chunks of lines and embedded. Files that haven't
+// changed since the last run keep their chunks.
+
+const DEFAULT_CHUNK_SIZE = 1500;
+const CHUNK_OVERLAP = 200;
+const MAX_FILE_BYTES = 1024 * 1024;
+const SKIPPED_DIRECTORIES = new Set(["node_modules", "dist", "dist-electron", "build", "out", "target", "vendor", "__pycache__"]);
+
+export interface IndexProgress {
+    file: string;
+    filesDone: number;
+    filesTotal: number;
+}
+
+export interface IndexResult {
+    files: number; // Text files found
+    embedded: number; // Of those, new or changed since the last run
+    removed: number; // Previously indexed files that no longer exist
+    chunks: number; // In the whole index afterwards
+    skipped: string[]; // Files that could not be read or embedded
+}
+
+async function isTextFile(absolutePath: string): Promise<boolean> {
+    const handle = await open(absolutePath, "r");
+    try {
+        const buffer = Buffer.alloc(8192);
+        const { bytesRead } = await handle.read(buffer, 0, buffer.length, 0);
+        return !buffer.subarray(0, bytesRead).includes(0);
+    } finally {
+        await handle.close();
+    }
+}
+
+async function collectFiles(absolutePath: string, files: string[]): Promise<void> {
+    const info = await stat(absolutePath);
+    if (info.isFile()) {
+        files.push(absolutePath);
+        return;
+    }
+    if (!info.isDirectory()) {
+        return;
+    }
+    for (const entry of await readdir(absolutePath, { withFileTypes: true })) {
+        if (entry.name.startsWith(".") || (entry.isDirectory() && SKIPPED_DIRECTORIES.has(entry.name))) {
+            continue;
+        }
+        const child = path.join(absolutePath, entry.name);
+        if (entry.isDirectory()) {
+            await collectFiles(child, files);
+        } else if (entry.isFile()) {
+            files.push(child);
+        }
+    }
+}
+
+/**
+ * Split text into chunks of whole lines of about `size` characters, each
+ * starting with the last lines of the one before
+ */
+export function chunkText(text: string, size: number): Array<{ startLine: number; endLine: number; text: string }> {
+    const lines = text.split("\n");
+    const chunks: Array<{ startLine: number; endLine: number; text: string }> = [];
+    let start = 0;
+    while (start < lines.length) {
+        let end = start;
+        let length = 0;
+        while (end < lines.length && (end === start || length + lines[end].length + 1 <= size)) {
+            length += lines[end].length + 1;
+            end++;
+        }
+        const chunk = lines.slice(start, end).join("\n");
+        if (chunk.trim()) {
+            chunks.push({ startLine: start + 1, endLine: end, text: chunk });
+        }
+        if (end >= lines.length) {
+            break;
+        }
+        // Step back over up to CHUNK_OVERLAP characters of lines, always moving forward
+        let next = end;
+        let overlap = 0;
+        while (next > start + 1 && overlap + lines[next - 1].length + 1 <= CHUNK_OVERLAP) {
+            overlap += lines[next - 1].length + 1;
+            next--;
+        }
+        start = next;
+    }
+    return chunks;
+}
+
+/**
+ * Add the files under `targetPath` to the project's index, embedding the ones
+ * that are new or changed. Starts over when the embedding model changed.
+ */
+export async function indexPath(
+    store: IndexStore,
+    embedder: Embedder,
+    projectPath: string,
+    targetPath: string,
+    options: { chunkSize?: number; signal?: AbortSignal; onProgress?: (progress: IndexProgress) => void } = {},
+): Promise<IndexResult> {
+    const root = path.resolve(projectPath, targetPath);
+    const relativeRoot = path.relative(projectPath, root);
+    if (relativeRoot.startsWith("..") || path.isAbsolute(relativeRoot)) {
+        throw new Error(`${targetPath} is outside the project ${projectPath}`);
+    }
+
+    const existing = await store.load(projectPath);
+    const previous: ProjectIndexData = existing && existing.model === embedder.model
+        ? existing
+        : { model: embedder.model, dimension: 0, updatedAt: "", files: {}, chunks: [] };
+
+    const absoluteFiles: string[] = [];
+    await collectFiles(root, absoluteFiles);
+    const found = new Set(absoluteFiles.map(file => path.relative(projectPath, file)));
+    const inScope = (file: string) => relativeRoot === "" || file === relativeRoot || file.startsWith(`${relativeRoot}${path.sep}`);
+
+    // Chunks of files outside the indexed path, or unchanged within it, are kept
+    const files: Record<string, { mtimeMs: number; size: number }> = {};
+    const unchanged = new Set<string>();
+    let removed = 0;
+    for (const [file, info] of Object.entries(previous.files)) {
+        if (!inScope(file)) {
+            files[file] = info;
+        } else if (!found.has(file)) {
+            removed++;
+        }
+    }
+
+    const chunks: IndexedChunk[] = [];
+    const skipped: string[] = [];
+    let dimension = previous.dimension;
+    let embedded = 0;
+    let textFiles = 0;
+
+    for (let i = 0; i < absoluteFiles.length; i++) {
+        options.signal?.throwIfAborted();
+        const absolute = absoluteFiles[i];
+        const file = path.relative(projectPath, absolute);
+        options.onProgress?.({ file, filesDone: i, filesTotal: absoluteFiles.length });
+
+        try {
+            const info = await stat(absolute);
+            if (info.size > MAX_FILE_BYTES || !(await isTextFile(absolute))) {
+                continue;
+            }
+            textFiles++;
+            const known = previous.files[file];
+            if (known && known.mtimeMs === info.mtimeMs && known.size === info.size) {
+                files[file] = known;
+                unchanged.add(file);
+                continue;
+            }
+
+            const pieces = chunkText(await readFile(absolute, "utf-8"), options.chunkSize || DEFAULT_CHUNK_SIZE);
+            const fileChunks: IndexedChunk[] = [];
+            for (const piece of pieces) {
+                // The file name helps match questions that mention it
+                const vector = await embedder.embed(`${file}\n\n${piece.text}`, options.signal);
+                if (dimension && vector.length !== dimension) {
+                    throw new Error(`Embedding has ${vector.length} dimensions, the index has ${dimension}`);
+                }
+                dimension = vector.length;
+                fileChunks.push({ file, ...piece, vector });
+            }
+            chunks.push(...fileChunks);
+            files[file] = { mtimeMs: info.mtimeMs, size: info.size };
+            embedded++;
+        } catch (error) {
+            if (options.signal?.aborted) {
+                throw error;
+            }
+            skipped.push(`${file}: ${error instanceof Error ? error.message : "Unknown error"}`);
+        }
+    }
+
+    const kept = previous.chunks.filter(c => unchanged.has(c.file) || (!inScope(c.file) && files[c.file]));
+    const data: ProjectIndexData = {
+        model: embedder.model,
+        dimension,
+        updatedAt: new Date().toISOString(),
+        files,
+        chunks: [...kept, ...chunks],
+    };
+    await store.save(projectPath, data);
+
+    return { files: textFiles, embedded, removed, chunks: data.chunks.length, skipped };
+}
@@ -0,0 +1,159 @@
+import { createHash } from "node:crypto";
+import { existsSync } from "node:fs";
+import { mkdir, readFile, rename, stat, unlink, writeFile } from "node:fs/promises";
+import path from "node:path";
+
+// One index file per project in the config directory (rag/<hash>.json, the hash
+// of the project path as for the other per-project files).
+// Vectors are stored as base64 Float32 arrays, which keeps a few thousand
+// chunks to a few megabytes.
+
+const INDEX_VERSION = 1;
+
+export interface IndexedChunk {
+    file: string; // Relative to the project
+    startLine: number;
+    endLine: number;
+    text: string;
+    vector: Float32Array;
+}
+
+export interface IndexedFile {
+    mtimeMs: number;
+    size: number;
+}
+
+export interface ProjectIndexData {
+    model: string; // "providerId/modelId" the vectors were made with
+    dimension: number;
+    updatedAt: string;
+    files: Record<string, IndexedFile>;
+    chunks: IndexedChunk[];
+}
+
+interface StoredIndex extends Omit<ProjectIndexData, "chunks"> {
+    version: number;
+    chunks: Array<Omit<IndexedChunk, "vector"> & { vector: string }>;
+}
+
+export interface SearchHit {
+    chunk: IndexedChunk;
+    score: number; // Cosine similarity
+}
+
+const encodeVector = (vector: Float32Array) => Buffer.from(vector.buffer, vector.byteOffset, vector.byteLength).toString("base64");
+
+function decodeVector(encoded: string): Float32Array {
+    const bytes = Buffer.from(encoded, "base64");
+    // Copy so the array is aligned whatever offset Buffer chose
+    return new Float32Array(bytes.buffer.slice(bytes.byteOffset, bytes.byteOffset + bytes.byteLength));
+}
+
+export function cosineSimilarity(a: Float32Array, b: Float32Array): number {
+    let dot = 0;
+    let normA = 0;
+    let normB = 0;
+    for (let i = 0; i < a.length && i < b.length; i++) {
+        dot += a[i] * b[i];
+        normA += a[i] * a[i];
+        normB += b[i] * b[i];
+    }
+    return normA === 0 || normB === 0 ? 0 : dot / (Math.sqrt(normA) * Math.sqrt(normB));
+}
+
+export class IndexStore {
+    // Loaded indexes by project, so each chat request doesn't re-read the file.
+    // Kept with the file's modification time (-1 for no file), since `poe index`
+    // can write the index from another process.
+    private cache = new Map<string, { mtimeMs: number; data: ProjectIndexData | null }>();
+
+    constructor(private readonly directory: string) {}
+
+    indexPath(projectPath: string): string {
+        const hash = createHash("sha256").update(projectPath).digest("hex").substring(0, 16);
+        return path.join(this.directory, `${hash}.json`);
+    }
+
+    // Indexes were first named after the project path with its punctuation
+    // replaced, which let different paths share a file
+    private async migrateLegacyIndex(projectPath: string, file: string): Promise<void> {
+        const legacy = path.join(this.directory, `${projectPath.replace(/[^a-zA-Z0-9]/g, "_")}.json`);
+        if (!existsSync(file) && existsSync(legacy)) {
+            await rename(legacy, file).catch(error => console.error(`Failed to rename index ${legacy}:`, error));
+        }
+    }
+
+    async load(projectPath: string): Promise<ProjectIndexData | null> {
+        const file = this.indexPath(projectPath);
+        await this.migrateLegacyIndex(projectPath, file);
+        const mtimeMs = (await stat(file).catch(() => null))?.mtimeMs ?? -1;
+        const cached = this.cache.get(projectPath);
+        if (cached && cached.mtimeMs === mtimeMs) {
+            return cached.data;
+        }
+        let data: ProjectIndexData | null = null;
+        if (mtimeMs !== -1) {
+            try {
+                const stored = JSON.parse(await readFile(file, "utf-8")) as StoredIndex;
+                if (stored.version === INDEX_VERSION) {
+                    data = {
+                        model: stored.model,
+                        dimension: stored.dimension,
+                        updatedAt: stored.updatedAt,
+                        files: stored.files,
+                        chunks: stored.chunks.map(c => ({ ...c, vector: decodeVector(c.vector) })),
+                    };
+                } else {
+                    console.warn(`Ignoring index ${file} from an older version; run poe index again`);
+                }
+            } catch (error) {
+                console.error(`Failed to read index ${file}:`, error);
+            }
+        }
+        this.cache.set(projectPath, { mtimeMs, data });
+        return data;
+    }
+
+    async save(projectPath: string, data: ProjectIndexData): Promise<void> {
+        const stored: StoredIndex = {
+            version: INDEX_VERSION,
+            model: data.model,
+            dimension: data.dimension,
+            updatedAt: data.updatedAt,
+            files: data.files,
+            chunks: data.chunks.map(c => ({ ...c, vector: encodeVector(c.vector) })),
+        };
+        await mkdir(this.directory, { recursive: true });
+        // Written beside the index and renamed over it, so a crash can't leave half a file
+        const file = this.indexPath(projectPath);
+        await writeFile(`${file}.tmp`, JSON.stringify(stored), "utf-8");
+        await rename(`${file}.tmp`, file);
+        this.cache.set(projectPath, { mtimeMs: (await stat(file)).mtimeMs, data });
+    }
+
+    async remove(projectPath: string): Promise<boolean> {
+        this.cache.delete(projectPath);
+        const file = this.indexPath(projectPath);
+        if (!existsSync(file)) {
+            return false;
+        }
+        await unlink(file);
+        return true;
+    }
+
+    async sizeOnDisk(projectPath: string): Promise<number> {
+        const info = await stat(this.indexPath(projectPath)).catch(() => null);
+        return info?.size ?? 0;
+    }
+}
+
+/**
+ * The `topK` chunks most similar to `query`, best first, that score at least `minScore`
+ */
+export function searchIndex(data: ProjectIndexData, query: Float32Array, topK: number, minScore: number): SearchHit[] {
+    return data.chunks
+        .map(chunk => ({ chunk, score: cosineSimilarity(query, chunk.vector) }))
+        .filter(hit => hit.score >= minScore)
+        .sort((a, b) => b.score - a.score)
+        .slice(0, topK);
+}
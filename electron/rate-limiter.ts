@@ -0,0 +1,217 @@
+// Token-bucket rate limits for providers and tools, configured in
+// ~/.config/poe/rate-limits.yaml:
+//
+// providers:
+//   shared-ollama:
+//     requestsPerMinute: 20
+//     tokensPerMinute: 40000
+// tools:
+//   bash:
+//     callsPerMinute: 10
+//
+// Requests over the limit are queued in order rather than rejected.
+
+export interface ProviderRateLimit {
+    requestsPerMinute?: number;
+    tokensPerMinute?: number;
+}
+
+export interface ToolRateLimit {
+    callsPerMinute?: number;
+}
+
+export interface RateLimitConfig {
+    providers?: Record<string, ProviderRateLimit>;
+    tools?: Record<string, ToolRateLimit>;
+}
+
+// Called once when a request has to wait, with the expected delay
+export type ThrottleCallback = (waitMs: number) => void;
+
+const MINUTE_MS = 60_000;
+
+class TokenBucket {
+    private tokens: number;
+    private lastRefill = Date.now();
+    private queue: Promise<void> = Promise.resolve();
+
+    constructor(
+        public readonly capacity: number,
+        private readonly refillPerMs: number,
+    ) {
+        this.tokens = capacity;
+    }
+
+    private refill() {
+        const now = Date.now();
+        this.tokens = Math.min(this.capacity, this.tokens + (now - this.lastRefill) * this.refillPerMs);
+        this.lastRefill = now;
+    }
+
+    /**
+     * Wait until `amount` tokens are available and take them. Waiters are
+     * served in FIFO order.
+     */
+    acquire(amount: number, signal?: AbortSignal, onThrottle?: ThrottleCallback): Promise<void> {
+        const needed = Math.min(amount, this.capacity);
+        const turn = this.queue.then(() => this.take(needed, signal, onThrottle));
+        // A cancelled waiter must not block the ones behind it
+        this.queue = turn.catch(() => undefined);
+        return turn;
+    }
+
+    private async take(amount: number, signal?: AbortSignal, onThrottle?: ThrottleCallback) {
+        let notified = false;
+        for (;;) {
+            if (signal?.aborted) {
+                throw abortError();
+            }
+            this.refill();
+            if (this.tokens >= amount) {
+                this.tokens -= amount;
+                return;
+            }
+
+            const waitMs = Math.ceil((amount - this.tokens) / this.refillPerMs);
+            if (!notified) {
+                onThrottle?.(waitMs);
+                notified = true;
+            }
+            await sleep(waitMs, signal);
+        }
+    }
+
+    /**
+     * Take tokens without waiting. The bucket may go into debt, which delays
+     * later requests (used to settle actual token usage after a response).
+     */
+    consume(amount: number) {
+        this.refill();
+        this.tokens = Math.min(this.capacity, this.tokens - amount);
+    }
+}
+
+function abortError(): Error {
+    const error = new Error("Request was cancelled while rate limited");
+    error.name = "AbortError";
+    return error;
+}
+
+function sleep(ms: number, signal?: AbortSignal): Promise<void> {
+    return new Promise((resolve, reject) => {
+        const timer = setTimeout(() => {
+            signal?.removeEventListener("abort", onAbort);
+            resolve();
+        }, ms);
+        const onAbort = () => {
+            clearTimeout(timer);
+            reject(abortError());
+        };
+        signal?.addEventListener("abort", onAbort, { once: true });
+    });
+}
+
+function perMinuteBucket(limit: number | undefined): TokenBucket | null {
+    if (typeof limit !== "number" || !(limit > 0)) {
+        return null;
+    }
+    return new TokenBucket(limit, limit / MINUTE_MS);
+}
+
+// Keep only the entries that are mappings; `bash:` with nothing under it is
+// null in the YAML and limits nothing
+function limitEntries<T>(section: unknown): Record<string, T> {
+    if (!section || typeof section !== "object" || Array.isArray(section)) {
+        return {};
+    }
+    return Object.fromEntries(
+        Object.entries(section).filter(([, limit]) => limit && typeof limit === "object" && !Array.isArray(limit)),
+    ) as Record<string, T>;
+}
+
+class RateLimiter {
+    private config: RateLimitConfig = {};
+    private requestBuckets: Map<string, TokenBucket> = new Map();
+    private tokenBuckets: Map<string, TokenBucket> = new Map();
+    private toolBuckets: Map<string, TokenBucket> = new Map();
+
+    /**
+     * Apply a new config. Buckets whose limit is unchanged keep their state.
+     */
+    configure(config: RateLimitConfig) {
+        const previous = this.config;
+        this.config = {
+            providers: limitEntries<ProviderRateLimit>(config?.providers),
+            tools: limitEntries<ToolRateLimit>(config?.tools),
+        };
+
+        for (const [id, limit] of Object.entries(this.config.providers || {})) {
+            const old = previous.providers?.[id];
+            if (old?.requestsPerMinute !== limit.requestsPerMinute) {
+                this.setBucket(this.requestBuckets, id, perMinuteBucket(limit.requestsPerMinute));
+            }
+            if (old?.tokensPerMinute !== limit.tokensPerMinute) {
+                this.setBucket(this.tokenBuckets, id, perMinuteBucket(limit.tokensPerMinute));
+            }
+        }
+        for (const id of Object.keys(previous.providers || {})) {
+            if (!this.config.providers?.[id]) {
+                this.requestBuckets.delete(id);
+                this.tokenBuckets.delete(id);
+            }
+        }
+
+        for (const [name, limit] of Object.entries(this.config.tools || {})) {
+            if (previous.tools?.[name]?.callsPerMinute !== limit.callsPerMinute) {
+                this.setBucket(this.toolBuckets, name, perMinuteBucket(limit.callsPerMinute));
+            }
+        }
+        for (const name of Object.keys(previous.tools || {})) {
+            if (!this.config.tools?.[name]) {
+                this.toolBuckets.delete(name);
+            }
+        }
+    }
+
+    private setBucket(buckets: Map<string, TokenBucket>, key: string, bucket: TokenBucket | null) {
+        if (bucket) {
+            buckets.set(key, bucket);
+        } else {
+            buckets.delete(key);
+        }
+    }
+
+    /**
+     * Wait for a request slot and enough token budget for the estimated prompt
+     */
+    async acquireProvider(
+        providerId: string,
+        estimatedTokens: number,
+        signal?: AbortSignal,
+        onThrottle?: ThrottleCallback,
+    ): Promise<void> {
+        await this.requestBuckets.get(providerId)?.acquire(1, signal, onThrottle);
+        await this.tokenBuckets.get(providerId)?.acquire(estimatedTokens, signal, onThrottle);
+    }
+
+    /**
+     * Settle the difference between the estimated and actual token usage
+     */
+    recordProviderTokens(providerId: string, estimatedTokens: number, actualTokens: number) {
+        this.tokenBuckets.get(providerId)?.consume(actualTokens - estimatedTokens);
+    }
+
+    async acquireTool(toolName: string, signal?: AbortSignal, onThrottle?: ThrottleCallback): Promise<void> {
+        await this.toolBuckets.get(toolName)?.acquire(1, signal, onThrottle);
+    }
+}
+
+/**
+ * Rough prompt size in tokens (~4 characters per token)
+ */
+export function estimateTokens(messages: Array<{ content?: string }>): number {
+    const chars = messages.reduce((total, m) => total + (m.content?.length || 0), 0);
+    return Math.ceil(chars / 4);
+}
+
+export const rateLimiter = new RateLimiter();
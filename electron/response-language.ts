@@ -0,0 +1,20 @@
+import type { ChatMessage } from "./providers/types";
+import { languageName } from "../src/utils/languageName";
+import { appendToSystemPrompt } from "./system-prompt";
+
+// The responseLanguage preference (set with /lang) asks for replies in one
+// language whatever language the prompt is in. It is a BCP 47 code such as "ja"
+// or "pt-BR", added to the system prompt of every chat request.
+
+/**
+ * Add the response language instruction to the first system message, or as a new
+ * system message when there is none
+ */
+export function withResponseLanguage(messages: ChatMessage[], language: unknown): ChatMessage[] {
+    if (typeof language !== "string" || !language) {
+        return messages;
+    }
+
+    const instruction = `Always respond in ${languageName(language)} (${language}), regardless of the language the user writes in. Keep code, identifiers, commands and quoted text unchanged.`;
+    return appendToSystemPrompt(messages, instruction);
+}
@@ -0,0 +1,46 @@
+import type { ChatMessage, GenerationOptions } from "./providers/types";
+import { appendToSystemPrompt } from "./system-prompt";
+
+// The responseLength preference (set with /length) makes replies shorter or
+// longer: an instruction added to the system prompt of every chat request, and
+// an Ollama num_predict limit that only applies when neither the model config
+// nor /set gives one. "normal", or no preference, changes nothing.
+
+interface LengthPreset {
+    instruction: string;
+    numPredict: number;
+}
+
+const PRESETS: Record<string, LengthPreset> = {
+    short: {
+        instruction: "Keep responses brief: answer in a few sentences or a short list, without preamble or recap. Give longer output only when the user asks for it or it's code they requested.",
+        // Room for a short answer, and some reasoning from thinking models
+        numPredict: 1024,
+    },
+    long: {
+        instruction: "Give thorough, detailed responses: explain the reasoning, cover edge cases and alternatives, and include examples where they help.",
+        // No limit, even when the model's Modelfile sets one
+        numPredict: -1,
+    },
+};
+
+/**
+ * Ollama options for a length preset, for the lowest-priority defaults of a request
+ */
+export function responseLengthOptions(length: unknown): GenerationOptions | undefined {
+    const preset = typeof length === "string" ? PRESETS[length] : undefined;
+    return preset ? { num_predict: preset.numPredict } : undefined;
+}
+
+/**
+ * Add the length instruction to the first system message, or as a new system
+ * message when there is none
+ */
+export function withResponseLength(messages: ChatMessage[], length: unknown): ChatMessage[] {
+    const preset = typeof length === "string" ? PRESETS[length] : undefined;
+    if (!preset) {
+        return messages;
+    }
+
+    return appendToSystemPrompt(messages, preset.instruction);
+}
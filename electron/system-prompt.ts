@@ -0,0 +1,14 @@
+import type { ChatMessage } from "./providers/types";
+
+/**
+ * Add text to the first system message, or as a new system message when there
+ * is none. The notes added to every request (date, language, length, JSON
+ * output, project excerpts) all go through here.
+ */
+export function appendToSystemPrompt(messages: ChatMessage[], text: string): ChatMessage[] {
+    const systemIndex = messages.findIndex(m => m.role === "system");
+    if (systemIndex === -1) {
+        return [{ role: "system", content: text, timestamp: Date.now() }, ...messages];
+    }
+    return messages.map((m, i) => i === systemIndex ? { ...m, content: `${m.content}\n\n${text}` } : m);
+}
@@ -0,0 +1,22 @@
+import path from 'node:path';
+import { mkdir, appendFile } from 'node:fs/promises';
+import { expandHome } from '../platform';
+import { TranscriptRecord, TranscriptSink, TranscriptSinkConfig } from './TranscriptSink';
+
+// One JSON line per record, in a file per day: <path>/2025-01-31.jsonl
+export class FileSink implements TranscriptSink {
+    readonly name: string;
+    private dir: string;
+
+    constructor(config: TranscriptSinkConfig, defaultDir: string) {
+        const configured = typeof config.path === 'string' && config.path ? config.path : defaultDir;
+        this.dir = expandHome(configured);
+        this.name = `file:${this.dir}`;
+    }
+
+    async write(record: TranscriptRecord): Promise<void> {
+        await mkdir(this.dir, { recursive: true });
+        const file = path.join(this.dir, `${record.recordedAt.substring(0, 10)}.jsonl`);
+        await appendFile(file, `${JSON.stringify(record)}\n`, 'utf-8');
+    }
+}
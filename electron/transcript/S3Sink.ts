@@ -0,0 +1,129 @@
+import { createHash, createHmac, randomUUID } from 'node:crypto';
+import { TranscriptRecord, TranscriptSink, TranscriptSinkConfig } from './TranscriptSink';
+
+// Batches records into JSONL objects and uploads them with a SigV4-signed PUT,
+// which works against AWS S3 and compatible stores (MinIO, R2, Ceph...).
+
+interface S3SinkConfig {
+    endpoint: string;
+    region: string;
+    bucket: string;
+    prefix: string;
+    accessKeyId: string;
+    secretAccessKey: string;
+    // Virtual-hosted style (bucket.endpoint) instead of endpoint/bucket
+    virtualHosted: boolean;
+    batchSize: number;
+    flushIntervalMs: number;
+}
+
+const sha256 = (data: string | Buffer) => createHash('sha256').update(data).digest('hex');
+const hmac = (key: string | Buffer, data: string) => createHmac('sha256', key).update(data).digest();
+
+// S3 wants every path segment URI-encoded, with "/" kept as the separator
+const encodePath = (key: string) => key.split('/').map(segment =>
+    encodeURIComponent(segment).replace(/[!'()*]/g, c => `%${c.charCodeAt(0).toString(16).toUpperCase()}`)
+).join('/');
+
+export class S3Sink implements TranscriptSink {
+    readonly name: string;
+    private config: S3SinkConfig;
+    private buffer: TranscriptRecord[] = [];
+    private timer: ReturnType<typeof setTimeout> | null = null;
+
+    constructor(raw: TranscriptSinkConfig) {
+        for (const key of ['endpoint', 'bucket', 'accessKeyId', 'secretAccessKey']) {
+            if (typeof raw[key] !== 'string' || !raw[key]) {
+                throw new Error(`s3 transcript sink requires "${key}"`);
+            }
+        }
+        this.config = {
+            endpoint: (raw.endpoint as string).replace(/\/+$/, ''),
+            region: (raw.region as string) || 'us-east-1',
+            bucket: raw.bucket as string,
+            prefix: (raw.prefix as string) || '',
+            accessKeyId: raw.accessKeyId as string,
+            secretAccessKey: raw.secretAccessKey as string,
+            virtualHosted: raw.virtualHosted === true,
+            batchSize: typeof raw.batchSize === 'number' ? raw.batchSize : 50,
+            flushIntervalMs: typeof raw.flushIntervalMs === 'number' ? raw.flushIntervalMs : 30_000,
+        };
+        this.name = `s3:${this.config.bucket}`;
+    }
+
+    async write(record: TranscriptRecord): Promise<void> {
+        this.buffer.push(record);
+        if (this.buffer.length >= this.config.batchSize) {
+            await this.flush();
+        } else if (!this.timer) {
+            this.timer = setTimeout(() => {
+                this.flush().catch(error => console.error(`Transcript sink ${this.name} failed:`, error));
+            }, this.config.flushIntervalMs);
+        }
+    }
+
+    async flush(): Promise<void> {
+        if (this.timer) {
+            clearTimeout(this.timer);
+            this.timer = null;
+        }
+        if (this.buffer.length === 0) {
+            return;
+        }
+
+        const batch = this.buffer;
+        this.buffer = [];
+        const now = new Date();
+        const day = now.toISOString().substring(0, 10).replace(/-/g, '/');
+        const key = `${this.config.prefix}${day}/${now.getTime()}-${randomUUID()}.jsonl`;
+        const body = batch.map(record => JSON.stringify(record)).join('\n') + '\n';
+
+        try {
+            await this.put(key, body);
+        } catch (error) {
+            // Keep the records for the next attempt
+            this.buffer = batch.concat(this.buffer);
+            throw error;
+        }
+    }
+
+    private async put(key: string, body: string): Promise<void> {
+        const { endpoint, region, bucket, accessKeyId, secretAccessKey, virtualHosted } = this.config;
+        const base = new URL(endpoint);
+        const host = virtualHosted ? `${bucket}.${base.host}` : base.host;
+        const canonicalUri = virtualHosted ? `/${encodePath(key)}` : `/${encodePath(bucket)}/${encodePath(key)}`;
+
+        const amzDate = new Date().toISOString().replace(/[:-]|\.\d{3}/g, '');
+        const date = amzDate.substring(0, 8);
+        const payloadHash = sha256(body);
+        const headers: Record<string, string> = {
+            'content-type': 'application/x-ndjson',
+            host,
+            'x-amz-content-sha256': payloadHash,
+            'x-amz-date': amzDate,
+        };
+        const signedHeaders = Object.keys(headers).sort().join(';');
+        const canonicalHeaders = Object.keys(headers).sort().map(name => `${name}:${headers[name]}\n`).join('');
+        const canonicalRequest = ['PUT', canonicalUri, '', canonicalHeaders, signedHeaders, payloadHash].join('\n');
+
+        const scope = `${date}/${region}/s3/aws4_request`;
+        const stringToSign = ['AWS4-HMAC-SHA256', amzDate, scope, sha256(canonicalRequest)].join('\n');
+        const signingKey = hmac(hmac(hmac(hmac(`AWS4${secretAccessKey}`, date), region), 's3'), 'aws4_request');
+        const signature = createHmac('sha256', signingKey).update(stringToSign).digest('hex');
+
+        // fetch sets Host itself from the URL
+        const response = await fetch(`${base.protocol}//${host}${canonicalUri}`, {
+            method: 'PUT',
+            headers: {
+                'content-type': headers['content-type'],
+                'x-amz-content-sha256': payloadHash,
+                'x-amz-date': amzDate,
+                Authorization: `AWS4-HMAC-SHA256 Credential=${accessKeyId}/${scope}, SignedHeaders=${signedHeaders}, Signature=${signature}`,
+            },
+            body,
+        });
+        if (!response.ok) {
+            throw new Error(`S3 upload of ${key} failed with ${response.status}: ${await response.text()}`);
+        }
+    }
+}
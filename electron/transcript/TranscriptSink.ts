@@ -0,0 +1,115 @@
+// Archiving of chat activity to external storage. Sinks are configured in
+// ~/.config/poe/transcript-sinks.yaml:
+//
+// sinks:
+//   - type: file
+//     path: ~/poe-archive
+//   - type: s3
+//     endpoint: https://s3.us-east-1.amazonaws.com
+//     region: us-east-1
+//     bucket: agent-transcripts
+//     prefix: poe/
+//     accessKeyId: ${AWS_ACCESS_KEY_ID}
+//     secretAccessKey: ${AWS_SECRET_ACCESS_KEY}
+
+export type TranscriptRecordKind =
+    | 'message'          // a message was finalized
+    | 'message_edited'   // a finalized message changed (edit, post-response hook)
+    | 'message_deleted'
+    | 'cleared'          // every message in the session was removed at once
+    | 'tool_permission'; // the user allowed or denied a tool call
+
+export interface TranscriptRecord {
+    kind: TranscriptRecordKind;
+    projectPath: string;
+    sessionId: string;
+    recordedAt: string;
+    message?: {
+        id: string;
+        role: string;
+        content: string;
+        tool_calls?: unknown[];
+        tool_call_id?: string;
+        timestamp: number;
+        providerId?: string;
+        modelId?: string;
+    };
+    messageId?: string;
+    toolCall?: {
+        id: string;
+        name: string;
+        arguments: string;
+        decision: 'allowed' | 'denied';
+    };
+}
+
+export interface TranscriptSinkConfig {
+    type: string;
+    [key: string]: unknown;
+}
+
+export interface TranscriptSink {
+    readonly name: string;
+    write(record: TranscriptRecord): Promise<void>;
+    // Push out anything buffered (called on quit and when the config changes)
+    flush?(): Promise<void>;
+}
+
+export type TranscriptSinkFactory = (config: TranscriptSinkConfig) => TranscriptSink;
+
+export class TranscriptArchive {
+    private factories = new Map<string, TranscriptSinkFactory>();
+    private sinks: TranscriptSink[] = [];
+
+    registerSinkType(type: string, factory: TranscriptSinkFactory): void {
+        this.factories.set(type, factory);
+    }
+
+    getSinkTypes(): string[] {
+        return Array.from(this.factories.keys());
+    }
+
+    async configure(configs: TranscriptSinkConfig[]): Promise<void> {
+        await this.flush();
+        this.sinks = [];
+        for (const config of configs) {
+            const factory = this.factories.get(config.type);
+            if (!factory) {
+                console.error(`Unknown transcript sink type: ${config.type}`);
+                continue;
+            }
+            try {
+                this.sinks.push(factory(config));
+            } catch (error) {
+                console.error(`Failed to create transcript sink ${config.type}:`, error);
+            }
+        }
+    }
+
+    isEnabled(): boolean {
+        return this.sinks.length > 0;
+    }
+
+    /**
+     * Hand a record to every sink. A failing sink is logged and doesn't block the others.
+     */
+    async record(record: TranscriptRecord): Promise<void> {
+        await Promise.all(this.sinks.map(async (sink) => {
+            try {
+                await sink.write(record);
+            } catch (error) {
+                console.error(`Transcript sink ${sink.name} failed:`, error);
+            }
+        }));
+    }
+
+    async flush(): Promise<void> {
+        await Promise.all(this.sinks.map(async (sink) => {
+            try {
+                await sink.flush?.();
+            } catch (error) {
+                console.error(`Failed to flush transcript sink ${sink.name}:`, error);
+            }
+        }));
+    }
+}
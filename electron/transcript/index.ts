@@ -0,0 +1,12 @@
+import { TranscriptArchive } from './TranscriptSink';
+import { FileSink } from './FileSink';
+import { S3Sink } from './S3Sink';
+
+export * from './TranscriptSink';
+
+export function createTranscriptArchive(defaultFileDir: string): TranscriptArchive {
+    const archive = new TranscriptArchive();
+    archive.registerSinkType('file', (config) => new FileSink(config, defaultFileDir));
+    archive.registerSinkType('s3', (config) => new S3Sink(config));
+    return archive;
+}
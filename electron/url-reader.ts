@@ -0,0 +1,208 @@
+import { lookup } from "node:dns/promises";
+import { isIP } from "node:net";
+import { decodeEntities } from "./web-search";
+
+// The fetch_url tool: download a page and reduce it to readable text. Scripts,
+// navigation, headers, footers and forms are dropped, and when the page marks
+// its content with <main> or <article> only that part is kept.
+
+const DEFAULT_TIMEOUT_MS = 20000;
+const MAX_DOWNLOAD_BYTES = 2 * 1024 * 1024;
+const DEFAULT_MAX_LENGTH = 20000;
+const MAX_LENGTH_LIMIT = 100000;
+const MAX_REDIRECTS = 5;
+
+const BOILERPLATE_TAGS = ["script", "style", "noscript", "template", "svg", "nav", "header", "footer", "aside", "form", "iframe"];
+
+export interface FetchUrlParams {
+    url: string;
+    max_length?: number; // Characters of text returned
+    timeout?: number; // Seconds
+    signal?: AbortSignal;
+}
+
+// Loopback, private, link-local (cloud metadata at 169.254.169.254), shared and
+// unspecified addresses. The model picks the URL, so it must not reach services
+// on this machine or its network.
+function isPrivateAddress(address: string): boolean {
+    if (isIP(address) === 6) {
+        const lower = address.toLowerCase();
+        const mapped = lower.match(/^::ffff:(.+)$/);
+        if (mapped) {
+            // IPv4-mapped, written dotted (::ffff:127.0.0.1) or in hex (::ffff:7f00:1)
+            const hex = mapped[1].match(/^([0-9a-f]{1,4}):([0-9a-f]{1,4})$/);
+            return isPrivateAddress(hex
+                ? [parseInt(hex[1], 16) >> 8, parseInt(hex[1], 16) & 255, parseInt(hex[2], 16) >> 8, parseInt(hex[2], 16) & 255].join(".")
+                : mapped[1]);
+        }
+        return lower === "::" || lower === "::1" || /^f[cd]/.test(lower) || /^fe[89ab]/.test(lower);
+    }
+    const [a, b] = address.split(".").map(Number);
+    return a === 0 || a === 10 || a === 127
+        || (a === 169 && b === 254)
+        || (a === 172 && b >= 16 && b <= 31)
+        || (a === 192 && b === 168)
+        || (a === 100 && b >= 64 && b <= 127);
+}
+
+async function assertPublicHost(url: URL): Promise<void> {
+    if (url.protocol !== "http:" && url.protocol !== "https:") {
+        throw new Error("Only http and https URLs can be fetched");
+    }
+    const host = url.hostname.replace(/^\[(.*)\]$/, "$1");
+    const addresses = isIP(host) ? [host] : (await lookup(host, { all: true })).map(entry => entry.address);
+    if (addresses.some(isPrivateAddress)) {
+        throw new Error(`Refusing to fetch ${url.host}: it is a local or private network address`);
+    }
+}
+
+async function readLimited(response: Response, maxBytes: number): Promise<{ text: string; truncated: boolean }> {
+    if (!response.body) {
+        return { text: "", truncated: false };
+    }
+    const reader = response.body.getReader();
+    const chunks: Uint8Array[] = [];
+    let received = 0;
+    let truncated = false;
+    for (;;) {
+        const { done, value } = await reader.read();
+        if (done) {
+            break;
+        }
+        chunks.push(value);
+        received += value.length;
+        if (received >= maxBytes) {
+            truncated = true;
+            await reader.cancel();
+            break;
+        }
+    }
+    return { text: Buffer.concat(chunks).subarray(0, maxBytes).toString("utf-8"), truncated };
+}
+
+function extractTitle(html: string): string | undefined {
+    const match = html.match(/<title[^>]*>([\s\S]*?)<\/title>/i);
+    const title = match ? decodeEntities(match[1]).replace(/\s+/g, " ").trim() : "";
+    return title || undefined;
+}
+
+// The page's <main> or <article> element, when it has one
+function contentRegion(html: string): string {
+    for (const tag of ["main", "article"]) {
+        const match = html.match(new RegExp(`<${tag}\\b[^>]*>([\\s\\S]*?)<\\/${tag}>`, "i"));
+        if (match && match[1].trim()) {
+            return match[1];
+        }
+    }
+    const body = html.match(/<body\b[^>]*>([\s\S]*)<\/body>/i);
+    return body ? body[1] : html;
+}
+
+/**
+ * Convert an HTML page to plain text, keeping headings, list items, paragraphs
+ * and code blocks on their own lines
+ */
+export function htmlToText(html: string): string {
+    let text = html.replace(/<!--[\s\S]*?-->/g, "");
+    for (const tag of BOILERPLATE_TAGS) {
+        text = text.replace(new RegExp(`<${tag}\\b[^>]*>[\\s\\S]*?<\\/${tag}>`, "gi"), "");
+    }
+    text = contentRegion(text);
+
+    // Preformatted text keeps its whitespace; the rest is collapsed below
+    const preformatted: string[] = [];
+    text = text.replace(/<pre\b[^>]*>([\s\S]*?)<\/pre>/gi, (_, code: string) => {
+        preformatted.push(decodeEntities(code.replace(/<[^>]*>/g, "")));
+        return `\n\u0000${preformatted.length - 1}\u0000\n`;
+    });
+
+    text = text
+        .replace(/<h([1-6])\b[^>]*>/gi, (_, level: string) => `\n\n${"#".repeat(Number(level))} `)
+        .replace(/<li\b[^>]*>/gi, "\n- ")
+        .replace(/<br\s*\/?>/gi, "\n")
+        .replace(/<\/(p|div|section|h[1-6]|ul|ol|table|tr|blockquote|dl|dt|dd)>/gi, "\n\n")
+        .replace(/<\/t[dh]>/gi, " | ")
+        .replace(/<[^>]*>/g, "");
+    text = decodeEntities(text)
+        .split("\n")
+        .map(line => line.replace(/[ \t\u00a0]+/g, " ").trim())
+        .join("\n")
+        .replace(/\n{3,}/g, "\n\n")
+        .trim();
+
+    return text.replace(/\u0000(\d+)\u0000/g, (_, index: string) => `\`\`\`\n${preformatted[Number(index)].replace(/\n+$/, "")}\n\`\`\``);
+}
+
+export async function handleFetchUrl(params: FetchUrlParams) {
+    try {
+        let url: URL;
+        try {
+            url = new URL(params.url);
+        } catch {
+            throw new Error(`Invalid URL: ${params.url}`);
+        }
+
+        const timeoutMs = params.timeout ? Math.max(1, params.timeout) * 1000 : DEFAULT_TIMEOUT_MS;
+        const timeout = AbortSignal.timeout(timeoutMs);
+        let response: Response;
+        try {
+            // Redirects are followed here so every hop's host is checked
+            for (let hops = 0; ; hops++) {
+                await assertPublicHost(url);
+                response = await fetch(url, {
+                    headers: { Accept: "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5" },
+                    redirect: "manual",
+                    signal: params.signal ? AbortSignal.any([params.signal, timeout]) : timeout,
+                });
+                const location = response.headers.get("location");
+                if (response.status < 300 || response.status >= 400 || !location) {
+                    break;
+                }
+                await response.body?.cancel();
+                if (hops >= MAX_REDIRECTS) {
+                    throw new Error(`Stopped after ${MAX_REDIRECTS} redirects`);
+                }
+                url = new URL(location, url);
+            }
+        } catch (error) {
+            if (timeout.aborted) {
+                throw new Error(`Timed out after ${Math.round(timeoutMs / 1000)}s`);
+            }
+            throw error;
+        }
+        if (!response.ok) {
+            throw new Error(`HTTP ${response.status} ${response.statusText}`.trim());
+        }
+
+        const contentType = (response.headers.get("content-type") || "").toLowerCase();
+        const isHtml = contentType.includes("html") || contentType === "";
+        const isText = isHtml || contentType.startsWith("text/") || /json|xml|yaml|javascript/.test(contentType);
+        if (!isText) {
+            await response.body?.cancel();
+            throw new Error(`Cannot read ${contentType} content as text`);
+        }
+
+        const { text: body, truncated: downloadTruncated } = await readLimited(response, MAX_DOWNLOAD_BYTES);
+        // Servers that send no content type get the benefit of the doubt only if the body looks like a page
+        const looksLikeHtml = contentType.includes("html") || (isHtml && /<html|<body|<head|<!doctype html/i.test(body));
+        const content = looksLikeHtml ? htmlToText(body) : body.trim();
+
+        const maxLength = Math.min(Math.max(1, Math.floor(params.max_length ?? DEFAULT_MAX_LENGTH)), MAX_LENGTH_LIMIT);
+        const truncated = downloadTruncated || content.length > maxLength;
+        const title = looksLikeHtml ? extractTitle(body) : undefined;
+
+        return {
+            success: true,
+            url: url.toString(),
+            ...(title && { title }),
+            content: content.length > maxLength ? content.substring(0, maxLength) : content,
+            length: content.length,
+            truncated,
+        };
+    } catch (error) {
+        return {
+            success: false,
+            error: error instanceof Error ? error.message : "Unknown error",
+        };
+    }
+}
@@ -0,0 +1,177 @@
+import { interpolateString } from "./config-env";
+
+// Backends for the web_search tool, chosen with the "webSearch" preference:
+//
+//   { "provider": "searxng", "url": "http://localhost:8888" }
+//   { "provider": "brave", "apiKey": "${BRAVE_API_KEY}" }
+//   { "provider": "duckduckgo" }   (the default; needs no key)
+//
+// Every backend returns the same title/url/snippet results, so the model
+// doesn't need to know which one answered.
+
+const DEFAULT_MAX_RESULTS = 5;
+const MAX_RESULTS_LIMIT = 20;
+const REQUEST_TIMEOUT_MS = 15000;
+
+export interface WebSearchConfig {
+    provider?: string;
+    url?: string; // SearxNG instance
+    apiKey?: string; // Brave; ${VAR} references are expanded
+}
+
+export interface WebSearchResult {
+    title: string;
+    url: string;
+    snippet: string;
+}
+
+interface SearchOptions {
+    maxResults: number;
+    signal?: AbortSignal;
+}
+
+interface SearchProvider {
+    search(query: string, config: WebSearchConfig, options: SearchOptions): Promise<WebSearchResult[]>;
+}
+
+async function getResponse(url: string, init: RequestInit, signal?: AbortSignal): Promise<Response> {
+    const timeout = AbortSignal.timeout(REQUEST_TIMEOUT_MS);
+    const response = await fetch(url, {
+        ...init,
+        signal: signal ? AbortSignal.any([signal, timeout]) : timeout,
+    });
+    if (!response.ok) {
+        throw new Error(`Search request failed: HTTP ${response.status}`);
+    }
+    return response;
+}
+
+const searxng: SearchProvider = {
+    async search(query, config, { maxResults, signal }) {
+        if (!config.url) {
+            throw new Error("Set webSearch.url to the address of a SearxNG instance");
+        }
+        const url = new URL("/search", config.url);
+        url.searchParams.set("q", query);
+        url.searchParams.set("format", "json");
+        const response = await getResponse(url.toString(), { headers: { Accept: "application/json" } }, signal);
+        const data = (await response.json()) as { results?: Array<{ title?: string; url?: string; content?: string }> };
+        return (data.results || []).slice(0, maxResults).map(r => ({
+            title: r.title || r.url || "",
+            url: r.url || "",
+            snippet: r.content || "",
+        }));
+    },
+};
+
+const brave: SearchProvider = {
+    async search(query, config, { maxResults, signal }) {
+        const apiKey = config.apiKey ? interpolateString(config.apiKey, process.env) : "";
+        if (!apiKey) {
+            throw new Error("Set webSearch.apiKey to a Brave Search API key");
+        }
+        const url = new URL("https://api.search.brave.com/res/v1/web/search");
+        url.searchParams.set("q", query);
+        url.searchParams.set("count", String(maxResults));
+        const response = await getResponse(url.toString(), {
+            headers: { Accept: "application/json", "X-Subscription-Token": apiKey },
+        }, signal);
+        const data = (await response.json()) as { web?: { results?: Array<{ title?: string; url?: string; description?: string }> } };
+        return (data.web?.results || []).slice(0, maxResults).map(r => ({
+            title: r.title || r.url || "",
+            url: r.url || "",
+            snippet: stripTags(r.description || ""),
+        }));
+    },
+};
+
+export function decodeEntities(text: string): string {
+    return text
+        .replace(/&#x([0-9a-f]+);/gi, (_, hex: string) => String.fromCodePoint(parseInt(hex, 16)))
+        .replace(/&#(\d+);/g, (_, dec: string) => String.fromCodePoint(parseInt(dec, 10)))
+        .replace(/&quot;/g, "\"")
+        .replace(/&apos;/g, "'")
+        .replace(/&lt;/g, "<")
+        .replace(/&gt;/g, ">")
+        .replace(/&nbsp;/g, " ")
+        .replace(/&amp;/g, "&");
+}
+
+function stripTags(html: string): string {
+    return decodeEntities(html.replace(/<[^>]*>/g, "")).replace(/\s+/g, " ").trim();
+}
+
+// Result links on the HTML page go through a redirect: //duckduckgo.com/l/?uddg=<url>
+function unwrapDuckDuckGoLink(href: string): string {
+    const decoded = decodeEntities(href);
+    try {
+        const url = new URL(decoded, "https://duckduckgo.com");
+        return url.searchParams.get("uddg") || url.toString();
+    } catch {
+        return decoded;
+    }
+}
+
+const duckduckgo: SearchProvider = {
+    async search(query, _config, { maxResults, signal }) {
+        const response = await getResponse("https://html.duckduckgo.com/html/", {
+            method: "POST",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: new URLSearchParams({ q: query }).toString(),
+        }, signal);
+        const html = await response.text();
+
+        const results: WebSearchResult[] = [];
+        const linkPattern = /<a[^>]*class="result__a"[^>]*href="([^"]*)"[^>]*>([\s\S]*?)<\/a>/g;
+        const snippetPattern = /<a[^>]*class="result__snippet"[^>]*>([\s\S]*?)<\/a>/g;
+        const snippets = Array.from(html.matchAll(snippetPattern), m => stripTags(m[1]));
+        let index = 0;
+        for (const match of html.matchAll(linkPattern)) {
+            const url = unwrapDuckDuckGoLink(match[1]);
+            // Ads link to duckduckgo.com/y.js and have no snippet of their own
+            if (!url.includes("duckduckgo.com/y.js")) {
+                results.push({ title: stripTags(match[2]), url, snippet: snippets[index] || "" });
+            }
+            index++;
+            if (results.length >= maxResults) {
+                break;
+            }
+        }
+        return results;
+    },
+};
+
+const providers: Record<string, SearchProvider> = { searxng, brave, duckduckgo };
+
+export interface WebSearchParams {
+    query: string;
+    max_results?: number;
+    config: WebSearchConfig | null;
+    signal?: AbortSignal;
+}
+
+export async function handleWebSearch(params: WebSearchParams) {
+    const providerName = (params.config?.provider || "duckduckgo").toLowerCase();
+    try {
+        const provider = providers[providerName];
+        if (!provider) {
+            throw new Error(`Unknown search provider "${providerName}" (expected ${Object.keys(providers).join(", ")})`);
+        }
+        if (!params.query.trim()) {
+            throw new Error("Query must not be empty");
+        }
+        const maxResults = Math.min(Math.max(1, Math.floor(params.max_results ?? DEFAULT_MAX_RESULTS)), MAX_RESULTS_LIMIT);
+        const results = await provider.search(params.query, params.config || {}, { maxResults, signal: params.signal });
+        return {
+            success: true,
+            query: params.query,
+            provider: providerName,
+            results,
+        };
+    } catch (error) {
+        return {
+            success: false,
+            error: error instanceof Error ? error.message : "Unknown error",
+        };
+    }
+}
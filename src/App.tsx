@@ -1,4 +1,4 @@
-import { useState } from "react";
+import { useState, useEffect } from "react";
 import { ThemeProvider, createTheme, CssBaseline, Box } from "@mui/material";
 import { TitleBar } from "./components/TitleBar";
 import { DirectorySelectionView } from "./components/DirectorySelectionView";
@@ -35,6 +35,28 @@ function App() {
 	const [loadHistory, setLoadHistory] = useState<boolean>(true);
 	const [focusTrigger, setFocusTrigger] = useState(0);
 	const [settingsTab, setSettingsTab] = useState<number>(0);
+	const [initialSession, setInitialSession] = useState<string | null>(null);
+
+	// Open a project straight away for "poe --resume" / "poe --session <name>"
+	useEffect(() => {
+		const applyLaunchOptions = async () => {
+			const options = await window.electronAPI.getLaunchOptions();
+			if (!options?.directory || (!options.resume && !options.session)) return;
+
+			const validation = await window.electronAPI.validateDirectory(options.directory);
+			if (!validation.valid) {
+				console.error("Cannot resume in", options.directory, validation.error);
+				return;
+			}
+
+			await window.electronAPI.changeWorkingDirectory(options.directory);
+			await window.electronAPI.recentProjectsAdd(options.directory);
+			setInitialSession(options.session);
+			handleDirectorySelected(options.directory, true);
+		};
+
+		applyLaunchOptions().catch(console.error);
+	}, []);
 
 	const handleDirectorySelected = (path: string, shouldLoadHistory: boolean) => {
 		setWorkingDirectory(path);
@@ -84,6 +106,7 @@ function App() {
 					<ProjectView
 						workingDirectory={workingDirectory}
 						loadHistory={loadHistory}
+						initialSession={initialSession}
 						onOpenSettings={handleOpenSettings}
 						focusTrigger={focusTrigger}
 					/>
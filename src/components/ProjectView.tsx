@@ -1,20 +1,25 @@
 import ChatProvider from '../context/ChatContext';
 import { ChatContainer } from './chat/ChatContainer';
 import { initializeTools } from '../tools';
+import { initializeHooks } from '../pipeline';
+import { initializeSpeech } from '../speech';
 
 interface ProjectViewProps {
   workingDirectory: string;
   loadHistory: boolean;
+  initialSession?: string | null;
   onOpenSettings: (tab?: string | number) => void;
   focusTrigger?: number;
 }
 
-// Initialize tools on module load
+// Initialize tools, hooks and speech backends on module load
 initializeTools();
+initializeHooks();
+initializeSpeech();
 
-export function ProjectView({ workingDirectory, loadHistory, onOpenSettings, focusTrigger }: ProjectViewProps) {
+export function ProjectView({ workingDirectory, loadHistory, initialSession, onOpenSettings, focusTrigger }: ProjectViewProps) {
   return (
-    <ChatProvider workingDirectory={workingDirectory} loadHistory={loadHistory}>
+    <ChatProvider workingDirectory={workingDirectory} loadHistory={loadHistory} initialSession={initialSession}>
       <ChatContainer
         workingDirectory={workingDirectory}
         onOpenSettings={onOpenSettings}
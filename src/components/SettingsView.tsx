@@ -100,12 +100,34 @@ const DEFAULT_PROVIDERS = yaml.dump(
                                 },
                                 enabled: true,
                         },
+                        {
+                                id: "openai",
+                                name: "OpenAI-compatible",
+                                type: "openai",
+                                baseURL: "https://api.openai.com/v1",
+                                apiKey: "${OPENAI_API_KEY}",
+                                models: [
+                                        {
+                                                id: "gpt-4o-mini",
+                                                name: "GPT-4o mini",
+                                                type: "chat",
+                                                contextLength: 128000,
+                                                embeddingDimension: null,
+                                                supportsTools: true,
+                                        },
+                                ],
+                                config: {
+                                        timeout: 60000,
+                                        retryAttempts: 3,
+                                },
+                                enabled: false,
+                        },
                         {
                                 id: "gemini",
                                 name: "Google Gemini",
                                 type: "gemini",
                                 baseURL: "https://generativelanguage.googleapis.com/v1beta",
-                                apiKey: "YOUR_GEMINI_API_KEY_HERE",
+                                apiKey: "${GEMINI_API_KEY}",
                                 models: [
                                         {
                                                 id: "gemini-2.0-flash-exp",
@@ -143,7 +165,7 @@ const DEFAULT_PROVIDERS = yaml.dump(
                                 name: "Anthropic Claude",
                                 type: "claude",
                                 baseURL: "https://api.anthropic.com/v1",
-                                apiKey: "YOUR_ANTHROPIC_API_KEY_HERE",
+                                apiKey: "${ANTHROPIC_API_KEY}",
                                 models: [
                                         {
                                                 id: "claude-3-5-sonnet-20241022",
@@ -0,0 +1,49 @@
+import { useMemo } from 'react';
+import type { CSSProperties } from 'react';
+import { parseAnsiLines, type AnsiSegment, type AnsiStyle } from '../../utils/ansi';
+
+// Inverse video swaps with these when the text sets no colors of its own
+const DEFAULT_FG = '#cdd6f4';
+const DEFAULT_BG = '#1e1e2e';
+
+function segmentStyle(style: AnsiStyle): CSSProperties | undefined {
+  const fg = style.inverse ? style.bg ?? DEFAULT_BG : style.fg;
+  const bg = style.inverse ? style.fg ?? DEFAULT_FG : style.bg;
+  const decorations = [style.underline && 'underline', style.strike && 'line-through'].filter(Boolean);
+  const css: CSSProperties = {
+    ...(fg && { color: fg }),
+    ...(bg && { backgroundColor: bg }),
+    ...(style.bold && { fontWeight: 600 }),
+    ...(style.dim && { opacity: 0.6 }),
+    ...(style.italic && { fontStyle: 'italic' }),
+    ...(decorations.length > 0 && { textDecoration: decorations.join(' ') }),
+  };
+  return Object.keys(css).length > 0 ? css : undefined;
+}
+
+function renderSegments(segments: AnsiSegment[]) {
+  return segments.map((segment, index) => {
+    const css = segmentStyle(segment.style);
+    return css ? <span key={index} style={css}>{segment.text}</span> : segment.text;
+  });
+}
+
+// One line of terminal output, as parsed by parseAnsiLines
+export function AnsiLine({ segments }: { segments: AnsiSegment[] }) {
+  return <>{segments.length > 0 ? renderSegments(segments) : ' '}</>;
+}
+
+// Tool output with its terminal colors, and without the escape codes that carry them
+export function AnsiText({ text }: { text: string }) {
+  const lines = useMemo(() => parseAnsiLines(text), [text]);
+  return (
+    <>
+      {lines.map((segments, index) => (
+        <span key={index}>
+          {renderSegments(segments)}
+          {index < lines.length - 1 && '\n'}
+        </span>
+      ))}
+    </>
+  );
+}
@@ -0,0 +1,109 @@
+import { Box, CircularProgress, Dialog, DialogContent, DialogTitle, IconButton, Typography } from '@mui/material';
+import { X } from 'lucide-react';
+import { diffWords } from 'diff';
+import { useMemo } from 'react';
+
+export interface AnswerDiffState {
+  previous: { label: string; content: string };
+  rerun: { label: string; content: string | null; error: string | null }; // content is null while running
+}
+
+interface AnswerDiffProps {
+  diff: AnswerDiffState | null;
+  onClose: () => void;
+}
+
+// /diff last: word-level differences between an answer and a re-run of its prompt
+export function AnswerDiff({ diff, onClose }: AnswerDiffProps) {
+  const changes = useMemo(() => {
+    if (!diff || diff.rerun.content === null) {
+      return null;
+    }
+    return diffWords(diff.previous.content, diff.rerun.content);
+  }, [diff]);
+
+  const counts = useMemo(() => {
+    const words = (text: string) => text.split(/\s+/).filter(Boolean).length;
+    return {
+      added: (changes || []).filter(c => c.added).reduce((sum, c) => sum + words(c.value), 0),
+      removed: (changes || []).filter(c => c.removed).reduce((sum, c) => sum + words(c.value), 0),
+    };
+  }, [changes]);
+
+  return (
+    <Dialog
+      open={!!diff}
+      onClose={onClose}
+      maxWidth="md"
+      fullWidth
+      PaperProps={{
+        sx: {
+          backgroundColor: '#313244',
+          color: '#cdd6f4',
+          maxHeight: '80vh',
+        },
+      }}
+    >
+      <DialogTitle sx={{ display: 'flex', alignItems: 'center', py: 1.5 }}>
+        <Box sx={{ flex: 1, minWidth: 0 }}>
+          <Typography component="div" sx={{ fontWeight: 600 }}>
+            Answer diff
+          </Typography>
+          {diff && (
+            <Typography variant="caption" component="div" sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+              <Box component="span" sx={{ color: '#f38ba8' }}>{diff.previous.label}</Box>
+              {' → '}
+              <Box component="span" sx={{ color: '#a6e3a1' }}>{diff.rerun.label}</Box>
+              {changes && ` · ${counts.removed} words removed, ${counts.added} added`}
+            </Typography>
+          )}
+        </Box>
+        <IconButton size="small" onClick={onClose} sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+          <X size={16} />
+        </IconButton>
+      </DialogTitle>
+      <DialogContent sx={{ pt: 0 }}>
+        {diff?.rerun.error && (
+          <Typography variant="body2" sx={{ color: '#f38ba8', py: 1 }}>
+            {diff.rerun.error}
+          </Typography>
+        )}
+
+        {diff && !diff.rerun.error && !changes && (
+          <Box sx={{ display: 'flex', alignItems: 'center', gap: 1, py: 3, justifyContent: 'center' }}>
+            <CircularProgress size={20} sx={{ color: '#89b4fa' }} />
+            <Typography variant="body2" sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+              Re-running the prompt with {diff.rerun.label}…
+            </Typography>
+          </Box>
+        )}
+
+        {changes && (
+          <Box sx={{
+            whiteSpace: 'pre-wrap',
+            wordBreak: 'break-word',
+            fontSize: '14px',
+            lineHeight: 1.6,
+            backgroundColor: '#1e1e2e',
+            borderRadius: 1,
+            p: 2,
+          }}>
+            {changes.map((change, index) => (
+              <Box
+                key={index}
+                component="span"
+                sx={change.added
+                  ? { backgroundColor: 'rgba(166, 227, 161, 0.15)', color: '#a6e3a1' }
+                  : change.removed
+                  ? { backgroundColor: 'rgba(243, 139, 168, 0.15)', color: '#f38ba8', textDecoration: 'line-through' }
+                  : undefined}
+              >
+                {change.value}
+              </Box>
+            ))}
+          </Box>
+        )}
+      </DialogContent>
+    </Dialog>
+  );
+}
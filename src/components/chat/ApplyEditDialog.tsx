@@ -0,0 +1,109 @@
+import { Box, Button, Dialog, DialogActions, DialogContent, DialogTitle, IconButton, Typography } from '@mui/material';
+import { X } from 'lucide-react';
+import { diffLines } from 'diff';
+import { useMemo, useState } from 'react';
+import { DiffViewer } from './DiffViewer';
+import type { PendingApply } from '../../hooks/usePendingApply';
+
+interface ApplyEditDialogProps {
+  pending: PendingApply | null;
+  onApply: () => Promise<void>;
+  onClose: () => void;
+}
+
+// /apply: the change a code block would make to its file, written only once confirmed
+export function ApplyEditDialog({ pending, onApply, onClose }: ApplyEditDialogProps) {
+  const [applying, setApplying] = useState(false);
+
+  const counts = useMemo(() => {
+    const changes = pending ? diffLines(pending.oldContent, pending.newContent) : [];
+    return {
+      added: changes.filter(c => c.added).reduce((sum, c) => sum + (c.count ?? 0), 0),
+      removed: changes.filter(c => c.removed).reduce((sum, c) => sum + (c.count ?? 0), 0),
+    };
+  }, [pending]);
+
+  const handleApply = async () => {
+    setApplying(true);
+    try {
+      await onApply();
+    } finally {
+      setApplying(false);
+    }
+  };
+
+  return (
+    <Dialog
+      open={!!pending}
+      onClose={onClose}
+      maxWidth="md"
+      fullWidth
+      PaperProps={{
+        sx: {
+          backgroundColor: '#313244',
+          color: '#cdd6f4',
+          maxHeight: '80vh',
+        },
+      }}
+    >
+      <DialogTitle sx={{ display: 'flex', alignItems: 'center', py: 1.5 }}>
+        <Box sx={{ flex: 1, minWidth: 0 }}>
+          <Typography component="div" sx={{ fontWeight: 600 }}>
+            Apply to {pending?.path}
+          </Typography>
+          {pending && (
+            <Typography variant="caption" component="div" sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+              <Box component="span" sx={{ color: '#a6e3a1' }}>+{counts.added}</Box>
+              {' '}
+              <Box component="span" sx={{ color: '#f38ba8' }}>-{counts.removed}</Box>
+              {' lines'}
+            </Typography>
+          )}
+        </Box>
+        <IconButton size="small" onClick={onClose} sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+          <X size={16} />
+        </IconButton>
+      </DialogTitle>
+      <DialogContent sx={{ pt: 0 }}>
+        {pending?.warnings.map(warning => (
+          <Typography key={warning} variant="body2" sx={{ color: '#f9e2af', py: 0.5 }}>
+            {warning}
+          </Typography>
+        ))}
+        {pending && counts.added === 0 && counts.removed === 0 ? (
+          <Typography variant="body2" sx={{ color: 'rgba(205, 214, 244, 0.6)', py: 3, textAlign: 'center' }}>
+            The file already matches this version.
+          </Typography>
+        ) : pending && (
+          <DiffViewer oldContent={pending.oldContent} newContent={pending.newContent} fileName={pending.path} />
+        )}
+      </DialogContent>
+      <DialogActions>
+        <Button
+          onClick={onClose}
+          sx={{
+            color: 'rgba(205, 214, 244, 0.7)',
+            '&:hover': {
+              backgroundColor: 'rgba(205, 214, 244, 0.1)',
+            }
+          }}
+        >
+          Cancel
+        </Button>
+        <Button
+          onClick={handleApply}
+          disabled={applying || (counts.added === 0 && counts.removed === 0)}
+          sx={{
+            color: '#a6e3a1',
+            '&:hover': {
+              backgroundColor: 'rgba(166, 227, 161, 0.1)',
+            }
+          }}
+          autoFocus
+        >
+          Apply
+        </Button>
+      </DialogActions>
+    </Dialog>
+  );
+}
@@ -1,29 +1,75 @@
 import { Box } from '@mui/material';
-import { useEffect, useCallback, useState, useRef } from 'react';
+import { useEffect, useCallback, useState, useRef, useMemo } from 'react';
 import { useChat } from '../../hooks/useChat';
+import { conversationStore } from '../../context/conversationStore';
 import { MessageList } from './MessageList';
+import { DebugPane } from './DebugPane';
+import { EventsPane } from './EventsPane';
 import { InputBox } from './InputBox';
 import { ToolsPanel } from './ToolsPanel';
 import { ChatHeader } from './ChatHeader';
 import { SessionMenu } from './SessionMenu';
 import { ErrorDisplay } from './ErrorDisplay';
-import type { ChatMessage, ProvidersData } from '../../types/chat';
+import { NoticeDisplay } from './NoticeDisplay';
+import { ModelPicker } from './ModelPicker';
+import { AnswerDiff, type AnswerDiffState } from './AnswerDiff';
+import { ApplyEditDialog } from './ApplyEditDialog';
+import { OutputPager } from './OutputPager';
+import { DEFAULT_THINKING_SETTINGS, type ChatMessage, type ContextMode, type ModelConfig, type ProviderConfig, type ThinkingSettings, type WorkspaceTrust } from '../../types/chat';
+import { findModelByRef, isLocalProvider, parseModelOverride, type ModelSelection } from '../../utils/modelUtils';
 import { toolRegistry } from '../../tools';
+import { hookConfigManager } from '../../pipeline';
 import { mcpToolsManager } from '../../tools/MCPToolsManager';
 import { toolConfigManager } from '../../tools/ToolConfigManager';
+import { speechManager } from '../../speech';
 import { useContextManagement } from '../../hooks/useContextManagement';
 import { useSessionManagement } from '../../hooks/useSessionManagement';
-import { useToolExecution } from '../../hooks/useToolExecution';
+import { useToolExecution, cancelRunningToolCalls } from '../../hooks/useToolExecution';
 import { useMessageActions } from '../../hooks/useMessageActions';
 import { useChatStreaming } from '../../hooks/useChatStreaming';
+import { useSlashCommands } from '../../hooks/useSlashCommands';
+import { useTranscriptArchive } from '../../hooks/useTranscriptArchive';
+import { useWatchNotifications } from '../../hooks/useWatchNotifications';
+import { useObserverFeed } from '../../hooks/useObserverFeed';
+import { useLongRequestHooks } from '../../hooks/useLongRequestHooks';
+import { useTurnHooks } from '../../hooks/useTurnHooks';
+import { useSessionTitle } from '../../hooks/useSessionTitle';
+import { getKeyBindings } from '../../hooks/useKeyBindings';
+import { matchesKey } from '../../utils/keyBindings';
+import { clearSessionRecap, useSessionRecap } from '../../hooks/useSessionRecap';
+import { setPendingSnippets, usePendingSnippets, writeSnippets } from '../../hooks/usePendingSnippets';
+import { setPendingApply, usePendingApply } from '../../hooks/usePendingApply';
+import { setToolOutputPage, useToolOutputPage } from '../../hooks/useToolOutputPager';
+import { describeSavedSnippets } from '../../utils/snippets';
+import { addAttachment, clearAttachments, getAttachments, takeAttachments } from '../../hooks/useAttachments';
+import { setInputDraft } from '../../hooks/useInputDraft';
+import { unescapeSlashMessage } from '../../utils/slashCommands';
+import { estimateTokenUsage } from '../../utils/messageUtils';
+import { applySummaries, readSummarizerSettings } from '../../utils/contextSummary';
+import { checkToolIterationLimit } from '../../utils/toolIterations';
 import yaml from 'js-yaml';
 
+// Identical sends within this window are treated as an accidental double submit
+const DUPLICATE_SEND_WINDOW_MS = 2000;
+
+// Sent after a stopped reply by /continue, which resumes it in place
+const INTERRUPTED_CONTINUE_PROMPT = 'Your previous response was interrupted. Continue it from exactly where it stopped, without repeating anything already written.';
+
 interface ChatContainerProps {
   workingDirectory: string;
   onOpenSettings: (tab?: string | number) => void;
   focusTrigger?: number;
 }
 
+// Stored thinking preference merged over the defaults
+async function readThinkingSettings(): Promise<ThinkingSettings> {
+  const result = await window.electronAPI.preferencesGet('thinking');
+  const stored = result.success && result.value && typeof result.value === 'object'
+    ? result.value as Partial<ThinkingSettings>
+    : {};
+  return { ...DEFAULT_THINKING_SETTINGS, ...stored };
+}
+
 export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }: ChatContainerProps) {
   const { state, dispatch, loadSession, createNewSession, updateSessionName } = useChat();
   const [homeDir, setHomeDir] = useState<string>('');
@@ -35,7 +81,7 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
   const prevModelIdRef = useRef<string | undefined>(undefined);
   const prevMessagesLengthRef = useRef<number>(0);
   const prevVirtualContextSizeRef = useRef<number | null>(null);
-  const prevContextModeRef = useRef<'rolling' | 'halt'>('rolling');
+  const prevContextModeRef = useRef<ContextMode>('rolling');
   const prevMessagesContentLengthRef = useRef<number>(0);
 
   const loadHomeDir = async () => {
@@ -43,20 +89,48 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     setHomeDir(home);
   };
 
+  const loadOfflineMode = async () => {
+    const result = await window.electronAPI.preferencesGet('offlineMode');
+    if (result.success && result.value === true) {
+      dispatch({ type: 'SET_OFFLINE_MODE', payload: true });
+    }
+  };
+
+  const loadShowStats = async () => {
+    const result = await window.electronAPI.preferencesGet('showStats');
+    if (result.success && result.value === true) {
+      dispatch({ type: 'SET_SHOW_STATS', payload: true });
+    }
+  };
+
+  const loadSafeMode = async () => {
+    if (await window.electronAPI.getSafeMode()) {
+      dispatch({ type: 'SET_NOTICE', payload: 'Safe mode: default settings, no tools, hooks or MCP servers. Changes are discarded on quit.' });
+    }
+  };
+
+  const loadThinkingSettings = async () => {
+    const settings = await readThinkingSettings();
+    dispatch({ type: 'SET_THINKING_SETTINGS', payload: settings });
+  };
+
   const loadProviders = async () => {
-    const result = await window.electronAPI.configRead('providers.json');
-    if (result.success && result.content) {
-      const data: ProvidersData = yaml.load(result.content) as ProvidersData;
-      dispatch({ type: 'LOAD_PROVIDERS', payload: data.providers });
+    // Resolved in main like the registry's copy, so ${VAR} in baseURL doesn't
+    // throw off isLocalProvider
+    const result = await window.electronAPI.configReadProviders();
+    if (result.success) {
+      dispatch({ type: 'LOAD_PROVIDERS', payload: result.providers });
     }
   };
 
   // Context management hook
   const {
     contextMode,
+    setContextMode,
     virtualContextSize,
     setVirtualContextSize,
     applyContextManagement,
+    compactContext,
     updateContextUsage,
   } = useContextManagement(state, dispatch, workingDirectory);
 
@@ -74,22 +148,47 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     }
 
     const currentMessages = [...state.messages];
+    const lastMessage = currentMessages[currentMessages.length - 1];
+    let messagesToSend = currentMessages;
+
+    // Tool results going back to the model count towards the tool iteration limit
+    if (lastMessage?.role === 'tool') {
+      const limitNotice = await checkToolIterationLimit(currentMessages);
+      if (limitNotice) {
+        dispatch({ type: 'SET_NOTICE', payload: limitNotice });
+        return;
+      }
+    }
 
-    const assistantMessageId = `assistant-${Date.now()}`;
-    const assistantMessage: ChatMessage = {
-      id: assistantMessageId,
-      role: 'assistant',
-      content: '',
-      timestamp: Date.now(),
-    };
-
-    dispatch({ type: 'ADD_MESSAGE', payload: assistantMessage });
-    dispatch({ type: 'START_STREAMING', payload: assistantMessageId });
+    if (lastMessage?.role === 'assistant' && lastMessage.stopped) {
+      // Resume the stopped response in place instead of starting a new message
+      dispatch({ type: 'UPDATE_MESSAGE', payload: { id: lastMessage.id, updates: { stopped: false } } });
+      dispatch({ type: 'START_STREAMING', payload: lastMessage.id });
+
+      messagesToSend = [
+        ...currentMessages,
+        {
+          id: `continue-${Date.now()}`,
+          role: 'user',
+          content: INTERRUPTED_CONTINUE_PROMPT,
+          timestamp: Date.now(),
+        },
+      ];
+    } else {
+      const assistantMessageId = `assistant-${Date.now()}`;
+      const assistantMessage: ChatMessage = {
+        id: assistantMessageId,
+        role: 'assistant',
+        content: '',
+        timestamp: Date.now(),
+      };
+
+      dispatch({ type: 'ADD_MESSAGE', payload: assistantMessage });
+      dispatch({ type: 'START_STREAMING', payload: assistantMessageId });
+    }
 
     toolExecution.clearToolExecutionRefs();
 
-    const messagesToSend = currentMessages;
-
     let contextTotal = virtualContextSize || null;
     if (!contextTotal) {
       contextTotal = state.currentModel.contextLength || null;
@@ -137,11 +236,15 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     });
 
     try {
+      // Stream hooks are made from it as the first content arrives
+      await hookConfigManager.loadConfig();
       const result = await window.electronAPI.chatSendMessage({
         provider: state.currentProvider.id,
         model: state.currentModel.id,
         messages: finalMessagesToSend,
         tools: toolRegistry.getDefinitions(),
+        projectPath: workingDirectory,
+        options: state.generationOptions,
       });
 
       if (result && !result.success && result.error) {
@@ -160,11 +263,24 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
       });
       dispatch({ type: 'END_STREAMING' });
     }
-  }, [state.isLoading, state.currentProvider, state.currentModel, state.messages, virtualContextSize, applyContextManagement, dispatch]);
+  }, [state.isLoading, state.currentProvider, state.currentModel, state.messages, state.generationOptions, virtualContextSize, applyContextManagement, dispatch, workingDirectory]);
 
   // Tool execution hook
   const toolExecution = useToolExecution(state, dispatch, workingDirectory, handleContinue);
 
+  // Archive finalized messages to any configured transcript sinks
+  useTranscriptArchive(state, workingDirectory, toolExecution.toolCallStatuses);
+
+  // Keep /observe viewers up to date
+  useObserverFeed(state);
+
+  // longRequest hooks from hooks.yaml
+  useLongRequestHooks(state, workingDirectory);
+  // postTurn hooks, once the reply to a message is complete
+  useTurnHooks(state, workingDirectory);
+  useSessionTitle(state, dispatch);
+  const sessionRecap = useSessionRecap(state);
+
   // Chat streaming hook (sets up listeners automatically)
   useChatStreaming(
     state,
@@ -176,13 +292,18 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
       toolResultsAddedRef: toolExecution.toolResultsAddedRef,
       toolResultMessagesRef: toolExecution.toolResultMessagesRef,
       addedToolCallIdsRef: toolExecution.addedToolCallIdsRef,
+      cancelledToolCallIdsRef: toolExecution.cancelledToolCallIdsRef,
     },
     updateContextUsage,
     workingDirectory
   );
 
-  const handleSendMessage = useCallback(async (messageText: string, systemPrompt?: string) => {
-    if (!state.currentProvider || !state.currentModel) {
+  const handleSendMessage = useCallback(async (messageText: string, systemPrompt?: string, modelOverride?: ModelSelection, fromWatch?: boolean) => {
+    // A one-off override answers just this message without changing the session default
+    const provider = modelOverride?.provider ?? state.currentProvider;
+    const model = modelOverride?.model ?? state.currentModel;
+
+    if (!provider || !model) {
       dispatch({ type: 'SET_ERROR', payload: 'Please select a provider and model' });
       return;
     }
@@ -190,18 +311,25 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     console.log('[handleSendMessage] START:', {
       virtualContextSize,
       contextMode,
-      messageCount: state.messages.length,
-      provider: state.currentProvider.id,
-      model: state.currentModel.id,
+      messageCount: conversationStore.getState().messages.length,
+      provider: provider.id,
+      model: model.id,
     });
 
+    // Images added with /attach or dropped on the input box go with this message,
+    // unless a watch sent it while they wait for the user's next one
+    const images = fromWatch ? [] : takeAttachments();
     const userMessage: ChatMessage = {
       id: `user-${Date.now()}`,
       role: 'user',
       content: messageText,
       timestamp: Date.now(),
+      ...(images.length > 0 && { images }),
+      ...(fromWatch && { fromWatch }),
     };
 
+    // Read from the store, since /retry rewinds it just before calling this
+    const history = conversationStore.getState().messages;
     dispatch({ type: 'ADD_MESSAGE', payload: userMessage });
 
     const assistantMessageId = `assistant-${Date.now()}`;
@@ -210,6 +338,9 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
       role: 'assistant',
       content: '',
       timestamp: Date.now(),
+      ...(modelOverride && {
+        modelOverride: { providerId: provider.id, modelId: model.id },
+      }),
     };
 
     dispatch({ type: 'ADD_MESSAGE', payload: assistantMessage });
@@ -217,12 +348,14 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
 
     toolExecution.clearToolExecutionRefs();
 
-    const messagesWithUser = [...state.messages, userMessage];
+    let messagesWithUser = [...history, userMessage];
 
-    const systemPromptMessage = systemPrompt ? {
+    // A prompt set with /system set replaces the one selected in the input box
+    const effectiveSystemPrompt = state.sessionSystemPrompt ?? systemPrompt;
+    const systemPromptMessage = effectiveSystemPrompt ? {
       id: `system-${Date.now()}`,
       role: 'system' as const,
-      content: systemPrompt,
+      content: effectiveSystemPrompt,
       timestamp: Date.now(),
     } : null;
 
@@ -231,12 +364,12 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
       console.log('[handleSendMessage] Using virtual context size:', virtualContextSize);
     }
     if (!contextTotal) {
-      contextTotal = state.currentModel.contextLength || null;
+      contextTotal = model.contextLength || null;
       if (!contextTotal) {
         try {
           const contextResult = await window.electronAPI.chatGetContextLength({
-            provider: state.currentProvider.id,
-            model: state.currentModel.id,
+            provider: provider.id,
+            model: model.id,
           });
           if (contextResult.success && contextResult.contextLength) {
             contextTotal = contextResult.contextLength;
@@ -251,6 +384,26 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
 
     let messagesToSend: ChatMessage[];
 
+    // Summarize mode: fold the oldest turns into a summary before the window fills up
+    if (contextTotal && contextMode === 'summarize') {
+      const { messages: visible, systemPrompt: prompt } = applySummaries(messagesWithUser, systemPromptMessage);
+      const usage = estimateTokenUsage(prompt ? [prompt, ...visible] : visible);
+      const { threshold } = await readSummarizerSettings();
+      if ((usage / contextTotal) * 100 >= threshold) {
+        dispatch({ type: 'SET_STREAM_STATUS', payload: 'Summarizing earlier messages…' });
+        try {
+          messagesWithUser = (await compactContext(messagesWithUser))?.messages ?? messagesWithUser;
+        } catch (error) {
+          console.error('[handleSendMessage] Summarizing failed, falling back to truncation:', error);
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: `Could not summarize earlier messages (${error instanceof Error ? error.message : 'unknown error'}); the oldest ones will be dropped instead if needed.`,
+          });
+        }
+        dispatch({ type: 'SET_STREAM_STATUS', payload: null });
+      }
+    }
+
     if (contextTotal) {
       const contextResult = applyContextManagement(
         messagesWithUser,
@@ -298,11 +451,15 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     });
 
     try {
+      // Stream hooks are made from it as the first content arrives
+      await hookConfigManager.loadConfig();
       const result = await window.electronAPI.chatSendMessage({
-        provider: state.currentProvider.id,
-        model: state.currentModel.id,
+        provider: provider.id,
+        model: model.id,
         messages: messagesToSend,
         tools: toolRegistry.getDefinitions(),
+        projectPath: workingDirectory,
+        options: state.generationOptions,
       });
 
       if (result && !result.success && result.error) {
@@ -321,11 +478,97 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
       });
       dispatch({ type: 'END_STREAMING' });
     }
-  }, [state.currentProvider, state.currentModel, state.messages, contextMode, virtualContextSize, dispatch, applyContextManagement, toolExecution]);
+  }, [state.currentProvider, state.currentModel, state.sessionSystemPrompt, state.generationOptions, contextMode, virtualContextSize, dispatch, applyContextManagement, compactContext, toolExecution, workingDirectory]);
+
+  // /preview: the request handleSendMessage would make for this text (or
+  // handleContinue without it), opened in the pager instead of sent. Summarize
+  // mode isn't run, since that would send a request of its own.
+  const handlePreviewMessage = useCallback(async (messageText: string, systemPrompt?: string) => {
+    // "@model: question" goes to that model, as it would when sent
+    const override = messageText ? parseModelOverride(messageText) : null;
+    const selection = override ? findModelByRef(state.providers, override.modelRef) : null;
+    if (override && !selection) {
+      throw new Error(`Unknown model "${override.modelRef}"`);
+    }
+    const provider = selection?.provider ?? state.currentProvider;
+    const model = selection?.model ?? state.currentModel;
+    if (!provider || !model) {
+      throw new Error('Please select a provider and model');
+    }
+    messageText = override ? override.text : unescapeSlashMessage(messageText);
+
+    let history = state.messages;
+    let requestSystemPrompt: ChatMessage | null = null;
+    const lastMessage = history[history.length - 1];
+    if (!messageText && lastMessage?.role === 'assistant' && lastMessage.stopped) {
+      // /continue resumes a stopped reply with this request
+      history = [...history, { id: `continue-${Date.now()}`, role: 'user', content: INTERRUPTED_CONTINUE_PROMPT, timestamp: Date.now() }];
+    }
+    if (messageText) {
+      const images = getAttachments().map(({ mimeType, data, path }) => ({ mimeType, data, ...(path && { path }) }));
+      history = [...history, {
+        id: `user-${Date.now()}`,
+        role: 'user',
+        content: messageText,
+        timestamp: Date.now(),
+        ...(images.length > 0 && { images }),
+      }];
+      const effectiveSystemPrompt = state.sessionSystemPrompt ?? systemPrompt;
+      requestSystemPrompt = effectiveSystemPrompt
+        ? { id: `system-${Date.now()}`, role: 'system', content: effectiveSystemPrompt, timestamp: Date.now() }
+        : null;
+    } else if (history.length === 0) {
+      throw new Error('Usage: /preview <message>, or /preview alone to see what /continue would send');
+    }
+
+    let contextTotal = virtualContextSize || model.contextLength || null;
+    if (!contextTotal) {
+      const contextResult = await window.electronAPI.chatGetContextLength({ provider: provider.id, model: model.id });
+      if (contextResult.success && contextResult.contextLength) {
+        contextTotal = contextResult.contextLength;
+      }
+    }
+
+    let messagesToSend: ChatMessage[];
+    if (contextTotal) {
+      const contextResult = applyContextManagement(history, requestSystemPrompt, contextTotal);
+      if (contextResult.shouldHalt) {
+        throw new Error('Context usage has reached 100%, so this message would not be sent');
+      }
+      messagesToSend = contextResult.messagesToSend;
+    } else {
+      messagesToSend = requestSystemPrompt ? [requestSystemPrompt, ...history] : history;
+    }
+
+    const result = await window.electronAPI.chatPreview({
+      provider: provider.id,
+      model: model.id,
+      messages: messagesToSend,
+      tools: toolRegistry.getDefinitions(),
+      projectPath: workingDirectory,
+      options: state.generationOptions,
+    });
+    if (!result.success || !result.preview) {
+      throw new Error(result.error || 'Failed to compose the request');
+    }
+    const { url, body, leftOut } = result.preview;
+    const notes = [
+      ...(leftOut.length > 0 ? [`left out: ${leftOut.join(', ')}`] : []),
+      ...(contextMode === 'summarize' ? ['summarize mode not applied'] : []),
+    ];
+    setToolOutputPage({
+      title: `Request preview: ${provider.id}/${model.id}${notes.length > 0 ? ` (${notes.join('; ')})` : ''}`,
+      text: `POST ${url}\n\n${JSON.stringify(body, null, 2)}`,
+    });
+  }, [state.providers, state.currentProvider, state.currentModel, state.messages, state.sessionSystemPrompt, state.generationOptions, virtualContextSize, contextMode, applyContextManagement, workingDirectory]);
 
   // Message actions hook
   const messageActions = useMessageActions(state, dispatch, handleSendMessage, handleContinue);
 
+  // Watched paths prompt the model with the system prompt of the last message sent
+  const lastSystemPromptRef = useRef<string | undefined>(undefined);
+  useWatchNotifications(state, dispatch, handleSendMessage, lastSystemPromptRef, workingDirectory);
+
   // Session management hook
   const sessionManagement = useSessionManagement(
     state,
@@ -336,6 +579,8 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
 
   const handleCancelMessage = useCallback(async () => {
     console.log('Cancelling message');
+    // Tools can still be running after the model has finished its reply
+    cancelRunningToolCalls();
     try {
       await window.electronAPI.chatCancel();
     } catch (error) {
@@ -343,6 +588,353 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     }
   }, []);
 
+  // Stop generating but keep the partial response
+  const handleStopMessage = useCallback(async () => {
+    console.log('Stopping message');
+    dispatch({ type: 'STOP_STREAMING' });
+    try {
+      await window.electronAPI.chatCancel();
+    } catch (error) {
+      console.error('Failed to stop message:', error);
+    }
+  }, [dispatch]);
+
+  // Send one message to another model, e.g. "@llama3:8b: summarize this" or "/ask llama3:8b summarize this"
+  const handleAskModel = useCallback(async (modelRef: string, messageText: string, systemPrompt?: string) => {
+    const selection = findModelByRef(state.providers, modelRef);
+    if (!selection) {
+      dispatch({ type: 'SET_ERROR', payload: `Unknown model "${modelRef}"` });
+      return;
+    }
+    await handleSendMessage(messageText, systemPrompt, selection);
+  }, [state.providers, dispatch, handleSendMessage]);
+
+  const handleSetOfflineMode = useCallback(async (enabled: boolean) => {
+    dispatch({ type: 'SET_OFFLINE_MODE', payload: enabled });
+    await window.electronAPI.preferencesSet('offlineMode', enabled);
+  }, [dispatch]);
+
+  const handleSetThinking = useCallback(async (updates: Partial<ThinkingSettings>) => {
+    const settings = { ...(await readThinkingSettings()), ...updates };
+    dispatch({ type: 'SET_THINKING_SETTINGS', payload: settings });
+    await window.electronAPI.preferencesSet('thinking', settings);
+    return settings;
+  }, [dispatch]);
+
+  // Tool processes in the main process get the session's /env variables
+  useEffect(() => {
+    window.electronAPI.toolEnvSet(workingDirectory, state.sessionEnv).catch(error => {
+      console.error('Failed to update tool environment:', error);
+    });
+  }, [workingDirectory, state.sessionEnv]);
+
+  // Keep network tools out of requests while offline
+  useEffect(() => {
+    toolRegistry.setOfflineMode(state.offlineMode);
+  }, [state.offlineMode]);
+
+  const handleSetWorkspaceTrust = useCallback(async (trust: WorkspaceTrust | null) => {
+    const result = await window.electronAPI.workspaceTrustSet(workingDirectory, trust);
+    if (!result.success) {
+      throw new Error(result.error || 'Failed to save the trust decision');
+    }
+    dispatch({ type: 'SET_WORKSPACE_TRUST', payload: result.trust });
+    return result;
+  }, [workingDirectory, dispatch]);
+
+  // Tools that write files or run processes stay off until the workspace is trusted
+  useEffect(() => {
+    let cancelled = false;
+    dispatch({ type: 'SET_WORKSPACE_TRUST', payload: null });
+    window.electronAPI.workspaceTrustGet(workingDirectory).then(result => {
+      if (cancelled || !result.success) {
+        return;
+      }
+      dispatch({ type: 'SET_WORKSPACE_TRUST', payload: result.trust });
+      if (!result.trust) {
+        dispatch({ type: 'SET_NOTICE', payload: `${workingDirectory} isn't trusted yet, so file-writing, shell and MCP tools are off. /trust yes turns them on; /trust no keeps them off without this reminder.` });
+      }
+    }).catch(error => console.error('Failed to read workspace trust:', error));
+    return () => {
+      cancelled = true;
+    };
+  }, [workingDirectory, dispatch]);
+
+  useEffect(() => {
+    toolRegistry.setWorkspaceTrusted(state.workspaceTrust === 'trusted');
+  }, [state.workspaceTrust]);
+
+  // With the warmUpModel preference (/warmup on), a model is loaded as soon as it
+  // is selected so the first message doesn't wait for it
+  useEffect(() => {
+    const provider = state.currentProvider;
+    const modelId = state.currentModel?.id;
+    if (!provider || !modelId || (state.offlineMode && !isLocalProvider(provider))) {
+      return;
+    }
+    (async () => {
+      const preference = await window.electronAPI.preferencesGet('warmUpModel');
+      if (!preference.success || preference.value !== true) {
+        return;
+      }
+      const result = await window.electronAPI.chatWarmUp({ provider: provider.id, model: modelId });
+      if (!result.success) {
+        console.warn('Model warm-up failed:', result.error);
+      }
+    })();
+  }, [state.currentProvider, state.currentModel?.id, state.offlineMode]);
+
+  // /models: the picker opens immediately and fills in when the server answers
+  const [modelPicker, setModelPicker] = useState<{ provider: ProviderConfig; models: ModelConfig[] | null; error: string | null; filter?: string } | null>(null);
+
+  const handleShowModels = useCallback(async (providerRef?: string) => {
+    const needle = providerRef?.toLowerCase();
+    const provider = needle
+      ? state.providers.find(p => p.enabled && (p.id.toLowerCase() === needle || p.name.toLowerCase() === needle))
+      : state.currentProvider;
+    if (!provider) {
+      throw new Error(providerRef ? `Unknown or disabled provider: ${providerRef}` : 'No provider selected. Use /models <provider>.');
+    }
+
+    setModelPicker({ provider, models: null, error: null });
+    const result = await window.electronAPI.chatListModels({ provider: provider.id });
+    setModelPicker(prev => prev?.provider.id === provider.id
+      ? { ...prev, models: result.success ? result.models : [], error: result.success ? null : result.error || 'Failed to list models' }
+      : prev);
+  }, [state.providers, state.currentProvider]);
+
+  // /model <name>: a configured model, or the one model on the provider's
+  // server whose name contains it; with several, the picker opens on them
+  const handleSwitchModel = useCallback(async (modelRef: string): Promise<string | null> => {
+    const configured = findModelByRef(state.providers, modelRef);
+    if (configured) {
+      dispatch({ type: 'SET_PROVIDER_AND_MODEL', payload: configured });
+      return `${configured.provider.id}/${configured.model.id}`;
+    }
+
+    const slashIndex = modelRef.indexOf('/');
+    const named = slashIndex > 0
+      ? state.providers.find(p => p.enabled && p.id.toLowerCase() === modelRef.substring(0, slashIndex).toLowerCase())
+      : undefined;
+    const provider = named ?? state.currentProvider;
+    if (!provider) {
+      throw new Error('No provider selected. Use /model <provider>/<model>.');
+    }
+    const needle = (named ? modelRef.substring(slashIndex + 1) : modelRef).toLowerCase();
+
+    const result = await window.electronAPI.chatListModels({ provider: provider.id });
+    if (!result.success) {
+      throw new Error(result.error || 'Failed to list models');
+    }
+    const models = result.models.filter(m => m.type !== 'embedding');
+    const exact = models.find(m => m.id.toLowerCase() === needle || m.id.toLowerCase() === `${needle}:latest`);
+    const matches = exact ? [exact] : models.filter(m => m.id.toLowerCase().includes(needle));
+    if (matches.length === 1) {
+      dispatch({ type: 'SELECT_DISCOVERED_MODEL', payload: { providerId: provider.id, model: matches[0] } });
+      return `${provider.id}/${matches[0].id}`;
+    }
+    setModelPicker({ provider, models: result.models, error: null, filter: matches.length > 1 ? needle : undefined });
+    return null;
+  }, [state.providers, state.currentProvider, dispatch]);
+
+  const handleSelectModel = useCallback((model: ModelConfig) => {
+    if (!modelPicker) {
+      return;
+    }
+    dispatch({ type: 'SELECT_DISCOVERED_MODEL', payload: { providerId: modelPicker.provider.id, model } });
+    setModelPicker(null);
+  }, [modelPicker, dispatch]);
+
+  // /context compact: summarize now regardless of the context mode
+  const handleCompactContext = useCallback(async () => {
+    const result = await compactContext(state.messages);
+    return result?.summarizedCount ?? 0;
+  }, [state.messages, compactContext]);
+
+  // /diff last: ask again for the latest answer, optionally from another model, and compare
+  const [answerDiff, setAnswerDiff] = useState<AnswerDiffState | null>(null);
+
+  const handleDiffLast = useCallback(async (modelRef?: string, systemPrompt?: string) => {
+    let answerIndex = state.messages.length - 1;
+    while (answerIndex >= 0 && !(state.messages[answerIndex].role === 'assistant' && state.messages[answerIndex].content)) {
+      answerIndex--;
+    }
+    let promptIndex = answerIndex - 1;
+    while (promptIndex >= 0 && state.messages[promptIndex].role !== 'user') {
+      promptIndex--;
+    }
+    if (answerIndex < 0 || promptIndex < 0) {
+      throw new Error('There is no answered prompt to re-run yet');
+    }
+    const answer = state.messages[answerIndex];
+
+    const answeredBy = answer.modelOverride
+      ? findModelByRef(state.providers, `${answer.modelOverride.providerId}/${answer.modelOverride.modelId}`)
+      : state.currentProvider && state.currentModel
+      ? { provider: state.currentProvider, model: state.currentModel }
+      : null;
+    const selection = modelRef ? findModelByRef(state.providers, modelRef) : answeredBy;
+    if (!selection?.model) {
+      throw new Error(modelRef ? `Unknown model "${modelRef}"` : 'Please select a provider and model');
+    }
+    const label = (provider: ProviderConfig, model: ModelConfig) => `${provider.id}/${model.id}`;
+
+    setAnswerDiff({
+      previous: {
+        label: answeredBy?.model ? label(answeredBy.provider, answeredBy.model) : 'previous answer',
+        content: answer.content,
+      },
+      rerun: { label: label(selection.provider, selection.model), content: null, error: null },
+    });
+
+    // The re-run is a plain completion: tool calls and their results are left out
+    const effectiveSystemPrompt = state.sessionSystemPrompt ?? systemPrompt;
+    const systemPromptMessage: ChatMessage | null = effectiveSystemPrompt
+      ? { id: `system-${Date.now()}`, role: 'system', content: effectiveSystemPrompt, timestamp: Date.now() }
+      : null;
+    const { messages, systemPrompt: prompt } = applySummaries(state.messages.slice(0, promptIndex + 1), systemPromptMessage);
+    const history = (prompt ? [prompt, ...messages] : messages)
+      .filter((m): m is ChatMessage & { role: 'system' | 'user' | 'assistant' } => m.role !== 'tool' && !!m.content)
+      .map(m => ({ role: m.role, content: m.content }));
+
+    const result = await window.electronAPI.chatComplete({
+      provider: selection.provider.id,
+      model: selection.model.id,
+      messages: history,
+    });
+    setAnswerDiff(prev => prev && {
+      ...prev,
+      rerun: {
+        ...prev.rerun,
+        content: result.success ? result.content : null,
+        error: result.success ? null : result.error || 'Re-running the prompt failed',
+      },
+    });
+  }, [state.messages, state.sessionSystemPrompt, state.providers, state.currentProvider, state.currentModel]);
+
+  // /retry and /edit: drop the last user message and everything after it,
+  // putting back the images it had so they go with it again
+  const rewindToLastPrompt = useCallback((): ChatMessage => {
+    const messages = conversationStore.getState().messages;
+    const prompt = [...messages].reverse().find(m => m.role === 'user');
+    if (!prompt) {
+      throw new Error('There is no message to go back to yet');
+    }
+    dispatch({ type: 'REWIND_TO_MESSAGE', payload: prompt.id });
+    clearAttachments();
+    (prompt.images || []).forEach((image, i) => {
+      if (image.data) {
+        addAttachment({ ...image, data: image.data, name: `image-${i + 1}` });
+      }
+    });
+    return prompt;
+  }, [dispatch]);
+
+  const handleRetryLast = useCallback(async (systemPrompt?: string) => {
+    const messages = conversationStore.getState().messages;
+    const prompt = rewindToLastPrompt();
+    // A reply from a one-off model is asked of that model again
+    const reply = messages.slice(messages.indexOf(prompt) + 1).find(m => m.role === 'assistant');
+    const override = reply?.modelOverride
+      ? findModelByRef(state.providers, `${reply.modelOverride.providerId}/${reply.modelOverride.modelId}`)
+      : null;
+    await handleSendMessage(prompt.content, systemPrompt, override?.model ? { provider: override.provider, model: override.model } : undefined);
+  }, [state.providers, rewindToLastPrompt, handleSendMessage]);
+
+  const handleEditLast = useCallback(() => {
+    const prompt = rewindToLastPrompt();
+    // After the input box has cleared the /edit command itself
+    setTimeout(() => setInputDraft(prompt.content), 0);
+  }, [rewindToLastPrompt]);
+
+  const slashCommandHandlers = useMemo(() => ({
+    handleContinue,
+    handleSendMessage: (messageText: string, systemPrompt?: string) => handleSendMessage(messageText, systemPrompt),
+    handleAskModel,
+    handleSetOfflineMode,
+    handleSetThinking,
+    handleShowModels,
+    handleSwitchModel,
+    handleCompactContext,
+    getContextMode: () => contextMode,
+    handleLoadSession: loadSession,
+    handleDiffLast,
+    handleSetWorkspaceTrust,
+    handlePreviewMessage,
+    handleRetryLast,
+    handleEditLast,
+  }), [handleContinue, handleSendMessage, handleAskModel, handleSetOfflineMode, handleSetThinking, handleShowModels, handleSwitchModel, handleCompactContext, contextMode, loadSession, handleDiffLast, handleSetWorkspaceTrust, handlePreviewMessage, handleRetryLast, handleEditLast]);
+
+  const { runCommand } = useSlashCommands(state, dispatch, slashCommandHandlers, workingDirectory);
+
+  // /snip waits here for confirmation before overwriting files
+  const pendingSnippets = usePendingSnippets();
+  const handleOverwriteSnippets = useCallback(async () => {
+    if (!pendingSnippets) return;
+    try {
+      const written = await writeSnippets(pendingSnippets.files, pendingSnippets.projectPath, true);
+      dispatch({ type: 'SET_NOTICE', payload: describeSavedSnippets(written ?? []) });
+    } catch (error) {
+      setPendingSnippets(null);
+      dispatch({ type: 'SET_ERROR', payload: error instanceof Error ? error.message : 'Failed to write the code blocks' });
+    }
+  }, [pendingSnippets, dispatch]);
+
+  const toolOutputPage = useToolOutputPage();
+
+  // /apply writes through the write tool, so its permission and the workspace trust apply
+  const pendingApply = usePendingApply();
+  const handleApplyEdit = useCallback(async () => {
+    if (!pendingApply) return;
+    try {
+      if (toolConfigManager.getConfig('write', 'ask').permission === 'deny') {
+        throw new Error('The write tool is set to deny, so /apply cannot change files');
+      }
+      const result = await toolRegistry.execute('write', {
+        file_path: pendingApply.path,
+        content: pendingApply.newContent,
+      }, pendingApply.projectPath) as { success?: boolean; error?: string };
+      if (!result?.success) {
+        throw new Error(result?.error || `Failed to write ${pendingApply.path}`);
+      }
+      dispatch({ type: 'SET_NOTICE', payload: `Applied the change to ${pendingApply.path}` });
+    } catch (error) {
+      dispatch({ type: 'SET_ERROR', payload: error instanceof Error ? error.message : 'Failed to apply the change' });
+    }
+    setPendingApply(null);
+  }, [pendingApply, dispatch]);
+
+  const lastSubmitRef = useRef<{ text: string; at: number } | null>(null);
+  const [duplicateSend, setDuplicateSend] = useState<{ text: string; systemPrompt?: string } | null>(null);
+
+  const handleSubmit = useCallback(async (messageText: string, systemPrompt?: string, force = false) => {
+    const now = Date.now();
+    const lastSubmit = lastSubmitRef.current;
+    if (!force && lastSubmit && lastSubmit.text === messageText && now - lastSubmit.at < DUPLICATE_SEND_WINDOW_MS) {
+      console.log('Ignoring duplicate send of the same message');
+      setDuplicateSend({ text: messageText, systemPrompt });
+      return;
+    }
+    lastSubmitRef.current = { text: messageText, at: now };
+    lastSystemPromptRef.current = systemPrompt;
+    setDuplicateSend(null);
+
+    if (await runCommand(messageText, systemPrompt)) {
+      return;
+    }
+
+    // Don't talk over the next question
+    speechManager.stop();
+
+    const override = parseModelOverride(messageText);
+    if (override) {
+      await handleAskModel(override.modelRef, override.text, systemPrompt);
+      return;
+    }
+
+    await handleSendMessage(unescapeSlashMessage(messageText), systemPrompt);
+  }, [runCommand, handleAskModel, handleSendMessage]);
+
   const exportChatState = useCallback(() => {
     const debugInfo = {
       timestamp: new Date().toISOString(),
@@ -362,7 +954,10 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
 
     const formatted = yaml.dump(debugInfo, { indent: 2, lineWidth: -1 });
 
-    navigator.clipboard.writeText(formatted).then(() => {
+    window.electronAPI.clipboardWriteText(formatted).then((result) => {
+      if (!result.success) {
+        throw new Error(result.error || 'Clipboard write failed');
+      }
       console.log('Chat state copied to clipboard!');
       alert('Chat state copied to clipboard!');
     }).catch(err => {
@@ -377,7 +972,12 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
   // Load providers and home directory on mount, and initialize MCP
   useEffect(() => {
     loadProviders();
+    loadOfflineMode();
+    loadShowStats();
+    loadSafeMode();
+    loadThinkingSettings();
     loadHomeDir();
+    speechManager.loadSettings();
 
     toolConfigManager.loadConfigs();
 
@@ -388,6 +988,9 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     }
   }, [workingDirectory]);
 
+  // Bumped by the thinking shortcut; MessageList toggles the pane of messageId
+  const [thinkingToggle, setThinkingToggle] = useState<{ messageId: string | null; count: number }>({ messageId: null, count: 0 });
+
   // Global keyboard shortcuts
   useEffect(() => {
     const isMac = navigator.platform.toUpperCase().indexOf('MAC') >= 0 || navigator.userAgent.toUpperCase().indexOf('MAC') >= 0;
@@ -395,6 +998,15 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     const handleGlobalKeyDown = (e: globalThis.KeyboardEvent) => {
       const modifierKey = isMac ? e.metaKey : e.ctrlKey;
 
+      // Keys bound to commands in the keyBindings preference come first
+      const bound = Object.entries(getKeyBindings().commands).find(([key]) => matchesKey(key, e, isMac));
+      if (bound && !(e.target as HTMLElement).closest?.('[role="dialog"]')) {
+        e.preventDefault();
+        e.stopPropagation();
+        runCommand(bound[1]);
+        return;
+      }
+
       if (modifierKey && e.key === 'c' && !state.isLoading) {
         const selection = window.getSelection();
         const hasSelection = selection && selection.toString().length > 0;
@@ -410,11 +1022,30 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
         messageActions.handleRegenerate();
       }
 
+      // Same as /copy; works mid-stream and copies the last finished response
+      if (modifierKey && !e.shiftKey && e.key.toLowerCase() === 'y') {
+        e.preventDefault();
+        runCommand('/copy');
+        return;
+      }
+
       if (modifierKey && e.key === ',') {
         e.preventDefault();
         onOpenSettings();
       }
 
+      // Works mid-stream, unlike most shortcuts here
+      if (modifierKey && e.shiftKey && e.key.toLowerCase() === 't') {
+        e.preventDefault();
+        e.stopPropagation();
+        const target = state.streamingMessageId
+          || [...state.messages].reverse().find(m => m.role === 'assistant' && m.thinking)?.id;
+        if (target) {
+          setThinkingToggle(prev => ({ messageId: target, count: prev.count + 1 }));
+        }
+        return;
+      }
+
       if (modifierKey && e.key === 't' && !state.isLoading) {
         e.preventDefault();
         e.stopPropagation();
@@ -427,7 +1058,7 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
     return () => {
       document.removeEventListener('keydown', handleGlobalKeyDown);
     };
-  }, [state.isLoading, handleContinue, messageActions, onOpenSettings, sessionManagement]);
+  }, [state.isLoading, state.streamingMessageId, state.messages, handleContinue, messageActions, onOpenSettings, sessionManagement, runCommand]);
 
   // Update context usage when relevant state changes
   useEffect(() => {
@@ -463,6 +1094,7 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
       display: 'flex',
       height: '100%',
       width: '100%',
+      position: 'relative', // For the tools panel in the compact layout
       backgroundColor: '#1e1e2e',
       overflow: 'hidden',
     }}>
@@ -509,9 +1141,39 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
           onDismiss={() => dispatch({ type: 'SET_ERROR', payload: null })}
         />
 
+        <NoticeDisplay
+          notice={state.notice}
+          onDismiss={() => dispatch({ type: 'SET_NOTICE', payload: null })}
+        />
+
+        <NoticeDisplay
+          notice={duplicateSend ? 'Ignored a duplicate of the message you just sent.' : null}
+          onDismiss={() => setDuplicateSend(null)}
+          action={duplicateSend ? {
+            label: 'Send anyway',
+            onClick: () => handleSubmit(duplicateSend.text, duplicateSend.systemPrompt, true),
+          } : undefined}
+        />
+
+        <NoticeDisplay
+          notice={pendingSnippets ? `${pendingSnippets.existing.join(', ')} already ${pendingSnippets.existing.length === 1 ? 'exists' : 'exist'}. Overwrite with the code blocks from /snip?` : null}
+          onDismiss={() => setPendingSnippets(null)}
+          action={pendingSnippets ? { label: 'Overwrite', onClick: handleOverwriteSnippets } : undefined}
+        />
+
+        <NoticeDisplay
+          notice={sessionRecap?.text ?? null}
+          onDismiss={clearSessionRecap}
+        />
+
         <MessageList
           messages={state.messages}
+          thinkingDisplay={state.thinking.display}
+          thinkingRenderLimit={state.thinking.renderLimit}
+          thinkingToggle={thinkingToggle}
+          showStats={state.showStats}
           isLoading={state.isLoading}
+          streamStatus={state.streamStatus}
           pendingPermissions={toolExecution.pendingPermissions}
           toolCallStatuses={toolExecution.toolCallStatuses}
           onEditMessage={messageActions.handleEditMessage}
@@ -519,11 +1181,25 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
           onRegenerate={messageActions.handleRegenerate}
           onContinue={handleContinue}
           onFork={(messageId) => messageActions.handleFork(messageId, workingDirectory, loadSession)}
+          onMessageCommand={runCommand}
         />
 
+        <DebugPane />
+
+        <EventsPane />
+
         <InputBox
-          onSendMessage={handleSendMessage}
+          onSendMessage={handleSubmit}
           onCancelMessage={handleCancelMessage}
+          onStopMessage={handleStopMessage}
+          offlineMode={state.offlineMode}
+          onToggleOfflineMode={() => handleSetOfflineMode(!state.offlineMode)}
+          workspaceTrusted={state.workspaceTrust === 'trusted'}
+          onTrustWorkspace={() => runCommand('/trust yes')}
+          onVoiceError={(message) => dispatch({ type: 'SET_ERROR', payload: message })}
+          onAttachError={(message) => dispatch({ type: 'SET_ERROR', payload: message })}
+          onPromptChange={(promptName) => dispatch({ type: 'SET_ACTIVE_PROMPT', payload: promptName })}
+          sessionPromptOverride={state.sessionSystemPrompt !== null}
           isLoading={state.isLoading}
           currentProvider={state.currentProvider}
           currentModel={state.currentModel}
@@ -537,9 +1213,31 @@ export function ChatContainer({ workingDirectory, onOpenSettings, focusTrigger }
           workingDirectory={workingDirectory}
           virtualContextSize={virtualContextSize}
           onVirtualContextSizeChange={setVirtualContextSize}
+          onContextModeChange={setContextMode}
         />
       </Box>
 
+      <ModelPicker
+        provider={modelPicker?.provider ?? null}
+        models={modelPicker?.models ?? null}
+        error={modelPicker?.error ?? null}
+        initialFilter={modelPicker?.filter}
+        currentModelId={state.currentProvider?.id === modelPicker?.provider.id ? state.currentModel?.id : undefined}
+        onSelect={handleSelectModel}
+        onClose={() => setModelPicker(null)}
+      />
+
+      <AnswerDiff diff={answerDiff} onClose={() => setAnswerDiff(null)} />
+
+      <ApplyEditDialog pending={pendingApply} onApply={handleApplyEdit} onClose={() => setPendingApply(null)} />
+
+      <OutputPager
+        open={!!toolOutputPage}
+        title={toolOutputPage?.title ?? ''}
+        text={toolOutputPage?.text ?? ''}
+        onClose={() => setToolOutputPage(null)}
+      />
+
       {/* Tools Panel on the right */}
       <ToolsPanel
         collapsed={toolsPanelCollapsed}
@@ -1,6 +1,7 @@
 import { Box, Typography, IconButton, Badge, TextField } from '@mui/material';
 import SegmentIcon from '@mui/icons-material/Segment';
 import { Settings, Download, Wrench, FilePlus } from 'lucide-react';
+import { useCompactLayout } from '../../hooks/useCompactLayout';
 
 interface ChatHeaderProps {
   displayPath: string;
@@ -28,22 +29,39 @@ export function ChatHeader({
   onToggleToolsPanel,
 }: ChatHeaderProps) {
   const isMac = navigator.platform.toUpperCase().indexOf('MAC') >= 0;
+  const compact = useCompactLayout();
 
   return (
     <Box sx={{
       display: 'flex',
       alignItems: 'center',
       justifyContent: 'space-between',
-      p: 2,
-      borderBottom: '1px solid rgba(205, 214, 244, 0.1)',
+      gap: 1,
+      p: compact ? 0.5 : 2,
+      borderBottom: compact ? 'none' : '1px solid rgba(205, 214, 244, 0.1)',
       flexShrink: 0,
     }}>
-      <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
-        <Typography variant="body2" sx={{ color: 'rgba(205, 214, 244, 0.6)', fontFamily: 'monospace' }}>
+      {/* A long path is cut short rather than pushing the buttons out */}
+      <Box sx={{ display: 'flex', alignItems: 'center', gap: 1, minWidth: 0 }}>
+        <Typography
+          variant="body2"
+          noWrap
+          title={displayPath}
+          sx={{ color: 'rgba(205, 214, 244, 0.6)', fontFamily: 'monospace' }}
+        >
           {displayPath}
         </Typography>
       </Box>
-      <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
+      {/* In the compact layout the session name gives way first, so the buttons
+          (the tools panel toggle last) stay on screen down to about 200px */}
+      <Box sx={{
+        display: 'flex',
+        alignItems: 'center',
+        gap: compact ? 0 : 1,
+        flexShrink: compact ? 1 : 0,
+        minWidth: 0,
+        '& .MuiIconButton-root': { flexShrink: 0 },
+      }}>
         <TextField
           value={currentSessionName}
           onChange={(e) => onSessionNameChange(e.target.value)}
@@ -55,7 +73,7 @@ export function ChatHeader({
             sx: {
               color: '#cdd6f4',
               fontSize: '0.875rem',
-              maxWidth: 200,
+              maxWidth: compact ? 120 : 200,
               '& input': {
                 padding: '4px 8px',
                 textOverflow: 'ellipsis',
@@ -67,6 +85,8 @@ export function ChatHeader({
             }
           }}
           sx={{
+            minWidth: compact ? 48 : undefined,
+            flexShrink: 1,
             '& .MuiInput-root': {
               backgroundColor: 'rgba(205, 214, 244, 0.05)',
               borderRadius: '4px',
@@ -111,18 +131,20 @@ export function ChatHeader({
         >
           <SegmentIcon sx={{ fontSize: 18 }} />
         </IconButton>
-        <IconButton
-          onClick={onExportChatState}
-          title="Export chat state to clipboard"
-          sx={{
-            color: '#cdd6f4',
-            '&:hover': {
-              backgroundColor: 'rgba(205, 214, 244, 0.1)',
-            },
-          }}
-        >
-          <Download size={18} />
-        </IconButton>
+        {!compact && (
+          <IconButton
+            onClick={onExportChatState}
+            title="Export chat state to clipboard"
+            sx={{
+              color: '#cdd6f4',
+              '&:hover': {
+                backgroundColor: 'rgba(205, 214, 244, 0.1)',
+              },
+            }}
+          >
+            <Download size={18} />
+          </IconButton>
+        )}
         <IconButton
           onClick={onOpenSettings}
           title={`Settings (${isMac ? '⌘' : 'Ctrl'}+,)`}
@@ -0,0 +1,80 @@
+import { Box, IconButton, Typography } from '@mui/material';
+import { X } from 'lucide-react';
+import { useEffect, useRef } from 'react';
+import { setDebugPane, useDebugLog, useDebugPane, type DebugLevel } from '../../hooks/useDebugLog';
+import type { LogEntry } from '../../types/chat';
+
+const LEVEL_ORDER: DebugLevel[] = ['debug', 'info', 'warn', 'error'];
+
+const LEVEL_COLORS: Record<DebugLevel, string> = {
+  debug: 'rgba(205, 214, 244, 0.5)',
+  info: '#89b4fa',
+  warn: '#f9e2af',
+  error: '#f38ba8',
+};
+
+function formatFields(fields: LogEntry['fields']): string {
+  if (!fields) {
+    return '';
+  }
+  return Object.entries(fields)
+    .map(([key, value]) => `${key}=${typeof value === 'string' ? value : JSON.stringify(value)}`)
+    .join(' ');
+}
+
+// Main-process log entries as they happen, opened with /debug
+export function DebugPane() {
+  const { open, level } = useDebugPane();
+  const { entries, file } = useDebugLog(open);
+  const scrollRef = useRef<HTMLDivElement>(null);
+  const minimum = LEVEL_ORDER.indexOf(level);
+  const shown = entries.filter(entry => LEVEL_ORDER.indexOf(entry.level) >= minimum);
+
+  // Stay at the newest entry unless scrolled up to read older ones
+  useEffect(() => {
+    const el = scrollRef.current;
+    if (el && el.scrollHeight - el.scrollTop - el.clientHeight < 60) {
+      el.scrollTop = el.scrollHeight;
+    }
+  }, [shown.length]);
+
+  if (!open) {
+    return null;
+  }
+
+  return (
+    <Box sx={{
+      borderTop: '1px solid rgba(108, 112, 134, 0.4)',
+      backgroundColor: '#181825',
+      display: 'flex',
+      flexDirection: 'column',
+      height: '30vh',
+      minHeight: 120,
+    }}>
+      <Box sx={{ display: 'flex', alignItems: 'center', gap: 1, px: 2, py: 0.5, borderBottom: '1px solid rgba(108, 112, 134, 0.2)' }}>
+        <Typography variant="caption" sx={{ color: '#cdd6f4', fontWeight: 500 }}>
+          Debug log
+        </Typography>
+        <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.5)', flexGrow: 1, overflow: 'hidden', textOverflow: 'ellipsis', whiteSpace: 'nowrap' }}>
+          {level} and above · {shown.length} entries{file ? ` · ${file}` : ''}
+        </Typography>
+        <IconButton size="small" title="Close (/debug off)" onClick={() => setDebugPane({ open: false })} sx={{ color: '#cdd6f4' }}>
+          <X size={14} />
+        </IconButton>
+      </Box>
+      <Box ref={scrollRef} sx={{ flexGrow: 1, overflowY: 'auto', px: 2, py: 0.5, fontFamily: 'monospace', fontSize: '11px', lineHeight: 1.5 }}>
+        {shown.map((entry, i) => (
+          <Box key={`${entry.time}-${i}`} sx={{ whiteSpace: 'pre-wrap', wordBreak: 'break-word', color: '#cdd6f4' }}>
+            <Box component="span" sx={{ color: 'rgba(205, 214, 244, 0.4)' }}>{entry.time.substring(11, 23)} </Box>
+            <Box component="span" sx={{ color: LEVEL_COLORS[entry.level] }}>{entry.level.toUpperCase().padEnd(5)} </Box>
+            <Box component="span" sx={{ color: '#cba6f7' }}>{entry.scope} </Box>
+            {entry.message}
+            {entry.fields && (
+              <Box component="span" sx={{ color: 'rgba(205, 214, 244, 0.6)' }}> {formatFields(entry.fields)}</Box>
+            )}
+          </Box>
+        ))}
+      </Box>
+    </Box>
+  );
+}
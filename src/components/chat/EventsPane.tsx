@@ -0,0 +1,87 @@
+import { Box, IconButton, Typography } from '@mui/material';
+import { X } from 'lucide-react';
+import { useEffect, useRef } from 'react';
+import { setEventsPane, useEngineEvents, useEventsPane } from '../../hooks/useEngineEvents';
+import type { EngineEventKind, LogEntry } from '../../types/chat';
+
+const KIND_COLORS: Record<EngineEventKind, string> = {
+  connection: '#89b4fa',
+  retry: '#f9e2af',
+  cache: '#a6e3a1',
+  hook: '#cba6f7',
+  'rate-limit': '#fab387',
+  model: '#94e2d5',
+};
+
+const LEVEL_COLORS: Partial<Record<LogEntry['level'], string>> = {
+  warn: '#f9e2af',
+  error: '#f38ba8',
+};
+
+function formatFields(fields: LogEntry['fields']): string {
+  if (!fields) {
+    return '';
+  }
+  return Object.entries(fields)
+    .map(([key, value]) => `${key}=${typeof value === 'string' ? value : JSON.stringify(value)}`)
+    .join(' ');
+}
+
+// What the engine did (connections, retries, cache hits, hooks, rate limits),
+// without the rest of the debug log; opened with /events
+export function EventsPane() {
+  const { open, kind } = useEventsPane();
+  const events = useEngineEvents(open);
+  const scrollRef = useRef<HTMLDivElement>(null);
+  const shown = kind ? events.filter(event => event.event === kind) : events;
+
+  // Stay at the newest event unless scrolled up to read older ones
+  useEffect(() => {
+    const el = scrollRef.current;
+    if (el && el.scrollHeight - el.scrollTop - el.clientHeight < 60) {
+      el.scrollTop = el.scrollHeight;
+    }
+  }, [shown.length]);
+
+  if (!open) {
+    return null;
+  }
+
+  return (
+    <Box sx={{
+      borderTop: '1px solid rgba(108, 112, 134, 0.4)',
+      backgroundColor: '#181825',
+      display: 'flex',
+      flexDirection: 'column',
+      height: '25vh',
+      minHeight: 100,
+    }}>
+      <Box sx={{ display: 'flex', alignItems: 'center', gap: 1, px: 2, py: 0.5, borderBottom: '1px solid rgba(108, 112, 134, 0.2)' }}>
+        <Typography variant="caption" sx={{ color: '#cdd6f4', fontWeight: 500 }}>
+          Engine events
+        </Typography>
+        <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.5)', flexGrow: 1 }}>
+          {kind ?? 'all kinds'} · {shown.length} events
+        </Typography>
+        <IconButton size="small" title="Close (/events off)" onClick={() => setEventsPane({ open: false })} sx={{ color: '#cdd6f4' }}>
+          <X size={14} />
+        </IconButton>
+      </Box>
+      <Box ref={scrollRef} sx={{ flexGrow: 1, overflowY: 'auto', px: 2, py: 0.5, fontFamily: 'monospace', fontSize: '11px', lineHeight: 1.5 }}>
+        {shown.length === 0 && (
+          <Box sx={{ color: 'rgba(205, 214, 244, 0.5)' }}>Nothing yet</Box>
+        )}
+        {shown.map((event, i) => (
+          <Box key={`${event.time}-${i}`} sx={{ whiteSpace: 'pre-wrap', wordBreak: 'break-word', color: LEVEL_COLORS[event.level] ?? '#cdd6f4' }}>
+            <Box component="span" sx={{ color: 'rgba(205, 214, 244, 0.4)' }}>{event.time.substring(11, 19)} </Box>
+            <Box component="span" sx={{ color: event.event ? KIND_COLORS[event.event] : '#cdd6f4' }}>{(event.event ?? '').padEnd(10)} </Box>
+            {event.message}
+            {event.fields && (
+              <Box component="span" sx={{ color: 'rgba(205, 214, 244, 0.6)' }}> {formatFields(event.fields)}</Box>
+            )}
+          </Box>
+        ))}
+      </Box>
+    </Box>
+  );
+}
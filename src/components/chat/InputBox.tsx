@@ -1,11 +1,23 @@
 import { Box, TextField, Select, MenuItem, FormControl, ListSubheader, Typography } from '@mui/material';
-import { FileText, Settings as SettingsIcon } from 'lucide-react';
-import { useState, useEffect, useRef } from 'react';
-import type { KeyboardEvent } from 'react';
-import type { ProviderConfig, ModelConfig } from '../../types/chat';
+import { FileText, Settings as SettingsIcon, WifiOff, Mic, ShieldAlert, Image as ImageIcon, X } from 'lucide-react';
+import { useState, useEffect, useRef, useCallback } from 'react';
+import type { ClipboardEvent, DragEvent, KeyboardEvent } from 'react';
+import { CONTEXT_MODES, type ContextMode, type ProviderConfig, type ModelConfig } from '../../types/chat';
+import { isLocalProvider } from '../../utils/modelUtils';
+import { voiceInput, type VoiceInputState } from '../../speech';
+import { useInputHistory } from '../../hooks/useInputHistory';
+import { attachFile, removeAttachment, useAttachments } from '../../hooks/useAttachments';
+import { useInputDraft } from '../../hooks/useInputDraft';
+import { scrollHistory, setVimMode, useVimMode } from '../../hooks/useVimMode';
+import { startMessageSelection, useMessageSelection } from '../../hooks/useMessageSelection';
+import { useFindQuery } from '../../hooks/useFindInSession';
+import { useKeyBindings } from '../../hooks/useKeyBindings';
+import { formatKey, matchesKey } from '../../utils/keyBindings';
+import { leaveInsert, normalModeKey } from '../../utils/vimKeys';
+import { useCompactLayout } from '../../hooks/useCompactLayout';
 
-// Helper function to format context usage
-function formatContextUsage(used: number, total: number): string {
+// Helper function to format context usage; compact is just the percentage
+function formatContextUsage(used: number, total: number, compact = false): string {
   const formatNumber = (n: number): string => {
     if (n >= 1000000) {
       return `${(n / 1000000).toFixed(1)}M`;
@@ -18,6 +30,9 @@ function formatContextUsage(used: number, total: number): string {
   const usedFormatted = formatNumber(used);
   const totalFormatted = formatNumber(total);
   const percentage = ((used / total) * 100).toFixed(1);
+  if (compact) {
+    return `${percentage}%`;
+  }
 
   return `${usedFormatted}/${totalFormatted} (${percentage}%)`;
 }
@@ -25,6 +40,15 @@ function formatContextUsage(used: number, total: number): string {
 interface InputBoxProps {
   onSendMessage: (message: string, systemPrompt?: string) => void;
   onCancelMessage: () => void;
+  onStopMessage: () => void;
+  onPromptChange?: (promptName: string | null) => void;
+  sessionPromptOverride?: boolean; // The session has its own prompt from /system set
+  offlineMode?: boolean;
+  onToggleOfflineMode?: () => void;
+  workspaceTrusted?: boolean;
+  onTrustWorkspace?: () => void;
+  onVoiceError?: (message: string) => void;
+  onAttachError?: (message: string) => void;
   isLoading: boolean;
   currentProvider: ProviderConfig | null;
   currentModel: ModelConfig | null;
@@ -41,11 +65,21 @@ interface InputBoxProps {
   workingDirectory: string;
   virtualContextSize: number | null;
   onVirtualContextSizeChange: (size: number | null) => void;
+  onContextModeChange?: (mode: ContextMode) => void;
 }
 
 export function InputBox({
   onSendMessage,
   onCancelMessage,
+  onStopMessage,
+  onPromptChange,
+  sessionPromptOverride = false,
+  offlineMode = false,
+  onToggleOfflineMode,
+  workspaceTrusted = true,
+  onTrustWorkspace,
+  onVoiceError,
+  onAttachError,
   isLoading,
   currentProvider,
   currentModel,
@@ -57,14 +91,21 @@ export function InputBox({
   workingDirectory,
   virtualContextSize,
   onVirtualContextSizeChange,
+  onContextModeChange,
 }: InputBoxProps) {
   const [input, setInput] = useState('');
   const [prompts, setPrompts] = useState<string[]>([]);
   const [selectedPrompt, setSelectedPrompt] = useState<string>('');
-  const [contextMode, setContextMode] = useState<'rolling' | 'halt'>('rolling');
+  const [contextMode, setContextMode] = useState<ContextMode>('rolling');
   const [isEditingContextSize, setIsEditingContextSize] = useState(false);
+  const compact = useCompactLayout();
   const contextSizeInputRef = useRef<HTMLInputElement>(null);
   const inputRef = useRef<HTMLInputElement>(null);
+  const history = useInputHistory();
+  // Ctrl+R reverse search: what was typed, the draft to restore, and the match shown
+  const [search, setSearch] = useState<{ query: string; draft: string; match: { entry: string; index: number } | null } | null>(null);
+  const vim = useVimMode();
+  const vimPendingRef = useRef('');
 
   useEffect(() => {
     loadPrompts();
@@ -86,7 +127,7 @@ export function InputBox({
     try {
       const result = await window.electronAPI.projectContextModeRead(workingDirectory);
       if (result.success) {
-        setContextMode(result.mode === 'halt' ? 'halt' : 'rolling');
+        setContextMode(CONTEXT_MODES.includes(result.mode as ContextMode) ? result.mode as ContextMode : 'rolling');
       }
     } catch (error) {
       console.error('Failed to load context mode:', error);
@@ -95,8 +136,9 @@ export function InputBox({
     }
   };
 
-  const handleContextModeChange = async (mode: 'rolling' | 'halt') => {
+  const handleContextModeChange = async (mode: ContextMode) => {
     setContextMode(mode);
+    onContextModeChange?.(mode);
     
     if (workingDirectory) {
       try {
@@ -107,6 +149,9 @@ export function InputBox({
     }
   };
 
+  const keys = useKeyBindings();
+  const isMac = navigator.platform.toUpperCase().indexOf('MAC') >= 0;
+
   // Focus input on mount (when chat view is shown)
   useEffect(() => {
     // Use setTimeout to ensure the input is fully rendered and ready
@@ -119,6 +164,22 @@ export function InputBox({
     return () => clearTimeout(timeoutId);
   }, []);
 
+  // Back to typing when message selection ends, unless it ended by editing a message
+  const messageSelection = useMessageSelection();
+  const findQuery = useFindQuery();
+  const wasSelectingRef = useRef(false);
+  useEffect(() => {
+    if (wasSelectingRef.current && !messageSelection.active) {
+      setTimeout(() => {
+        const focused = document.activeElement;
+        if (!focused || focused === document.body) {
+          inputRef.current?.focus();
+        }
+      }, 0);
+    }
+    wasSelectingRef.current = messageSelection.active;
+  }, [messageSelection.active]);
+
   // Focus input when loading state changes (i.e., when AI finishes responding)
   useEffect(() => {
     if (!isLoading && inputRef.current) {
@@ -126,6 +187,59 @@ export function InputBox({
     }
   }, [isLoading]);
 
+  // Dictated text is appended to the draft so it can be reviewed before sending
+  const [voiceState, setVoiceState] = useState<VoiceInputState>(voiceInput.getState());
+  useEffect(() => {
+    const unsubscribeState = voiceInput.subscribe(setVoiceState);
+    const unsubscribeTranscript = voiceInput.onTranscript((text) => {
+      setInput(prev => (prev.trim() ? `${prev.trimEnd()} ${text}` : text));
+      setTimeout(() => inputRef.current?.focus(), 0);
+    });
+    return () => {
+      unsubscribeState();
+      unsubscribeTranscript();
+    };
+  }, []);
+
+  // A /prompt template expanded for review replaces the draft
+  useInputDraft(useCallback((text: string) => {
+    setInput(text);
+    setTimeout(() => inputRef.current?.focus(), 0);
+  }, []));
+
+  const toggleVoice = () => {
+    voiceInput.toggle().catch(error => {
+      onVoiceError?.(error instanceof Error ? error.message : 'Voice input failed');
+    });
+  };
+
+  // Images dropped or pasted into the input go with the next message, like /attach
+  const attachments = useAttachments();
+  const attachFiles = (files: File[]) => {
+    for (const file of files) {
+      attachFile(file).catch(error => {
+        onAttachError?.(error instanceof Error ? error.message : 'Failed to attach image');
+      });
+    }
+  };
+
+  const handleDrop = (e: DragEvent) => {
+    const files = Array.from(e.dataTransfer.files);
+    if (files.length > 0) {
+      e.preventDefault();
+      attachFiles(files);
+    }
+  };
+
+  const handlePaste = (e: ClipboardEvent) => {
+    collapseVimCaret();
+    const files = Array.from(e.clipboardData.files).filter(file => file.type.startsWith('image/'));
+    if (files.length > 0) {
+      e.preventDefault();
+      attachFiles(files);
+    }
+  };
+
   // Focus input when focusTrigger changes (when navigating back to chat)
   useEffect(() => {
     if (focusTrigger !== undefined && focusTrigger > 0 && inputRef.current) {
@@ -161,14 +275,27 @@ export function InputBox({
     };
   }, []);
 
-  // Global Escape key listener for canceling generation
+  // Global cancel (Esc) and stop (S) keys while a response is generated
   useEffect(() => {
     if (!isLoading) return;
 
     const handleGlobalKeyDown = (e: globalThis.KeyboardEvent) => {
-      if (e.key === 'Escape') {
+      // While recording, Esc discards the recording instead
+      if (matchesKey(keys.cancel, e, isMac)) {
+        if (e.key === 'Escape' && voiceInput.getState() === 'recording') {
+          return;
+        }
         e.preventDefault();
         onCancelMessage();
+        return;
+      }
+
+      // Stop keeps the partial response; ignore it while typing elsewhere (e.g. session name)
+      const target = e.target as HTMLElement;
+      const isTyping = target.tagName === 'INPUT' || target.tagName === 'TEXTAREA' || target.isContentEditable;
+      if (matchesKey(keys.stop, e, isMac) && !isTyping) {
+        e.preventDefault();
+        onStopMessage();
       }
     };
 
@@ -179,7 +306,7 @@ export function InputBox({
     return () => {
       document.removeEventListener('keydown', handleGlobalKeyDown);
     };
-  }, [isLoading, onCancelMessage]);
+  }, [isLoading, onCancelMessage, onStopMessage, keys.cancel, keys.stop, isMac]);
 
   const loadPrompts = async () => {
     // Ensure default prompt exists
@@ -193,9 +320,11 @@ export function InputBox({
       const lastSelected = localStorage.getItem('lastSelectedPrompt');
       if (lastSelected && result.prompts.includes(lastSelected)) {
         setSelectedPrompt(lastSelected);
+        onPromptChange?.(lastSelected);
       } else if (result.prompts.includes('Default')) {
         // If no last selected prompt, auto-select "Default"
         setSelectedPrompt('Default');
+        onPromptChange?.('Default');
         localStorage.setItem('lastSelectedPrompt', 'Default');
       }
     }
@@ -225,6 +354,7 @@ When working on tasks:
 
   const handlePromptChange = (value: string) => {
     setSelectedPrompt(value);
+    onPromptChange?.(value || null);
     // Save selection to localStorage
     if (value) {
       localStorage.setItem('lastSelectedPrompt', value);
@@ -245,6 +375,7 @@ When working on tasks:
       }
     }
 
+    history.add(input.trim());
     onSendMessage(input.trim(), systemPromptContent);
     setInput('');
   };
@@ -253,17 +384,164 @@ When working on tasks:
     onCancelMessage();
   };
 
-  const handleKeyPress = (e: KeyboardEvent<HTMLDivElement>) => {
-    if (e.key === 'Enter' && !e.shiftKey) {
-      e.preventDefault();
+  // Send and newline keys from the keyBindings preference. Enter and
+  // Shift+Enter start a new line by themselves when they aren't the send key.
+  const handleSendKey = (e: KeyboardEvent<HTMLDivElement>): boolean => {
+    if (e.nativeEvent.isComposing) {
+      return false;
+    }
+    if (matchesKey(keys.send, e, isMac)) {
       handleSend();
+      return true;
+    }
+    if (!matchesKey(keys.newline, e, isMac) || (e.key === 'Enter' && !e.ctrlKey && !e.metaKey && !e.altKey)) {
+      return false;
+    }
+    const element = inputRef.current;
+    const start = element?.selectionStart ?? input.length;
+    const end = element?.selectionEnd ?? start;
+    history.reset();
+    setInput(`${input.substring(0, start)}\n${input.substring(end)}`);
+    setTimeout(() => inputRef.current?.setSelectionRange(start + 1, start + 1), 0);
+    return true;
+  };
+
+  const handleInputChange = (value: string) => {
+    if (search) {
+      setSearch({ ...search, query: value, match: value ? history.search(value) : null });
+      return;
+    }
+    history.reset();
+    setInput(value);
+  };
+
+  // Returns true when the key was used for history recall or search
+  const handleHistoryKey = (e: KeyboardEvent<HTMLDivElement>): boolean => {
+    if (e.key === 'r' && e.ctrlKey && !e.shiftKey && !e.altKey) {
+      // Again while searching finds the next older match
+      setSearch(search
+        ? { ...search, match: search.query ? history.search(search.query, search.match?.index) ?? search.match : null }
+        : { query: '', draft: input, match: null });
+      return true;
+    }
+    if (search) {
+      if (e.key === 'Enter' || e.key === 'Escape') {
+        // Enter puts the match in the box for editing; it isn't sent yet
+        setInput(e.key === 'Enter' ? search.match?.entry ?? search.draft : search.draft);
+        setSearch(null);
+        return true;
+      }
+      return false;
+    }
+    if (e.altKey || e.ctrlKey || e.metaKey || e.shiftKey) {
+      return false;
+    }
+
+    // Arrows only recall history from the first or last line, so they still move through multi-line text
+    const element = inputRef.current;
+    const caret = element?.selectionStart ?? 0;
+    if (e.key === 'ArrowUp' && !input.slice(0, caret).includes('\n')) {
+      const entry = history.previous(input);
+      if (entry !== null) {
+        setInput(entry);
+      }
+      return entry !== null;
+    }
+    if (e.key === 'ArrowDown' && !input.slice(element?.selectionEnd ?? input.length).includes('\n')) {
+      const entry = history.next();
+      if (entry !== null) {
+        setInput(entry);
+      }
+      return entry !== null;
+    }
+    return false;
+  };
+
+  // In normal mode the character under the caret is selected, as a block cursor
+  const placeCaret = (text: string, caret: number, normal: boolean) => {
+    setTimeout(() => {
+      const element = inputRef.current;
+      const onCharacter = normal && caret < text.length && text[caret] !== '\n';
+      element?.setSelectionRange(caret, onCharacter ? caret + 1 : caret);
+    }, 0);
+  };
+
+  // Normal mode shows the caret as a one-character selection; shortcuts and
+  // pastes it hands back to the text box mustn't replace that character
+  const collapseVimCaret = () => {
+    const element = inputRef.current;
+    if (vim.enabled && vim.mode === 'normal' && element && element.selectionStart !== element.selectionEnd) {
+      element.setSelectionRange(element.selectionStart, element.selectionStart);
+    }
+  };
+
+  // Returns true when vim mode used the key
+  const handleVimKey = (e: KeyboardEvent<HTMLDivElement>): boolean => {
+    if (!vim.enabled || search || e.altKey || e.metaKey) {
+      collapseVimCaret();
+      return false;
+    }
+    const caret = inputRef.current?.selectionStart ?? input.length;
+    if (vim.mode === 'insert') {
+      if (e.key !== 'Escape' || e.ctrlKey) {
+        return false;
+      }
+      setVimMode('normal');
+      placeCaret(input, leaveInsert(input, caret), true);
+      return true;
+    }
+
+    const result = normalModeKey({ text: input, caret, pending: vimPendingRef.current }, e.key, e.ctrlKey);
+    if (!result) {
+      if (e.ctrlKey) {
+        collapseVimCaret();
+      }
+      return false;
+    }
+    vimPendingRef.current = result.pending;
+    const text = result.text ?? input;
+    if (result.text !== undefined) {
+      history.reset();
+      setInput(text);
+    }
+    if (result.mode) {
+      setVimMode(result.mode);
+    }
+    if (result.scroll) {
+      scrollHistory(result.scroll);
     }
+    placeCaret(text, Math.min(result.caret ?? caret, text.length), (result.mode ?? vim.mode) === 'normal');
+    return true;
   };
 
   const handleKeyDown = (e: KeyboardEvent<HTMLDivElement>) => {
-    if (e.key === 'Escape' && isLoading) {
+    if (handleHistoryKey(e)) {
+      // Ctrl+R in the input box searches history instead of regenerating (Cmd+R on macOS is unaffected)
+      e.preventDefault();
+      e.stopPropagation();
+      return;
+    }
+    if (!(e.key === 'Escape' && voiceState === 'recording') && handleVimKey(e)) {
+      e.preventDefault();
+      e.stopPropagation();
+      return;
+    }
+    if (e.key === ' ' && e.ctrlKey && e.shiftKey) {
+      e.preventDefault();
+      toggleVoice();
+    } else if (e.key === 'Escape' && voiceState === 'recording') {
+      e.preventDefault();
+      voiceInput.cancel();
+    } else if (isLoading && matchesKey(keys.cancel, e, isMac)) {
       e.preventDefault();
       handleCancel();
+    } else if (!search && handleSendKey(e)) {
+      e.preventDefault();
+    } else if (e.key === 'Escape' && !e.ctrlKey && !input.trim() && !findQuery) {
+      // Esc in an empty box (in vim mode, from normal mode) selects past messages
+      e.preventDefault();
+      inputRef.current?.blur();
+      startMessageSelection();
     }
   };
 
@@ -307,14 +585,14 @@ When working on tasks:
 
   return (
     <Box sx={{
-      borderTop: '1px solid rgba(205, 214, 244, 0.1)',
-      p: 2,
+      borderTop: compact ? 'none' : '1px solid rgba(205, 214, 244, 0.1)',
+      p: compact ? 1 : 2,
       backgroundColor: '#1e1e2e',
     }}>
-      {/* Selectors row */}
-      <Box sx={{ display: 'flex', gap: 1, mb: 1, alignItems: 'center' }}>
+      {/* Selectors row; in the compact layout the model gets its own line and the rest wrap */}
+      <Box sx={{ display: 'flex', flexWrap: compact ? 'wrap' : 'nowrap', gap: 1, mb: 1, alignItems: 'center' }}>
         {/* Combined Provider/Model selector */}
-        <FormControl size="small" sx={{ minWidth: 300 }}>
+        <FormControl size="small" sx={compact ? { minWidth: 0, flex: '1 1 100%' } : { minWidth: 300 }}>
           <Select
             value={currentValue}
             onChange={(e) => handleModelSelection(e.target.value)}
@@ -371,7 +649,7 @@ When working on tasks:
               },
             }}
           >
-            {providers.filter(p => p.enabled).map(provider => {
+            {providers.filter(p => p.enabled && (!offlineMode || isLocalProvider(p))).map(provider => {
               const chatModels = provider.models.filter(m => m.type === 'chat');
               if (chatModels.length === 0) return null;
 
@@ -413,12 +691,23 @@ When working on tasks:
         </FormControl>
 
         {/* System Prompt selector */}
-        <FormControl size="small" sx={{ minWidth: 200 }}>
+        <FormControl size="small" sx={compact ? { minWidth: 0, flex: '1 1 0' } : { minWidth: 200 }}>
           <Select
             value={selectedPrompt}
             onChange={(e) => handlePromptChange(e.target.value)}
             displayEmpty
             renderValue={(selected) => {
+              if (sessionPromptOverride) {
+                return (
+                  <Box
+                    title={`This session's own prompt is used instead${selected ? ` of "${selected}"` : ''} (/system reset)`}
+                    sx={{ display: 'flex', alignItems: 'center', gap: 0.5, color: '#f9e2af' }}
+                  >
+                    <FileText size={14} />
+                    Session prompt
+                  </Box>
+                );
+              }
               if (!selected) {
                 return (
                   <Box sx={{ display: 'flex', alignItems: 'center', gap: 0.5 }}>
@@ -501,10 +790,10 @@ When working on tasks:
         </FormControl>
 
         {/* Context Mode selector */}
-        <FormControl size="small" sx={{ minWidth: 180 }}>
+        <FormControl size="small" sx={compact ? { minWidth: 0, flex: '1 1 0' } : { minWidth: 180 }}>
           <Select
             value={contextMode}
-            onChange={(e) => handleContextModeChange(e.target.value as 'rolling' | 'halt')}
+            onChange={(e) => handleContextModeChange(e.target.value as ContextMode)}
             sx={{
               color: '#cdd6f4',
               '& .MuiOutlinedInput-notchedOutline': {
@@ -546,12 +835,94 @@ When working on tasks:
           >
             <MenuItem value="rolling">Rolling Context</MenuItem>
             <MenuItem value="halt">Halting Context</MenuItem>
+            <MenuItem value="summarize">Summarizing Context</MenuItem>
           </Select>
         </FormControl>
 
         {/* Spacer to push context usage to the right */}
         <Box sx={{ flexGrow: 1 }} />
 
+        {/* Offline mode indicator */}
+        {offlineMode && (
+          <Box
+            onClick={onToggleOfflineMode}
+            title="Offline mode: only local Ollama, network tools disabled. Click to go online."
+            sx={{
+              display: 'flex',
+              alignItems: 'center',
+              gap: 0.5,
+              px: 1,
+              py: 0.25,
+              borderRadius: 1,
+              border: compact ? 'none' : '1px solid rgba(249, 226, 175, 0.4)',
+              color: '#f9e2af',
+              fontSize: '0.75rem',
+              cursor: onToggleOfflineMode ? 'pointer' : 'default',
+              userSelect: 'none',
+              '&:hover': {
+                backgroundColor: 'rgba(249, 226, 175, 0.1)',
+              },
+            }}
+          >
+            <WifiOff size={12} />
+            {!compact && 'Offline'}
+          </Box>
+        )}
+
+        {/* Untrusted workspace indicator */}
+        {!workspaceTrusted && (
+          <Box
+            onClick={onTrustWorkspace}
+            title="Restricted workspace: file-writing, shell and MCP tools are disabled. Click to trust this folder."
+            sx={{
+              display: 'flex',
+              alignItems: 'center',
+              gap: 0.5,
+              px: 1,
+              py: 0.25,
+              borderRadius: 1,
+              border: compact ? 'none' : '1px solid rgba(250, 179, 135, 0.4)',
+              color: '#fab387',
+              fontSize: '0.75rem',
+              cursor: onTrustWorkspace ? 'pointer' : 'default',
+              userSelect: 'none',
+              '&:hover': {
+                backgroundColor: 'rgba(250, 179, 135, 0.1)',
+              },
+            }}
+          >
+            <ShieldAlert size={12} />
+            {!compact && 'Restricted'}
+          </Box>
+        )}
+
+        {/* Voice input indicator */}
+        {voiceState !== 'idle' && (
+          <Box
+            onClick={voiceState === 'recording' ? toggleVoice : undefined}
+            title={voiceState === 'recording' ? 'Recording. Click or press Ctrl+Shift+Space to stop, Esc to discard.' : 'Transcribing...'}
+            sx={{
+              display: 'flex',
+              alignItems: 'center',
+              gap: 0.5,
+              px: 1,
+              py: 0.25,
+              borderRadius: 1,
+              border: compact ? 'none' : '1px solid rgba(243, 139, 168, 0.4)',
+              color: '#f38ba8',
+              fontSize: '0.75rem',
+              cursor: voiceState === 'recording' ? 'pointer' : 'default',
+              userSelect: 'none',
+              '&:hover': {
+                backgroundColor: 'rgba(243, 139, 168, 0.1)',
+              },
+            }}
+          >
+            <Mic size={12} />
+            {!compact && (voiceState === 'recording' ? 'Recording' : 'Transcribing')}
+          </Box>
+        )}
+
         {/* Context usage display */}
         {contextUsage && (
           isEditingContextSize ? (
@@ -630,7 +1001,8 @@ When working on tasks:
             >
               {formatContextUsage(
                 contextUsage.used,
-                virtualContextSize ?? contextUsage.total
+                virtualContextSize ?? contextUsage.total,
+                compact
               )}
             </Typography>
           )
@@ -639,15 +1011,84 @@ When working on tasks:
 
       {/* Input box */}
       <Box>
+        {vim.enabled && !search && (
+          <Typography
+            sx={{
+              color: vim.mode === 'normal' ? '#89b4fa' : 'rgba(205, 214, 244, 0.6)',
+              fontSize: '0.8rem',
+              fontFamily: 'monospace',
+              mb: 0.5,
+            }}
+          >
+            {vim.mode === 'normal'
+              ? '-- NORMAL -- (i: insert, j/k: scroll, dd: clear, Enter: send)'
+              : '-- INSERT -- (Esc: normal mode)'}
+          </Typography>
+        )}
+        {search && (
+          <Typography
+            sx={{
+              color: search.query && !search.match ? '#f38ba8' : 'rgba(205, 214, 244, 0.6)',
+              fontSize: '0.8rem',
+              fontFamily: 'monospace',
+              mb: 0.5,
+              whiteSpace: 'nowrap',
+              overflow: 'hidden',
+              textOverflow: 'ellipsis',
+            }}
+          >
+            {!search.query
+              ? 'History search: type to find a sent message (Ctrl+R: older, Enter: use, Esc: cancel)'
+              : search.match
+              ? `History: ${search.match.entry.replace(/\n/g, ' ⏎ ')}`
+              : `No earlier message contains "${search.query}"`}
+          </Typography>
+        )}
+        {attachments.length > 0 && (
+          <Box sx={{ display: 'flex', flexWrap: 'wrap', gap: 0.5, mb: 0.5 }}>
+            {attachments.map((attachment, index) => (
+              <Box
+                key={`${attachment.name}-${index}`}
+                title={attachment.path ?? attachment.name}
+                sx={{
+                  display: 'flex',
+                  alignItems: 'center',
+                  gap: 0.5,
+                  pl: 1,
+                  pr: 0.5,
+                  py: 0.25,
+                  borderRadius: 1,
+                  border: '1px solid rgba(137, 180, 250, 0.4)',
+                  color: '#89b4fa',
+                  fontSize: '0.75rem',
+                  userSelect: 'none',
+                }}
+              >
+                <ImageIcon size={12} />
+                {attachment.name}
+                <Box
+                  component="span"
+                  onClick={() => removeAttachment(index)}
+                  title="Remove"
+                  sx={{ display: 'flex', cursor: 'pointer', '&:hover': { color: '#f38ba8' } }}
+                >
+                  <X size={12} />
+                </Box>
+              </Box>
+            ))}
+          </Box>
+        )}
         <TextField
           fullWidth
           multiline
           maxRows={6}
-          value={input}
-          onChange={(e) => setInput(e.target.value)}
-          onKeyPress={handleKeyPress}
+          onDrop={handleDrop}
+          onDragOver={(e) => e.preventDefault()}
+          onPaste={handlePaste}
+          value={search ? search.query : input}
+          onChange={(e) => handleInputChange(e.target.value)}
           onKeyDown={handleKeyDown}
-          placeholder={isLoading ? "Press ESC to Cancel" : "Type your message... (SHIFT+ENTER: new line / focus input)"}
+          placeholder={isLoading ? `Press ${formatKey(keys.cancel, isMac)} to Cancel, ${formatKey(keys.stop, isMac)} to Stop and keep the response` : search ? "Search sent messages..." : `Type your message or /help... (${formatKey(keys.newline, isMac)}: new line, ↑/Ctrl+R: history)`}
           disabled={isLoading || !currentProvider || !currentModel}
           inputRef={inputRef}
           autoFocus
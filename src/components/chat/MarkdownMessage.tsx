@@ -1,14 +1,120 @@
-import { Box } from '@mui/material';
-import ReactMarkdown from 'react-markdown';
+import { Box, IconButton } from '@mui/material';
+import { Check, Copy } from 'lucide-react';
+import { memo, useState } from 'react';
+import ReactMarkdown, { type Components } from 'react-markdown';
 import remarkGfm from 'remark-gfm';
 import { Prism as SyntaxHighlighter } from 'react-syntax-highlighter';
 import { oneDark } from 'react-syntax-highlighter/dist/esm/styles/prism';
 
 interface MarkdownMessageProps {
   content: string;
+  // While a response streams, finished blocks are rendered once and only the last
+  // one is re-rendered as chunks arrive
+  streaming?: boolean;
 }
 
-export function MarkdownMessage({ content }: MarkdownMessageProps) {
+function CodeBlock({ language, code }: { language: string; code: string }) {
+  const [copied, setCopied] = useState(false);
+
+  const handleCopy = async () => {
+    const result = await window.electronAPI.clipboardWriteText(code);
+    if (result.success) {
+      setCopied(true);
+      setTimeout(() => setCopied(false), 1500);
+    } else {
+      console.error('Failed to copy code block:', result.error);
+    }
+  };
+
+  return (
+    <Box sx={{ position: 'relative', '&:hover .code-copy': { opacity: 1 } }}>
+      <IconButton
+        className="code-copy"
+        size="small"
+        onClick={handleCopy}
+        title={copied ? 'Copied' : 'Copy code'}
+        sx={{
+          position: 'absolute',
+          top: 4,
+          right: 4,
+          zIndex: 1,
+          opacity: 0,
+          color: copied ? '#a6e3a1' : 'rgba(205, 214, 244, 0.6)',
+          '&:focus-visible': { opacity: 1 },
+        }}
+      >
+        {copied ? <Check size={14} /> : <Copy size={14} />}
+      </IconButton>
+      <SyntaxHighlighter
+        style={oneDark as { [key: string]: React.CSSProperties }}
+        language={language}
+        PreTag="div"
+        customStyle={{
+          margin: 0,
+          borderRadius: '6px',
+          fontSize: '0.9em',
+        }}
+      >
+        {code}
+      </SyntaxHighlighter>
+    </Box>
+  );
+}
+
+const FENCE_PATTERN = /^\s{0,3}(`{3,}|~{3,})/;
+
+/**
+ * Split streaming markdown at blank lines outside code fences. Returns the blocks
+ * that are complete and the block still being written.
+ */
+function splitCompletedBlocks(content: string): { completed: string[]; partial: string } {
+  const lines = content.split('\n');
+  const completed: string[] = [];
+  let current: string[] = [];
+  let fence: string | null = null;
+
+  // The last line may still be growing, so it always belongs to the partial block
+  for (const line of lines.slice(0, -1)) {
+    const marker = FENCE_PATTERN.exec(line)?.[1];
+    if (marker && (!fence || (marker[0] === fence[0] && marker.length >= fence.length))) {
+      fence = fence ? null : marker;
+    }
+    if (!fence && line.trim() === '' && current.length > 0) {
+      completed.push(current.join('\n'));
+      current = [];
+      continue;
+    }
+    current.push(line);
+  }
+  current.push(lines[lines.length - 1]);
+  return { completed, partial: current.join('\n') };
+}
+
+const markdownComponents: Components = {
+  code({ className, children, ...props }) {
+    const match = /language-(\w+)/.exec(className || '');
+    const language = match ? match[1] : '';
+    const inline = !match;
+
+    return !inline && language ? (
+      <CodeBlock language={language} code={String(children).replace(/\n$/, '')} />
+    ) : (
+      <code className={className} {...props}>
+        {children}
+      </code>
+    );
+  },
+};
+
+const MarkdownBlock = memo(function MarkdownBlock({ content }: { content: string }) {
+  return (
+    <ReactMarkdown remarkPlugins={[remarkGfm]} components={markdownComponents}>
+      {content}
+    </ReactMarkdown>
+  );
+});
+
+export function MarkdownMessage({ content, streaming = false }: MarkdownMessageProps) {
   // Handle empty content gracefully
   if (!content || content.trim() === '') {
     return null;
@@ -88,37 +194,23 @@ export function MarkdownMessage({ content }: MarkdownMessageProps) {
         fontWeight: 600,
       },
     }}>
-      <ReactMarkdown
-        remarkPlugins={[remarkGfm]}
-        components={{
-          code({ className, children, ...props }) {
-            const match = /language-(\w+)/.exec(className || '');
-            const language = match ? match[1] : '';
-            const inline = !match;
-
-            return !inline && language ? (
-              <SyntaxHighlighter
-                style={oneDark as { [key: string]: React.CSSProperties }}
-                language={language}
-                PreTag="div"
-                customStyle={{
-                  margin: 0,
-                  borderRadius: '6px',
-                  fontSize: '0.9em',
-                }}
-              >
-                {String(children).replace(/\n$/, '')}
-              </SyntaxHighlighter>
-            ) : (
-              <code className={className} {...props}>
-                {children}
-              </code>
-            );
-          },
-        }}
-      >
-        {content}
-      </ReactMarkdown>
+      {streaming ? (
+        <StreamingMarkdown content={content} />
+      ) : (
+        <MarkdownBlock content={content} />
+      )}
     </Box>
   );
 }
+
+function StreamingMarkdown({ content }: { content: string }) {
+  const { completed, partial } = splitCompletedBlocks(content);
+  return (
+    <>
+      {completed.map((block, index) => (
+        <MarkdownBlock key={index} content={block} />
+      ))}
+      <MarkdownBlock content={partial} />
+    </>
+  );
+}
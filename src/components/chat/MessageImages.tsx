@@ -0,0 +1,77 @@
+import { Box, Dialog, Typography } from '@mui/material';
+import { Image } from 'lucide-react';
+import { useState } from 'react';
+import type { MessageImage } from '../../types/chat';
+
+interface MessageImagesProps {
+  images: MessageImage[];
+}
+
+// Images attached to a user message or returned alongside a tool result. Inline
+// images open full size on click; the rest were saved to a temp file and are
+// listed by path.
+export function MessageImages({ images }: MessageImagesProps) {
+  const [zoomed, setZoomed] = useState<string | null>(null);
+
+  return (
+    <Box sx={{ mt: 1 }}>
+      <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5, fontWeight: 600 }}>
+        {images.length === 1 ? 'Image' : `Images (${images.length})`}
+      </Typography>
+      <Box sx={{ display: 'flex', flexWrap: 'wrap', gap: 1 }}>
+        {images.map((image, index) => {
+          if (image.data) {
+            const src = `data:${image.mimeType};base64,${image.data}`;
+            return (
+              <Box
+                key={index}
+                component="img"
+                src={src}
+                alt={image.path ?? `Image ${index + 1}`}
+                title={image.path}
+                onClick={() => setZoomed(src)}
+                sx={{
+                  maxWidth: '100%',
+                  maxHeight: 360,
+                  borderRadius: 0.5,
+                  border: '1px solid rgba(108, 112, 134, 0.2)',
+                  backgroundColor: '#1e1e2e',
+                  cursor: 'zoom-in',
+                }}
+              />
+            );
+          }
+
+          return (
+            <Box key={index} sx={{
+              display: 'flex',
+              alignItems: 'center',
+              gap: 1,
+              p: 1,
+              borderRadius: 0.5,
+              border: '1px solid rgba(108, 112, 134, 0.2)',
+              backgroundColor: '#1e1e2e',
+            }}>
+              <Image size={14} color="rgba(205, 214, 244, 0.6)" />
+              <Typography variant="body2" sx={{ color: '#cdd6f4', fontFamily: 'monospace', fontSize: '12px', userSelect: 'text' }}>
+                {image.mimeType} saved to {image.path}
+              </Typography>
+            </Box>
+          );
+        })}
+      </Box>
+
+      <Dialog open={!!zoomed} onClose={() => setZoomed(null)} maxWidth={false}>
+        {zoomed && (
+          <Box
+            component="img"
+            src={zoomed}
+            alt="Full size"
+            onClick={() => setZoomed(null)}
+            sx={{ display: 'block', maxWidth: '90vw', maxHeight: '90vh', cursor: 'zoom-out' }}
+          />
+        )}
+      </Dialog>
+    </Box>
+  );
+}
@@ -1,13 +1,24 @@
 import { Box, Typography, Collapse, IconButton, keyframes, TextField } from '@mui/material';
 import { useEffect, useRef, useState } from 'react';
-import type { ChatMessage } from '../../types/chat';
+import type { ChatMessage, GenerationStats, ThinkingDisplay } from '../../types/chat';
 import { ToolResultDisplay } from './ToolResultDisplay';
 import { MarkdownMessage } from './MarkdownMessage';
-import { Brain, ChevronDown, ChevronRight, Edit2, Trash2, RotateCw, Check, X, ArrowRight, GitBranch } from 'lucide-react';
+import { Brain, ChevronDown, ChevronRight, ChevronUp, Edit2, Trash2, RotateCw, Check, X, ArrowRight, GitBranch, ThumbsUp, ThumbsDown, ArrowDown, Pin } from 'lucide-react';
+import { getMessageNumber } from '../../utils/messageUtils';
+import { setFindQuery, useFindQuery } from '../../hooks/useFindInSession';
+import { onHistoryScroll } from '../../hooks/useVimMode';
+import { endMessageSelection, selectMessage, useMessageSelection } from '../../hooks/useMessageSelection';
+import { MessageImages } from './MessageImages';
+import { useCompactLayout } from '../../hooks/useCompactLayout';
 
 interface MessageListProps {
   messages: ChatMessage[];
+  thinkingDisplay?: ThinkingDisplay;
+  thinkingRenderLimit?: number;
+  thinkingToggle?: { messageId: string | null; count: number };
+  showStats?: boolean;
   isLoading?: boolean;
+  streamStatus?: string | null;
   pendingPermissions?: Map<string, {
     onAllow: () => void;
     onDeny: () => void;
@@ -19,8 +30,35 @@ interface MessageListProps {
   onRegenerate?: () => void;
   onContinue?: () => void;
   onFork?: (messageId: string) => void;
+  onMessageCommand?: (command: string) => void; // Runs a slash command such as "/pin 3" for the selection keys
 }
 
+// How close to the bottom (px) still counts as following the conversation
+const SCROLL_FOLLOW_THRESHOLD = 80;
+
+// Ranges of rendered text under `root` containing `query`, case-insensitive.
+// A match split across elements (half of it bold, say) isn't found.
+function findTextRanges(root: HTMLElement, query: string): Range[] {
+  const needle = query.toLowerCase();
+  const ranges: Range[] = [];
+  const walker = document.createTreeWalker(root, NodeFilter.SHOW_TEXT);
+  for (let node = walker.nextNode(); node; node = walker.nextNode()) {
+    const text = (node.nodeValue || '').toLowerCase();
+    for (let i = text.indexOf(needle); i !== -1; i = text.indexOf(needle, i + needle.length)) {
+      const range = document.createRange();
+      range.setStart(node, i);
+      range.setEnd(node, i + needle.length);
+      ranges.push(range);
+    }
+  }
+  return ranges;
+}
+
+// Messages the selection cursor stops at: the numbered ones that are shown
+const isSelectable = (message: ChatMessage) =>
+  (message.role === 'user' || message.role === 'assistant') &&
+  !!(message.content || message.thinking || message.tool_calls?.length);
+
 // Keyframes for the dot animation
 const dotPulse = keyframes`
   0%, 20% {
@@ -34,7 +72,12 @@ const dotPulse = keyframes`
   }
 `;
 
-function LoadingIndicator() {
+// The colored bar that marks who a message is from; the compact layout has no room for it
+function messageGutter(color: string, compact: boolean) {
+  return compact ? { pl: 0 } : { borderLeft: `4px solid ${color}`, pl: 2 };
+}
+
+function LoadingIndicator({ status }: { status?: string | null }) {
   return (
     <Box sx={{
       display: 'flex',
@@ -48,6 +91,7 @@ function LoadingIndicator() {
           sx={{
             width: 6,
             height: 6,
+            flexShrink: 0,
             borderRadius: '50%',
             backgroundColor: '#a6e3a1',
             animation: `${dotPulse} 1.4s ease-in-out infinite`,
@@ -55,15 +99,63 @@ function LoadingIndicator() {
           }}
         />
       ))}
+      {status && (
+        <Typography variant="caption" noWrap title={status} sx={{ color: 'rgba(205, 214, 244, 0.6)', ml: 1, minWidth: 0 }}>
+          {status}
+        </Typography>
+      )}
     </Box>
   );
 }
 
-export function MessageList({ messages, isLoading, pendingPermissions, toolCallStatuses, onEditMessage, onDeleteMessage, onRegenerate, onContinue, onFork }: MessageListProps) {
+export function MessageList({ messages, thinkingDisplay = 'collapsed', thinkingRenderLimit = 0, thinkingToggle, showStats = false, isLoading, streamStatus, pendingPermissions, toolCallStatuses, onEditMessage, onDeleteMessage, onRegenerate, onContinue, onFork, onMessageCommand }: MessageListProps) {
   const messagesEndRef = useRef<HTMLDivElement>(null);
+  const scrollRef = useRef<HTMLDivElement>(null);
+  // Follow new output only while the view is at the bottom, so scrolling back mid-stream sticks
+  const followRef = useRef(true);
+  const [showJump, setShowJump] = useState(false);
+  const lastMessage = messages[messages.length - 1];
+  const findQuery = useFindQuery();
+  const compact = useCompactLayout();
+  const findRangesRef = useRef<Range[]>([]);
+  const findQueryRef = useRef<string | null>(null);
+  const findIndexRef = useRef(0);
+  const [findPosition, setFindPosition] = useState({ current: 0, total: 0 });
+  const isLoadingRef = useRef(isLoading);
+  isLoadingRef.current = isLoading;
+  const selection = useMessageSelection();
+  const selectionPendingRef = useRef('');
+  // Bumped by the selection's edit key; the message's block opens its editor
+  const [editRequest, setEditRequest] = useState<{ messageId: string | null; count: number }>({ messageId: null, count: 0 });
+
+  const handleScroll = () => {
+    const el = scrollRef.current;
+    if (!el) {
+      return;
+    }
+    const atBottom = el.scrollHeight - el.scrollTop - el.clientHeight < SCROLL_FOLLOW_THRESHOLD;
+    followRef.current = atBottom;
+    setShowJump(!atBottom);
+  };
+
+  const scrollToBottom = (behavior: ScrollBehavior = 'smooth') => {
+    followRef.current = true;
+    setShowJump(false);
+    messagesEndRef.current?.scrollIntoView({ behavior });
+  };
+
+  // Sending a message always brings the view back down
+  useEffect(() => {
+    if (lastMessage?.role === 'user') {
+      followRef.current = true;
+    }
+  }, [lastMessage?.id, lastMessage?.role]);
 
   // Auto-scroll to bottom when new messages arrive or permissions are requested
   useEffect(() => {
+    if (!followRef.current) {
+      return;
+    }
     // Use setTimeout to wait for animations/expansions to complete
     const timer = setTimeout(() => {
       messagesEndRef.current?.scrollIntoView({ behavior: 'smooth' });
@@ -71,11 +163,234 @@ export function MessageList({ messages, isLoading, pendingPermissions, toolCallS
     return () => clearTimeout(timer);
   }, [messages, isLoading, pendingPermissions]);
 
+  // Mark match `index` (wrapping around) as the current one, optionally bringing it into the middle of the view
+  const showFindMatch = (index: number, scroll: boolean) => {
+    const el = scrollRef.current;
+    const ranges = findRangesRef.current;
+    if (!el || ranges.length === 0) {
+      findIndexRef.current = 0;
+      CSS.highlights.delete('find-current');
+      setFindPosition({ current: 0, total: 0 });
+      return;
+    }
+    const current = (index + ranges.length) % ranges.length;
+    findIndexRef.current = current;
+    CSS.highlights.set('find-current', new Highlight(ranges[current]));
+    setFindPosition({ current, total: ranges.length });
+    if (scroll) {
+      const rect = ranges[current].getBoundingClientRect();
+      const view = el.getBoundingClientRect();
+      el.scrollBy({ top: rect.top - view.top - el.clientHeight / 2 });
+    }
+  };
+  const showFindMatchRef = useRef(showFindMatch);
+  showFindMatchRef.current = showFindMatch;
+
+  // Find again whenever the query or the transcript changes; a new query starts
+  // at the first match from the top of the view, otherwise the current one stays
+  useEffect(() => {
+    const el = scrollRef.current;
+    const isNewQuery = findQuery !== findQueryRef.current;
+    findQueryRef.current = findQuery;
+    if (!findQuery || !el) {
+      findRangesRef.current = [];
+      CSS.highlights.delete('find-match');
+      CSS.highlights.delete('find-current');
+      setFindPosition({ current: 0, total: 0 });
+      return;
+    }
+
+    const ranges = findTextRanges(el, findQuery);
+    findRangesRef.current = ranges;
+    CSS.highlights.set('find-match', new Highlight(...ranges));
+    if (isNewQuery) {
+      const top = el.getBoundingClientRect().top;
+      const first = ranges.findIndex(range => range.getBoundingClientRect().bottom >= top);
+      showFindMatchRef.current(first === -1 ? ranges.length - 1 : first, true);
+    } else {
+      showFindMatchRef.current(Math.min(findIndexRef.current, ranges.length - 1), false);
+    }
+  }, [findQuery, messages]);
+
+  // F3 or Ctrl/Cmd+G for the next match, with Shift for the previous one, Esc to close
+  useEffect(() => {
+    if (!findQuery) {
+      return;
+    }
+    const handleKeyDown = (e: globalThis.KeyboardEvent) => {
+      if ((e.target as HTMLElement).closest?.('[role="dialog"]')) {
+        return;
+      }
+      if (e.key === 'F3' || ((e.metaKey || e.ctrlKey) && e.key.toLowerCase() === 'g')) {
+        e.preventDefault();
+        showFindMatchRef.current(findIndexRef.current + (e.shiftKey ? -1 : 1), true);
+      } else if (e.key === 'Escape' && !isLoadingRef.current) {
+        // While a response streams, Esc cancels it instead
+        setFindQuery(null);
+      }
+    };
+    document.addEventListener('keydown', handleKeyDown);
+    return () => document.removeEventListener('keydown', handleKeyDown);
+  }, [findQuery]);
+
+  // PgUp/PgDn page through the history, Ctrl/Cmd+Home/End jump to either end,
+  // even while the input box has focus. Dialogs (pager, pickers) keep their own keys.
+  useEffect(() => {
+    const handleKeyDown = (e: globalThis.KeyboardEvent) => {
+      const el = scrollRef.current;
+      if (!el || (e.target as HTMLElement).closest?.('[role="dialog"]')) {
+        return;
+      }
+      const page = el.clientHeight * 0.9;
+      const modifier = e.metaKey || e.ctrlKey;
+      if (e.key === 'PageUp') {
+        el.scrollBy({ top: -page });
+      } else if (e.key === 'PageDown') {
+        el.scrollBy({ top: page });
+      } else if (modifier && e.key === 'Home') {
+        el.scrollTo({ top: 0 });
+      } else if (modifier && e.key === 'End') {
+        scrollToBottom('auto');
+      } else {
+        return;
+      }
+      e.preventDefault();
+    };
+
+    // Text re-wraps on its own when the window resizes; keep the bottom in view if we were following
+    const handleResize = () => {
+      if (followRef.current) {
+        messagesEndRef.current?.scrollIntoView();
+      }
+    };
+
+    document.addEventListener('keydown', handleKeyDown);
+    window.addEventListener('resize', handleResize);
+    return () => {
+      document.removeEventListener('keydown', handleKeyDown);
+      window.removeEventListener('resize', handleResize);
+    };
+  }, []);
+
+  // j/k, Ctrl+D/Ctrl+U, gg and G from the input box in vim normal mode
+  useEffect(() => onHistoryScroll(scroll => {
+    const el = scrollRef.current;
+    if (!el) {
+      return;
+    }
+    const line = 40;
+    if (scroll === 'down' || scroll === 'up') {
+      el.scrollBy({ top: scroll === 'down' ? line : -line });
+    } else if (scroll === 'half-down' || scroll === 'half-up') {
+      el.scrollBy({ top: (scroll === 'half-down' ? 1 : -1) * el.clientHeight / 2 });
+    } else if (scroll === 'top') {
+      el.scrollTo({ top: 0 });
+    } else {
+      scrollToBottom('auto');
+    }
+  }), []);
+
+  // Selection starts on the latest message, moves on when the selected one is
+  // deleted, and ends when a response starts or nothing can be selected
+  useEffect(() => {
+    if (!selection.active) {
+      return;
+    }
+    const selectable = messages.filter(isSelectable);
+    if (isLoading || selectable.length === 0) {
+      endMessageSelection();
+    } else if (!selectable.some(m => m.id === selection.messageId)) {
+      selectMessage(selectable[selectable.length - 1].id);
+    }
+  }, [selection, messages, isLoading]);
+
+  // Keys while selecting: j/k (or the arrows) move, gg and G jump to either end,
+  // and the rest act on the selected message; Esc, q or i go back to the input box
+  useEffect(() => {
+    if (!selection.active) {
+      return;
+    }
+    selectionPendingRef.current = '';
+    const handleKeyDown = (e: globalThis.KeyboardEvent) => {
+      const target = e.target as HTMLElement;
+      if (e.defaultPrevented || target.closest?.('[role="dialog"]') || target.tagName === 'INPUT' || target.tagName === 'TEXTAREA' || e.ctrlKey || e.metaKey || e.altKey) {
+        return;
+      }
+      const selectable = messages.filter(isSelectable);
+      const index = selectable.findIndex(m => m.id === selection.messageId);
+      const message = selectable[index];
+      if (!message) {
+        return;
+      }
+      const number = getMessageNumber(messages, message.id);
+      const command = selectionPendingRef.current + e.key;
+      selectionPendingRef.current = '';
+
+      if (command === 'j' || command === 'ArrowDown') {
+        selectMessage(selectable[Math.min(index + 1, selectable.length - 1)].id);
+      } else if (command === 'k' || command === 'ArrowUp') {
+        selectMessage(selectable[Math.max(index - 1, 0)].id);
+      } else if (command === 'gg' || command === 'Home') {
+        selectMessage(selectable[0].id);
+      } else if (command === 'G' || command === 'End') {
+        selectMessage(selectable[selectable.length - 1].id);
+      } else if (command === 'g' || command === 'd') {
+        selectionPendingRef.current = command;
+      } else if (command === 'y' || command === 'c') {
+        onMessageCommand?.(`/copy ${number}`);
+      } else if (command === 'p') {
+        onMessageCommand?.(`/pin ${number}`);
+      } else if (command === '+' || command === '=' || command === '-' || command === '0') {
+        onMessageCommand?.(`/rate ${number} ${command === '-' ? 'bad' : command === '0' ? 'clear' : 'good'}`);
+      } else if (command === 'Enter' || command === 'o') {
+        onMessageCommand?.(`/inspect ${number}`);
+      } else if (command === 'e' && onEditMessage) {
+        endMessageSelection();
+        setEditRequest(prev => ({ messageId: message.id, count: prev.count + 1 }));
+      } else if (command === 'f' && onFork) {
+        endMessageSelection();
+        onFork(message.id);
+      } else if (command === 'dd' && onDeleteMessage) {
+        const next = selectable[index + 1] ?? selectable[index - 1];
+        onDeleteMessage(message.id);
+        if (next) {
+          selectMessage(next.id);
+        }
+      } else if (command === 'Escape' || command === 'q' || command === 'i') {
+        endMessageSelection();
+      } else if (e.key === 'Shift') {
+        // Typing G or + shouldn't cancel a half-typed gg or dd
+        selectionPendingRef.current = command.slice(0, -e.key.length);
+        return;
+      } else {
+        return;
+      }
+      e.preventDefault();
+      e.stopPropagation();
+    };
+
+    // Clicking into the input box or another field goes back to typing
+    const handleFocusIn = (e: FocusEvent) => {
+      const target = e.target as HTMLElement;
+      if (target.tagName === 'INPUT' || target.tagName === 'TEXTAREA') {
+        endMessageSelection();
+      }
+    };
+
+    document.addEventListener('keydown', handleKeyDown);
+    document.addEventListener('focusin', handleFocusIn);
+    return () => {
+      document.removeEventListener('keydown', handleKeyDown);
+      document.removeEventListener('focusin', handleFocusIn);
+    };
+  }, [selection, messages, onMessageCommand, onEditMessage, onDeleteMessage, onFork]);
+
   // Check if we should show the loading indicator
   // Show it when isLoading is true AND the last assistant message has no content yet
   const shouldShowLoading = isLoading && messages.length > 0 &&
     messages[messages.length - 1]?.role === 'assistant' &&
     !messages[messages.length - 1]?.content &&
+    !messages[messages.length - 1]?.thinking &&
     (!messages[messages.length - 1]?.tool_calls || messages[messages.length - 1]?.tool_calls?.length === 0);
 
   // Find the last assistant message (for regenerate button)
@@ -94,73 +409,199 @@ export function MessageList({ messages, isLoading, pendingPermissions, toolCallS
   }
 
   return (
-    <Box sx={{
-      flexGrow: 1,
-      overflowY: 'auto',
-      p: 3,
-      display: 'flex',
-      flexDirection: 'column',
-      gap: 2,
-    }}>
-      {messages.length === 0 ? (
+    <Box sx={{ position: 'relative', flexGrow: 1, minHeight: 0, display: 'flex', flexDirection: 'column' }}>
+      <Box ref={scrollRef} onScroll={handleScroll} sx={{
+        flexGrow: 1,
+        overflowY: 'auto',
+        p: compact ? 1 : 3,
+        display: 'flex',
+        flexDirection: 'column',
+        gap: 2,
+      }}>
+        {messages.length === 0 ? (
+          <Box sx={{
+            display: 'flex',
+            alignItems: 'center',
+            justifyContent: 'center',
+            height: '100%',
+          }}>
+            <Typography variant="body1" sx={{ color: 'rgba(205, 214, 244, 0.5)' }}>
+              Start a conversation...
+            </Typography>
+          </Box>
+        ) : (
+          <>
+            {messages.map((message) => (
+              <MessageBlock
+                key={message.id}
+                message={message}
+                allMessages={messages}
+                thinkingDisplay={thinkingDisplay}
+                thinkingRenderLimit={thinkingRenderLimit}
+                thinkingToggleCount={thinkingToggle?.messageId === message.id ? thinkingToggle.count : 0}
+                showStats={showStats}
+                pendingPermissions={pendingPermissions}
+                toolCallStatuses={toolCallStatuses}
+                onEditMessage={onEditMessage}
+                onDeleteMessage={onDeleteMessage}
+                isLastAssistant={lastAssistantMessage?.id === message.id && !isLoading}
+                onRegenerate={onRegenerate}
+                isLastMessage={lastVisibleMessage?.id === message.id && !isLoading}
+                onContinue={onContinue}
+                onFork={onFork}
+                isLoading={isLoading}
+                isStreaming={isLoading && message.id === messages[messages.length - 1].id}
+                selected={selection.active && selection.messageId === message.id}
+                editRequestCount={editRequest.messageId === message.id ? editRequest.count : 0}
+                compact={compact}
+              />
+            ))}
+            {shouldShowLoading && (
+              <Box sx={{
+                display: 'flex',
+                gap: 0,
+                alignItems: 'flex-start',
+              }}>
+                <Box sx={{
+                  flexGrow: 1,
+                  minWidth: 0,
+                  ...messageGutter('#a6e3a1', compact),
+                }}>
+                  <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5 }}>
+                    Assistant
+                  </Typography>
+                  <LoadingIndicator status={streamStatus} />
+                </Box>
+              </Box>
+            )}
+          </>
+        )}
+        <div ref={messagesEndRef} />
+      </Box>
+      {findQuery && (
         <Box sx={{
+          position: 'absolute',
+          top: 8,
+          right: 24,
           display: 'flex',
           alignItems: 'center',
-          justifyContent: 'center',
-          height: '100%',
+          gap: 0.5,
+          pl: 1.5,
+          pr: 0.5,
+          py: 0.25,
+          backgroundColor: '#313244',
+          border: '1px solid rgba(108, 112, 134, 0.4)',
+          borderRadius: 1,
         }}>
-          <Typography variant="body1" sx={{ color: 'rgba(205, 214, 244, 0.5)' }}>
-            Start a conversation...
+          <Typography variant="caption" sx={{ color: '#cdd6f4', maxWidth: 240, overflow: 'hidden', textOverflow: 'ellipsis', whiteSpace: 'nowrap' }}>
+            {findQuery}
+          </Typography>
+          <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', mx: 0.5 }}>
+            {findPosition.total === 0 ? 'no matches' : `${findPosition.current + 1} of ${findPosition.total}`}
           </Typography>
+          <IconButton size="small" title="Previous match (Shift+F3)" disabled={findPosition.total === 0} onClick={() => showFindMatch(findIndexRef.current - 1, true)} sx={{ color: '#cdd6f4' }}>
+            <ChevronUp size={14} />
+          </IconButton>
+          <IconButton size="small" title="Next match (F3)" disabled={findPosition.total === 0} onClick={() => showFindMatch(findIndexRef.current + 1, true)} sx={{ color: '#cdd6f4' }}>
+            <ChevronDown size={14} />
+          </IconButton>
+          <IconButton size="small" title="Close (Esc)" onClick={() => setFindQuery(null)} sx={{ color: '#cdd6f4' }}>
+            <X size={14} />
+          </IconButton>
         </Box>
-      ) : (
-        <>
-          {messages.map((message) => (
-            <MessageBlock
-              key={message.id}
-              message={message}
-              allMessages={messages}
-              pendingPermissions={pendingPermissions}
-              toolCallStatuses={toolCallStatuses}
-              onEditMessage={onEditMessage}
-              onDeleteMessage={onDeleteMessage}
-              isLastAssistant={lastAssistantMessage?.id === message.id && !isLoading}
-              onRegenerate={onRegenerate}
-              isLastMessage={lastVisibleMessage?.id === message.id && !isLoading}
-              onContinue={onContinue}
-              onFork={onFork}
-              isLoading={isLoading}
-            />
-          ))}
-          {shouldShowLoading && (
-            <Box sx={{
-              display: 'flex',
-              gap: 0,
-              alignItems: 'flex-start',
-            }}>
-              <Box sx={{
-                flexGrow: 1,
-                minWidth: 0,
-                borderLeft: `4px solid #a6e3a1`,
-                pl: 2,
-              }}>
-                <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5 }}>
-                  Assistant
-                </Typography>
-                <LoadingIndicator />
-              </Box>
-            </Box>
-          )}
-        </>
       )}
-      <div ref={messagesEndRef} />
+      {selection.active && selection.messageId && (
+        <Box sx={{
+          position: 'absolute',
+          left: 24,
+          right: 24,
+          bottom: 8,
+          px: 1.5,
+          py: 0.5,
+          backgroundColor: '#313244',
+          border: '1px solid rgba(108, 112, 134, 0.4)',
+          borderRadius: 1,
+        }}>
+          <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.8)', fontFamily: 'monospace' }}>
+            {`#${getMessageNumber(messages, selection.messageId)} · j/k move · y copy · p pin · e edit · +/- rate · 0 unrate · dd delete · f fork · Enter inspect · Esc back`}
+          </Typography>
+        </Box>
+      )}
+      {showJump && !selection.active && (
+        <IconButton
+          size="small"
+          onClick={() => scrollToBottom()}
+          title={`Jump to latest (${navigator.platform.toUpperCase().indexOf('MAC') >= 0 ? '⌘' : 'Ctrl'}+End)`}
+          sx={{
+            position: 'absolute',
+            right: 24,
+            bottom: 16,
+            backgroundColor: '#313244',
+            color: '#cdd6f4',
+            border: '1px solid rgba(108, 112, 134, 0.4)',
+            '&:hover': { backgroundColor: '#45475a' },
+          }}
+        >
+          <ArrowDown size={16} />
+        </IconButton>
+      )}
     </Box>
   );
 }
 
-function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatuses, onEditMessage, onDeleteMessage, isLastAssistant, onRegenerate, isLastMessage, onContinue, onFork, isLoading }: {
+// Long reasoning is cut to its last `limit` characters, the start of the cut at a line break when one is close
+function ThinkingText({ text, limit, onShowAll }: { text: string; limit: number; onShowAll: () => void }) {
+  if (!limit || text.length <= limit) {
+    return <>{text}</>;
+  }
+
+  let start = text.length - limit;
+  const lineBreak = text.indexOf('\n', start);
+  if (lineBreak >= 0 && lineBreak - start < 200) {
+    start = lineBreak + 1;
+  }
+
+  return (
+    <>
+      <Box
+        component="span"
+        onClick={onShowAll}
+        sx={{
+          display: 'block',
+          mb: 1,
+          color: '#f5c2e7',
+          cursor: 'pointer',
+          fontStyle: 'italic',
+          '&:hover': { textDecoration: 'underline' },
+        }}
+      >
+        … {start.toLocaleString()} earlier characters hidden, show all
+      </Box>
+      {text.substring(start)}
+    </>
+  );
+}
+
+// "412 tokens · 38.5 tok/s · prompt 1,024 · 11.2s"
+function formatGenerationStats(stats: GenerationStats): string {
+  const parts = [`${stats.completionTokens.toLocaleString()} tokens`];
+  if (stats.evalDurationMs) {
+    parts.push(`${(stats.completionTokens / (stats.evalDurationMs / 1000)).toFixed(1)} tok/s`);
+  }
+  parts.push(`prompt ${stats.promptTokens.toLocaleString()}`);
+  if (stats.totalDurationMs) {
+    parts.push(`${(stats.totalDurationMs / 1000).toFixed(1)}s`);
+  }
+  return parts.join(' · ');
+}
+
+function MessageBlock({ message, allMessages, thinkingDisplay, thinkingRenderLimit, thinkingToggleCount, showStats, pendingPermissions, toolCallStatuses, onEditMessage, onDeleteMessage, isLastAssistant, onRegenerate, isLastMessage, onContinue, onFork, isLoading, isStreaming, selected, editRequestCount, compact }: {
   message: ChatMessage;
   allMessages: ChatMessage[];
+  thinkingDisplay: ThinkingDisplay;
+  thinkingRenderLimit: number;
+  thinkingToggleCount: number;
+  showStats: boolean;
   pendingPermissions?: Map<string, {
     onAllow: () => void;
     onDeny: () => void;
@@ -174,13 +615,87 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
   onContinue?: () => void;
   onFork?: (messageId: string) => void;
   isLoading?: boolean;
+  isStreaming?: boolean;
+  selected?: boolean;
+  editRequestCount: number;
+  compact: boolean;
 }) {
   const isUser = message.role === 'user';
   const isTool = message.role === 'tool';
-  const [thinkingExpanded, setThinkingExpanded] = useState(false);
+  const [thinkingExpanded, setThinkingExpanded] = useState(thinkingDisplay === 'expanded');
+  const [showFullThinking, setShowFullThinking] = useState(false);
+  const [thinkingRevealed, setThinkingRevealed] = useState(false);
+  const [summaryExpanded, setSummaryExpanded] = useState(false);
   const [isEditing, setIsEditing] = useState(false);
   const [editContent, setEditContent] = useState(message.content);
 
+  // Changing the preference resets any per-message toggles
+  useEffect(() => {
+    setThinkingExpanded(thinkingDisplay === 'expanded');
+    setThinkingRevealed(false);
+  }, [thinkingDisplay]);
+
+  // Keyboard toggle aimed at this message; a hidden pane is revealed expanded
+  const handledToggleRef = useRef(thinkingToggleCount);
+  useEffect(() => {
+    if (thinkingToggleCount === 0 || thinkingToggleCount === handledToggleRef.current) {
+      return;
+    }
+    handledToggleRef.current = thinkingToggleCount;
+    if (thinkingDisplay === 'hidden' && !thinkingRevealed) {
+      setThinkingRevealed(true);
+      setThinkingExpanded(true);
+      return;
+    }
+    setThinkingExpanded(expanded => !expanded);
+  }, [thinkingToggleCount, thinkingDisplay, thinkingRevealed]);
+
+  // Edit key of the message selection
+  const handledEditRef = useRef(editRequestCount);
+  useEffect(() => {
+    if (editRequestCount === 0 || editRequestCount === handledEditRef.current) {
+      return;
+    }
+    handledEditRef.current = editRequestCount;
+    setIsEditing(true);
+    setEditContent(message.content);
+  }, [editRequestCount, message.content]);
+
+  const blockRef = useRef<HTMLDivElement>(null);
+  useEffect(() => {
+    if (selected) {
+      blockRef.current?.scrollIntoView({ block: 'nearest' });
+    }
+  }, [selected]);
+
+  // Summary standing in for older turns in the context sent to the model
+  if (message.contextSummary) {
+    const count = message.contextSummary.messageIds.filter(id => allMessages.some(m => m.id === id && !m.contextSummary)).length;
+    return (
+      <Box sx={{
+        border: '1px dashed rgba(108, 112, 134, 0.4)',
+        borderRadius: 1,
+        px: 1.5,
+        py: 1,
+      }}>
+        <Box
+          onClick={() => setSummaryExpanded(!summaryExpanded)}
+          sx={{ display: 'flex', alignItems: 'center', gap: 0.75, cursor: 'pointer', color: 'rgba(205, 214, 244, 0.6)' }}
+        >
+          {summaryExpanded ? <ChevronDown size={14} /> : <ChevronRight size={14} />}
+          <Typography variant="caption">
+            Summary of {count} earlier message{count === 1 ? '' : 's'} · {message.contextSummary.modelId}
+          </Typography>
+        </Box>
+        <Collapse in={summaryExpanded}>
+          <Box sx={{ mt: 1, color: '#cdd6f4' }}>
+            <MarkdownMessage content={message.content} />
+          </Box>
+        </Collapse>
+      </Box>
+    );
+  }
+
   // Check if this tool message is orphaned (no corresponding assistant message with tool_calls)
   if (isTool) {
     // Find if there's an assistant message that has this tool call
@@ -209,8 +724,7 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
           <Box sx={{ 
             flexGrow: 1, 
             minWidth: 0,
-            borderLeft: `4px solid #f9e2af`,
-            pl: 2,
+            ...messageGutter('#f9e2af', compact),
           }}>
             <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5 }}>
               Tool Result (orphaned)
@@ -220,6 +734,8 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
               toolCallArgs={{}}
               result={parsedResult}
               isPendingPermission={false}
+              images={message.images}
+              resultView={message.resultView}
             />
           </Box>
         </Box>
@@ -231,7 +747,7 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
   }
 
   // Don't render empty assistant messages - they'll be shown by the loading indicator
-  if (message.role === 'assistant' && !message.content && (!message.tool_calls || message.tool_calls.length === 0)) {
+  if (message.role === 'assistant' && !message.content && !message.thinking && (!message.tool_calls || message.tool_calls.length === 0)) {
     return null;
   }
 
@@ -278,27 +794,58 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
 
   return (
     <Box 
+      ref={blockRef}
       sx={{
         display: 'flex',
         gap: 0,
         alignItems: 'flex-start',
         position: 'relative',
+        ...(selected && {
+          backgroundColor: 'rgba(137, 180, 250, 0.08)',
+          outline: '1px solid rgba(137, 180, 250, 0.4)',
+          borderRadius: 1,
+        }),
       }}
     >
       {/* Message content with left border */}
       <Box sx={{ 
         flexGrow: 1, 
         minWidth: 0,
-        borderLeft: `4px solid ${isUser ? '#89b4fa' : '#a6e3a1'}`,
-        pl: 2,
+        ...messageGutter(isUser ? '#89b4fa' : '#a6e3a1', compact),
         position: 'relative',
       }}>
         <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5 }}>
           {isUser ? 'You' : 'Assistant'}
+          {` #${getMessageNumber(allMessages, message.id)}`}
+          {message.modelOverride ? ` · ${message.modelOverride.modelId}` : ''}
+          {message.mergedFrom ? ` · from ${message.mergedFrom.sessionName}` : ''}
+          {message.stopped ? ' (stopped)' : ''}
+          {message.pinned && (
+            <Box component="span" title="Pinned: kept in the context (/pin)" sx={{ display: 'inline-flex', ml: 1, verticalAlign: 'middle', color: '#f9e2af' }}>
+              <Pin size={12} />
+            </Box>
+          )}
+          {message.rating && (
+            <Box
+              component="span"
+              title={message.rating.note || undefined}
+              sx={{
+                display: 'inline-flex',
+                alignItems: 'center',
+                gap: 0.5,
+                ml: 1,
+                verticalAlign: 'middle',
+                color: message.rating.value === 'good' ? '#a6e3a1' : '#f38ba8',
+              }}
+            >
+              {message.rating.value === 'good' ? <ThumbsUp size={12} /> : <ThumbsDown size={12} />}
+              {message.rating.note && <span>{message.rating.note}</span>}
+            </Box>
+          )}
         </Typography>
 
         {/* Thinking/Reasoning (if present) */}
-        {message.thinking && (
+        {message.thinking && (thinkingDisplay !== 'hidden' || thinkingRevealed) && (
           <Box sx={{
             mb: 1,
             border: '1px solid rgba(245, 194, 231, 0.3)',
@@ -318,6 +865,7 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
                 },
               }}
               onClick={() => setThinkingExpanded(!thinkingExpanded)}
+              title={`Show or hide thinking (${navigator.platform.toUpperCase().indexOf('MAC') >= 0 ? '⌘' : 'Ctrl'}+Shift+T)`}
             >
               <IconButton size="small" sx={{ color: '#f5c2e7', p: 0 }}>
                 {thinkingExpanded ? <ChevronDown size={16} /> : <ChevronRight size={16} />}
@@ -341,7 +889,11 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
                   maxHeight: '300px',
                   overflowY: 'auto',
                 }}>
-                  {message.thinking}
+                  <ThinkingText
+                    text={message.thinking}
+                    limit={showFullThinking ? 0 : thinkingRenderLimit}
+                    onShowAll={() => setShowFullThinking(true)}
+                  />
                 </Box>
               </Box>
             </Collapse>
@@ -383,11 +935,27 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
         ) : (
           message.content && (
             <Box sx={{ wordBreak: 'break-word' }}>
-              <MarkdownMessage content={message.content} />
+              <MarkdownMessage content={message.content} streaming={isStreaming} />
             </Box>
           )
         )}
 
+        {isUser && message.images && message.images.length > 0 && (
+          <MessageImages images={message.images} />
+        )}
+
+        {showStats && message.generationStats && !isStreaming && (
+          <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.4)', display: 'block', mt: 0.5, fontFamily: 'monospace' }}>
+            {formatGenerationStats(message.generationStats)}
+          </Typography>
+        )}
+
+        {message.doneReason === 'length' && (
+          <Typography variant="caption" sx={{ color: '#f9e2af', display: 'block', mt: 0.5 }}>
+            Response truncated: hit length limit
+          </Typography>
+        )}
+
         {/* Tool calls with their results */}
         {message.tool_calls && message.tool_calls.length > 0 && (
           <Box sx={{ mt: 1 }}>
@@ -422,6 +990,7 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
               return (
                 <ToolResultDisplay
                   key={toolCall.id || index}
+                  toolCallId={toolCall.id}
                   toolCallName={toolCall.function.name}
                   toolCallArgs={args}
                   result={parsedResult}
@@ -430,6 +999,8 @@ function MessageBlock({ message, allMessages, pendingPermissions, toolCallStatus
                   onPermissionDeny={pendingPermission?.onDeny}
                   previewData={pendingPermission && 'previewData' in pendingPermission ? pendingPermission.previewData : undefined}
                   permissionStatus={status}
+                  images={toolResult?.images}
+                  resultView={toolResult?.resultView}
                 />
               );
             })}
@@ -0,0 +1,158 @@
+import { Box, Dialog, DialogTitle, DialogContent, IconButton, List, ListItemButton, ListItemText, TextField, Typography, CircularProgress } from '@mui/material';
+import { X } from 'lucide-react';
+import { useEffect, useMemo, useState } from 'react';
+import type { ModelConfig, ProviderConfig } from '../../types/chat';
+
+interface ModelPickerProps {
+  provider: ProviderConfig | null;
+  models: ModelConfig[] | null; // null while loading
+  error: string | null;
+  initialFilter?: string; // What /model was given when it matched more than one model
+  currentModelId?: string;
+  onSelect: (model: ModelConfig) => void;
+  onClose: () => void;
+}
+
+const formatSize = (bytes: number) =>
+  bytes >= 1e9 ? `${(bytes / 1e9).toFixed(1)} GB` : `${Math.max(1, Math.round(bytes / 1e6))} MB`;
+
+// "8.0B · Q4_K_M · llama · 4.9 GB", from what the server reports
+function describeDetails(model: ModelConfig): string {
+  const details = model.details;
+  if (!details) {
+    return '';
+  }
+  return [details.parameterSize, details.quantization, details.family, details.size ? formatSize(details.size) : undefined]
+    .filter(Boolean)
+    .join(' · ');
+}
+
+// Models reported by the provider's server (/models, /model), filterable and keyboard selectable
+export function ModelPicker({ provider, models, error, initialFilter, currentModelId, onSelect, onClose }: ModelPickerProps) {
+  const [filter, setFilter] = useState('');
+  const [highlighted, setHighlighted] = useState(0);
+
+  useEffect(() => {
+    setFilter(initialFilter ?? '');
+    setHighlighted(0);
+  }, [provider?.id, initialFilter]);
+
+  const visible = useMemo(() => {
+    const needle = filter.trim().toLowerCase();
+    return (models || [])
+      .filter(m => m.type !== 'embedding')
+      .filter(m => !needle || [m.id, m.name, describeDetails(m)].some(text => text.toLowerCase().includes(needle)));
+  }, [models, filter]);
+
+  useEffect(() => {
+    setHighlighted(index => Math.min(index, Math.max(0, visible.length - 1)));
+  }, [visible.length]);
+
+  const isConfigured = (model: ModelConfig) => provider?.models.some(m => m.id === model.id) ?? false;
+
+  const handleKeyDown = (e: React.KeyboardEvent) => {
+    if (e.key === 'ArrowDown') {
+      e.preventDefault();
+      setHighlighted(index => Math.min(index + 1, visible.length - 1));
+    } else if (e.key === 'ArrowUp') {
+      e.preventDefault();
+      setHighlighted(index => Math.max(index - 1, 0));
+    } else if (e.key === 'Enter' && visible[highlighted]) {
+      e.preventDefault();
+      onSelect(visible[highlighted]);
+    }
+  };
+
+  return (
+    <Dialog
+      open={!!provider}
+      onClose={onClose}
+      maxWidth="sm"
+      fullWidth
+      PaperProps={{
+        sx: {
+          backgroundColor: '#313244',
+          color: '#cdd6f4',
+          maxHeight: '70vh',
+        },
+      }}
+    >
+      <DialogTitle sx={{ display: 'flex', alignItems: 'center', py: 1.5 }}>
+        <Typography component="span" sx={{ flex: 1, fontWeight: 600 }}>
+          {provider?.name} models
+        </Typography>
+        <IconButton size="small" onClick={onClose} sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+          <X size={16} />
+        </IconButton>
+      </DialogTitle>
+      <DialogContent sx={{ pt: 0 }}>
+        <TextField
+          autoFocus
+          fullWidth
+          size="small"
+          placeholder="Filter models"
+          value={filter}
+          onChange={(e) => setFilter(e.target.value)}
+          onKeyDown={handleKeyDown}
+          sx={{
+            mb: 1,
+            '& .MuiOutlinedInput-root': {
+              color: '#cdd6f4',
+              backgroundColor: '#1e1e2e',
+              '& fieldset': { borderColor: 'rgba(205, 214, 244, 0.2)' },
+            },
+          }}
+        />
+
+        {models === null && (
+          <Box sx={{ display: 'flex', justifyContent: 'center', py: 3 }}>
+            <CircularProgress size={20} sx={{ color: '#89b4fa' }} />
+          </Box>
+        )}
+
+        {error && (
+          <Typography variant="body2" sx={{ color: '#f38ba8', py: 1 }}>
+            {error}
+          </Typography>
+        )}
+
+        {models !== null && !error && visible.length === 0 && (
+          <Typography variant="body2" sx={{ color: 'rgba(205, 214, 244, 0.6)', py: 1 }}>
+            {filter ? 'No models match the filter.' : 'The server reported no models.'}
+          </Typography>
+        )}
+
+        <List dense disablePadding>
+          {visible.map((model, index) => (
+            <ListItemButton
+              key={model.id}
+              selected={index === highlighted}
+              onClick={() => onSelect(model)}
+              onMouseEnter={() => setHighlighted(index)}
+              sx={{
+                borderRadius: 0.5,
+                '&.Mui-selected, &.Mui-selected:hover': { backgroundColor: 'rgba(137, 180, 250, 0.15)' },
+              }}
+            >
+              <ListItemText
+                primary={model.id}
+                secondary={[
+                  describeDetails(model),
+                  isConfigured(model) ? (model.name !== model.id ? model.name : '') : 'Not in providers.yaml, available for this run',
+                ].filter(Boolean).join(' — ') || null}
+                primaryTypographyProps={{
+                  sx: {
+                    fontFamily: 'monospace',
+                    fontSize: '13px',
+                    color: model.id === currentModelId ? '#a6e3a1' : '#cdd6f4',
+                  },
+                }}
+                secondaryTypographyProps={{ sx: { color: 'rgba(205, 214, 244, 0.5)', fontSize: '11px' } }}
+              />
+            </ListItemButton>
+          ))}
+        </List>
+      </DialogContent>
+    </Dialog>
+  );
+}
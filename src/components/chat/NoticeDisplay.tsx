@@ -0,0 +1,76 @@
+import { Box, Typography, IconButton, Button } from '@mui/material';
+import { X } from 'lucide-react';
+
+interface NoticeDisplayProps {
+  notice: string | null;
+  onDismiss: () => void;
+  action?: {
+    label: string;
+    onClick: () => void;
+  };
+}
+
+export function NoticeDisplay({ notice, onDismiss, action }: NoticeDisplayProps) {
+  if (!notice) return null;
+
+  return (
+    <Box sx={{
+      p: 2,
+      backgroundColor: 'rgba(137, 180, 250, 0.1)',
+      borderBottom: '1px solid rgba(137, 180, 250, 0.3)',
+      display: 'flex',
+      alignItems: 'flex-start',
+      justifyContent: 'space-between',
+      gap: 1,
+      maxHeight: '40vh',
+      overflowY: 'auto',
+    }}>
+      <Typography
+        variant="body2"
+        component="pre"
+        sx={{
+          color: '#89b4fa',
+          flexGrow: 1,
+          m: 0,
+          fontFamily: 'monospace',
+          fontSize: '12px',
+          whiteSpace: 'pre-wrap',
+          wordBreak: 'break-word',
+        }}
+      >
+        {notice}
+      </Typography>
+      {action && (
+        <Button
+          size="small"
+          onClick={action.onClick}
+          sx={{
+            color: '#89b4fa',
+            textTransform: 'none',
+            py: 0,
+            whiteSpace: 'nowrap',
+            '&:hover': {
+              backgroundColor: 'rgba(137, 180, 250, 0.2)',
+            },
+          }}
+        >
+          {action.label}
+        </Button>
+      )}
+      <IconButton
+        size="small"
+        onClick={onDismiss}
+        sx={{
+          color: '#89b4fa',
+          p: 0.5,
+          '&:hover': {
+            backgroundColor: 'rgba(137, 180, 250, 0.2)',
+          },
+        }}
+        title="Dismiss"
+      >
+        <X size={16} />
+      </IconButton>
+    </Box>
+  );
+}
@@ -0,0 +1,326 @@
+import { Box, Dialog, IconButton, Typography } from '@mui/material';
+import { X } from 'lucide-react';
+import { useCallback, useEffect, useMemo, useRef, useState } from 'react';
+import { AnsiLine } from './AnsiText';
+import { parseAnsiLines } from '../../utils/ansi';
+
+// less-style viewer for tool output that doesn't fit in the transcript.
+// Only the visible window of lines is rendered, so multi-megabyte output stays responsive.
+// Terminal colors are kept; search runs on the text without them, and a line
+// with a match shows the match highlighted in place of its colors.
+
+interface OutputPagerProps {
+  open: boolean;
+  title: string;
+  text: string;
+  onClose: () => void;
+}
+
+const LINE_HEIGHT = 18;
+
+const KEY_HELP = 'j/k line · space/b page · d/u half · g/G top/end · /search · n/N next/prev · q quit';
+
+function HighlightedLine({ line, pattern }: { line: string; pattern: RegExp | null }) {
+  if (!pattern || !line) {
+    return <>{line || ' '}</>;
+  }
+
+  const parts: React.ReactNode[] = [];
+  let last = 0;
+  pattern.lastIndex = 0;
+  for (let match = pattern.exec(line); match; match = pattern.exec(line)) {
+    if (match[0].length === 0) {
+      pattern.lastIndex++;
+      continue;
+    }
+    parts.push(line.substring(last, match.index));
+    parts.push(
+      <mark key={match.index} style={{ backgroundColor: '#f9e2af', color: '#1e1e2e' }}>
+        {match[0]}
+      </mark>
+    );
+    last = match.index + match[0].length;
+  }
+  parts.push(line.substring(last));
+  return <>{parts}</>;
+}
+
+function matchesLine(pattern: RegExp, line: string): boolean {
+  pattern.lastIndex = 0;
+  return pattern.test(line);
+}
+
+function escapeRegExp(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+export function OutputPager({ open, title, text, onClose }: OutputPagerProps) {
+  const styledLines = useMemo(() => parseAnsiLines(text), [text]);
+  const lines = useMemo(() => styledLines.map(line => line.map(segment => segment.text).join('')), [styledLines]);
+  const [top, setTop] = useState(0);
+  const [rows, setRows] = useState(30);
+  const [searchInput, setSearchInput] = useState<string | null>(null);
+  const [query, setQuery] = useState('');
+  const [message, setMessage] = useState('');
+  const viewportRef = useRef<HTMLDivElement>(null);
+  const searchRef = useRef<HTMLInputElement>(null);
+
+  const maxTop = Math.max(0, lines.length - rows);
+  const scrollTo = useCallback((line: number) => {
+    setTop(Math.max(0, Math.min(line, maxTop)));
+  }, [maxTop]);
+
+  // Case-insensitive unless the query has uppercase letters (less -i semantics)
+  const pattern = useMemo(() => {
+    if (!query) {
+      return null;
+    }
+    return new RegExp(escapeRegExp(query), /[A-Z]/.test(query) ? 'g' : 'gi');
+  }, [query]);
+
+  const findMatch = useCallback((from: number, direction: 1 | -1, re: RegExp | null = pattern): boolean => {
+    if (!re) {
+      return false;
+    }
+    for (let i = from; i >= 0 && i < lines.length; i += direction) {
+      re.lastIndex = 0;
+      if (re.test(lines[i])) {
+        setTop(Math.max(0, Math.min(i, Math.max(0, lines.length - rows))));
+        setMessage('');
+        return true;
+      }
+    }
+    setMessage('Pattern not found');
+    return false;
+  }, [lines, rows, pattern]);
+
+  useEffect(() => {
+    if (open) {
+      setTop(0);
+      setQuery('');
+      setSearchInput(null);
+      setMessage('');
+    }
+  }, [open, text]);
+
+  // Fit the page size to the dialog
+  const measure = useCallback(() => {
+    if (viewportRef.current) {
+      setRows(Math.max(1, Math.floor(viewportRef.current.clientHeight / LINE_HEIGHT)));
+    }
+  }, []);
+
+  useEffect(() => {
+    if (!open) {
+      return;
+    }
+    window.addEventListener('resize', measure);
+    return () => window.removeEventListener('resize', measure);
+  }, [open, measure]);
+
+  useEffect(() => {
+    if (searchInput !== null) {
+      searchRef.current?.focus();
+    } else {
+      viewportRef.current?.focus();
+    }
+  }, [searchInput]);
+
+  const handleKeyDown = (e: React.KeyboardEvent) => {
+    if (searchInput !== null) {
+      return;
+    }
+
+    const half = Math.max(1, Math.floor(rows / 2));
+    let handled = true;
+    switch (e.key) {
+      case 'j':
+      case 'ArrowDown':
+      case 'Enter':
+        scrollTo(top + 1);
+        break;
+      case 'k':
+      case 'ArrowUp':
+        scrollTo(top - 1);
+        break;
+      case ' ':
+      case 'f':
+      case 'PageDown':
+        scrollTo(top + rows);
+        break;
+      case 'b':
+      case 'PageUp':
+        scrollTo(top - rows);
+        break;
+      case 'd':
+        scrollTo(top + half);
+        break;
+      case 'u':
+        scrollTo(top - half);
+        break;
+      case 'g':
+      case 'Home':
+        scrollTo(0);
+        break;
+      case 'G':
+      case 'End':
+        scrollTo(maxTop);
+        break;
+      case '/':
+        setSearchInput('');
+        break;
+      case 'n':
+        findMatch(top + 1, 1);
+        break;
+      case 'N':
+        findMatch(top - 1, -1);
+        break;
+      case 'q':
+      case 'Escape':
+        onClose();
+        break;
+      default:
+        handled = false;
+    }
+
+    if (handled) {
+      e.preventDefault();
+      e.stopPropagation();
+    }
+  };
+
+  const handleSearchKeyDown = (e: React.KeyboardEvent<HTMLInputElement>) => {
+    if (e.key === 'Enter') {
+      e.preventDefault();
+      const value = searchInput || '';
+      setSearchInput(null);
+      if (value) {
+        setQuery(value);
+        const re = new RegExp(escapeRegExp(value), /[A-Z]/.test(value) ? 'g' : 'gi');
+        findMatch(top, 1, re);
+      }
+    } else if (e.key === 'Escape') {
+      e.preventDefault();
+      e.stopPropagation();
+      setSearchInput(null);
+    }
+  };
+
+  const handleWheel = (e: React.WheelEvent) => {
+    scrollTo(top + Math.sign(e.deltaY) * 3);
+  };
+
+  const visible = lines.slice(top, top + rows);
+  const lastShown = Math.min(lines.length, top + rows);
+  const percent = lines.length > 0 ? Math.round((lastShown / lines.length) * 100) : 100;
+  const gutterWidth = String(lines.length).length;
+
+  return (
+    <Dialog
+      open={open}
+      onClose={onClose}
+      maxWidth={false}
+      TransitionProps={{
+        onEntered: () => {
+          measure();
+          viewportRef.current?.focus();
+        },
+      }}
+      PaperProps={{
+        sx: {
+          width: '90vw',
+          height: '85vh',
+          backgroundColor: '#1e1e2e',
+          border: '1px solid rgba(108, 112, 134, 0.3)',
+          display: 'flex',
+          flexDirection: 'column',
+        },
+      }}
+    >
+      <Box sx={{
+        display: 'flex',
+        alignItems: 'center',
+        px: 1.5,
+        py: 0.75,
+        borderBottom: '1px solid rgba(108, 112, 134, 0.2)',
+        backgroundColor: 'rgba(108, 112, 134, 0.1)',
+      }}>
+        <Typography variant="body2" sx={{ color: '#cdd6f4', fontFamily: 'monospace', fontSize: '12px', flex: 1, overflow: 'hidden', textOverflow: 'ellipsis', whiteSpace: 'nowrap' }}>
+          {title}
+        </Typography>
+        <IconButton size="small" onClick={onClose} sx={{ color: 'rgba(205, 214, 244, 0.6)' }}>
+          <X size={16} />
+        </IconButton>
+      </Box>
+
+      <Box
+        ref={viewportRef}
+        tabIndex={0}
+        onKeyDown={handleKeyDown}
+        onWheel={handleWheel}
+        sx={{
+          flex: 1,
+          overflow: 'hidden',
+          outline: 'none',
+          px: 1.5,
+          fontFamily: 'monospace',
+          fontSize: '12px',
+          lineHeight: `${LINE_HEIGHT}px`,
+          color: '#cdd6f4',
+        }}
+      >
+        {visible.map((line, index) => (
+          <Box key={top + index} sx={{ display: 'flex', whiteSpace: 'pre', height: LINE_HEIGHT }}>
+            <Box component="span" sx={{ color: 'rgba(205, 214, 244, 0.3)', pr: 1.5, userSelect: 'none', flexShrink: 0 }}>
+              {String(top + index + 1).padStart(gutterWidth)}
+            </Box>
+            <Box component="span" sx={{ overflow: 'hidden', textOverflow: 'ellipsis' }}>
+              {pattern && matchesLine(pattern, line)
+                ? <HighlightedLine line={line} pattern={pattern} />
+                : <AnsiLine segments={styledLines[top + index]} />}
+            </Box>
+          </Box>
+        ))}
+      </Box>
+
+      <Box sx={{
+        display: 'flex',
+        alignItems: 'center',
+        gap: 2,
+        px: 1.5,
+        py: 0.5,
+        borderTop: '1px solid rgba(108, 112, 134, 0.2)',
+        fontFamily: 'monospace',
+        fontSize: '11px',
+        color: 'rgba(205, 214, 244, 0.6)',
+      }}>
+        {searchInput !== null ? (
+          <Box sx={{ display: 'flex', flex: 1 }}>
+            <span>/</span>
+            <input
+              ref={searchRef}
+              value={searchInput}
+              onChange={(e) => setSearchInput(e.target.value)}
+              onKeyDown={handleSearchKeyDown}
+              style={{
+                flex: 1,
+                border: 'none',
+                outline: 'none',
+                background: 'transparent',
+                color: '#cdd6f4',
+                font: 'inherit',
+              }}
+            />
+          </Box>
+        ) : (
+          <>
+            <span style={{ color: message ? '#f38ba8' : undefined }}>
+              {message || `lines ${lines.length > 0 ? top + 1 : 0}-${lastShown}/${lines.length} ${lastShown >= lines.length ? '(END)' : `${percent}%`}`}
+            </span>
+            <span style={{ marginLeft: 'auto', color: 'rgba(205, 214, 244, 0.4)' }}>{KEY_HELP}</span>
+          </>
+        )}
+      </Box>
+    </Dialog>
+  );
+}
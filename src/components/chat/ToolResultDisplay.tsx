@@ -1,11 +1,21 @@
-import { Box, Typography, Collapse, IconButton, Button } from '@mui/material';
+import { Box, Typography, Collapse, IconButton, Button, Table, TableBody, TableCell, TableHead, TableRow, TextField } from '@mui/material';
 import { ChevronDown, ChevronRight, Wrench, CheckCircle, XCircle, FileText, FolderTree } from 'lucide-react';
 import { useState, useEffect } from 'react';
 import { DiffViewer } from './DiffViewer';
+import { OutputPager } from './OutputPager';
+import { AnsiText } from './AnsiText';
+import { MessageImages } from './MessageImages';
+import type { MessageImage, ToolResultView } from '../../types/chat';
+import { useLiveToolOutput, type LiveToolOutput } from '../../tools/liveToolOutput';
+import { redactToolArgs } from '../../tools/redaction';
+import { answerUserInput, useUserInputRequest, type UserInputRequest } from '../../tools/userInput';
 import { Prism as SyntaxHighlighter } from 'react-syntax-highlighter';
 import { vscDarkPlus } from 'react-syntax-highlighter/dist/esm/styles/prism';
+import { detectLanguage, getLanguageFromPath } from '../../utils/codeFence';
+import { hasAnsi } from '../../utils/ansi';
 
 interface ToolResultDisplayProps {
+  toolCallId?: string;
   toolCallName: string;
   toolCallArgs: Record<string, unknown>;
   result: unknown;
@@ -14,43 +24,8 @@ interface ToolResultDisplayProps {
   onPermissionDeny?: () => void;
   previewData?: any;
   permissionStatus?: 'denied' | 'allowed';
-}
-
-// Helper to get file extension for syntax highlighting
-function getLanguageFromPath(filePath: string | undefined): string {
-  if (!filePath) return 'text';
-  const ext = filePath.split('.').pop()?.toLowerCase() || '';
-  const languageMap: Record<string, string> = {
-    'ts': 'typescript',
-    'tsx': 'tsx',
-    'js': 'javascript',
-    'jsx': 'jsx',
-    'py': 'python',
-    'rb': 'ruby',
-    'go': 'go',
-    'rs': 'rust',
-    'java': 'java',
-    'c': 'c',
-    'cpp': 'cpp',
-    'h': 'c',
-    'hpp': 'cpp',
-    'cs': 'csharp',
-    'php': 'php',
-    'sh': 'bash',
-    'bash': 'bash',
-    'zsh': 'bash',
-    'json': 'json',
-    'yaml': 'yaml',
-    'yml': 'yaml',
-    'xml': 'xml',
-    'html': 'html',
-    'css': 'css',
-    'scss': 'scss',
-    'sass': 'sass',
-    'md': 'markdown',
-    'sql': 'sql',
-  };
-  return languageMap[ext] || 'text';
+  images?: MessageImage[];
+  resultView?: ToolResultView;
 }
 
 // Custom renderer for Read tool
@@ -784,6 +759,306 @@ function WriteToolResult({ result, args }: { result: any; args: Record<string, u
   );
 }
 
+// Output longer than this is previewed inline and opened in the pager in full
+const PAGER_THRESHOLD_LINES = 20;
+
+function previewLines(text: string): { preview: string; totalLines: number } {
+  const lines = text.split('\n');
+  if (lines.length <= PAGER_THRESHOLD_LINES) {
+    return { preview: text, totalLines: lines.length };
+  }
+  return { preview: lines.slice(0, PAGER_THRESHOLD_LINES).join('\n'), totalLines: lines.length };
+}
+
+// "View all" bar under a preview, owning the pager dialog for that output
+function PagerLink({ text, title, totalLines }: { text: string; title: string; totalLines: number }) {
+  const [open, setOpen] = useState(false);
+
+  return (
+    <>
+      <Box sx={{
+        display: 'flex',
+        alignItems: 'center',
+        gap: 1,
+        px: 1,
+        py: 0.5,
+        borderTop: '1px solid rgba(108, 112, 134, 0.2)',
+        backgroundColor: 'rgba(108, 112, 134, 0.05)',
+      }}>
+        <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.5)', fontFamily: 'monospace', fontSize: '11px' }}>
+          Showing {PAGER_THRESHOLD_LINES} of {totalLines} lines
+        </Typography>
+        <Button
+          size="small"
+          startIcon={<FileText size={12} />}
+          onClick={(e) => {
+            e.stopPropagation();
+            setOpen(true);
+          }}
+          sx={{ ml: 'auto', py: 0, fontSize: '11px', textTransform: 'none', color: '#89b4fa' }}
+        >
+          Open in pager
+        </Button>
+      </Box>
+      <OutputPager open={open} title={title} text={text} onClose={() => setOpen(false)} />
+    </>
+  );
+}
+
+const formatCell = (value: unknown): string =>
+  value === null || value === undefined ? '' : typeof value === 'object' ? JSON.stringify(value) : String(value);
+
+// Renderer for tools that return a structured view next to their text content
+function StructuredToolResult({ view }: { view: ToolResultView }) {
+  const frame = {
+    backgroundColor: '#1e1e2e',
+    borderRadius: 0.5,
+    border: '1px solid rgba(108, 112, 134, 0.2)',
+    overflow: 'auto',
+    maxHeight: '300px',
+  };
+  const label = (text: string) => (
+    <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5, fontWeight: 600 }}>
+      {text}
+    </Typography>
+  );
+
+  switch (view.kind) {
+    case 'json':
+      return (
+        <Box>
+          {label('Result')}
+          <Box sx={{ ...frame, maxHeight: 'none', overflow: 'hidden' }}>
+            <HighlightedOutput text={JSON.stringify(view.data, null, 2)} title="Result" />
+          </Box>
+        </Box>
+      );
+    case 'table':
+      return (
+        <Box>
+          {label(`${view.rows.length} row${view.rows.length === 1 ? '' : 's'}`)}
+          <Box sx={frame}>
+            <Table size="small" stickyHeader>
+              <TableHead>
+                <TableRow>
+                  {view.columns.map(column => (
+                    <TableCell key={column} sx={{ backgroundColor: '#313244', color: '#89b4fa', fontWeight: 600, fontSize: '12px', borderColor: 'rgba(108, 112, 134, 0.2)' }}>
+                      {column}
+                    </TableCell>
+                  ))}
+                </TableRow>
+              </TableHead>
+              <TableBody>
+                {view.rows.map((row, rowIndex) => (
+                  <TableRow key={rowIndex} sx={{ '&:hover': { backgroundColor: 'rgba(137, 180, 250, 0.05)' } }}>
+                    {row.map((cell, cellIndex) => (
+                      <TableCell key={cellIndex} sx={{ color: '#cdd6f4', fontFamily: 'monospace', fontSize: '12px', borderColor: 'rgba(108, 112, 134, 0.1)' }}>
+                        {formatCell(cell)}
+                      </TableCell>
+                    ))}
+                  </TableRow>
+                ))}
+              </TableBody>
+            </Table>
+          </Box>
+        </Box>
+      );
+    case 'files':
+      return (
+        <Box>
+          {label(`${view.files.length} file${view.files.length === 1 ? '' : 's'}`)}
+          <Box sx={frame}>
+            {view.files.map((file, idx) => (
+              <Box
+                key={idx}
+                sx={{
+                  p: 0.75,
+                  pl: 1.5,
+                  fontFamily: 'monospace',
+                  fontSize: '12px',
+                  color: file.type === 'directory' ? '#89b4fa' : '#cdd6f4',
+                  borderBottom: idx < view.files.length - 1 ? '1px solid rgba(108, 112, 134, 0.1)' : 'none',
+                  display: 'flex',
+                  justifyContent: 'space-between',
+                  alignItems: 'center',
+                }}
+              >
+                <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
+                  {file.type === 'directory' ? <FolderTree size={14} /> : <FileText size={14} />}
+                  <span>{file.path}</span>
+                </Box>
+                {file.size !== undefined && (
+                  <Box component="span" sx={{ color: 'rgba(205, 214, 244, 0.5)', fontSize: '11px' }}>
+                    {formatBytes(file.size)}
+                  </Box>
+                )}
+              </Box>
+            ))}
+          </Box>
+        </Box>
+      );
+    case 'diff':
+      return <DiffViewer oldContent={view.oldContent} newContent={view.newContent} fileName={view.fileName} />;
+  }
+}
+
+// Output block that picks up syntax highlighting when the text looks like code or logs
+function HighlightedOutput({ text, title = 'Output', color = '#cdd6f4' }: { text: string; title?: string; color?: string }) {
+  const { preview, totalLines } = previewLines(text);
+  const paged = preview !== text;
+
+  return (
+    <>
+      <HighlightedBlock text={preview} color={color} />
+      {paged && <PagerLink text={text} title={title} totalLines={totalLines} />}
+    </>
+  );
+}
+
+function HighlightedBlock({ text, color }: { text: string; color: string }) {
+  // Output that colors itself keeps its own colors instead of being highlighted
+  const styled = hasAnsi(text);
+  const language = styled ? 'text' : detectLanguage(text);
+
+  if (language === 'text') {
+    return (
+      <Box sx={{
+        p: 1,
+        fontFamily: 'monospace',
+        fontSize: '12px',
+        color,
+        whiteSpace: 'pre-wrap',
+        wordBreak: 'break-word',
+        maxHeight: '300px',
+        overflowY: 'auto',
+      }}>
+        {styled ? <AnsiText text={text} /> : text}
+      </Box>
+    );
+  }
+
+  return (
+    <Box sx={{ maxHeight: '300px', overflowY: 'auto' }}>
+      <SyntaxHighlighter
+        language={language}
+        style={vscDarkPlus}
+        customStyle={{
+          margin: 0,
+          padding: '8px',
+          fontSize: '12px',
+          backgroundColor: 'transparent',
+        }}
+        wrapLongLines
+      >
+        {text}
+      </SyntaxHighlighter>
+    </Box>
+  );
+}
+
+// A question from the running tool, answered with one of its choices or free text
+function ToolInputPrompt({ request }: { request: UserInputRequest }) {
+  const [answer, setAnswer] = useState('');
+
+  return (
+    <Box sx={{
+      backgroundColor: '#1e1e2e',
+      borderRadius: 0.5,
+      p: 2,
+      mb: 1,
+      border: '1px solid rgba(137, 180, 250, 0.4)',
+    }}>
+      <Typography variant="body2" sx={{ color: '#89b4fa', mb: 1.5, whiteSpace: 'pre-wrap' }}>
+        {request.question}
+      </Typography>
+      <Box sx={{ display: 'flex', gap: 1, flexWrap: 'wrap', alignItems: 'center' }}>
+        {request.choices ? (
+          request.choices.map(choice => (
+            <Button
+              key={choice}
+              size="small"
+              variant="outlined"
+              onClick={(e) => {
+                e.stopPropagation();
+                answerUserInput(request.id, choice);
+              }}
+              sx={{ color: '#89b4fa', borderColor: 'rgba(137, 180, 250, 0.5)', textTransform: 'none' }}
+            >
+              {choice}
+            </Button>
+          ))
+        ) : (
+          <TextField
+            size="small"
+            autoFocus
+            value={answer}
+            onChange={(e) => setAnswer(e.target.value)}
+            onClick={(e) => e.stopPropagation()}
+            onKeyDown={(e) => {
+              if (e.key === 'Enter' && answer.trim()) {
+                answerUserInput(request.id, answer);
+              }
+            }}
+            placeholder="Answer and press Enter"
+            sx={{
+              flex: 1,
+              minWidth: 200,
+              '& .MuiOutlinedInput-root': {
+                color: '#cdd6f4',
+                fontSize: '14px',
+                '& fieldset': { borderColor: 'rgba(205, 214, 244, 0.2)' },
+                '&.Mui-focused fieldset': { borderColor: '#89b4fa' },
+              },
+            }}
+          />
+        )}
+        <Button
+          size="small"
+          onClick={(e) => {
+            e.stopPropagation();
+            answerUserInput(request.id, null);
+          }}
+          sx={{ color: 'rgba(205, 214, 244, 0.6)', textTransform: 'none' }}
+        >
+          Skip
+        </Button>
+      </Box>
+    </Box>
+  );
+}
+
+// Tail of a running command's output; the full result replaces it when the command exits
+function LiveOutput({ output }: { output: LiveToolOutput }) {
+  const tail = (text: string) => text.split('\n').slice(-PAGER_THRESHOLD_LINES).join('\n');
+
+  return (
+    <Box sx={{
+      backgroundColor: '#1e1e2e',
+      borderRadius: 0.5,
+      border: '1px solid rgba(108, 112, 134, 0.2)',
+      overflow: 'hidden',
+      mb: 0.5,
+    }}>
+      {[
+        { text: output.stdout, color: '#cdd6f4' },
+        { text: output.stderr, color: '#f38ba8' },
+      ].filter(part => part.text).map((part, index) => (
+        <Box key={index} sx={{
+          p: 1,
+          fontFamily: 'monospace',
+          fontSize: '12px',
+          color: part.color,
+          whiteSpace: 'pre-wrap',
+          wordBreak: 'break-word',
+          borderTop: index > 0 ? '1px solid rgba(243, 139, 168, 0.2)' : 'none',
+        }}>
+          <AnsiText text={tail(part.text)} />
+        </Box>
+      ))}
+    </Box>
+  );
+}
+
 // Custom renderer for Bash tool
 function BashToolResult({ result, args }: { result: any; args: Record<string, unknown> }) {
   const command = args.command as string || result?.command || 'Unknown command';
@@ -791,6 +1066,7 @@ function BashToolResult({ result, args }: { result: any; args: Record<string, un
   const stdout = result?.stdout || '';
   const stderr = result?.stderr || '';
   const exitCode = result?.exit_code;
+  const stderrPreview = previewLines(stderr);
 
   return (
     <Box>
@@ -812,35 +1088,27 @@ function BashToolResult({ result, args }: { result: any; args: Record<string, un
             $ {command}
           </Typography>
         </Box>
-        {stdout && (
-          <Box sx={{
-            p: 1,
-            fontFamily: 'monospace',
-            fontSize: '12px',
-            color: '#cdd6f4',
-            whiteSpace: 'pre-wrap',
-            wordBreak: 'break-word',
-            maxHeight: '300px',
-            overflowY: 'auto',
-          }}>
-            {stdout}
-          </Box>
-        )}
+        {stdout && <HighlightedOutput text={stdout} title={`$ ${command}`} />}
         {stderr && (
-          <Box sx={{
-            p: 1,
-            fontFamily: 'monospace',
-            fontSize: '12px',
-            color: '#f38ba8',
-            backgroundColor: 'rgba(243, 139, 168, 0.05)',
-            whiteSpace: 'pre-wrap',
-            wordBreak: 'break-word',
-            maxHeight: '300px',
-            overflowY: 'auto',
-            borderTop: stdout ? '1px solid rgba(243, 139, 168, 0.2)' : 'none',
-          }}>
-            {stderr}
-          </Box>
+          <>
+            <Box sx={{
+              p: 1,
+              fontFamily: 'monospace',
+              fontSize: '12px',
+              color: '#f38ba8',
+              backgroundColor: 'rgba(243, 139, 168, 0.05)',
+              whiteSpace: 'pre-wrap',
+              wordBreak: 'break-word',
+              maxHeight: '300px',
+              overflowY: 'auto',
+              borderTop: stdout ? '1px solid rgba(243, 139, 168, 0.2)' : 'none',
+            }}>
+              <AnsiText text={stderrPreview.preview} />
+            </Box>
+            {stderrPreview.preview !== stderr && (
+              <PagerLink text={stderr} title={`$ ${command} (stderr)`} totalLines={stderrPreview.totalLines} />
+            )}
+          </>
         )}
         {!stdout && !stderr && (
           <Box sx={{
@@ -872,15 +1140,22 @@ function BashToolResult({ result, args }: { result: any; args: Record<string, un
 }
 
 export function ToolResultDisplay({
+  toolCallId,
   toolCallName,
-  toolCallArgs,
+  toolCallArgs: rawArgs,
   result,
   isPendingPermission = false,
   onPermissionAllow,
   onPermissionDeny,
   previewData,
   permissionStatus,
+  images,
+  resultView,
 }: ToolResultDisplayProps) {
+  const liveOutput = useLiveToolOutput(toolCallId, !isPendingPermission && result === undefined);
+  const toolCallArgs = redactToolArgs(toolCallName, rawArgs);
+  const inputRequest = useUserInputRequest(result === undefined ? toolCallId : undefined);
+
   // Built-in tools that should always be expanded (they have custom visualizations)
   const builtInTools = ['read', 'write', 'edit', 'find', 'grep', 'ls', 'bash', 'move', 'rm', 'mkdir'];
   const isBuiltInTool = builtInTools.includes(toolCallName);
@@ -889,12 +1164,12 @@ export function ToolResultDisplay({
   const shouldAutoExpand = isPendingPermission || isBuiltInTool;
   const [expanded, setExpanded] = useState(shouldAutoExpand);
   
-  // Ensure built-in tools are always expanded
+  // Ensure built-in tools are always expanded, and questions from the tool are seen
   useEffect(() => {
-    if (isBuiltInTool && !expanded) {
+    if ((isBuiltInTool || inputRequest) && !expanded) {
       setExpanded(true);
     }
-  }, [isBuiltInTool, expanded]);
+  }, [isBuiltInTool, inputRequest, expanded]);
 
   // Create compact representation for collapsed state
   const argsPreview = toolCallArgs && typeof toolCallArgs === 'object'
@@ -1111,6 +1386,8 @@ export function ToolResultDisplay({
                     </Typography>
                   </Box>
                 </Box>
+              ) : resultView ? (
+                <StructuredToolResult view={resultView} />
               ) : toolCallName === 'read' ? (
                 <ReadToolResult result={result} args={toolCallArgs} />
               ) : toolCallName === 'edit' && typeof result === 'object' && result !== null && 'old_content' in result ? (
@@ -1143,27 +1420,37 @@ export function ToolResultDisplay({
                   <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.6)', display: 'block', mb: 0.5, fontWeight: 600 }}>
                     Result
                   </Typography>
-                  <Box sx={{
-                    backgroundColor: '#1e1e2e',
-                    borderRadius: 0.5,
-                    p: 1,
-                    fontFamily: 'monospace',
-                    fontSize: '12px',
-                    color: '#cdd6f4',
-                    overflowX: 'auto',
-                  }}>
-                    <pre style={{ margin: 0 }}>
-                      {typeof result === 'string' ? result : JSON.stringify(result, null, 2)}
-                    </pre>
-                  </Box>
+                  {typeof result === 'string' || (typeof (result as any)?.content === 'string' && (result as any).content.includes('\n')) ? (
+                    <Box sx={{
+                      backgroundColor: '#1e1e2e',
+                      borderRadius: 0.5,
+                      border: '1px solid rgba(108, 112, 134, 0.2)',
+                      overflow: 'hidden',
+                    }}>
+                      <HighlightedOutput text={typeof result === 'string' ? result : (result as any).content} title={compactDisplay} />
+                    </Box>
+                  ) : (
+                    <Box sx={{
+                      backgroundColor: '#1e1e2e',
+                      borderRadius: 0.5,
+                      border: '1px solid rgba(108, 112, 134, 0.2)',
+                      overflow: 'hidden',
+                    }}>
+                      <HighlightedOutput text={JSON.stringify(result, null, 2)} title={compactDisplay} />
+                    </Box>
+                  )}
                 </Box>
               )}
             </>
           )}
 
+          {!isPendingPermission && images && images.length > 0 && <MessageImages images={images} />}
+
           {/* Show pending state if no result yet and not waiting for permission */}
           {!isPendingPermission && result === undefined && (
             <Box>
+              {inputRequest && <ToolInputPrompt key={inputRequest.id} request={inputRequest} />}
+              {liveOutput && <LiveOutput output={liveOutput} />}
               <Typography variant="caption" sx={{ color: 'rgba(205, 214, 244, 0.4)', fontStyle: 'italic' }}>
                 Executing...
               </Typography>
@@ -6,6 +6,7 @@ import { toolRegistry } from '../../tools';
 import { toolConfigManager, type ToolConfig } from '../../tools/ToolConfigManager';
 import { mcpToolsManager } from '../../tools/MCPToolsManager';
 import { EnvironmentVariablesSection } from './EnvironmentVariablesSection';
+import { useCompactLayout } from '../../hooks/useCompactLayout';
 import yaml from 'js-yaml';
 
 interface ToolsPanelProps {
@@ -17,6 +18,7 @@ interface ToolsPanelProps {
 }
 
 export function ToolsPanel({ collapsed, onToggleCollapse, onStartingStateChange, onOpenSettings, workingDirectory }: ToolsPanelProps) {
+  const compact = useCompactLayout();
   const [mcpConfig, setMcpConfig] = useState<MCPServersConfig | null>(null);
   const [serversStatus, setServersStatus] = useState<MCPServerStatus[]>([]);
   const [expandedSections, setExpandedSections] = useState<Set<string>>(new Set());
@@ -279,6 +281,16 @@ export function ToolsPanel({ collapsed, onToggleCollapse, onStartingStateChange,
           flexDirection: 'column',
           position: 'relative',
           overflow: 'hidden',
+          // Over the conversation rather than beside it when the window is too narrow for both
+          ...(compact && {
+            position: 'absolute',
+            top: 0,
+            right: 0,
+            zIndex: 2,
+            width: '100%',
+            minWidth: 0,
+            maxWidth: '280px',
+          }),
         }}>
           {/* Header */}
           <Box sx={{
@@ -0,0 +1,196 @@
+import { renderToStaticMarkup } from 'react-dom/server';
+import ReactMarkdown from 'react-markdown';
+import remarkGfm from 'remark-gfm';
+import { Prism as SyntaxHighlighter } from 'react-syntax-highlighter';
+import { oneDark } from 'react-syntax-highlighter/dist/esm/styles/prism';
+import type { ChatMessage, ToolCall } from '../../types/chat';
+import { detectLanguage } from '../../utils/codeFence';
+
+// Static rendering of a conversation for sharing. Everything is inlined (the
+// highlighter emits inline styles, the rest comes from TRANSCRIPT_CSS), so the
+// exported file opens anywhere without network access.
+
+export interface TranscriptMeta {
+  title: string;
+  providerId?: string;
+  modelId?: string;
+  workingDirectory?: string;
+  exportedAt: number;
+  systemPrompt?: string; // Included in fine-tuning exports
+}
+
+const TRANSCRIPT_CSS = `
+  * { box-sizing: border-box; }
+  body { margin: 0; background: #1e1e2e; color: #cdd6f4; font: 14px/1.6 -apple-system, "Segoe UI", Roboto, sans-serif; }
+  main { max-width: 960px; margin: 0 auto; padding: 24px; }
+  header { border-bottom: 1px solid rgba(205, 214, 244, 0.1); margin-bottom: 24px; padding-bottom: 12px; }
+  header h1 { margin: 0 0 4px; font-size: 20px; color: #89b4fa; }
+  header .meta { color: rgba(205, 214, 244, 0.5); font-size: 12px; }
+  .message { margin: 16px 0; padding: 12px 16px; border-radius: 8px; border: 1px solid rgba(205, 214, 244, 0.1); }
+  .message.user { background: #313244; }
+  .message.assistant { background: rgba(137, 180, 250, 0.05); }
+  .message.system { background: rgba(249, 226, 175, 0.05); border-color: rgba(249, 226, 175, 0.2); }
+  .role { font-size: 12px; font-weight: 600; text-transform: uppercase; letter-spacing: 0.05em; color: rgba(205, 214, 244, 0.6); margin-bottom: 6px; }
+  .role time { font-weight: 400; text-transform: none; margin-left: 8px; color: rgba(205, 214, 244, 0.4); }
+  details { margin: 8px 0; border: 1px solid rgba(205, 214, 244, 0.1); border-radius: 6px; background: rgba(0, 0, 0, 0.15); }
+  summary { cursor: pointer; padding: 6px 10px; font-size: 12px; color: rgba(205, 214, 244, 0.7); user-select: none; }
+  details > .body { padding: 0 10px 10px; }
+  details.thinking summary { color: #f5c2e7; }
+  details.tool summary { color: #a6e3a1; }
+  details.tool.failed summary { color: #f38ba8; }
+  .thinking-text { white-space: pre-wrap; font-style: italic; color: rgba(205, 214, 244, 0.7); }
+  .label { font-size: 11px; font-weight: 600; color: rgba(205, 214, 244, 0.5); margin: 8px 0 4px; }
+  p { margin: 0.5em 0; }
+  a { color: #89b4fa; }
+  blockquote { margin: 0.5em 0; padding-left: 1em; border-left: 3px solid #89b4fa; color: rgba(205, 214, 244, 0.8); }
+  h1, h2, h3, h4, h5, h6 { color: #89b4fa; margin: 0.75em 0 0.5em; }
+  code { background: rgba(205, 214, 244, 0.1); padding: 0.15em 0.4em; border-radius: 3px; font: 0.9em "Fira Code", "Courier New", monospace; }
+  pre code { background: transparent; padding: 0; }
+  table { border-collapse: collapse; margin: 0.75em 0; width: 100%; }
+  th, td { border: 1px solid rgba(205, 214, 244, 0.2); padding: 0.5em; text-align: left; }
+  th { background: rgba(137, 180, 250, 0.1); }
+  hr { border: none; border-top: 1px solid rgba(205, 214, 244, 0.2); }
+  img { display: block; max-width: 100%; margin: 8px 0; border-radius: 4px; }
+`;
+
+function CodeBlock({ code, language }: { code: string; language: string }) {
+  return (
+    <SyntaxHighlighter
+      style={oneDark as { [key: string]: React.CSSProperties }}
+      language={language}
+      PreTag="div"
+      customStyle={{ margin: 0, borderRadius: '6px', fontSize: '0.85em' }}
+    >
+      {code.replace(/\n$/, '')}
+    </SyntaxHighlighter>
+  );
+}
+
+function Markdown({ content }: { content: string }) {
+  return (
+    <ReactMarkdown
+      remarkPlugins={[remarkGfm]}
+      components={{
+        code({ className, children }) {
+          const match = /language-(\w+)/.exec(className || '');
+          return match ? (
+            <CodeBlock code={String(children)} language={match[1]} />
+          ) : (
+            <code className={className}>{children}</code>
+          );
+        },
+      }}
+    >
+      {content}
+    </ReactMarkdown>
+  );
+}
+
+function formatJson(text: string): string {
+  try {
+    return JSON.stringify(JSON.parse(text), null, 2);
+  } catch {
+    return text;
+  }
+}
+
+function ToolCallSection({ toolCall, result }: { toolCall: ToolCall; result?: ChatMessage }) {
+  const args = formatJson(toolCall.function.arguments || '{}');
+  const output = result ? formatJson(result.content) : '';
+  let failed = false;
+  try {
+    failed = result ? JSON.parse(result.content)?.success === false : false;
+  } catch {
+    // Plain-text results have no success flag
+  }
+
+  return (
+    <details className={failed ? 'tool failed' : 'tool'}>
+      <summary>Tool: {toolCall.function.name}{failed ? ' (failed)' : ''}</summary>
+      <div className="body">
+        <div className="label">Arguments</div>
+        <CodeBlock code={args} language="json" />
+        <div className="label">Result</div>
+        {result ? (
+          <CodeBlock code={output} language={detectLanguage(output)} />
+        ) : (
+          <div className="thinking-text">No result recorded</div>
+        )}
+        {result?.images?.map((image, index) => image.data ? (
+          <img key={index} src={`data:${image.mimeType};base64,${image.data}`} alt={`Tool output ${index + 1}`} />
+        ) : (
+          <div key={index} className="thinking-text">{image.mimeType} image (not embedded)</div>
+        ))}
+      </div>
+    </details>
+  );
+}
+
+function TranscriptMessage({ message, toolResults }: { message: ChatMessage; toolResults: Map<string, ChatMessage> }) {
+  return (
+    <section className={`message ${message.role}`}>
+      <div className="role">
+        {message.role}
+        {message.modelOverride && ` · ${message.modelOverride.modelId}`}
+        <time>{new Date(message.timestamp).toLocaleString()}</time>
+      </div>
+      {message.thinking && (
+        <details className="thinking">
+          <summary>Thinking</summary>
+          <div className="body thinking-text">{message.thinking}</div>
+        </details>
+      )}
+      {message.content && <Markdown content={message.content} />}
+      {message.tool_calls?.map(toolCall => (
+        <ToolCallSection key={toolCall.id} toolCall={toolCall} result={toolResults.get(toolCall.id)} />
+      ))}
+    </section>
+  );
+}
+
+function TranscriptDocument({ messages, meta }: { messages: ChatMessage[]; meta: TranscriptMeta }) {
+  // Tool results are shown inside the tool call that produced them
+  const toolResults = new Map<string, ChatMessage>();
+  for (const message of messages) {
+    if (message.role === 'tool' && message.tool_call_id) {
+      toolResults.set(message.tool_call_id, message);
+    }
+  }
+
+  const details = [
+    meta.providerId && meta.modelId ? `${meta.providerId}/${meta.modelId}` : null,
+    meta.workingDirectory,
+    `Exported ${new Date(meta.exportedAt).toLocaleString()}`,
+  ].filter(Boolean).join(' · ');
+
+  return (
+    <html lang="en">
+      <head>
+        <meta charSet="utf-8" />
+        <meta name="viewport" content="width=device-width, initial-scale=1" />
+        <title>{meta.title}</title>
+        <style dangerouslySetInnerHTML={{ __html: TRANSCRIPT_CSS }} />
+      </head>
+      <body>
+        <main>
+          <header>
+            <h1>{meta.title}</h1>
+            <div className="meta">{details}</div>
+          </header>
+          {messages
+            .filter(m => m.role !== 'tool')
+            .map(message => (
+              <TranscriptMessage key={message.id} message={message} toolResults={toolResults} />
+            ))}
+        </main>
+      </body>
+    </html>
+  );
+}
+
+/**
+ * Render a conversation as a single self-contained HTML document
+ */
+export function renderTranscriptHtml(messages: ChatMessage[], meta: TranscriptMeta): string {
+  return `<!DOCTYPE html>\n${renderToStaticMarkup(<TranscriptDocument messages={messages} meta={meta} />)}`;
+}
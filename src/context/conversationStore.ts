@@ -0,0 +1,506 @@
+import { DEFAULT_THINKING_SETTINGS, type ChatMessage, type ProviderConfig, type ModelConfig, type ToolCall, type ThinkingSettings, type GenerationOptions, type WorkspaceTrust } from '../types/chat';
+import { isLocalProvider } from '../utils/modelUtils';
+
+// The conversation: messages, the session they belong to, and the request in
+// flight. It lives here rather than in a component so there is one copy that
+// everything reads. ChatProvider renders from it and hands its dispatch to the
+// components; hooks, tools and callbacks that outlive a render read the
+// current state with getState() instead of keeping refs to a stale one.
+
+// Chat state
+export interface ChatState {
+  messages: ChatMessage[];
+  currentProvider: ProviderConfig | null;
+  currentModel: ModelConfig | null;
+  providers: ProviderConfig[];
+  isLoading: boolean;
+  error: string | null;
+  notice: string | null;
+  streamingMessageId: string | null;
+  streamStatus: string | null;
+  activePromptName: string | null;
+  offlineMode: boolean;
+  workspaceTrust: WorkspaceTrust | null; // For the working directory; null until decided with /trust
+  thinking: ThinkingSettings;
+  sessionEnv: Record<string, string>;
+  sessionSystemPrompt: string | null; // Set with /system set, replaces the selected prompt
+  generationOptions: GenerationOptions; // Set with /set, sent with every request
+  showStats: boolean; // Token counts and speed under each response, toggled with /stats
+  currentSessionId: string;
+  currentSessionName: string;
+  isCustomName: boolean;
+  contextUsage: {
+    used: number;
+    total: number;
+  } | null;
+}
+
+// Chat actions
+export type ChatAction =
+  | { type: 'ADD_MESSAGE'; payload: ChatMessage }
+  | { type: 'INSERT_MESSAGE'; payload: { afterId: string; message: ChatMessage } }
+  | { type: 'UPDATE_MESSAGE'; payload: { id: string; updates: Partial<ChatMessage> } }
+  | { type: 'DELETE_MESSAGE'; payload: string } // message ID
+  | { type: 'REWIND_TO_MESSAGE'; payload: string } // message ID; drops it and everything after it
+  | { type: 'START_STREAMING'; payload: string } // message ID
+  | { type: 'APPEND_TO_STREAMING'; payload: string } // content to append
+  | { type: 'APPEND_THINKING_TO_STREAMING'; payload: string } // thinking to append
+  | { type: 'END_STREAMING' }
+  | { type: 'CANCEL_STREAMING' }
+  | { type: 'STOP_STREAMING' }
+  | { type: 'SET_STREAM_STATUS'; payload: string | null }
+  | { type: 'SET_PROVIDER'; payload: ProviderConfig }
+  | { type: 'SET_MODEL'; payload: ModelConfig }
+  | { type: 'SET_PROVIDER_AND_MODEL'; payload: { provider: ProviderConfig; model: ModelConfig } }
+  | { type: 'SELECT_DISCOVERED_MODEL'; payload: { providerId: string; model: ModelConfig } } // Adds it to the provider for this run if it isn't configured
+  | { type: 'SET_LOADING'; payload: boolean }
+  | { type: 'SET_ERROR'; payload: string | null }
+  | { type: 'SET_NOTICE'; payload: string | null }
+  | { type: 'SET_ACTIVE_PROMPT'; payload: string | null }
+  | { type: 'SET_OFFLINE_MODE'; payload: boolean }
+  | { type: 'SET_WORKSPACE_TRUST'; payload: WorkspaceTrust | null }
+  | { type: 'SET_THINKING_SETTINGS'; payload: ThinkingSettings }
+  | { type: 'SET_SESSION_ENV'; payload: Record<string, string> }
+  | { type: 'SET_SESSION_SYSTEM_PROMPT'; payload: string | null }
+  | { type: 'SET_GENERATION_OPTIONS'; payload: GenerationOptions }
+  | { type: 'SET_SHOW_STATS'; payload: boolean }
+  | { type: 'LOAD_PROVIDERS'; payload: ProviderConfig[] }
+  | { type: 'CLEAR_CONVERSATION' }
+  | { type: 'ADD_TOOL_CALL'; payload: { messageId: string; toolCall: ToolCall } }
+  | { type: 'LOAD_MESSAGES'; payload: ChatMessage[] }
+  | { type: 'SET_SESSION_ID'; payload: string }
+  | { type: 'SET_SESSION_NAME'; payload: { name: string; isCustom: boolean } }
+  | { type: 'NEW_SESSION'; payload: string }
+  | { type: 'UPDATE_CONTEXT_USAGE'; payload: { used: number; total: number } | null };
+
+// Initial state
+export const initialState: ChatState = {
+  messages: [],
+  currentProvider: null,
+  currentModel: null,
+  providers: [],
+  isLoading: false,
+  error: null,
+  notice: null,
+  streamingMessageId: null,
+  streamStatus: null,
+  activePromptName: null,
+  offlineMode: false,
+  workspaceTrust: null,
+  thinking: DEFAULT_THINKING_SETTINGS,
+  sessionEnv: {},
+  sessionSystemPrompt: null,
+  generationOptions: {},
+  showStats: false,
+  currentSessionId: 'default',
+  currentSessionName: '',
+  isCustomName: false,
+  contextUsage: null,
+};
+
+// Helper function to generate display name from session ID
+export function getDisplayName(sessionId: string, customName: string, isCustom: boolean): string {
+  if (isCustom && customName) {
+    return customName;
+  }
+  if (sessionId === 'default') {
+    return 'Default Session';
+  }
+  // Return first 8 chars of UUID
+  return `Session ${sessionId.substring(0, 8)}`;
+}
+
+// Cut a finished reply's reasoning to its last `limit` characters (0 keeps it
+// all). Done once when the reply ends, so saving never cuts it again.
+function retainThinking(message: ChatMessage, limit: number): ChatMessage {
+  const thinking = message.thinking;
+  if (!limit || !thinking || thinking.length <= limit) {
+    return message;
+  }
+  return {
+    ...message,
+    thinking: `…[${thinking.length - limit} earlier characters dropped]\n${thinking.substring(thinking.length - limit)}`,
+  };
+}
+
+// Reducer
+export function chatReducer(state: ChatState, action: ChatAction): ChatState {
+  switch (action.type) {
+    case 'ADD_MESSAGE':
+      return {
+        ...state,
+        messages: [...state.messages, action.payload],
+        error: null,
+      };
+
+    case 'INSERT_MESSAGE': {
+      const index = state.messages.findIndex(msg => msg.id === action.payload.afterId);
+      if (index === -1) {
+        return state;
+      }
+      return {
+        ...state,
+        messages: [
+          ...state.messages.slice(0, index + 1),
+          action.payload.message,
+          ...state.messages.slice(index + 1),
+        ],
+      };
+    }
+
+    case 'UPDATE_MESSAGE':
+      return {
+        ...state,
+        messages: state.messages.map(msg =>
+          msg.id === action.payload.id
+            ? { ...msg, ...action.payload.updates }
+            : msg
+        ),
+      };
+
+    case 'DELETE_MESSAGE':
+      return {
+        ...state,
+        messages: state.messages.filter(msg => msg.id !== action.payload),
+      };
+
+    case 'REWIND_TO_MESSAGE': {
+      const index = state.messages.findIndex(msg => msg.id === action.payload);
+      if (index === -1) {
+        return state;
+      }
+      return {
+        ...state,
+        messages: state.messages.slice(0, index),
+        error: null,
+      };
+    }
+
+    case 'START_STREAMING':
+      return {
+        ...state,
+        streamingMessageId: action.payload,
+        isLoading: true,
+      };
+
+    case 'APPEND_TO_STREAMING':
+      if (!state.streamingMessageId) return state;
+      return {
+        ...state,
+        messages: state.messages.map(msg =>
+          msg.id === state.streamingMessageId
+            ? { ...msg, content: msg.content + action.payload }
+            : msg
+        ),
+        streamStatus: null,
+      };
+
+    case 'APPEND_THINKING_TO_STREAMING':
+      if (!state.streamingMessageId) return state;
+      return {
+        ...state,
+        messages: state.messages.map(msg =>
+          msg.id === state.streamingMessageId
+            ? { ...msg, thinking: (msg.thinking || '') + action.payload }
+            : msg
+        ),
+        streamStatus: null,
+      };
+
+    case 'END_STREAMING': {
+      // Remove the streaming message if it's completely empty (no content, no tool calls)
+      const streamingMessage = state.messages.find(m => m.id === state.streamingMessageId);
+      const shouldRemoveEmptyMessage = streamingMessage && 
+        !streamingMessage.content && 
+        !streamingMessage.thinking &&
+        (!streamingMessage.tool_calls || streamingMessage.tool_calls.length === 0);
+
+      return {
+        ...state,
+        messages: shouldRemoveEmptyMessage 
+          ? state.messages.filter(m => m.id !== state.streamingMessageId)
+          : state.messages.map(msg =>
+              msg.id === state.streamingMessageId ? retainThinking(msg, state.thinking.retainLimit) : msg
+            ),
+        streamingMessageId: null,
+        streamStatus: null,
+        isLoading: false,
+      };
+    }
+
+    case 'CANCEL_STREAMING': {
+      // Cancelling discards the partial response (STOP_STREAMING keeps it).
+      // Messages with tool calls are kept so their tool results stay attached.
+      const streamingMessage = state.messages.find(m => m.id === state.streamingMessageId);
+      const shouldRemoveMessage = streamingMessage &&
+        (!streamingMessage.tool_calls || streamingMessage.tool_calls.length === 0);
+
+      return {
+        ...state,
+        messages: shouldRemoveMessage
+          ? state.messages.filter(m => m.id !== state.streamingMessageId)
+          : state.messages,
+        streamingMessageId: null,
+        streamStatus: null,
+        isLoading: false,
+        error: null,
+      };
+    }
+
+    case 'STOP_STREAMING': {
+      // Keep whatever was generated so far and mark it so /continue can resume it
+      const streamingMessage = state.messages.find(m => m.id === state.streamingMessageId);
+      const isEmpty = streamingMessage &&
+        !streamingMessage.content &&
+        !streamingMessage.thinking &&
+        (!streamingMessage.tool_calls || streamingMessage.tool_calls.length === 0);
+
+      return {
+        ...state,
+        messages: isEmpty
+          ? state.messages.filter(m => m.id !== state.streamingMessageId)
+          : state.messages.map(msg =>
+              msg.id === state.streamingMessageId ? { ...retainThinking(msg, state.thinking.retainLimit), stopped: true } : msg
+            ),
+        streamingMessageId: null,
+        streamStatus: null,
+        isLoading: false,
+      };
+    }
+
+    case 'SET_STREAM_STATUS':
+      return {
+        ...state,
+        streamStatus: action.payload,
+      };
+
+    case 'SET_PROVIDER': {
+      // When provider changes, select first chat model if available
+      const firstChatModel = action.payload.models.find(m => m.type === 'chat');
+      return {
+        ...state,
+        currentProvider: action.payload,
+        currentModel: firstChatModel || action.payload.models[0] || null,
+      };
+    }
+
+    case 'SET_MODEL':
+      return {
+        ...state,
+        currentModel: action.payload,
+      };
+
+    case 'SET_PROVIDER_AND_MODEL':
+      return {
+        ...state,
+        currentProvider: action.payload.provider,
+        currentModel: action.payload.model,
+      };
+
+    case 'SELECT_DISCOVERED_MODEL': {
+      const provider = state.providers.find(p => p.id === action.payload.providerId);
+      if (!provider) {
+        return state;
+      }
+      const known = provider.models.find(m => m.id === action.payload.model.id);
+      const updatedProvider = known ? provider : { ...provider, models: [...provider.models, action.payload.model] };
+      return {
+        ...state,
+        providers: state.providers.map(p => p.id === provider.id ? updatedProvider : p),
+        currentProvider: updatedProvider,
+        currentModel: known ?? action.payload.model,
+      };
+    }
+
+    case 'SET_LOADING':
+      return {
+        ...state,
+        isLoading: action.payload,
+      };
+
+    case 'SET_ERROR':
+      return {
+        ...state,
+        error: action.payload,
+        isLoading: false,
+      };
+
+    case 'SET_NOTICE':
+      return {
+        ...state,
+        notice: action.payload,
+      };
+
+    case 'SET_ACTIVE_PROMPT':
+      return {
+        ...state,
+        activePromptName: action.payload,
+      };
+
+    case 'SET_SESSION_ENV':
+      return {
+        ...state,
+        sessionEnv: action.payload,
+      };
+
+    case 'SET_SESSION_SYSTEM_PROMPT':
+      return {
+        ...state,
+        sessionSystemPrompt: action.payload,
+      };
+
+    case 'SET_GENERATION_OPTIONS':
+      return {
+        ...state,
+        generationOptions: action.payload,
+      };
+
+    case 'SET_SHOW_STATS':
+      return {
+        ...state,
+        showStats: action.payload,
+      };
+
+    case 'SET_THINKING_SETTINGS':
+      return {
+        ...state,
+        thinking: action.payload,
+      };
+
+    case 'SET_WORKSPACE_TRUST':
+      return { ...state, workspaceTrust: action.payload };
+
+    case 'SET_OFFLINE_MODE': {
+      if (!action.payload || !state.currentProvider || isLocalProvider(state.currentProvider)) {
+        return { ...state, offlineMode: action.payload };
+      }
+
+      // Switch away from a remote provider to the first local chat model
+      const localProvider = state.providers.find(p =>
+        p.enabled && isLocalProvider(p) && p.models.some(m => m.type === 'chat')
+      );
+      return {
+        ...state,
+        offlineMode: true,
+        currentProvider: localProvider || null,
+        currentModel: localProvider?.models.find(m => m.type === 'chat') || null,
+      };
+    }
+
+    case 'LOAD_PROVIDERS': {
+      // Auto-select first enabled provider with a chat model (local only when offline)
+      const defaultProvider = action.payload.find(p =>
+        p.enabled && p.models.some(m => m.type === 'chat') && (!state.offlineMode || isLocalProvider(p))
+      );
+      const defaultModel = defaultProvider?.models.find(m => m.type === 'chat');
+
+      return {
+        ...state,
+        providers: action.payload,
+        currentProvider: defaultProvider || null,
+        currentModel: defaultModel || null,
+      };
+    }
+
+    case 'CLEAR_CONVERSATION':
+      return {
+        ...state,
+        messages: [],
+        streamingMessageId: null,
+        error: null,
+      };
+
+    case 'ADD_TOOL_CALL':
+      return {
+        ...state,
+        streamStatus: null,
+        messages: state.messages.map(msg =>
+          msg.id === action.payload.messageId
+            ? {
+                ...msg,
+                tool_calls: [
+                  ...(msg.tool_calls || []).filter(tc => tc.id !== action.payload.toolCall.id),
+                  action.payload.toolCall
+                ]
+              }
+            : msg
+        ),
+      };
+
+    case 'LOAD_MESSAGES':
+      return {
+        ...state,
+        messages: action.payload,
+      };
+
+    case 'SET_SESSION_ID':
+      return {
+        ...state,
+        currentSessionId: action.payload,
+      };
+
+    case 'SET_SESSION_NAME':
+      return {
+        ...state,
+        currentSessionName: action.payload.name,
+        isCustomName: action.payload.isCustom,
+      };
+
+    case 'NEW_SESSION': {
+      const sessionId = action.payload;
+      const displayName = getDisplayName(sessionId, '', false);
+      return {
+        ...state,
+        messages: [],
+        currentSessionId: sessionId,
+        currentSessionName: displayName,
+        isCustomName: false,
+        streamingMessageId: null,
+        error: null,
+        contextUsage: null,
+        sessionEnv: {},
+        sessionSystemPrompt: null,
+        generationOptions: {},
+      };
+    }
+
+    case 'UPDATE_CONTEXT_USAGE':
+      return {
+        ...state,
+        contextUsage: action.payload,
+      };
+
+    default:
+      return state;
+  }
+}
+
+let state: ChatState = initialState;
+const listeners = new Set<() => void>();
+
+export const conversationStore = {
+  getState(): ChatState {
+    return state;
+  },
+
+  dispatch(action: ChatAction) {
+    const next = chatReducer(state, action);
+    if (next !== state) {
+      state = next;
+      listeners.forEach(listener => listener());
+    }
+  },
+
+  subscribe(listener: () => void): () => void {
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  },
+
+  // Called when a project view closes, so the next one starts from a clean conversation
+  reset() {
+    state = initialState;
+    listeners.forEach(listener => listener());
+  },
+};
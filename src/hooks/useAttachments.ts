@@ -0,0 +1,82 @@
+import { useEffect, useState } from 'react';
+import type { MessageImage } from '../types/chat';
+import { checkAttachmentSize, isAttachableType } from '../utils/attachments';
+
+// Images waiting to go out with the next message, added with /attach or by
+// dropping or pasting them into the input box.
+
+export type Attachment = MessageImage & { data: string; name: string };
+
+let attachments: Attachment[] = [];
+const listeners = new Set<() => void>();
+
+function setAttachments(next: Attachment[]) {
+  attachments = next;
+  listeners.forEach(listener => listener());
+}
+
+export function addAttachment(attachment: Attachment) {
+  setAttachments([...attachments, attachment]);
+}
+
+export function removeAttachment(index: number) {
+  setAttachments(attachments.filter((_, i) => i !== index));
+}
+
+export function clearAttachments() {
+  setAttachments([]);
+}
+
+export function getAttachments() {
+  return attachments;
+}
+
+/**
+ * The pending attachments as message images, leaving none pending
+ */
+export function takeAttachments(): MessageImage[] {
+  const images = attachments.map(({ mimeType, data, path }) => ({ mimeType, data, ...(path && { path }) }));
+  if (attachments.length > 0) {
+    clearAttachments();
+  }
+  return images;
+}
+
+/**
+ * Attach an image file dropped or pasted into the input box
+ */
+export async function attachFile(file: File): Promise<void> {
+  if (!isAttachableType(file.type)) {
+    throw new Error(`${file.name || 'Pasted file'} is not a PNG, JPEG, GIF or WebP image`);
+  }
+  const sizeError = checkAttachmentSize(file.size);
+  if (sizeError) {
+    throw new Error(sizeError);
+  }
+
+  const dataUrl = await new Promise<string>((resolve, reject) => {
+    const reader = new FileReader();
+    reader.onload = () => resolve(reader.result as string);
+    reader.onerror = () => reject(reader.error ?? new Error(`Failed to read ${file.name}`));
+    reader.readAsDataURL(file);
+  });
+  addAttachment({
+    mimeType: file.type,
+    data: dataUrl.substring(dataUrl.indexOf(',') + 1),
+    name: file.name || `pasted-image-${attachments.length + 1}`,
+  });
+}
+
+export const useAttachments = () => {
+  const [state, setState] = useState(attachments);
+
+  useEffect(() => {
+    const listener = () => setState(attachments);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
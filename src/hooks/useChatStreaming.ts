@@ -1,8 +1,16 @@
 import { useCallback, useRef, useEffect } from 'react';
 import type { ChatMessage, ToolCall } from '../types/chat';
+import { isChatEvent, type ChatEvent } from '../types/events';
 import type { ChatState, ChatAction } from '../context/ChatContext';
+import { conversationStore } from '../context/conversationStore';
 import { toolRegistry } from '../tools';
+import { hookRegistry, hookConfigManager } from '../pipeline';
+import type { StreamPipeline } from '../pipeline/HookRegistry';
+import { speechManager } from '../speech';
 import { ensureSystemPromptFirst } from '../utils/messageUtils';
+import { applySummaries } from '../utils/contextSummary';
+import { checkToolIterationLimit } from '../utils/toolIterations';
+import { recordEngineEvent } from '../utils/engineEvents';
 
 export const useChatStreaming = (
   state: ChatState,
@@ -14,16 +22,91 @@ export const useChatStreaming = (
     toolResultsAddedRef: React.MutableRefObject<Set<string>>;
     toolResultMessagesRef: React.MutableRefObject<Map<string, ChatMessage>>;
     addedToolCallIdsRef: React.MutableRefObject<Set<string>>;
+    cancelledToolCallIdsRef: React.MutableRefObject<Set<string>>;
   },
   updateContextUsage: (usedTokens?: number) => Promise<void>,
   workingDirectory: string
 ) => {
-  const pendingToolCallsRef = useRef<ToolCall[]>([]);
   const isContinuingAfterToolsRef = useRef<boolean>(false);
   const pendingContinuationRef = useRef<string | null>(null);
   const updateContextUsageRef = useRef(updateContextUsage);
   updateContextUsageRef.current = updateContextUsage;
 
+  // The last note about what a request left out for the model
+  const adaptedNoticeRef = useRef<string | null>(null);
+
+  // Stream hooks for the message being streamed, made when its first content
+  // arrives from the hooks.yaml loaded before the request was sent
+  const streamPipelineRef = useRef<{ messageId: string; pipeline: StreamPipeline | null } | null>(null);
+
+  // Pass streamed content through the stream hooks; returns what to show now
+  const applyStreamHooks = useCallback((messageId: string, content: string): string => {
+    if (streamPipelineRef.current?.messageId !== messageId) {
+      const current = conversationStore.getState();
+      const message = current.messages.find(m => m.id === messageId);
+      const specs = hookConfigManager.getStreamHooks(current.activePromptName);
+      streamPipelineRef.current = {
+        messageId,
+        pipeline: specs.length > 0 ? hookRegistry.createStreamPipeline(specs, {
+          providerId: message?.modelOverride?.providerId ?? current.currentProvider?.id,
+          modelId: message?.modelOverride?.modelId ?? current.currentModel?.id,
+          promptName: current.activePromptName,
+        }) : null,
+      };
+    }
+    const pipeline = streamPipelineRef.current.pipeline;
+    return pipeline ? pipeline.push(content).join('') : content;
+  }, []);
+
+  // Release what the stream hooks are still holding back, as the response ends
+  const flushStreamHooks = useCallback(() => {
+    const held = streamPipelineRef.current?.pipeline?.flush().join('');
+    streamPipelineRef.current = null;
+    if (held) {
+      dispatch({ type: 'APPEND_TO_STREAMING', payload: held });
+    }
+  }, [dispatch]);
+
+  // Run the configured post-response filters over a finished assistant message and its thinking
+  // Returns the message content after filtering, or null if there is none
+  const applyPostResponseHooks = useCallback(async (messageId: string): Promise<string | null> => {
+    const current = conversationStore.getState();
+    const message = current.messages.find(m => m.id === messageId);
+    if (!message || message.role !== 'assistant' || (!message.content && !message.thinking)) {
+      return null;
+    }
+
+    await hookConfigManager.loadConfig();
+    const promptName = current.activePromptName;
+    const specs = hookConfigManager.getPostResponseHooks(promptName);
+    if (specs.length === 0) {
+      return message.content || null;
+    }
+
+    const filtered = await hookRegistry.runPostResponse(specs, {
+      content: message.content,
+      thinking: message.thinking,
+    }, {
+      providerId: message.modelOverride?.providerId ?? state.currentProvider?.id,
+      modelId: message.modelOverride?.modelId ?? state.currentModel?.id,
+      promptName,
+    });
+
+    const updates: Partial<ChatMessage> = {};
+    if (filtered.content !== message.content) {
+      updates.content = filtered.content;
+    }
+    if (filtered.thinking !== message.thinking) {
+      updates.thinking = filtered.thinking;
+    }
+    if (Object.keys(updates).length > 0) {
+      console.log(`Post-response hooks changed message ${messageId}:`, specs);
+      recordEngineEvent('hook', 'hooks', 'Hooks changed the reply', { hooks: specs, changed: Object.keys(updates) });
+      dispatch({ type: 'UPDATE_MESSAGE', payload: { id: messageId, updates } });
+    }
+    return filtered.content || null;
+  }, [state.currentProvider, state.currentModel, dispatch]);
+
   // Continue conversation after tool execution
   const continueAfterToolExecution = useCallback(async (streamingMessageIdOverride?: string) => {
     if (!state.currentProvider || !state.currentModel) {
@@ -68,7 +151,21 @@ export const useChatStreaming = (
 
     console.log(`Checking tool results: ref=${resultsCountInRef}/${toolCallIds.length}, messages=${toolResultsInMessages.length}/${toolCallIds.length}, allAdded=${allResultsAdded}`);
 
+    if (allResultsAdded && toolCallIds.some(id => toolExecutionRefs.cancelledToolCallIdsRef.current.has(id))) {
+      console.log('Tool calls were cancelled, not continuing');
+      dispatch({ type: 'END_STREAMING' });
+      return;
+    }
+
     if (allResultsAdded && resultsCountInRef === toolCallIds.length) {
+      const limitNotice = await checkToolIterationLimit(conversationStore.getState().messages);
+      if (limitNotice) {
+        console.log('Tool iteration limit reached, not continuing');
+        dispatch({ type: 'END_STREAMING' });
+        dispatch({ type: 'SET_NOTICE', payload: limitNotice });
+        return;
+      }
+
       console.log('All tool results are ready (confirmed by ref), proceeding with continuation');
       setTimeout(() => {
         if (isContinuingAfterToolsRef.current) {
@@ -147,30 +244,38 @@ export const useChatStreaming = (
       ...allToolResults,
     ];
 
-    const hasSystemMessage = conversationHistory.some(m => m.role === 'system');
+    const hasSystemMessage = conversationHistory.some(m => m.role === 'system' && !m.contextSummary);
     const defaultSystemMessage: ChatMessage = {
       id: 'system-prompt',
       role: 'system',
       content: 'You are a helpful AI assistant.',
       timestamp: Date.now(),
     };
-    const messagesToSend = ensureSystemPromptFirst(conversationHistory, hasSystemMessage ? null : defaultSystemMessage);
+    const summarized = applySummaries(conversationHistory, hasSystemMessage ? null : defaultSystemMessage);
+    const messagesToSend = ensureSystemPromptFirst(summarized.messages, summarized.systemPrompt);
 
     console.log('Continuing with', messagesToSend.length, 'messages (including tool results)');
 
+    // Keep using a one-off model override for the rest of the tool loop
+    const modelOverride = assistantMessageWithTools?.modelOverride;
+    const overrideProvider = modelOverride ? state.providers.find(p => p.id === modelOverride.providerId) : undefined;
+    const overrideModel = overrideProvider?.models.find(m => m.id === modelOverride?.modelId);
+    const provider = overrideModel ? overrideProvider : state.currentProvider;
+    const model = overrideModel ?? state.currentModel;
+
     const assistantMessageId = `assistant-${Date.now()}`;
     const assistantMessage: ChatMessage = {
       id: assistantMessageId,
       role: 'assistant',
       content: '',
       timestamp: Date.now(),
+      ...(overrideModel && { modelOverride }),
     };
 
     dispatch({ type: 'ADD_MESSAGE', payload: assistantMessage });
     dispatch({ type: 'START_STREAMING', payload: assistantMessageId });
 
     toolExecutionRefs.addedToolCallIdsRef.current.clear();
-    pendingToolCallsRef.current = [];
     toolExecutionRefs.executedToolCallsRef.current.clear();
     toolExecutionRefs.toolCallsInCurrentMessageRef.current = [];
     toolExecutionRefs.toolResultsAddedRef.current.clear();
@@ -178,15 +283,19 @@ export const useChatStreaming = (
     pendingContinuationRef.current = null;
 
     try {
-      if (!state.currentProvider || !state.currentModel) {
+      if (!provider || !model) {
         console.error('Missing provider or model');
         return;
       }
+      // Stream hooks are made from it as the first content arrives
+      await hookConfigManager.loadConfig();
       const result = await window.electronAPI.chatSendMessage({
-        provider: state.currentProvider.id,
-        model: state.currentModel.id,
+        provider: provider.id,
+        model: model.id,
         messages: messagesToSend,
         tools: toolRegistry.getDefinitions(),
+        projectPath: workingDirectory,
+        options: state.generationOptions,
       });
 
       if (result && !result.success && result.error) {
@@ -207,36 +316,37 @@ export const useChatStreaming = (
     } finally {
       isContinuingAfterToolsRef.current = false;
     }
-  }, [state.currentProvider, state.currentModel, state.messages, dispatch, toolExecutionRefs]);
+  }, [state.currentProvider, state.currentModel, state.providers, state.messages, state.generationOptions, dispatch, toolExecutionRefs, workingDirectory]);
 
   // Setup chat chunk listener
   const setupChatChunkListener = useCallback(() => {
     window.electronAPI.onChatChunk((chunk: unknown) => {
-      const typedChunk = chunk as {
-        type: string;
-        content?: string;
-        tool_call?: ToolCall;
-        tool_calls?: ToolCall[];
-        error?: string;
-        usage?: {
-          prompt_tokens: number;
-          completion_tokens: number;
-          total_tokens: number;
-        };
-      };
+      if (!isChatEvent(chunk)) {
+        console.warn('Ignoring chat event with unknown schema:', chunk);
+        return;
+      }
+      const typedChunk: ChatEvent = chunk;
       console.log('Received chat chunk:', typedChunk);
+      // The listener can outlive the render it was set up in, so the streaming
+      // message is read from the store
+      const current = conversationStore.getState();
 
       if (typedChunk.type === 'content') {
-        dispatch({ type: 'APPEND_TO_STREAMING', payload: typedChunk.content || '' });
+        const content = current.streamingMessageId ? applyStreamHooks(current.streamingMessageId, typedChunk.content) : typedChunk.content;
+        if (content) {
+          dispatch({ type: 'APPEND_TO_STREAMING', payload: content });
+        }
         if (updateContextUsageRef.current) {
           setTimeout(() => {
             updateContextUsageRef.current();
           }, 100);
         }
+      } else if (typedChunk.type === 'thinking') {
+        dispatch({ type: 'APPEND_THINKING_TO_STREAMING', payload: typedChunk.thinking });
       } else if (typedChunk.type === 'tool_call') {
         console.log('Handling immediate tool call:', typedChunk.tool_call);
 
-        if (typedChunk.tool_call && state.streamingMessageId) {
+        if (current.streamingMessageId) {
           const toolCall = typedChunk.tool_call;
 
           if (!toolCall.id || !toolCall.function?.name) {
@@ -260,33 +370,32 @@ export const useChatStreaming = (
             console.log('Added tool call to ref:', toolCall.function.name, 'Total in ref:', toolExecutionRefs.toolCallsInCurrentMessageRef.current.length);
             dispatch({
               type: 'ADD_TOOL_CALL',
-              payload: { messageId: state.streamingMessageId, toolCall },
+              payload: { messageId: current.streamingMessageId, toolCall },
             });
           }
 
           toolExecutionRefs.handleImmediateToolCall(toolCall);
         }
-      } else if (typedChunk.type === 'tool_calls') {
-        console.log('Accumulating tool calls (batch mode)');
-        pendingToolCallsRef.current = typedChunk.tool_calls || [];
-
-        for (const toolCall of typedChunk.tool_calls || []) {
-          if (!toolExecutionRefs.addedToolCallIdsRef.current.has(toolCall.id)) {
-            toolExecutionRefs.addedToolCallIdsRef.current.add(toolCall.id);
-            dispatch({
-              type: 'ADD_TOOL_CALL',
-              payload: { messageId: state.streamingMessageId!, toolCall },
-            });
+      } else if (typedChunk.type === 'status') {
+        console.log('Stream status:', typedChunk.status, typedChunk.message);
+        if (typedChunk.status === 'adapted') {
+          // Every request of a tool loop says the same, so it's shown once
+          if (typedChunk.message !== adaptedNoticeRef.current) {
+            adaptedNoticeRef.current = typedChunk.message;
+            dispatch({ type: 'SET_NOTICE', payload: typedChunk.message });
           }
+        } else {
+          dispatch({ type: 'SET_STREAM_STATUS', payload: typedChunk.message });
         }
       } else if (typedChunk.type === 'done') {
-        console.log('Received done chunk');
-
-        if (pendingToolCallsRef.current.length > 0) {
-          console.log('Executing pending tool calls (batch mode):', pendingToolCallsRef.current);
-          pendingToolCallsRef.current = [];
-          // For batch mode, would need handleToolCalls - keeping simplified for now
-          return;
+        console.log('Received done chunk', typedChunk.done_reason);
+        flushStreamHooks();
+
+        if (typedChunk.done_reason && current.streamingMessageId) {
+          dispatch({
+            type: 'UPDATE_MESSAGE',
+            payload: { id: current.streamingMessageId, updates: { doneReason: typedChunk.done_reason } },
+          });
         }
 
         const toolCallsInMessage = toolExecutionRefs.toolCallsInCurrentMessageRef.current;
@@ -299,7 +408,7 @@ export const useChatStreaming = (
         if (hasToolCalls) {
           console.log('Tool calls found in current message, will continue after tool execution completes');
 
-          const streamingMsgId = state.streamingMessageId;
+          const streamingMsgId = current.streamingMessageId;
 
           const toolCallIds = toolCallsInMessage.map(tc => tc.id);
           const allResultsReady = toolCallIds.every(id => toolExecutionRefs.toolResultsAddedRef.current.has(id));
@@ -321,23 +430,55 @@ export const useChatStreaming = (
           return;
         }
 
-        console.log('Ending streaming for message (no tool calls):', state.streamingMessageId);
+        console.log('Ending streaming for message (no tool calls):', current.streamingMessageId);
+        const finishedMessageId = current.streamingMessageId;
         dispatch({ type: 'END_STREAMING' });
+        if (finishedMessageId) {
+          // The store already holds the final content chunk
+          applyPostResponseHooks(finishedMessageId).then(content => {
+            if (content) {
+              speechManager.speak(content).catch(error => {
+                dispatch({ type: 'SET_ERROR', payload: error instanceof Error ? error.message : 'Text-to-speech failed' });
+              });
+            }
+          });
+        }
       } else if (typedChunk.type === 'usage') {
         console.log('Received usage info:', typedChunk.usage);
-        if (typedChunk.usage && state.currentProvider && state.currentModel) {
+        if (current.currentProvider && current.currentModel) {
           updateContextUsage(typedChunk.usage.total_tokens);
         }
+        if (current.streamingMessageId) {
+          // Some providers report prompt and completion tokens in separate events
+          const usage = typedChunk.usage;
+          const previous = current.messages.find(m => m.id === current.streamingMessageId)?.generationStats;
+          dispatch({
+            type: 'UPDATE_MESSAGE',
+            payload: {
+              id: current.streamingMessageId,
+              updates: {
+                generationStats: {
+                  promptTokens: usage.prompt_tokens || previous?.promptTokens || 0,
+                  completionTokens: usage.completion_tokens || previous?.completionTokens || 0,
+                  ...(typeof usage.eval_duration_ms === 'number' && { evalDurationMs: usage.eval_duration_ms }),
+                  ...(typeof usage.total_duration_ms === 'number' && { totalDurationMs: usage.total_duration_ms }),
+                },
+              },
+            },
+          });
+        }
       } else if (typedChunk.type === 'cancelled') {
         console.log('Stream was cancelled');
+        flushStreamHooks();
         dispatch({ type: 'CANCEL_STREAMING' });
       } else if (typedChunk.type === 'error') {
         console.error('Chat chunk error:', typedChunk.error);
+        flushStreamHooks();
         dispatch({ type: 'SET_ERROR', payload: typedChunk.error || 'Unknown streaming error' });
         dispatch({ type: 'END_STREAMING' });
       }
     });
-  }, [toolExecutionRefs, continueAfterToolExecution, dispatch, state.streamingMessageId, state.currentProvider, state.currentModel, state.messages, updateContextUsage]);
+  }, [toolExecutionRefs, continueAfterToolExecution, applyPostResponseHooks, applyStreamHooks, flushStreamHooks, dispatch, updateContextUsage]);
 
   // Setup listener on mount
   useEffect(() => {
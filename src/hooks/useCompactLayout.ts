@@ -0,0 +1,10 @@
+import { useMediaQuery } from '@mui/material';
+
+// Below this window width the chat drops its borders and padding, shortens
+// the status text and lets the selectors wrap, instead of squeezing the
+// regular layout until its fixed widths overflow (a window tiled into a
+// narrow column, for example).
+export const COMPACT_LAYOUT_MAX_WIDTH = 640;
+
+export const useCompactLayout = (): boolean =>
+  useMediaQuery(`(max-width: ${COMPACT_LAYOUT_MAX_WIDTH}px)`, { noSsr: true });
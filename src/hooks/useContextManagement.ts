@@ -1,7 +1,9 @@
 import { useState, useCallback, useEffect } from 'react';
-import type { ChatMessage } from '../types/chat';
+import { CONTEXT_MODES, type ChatMessage, type ContextMode } from '../types/chat';
 import type { ChatState, ChatAction } from '../context/ChatContext';
-import { estimateTokenUsage } from '../utils/messageUtils';
+import { estimateTokenUsage, withPinned } from '../utils/messageUtils';
+import { applySummaries, getActiveSummary, readSummarizerSettings, selectMessagesToSummarize, summarizeMessages } from '../utils/contextSummary';
+import { findModelByRef } from '../utils/modelUtils';
 
 interface ContextManagementResult {
   messagesToSend: ChatMessage[];
@@ -13,7 +15,7 @@ export const useContextManagement = (
   dispatch: React.Dispatch<ChatAction>,
   workingDirectory: string
 ) => {
-  const [contextMode, setContextMode] = useState<'rolling' | 'halt'>('rolling');
+  const [contextMode, setContextMode] = useState<ContextMode>('rolling');
   const [virtualContextSize, setVirtualContextSize] = useState<number | null>(null);
 
   // Load context mode when working directory changes
@@ -31,7 +33,7 @@ export const useContextManagement = (
     try {
       const result = await window.electronAPI.projectContextModeRead(workingDirectory);
       if (result.success) {
-        setContextMode(result.mode === 'halt' ? 'halt' : 'rolling');
+        setContextMode(CONTEXT_MODES.includes(result.mode as ContextMode) ? result.mode as ContextMode : 'rolling');
       }
     } catch (error) {
       console.error('Failed to load context mode:', error);
@@ -39,12 +41,15 @@ export const useContextManagement = (
     }
   };
 
-  // Apply context management: truncate messages based on context mode and usage
+  // Apply context management: swap summarized turns for their summary, then
+  // truncate messages based on context mode and usage
   const applyContextManagement = useCallback((
-    messages: ChatMessage[],
-    systemPrompt: ChatMessage | null,
+    allMessages: ChatMessage[],
+    requestSystemPrompt: ChatMessage | null,
     contextTotal: number
   ): ContextManagementResult => {
+    const { messages, systemPrompt } = applySummaries(allMessages, requestSystemPrompt);
+
     // Validate contextTotal - it should be a reasonable value (at least 1000 tokens)
     if (!contextTotal || contextTotal < 1000 || messages.length === 0) {
       if (contextTotal && contextTotal < 1000) {
@@ -83,8 +88,9 @@ export const useContextManagement = (
       return { messagesToSend: [], shouldHalt: true };
     }
 
-    // For Rolling Window mode: if at or over 95%, exclude 30% of oldest conversation messages
-    if (contextMode === 'rolling' && usagePercent >= 95) {
+    // For Rolling Window mode: if at or over 95%, exclude 30% of oldest conversation messages.
+    // Summarize mode falls back to this when summarizing didn't free enough space.
+    if (contextMode !== 'halt' && usagePercent >= 95) {
       console.log('[Context Management] ROLLING: Truncating at', usagePercent.toFixed(2) + '%');
 
       let currentMessages = [...conversationMessages];
@@ -185,6 +191,9 @@ export const useContextManagement = (
         firstMessageRole: currentMessages[0]?.role,
       });
 
+      // Pinned messages go back in even if that goes over the limit again
+      currentMessages = withPinned(currentMessages, conversationMessages);
+
       // Build messagesToSend with system prompt FIRST
       const messagesToSend = systemPrompt
         ? [systemPrompt, ...currentMessages]
@@ -207,6 +216,47 @@ export const useContextManagement = (
     };
   }, [contextMode]);
 
+  // Replace the oldest turns with a summary written by the summarizer model (the
+  // session's model unless the contextSummarizer preference names one). Returns
+  // the messages with the summary inserted, or null if there was nothing to summarize.
+  const compactContext = useCallback(async (messages: ChatMessage[]): Promise<{ messages: ChatMessage[]; summarizedCount: number } | null> => {
+    const settings = await readSummarizerSettings();
+    const toSummarize = selectMessagesToSummarize(messages, settings.keepRecent);
+    if (toSummarize.length === 0) {
+      return null;
+    }
+
+    let providerId = state.currentProvider?.id;
+    let modelId = state.currentModel?.id;
+    if (settings.model) {
+      const selection = findModelByRef(state.providers, settings.model);
+      if (!selection) {
+        throw new Error(`Summarizer model "${settings.model}" not found`);
+      }
+      providerId = selection.provider.id;
+      modelId = selection.model.id;
+    }
+    if (!providerId || !modelId) {
+      throw new Error('Please select a provider and model');
+    }
+
+    console.log('[Context Management] SUMMARIZE:', {
+      messageCount: toSummarize.length,
+      providerId,
+      modelId,
+    });
+
+    const summary = await summarizeMessages(toSummarize, getActiveSummary(messages), providerId, modelId);
+    const afterId = toSummarize[toSummarize.length - 1].id;
+    dispatch({ type: 'INSERT_MESSAGE', payload: { afterId, message: summary } });
+
+    const index = messages.findIndex(m => m.id === afterId);
+    return {
+      messages: [...messages.slice(0, index + 1), summary, ...messages.slice(index + 1)],
+      summarizedCount: toSummarize.length,
+    };
+  }, [state.providers, state.currentProvider, state.currentModel, dispatch]);
+
   // Function to update context usage
   const updateContextUsage = useCallback(async (usedTokens?: number) => {
     if (!state.currentProvider || !state.currentModel) {
@@ -287,9 +337,11 @@ export const useContextManagement = (
 
   return {
     contextMode,
+    setContextMode,
     virtualContextSize,
     setVirtualContextSize,
     applyContextManagement,
+    compactContext,
     updateContextUsage,
   };
 };
@@ -0,0 +1,65 @@
+import { useEffect, useState } from 'react';
+import type { LogEntry } from '../types/chat';
+
+// The /debug pane: whether it's open, the lowest level it shows, and the
+// main-process log entries it follows while open.
+
+const MAX_ENTRIES = 1000;
+
+export type DebugLevel = LogEntry['level'];
+
+let pane: { open: boolean; level: DebugLevel } = { open: false, level: 'debug' };
+const listeners = new Set<() => void>();
+
+export function setDebugPane(updates: Partial<typeof pane>) {
+  pane = { ...pane, ...updates };
+  listeners.forEach(listener => listener());
+}
+
+export function getDebugPane() {
+  return pane;
+}
+
+export const useDebugPane = () => {
+  const [state, setState] = useState(pane);
+
+  useEffect(() => {
+    const listener = () => setState(pane);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
+
+export const useDebugLog = (open: boolean) => {
+  const [entries, setEntries] = useState<LogEntry[]>([]);
+  const [file, setFile] = useState<string | null>(null);
+
+  useEffect(() => {
+    if (!open) {
+      return;
+    }
+    let cancelled = false;
+    const removeListener = window.electronAPI.onLogEntry(entry => {
+      setEntries(prev => [...prev.slice(-(MAX_ENTRIES - 1)), entry]);
+    });
+    window.electronAPI.logsRecent().then(result => {
+      if (!cancelled && result.success) {
+        setEntries(result.entries);
+        setFile(result.file);
+      }
+    }).catch(error => console.error('Failed to read the log:', error));
+    window.electronAPI.logsFollow(true).catch(error => console.error('Failed to follow the log:', error));
+
+    return () => {
+      cancelled = true;
+      removeListener();
+      window.electronAPI.logsFollow(false).catch(() => undefined);
+    };
+  }, [open]);
+
+  return { entries, file };
+};
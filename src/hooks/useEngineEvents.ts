@@ -0,0 +1,59 @@
+import { useEffect, useState } from 'react';
+import type { EngineEventKind, LogEntry } from '../types/chat';
+
+// The /events pane: whether it's open, the one kind of event it shows if
+// narrowed down, and the engine events it follows while open.
+
+const MAX_EVENTS = 300;
+
+let pane: { open: boolean; kind: EngineEventKind | null } = { open: false, kind: null };
+const listeners = new Set<() => void>();
+
+export function setEventsPane(updates: Partial<typeof pane>) {
+  pane = { ...pane, ...updates };
+  listeners.forEach(listener => listener());
+}
+
+export function getEventsPane() {
+  return pane;
+}
+
+export const useEventsPane = () => {
+  const [state, setState] = useState(pane);
+
+  useEffect(() => {
+    const listener = () => setState(pane);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
+
+export const useEngineEvents = (open: boolean) => {
+  const [events, setEvents] = useState<LogEntry[]>([]);
+
+  useEffect(() => {
+    if (!open) {
+      return;
+    }
+    let cancelled = false;
+    const removeListener = window.electronAPI.onEngineEvent(event => {
+      setEvents(prev => [...prev.slice(-(MAX_EVENTS - 1)), event]);
+    });
+    window.electronAPI.engineEventsRecent().then(result => {
+      if (!cancelled && result.success) {
+        setEvents(result.events);
+      }
+    }).catch(error => console.error('Failed to read engine events:', error));
+
+    return () => {
+      cancelled = true;
+      removeListener();
+    };
+  }, [open]);
+
+  return events;
+};
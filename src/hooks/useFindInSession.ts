@@ -0,0 +1,27 @@
+import { useEffect, useState } from 'react';
+
+// Text searched for with /find, shared with the message list which highlights
+// the matches and moves between them. Only what is rendered is searched, so
+// collapsed reasoning and tool output don't match until expanded.
+
+let findQuery: string | null = null;
+const listeners = new Set<() => void>();
+
+export function setFindQuery(query: string | null) {
+  findQuery = query && query.trim() ? query : null;
+  listeners.forEach(listener => listener());
+}
+
+export const useFindQuery = () => {
+  const [query, setQuery] = useState(findQuery);
+
+  useEffect(() => {
+    const listener = () => setQuery(findQuery);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return query;
+};
@@ -0,0 +1,19 @@
+import { useEffect } from 'react';
+
+// Text put into the input box for review before sending, by /prompt. It
+// replaces what's there, which is usually just the command that produced it.
+
+const listeners = new Set<(text: string) => void>();
+
+export function setInputDraft(text: string) {
+  listeners.forEach(listener => listener(text));
+}
+
+export const useInputDraft = (onDraft: (text: string) => void) => {
+  useEffect(() => {
+    listeners.add(onDraft);
+    return () => {
+      listeners.delete(onDraft);
+    };
+  }, [onDraft]);
+};
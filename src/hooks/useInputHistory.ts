@@ -0,0 +1,79 @@
+import { useCallback, useEffect, useRef } from 'react';
+
+// Previously sent messages for the input box, shared by every project and kept
+// in ~/.config/poe/history. Up and down walk through them like a shell; the
+// draft being typed is restored when walking back past the newest entry.
+
+export const useInputHistory = () => {
+  const entriesRef = useRef<string[]>([]);
+  // Index into entries while walking through them; entries.length is the draft
+  const positionRef = useRef(0);
+  const draftRef = useRef('');
+
+  useEffect(() => {
+    window.electronAPI.inputHistoryRead().then(result => {
+      if (result.success) {
+        entriesRef.current = result.entries;
+        positionRef.current = result.entries.length;
+      }
+    }).catch(error => console.error('Failed to load input history:', error));
+  }, []);
+
+  const add = useCallback((entry: string) => {
+    entriesRef.current = [...entriesRef.current.filter(e => e !== entry), entry];
+    positionRef.current = entriesRef.current.length;
+    draftRef.current = '';
+    window.electronAPI.inputHistoryAdd(entry).catch(error => console.error('Failed to save input history:', error));
+  }, []);
+
+  /**
+   * The entry before the one shown, or null at the oldest. `current` is kept as
+   * the draft when leaving it.
+   */
+  const previous = useCallback((current: string): string | null => {
+    if (positionRef.current === 0) {
+      return null;
+    }
+    if (positionRef.current === entriesRef.current.length) {
+      draftRef.current = current;
+    }
+    positionRef.current--;
+    return entriesRef.current[positionRef.current];
+  }, []);
+
+  /**
+   * The entry after the one shown, the draft after the newest, or null when the
+   * draft is already shown
+   */
+  const next = useCallback((): string | null => {
+    if (positionRef.current >= entriesRef.current.length) {
+      return null;
+    }
+    positionRef.current++;
+    return positionRef.current === entriesRef.current.length
+      ? draftRef.current
+      : entriesRef.current[positionRef.current];
+  }, []);
+
+  // Typing starts over from the newest entry
+  const reset = useCallback(() => {
+    positionRef.current = entriesRef.current.length;
+  }, []);
+
+  /**
+   * The newest entry before index `before` containing `query` (case-insensitive),
+   * for Ctrl+R. Pass the previous match's index to find an older one.
+   */
+  const search = useCallback((query: string, before?: number): { entry: string; index: number } | null => {
+    const needle = query.toLowerCase();
+    const entries = entriesRef.current;
+    for (let i = Math.min(before ?? entries.length, entries.length) - 1; i >= 0; i--) {
+      if (entries[i].toLowerCase().includes(needle)) {
+        return { entry: entries[i], index: i };
+      }
+    }
+    return null;
+  }, []);
+
+  return { add, previous, next, reset, search };
+};
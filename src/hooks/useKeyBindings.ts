@@ -0,0 +1,49 @@
+import { useEffect, useState } from 'react';
+import { DEFAULT_KEY_BINDINGS, resolveKeyBindings, type KeyBindings } from '../utils/keyBindings';
+
+// The "keyBindings" preference, read when the chat view first needs it and
+// again by /keys, so keys edited in preferences.json apply without a restart
+// (except Quit, whose menu item is set up when the app starts).
+
+let bindings: KeyBindings = DEFAULT_KEY_BINDINGS;
+const listeners = new Set<() => void>();
+let loaded = false;
+
+/**
+ * Read the preference again; returns what in it couldn't be used
+ */
+export async function reloadKeyBindings(): Promise<string[]> {
+  loaded = true;
+  const result = await window.electronAPI.preferencesGet('keyBindings');
+  const resolved = resolveKeyBindings(result.success ? result.value : null);
+  bindings = resolved.bindings;
+  listeners.forEach(listener => listener());
+  return resolved.problems;
+}
+
+export function getKeyBindings() {
+  return bindings;
+}
+
+export const useKeyBindings = () => {
+  const [state, setState] = useState(bindings);
+
+  useEffect(() => {
+    const listener = () => setState(bindings);
+    listeners.add(listener);
+    if (!loaded) {
+      reloadKeyBindings()
+        .then(problems => {
+          if (problems.length > 0) {
+            console.warn('Ignored parts of the keyBindings preference:', problems);
+          }
+        })
+        .catch(error => console.error('Failed to load the keyBindings preference:', error));
+    }
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
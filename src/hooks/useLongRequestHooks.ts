@@ -0,0 +1,99 @@
+import { useEffect, useRef } from 'react';
+import type { ChatState } from '../context/ChatContext';
+import { conversationStore } from '../context/conversationStore';
+import { hookRegistry, hookConfigManager } from '../pipeline';
+import type { LongRequestContext } from '../pipeline/HookRegistry';
+
+// Runs the longRequest hooks from hooks.yaml for a request that takes longer
+// than the configured time: once it finishes, or as soon as it crosses the
+// limit with `when: exceeded`. A request lasts from sending the message until
+// the reply is done, through any tool calls in between.
+
+// Loading stops briefly between a tool call and the model's follow-up
+const SETTLE_MS = 1500;
+const PREVIEW_CHARS = 200;
+
+export const useLongRequestHooks = (state: ChatState, workingDirectory: string) => {
+  const workingDirectoryRef = useRef(workingDirectory);
+  workingDirectoryRef.current = workingDirectory;
+  const requestRef = useRef<{ startedAt: number; endedAt?: number; fired: boolean } | null>(null);
+  const exceededTimerRef = useRef<ReturnType<typeof setTimeout> | null>(null);
+  const settleTimerRef = useRef<ReturnType<typeof setTimeout> | null>(null);
+
+  useEffect(() => {
+    const fire = (status: LongRequestContext['status'], startedAt: number, durationMs: number) => {
+      const current = conversationStore.getState();
+      const reply = [...current.messages].reverse().find(m => m.role === 'assistant' && m.content && m.timestamp >= startedAt);
+      const { specs } = hookConfigManager.getLongRequestHooks();
+      hookRegistry.runLongRequest(specs, {
+        durationMs,
+        status,
+        providerId: current.currentProvider?.id,
+        modelId: current.currentModel?.id,
+        projectPath: workingDirectoryRef.current,
+        ...(status !== 'running' && reply && { preview: reply.content.substring(0, PREVIEW_CHARS) }),
+      }).catch(error => console.error('Failed to run long-request hooks:', error));
+    };
+
+    if (state.isLoading) {
+      if (settleTimerRef.current) {
+        // The same request, continuing after a tool call
+        clearTimeout(settleTimerRef.current);
+        settleTimerRef.current = null;
+        if (requestRef.current) {
+          requestRef.current.endedAt = undefined;
+        }
+        return;
+      }
+
+      const request = { startedAt: Date.now(), fired: false };
+      requestRef.current = request;
+      hookConfigManager.loadConfig().then(() => {
+        const { afterMs, when, specs } = hookConfigManager.getLongRequestHooks();
+        if (specs.length === 0 || when !== 'exceeded' || requestRef.current !== request || request.endedAt) {
+          return;
+        }
+        exceededTimerRef.current = setTimeout(() => {
+          exceededTimerRef.current = null;
+          if (requestRef.current === request && !request.endedAt) {
+            request.fired = true;
+            fire('running', request.startedAt, Date.now() - request.startedAt);
+          }
+        }, Math.max(0, request.startedAt + afterMs - Date.now()));
+      }).catch(error => console.error('Failed to load hooks config:', error));
+      return;
+    }
+
+    const request = requestRef.current;
+    if (!request) {
+      return;
+    }
+    request.endedAt = Date.now();
+    settleTimerRef.current = setTimeout(() => {
+      settleTimerRef.current = null;
+      requestRef.current = null;
+      if (exceededTimerRef.current) {
+        clearTimeout(exceededTimerRef.current);
+        exceededTimerRef.current = null;
+      }
+
+      const durationMs = (request.endedAt ?? Date.now()) - request.startedAt;
+      const { afterMs, when, specs } = hookConfigManager.getLongRequestHooks();
+      if (request.fired || when !== 'finished' || specs.length === 0 || durationMs < afterMs) {
+        return;
+      }
+      const current = conversationStore.getState();
+      const lastAssistant = [...current.messages].reverse().find(m => m.role === 'assistant');
+      fire(current.error ? 'error' : lastAssistant?.stopped ? 'stopped' : 'done', request.startedAt, durationMs);
+    }, SETTLE_MS);
+  }, [state.isLoading]);
+
+  useEffect(() => () => {
+    if (exceededTimerRef.current) {
+      clearTimeout(exceededTimerRef.current);
+    }
+    if (settleTimerRef.current) {
+      clearTimeout(settleTimerRef.current);
+    }
+  }, []);
+};
@@ -75,7 +75,8 @@ export const useMessageActions = (
         displayName,
         true,
         state.currentProvider?.id,
-        state.currentModel?.id
+        state.currentModel?.id,
+        { env: state.sessionEnv, ...(state.sessionSystemPrompt !== null && { systemPrompt: state.sessionSystemPrompt }) }
       );
 
       await loadSession(newSessionId);
@@ -86,7 +87,7 @@ export const useMessageActions = (
         payload: error instanceof Error ? error.message : 'Failed to fork conversation',
       });
     }
-  }, [state.isLoading, state.messages, state.currentSessionName, state.currentProvider, state.currentModel, dispatch]);
+  }, [state.isLoading, state.messages, state.currentSessionName, state.currentProvider, state.currentModel, state.sessionEnv, state.sessionSystemPrompt, dispatch]);
 
   const handleRegenerate = useCallback(async () => {
     if (state.isLoading) return;
@@ -0,0 +1,50 @@
+import { useEffect, useState } from 'react';
+
+// Keyboard selection of past messages. Esc in an empty input box starts it on
+// the latest message; the message list moves the cursor and runs the
+// per-message actions, and the input box takes the focus back when it ends.
+
+interface MessageSelection {
+  active: boolean;
+  messageId: string | null; // null until the message list puts the cursor somewhere
+}
+
+let selection: MessageSelection = { active: false, messageId: null };
+const listeners = new Set<() => void>();
+
+function update(next: MessageSelection) {
+  selection = next;
+  listeners.forEach(listener => listener());
+}
+
+export function startMessageSelection() {
+  if (!selection.active) {
+    update({ active: true, messageId: null });
+  }
+}
+
+export function selectMessage(messageId: string) {
+  if (selection.active && selection.messageId !== messageId) {
+    update({ active: true, messageId });
+  }
+}
+
+export function endMessageSelection() {
+  if (selection.active) {
+    update({ active: false, messageId: null });
+  }
+}
+
+export const useMessageSelection = () => {
+  const [state, setState] = useState(selection);
+
+  useEffect(() => {
+    const listener = () => setState(selection);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
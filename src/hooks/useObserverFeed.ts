@@ -0,0 +1,78 @@
+import { useEffect, useRef, useState } from 'react';
+import type { ChatState } from '../context/ChatContext';
+import { redactToolArgsJson } from '../tools/redaction';
+import { createRedactionCache, redactTranscript } from '../utils/transcriptRedaction';
+
+// While /observe is on, the conversation is sent to the observer server in the
+// main process, which streams it to read-only viewers. The message being
+// streamed is left out; observers see it arrive through the chat events.
+
+let observing = false;
+const listeners = new Set<() => void>();
+
+export function setObserving(value: boolean) {
+  observing = value;
+  listeners.forEach(listener => listener());
+}
+
+export const useObserverFeed = (state: ChatState) => {
+  const [active, setActive] = useState(observing);
+  const publishedRef = useRef('');
+  const publishCountRef = useRef(0);
+  const redactionCacheRef = useRef(createRedactionCache());
+
+  useEffect(() => {
+    const listener = () => setActive(observing);
+    listeners.add(listener);
+    // Still running after a reload of this window
+    window.electronAPI.observerStatus()
+      .then(status => setObserving(status.running))
+      .catch(() => undefined);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  useEffect(() => {
+    if (!active) {
+      publishedRef.current = '';
+      // hooks.yaml is read again the next time /observe starts
+      redactionCacheRef.current = createRedactionCache();
+      return;
+    }
+    const messages = state.messages.filter(m => m.id !== state.streamingMessageId);
+    // Cheap check so the history isn't re-sent for every streamed chunk
+    const signature = messages.map(m => `${m.id}:${m.content.length}:${m.tool_calls?.length ?? 0}`).join('|');
+    if (signature === publishedRef.current) {
+      return;
+    }
+    publishedRef.current = signature;
+
+    // The history goes through the export hooks, like /export, so a secret
+    // pasted earlier isn't shown to viewers. Only the latest history is sent,
+    // and only new or edited messages go through the hooks again.
+    const publish = ++publishCountRef.current;
+    redactTranscript(messages, { promptName: state.activePromptName }, { cache: redactionCacheRef.current })
+      .then(({ messages: redacted, report }) => {
+        if (publish !== publishCountRef.current) {
+          return;
+        }
+        if (report) {
+          console.log(`Observer feed: ${report}`);
+        }
+        return window.electronAPI.observerPublish(redacted.map(m => ({
+          role: m.role,
+          content: m.content,
+          ...(m.thinking && { thinking: m.thinking }),
+          ...(m.tool_calls && {
+            tool_calls: m.tool_calls.map(tc => ({
+              id: tc.id,
+              function: { name: tc.function.name, arguments: redactToolArgsJson(tc.function.name, tc.function.arguments) },
+            })),
+          }),
+          ...(m.tool_call_id && { tool_call_id: m.tool_call_id }),
+        })));
+      })
+      .catch(error => console.error('Failed to update observers:', error));
+  }, [active, state.messages, state.streamingMessageId, state.activePromptName]);
+};
@@ -0,0 +1,34 @@
+import { useEffect, useState } from 'react';
+
+// A code block /apply is previewing as a diff against the file it replaces,
+// until the user applies or cancels it from the dialog.
+
+export interface PendingApply {
+  path: string;
+  oldContent: string;
+  newContent: string;
+  warnings: string[];
+  projectPath: string;
+}
+
+let pendingApply: PendingApply | null = null;
+const listeners = new Set<() => void>();
+
+export function setPendingApply(pending: PendingApply | null) {
+  pendingApply = pending;
+  listeners.forEach(listener => listener());
+}
+
+export const usePendingApply = () => {
+  const [state, setState] = useState(pendingApply);
+
+  useEffect(() => {
+    const listener = () => setState(pendingApply);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
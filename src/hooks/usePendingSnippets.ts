@@ -0,0 +1,50 @@
+import { useEffect, useState } from 'react';
+import type { Snippet } from '../utils/snippets';
+
+// Code blocks /snip didn't write because some of the files already exist,
+// waiting for the user to confirm the overwrite from the notice.
+
+export interface PendingSnippets {
+  files: Snippet[];
+  existing: string[];
+  projectPath: string | null;
+}
+
+let pendingSnippets: PendingSnippets | null = null;
+const listeners = new Set<() => void>();
+
+export function setPendingSnippets(pending: PendingSnippets | null) {
+  pendingSnippets = pending;
+  listeners.forEach(listener => listener());
+}
+
+/**
+ * Write the snippets, or leave them pending when that would overwrite files.
+ * Returns the paths written, or null when confirmation is needed.
+ */
+export async function writeSnippets(files: Snippet[], projectPath: string | null, overwrite = false): Promise<string[] | null> {
+  const result = await window.electronAPI.snippetWriteFiles(projectPath, files, overwrite);
+  if (!result.success) {
+    throw new Error(result.error || 'Failed to write the code blocks');
+  }
+  if (result.written.length === 0 && result.existing.length > 0) {
+    setPendingSnippets({ files, existing: result.existing, projectPath });
+    return null;
+  }
+  setPendingSnippets(null);
+  return result.written;
+}
+
+export const usePendingSnippets = () => {
+  const [state, setState] = useState(pendingSnippets);
+
+  useEffect(() => {
+    const listener = () => setState(pendingSnippets);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
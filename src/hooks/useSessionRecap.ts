@@ -0,0 +1,96 @@
+import { useEffect, useRef, useState } from 'react';
+import type { ChatState } from '../context/ChatContext';
+import { conversationStore } from '../context/conversationStore';
+import { titleModel } from '../utils/sessionTitle';
+import { generateSessionRecap, readSessionRecapSettings, recapMessages } from '../utils/sessionRecap';
+
+// The recap shown when a session is opened again. Loading a session asks for
+// one; it's written once a model is selected, and goes away when the next
+// prompt is sent, another session is opened or it is dismissed.
+
+export interface SessionRecap {
+  sessionId: string;
+  text: string | null; // null while the model writes it
+}
+
+let recap: SessionRecap | null = null;
+const listeners = new Set<() => void>();
+
+function update(next: SessionRecap | null) {
+  recap = next;
+  listeners.forEach(listener => listener());
+}
+
+export function requestSessionRecap(sessionId: string) {
+  update({ sessionId, text: null });
+}
+
+export function setSessionRecap(sessionId: string, text: string) {
+  update({ sessionId, text });
+}
+
+export function clearSessionRecap() {
+  update(null);
+}
+
+const useRecapState = () => {
+  const [state, setState] = useState(recap);
+
+  useEffect(() => {
+    const listener = () => setState(recap);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
+
+// Writes the requested recap with the session's model, or the one the
+// preference names, and returns the recap to show
+export const useSessionRecap = (state: ChatState) => {
+  const current = useRecapState();
+  const writingRef = useRef<SessionRecap | null>(null);
+
+  // Out of date once the conversation moves on or is left
+  useEffect(() => {
+    if (current && (state.isLoading || current.sessionId !== state.currentSessionId)) {
+      clearSessionRecap();
+    }
+  }, [current, state.isLoading, state.currentSessionId]);
+
+  useEffect(() => {
+    if (!current || current.text !== null || writingRef.current === current) {
+      return;
+    }
+    const request = current;
+    writingRef.current = request;
+
+    (async () => {
+      const settings = await readSessionRecapSettings();
+      const latest = conversationStore.getState();
+      if (!settings.enabled || recapMessages(latest.messages).length < settings.minMessages) {
+        clearSessionRecap();
+        return;
+      }
+      const selection = titleModel(settings, latest.providers, latest.currentProvider, latest.currentModel, latest.offlineMode);
+      if (!selection) {
+        // At startup the model may not be restored yet; this runs again when it is
+        writingRef.current = null;
+        return;
+      }
+      const text = await generateSessionRecap(latest.messages, selection);
+      if (recap === request) {
+        update({ ...request, text });
+      }
+    })().catch(error => {
+      console.warn('Failed to write a session recap:', error);
+      if (recap === request) {
+        clearSessionRecap();
+      }
+    });
+  }, [current, state.providers, state.currentProvider, state.currentModel, state.offlineMode]);
+
+  return current;
+};
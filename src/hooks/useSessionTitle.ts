@@ -0,0 +1,49 @@
+import { useEffect, useRef } from 'react';
+import type { ChatState, ChatAction } from '../context/ChatContext';
+import { conversationStore, getDisplayName } from '../context/conversationStore';
+import { firstExchange, generateSessionTitle, readSessionTitleSettings, titleModel } from '../utils/sessionTitle';
+
+// Names an unnamed session once its first prompt has been answered here. A
+// name given by the user, before or while the title is written, always wins.
+export const useSessionTitle = (state: ChatState, dispatch: React.Dispatch<ChatAction>) => {
+  const wasLoadingRef = useRef(false);
+  // Sessions already asked about, so a failed request isn't repeated every turn
+  const attemptedRef = useRef(new Set<string>());
+
+  useEffect(() => {
+    if (state.isLoading) {
+      wasLoadingRef.current = true;
+      return;
+    }
+    if (!wasLoadingRef.current) {
+      return;
+    }
+    wasLoadingRef.current = false;
+
+    const current = conversationStore.getState();
+    const sessionId = current.currentSessionId;
+    // A session that already has a title keeps it when reopened
+    const titled = !!current.currentSessionName && current.currentSessionName !== getDisplayName(sessionId, '', false);
+    if (current.isCustomName || titled || attemptedRef.current.has(sessionId) || !firstExchange(current.messages)) {
+      return;
+    }
+    attemptedRef.current.add(sessionId);
+
+    (async () => {
+      const settings = await readSessionTitleSettings();
+      if (!settings.enabled) {
+        return;
+      }
+      const selection = titleModel(settings, current.providers, current.currentProvider, current.currentModel, current.offlineMode);
+      if (!selection) {
+        return;
+      }
+      const title = await generateSessionTitle(current.messages, selection);
+      const latest = conversationStore.getState();
+      if (latest.currentSessionId === sessionId && !latest.isCustomName) {
+        // Not custom, so a name the user gives later still takes its place
+        dispatch({ type: 'SET_SESSION_NAME', payload: { name: title, isCustom: false } });
+      }
+    })().catch(error => console.warn('Failed to generate a session title:', error));
+  }, [state.isLoading, dispatch]);
+};
@@ -0,0 +1,1797 @@
+import { useCallback, useMemo } from 'react';
+import type { ChatState, ChatAction } from '../context/ChatContext';
+import { ENGINE_EVENT_KINDS, type ChatMessage, type ContextMode, type EngineEventKind, type GenerationOptions, type ThinkingDisplay, type ThinkingSettings, type WorkspaceTrust } from '../types/chat';
+import { commandArgsAfter, parseSlashCommand } from '../utils/slashCommands';
+import { extractCodeBlocks, extractFencedBlocks } from '../utils/codeFence';
+import { exportTranscript, formatForPath, EXPORT_FORMATS, type ExportFormat } from '../utils/exportTranscript';
+import { copyMessagesForMerge, estimateMessageTokens, findMessageByNumber, findSessionByRef, getMessageNumber, parseMessageRange, selectMessageRange } from '../utils/messageUtils';
+import { getActiveSummary, getSummarizedIds } from '../utils/contextSummary';
+import { speechManager, voiceInput } from '../speech';
+import { toolStats } from '../tools/toolStats';
+import { toolRegistry } from '../tools/ToolRegistry';
+import { setObserving } from './useObserverFeed';
+import { setFindQuery } from './useFindInSession';
+import { getDebugPane, setDebugPane, type DebugLevel } from './useDebugLog';
+import { getEventsPane, setEventsPane } from './useEngineEvents';
+import { addAttachment, clearAttachments, getAttachments } from './useAttachments';
+import { setInputDraft } from './useInputDraft';
+import { writeSnippets } from './usePendingSnippets';
+import { setPendingApply } from './usePendingApply';
+import { setToolOutputPage } from './useToolOutputPager';
+import { setSessionRecap } from './useSessionRecap';
+import { getVimEnabled, setVimEnabled } from './useVimMode';
+import { getKeyBindings, reloadKeyBindings } from './useKeyBindings';
+import { formatKey, KEY_ACTIONS } from '../utils/keyBindings';
+import { describeSavedSnippets, snippetFiles } from '../utils/snippets';
+import { applyWarnings, attachedFiles, proposedEdit } from '../utils/applyEdit';
+import { expandPromptTemplate, templateArgumentValues, templateVariables } from '../utils/promptTemplates';
+import { getLastSelection } from '../utils/lastSelection';
+import { languageName } from '../utils/languageName';
+import { findModelByRef } from '../utils/modelUtils';
+import { redactTranscript } from '../utils/transcriptRedaction';
+import { generateSessionTitle, readSessionTitleSettings, titleModel } from '../utils/sessionTitle';
+import { generateSessionRecap, readSessionRecapSettings, recapMessages } from '../utils/sessionRecap';
+import { getFullOutput, toolOutputText } from '../tools/toolOutputLimit';
+
+const ENV_KEY_PATTERN = /^[A-Za-z_][A-Za-z0-9_]*$/;
+
+const THINKING_DISPLAYS: Record<string, ThinkingDisplay> = {
+  show: 'expanded',
+  expand: 'expanded',
+  collapse: 'collapsed',
+  hide: 'hidden',
+};
+
+const RATING_VALUES: Record<string, 'good' | 'bad' | null> = {
+  good: 'good',
+  up: 'good',
+  '+': 'good',
+  bad: 'bad',
+  down: 'bad',
+  '-': 'bad',
+  clear: null,
+};
+
+// Options /set accepts, and whether they take whole numbers or text
+const GENERATION_OPTIONS: Record<keyof GenerationOptions, 'integer' | 'number' | 'strings'> = {
+  temperature: 'number',
+  top_p: 'number',
+  top_k: 'integer',
+  min_p: 'number',
+  num_ctx: 'integer',
+  num_predict: 'integer',
+  repeat_penalty: 'number',
+  seed: 'integer',
+  stop: 'strings',
+};
+
+// Names the other APIs use, for /set
+const OPTION_ALIASES: Record<string, keyof GenerationOptions> = {
+  max_tokens: 'num_predict',
+};
+
+// The options providers other than Ollama send on, by provider type
+const PROVIDER_OPTIONS: Record<string, Array<keyof GenerationOptions>> = {
+  claude: ['temperature', 'top_p', 'top_k', 'num_predict', 'stop'],
+  openai: ['num_predict', 'stop'],
+  lmstudio: ['num_predict', 'stop'],
+  gemini: ['num_predict', 'stop'],
+};
+
+// \n and \t in a stop sequence, since the input box sends on Enter
+const unescapeStop = (value: string): string =>
+  value.replace(/\\(n|t|\\)/g, (_, c: string) => (c === 'n' ? '\n' : c === 't' ? '\t' : '\\'));
+
+// A seed from /seed: a whole number, or "random" for a new one
+const parseSeed = (value: string): number => {
+  if (value === 'random') {
+    return Math.floor(Math.random() * 2 ** 31);
+  }
+  const seed = Number(value);
+  if (!Number.isSafeInteger(seed) || seed < 0) {
+    throw new Error(`"${value}" is not a seed. Use a whole number, or random.`);
+  }
+  return seed;
+};
+
+const formatGenerationOptions = (options: GenerationOptions): string =>
+  Object.entries(options)
+    .map(([name, value]) => `${name}=${Array.isArray(value) ? value.map(v => JSON.stringify(v)).join(',') : value}`)
+    .join(' ');
+
+export interface SlashCommand {
+  name: string;
+  usage: string;
+  description: string;
+  // Commands that start a request can't run while a response is streaming
+  allowWhileLoading?: boolean;
+  run: (args: string[], rawArgs: string, context: SlashCommandContext) => void | Promise<void>;
+}
+
+export interface SlashCommandContext {
+  // System prompt selected in the input box when the command was entered
+  systemPrompt?: string;
+}
+
+export interface SlashCommandHandlers {
+  handleContinue: () => Promise<void>;
+  handleSendMessage: (messageText: string, systemPrompt?: string) => Promise<void>;
+  handleAskModel: (modelRef: string, messageText: string, systemPrompt?: string) => Promise<void>;
+  handleSetOfflineMode: (enabled: boolean) => Promise<void>;
+  handleSetThinking: (updates: Partial<ThinkingSettings>) => Promise<ThinkingSettings>;
+  handleShowModels: (providerRef?: string) => Promise<void>;
+  handleSwitchModel: (modelRef: string) => Promise<string | null>; // The "provider/model" switched to, or null when the picker opened
+  handleCompactContext: () => Promise<number>; // Number of messages summarized
+  getContextMode: () => ContextMode;
+  handleLoadSession: (sessionId: string) => Promise<void>;
+  handleDiffLast: (modelRef?: string, systemPrompt?: string) => Promise<void>;
+  handleSetWorkspaceTrust: (trust: WorkspaceTrust | null) => Promise<{ trust: WorkspaceTrust | null; decidedFor: string | null }>;
+  handlePreviewMessage: (messageText: string, systemPrompt?: string) => Promise<void>;
+  handleRetryLast: (systemPrompt?: string) => Promise<void>;
+  handleEditLast: () => void;
+}
+
+export const useSlashCommands = (
+  state: ChatState,
+  dispatch: React.Dispatch<ChatAction>,
+  handlers: SlashCommandHandlers,
+  workingDirectory?: string
+) => {
+  const commands = useMemo<SlashCommand[]>(() => {
+    const list: SlashCommand[] = [
+      {
+        name: 'continue',
+        usage: '/continue',
+        description: 'Resume a stopped response, or ask the model to keep going',
+        run: () => handlers.handleContinue(),
+      },
+      {
+        name: 'retry',
+        usage: '/retry',
+        description: 'Send your last message again, dropping the reply to it',
+        run: (_args, _rawArgs, context) => handlers.handleRetryLast(context.systemPrompt),
+      },
+      {
+        name: 'edit',
+        usage: '/edit',
+        description: 'Take your last message and the reply to it back into the input box to change and resend',
+        run: () => handlers.handleEditLast(),
+      },
+      {
+        name: 'ask',
+        usage: '/ask <model> <prompt>',
+        description: 'Send one message to another model (same as "@model: prompt")',
+        run: (args, rawArgs, context) => {
+          const [modelRef] = args;
+          const prompt = modelRef ? rawArgs.substring(rawArgs.indexOf(modelRef) + modelRef.length).trim() : '';
+          if (!modelRef || !prompt) {
+            throw new Error('Usage: /ask <model> <prompt>');
+          }
+          return handlers.handleAskModel(modelRef, prompt, context.systemPrompt);
+        },
+      },
+      {
+        name: 'diff',
+        usage: '/diff last [model]',
+        description: 'Ask the last prompt again, optionally with another model, and compare the answers',
+        run: (args, _rawArgs, context) => {
+          const [target, modelRef] = args;
+          if (target !== 'last') {
+            throw new Error('Usage: /diff last [model]');
+          }
+          return handlers.handleDiffLast(modelRef, context.systemPrompt);
+        },
+      },
+      {
+        name: 'model',
+        usage: '/model [name]',
+        description: 'Switch to a model by any part of its name, or pick one from the models the server has',
+        run: async (_args, rawArgs) => {
+          if (!rawArgs) {
+            await handlers.handleShowModels();
+            return;
+          }
+          const switched = await handlers.handleSwitchModel(rawArgs);
+          if (switched) {
+            dispatch({ type: 'SET_NOTICE', payload: `Switched to ${switched}` });
+          }
+        },
+      },
+      {
+        name: 'models',
+        usage: '/models [provider]',
+        description: 'List the models the provider server has and pick one',
+        run: async (args) => {
+          await handlers.handleShowModels(args[0]);
+        },
+      },
+      {
+        name: 'caps',
+        usage: '/caps [model] [refresh]',
+        description: 'Show what a model supports, as detected from its server and set in the provider config',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const refresh = args[args.length - 1]?.toLowerCase() === 'refresh';
+          const [ref, ...rest] = refresh ? args.slice(0, -1) : args;
+          if (rest.length > 0) {
+            throw new Error('Usage: /caps [model] [refresh]');
+          }
+          const selection = ref
+            ? findModelByRef(state.providers, ref)
+            : state.currentProvider && state.currentModel ? { provider: state.currentProvider, model: state.currentModel } : null;
+          if (!selection) {
+            throw new Error(ref ? `Unknown model: ${ref}` : 'Select a model first');
+          }
+
+          const result = await window.electronAPI.chatModelCapabilities({
+            provider: selection.provider.id,
+            model: selection.model.id,
+            refresh,
+          });
+          if (!result.success || !result.capabilities) {
+            throw new Error(result.error || 'Failed to get model capabilities');
+          }
+          const caps = result.capabilities;
+          const supported = (value: boolean | null) => (value === null ? 'unknown' : value ? 'yes' : 'no');
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: [
+              `${selection.provider.id}/${selection.model.id}${caps.detected ? '' : " (the server doesn't report capabilities)"}`,
+              `Tools: ${supported(caps.tools)} · Thinking: ${supported(caps.thinking)} · Images: ${supported(caps.images)}`,
+              `Context: ${caps.contextLength ? `${caps.contextLength.toLocaleString()} tokens` : 'unknown'}`,
+              'Override with supportsTools, supportsThinking, supportsImages or contextLength on the model in the provider config',
+            ].join('\n'),
+          });
+        },
+      },
+      {
+        name: 'context',
+        usage: '/context [compact]',
+        description: 'Show context window usage, or summarize older messages now',
+        run: async (args) => {
+          if (args[0] === 'compact') {
+            const count = await handlers.handleCompactContext();
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: count > 0
+                ? `Summarized ${count} earlier message${count === 1 ? '' : 's'}. They stay in the transcript but are no longer sent.`
+                : 'Nothing to summarize yet',
+            });
+            return;
+          }
+          if (args.length > 0) {
+            throw new Error('Usage: /context [compact]');
+          }
+
+          const summarized = getSummarizedIds(state.messages);
+          const sent = state.messages.filter(m => !m.contextSummary && !summarized.has(m.id));
+          const summarizedCount = state.messages.filter(m => !m.contextSummary && summarized.has(m.id)).length;
+          const summary = getActiveSummary(state.messages);
+          const usage = state.contextUsage;
+          const lines = [
+            usage
+              ? `Context: ${usage.used.toLocaleString()} / ${usage.total.toLocaleString()} tokens (${Math.round((usage.used / usage.total) * 100)}%), ${handlers.getContextMode()} mode`
+              : `Context: window size unknown, ${handlers.getContextMode()} mode`,
+            `Messages: ${sent.length} sent${summary ? `, ${summarizedCount} summarized by ${summary.contextSummary?.providerId}/${summary.contextSummary?.modelId}` : ''}`,
+          ];
+
+          const largest = [...sent]
+            .sort((a, b) => estimateMessageTokens(b) - estimateMessageTokens(a))
+            .slice(0, 3)
+            .filter(m => estimateMessageTokens(m) > 0);
+          if (largest.length > 0) {
+            const labels = largest.map(m => {
+              const number = getMessageNumber(state.messages, m.id);
+              const label = m.role === 'tool' ? 'tool result' : `#${number} ${m.role === 'user' ? 'You' : 'Assistant'}`;
+              return `${label} ${estimateMessageTokens(m).toLocaleString()}`;
+            });
+            lines.push(`Largest: ${labels.join(' · ')}`);
+          }
+          dispatch({ type: 'SET_NOTICE', payload: lines.join('\n') });
+        },
+      },
+      {
+        name: 'offline',
+        usage: '/offline [on|off]',
+        description: 'Only allow local Ollama and disable network tools',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [value] = args;
+          if (value && value !== 'on' && value !== 'off') {
+            throw new Error('Usage: /offline [on|off]');
+          }
+          const enabled = value ? value === 'on' : !state.offlineMode;
+          await handlers.handleSetOfflineMode(enabled);
+          dispatch({ type: 'SET_NOTICE', payload: enabled ? 'Offline mode on: only local Ollama providers and offline tools are available.' : 'Offline mode off.' });
+        },
+      },
+      {
+        name: 'trust',
+        usage: '/trust [yes|no|forget]',
+        description: 'Trust this workspace to enable file-writing, shell and MCP tools, or keep them off',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [value] = args.map(a => a.toLowerCase());
+          if (!workingDirectory) {
+            throw new Error('No working directory.');
+          }
+          if (!value) {
+            const result = await window.electronAPI.workspaceTrustGet(workingDirectory);
+            if (!result.success) {
+              throw new Error(result.error || 'Failed to read workspace trust');
+            }
+            const from = result.decidedFor && result.decidedFor !== workingDirectory ? ` (decided for ${result.decidedFor})` : '';
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: result.trust === 'trusted'
+                ? `${workingDirectory} is trusted${from}: all enabled tools are available.`
+                : `${workingDirectory} is ${result.trust === 'restricted' ? 'restricted' : 'not trusted yet'}${from}: file-writing, shell and MCP tools are off. /trust yes turns them on.`,
+            });
+            return;
+          }
+          if (value !== 'yes' && value !== 'no' && value !== 'forget') {
+            throw new Error('Usage: /trust [yes|no|forget]');
+          }
+
+          const result = await handlers.handleSetWorkspaceTrust(value === 'yes' ? 'trusted' : value === 'no' ? 'restricted' : null);
+          const notices: Record<string, string> = {
+            yes: `Trusted ${workingDirectory} and the folders in it: file-writing, shell and MCP tools are available.`,
+            no: `${workingDirectory} is restricted: file-writing, shell and MCP tools stay off.`,
+            forget: result.trust
+              ? `Forgot the decision for ${workingDirectory}; ${result.decidedFor} is ${result.trust}, which applies here.`
+              : `Forgot the decision for ${workingDirectory}; it isn't trusted until /trust yes.`,
+          };
+          dispatch({ type: 'SET_NOTICE', payload: notices[value] });
+        },
+      },
+      {
+        name: 'stats',
+        usage: '/stats [on|off]',
+        description: 'Show token counts and generation speed under each response',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [value] = args.map(a => a.toLowerCase());
+          if (value && value !== 'on' && value !== 'off') {
+            throw new Error('Usage: /stats [on|off]');
+          }
+          const enabled = value ? value === 'on' : !state.showStats;
+          dispatch({ type: 'SET_SHOW_STATS', payload: enabled });
+          await window.electronAPI.preferencesSet('showStats', enabled);
+          dispatch({ type: 'SET_NOTICE', payload: enabled ? 'Generation stats shown under responses.' : 'Generation stats hidden.' });
+        },
+      },
+      {
+        name: 'thinking',
+        usage: '/thinking [show|collapse|hide] | /thinking save [on|off] | /thinking limit|retain <chars|off>',
+        description: 'Choose how reasoning is shown, how much of it is kept, and whether sessions save it',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action, value] = args.map(a => a.toLowerCase());
+          const usage = 'Usage: /thinking [show|collapse|hide] | /thinking save [on|off] | /thinking limit|retain <chars|off>';
+
+          if (action === 'save') {
+            if (value && value !== 'on' && value !== 'off') {
+              throw new Error(usage);
+            }
+            const saveToHistory = value ? value === 'on' : !state.thinking.saveToHistory;
+            await handlers.handleSetThinking({ saveToHistory });
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: saveToHistory
+                ? 'Reasoning will be kept in saved sessions.'
+                : 'Reasoning will be left out of saved sessions. It still shows until the session is reloaded.',
+            });
+            return;
+          }
+
+          if (action === 'limit' || action === 'retain') {
+            const chars = value === 'off' ? 0 : Number(value);
+            if (!value || !Number.isInteger(chars) || chars < 0) {
+              throw new Error(usage);
+            }
+            const key = action === 'limit' ? 'renderLimit' : 'retainLimit';
+            await handlers.handleSetThinking({ [key]: chars });
+            const what = action === 'limit' ? 'shown per message' : 'kept in saved sessions';
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: chars > 0 ? `Only the last ${chars} characters of reasoning will be ${what}.` : `All reasoning will be ${what}.`,
+            });
+            return;
+          }
+
+          if (action && !THINKING_DISPLAYS[action]) {
+            throw new Error(usage);
+          }
+          const settings = action
+            ? await handlers.handleSetThinking({ display: THINKING_DISPLAYS[action] })
+            : state.thinking;
+          const limit = (chars: number) => chars > 0 ? `last ${chars} chars` : 'no limit';
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: `Thinking is ${settings.display} (${limit(settings.renderLimit)}); ${settings.saveToHistory ? `kept in saved sessions (${limit(settings.retainLimit)})` : 'left out of saved sessions'}.`,
+          });
+        },
+      },
+      {
+        name: 'system',
+        usage: '/system [edit | set <text> | reset]',
+        description: 'Show the system prompt, edit the selected one, or override it for this session',
+        allowWhileLoading: true,
+        run: async (args, rawArgs, context) => {
+          const action = args[0]?.toLowerCase();
+          const promptName = state.activePromptName;
+
+          if (action === 'set') {
+            const text = rawArgs.trim().slice(args[0].length).trim();
+            if (!text) {
+              throw new Error('Usage: /system set <text>');
+            }
+            dispatch({ type: 'SET_SESSION_SYSTEM_PROMPT', payload: text });
+            dispatch({ type: 'SET_NOTICE', payload: 'This session now uses its own system prompt. /system reset goes back to the selected one.' });
+            return;
+          }
+          if (action === 'reset') {
+            dispatch({ type: 'SET_SESSION_SYSTEM_PROMPT', payload: null });
+            dispatch({ type: 'SET_NOTICE', payload: promptName ? `Using the "${promptName}" prompt again` : 'Using no system prompt again' });
+            return;
+          }
+          if (action === 'edit') {
+            if (!promptName) {
+              throw new Error('No prompt is selected. Pick one in the input box, or use /system set <text>.');
+            }
+            const result = await window.electronAPI.promptsOpen(promptName);
+            if (!result.success) {
+              throw new Error(result.error || `Could not open the "${promptName}" prompt`);
+            }
+            // Prompts are read again for every message, so saved changes apply to the next one
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: state.sessionSystemPrompt !== null
+                ? `Opened "${promptName}". This session overrides it until /system reset.`
+                : `Opened "${promptName}"; saved changes apply from the next message.`,
+            });
+            return;
+          }
+          if (action) {
+            throw new Error('Usage: /system [edit | set <text> | reset]');
+          }
+
+          if (state.sessionSystemPrompt !== null) {
+            dispatch({ type: 'SET_NOTICE', payload: `Session system prompt (set with /system set):\n\n${state.sessionSystemPrompt}` });
+          } else if (promptName && context.systemPrompt) {
+            dispatch({ type: 'SET_NOTICE', payload: `System prompt "${promptName}":\n\n${context.systemPrompt}` });
+          } else {
+            dispatch({ type: 'SET_NOTICE', payload: 'No system prompt is in use' });
+          }
+        },
+      },
+      {
+        name: 'set',
+        usage: '/set [<option> <value|off> | stop <text>... | reset]',
+        description: `Set a generation option for this session (${Object.keys(GENERATION_OPTIONS).join(', ')}; max_tokens is num_predict)`,
+        allowWhileLoading: true,
+        run: (args) => {
+          const [given, ...values] = args;
+          const configured = state.currentModel?.options || {};
+          const providerType = state.currentProvider?.type;
+          const passed = providerType && providerType !== 'ollama' ? PROVIDER_OPTIONS[providerType] ?? [] : null;
+          if (!given) {
+            const session = formatGenerationOptions(state.generationOptions);
+            const model = formatGenerationOptions(configured);
+            const stop = state.currentModel?.stop?.length ? ` stop ${state.currentModel.stop.map(v => JSON.stringify(v)).join(',')}` : '';
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: [
+                `Session: ${session || 'none set'}`,
+                `${state.currentModel?.name || 'Model'} config: ${(model + stop).trim() || 'none'}`,
+                ...(passed ? [`${state.currentProvider?.name} uses ${passed.join(', ') || 'none of these'}`] : []),
+              ].join('\n'),
+            });
+            return;
+          }
+          if (given === 'reset') {
+            dispatch({ type: 'SET_GENERATION_OPTIONS', payload: {} });
+            dispatch({ type: 'SET_NOTICE', payload: 'Generation options are back to the model config' });
+            return;
+          }
+
+          const name = OPTION_ALIASES[given] ?? given as keyof GenerationOptions;
+          const kind = GENERATION_OPTIONS[name];
+          if (!kind) {
+            throw new Error(`Unknown option "${given}". Options: ${Object.keys(GENERATION_OPTIONS).join(', ')}, max_tokens`);
+          }
+          if (values.length === 0 || (kind !== 'strings' && values.length > 1)) {
+            throw new Error(kind === 'strings' ? `Usage: /set ${given} <text>... | off` : `Usage: /set ${given} <value|off>`);
+          }
+          if (values[0] === 'off' && values.length === 1) {
+            const remaining = { ...state.generationOptions };
+            delete remaining[name];
+            dispatch({ type: 'SET_GENERATION_OPTIONS', payload: remaining });
+            const fallback = configured[name];
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: `${name} is back to ${fallback === undefined ? "the model's default" : Array.isArray(fallback) ? fallback.map(v => JSON.stringify(v)).join(', ') : fallback}`,
+            });
+            return;
+          }
+
+          let value: number | string[];
+          if (kind === 'strings') {
+            value = values.map(unescapeStop).filter(v => v.length > 0);
+            if (value.length === 0) {
+              throw new Error('Stop sequences need some text, e.g. /set stop "\\n\\nUser:"');
+            }
+          } else {
+            value = Number(values[0]);
+            if (!Number.isFinite(value) || (kind === 'integer' && !Number.isInteger(value))) {
+              throw new Error(`${given} needs ${kind === 'integer' ? 'a whole number' : 'a number'}`);
+            }
+          }
+
+          dispatch({ type: 'SET_GENERATION_OPTIONS', payload: { ...state.generationOptions, [name]: value } });
+          const unsupported = passed && !passed.includes(name)
+            ? ` (${state.currentProvider?.name} ignores it)`
+            : '';
+          const shown = Array.isArray(value) ? value.map(v => JSON.stringify(v)).join(', ') : value;
+          dispatch({ type: 'SET_NOTICE', payload: `${name}=${shown} for this session${unsupported}` });
+        },
+      },
+      {
+        name: 'seed',
+        usage: '/seed [<n>|random|off | default <n|off>]',
+        description: 'Pin the sampling seed for reproducible responses, for this session or by default',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [value, defaultValue] = args;
+          if (value === 'default') {
+            if (!defaultValue) {
+              throw new Error('Usage: /seed default <n|off>');
+            }
+            const seed = defaultValue === 'off' ? null : parseSeed(defaultValue);
+            const result = await window.electronAPI.preferencesSet('seed', seed);
+            if (!result.success) {
+              throw new Error(result.error || 'Failed to save the default seed');
+            }
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: seed === null
+                ? 'No default seed; sampling is random unless a session or model pins one'
+                : `Default seed is ${seed} for every session and poe -p, unless a session or model sets its own`,
+            });
+            return;
+          }
+          if (value === 'off') {
+            const remaining = { ...state.generationOptions };
+            delete remaining.seed;
+            dispatch({ type: 'SET_GENERATION_OPTIONS', payload: remaining });
+            dispatch({ type: 'SET_NOTICE', payload: 'This session no longer pins a seed' });
+            return;
+          }
+          if (value) {
+            const seed = parseSeed(value);
+            dispatch({ type: 'SET_GENERATION_OPTIONS', payload: { ...state.generationOptions, seed } });
+            const unsupported = state.currentProvider && state.currentProvider.type !== 'ollama'
+              ? ` (${state.currentProvider.name} ignores it; only Ollama providers take a seed)`
+              : '';
+            dispatch({ type: 'SET_NOTICE', payload: `Seed ${seed} for this session${unsupported}` });
+            return;
+          }
+
+          const preference = await window.electronAPI.preferencesGet('seed');
+          const defaultSeed = preference.success && typeof preference.value === 'number' ? preference.value : null;
+          const modelSeed = state.currentModel?.options?.seed;
+          const lines = state.generationOptions.seed !== undefined
+            ? [`Seed ${state.generationOptions.seed} (this session)`]
+            : modelSeed !== undefined
+            ? [`Seed ${modelSeed} (${state.currentModel?.name} config)`]
+            : defaultSeed !== null
+            ? [`Seed ${defaultSeed} (default)`]
+            : ['No seed is pinned; each response samples differently'];
+          if (defaultSeed !== null && (state.generationOptions.seed !== undefined || modelSeed !== undefined)) {
+            lines.push(`Default seed: ${defaultSeed}`);
+          }
+          dispatch({ type: 'SET_NOTICE', payload: lines.join('\n') });
+        },
+      },
+      {
+        name: 'lang',
+        usage: '/lang [code|off]',
+        description: 'Always reply in a language (e.g. ja, pt-BR), whatever the prompt is in',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [code] = args;
+          if (!code) {
+            const result = await window.electronAPI.preferencesGet('responseLanguage');
+            const current = result.success && typeof result.value === 'string' ? result.value : null;
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: current
+                ? `Replies are requested in ${languageName(current)} (${current}). Use /lang off to follow the prompt's language.`
+                : 'Replies follow the language of the prompt. Use /lang <code> to pick one.',
+            });
+            return;
+          }
+          if (code.toLowerCase() === 'off') {
+            await window.electronAPI.preferencesSet('responseLanguage', null);
+            dispatch({ type: 'SET_NOTICE', payload: 'Replies follow the language of the prompt' });
+            return;
+          }
+
+          let canonical: string;
+          try {
+            [canonical] = Intl.getCanonicalLocales(code);
+          } catch {
+            throw new Error(`"${code}" is not a language code. Use a code such as en, de, ja or pt-BR.`);
+          }
+          await window.electronAPI.preferencesSet('responseLanguage', canonical);
+          dispatch({ type: 'SET_NOTICE', payload: `Replies will be in ${languageName(canonical)} (${canonical})` });
+        },
+      },
+      {
+        name: 'length',
+        usage: '/length [short|normal|long]',
+        description: 'Ask for shorter or longer replies, with a matching response token limit on Ollama',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [length] = args;
+          if (!length) {
+            const result = await window.electronAPI.preferencesGet('responseLength');
+            const current = result.success && typeof result.value === 'string' ? result.value : 'normal';
+            dispatch({ type: 'SET_NOTICE', payload: `Response length: ${current}. Use /length short|normal|long to change it.` });
+            return;
+          }
+          if (!['short', 'normal', 'long'].includes(length)) {
+            throw new Error('Usage: /length [short|normal|long]');
+          }
+
+          await window.electronAPI.preferencesSet('responseLength', length === 'normal' ? null : length);
+          const notices: Record<string, string> = {
+            short: 'Replies will be brief, at most about 1,000 tokens on Ollama (/set num_predict overrides it)',
+            normal: 'Replies are back to their usual length',
+            long: 'Replies will be thorough, with no response token limit on Ollama',
+          };
+          dispatch({ type: 'SET_NOTICE', payload: notices[length] });
+        },
+      },
+      {
+        name: 'json',
+        usage: '/json [on|fix|off]',
+        description: 'Ask for JSON replies, checked and repaired before they are shown; fix also asks the model to correct invalid ones',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [mode] = args.map(a => a.toLowerCase());
+          if (!mode) {
+            const result = await window.electronAPI.preferencesGet('jsonOutput');
+            const current = result.success && typeof result.value === 'string' ? result.value : 'off';
+            dispatch({ type: 'SET_NOTICE', payload: `JSON output: ${current}. Use /json on|fix|off to change it.` });
+            return;
+          }
+          if (!['on', 'fix', 'off'].includes(mode)) {
+            throw new Error('Usage: /json [on|fix|off]');
+          }
+
+          await window.electronAPI.preferencesSet('jsonOutput', mode === 'off' ? null : mode);
+          const notices: Record<string, string> = {
+            on: 'Replies will be JSON, shown once they have been checked (truncated replies are closed, surrounding text is dropped)',
+            fix: "Replies will be JSON; one that still isn't valid after repair is sent back to the model to correct",
+            off: 'Replies are no longer asked to be JSON',
+          };
+          dispatch({ type: 'SET_NOTICE', payload: notices[mode] });
+        },
+      },
+      {
+        name: 'date',
+        usage: '/date [on|off]',
+        description: 'Tell the model the current date with every message',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action] = args.map(a => a.toLowerCase());
+          if (action === 'on' || action === 'off') {
+            await window.electronAPI.preferencesSet('dateContext', action === 'on');
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: action === 'on'
+                ? 'The current date is added to the system prompt'
+                : 'The model is no longer told the date',
+            });
+            return;
+          }
+          if (action) {
+            throw new Error('Usage: /date [on|off]');
+          }
+          const result = await window.electronAPI.preferencesGet('dateContext');
+          const enabled = !(result.success && result.value === false);
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: enabled
+              ? 'The current date is added to the system prompt. Use /date off to stop.'
+              : 'The model is not told the date. Use /date on to add it.',
+          });
+        },
+      },
+      {
+        name: 'warmup',
+        usage: '/warmup [on|off]',
+        description: 'Load the current model now, or turn loading it on selection on or off',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action] = args.map(a => a.toLowerCase());
+          if (action === 'on' || action === 'off') {
+            await window.electronAPI.preferencesSet('warmUpModel', action === 'on');
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: action === 'on'
+                ? 'Models are loaded as soon as they are selected'
+                : 'Models are loaded with the first message',
+            });
+            if (action === 'off') {
+              return;
+            }
+          } else if (action) {
+            throw new Error('Usage: /warmup [on|off]');
+          }
+
+          if (!state.currentProvider || !state.currentModel) {
+            throw new Error('Select a model first');
+          }
+          const result = await window.electronAPI.chatWarmUp({
+            provider: state.currentProvider.id,
+            model: state.currentModel.id,
+          });
+          if (!result.success) {
+            throw new Error(result.error || 'Warm-up failed');
+          }
+          if (!action) {
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: result.warmed
+                ? `${state.currentModel.name} is loaded`
+                : `${state.currentProvider.name} loads models itself; there is nothing to warm up`,
+            });
+          }
+        },
+      },
+      {
+        name: 'quit',
+        usage: '/quit | /quit confirm <always|generating|never>',
+        description: 'Close the window, or choose when closing asks first',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action, value] = args.map(a => a.toLowerCase());
+          if (!action) {
+            await window.electronAPI.closeWindow();
+            return;
+          }
+          if (action !== 'confirm' || !['always', 'generating', 'never'].includes(value)) {
+            throw new Error('Usage: /quit | /quit confirm <always|generating|never>');
+          }
+          await window.electronAPI.preferencesSet('confirmQuit', value);
+          const when: Record<string, string> = {
+            always: 'Closing POE will always ask first.',
+            generating: 'Closing POE will ask first while a response is being generated.',
+            never: 'Closing POE will never ask first.',
+          };
+          dispatch({ type: 'SET_NOTICE', payload: when[value] });
+        },
+      },
+      {
+        name: 'speak',
+        usage: '/speak [on|off|stop|backend <name>]',
+        description: 'Read finished responses aloud',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action, value] = args.map(a => a.toLowerCase());
+          const settings = speechManager.getSettings();
+          const backends = speechManager.getBackendNames().join(', ');
+
+          if (!action) {
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: `Speech is ${speechManager.isEnabled() ? 'on' : 'off'} (backend: ${settings.backend || 'system'}; available: ${backends})`,
+            });
+            return;
+          }
+
+          if (action === 'stop') {
+            speechManager.stop();
+          } else if (action === 'on' || action === 'off') {
+            await speechManager.updateSettings({ enabled: action === 'on' });
+            dispatch({ type: 'SET_NOTICE', payload: `Speech ${action}` });
+          } else if (action === 'backend' && value) {
+            await speechManager.updateSettings({ backend: value });
+            dispatch({ type: 'SET_NOTICE', payload: `Speech backend: ${value}` });
+          } else {
+            throw new Error(`Usage: /speak [on|off|stop|backend <${backends}>]`);
+          }
+        },
+      },
+      {
+        name: 'voice',
+        usage: '/voice',
+        description: 'Start or stop dictation (Ctrl+Shift+Space); the text lands in the input box',
+        allowWhileLoading: true,
+        run: async () => {
+          const wasRecording = voiceInput.getState() === 'recording';
+          await voiceInput.toggle();
+          if (!wasRecording) {
+            dispatch({ type: 'SET_NOTICE', payload: 'Recording... run /voice again or press Ctrl+Shift+Space to stop' });
+          }
+        },
+      },
+      {
+        name: 'env',
+        usage: '/env [set K=V...|unset K...|clear]',
+        description: 'Session environment for tool commands (not sent to the model)',
+        allowWhileLoading: true,
+        run: (args) => {
+          const [action, ...rest] = args;
+          const env = { ...state.sessionEnv };
+
+          if (!action || action === 'list') {
+            const keys = Object.keys(env).sort();
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: keys.length > 0
+                ? ['Session environment:', ...keys.map(key => `${key}=${env[key]}`)].join('\n')
+                : 'Session environment is empty. Use /env set KEY=value',
+            });
+            return;
+          }
+
+          if (action === 'set') {
+            if (rest.length === 0) {
+              throw new Error('Usage: /env set KEY=value [KEY2=value2...]');
+            }
+            for (const assignment of rest) {
+              const index = assignment.indexOf('=');
+              const key = index > 0 ? assignment.substring(0, index) : '';
+              if (!ENV_KEY_PATTERN.test(key)) {
+                throw new Error(`Invalid assignment "${assignment}", expected KEY=value`);
+              }
+              env[key] = assignment.substring(index + 1);
+            }
+          } else if (action === 'unset') {
+            for (const key of rest) {
+              delete env[key];
+            }
+          } else if (action === 'clear') {
+            Object.keys(env).forEach(key => delete env[key]);
+          } else {
+            throw new Error('Usage: /env [set K=V...|unset K...|clear]');
+          }
+
+          dispatch({ type: 'SET_SESSION_ENV', payload: env });
+          dispatch({ type: 'SET_NOTICE', payload: `Session environment: ${Object.keys(env).sort().join(', ') || '(empty)'}` });
+        },
+      },
+      {
+        name: 'tools',
+        usage: '/tools stats [reset] | cache [clear]',
+        description: 'Show calls, failure rate and timing per tool, or cached tool results',
+        allowWhileLoading: true,
+        run: (args) => {
+          const [action, option] = args.map(a => a.toLowerCase());
+          if (action === 'cache' && (!option || option === 'clear')) {
+            if (option === 'clear') {
+              toolRegistry.clearCache();
+              dispatch({ type: 'SET_NOTICE', payload: 'Tool result cache cleared' });
+              return;
+            }
+            const size = toolRegistry.getCacheSize();
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: size === 0
+                ? 'No cached tool results. Set cacheTtl (seconds) for a tool in tools.json or mcp.json to cache its results.'
+                : `${size} cached tool result${size === 1 ? '' : 's'}`,
+            });
+            return;
+          }
+          if (action !== 'stats' || (option && option !== 'reset')) {
+            throw new Error('Usage: /tools stats [reset] | cache [clear]');
+          }
+          if (option === 'reset') {
+            toolStats.reset();
+            dispatch({ type: 'SET_NOTICE', payload: 'Tool statistics cleared' });
+            return;
+          }
+
+          const all = toolStats.getAll();
+          if (all.length === 0) {
+            dispatch({ type: 'SET_NOTICE', payload: 'No tools have been called yet' });
+            return;
+          }
+          const seconds = (ms: number) => `${(ms / 1000).toFixed(ms < 10000 ? 2 : 1)}s`;
+          const width = Math.max(4, ...all.map(({ toolName }) => toolName.length));
+          const lines = all.map(({ toolName, stats }) => {
+            const failed = `${stats.failures} (${Math.round((stats.failures / stats.calls) * 100)}%)`;
+            return `${toolName.padEnd(width)}  ${String(stats.calls).padStart(5)}  ${failed.padStart(9)}  ${seconds(stats.totalMs / stats.calls).padStart(7)}  ${seconds(stats.maxMs).padStart(7)}`;
+          });
+          const errors = all
+            .filter(({ stats }) => stats.lastError)
+            .map(({ toolName, stats }) => `${toolName}: ${stats.lastError}`);
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: [
+              `${'Tool'.padEnd(width)}  ${'Calls'.padStart(5)}  ${'Failed'.padStart(9)}  ${'Avg'.padStart(7)}  ${'Max'.padStart(7)}`,
+              ...lines,
+              ...(errors.length > 0 ? ['', 'Last errors:', ...errors] : []),
+            ].join('\n'),
+          });
+        },
+      },
+      {
+        name: 'watch',
+        usage: '/watch [<path> [pattern] | stop <id|all>]',
+        description: 'Ask the model to react when a project file changes, or list and stop watches',
+        allowWhileLoading: true,
+        run: async (args) => {
+          if (args[0] === 'stop') {
+            if (!args[1]) {
+              throw new Error('Usage: /watch stop <id|all>');
+            }
+            const result = await window.electronAPI.watchStop(args[1]);
+            if (!result.success) {
+              throw new Error(result.error || 'Failed to stop watching');
+            }
+            dispatch({ type: 'SET_NOTICE', payload: `Stopped ${result.stopped} watch${result.stopped === 1 ? '' : 'es'}` });
+            return;
+          }
+
+          if (args.length === 0) {
+            const watches = await window.electronAPI.watchList();
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: watches.length === 0
+                ? 'Nothing is being watched. Use /watch <path> [pattern] to start.'
+                : watches.map(w => `${w.id}  ${w.path}${w.pattern ? `  /${w.pattern}/` : ''}${w.react ? '' : '  (notify only)'}`).join('\n'),
+            });
+            return;
+          }
+
+          if (!workingDirectory) {
+            throw new Error('Open a project before watching files');
+          }
+          const [path, ...pattern] = args;
+          const result = await window.electronAPI.watchStart(workingDirectory, {
+            path: path.startsWith('/') ? path : `/${path}`,
+            ...(pattern.length > 0 && { pattern: pattern.join(' ') }),
+            react: true,
+          });
+          if (!result.success || !result.watch) {
+            throw new Error(result.error || 'Failed to start watching');
+          }
+          dispatch({ type: 'SET_NOTICE', payload: `Watching ${result.watch.path} (${result.watch.id}); changes will be sent to the model` });
+        },
+      },
+      {
+        name: 'rag',
+        usage: '/rag [status | index <path> | clear | on | off]',
+        description: 'Show or build the project index used to add relevant file excerpts to prompts',
+        allowWhileLoading: true,
+        run: async (args, rawArgs) => {
+          if (!workingDirectory) {
+            throw new Error('Open a project first');
+          }
+          const [action = 'status'] = args.map(a => a.toLowerCase());
+
+          if (action === 'on' || action === 'off') {
+            const current = await window.electronAPI.preferencesGet('rag');
+            const settings = current.success && current.value && typeof current.value === 'object' ? current.value : {};
+            await window.electronAPI.preferencesSet('rag', { ...settings, enabled: action === 'on' });
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: action === 'on'
+                ? 'Excerpts from the project index are added to prompts'
+                : 'The project index is no longer used for prompts',
+            });
+            return;
+          }
+
+          if (action === 'index') {
+            const target = rawArgs.trim().slice(args[0].length).trim();
+            if (!target) {
+              throw new Error('Usage: /rag index <path>');
+            }
+            dispatch({ type: 'SET_NOTICE', payload: `Indexing ${target}…` });
+            const response = await window.electronAPI.ragIndex(workingDirectory, target.replace(/^\//, '') || '.');
+            if (!response.success || !response.result) {
+              throw new Error(response.error || 'Indexing failed');
+            }
+            const { files, embedded, removed, chunks, skipped } = response.result;
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: [
+                `Indexed ${files} files (${embedded} new or changed, ${removed} removed); ${chunks} chunks in the index`,
+                ...(skipped.length > 0 ? ['', 'Skipped:', ...skipped] : []),
+              ].join('\n'),
+            });
+            return;
+          }
+
+          if (action === 'clear') {
+            const response = await window.electronAPI.ragClear(workingDirectory);
+            if (!response.success) {
+              throw new Error(response.error || 'Failed to delete the index');
+            }
+            dispatch({ type: 'SET_NOTICE', payload: response.removed ? 'Project index deleted' : 'This project has no index' });
+            return;
+          }
+
+          if (action !== 'status') {
+            throw new Error('Usage: /rag [status | index <path> | clear | on | off]');
+          }
+          const response = await window.electronAPI.ragStatus(workingDirectory);
+          if (!response.success || !response.status) {
+            throw new Error(response.error || 'Failed to read the index');
+          }
+          const status = response.status;
+          if (!status.indexed) {
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: 'This project has no index. Build one with /rag index <path> or poe index <path> from a terminal.',
+            });
+            return;
+          }
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: [
+              `Index: ${status.files} files, ${status.chunks} chunks, ${(status.sizeBytes / (1024 * 1024)).toFixed(1)} MB`,
+              `Embeddings: ${status.model} (${status.dimension} dimensions)`,
+              `Updated: ${status.updatedAt ? new Date(status.updatedAt).toLocaleString() : 'unknown'}`,
+              `Retrieval: ${status.enabled ? 'on' : 'off (/rag on to use it)'}`,
+            ].join('\n'),
+          });
+        },
+      },
+      {
+        name: 'observe',
+        usage: '/observe [port|off]',
+        description: 'Let others watch this session read-only in a browser or with poe attach',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action] = args.map(a => a.toLowerCase());
+          if (action === 'off') {
+            const result = await window.electronAPI.observerStop();
+            setObserving(false);
+            dispatch({ type: 'SET_NOTICE', payload: result.stopped ? 'Observers disconnected' : 'This session is not being observed' });
+            return;
+          }
+
+          if (!action) {
+            const status = await window.electronAPI.observerStatus();
+            if (status.running && status.url) {
+              dispatch({
+                type: 'SET_NOTICE',
+                payload: `Observed by ${status.observers} viewer${status.observers === 1 ? '' : 's'} at ${status.url}\nTerminal: poe attach "${status.url}"\nUse /observe off to stop.`,
+              });
+              return;
+            }
+          }
+
+          const port = action ? Number(action) : undefined;
+          if (port !== undefined && (!Number.isInteger(port) || port < 1 || port > 65535)) {
+            throw new Error('Usage: /observe [port|off]');
+          }
+          const result = await window.electronAPI.observerStart(port);
+          if (!result.success || !result.url) {
+            throw new Error(result.error || 'Failed to start the observer server');
+          }
+          setObserving(true);
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: `Observers can watch at ${result.url} (this machine only)\nTerminal: poe attach "${result.url}"\nAnyone with the link can read the session until /observe off.`,
+          });
+        },
+      },
+      {
+        name: 'copy',
+        usage: '/copy [n|last] [code]',
+        description: 'Copy the last response or message #n, or only its code blocks, to the clipboard (also Ctrl/Cmd+Y)',
+        allowWhileLoading: true,
+        run: async (args) => {
+          // "/copy", "/copy code", "/copy 4" or "/copy last code"
+          const ref = args[0] && args[0] !== 'code' ? args[0] : null;
+          const code = args[ref ? 1 : 0];
+          if ((code && code !== 'code') || args.length > (ref ? 2 : 1) || (ref && ref !== 'last' && !/^\d+$/.test(ref))) {
+            throw new Error('Usage: /copy [n|last] [code]');
+          }
+          const number = ref && ref !== 'last' ? parseInt(ref, 10) : null;
+          const response = number
+            ? findMessageByNumber(state.messages, number)
+            : [...state.messages].reverse()
+              .find(m => m.role === 'assistant' && m.content.trim() && m.id !== state.streamingMessageId);
+          if (!response?.content.trim()) {
+            throw new Error(number ? `#${number} has no text to copy` : 'There is no response to copy yet');
+          }
+
+          let text = response.content;
+          let copied = number ? `#${number}` : 'the last response';
+          if (code) {
+            const blocks = extractCodeBlocks(response.content);
+            if (blocks.length === 0) {
+              throw new Error(`${number ? `#${number}` : 'The last response'} has no code blocks`);
+            }
+            text = blocks.join('\n\n');
+            copied = blocks.length === 1 ? '1 code block' : `${blocks.length} code blocks`;
+          }
+          const result = await window.electronAPI.clipboardWriteText(text);
+          if (!result.success) {
+            throw new Error(result.error || 'Failed to copy to the clipboard');
+          }
+          dispatch({ type: 'SET_NOTICE', payload: `Copied ${copied} (${text.length.toLocaleString()} characters)` });
+        },
+      },
+      {
+        name: 'snip',
+        usage: '/snip <n|last> [path]',
+        description: "Save a reply's code blocks to files, named from the fences or the text around them",
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [ref, target] = args;
+          if (!ref || args.length > 2) {
+            throw new Error('Usage: /snip <n|last> [path]');
+          }
+          const message = ref === 'last'
+            ? [...state.messages].reverse().find(m => m.role === 'assistant' && m.content.trim() && m.id !== state.streamingMessageId)
+            : findMessageByNumber(state.messages, parseInt(ref, 10));
+          if (!message || message.role !== 'assistant') {
+            throw new Error(`#${ref} is not an assistant message`);
+          }
+          const blocks = extractFencedBlocks(message.content);
+          if (blocks.length === 0) {
+            throw new Error(`#${getMessageNumber(state.messages, message.id) ?? ref} has no code blocks`);
+          }
+
+          const files = snippetFiles(blocks, target);
+          const written = await writeSnippets(files, workingDirectory || null);
+          if (written) {
+            dispatch({ type: 'SET_NOTICE', payload: describeSavedSnippets(written) });
+          }
+        },
+      },
+      {
+        name: 'apply',
+        usage: '/apply [n|last] [path]',
+        description: "Preview a reply's new version of a file read in this conversation as a diff, and write it once confirmed",
+        run: async (args) => {
+          const [ref = 'last', target] = args;
+          if (args.length > 2) {
+            throw new Error('Usage: /apply [n|last] [path]');
+          }
+          if (!workingDirectory) {
+            throw new Error('Open a project folder before applying changes');
+          }
+          const message = ref === 'last'
+            ? [...state.messages].reverse().find(m => m.role === 'assistant' && m.content.trim() && m.id !== state.streamingMessageId)
+            : findMessageByNumber(state.messages, parseInt(ref, 10));
+          if (!message || message.role !== 'assistant') {
+            throw new Error(`#${ref} is not an assistant message`);
+          }
+          const number = getMessageNumber(state.messages, message.id) ?? ref;
+          const blocks = extractFencedBlocks(message.content);
+          if (blocks.length === 0) {
+            throw new Error(`#${number} has no code blocks`);
+          }
+
+          const edit = proposedEdit(blocks, attachedFiles(state.messages), target);
+          if (!edit) {
+            throw new Error(target
+              ? `No code block in #${number} is for ${target}`
+              : `Couldn't tell which file #${number} changes. Name it: /apply ${ref} <path>`);
+          }
+          const current = await window.electronAPI.applyReadFile(workingDirectory, edit.path);
+          if (!current.success) {
+            throw new Error(current.error || `Failed to read ${edit.path}`);
+          }
+          setPendingApply({
+            path: edit.path,
+            oldContent: current.content ?? '',
+            newContent: edit.content,
+            warnings: applyWarnings(edit, current.content ?? '', !!current.exists),
+            projectPath: workingDirectory,
+          });
+        },
+      },
+      {
+        name: 'toolout',
+        usage: '/toolout [n|last]',
+        description: 'List the tool results that were shortened for the model, or read one in full',
+        allowWhileLoading: true,
+        run: (args) => {
+          if (args.length > 1) {
+            throw new Error('Usage: /toolout [n|last]');
+          }
+          // Shortened results in this conversation, in the order the tools ran
+          const shortened = state.messages
+            .flatMap(m => m.tool_calls || [])
+            .map(tc => getFullOutput(tc.id))
+            .filter((output): output is NonNullable<typeof output> => !!output);
+          const format = (n: number) => n.toLocaleString('en-US');
+
+          const [ref] = args;
+          if (!ref) {
+            if (shortened.length === 0) {
+              dispatch({ type: 'SET_NOTICE', payload: 'No tool results were shortened in this conversation' });
+              return;
+            }
+            const lines = shortened.map((output, i) =>
+              `${i + 1}. ${output.toolName}: ${format(output.originalChars)} characters, ${format(output.keptChars)} sent`);
+            dispatch({ type: 'SET_NOTICE', payload: `Shortened tool results:\n${lines.join('\n')}\nOpen one with /toolout <n>` });
+            return;
+          }
+
+          const index = ref === 'last' ? shortened.length - 1 : parseInt(ref, 10) - 1;
+          if (ref !== 'last' && (!/^\d+$/.test(ref) || index < 0)) {
+            throw new Error('Usage: /toolout [n|last]');
+          }
+          const output = shortened[index];
+          if (!output) {
+            throw new Error(shortened.length === 0
+              ? 'No tool results were shortened in this conversation. Full results are only kept until the app restarts'
+              : `There are ${shortened.length} shortened tool results; pick one from /toolout`);
+          }
+          setToolOutputPage({
+            title: `${output.toolName} (${format(output.originalChars)} characters)`,
+            text: toolOutputText(output.result),
+          });
+        },
+      },
+      {
+        name: 'preview',
+        usage: '/preview [message]',
+        description: 'Show the exact request a message would send to the provider, in the pager, without sending it',
+        allowWhileLoading: true,
+        run: (_args, rawArgs, context) => handlers.handlePreviewMessage(rawArgs, context.systemPrompt),
+      },
+      {
+        name: 'find',
+        usage: '/find [text]',
+        description: 'Highlight text in this conversation; F3 and Shift+F3 move between matches, no text or Esc clears',
+        allowWhileLoading: true,
+        run: (_args, rawArgs) => {
+          setFindQuery(rawArgs || null);
+        },
+      },
+      {
+        name: 'vim',
+        usage: '/vim [on|off]',
+        description: 'Toggle vim keys in the input box: Esc for normal mode, hjkl, dd to clear, i to type again',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action] = args.map(a => a.toLowerCase());
+          if (action && action !== 'on' && action !== 'off') {
+            throw new Error('Usage: /vim [on|off]');
+          }
+          const enabled = action ? action === 'on' : !getVimEnabled();
+          await setVimEnabled(enabled);
+          dispatch({
+            type: 'SET_NOTICE',
+            payload: enabled
+              ? 'Vim mode on: Esc for normal mode (h/l move, j/k scroll, dd clears), i to type'
+              : 'Vim mode off',
+          });
+        },
+      },
+      {
+        name: 'keys',
+        usage: '/keys',
+        description: 'Show the key bindings, reading the keyBindings preference again',
+        allowWhileLoading: true,
+        run: async () => {
+          const problems = await reloadKeyBindings();
+          const bindings = getKeyBindings();
+          const isMac = navigator.platform.toUpperCase().indexOf('MAC') >= 0;
+          const lines = [
+            ...KEY_ACTIONS.map(action => `${action}: ${formatKey(bindings[action], isMac)}`),
+            ...Object.entries(bindings.commands).map(([key, command]) => `${formatKey(key, isMac)}: ${command}`),
+          ];
+          if (problems.length > 0) {
+            lines.push('', 'Ignored in the keyBindings preference:', ...problems);
+          }
+          dispatch({ type: 'SET_NOTICE', payload: `Key bindings:\n${lines.join('\n')}` });
+        },
+      },
+      {
+        name: 'debug',
+        usage: '/debug [on|off|debug|info|warn|error]',
+        description: 'Toggle the debug log pane, or show only entries at a level and above',
+        allowWhileLoading: true,
+        run: (args) => {
+          const [value] = args;
+          if (!value) {
+            setDebugPane({ open: !getDebugPane().open });
+            return;
+          }
+          if (value === 'on' || value === 'off') {
+            setDebugPane({ open: value === 'on' });
+            return;
+          }
+          if (!['debug', 'info', 'warn', 'error'].includes(value)) {
+            throw new Error('Usage: /debug [on|off|debug|info|warn|error]');
+          }
+          setDebugPane({ open: true, level: value as DebugLevel });
+        },
+      },
+      {
+        name: 'events',
+        usage: '/events [on|off|all|connection|retry|cache|hook|rate-limit|model]',
+        description: 'Toggle the pane of engine events (connections, retries, cache hits, hook changes, rate-limit waits), or show one kind',
+        allowWhileLoading: true,
+        run: (args) => {
+          const [value] = args;
+          if (!value) {
+            setEventsPane({ open: !getEventsPane().open });
+            return;
+          }
+          if (value === 'on' || value === 'off') {
+            setEventsPane({ open: value === 'on' });
+            return;
+          }
+          if (value === 'all') {
+            setEventsPane({ open: true, kind: null });
+            return;
+          }
+          if (!ENGINE_EVENT_KINDS.includes(value as EngineEventKind)) {
+            throw new Error('Usage: /events [on|off|all|connection|retry|cache|hook|rate-limit|model]');
+          }
+          setEventsPane({ open: true, kind: value as EngineEventKind });
+        },
+      },
+      {
+        name: 'attach',
+        usage: '/attach [<image path>|clear]',
+        description: 'Attach an image to the next message, or list or clear pending ones',
+        allowWhileLoading: true,
+        run: async (args, rawArgs) => {
+          const target = rawArgs.trim().replace(/^(["'])(.*)\1$/, '$2');
+          if (!target) {
+            const pending = getAttachments();
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: pending.length === 0
+                ? 'No images attached. /attach <path> adds one to the next message.'
+                : `Attached to the next message: ${pending.map(a => a.name).join(', ')}`,
+            });
+            return;
+          }
+          if (args[0] === 'clear' && args.length === 1) {
+            clearAttachments();
+            dispatch({ type: 'SET_NOTICE', payload: 'Cleared attached images' });
+            return;
+          }
+
+          const result = await window.electronAPI.imageReadFile(workingDirectory || null, target);
+          if (!result.success || !result.image) {
+            throw new Error(result.error || `Failed to read ${target}`);
+          }
+          const name = result.image.path?.split(/[\\/]/).pop() || target;
+          addAttachment({ ...result.image, name });
+          dispatch({ type: 'SET_NOTICE', payload: `Attached ${name} to the next message` });
+        },
+      },
+      {
+        name: 'prompt',
+        usage: '/prompt [--send] [<template> [args]]',
+        description: 'Fill the input box from a message template, or send it with --send; lists templates without a name',
+        run: async (args, rawArgs, context) => {
+          const send = args[0] === '--send';
+          const [name, ...templateArgs] = send ? args.slice(1) : args;
+          if (!name) {
+            const result = await window.electronAPI.templatesList();
+            if (!result.success) {
+              throw new Error(result.error || 'Failed to list templates');
+            }
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: result.templates.length === 0
+                ? `No templates yet. Add Markdown files to ${result.directory} and use them with /prompt <name>.`
+                : ['Templates:', ...result.templates.map(t => `  ${t.name}${t.description ? ` - ${t.description}` : ''}`)].join('\n'),
+            });
+            return;
+          }
+
+          const result = await window.electronAPI.templatesRead(name);
+          if (!result.success || !result.template) {
+            throw new Error(result.error || `Failed to read template ${name}`);
+          }
+          const { body } = result.template;
+
+          // Everything after the name, as typed, for {{args}}
+          const afterName = commandArgsAfter(rawArgs, send ? 2 : 1);
+          const values = { ...templateArgumentValues(templateArgs, afterName), project: workingDirectory || '' };
+          const used = templateVariables(body);
+          if (used.includes('selection')) {
+            values.selection = getLastSelection();
+          }
+          if (used.includes('clipboard')) {
+            const clipboard = await window.electronAPI.clipboardReadText();
+            if (!clipboard.success) {
+              throw new Error(clipboard.error || 'Failed to read the clipboard');
+            }
+            values.clipboard = clipboard.text ?? '';
+          }
+
+          const text = expandPromptTemplate(body, values);
+          if (!text) {
+            throw new Error(`Template ${name} is empty`);
+          }
+          if (send || result.template.send) {
+            await handlers.handleSendMessage(text, context.systemPrompt);
+          } else {
+            setInputDraft(text);
+          }
+        },
+      },
+      {
+        name: 'sessions',
+        usage: '/sessions',
+        description: 'List saved sessions for this project',
+        allowWhileLoading: true,
+        run: async () => {
+          if (!workingDirectory) {
+            throw new Error('No project is open');
+          }
+          const result = await window.electronAPI.sessionList(workingDirectory);
+          if (!result.success) {
+            throw new Error(result.error || 'Failed to list sessions');
+          }
+          if (result.sessions.length === 0) {
+            dispatch({ type: 'SET_NOTICE', payload: 'No saved sessions yet' });
+            return;
+          }
+
+          const lines = result.sessions.map(session => {
+            const marker = session.id === state.currentSessionId ? '*' : ' ';
+            const name = session.isCustomName && session.name ? session.name : '(unnamed)';
+            const modified = new Date(session.lastModified).toLocaleString();
+            return `${marker} ${session.id.substring(0, 8)}  ${name.padEnd(24)} ${String(session.messageCount).padStart(4)} msgs  ${modified}`;
+          });
+          dispatch({ type: 'SET_NOTICE', payload: ['Sessions:', ...lines, '', 'Open one with /load <name|id>'].join('\n') });
+        },
+      },
+      {
+        name: 'title',
+        usage: '/title [on|off]',
+        description: 'Name this session after its first exchange now, or turn naming new sessions automatically on or off',
+        allowWhileLoading: true,
+        run: async (args) => {
+          const [action] = args.map(a => a.toLowerCase());
+          const settings = await readSessionTitleSettings();
+          if (action === 'on' || action === 'off') {
+            await window.electronAPI.preferencesSet('sessionTitles', { ...settings, enabled: action === 'on' });
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: action === 'on'
+                ? 'New sessions are named after their first exchange'
+                : 'New sessions keep their default names',
+            });
+            return;
+          } else if (action) {
+            throw new Error('Usage: /title [on|off]');
+          }
+
+          const selection = titleModel(settings, state.providers, state.currentProvider, state.currentModel, state.offlineMode);
+          if (!selection) {
+            throw new Error(settings.model ? `Title model "${settings.model}" is not available` : 'Please select a provider and model');
+          }
+          const title = await generateSessionTitle(state.messages, selection);
+          dispatch({ type: 'SET_SESSION_NAME', payload: { name: title, isCustom: true } });
+          dispatch({ type: 'SET_NOTICE', payload: `Named this session "${title}"` });
+        },
+      },
+      {
+        name: 'recap',
+        usage: '/recap [on|off]',
+        description: 'Recap where this session stands now, or turn recaps of reopened sessions on or off',
+        run: async (args) => {
+          const [action] = args.map(a => a.toLowerCase());
+          const settings = await readSessionRecapSettings();
+          if (action === 'on' || action === 'off') {
+            await window.electronAPI.preferencesSet('sessionRecap', { ...settings, enabled: action === 'on' });
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: action === 'on'
+                ? 'Reopened sessions start with a recap of where they left off'
+                : 'Reopened sessions no longer start with a recap',
+            });
+            return;
+          } else if (action) {
+            throw new Error('Usage: /recap [on|off]');
+          }
+
+          if (recapMessages(state.messages).length === 0) {
+            throw new Error('There is nothing to recap yet');
+          }
+          const selection = titleModel(settings, state.providers, state.currentProvider, state.currentModel, state.offlineMode);
+          if (!selection) {
+            throw new Error(settings.model ? `Recap model "${settings.model}" is not available` : 'Please select a provider and model');
+          }
+          const sessionId = state.currentSessionId;
+          setSessionRecap(sessionId, await generateSessionRecap(state.messages, selection));
+        },
+      },
+      {
+        name: 'load',
+        usage: '/load <name|id>',
+        description: 'Switch to a saved session',
+        run: async (_args, rawArgs) => {
+          if (!workingDirectory) {
+            throw new Error('No project is open');
+          }
+          if (!rawArgs) {
+            throw new Error('Usage: /load <name|id>');
+          }
+          const result = await window.electronAPI.sessionList(workingDirectory);
+          if (!result.success) {
+            throw new Error(result.error || 'Failed to list sessions');
+          }
+          const session = findSessionByRef(result.sessions, rawArgs);
+          if (!session) {
+            throw new Error(`No session matches "${rawArgs}". Use /sessions to list them.`);
+          }
+
+          await handlers.handleLoadSession(session.id);
+          dispatch({ type: 'SET_NOTICE', payload: `Loaded session ${session.isCustomName ? session.name : session.id.substring(0, 8)}` });
+        },
+      },
+      {
+        name: 'merge',
+        usage: '/merge <name|id> [range]',
+        description: 'Copy messages (e.g. 3-7, 5, 4-) from another session into this one',
+        run: async (args, rawArgs) => {
+          if (!workingDirectory) {
+            throw new Error('No project is open');
+          }
+          // The range is optional and comes last; session names may contain spaces
+          const range = args.length > 1 ? parseMessageRange(args[args.length - 1]) : null;
+          const sessionRef = range ? rawArgs.substring(0, rawArgs.lastIndexOf(args[args.length - 1])).trim() : rawArgs;
+          if (!sessionRef) {
+            throw new Error('Usage: /merge <name|id> [range]');
+          }
+
+          const list = await window.electronAPI.sessionList(workingDirectory);
+          if (!list.success) {
+            throw new Error(list.error || 'Failed to list sessions');
+          }
+          const session = findSessionByRef(list.sessions, sessionRef);
+          if (!session) {
+            throw new Error(`No session matches "${sessionRef}". Use /sessions to list them.`);
+          }
+          const loaded = await window.electronAPI.sessionLoad(workingDirectory, session.id);
+          if (!loaded.success || !loaded.messages) {
+            throw new Error(loaded.error || 'Failed to load session');
+          }
+
+          const sessionName = session.isCustomName && session.name ? session.name : session.id.substring(0, 8);
+          const selected = selectMessageRange(loaded.messages as ChatMessage[], range?.from ?? 1, range?.to ?? Infinity);
+          if (selected.length === 0) {
+            throw new Error(`Session ${sessionName} has no messages in that range`);
+          }
+
+          const merged = copyMessagesForMerge(selected, { sessionId: session.id, sessionName });
+          for (const message of merged) {
+            dispatch({ type: 'ADD_MESSAGE', payload: message });
+          }
+          const count = selected.filter(m => m.role !== 'tool').length;
+          dispatch({ type: 'SET_NOTICE', payload: `Merged ${count} message${count === 1 ? '' : 's'} from ${sessionName}` });
+        },
+      },
+      {
+        name: 'save',
+        usage: '/save [name]',
+        description: 'Save the session now, optionally under a new name',
+        allowWhileLoading: true,
+        run: async (_args, rawArgs) => {
+          if (!workingDirectory || !state.currentSessionId) {
+            throw new Error('No session to save');
+          }
+          const name = rawArgs || state.currentSessionName;
+          const isCustom = rawArgs ? true : state.isCustomName;
+
+          const result = await window.electronAPI.sessionSave(
+            workingDirectory,
+            state.currentSessionId,
+            state.messages,
+            name,
+            isCustom,
+            state.currentProvider?.id,
+            state.currentModel?.id,
+            {
+              env: state.sessionEnv,
+              ...(state.sessionSystemPrompt !== null && { systemPrompt: state.sessionSystemPrompt }),
+              ...(Object.keys(state.generationOptions).length > 0 && { options: state.generationOptions }),
+            }
+          );
+          if (!result.success) {
+            throw new Error(result.error || 'Failed to save session');
+          }
+          if (rawArgs) {
+            dispatch({ type: 'SET_SESSION_NAME', payload: { name, isCustom: true } });
+          }
+          dispatch({ type: 'SET_NOTICE', payload: `Saved session ${name}` });
+        },
+      },
+      {
+        name: 'rate',
+        usage: '/rate <n|last> <good|bad|clear> [note]',
+        description: 'Rate an assistant message for later review (/export eval)',
+        allowWhileLoading: true,
+        run: (args) => {
+          const [ref, value, ...noteParts] = args;
+          if (!ref || !value || !(value.toLowerCase() in RATING_VALUES)) {
+            throw new Error('Usage: /rate <n|last> <good|bad|clear> [note]');
+          }
+
+          const message = ref === 'last'
+            ? [...state.messages].reverse().find(m => m.role === 'assistant' && m.content)
+            : findMessageByNumber(state.messages, parseInt(ref, 10));
+          if (!message || message.role !== 'assistant') {
+            throw new Error(`#${ref} is not an assistant message`);
+          }
+
+          const rating = RATING_VALUES[value.toLowerCase()];
+          const note = noteParts.join(' ').trim();
+          dispatch({
+            type: 'UPDATE_MESSAGE',
+            payload: {
+              id: message.id,
+              updates: {
+                rating: rating ? { value: rating, ratedAt: Date.now(), ...(note && { note }) } : undefined,
+              },
+            },
+          });
+        },
+      },
+      {
+        name: 'pin',
+        usage: '/pin [n|last]',
+        description: 'Pin or unpin a message so it stays in the context when older turns are dropped or summarized; list the pinned ones',
+        allowWhileLoading: true,
+        run: (args) => {
+          if (args.length > 1) {
+            throw new Error('Usage: /pin [n|last]');
+          }
+          const [ref] = args;
+          if (!ref) {
+            const pinned = state.messages.filter(m => m.pinned).map(m => `#${getMessageNumber(state.messages, m.id)}`);
+            dispatch({
+              type: 'SET_NOTICE',
+              payload: pinned.length > 0 ? `Pinned: ${pinned.join(', ')}` : 'No messages are pinned. Pin one with /pin <n>',
+            });
+            return;
+          }
+
+          const message = ref === 'last'
+            ? [...state.messages].reverse().find(m => m.role === 'user' || m.role === 'assistant')
+            : findMessageByNumber(state.messages, parseInt(ref, 10));
+          if (!message) {
+            throw new Error(`There is no message #${ref}`);
+          }
+          const number = getMessageNumber(state.messages, message.id);
+          dispatch({ type: 'UPDATE_MESSAGE', payload: { id: message.id, updates: { pinned: !message.pinned || undefined } } });
+          dispatch({ type: 'SET_NOTICE', payload: message.pinned ? `Unpinned #${number}` : `Pinned #${number}` });
+        },
+      },
+      {
+        name: 'inspect',
+        usage: '/inspect <n|last>',
+        description: 'Show everything stored for a message (ids, model, stats, tool calls and their results) in the pager',
+        allowWhileLoading: true,
+        run: (args) => {
+          const [ref] = args;
+          if (!ref || args.length > 1) {
+            throw new Error('Usage: /inspect <n|last>');
+          }
+          const message = ref === 'last'
+            ? [...state.messages].reverse().find(m => m.role === 'user' || m.role === 'assistant')
+            : findMessageByNumber(state.messages, parseInt(ref, 10));
+          if (!message) {
+            throw new Error(`There is no message #${ref}`);
+          }
+          const toolCallIds = new Set((message.tool_calls || []).map(tc => tc.id));
+          const results = state.messages.filter(m => m.role === 'tool' && m.tool_call_id && toolCallIds.has(m.tool_call_id));
+          setToolOutputPage({
+            title: `Message #${getMessageNumber(state.messages, message.id)} (${message.role})`,
+            text: JSON.stringify(results.length > 0 ? { ...message, toolResults: results } : message, null, 2),
+          });
+        },
+      },
+      {
+        name: 'export',
+        usage: `/export [${EXPORT_FORMATS.join('|')}] [path]`,
+        description: 'Save the conversation to a file; the format follows the extension',
+        allowWhileLoading: true,
+        run: async (args, rawArgs, context) => {
+          // "/export markdown notes/chat.md", "/export chat.json" or "/export" to pick a file
+          const [first = '', second = ''] = args;
+          let named = EXPORT_FORMATS.find(f => f === first.toLowerCase() || (f === 'markdown' && first.toLowerCase() === 'md'));
+          let rest = named ? rawArgs.trim().slice(first.length) : rawArgs;
+          // "/export finetune [ollama|openai]": a Modelfile when chatting with Ollama, OpenAI JSONL otherwise
+          if (named === 'finetune') {
+            const flavor = second.toLowerCase();
+            if (flavor === 'ollama' || flavor === 'openai') {
+              rest = rest.trim().slice(second.length);
+            }
+            const ollama = flavor === 'ollama' || (flavor !== 'openai' && state.currentProvider?.type === 'ollama');
+            named = ollama ? 'modelfile' : 'finetune';
+          }
+          const targetPath = rest.trim() || undefined;
+          const format: ExportFormat | null = named ?? (targetPath ? formatForPath(targetPath) : 'html');
+          if (!format) {
+            throw new Error(`Unknown export format "${first}". Use one of: ${EXPORT_FORMATS.join(', ')}, or a path ending in .html, .md, .json, .jsonl or .Modelfile`);
+          }
+          if (state.messages.length === 0) {
+            throw new Error('Nothing to export yet');
+          }
+
+          const redacted = await redactTranscript(state.messages, {
+            providerId: state.currentProvider?.id,
+            modelId: state.currentModel?.id,
+            promptName: state.activePromptName,
+          }, { systemPrompt: state.sessionSystemPrompt ?? context.systemPrompt });
+          const filePath = await exportTranscript(redacted.messages, format, {
+            title: state.currentSessionName || 'POE transcript',
+            providerId: state.currentProvider?.id,
+            modelId: state.currentModel?.id,
+            workingDirectory,
+            exportedAt: Date.now(),
+            systemPrompt: redacted.systemPrompt,
+          }, targetPath);
+          if (filePath) {
+            dispatch({ type: 'SET_NOTICE', payload: `Exported conversation to ${filePath}${redacted.report ? `\n${redacted.report}` : ''}` });
+          }
+        },
+      },
+    ];
+
+    list.push({
+      name: 'help',
+      usage: '/help',
+      description: 'List available commands',
+      allowWhileLoading: true,
+      run: () => {
+        const lines = list.map(c => `${c.usage.padEnd(28)} ${c.description}`);
+        dispatch({ type: 'SET_NOTICE', payload: ['Commands:', ...lines, '', 'Start a message with // to send a literal /'].join('\n') });
+      },
+    });
+
+    return list;
+  }, [state.offlineMode, state.showStats, state.thinking, state.sessionEnv, state.sessionSystemPrompt, state.generationOptions, state.streamingMessageId, state.activePromptName, state.messages, state.contextUsage, state.currentSessionId, state.currentSessionName, state.isCustomName, state.currentProvider, state.currentModel, workingDirectory, dispatch, handlers]);
+
+  /**
+   * Run the input as a slash command. Returns false if the input is a regular message.
+   */
+  const runCommand = useCallback(async (input: string, systemPrompt?: string): Promise<boolean> => {
+    const parsed = parseSlashCommand(input);
+    if (!parsed) {
+      return false;
+    }
+
+    const command = commands.find(c => c.name === parsed.name);
+    if (!command) {
+      dispatch({ type: 'SET_ERROR', payload: `Unknown command /${parsed.name}. Type /help for a list of commands.` });
+      return true;
+    }
+
+    if (state.isLoading && !command.allowWhileLoading) {
+      dispatch({ type: 'SET_ERROR', payload: `/${command.name} can't run while a response is streaming` });
+      return true;
+    }
+
+    try {
+      await command.run(parsed.args, parsed.rawArgs, { systemPrompt });
+    } catch (error) {
+      console.error(`Command /${command.name} failed:`, error);
+      dispatch({
+        type: 'SET_ERROR',
+        payload: error instanceof Error ? error.message : `Command /${command.name} failed`,
+      });
+    }
+    return true;
+  }, [commands, state.isLoading, dispatch]);
+
+  return {
+    commands,
+    runCommand,
+  };
+};
@@ -1,8 +1,16 @@
 import { useState, useCallback, useRef, useEffect } from 'react';
-import type { ChatMessage, ToolCall } from '../types/chat';
+import type { ChatMessage, MessageImage, ToolCall, ToolResultView } from '../types/chat';
 import type { ChatState, ChatAction } from '../context/ChatContext';
 import { toolRegistry } from '../tools';
 import { generatePreviewData } from '../utils/previewDataGenerator';
+import { extractToolImages } from '../utils/toolImages';
+import { extractResultView } from '../utils/toolResultView';
+import { toolStats, resultError } from '../tools/toolStats';
+import { cancelUserInput, isWaitingForUser, subscribeUserInput } from '../tools/userInput';
+import { hookRegistry, hookConfigManager } from '../pipeline';
+import { toolConfigManager } from '../tools/ToolConfigManager';
+import { DEFAULT_TOOL_RESULT_CHARS, keepFullOutput, limitToolResult } from '../tools/toolOutputLimit';
+import { recordEngineEvent } from '../utils/engineEvents';
 
 interface PendingPermission {
   onAllow: () => void;
@@ -10,6 +18,160 @@ interface PendingPermission {
   previewData?: any;
 }
 
+// Thrown into a running tool call when the user cancels it
+class ToolCancelledError extends Error {}
+
+// Rejects the tool calls that are still running, by toolCallId
+const runningToolCalls = new Map<string, (error: Error) => void>();
+
+/**
+ * Stop every running tool call: each one fails with "Cancelled by user", and bash
+ * commands and MCP requests are stopped in the main process
+ */
+export function cancelRunningToolCalls(): void {
+  const running = Array.from(runningToolCalls.entries());
+  runningToolCalls.clear();
+  for (const [toolCallId, fail] of running) {
+    cancelUserInput(toolCallId);
+    fail(new ToolCancelledError('Cancelled by user'));
+    window.electronAPI.toolCancel(toolCallId).catch(() => undefined);
+  }
+}
+
+/**
+ * Run the tool, failing if it takes longer than its timeout or is cancelled, so a
+ * hung tool can't hold up the conversation. The clock stops while the tool waits
+ * for the user to answer a question, and restarts once they have.
+ */
+async function executeWithTimeout(
+  toolName: string,
+  args: Record<string, unknown>,
+  workingDirectory: string,
+  toolCallId: string
+): Promise<unknown> {
+  const timeoutMs = toolRegistry.getTimeoutMs(toolName, args);
+  let timer: ReturnType<typeof setTimeout> | undefined;
+  let unsubscribe: (() => void) | undefined;
+  const interrupted = new Promise<never>((_, reject) => {
+    runningToolCalls.set(toolCallId, reject);
+    const startTimer = () => {
+      timer = setTimeout(() => {
+        runningToolCalls.delete(toolCallId);
+        window.electronAPI.toolCancel(toolCallId).catch(() => undefined);
+        reject(new Error(`Tool ${toolName} timed out after ${Math.round(timeoutMs / 1000)}s`));
+      }, timeoutMs);
+    };
+    startTimer();
+
+    let waiting = false;
+    unsubscribe = subscribeUserInput(() => {
+      const nowWaiting = isWaitingForUser(toolCallId);
+      if (nowWaiting !== waiting) {
+        waiting = nowWaiting;
+        clearTimeout(timer);
+        if (!waiting) {
+          startTimer();
+        }
+      }
+    });
+  });
+
+  try {
+    return await Promise.race([
+      toolRegistry.execute(toolName, args, workingDirectory, toolCallId),
+      interrupted,
+    ]);
+  } finally {
+    clearTimeout(timer);
+    unsubscribe?.();
+    runningToolCalls.delete(toolCallId);
+    cancelUserInput(toolCallId);
+  }
+}
+
+interface ToolCallOutcome {
+  result: unknown;
+  images: MessageImage[];
+  view?: ToolResultView;
+}
+
+/**
+ * Run the configured pre-tool-call hooks. Done before the preview and the
+ * permission prompt, so the user is asked about the call that would actually
+ * run and never about one a hook refuses. Throws when a hook denies the call.
+ */
+async function runPreToolCallHooks(
+  toolCall: ToolCall,
+  args: Record<string, unknown>,
+  workingDirectory: string
+): Promise<Record<string, unknown>> {
+  await hookConfigManager.loadConfig();
+  const toolName = toolCall.function.name;
+  const context = { toolName, toolCallId: toolCall.id, projectPath: workingDirectory };
+
+  const pre = await hookRegistry.runPreToolCall(hookConfigManager.getToolCallHooks('preToolCall', toolName), args, context);
+  if (pre.denied) {
+    recordEngineEvent('hook', 'hooks', 'Tool call blocked', { tool: toolName, reason: pre.denied });
+    throw new Error(pre.denied);
+  }
+  if (pre.args !== args && JSON.stringify(pre.args) !== JSON.stringify(args)) {
+    recordEngineEvent('hook', 'hooks', 'Hooks changed the tool call arguments', { tool: toolName });
+  }
+  return pre.args;
+}
+
+/**
+ * Execute a tool call, already passed through the pre-tool-call hooks, and its
+ * post-tool-call hooks. Returns the result to feed back to the model, with any
+ * images and rich view split off.
+ */
+async function runToolCall(
+  toolCall: ToolCall,
+  args: Record<string, unknown>,
+  workingDirectory: string
+): Promise<ToolCallOutcome> {
+  const toolName = toolCall.function.name;
+  const context = { toolName, toolCallId: toolCall.id, projectPath: workingDirectory };
+
+  const startedAt = performance.now();
+  let executed: unknown;
+  try {
+    executed = await executeWithTimeout(toolName, args, workingDirectory, toolCall.id);
+  } catch (error) {
+    toolStats.record(toolName, performance.now() - startedAt, error instanceof Error ? error.message : 'Unknown error');
+    throw error;
+  }
+  toolStats.record(toolName, performance.now() - startedAt, resultError(executed));
+
+  const { result: output, images } = await extractToolImages(executed);
+  const { result, view } = extractResultView(output);
+  const postSpecs = hookConfigManager.getToolCallHooks('postToolCall', toolName);
+  const processed = postSpecs.length === 0
+    ? result
+    : await hookRegistry.runPostToolCall(postSpecs, result, { ...context, args });
+  if (processed !== result && JSON.stringify(processed) !== JSON.stringify(result)) {
+    recordEngineEvent('hook', 'hooks', 'Hooks changed the tool result', { tool: toolName, hooks: postSpecs });
+  }
+
+  const limit = await maxResultChars(toolName);
+  const limited = limitToolResult(processed, limit);
+  if (limited.truncated) {
+    console.log(`Tool result of ${toolName} shortened from ${limited.originalChars} to ${limited.keptChars} characters`);
+    keepFullOutput({ toolCallId: toolCall.id, toolName, result: processed, originalChars: limited.originalChars, keptChars: limited.keptChars });
+  }
+  return { result: limited.result, images, view };
+}
+
+// The tool's own maxResultChars, or the toolResultLimit preference
+async function maxResultChars(toolName: string): Promise<number> {
+  const configured = toolConfigManager.getConfig(toolName).maxResultChars;
+  if (typeof configured === 'number') {
+    return configured;
+  }
+  const preference = await window.electronAPI.preferencesGet('toolResultLimit');
+  return preference.success && typeof preference.value === 'number' ? preference.value : DEFAULT_TOOL_RESULT_CHARS;
+}
+
 export const useToolExecution = (
   state: ChatState,
   dispatch: React.Dispatch<ChatAction>,
@@ -26,6 +188,8 @@ export const useToolExecution = (
   const toolResultsAddedRef = useRef<Set<string>>(new Set());
   const toolResultMessagesRef = useRef<Map<string, ChatMessage>>(new Map());
   const addedToolCallIdsRef = useRef<Set<string>>(new Set());
+  // Tool calls the user cancelled; the conversation stops instead of continuing
+  const cancelledToolCallIdsRef = useRef<Set<string>>(new Set());
   const restoredPermissionsRef = useRef<Set<string>>(new Set());
 
   // Clear refs for new message
@@ -35,6 +199,7 @@ export const useToolExecution = (
     toolCallsInCurrentMessageRef.current = [];
     toolResultsAddedRef.current.clear();
     toolResultMessagesRef.current.clear();
+    cancelledToolCallIdsRef.current.clear();
   }, []);
 
   // Create permission handlers for a tool call
@@ -67,7 +232,7 @@ export const useToolExecution = (
         restoredPermissionsRef.current.add(toolCall.id);
 
         try {
-          const toolResult = await toolRegistry.execute(toolCall.function.name, args, workingDirectory);
+          const { result: toolResult, images, view } = await runToolCall(toolCall, args, workingDirectory);
 
           const toolResultMessage: ChatMessage = {
             id: `tool-result-${Date.now()}-${Math.random()}`,
@@ -75,6 +240,8 @@ export const useToolExecution = (
             content: JSON.stringify(toolResult),
             tool_call_id: toolCall.id,
             timestamp: Date.now(),
+            ...(images.length > 0 && { images }),
+            ...(view && { resultView: view }),
           };
           dispatch({ type: 'ADD_MESSAGE', payload: toolResultMessage });
 
@@ -99,6 +266,9 @@ export const useToolExecution = (
         } catch (error) {
           executingToolCallsRef.current.delete(toolCall.id);
           console.error('Tool execution failed:', error);
+          if (error instanceof ToolCancelledError) {
+            cancelledToolCallIdsRef.current.add(toolCall.id);
+          }
           const errorMessage: ChatMessage = {
             id: `tool-error-${Date.now()}-${Math.random()}`,
             role: 'tool',
@@ -117,7 +287,7 @@ export const useToolExecution = (
             toolResultsAddedRef.current.has(id)
           ).length;
 
-          if (resultsInRef === allToolCallIds.length) {
+          if (resultsInRef === allToolCallIds.length && !allToolCallIds.some(id => cancelledToolCallIdsRef.current.has(id))) {
             console.log('All tool calls have results (including errors), continuing conversation...');
             setTimeout(() => {
               handleContinue();
@@ -165,17 +335,18 @@ export const useToolExecution = (
     executedToolCallsRef.current.add(toolCall.id);
 
     try {
-      const args = typeof toolCall.function.arguments === 'string'
+      const parsedArgs = typeof toolCall.function.arguments === 'string'
         ? JSON.parse(toolCall.function.arguments)
         : toolCall.function.arguments;
+      const args = await runPreToolCallHooks(toolCall, parsedArgs, workingDirectory);
 
       // Generate preview data
       const previewData = await generatePreviewData(toolCall.function.name, args, workingDirectory);
 
       // Handle permissions
-      let result;
+      let execution: ToolCallOutcome;
       if (toolRegistry.requiresPermission(toolCall.function.name)) {
-        result = await new Promise((resolve, reject) => {
+        execution = await new Promise((resolve, reject) => {
           setPendingPermissions(prev => {
             const next = new Map(prev);
             next.set(toolCall.id, {
@@ -200,8 +371,7 @@ export const useToolExecution = (
                 });
 
                 try {
-                  const toolResult = await toolRegistry.execute(toolCall.function.name, args, workingDirectory);
-                  resolve(toolResult);
+                  resolve(await runToolCall(toolCall, args, workingDirectory));
                 } catch (error) {
                   reject(error);
                 } finally {
@@ -226,17 +396,20 @@ export const useToolExecution = (
           });
         });
       } else {
-        result = await toolRegistry.execute(toolCall.function.name, args, workingDirectory);
+        execution = await runToolCall(toolCall, args, workingDirectory);
       }
 
-      console.log('Immediate tool result:', result);
+      console.log('Immediate tool result:', execution.result);
+      const { result: modelResult, images, view } = execution;
 
       const toolResultMessage: ChatMessage = {
         id: `tool-result-${Date.now()}-${Math.random()}`,
         role: 'tool',
-        content: JSON.stringify(result),
+        content: JSON.stringify(modelResult),
         tool_call_id: toolCall.id,
         timestamp: Date.now(),
+        ...(images.length > 0 && { images }),
+        ...(view && { resultView: view }),
       };
 
       dispatch({ type: 'ADD_MESSAGE', payload: toolResultMessage });
@@ -245,6 +418,9 @@ export const useToolExecution = (
 
     } catch (error) {
       console.error('Immediate tool execution failed:', error);
+      if (error instanceof ToolCancelledError) {
+        cancelledToolCallIdsRef.current.add(toolCall.id);
+      }
       const errorMessage: ChatMessage = {
         id: `tool-error-${Date.now()}-${Math.random()}`,
         role: 'tool',
@@ -290,7 +466,25 @@ export const useToolExecution = (
       const newPendingPermissions = new Map(pendingPermissions);
       for (const { toolCall } of toolCallsToRestore) {
         try {
-          const args = JSON.parse(toolCall.function.arguments);
+          const parsedArgs = JSON.parse(toolCall.function.arguments);
+          let args: Record<string, unknown>;
+          try {
+            args = await runPreToolCallHooks(toolCall, parsedArgs, workingDirectory);
+          } catch (error) {
+            // Refused by a hook: answer the call instead of asking about it
+            restoredPermissionsRef.current.add(toolCall.id);
+            dispatch({
+              type: 'ADD_MESSAGE',
+              payload: {
+                id: `tool-error-${Date.now()}-${Math.random()}`,
+                role: 'tool',
+                content: JSON.stringify({ error: error instanceof Error ? error.message : 'Unknown error' }),
+                tool_call_id: toolCall.id,
+                timestamp: Date.now(),
+              },
+            });
+            continue;
+          }
 
           const messageWithToolCall = state.messages.find(m =>
             m.tool_calls?.some(tc => tc.id === toolCall.id)
@@ -329,5 +523,6 @@ export const useToolExecution = (
     toolResultsAddedRef,
     toolResultMessagesRef,
     addedToolCallIdsRef,
+    cancelledToolCallIdsRef,
   };
 };
@@ -0,0 +1,30 @@
+import { useEffect, useState } from 'react';
+
+// The full tool output /toolout opens in the pager
+
+export interface ToolOutputPage {
+  title: string;
+  text: string;
+}
+
+let page: ToolOutputPage | null = null;
+const listeners = new Set<() => void>();
+
+export function setToolOutputPage(next: ToolOutputPage | null) {
+  page = next;
+  listeners.forEach(listener => listener());
+}
+
+export const useToolOutputPage = () => {
+  const [state, setState] = useState(page);
+
+  useEffect(() => {
+    const listener = () => setState(page);
+    listeners.add(listener);
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
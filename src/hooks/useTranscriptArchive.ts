@@ -0,0 +1,128 @@
+import { useEffect, useRef } from 'react';
+import type { ChatState } from '../context/ChatContext';
+import type { ChatMessage } from '../types/chat';
+import { redactToolArgsJson } from '../tools/redaction';
+import { createRedactionCache, redactTranscript } from '../utils/transcriptRedaction';
+
+// Feeds finalized messages and tool permission decisions to the transcript
+// sinks in the main process (transcript-sinks.yaml). With no sinks configured
+// the main process drops the records. Messages go through the export hooks
+// first, like /export, so sinks don't keep a pasted secret.
+
+const signature = (message: ChatMessage) =>
+  `${message.content}\u0000${JSON.stringify(message.tool_calls || [])}`;
+
+const toRecordMessage = (message: ChatMessage, state: ChatState) => ({
+  id: message.id,
+  role: message.role,
+  content: message.content,
+  ...(message.tool_calls && {
+    tool_calls: message.tool_calls.map(tc => ({
+      ...tc,
+      function: { ...tc.function, arguments: redactToolArgsJson(tc.function.name, tc.function.arguments) },
+    })),
+  }),
+  ...(message.tool_call_id && { tool_call_id: message.tool_call_id }),
+  timestamp: message.timestamp,
+  providerId: message.modelOverride?.providerId ?? state.currentProvider?.id,
+  modelId: message.modelOverride?.modelId ?? state.currentModel?.id,
+});
+
+export const useTranscriptArchive = (
+  state: ChatState,
+  workingDirectory: string,
+  toolCallStatuses: Map<string, 'denied' | 'allowed'>
+) => {
+  // Messages that existed before this view opened were archived when they were created
+  const mountedAtRef = useRef(Date.now());
+  const seenRef = useRef<Map<string, string>>(new Map());
+  const sessionRef = useRef(state.currentSessionId);
+  const decisionsRef = useRef<Set<string>>(new Set());
+  const redactionCacheRef = useRef(createRedactionCache());
+  // Records are redacted and sent one after another, so they stay in order
+  const queueRef = useRef<Promise<void>>(Promise.resolve());
+
+  useEffect(() => {
+    const sessionId = state.currentSessionId;
+    const send = (record: Record<string, unknown> | (() => Promise<Record<string, unknown>>)) => {
+      queueRef.current = queueRef.current
+        .then(() => (typeof record === 'function' ? record() : record))
+        .then(resolved => window.electronAPI.transcriptRecord({ projectPath: workingDirectory, sessionId, ...resolved }))
+        .then(() => undefined)
+        .catch(error => console.error('Failed to archive transcript record:', error));
+    };
+    const messageRecord = async (kind: 'message' | 'message_edited', message: ChatMessage) => {
+      const { messages: [redacted] } = await redactTranscript([message], {
+        providerId: message.modelOverride?.providerId ?? state.currentProvider?.id,
+        modelId: message.modelOverride?.modelId ?? state.currentModel?.id,
+        promptName: state.activePromptName,
+      }, { cache: redactionCacheRef.current });
+      return { kind, message: toRecordMessage(redacted, state) };
+    };
+
+    if (sessionRef.current !== state.currentSessionId) {
+      sessionRef.current = state.currentSessionId;
+      seenRef.current = new Map();
+      redactionCacheRef.current = createRedactionCache();
+    }
+    const seen = seenRef.current;
+
+    // Clearing the conversation is one record, not a deletion per message
+    if (state.messages.length === 0 && seen.size > 0) {
+      seen.clear();
+      send({ kind: 'cleared' });
+      return;
+    }
+
+    const present = new Set<string>();
+    for (const message of state.messages) {
+      present.add(message.id);
+      if (message.id === state.streamingMessageId) {
+        continue;
+      }
+
+      const sig = signature(message);
+      const previous = seen.get(message.id);
+      if (previous === undefined) {
+        seen.set(message.id, sig);
+        if (message.timestamp >= mountedAtRef.current) {
+          send(() => messageRecord('message', message));
+        }
+      } else if (previous !== sig) {
+        seen.set(message.id, sig);
+        send(() => messageRecord('message_edited', message));
+      }
+    }
+
+    for (const id of Array.from(seen.keys())) {
+      if (!present.has(id)) {
+        seen.delete(id);
+        send({ kind: 'message_deleted', messageId: id });
+      }
+    }
+  }, [state.messages, state.streamingMessageId, state.currentSessionId, state.activePromptName, workingDirectory]);
+
+  useEffect(() => {
+    for (const [toolCallId, decision] of toolCallStatuses) {
+      if (decisionsRef.current.has(toolCallId)) {
+        continue;
+      }
+      decisionsRef.current.add(toolCallId);
+
+      const toolCall = state.messages
+        .flatMap(m => m.tool_calls || [])
+        .find(tc => tc.id === toolCallId);
+      window.electronAPI.transcriptRecord({
+        kind: 'tool_permission',
+        projectPath: workingDirectory,
+        sessionId: state.currentSessionId,
+        toolCall: {
+          id: toolCallId,
+          name: toolCall?.function.name ?? 'unknown',
+          arguments: toolCall ? redactToolArgsJson(toolCall.function.name, toolCall.function.arguments) : '',
+          decision,
+        },
+      }).catch(error => console.error('Failed to archive tool decision:', error));
+    }
+  }, [toolCallStatuses, state.messages, state.currentSessionId, workingDirectory]);
+};
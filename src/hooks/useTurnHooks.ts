@@ -0,0 +1,114 @@
+import { useEffect, useRef } from 'react';
+import type { ChatState } from '../context/ChatContext';
+import { conversationStore } from '../context/conversationStore';
+import { hookRegistry, hookConfigManager } from '../pipeline';
+import type { Turn, TurnToolCall } from '../pipeline/HookRegistry';
+import type { ChatMessage } from '../types/chat';
+
+// Runs the postTurn hooks from hooks.yaml once a turn is complete: the user's
+// message, the tool calls made to answer it and the reply that follows them.
+// Loading stops briefly between a tool call and the model's follow-up, so a
+// turn only counts as finished once loading has stayed off for a moment.
+
+const SETTLE_MS = 1500;
+
+function collectTurn(messages: ChatMessage[], status: Turn['status'], startedAt: number, endedAt: number): Turn | null {
+  let start = messages.length - 1;
+  while (start >= 0 && messages[start].role !== 'user') {
+    start--;
+  }
+  const turnMessages = messages.slice(start);
+  if (start < 0 || !turnMessages.some(m => m.role === 'assistant')) {
+    return null;
+  }
+
+  const results = new Map(turnMessages.filter(m => m.role === 'tool' && m.tool_call_id).map(m => [m.tool_call_id, m.content]));
+  const toolCalls: TurnToolCall[] = turnMessages.flatMap(m => (m.tool_calls || []).map(tc => {
+    let args: Record<string, unknown> = {};
+    try {
+      args = JSON.parse(tc.function.arguments || '{}');
+    } catch {
+      // Arguments that aren't valid JSON are left out
+    }
+    return { name: tc.function.name, args, result: results.get(tc.id) };
+  }));
+  const reply = [...turnMessages].reverse().find(m => m.role === 'assistant' && m.content);
+
+  return {
+    prompt: turnMessages[0].content,
+    reply: reply?.content ?? '',
+    ...(reply?.thinking && { thinking: reply.thinking }),
+    toolCalls,
+    messages: turnMessages,
+    status,
+    startedAt,
+    durationMs: endedAt - startedAt,
+  };
+}
+
+export const useTurnHooks = (state: ChatState, workingDirectory: string) => {
+  const workingDirectoryRef = useRef(workingDirectory);
+  workingDirectoryRef.current = workingDirectory;
+  const startedAtRef = useRef<number | null>(null);
+  const settleTimerRef = useRef<ReturnType<typeof setTimeout> | null>(null);
+  // The last turn the hooks saw, so a re-render never runs them twice for it
+  const lastTurnKeyRef = useRef<string | null>(null);
+
+  useEffect(() => {
+    if (state.isLoading) {
+      if (settleTimerRef.current) {
+        // The same turn, continuing after a tool call
+        clearTimeout(settleTimerRef.current);
+        settleTimerRef.current = null;
+        return;
+      }
+      startedAtRef.current = Date.now();
+      return;
+    }
+
+    const startedAt = startedAtRef.current;
+    if (startedAt === null) {
+      return;
+    }
+    const endedAt = Date.now();
+    settleTimerRef.current = setTimeout(() => {
+      settleTimerRef.current = null;
+      startedAtRef.current = null;
+
+      const current = conversationStore.getState();
+      const lastAssistant = [...current.messages].reverse().find(m => m.role === 'assistant');
+      const status: Turn['status'] = current.error ? 'error' : lastAssistant?.stopped ? 'stopped' : 'done';
+      const turn = collectTurn(current.messages, status, startedAt, endedAt);
+      if (!turn) {
+        return;
+      }
+      const last = turn.messages[turn.messages.length - 1];
+      const key = `${turn.messages[0].id}:${last.id}:${last.content.length}`;
+      if (lastTurnKeyRef.current === key) {
+        return;
+      }
+      lastTurnKeyRef.current = key;
+
+      hookConfigManager.loadConfig().then(() => {
+        const specs = hookConfigManager.getPostTurnHooks(current.activePromptName);
+        if (specs.length === 0) {
+          return;
+        }
+        return hookRegistry.runPostTurn(specs, turn, {
+          providerId: lastAssistant?.modelOverride?.providerId ?? current.currentProvider?.id,
+          modelId: lastAssistant?.modelOverride?.modelId ?? current.currentModel?.id,
+          promptName: current.activePromptName,
+          projectPath: workingDirectoryRef.current,
+          sessionId: current.currentSessionId,
+          ...(current.currentSessionName && { sessionName: current.currentSessionName }),
+        });
+      }).catch(error => console.error('Failed to run post-turn hooks:', error));
+    }, SETTLE_MS);
+  }, [state.isLoading]);
+
+  useEffect(() => () => {
+    if (settleTimerRef.current) {
+      clearTimeout(settleTimerRef.current);
+    }
+  }, []);
+};
@@ -0,0 +1,72 @@
+import { useEffect, useState } from 'react';
+import type { HistoryScroll, VimMode } from '../utils/vimKeys';
+
+// Vim mode for the input box, kept as the "vimMode" preference and turned on
+// or off with /vim. Normal mode scrolls the conversation, which the input box
+// asks the message list to do through scrollHistory.
+
+interface VimModeState {
+  enabled: boolean;
+  mode: VimMode;
+}
+
+let vimState: VimModeState = { enabled: false, mode: 'insert' };
+const listeners = new Set<() => void>();
+const scrollListeners = new Set<(scroll: HistoryScroll) => void>();
+let loaded = false;
+
+function update(next: Partial<VimModeState>) {
+  vimState = { ...vimState, ...next };
+  listeners.forEach(listener => listener());
+}
+
+export async function setVimEnabled(enabled: boolean) {
+  // Starting in insert mode, so typing works straight away
+  update({ enabled, mode: 'insert' });
+  await window.electronAPI.preferencesSet('vimMode', enabled);
+}
+
+export function getVimEnabled() {
+  return vimState.enabled;
+}
+
+export function setVimMode(mode: VimMode) {
+  if (vimState.mode !== mode) {
+    update({ mode });
+  }
+}
+
+export function scrollHistory(scroll: HistoryScroll) {
+  scrollListeners.forEach(listener => listener(scroll));
+}
+
+export function onHistoryScroll(listener: (scroll: HistoryScroll) => void): () => void {
+  scrollListeners.add(listener);
+  return () => {
+    scrollListeners.delete(listener);
+  };
+}
+
+export const useVimMode = () => {
+  const [state, setState] = useState(vimState);
+
+  useEffect(() => {
+    const listener = () => setState(vimState);
+    listeners.add(listener);
+    if (!loaded) {
+      loaded = true;
+      window.electronAPI.preferencesGet('vimMode')
+        .then(result => {
+          if (result.success && result.value === true) {
+            update({ enabled: true, mode: 'insert' });
+          }
+        })
+        .catch(error => console.error('Failed to load the vim mode preference:', error));
+    }
+    return () => {
+      listeners.delete(listener);
+    };
+  }, []);
+
+  return state;
+};
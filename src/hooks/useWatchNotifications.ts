@@ -0,0 +1,94 @@
+import { useCallback, useEffect, useRef } from 'react';
+import type { ChatAction, ChatState } from '../context/ChatContext';
+import { conversationStore } from '../context/conversationStore';
+import type { WatchEvent } from '../types/chat';
+import { checkToolIterationLimit } from '../utils/toolIterations';
+import type { ModelSelection } from '../utils/modelUtils';
+
+// Changes reported by watch_path and /watch. A watch started with react asks
+// the model to respond, as if the user had sent the notification; otherwise the
+// user only sees a notice. Reactions that arrive while a response is streaming
+// wait for it to finish. Watches belong to the conversation that started them
+// and stop when it is cleared or another session or project is opened.
+
+export const formatWatchEvent = (event: WatchEvent): string => {
+  const { watch, files, excerpt } = event;
+  const lines = [
+    watch.isDirectory
+      ? `Files changed in watched directory ${watch.path}: ${files.join(', ')}`
+      : `Watched file ${watch.path} changed.`,
+  ];
+  if (excerpt) {
+    lines.push('', watch.pattern ? `New lines matching /${watch.pattern}/:` : 'New lines:', '```', excerpt, '```');
+  }
+  if (watch.instructions) {
+    lines.push('', watch.instructions);
+  }
+  return lines.join('\n');
+};
+
+export const useWatchNotifications = (
+  state: ChatState,
+  dispatch: React.Dispatch<ChatAction>,
+  handleSendMessage: (messageText: string, systemPrompt?: string, modelOverride?: ModelSelection, fromWatch?: boolean) => Promise<void>,
+  lastSystemPromptRef: React.MutableRefObject<string | undefined>,
+  workingDirectory: string
+) => {
+  const queuedRef = useRef<string[]>([]);
+  const sendRef = useRef(handleSendMessage);
+  sendRef.current = handleSendMessage;
+
+  // A reaction is a turn like any other, so it counts toward maxToolIterations
+  const react = useCallback(async (text: string) => {
+    const limitNotice = await checkToolIterationLimit(conversationStore.getState().messages);
+    if (limitNotice) {
+      dispatch({ type: 'SET_NOTICE', payload: `${text}\n\n${limitNotice}` });
+      return;
+    }
+    await sendRef.current(text, lastSystemPromptRef.current, undefined, true);
+  }, [dispatch, lastSystemPromptRef]);
+
+  useEffect(() => {
+    return window.electronAPI.onWatchEvent((event) => {
+      const text = formatWatchEvent(event);
+      if (!event.watch.react) {
+        dispatch({ type: 'SET_NOTICE', payload: text });
+        return;
+      }
+      if (conversationStore.getState().isLoading) {
+        queuedRef.current.push(text);
+        return;
+      }
+      react(text).catch(error => {
+        console.error('Failed to send watch notification:', error);
+      });
+    });
+  }, [dispatch, react]);
+
+  // Send the reactions that arrived mid-response, together, once it finishes
+  useEffect(() => {
+    if (state.isLoading || queuedRef.current.length === 0) {
+      return;
+    }
+    const text = queuedRef.current.join('\n\n');
+    queuedRef.current = [];
+    react(text).catch(error => {
+      console.error('Failed to send watch notification:', error);
+    });
+  }, [state.isLoading, react]);
+
+  // Stop the window's watches when the conversation they were started for goes
+  const scopeRef = useRef({ sessionId: state.currentSessionId, workingDirectory });
+  const cleared = state.messages.length === 0;
+  useEffect(() => {
+    const scope = scopeRef.current;
+    if (scope.sessionId === state.currentSessionId && scope.workingDirectory === workingDirectory && !cleared) {
+      return;
+    }
+    scopeRef.current = { sessionId: state.currentSessionId, workingDirectory };
+    queuedRef.current = [];
+    window.electronAPI.watchStop('all').catch(error => {
+      console.error('Failed to stop watches:', error);
+    });
+  }, [state.currentSessionId, workingDirectory, cleared]);
+};
@@ -0,0 +1,162 @@
+import yaml from 'js-yaml';
+import { parseHookSpec } from './HookRegistry';
+
+// ~/.config/poe/hooks.yaml
+//
+// postResponse:
+//   default: [strip-thinking-remnants, redact-secrets]
+//   prompts:
+//     Default: [trim-apologies, smart-quotes, max-length:8000]
+// preToolCall:
+//   tools:
+//     bash: ["deny-command:rm -rf /|git push --force"]
+// postToolCall:
+//   default: [redact-secrets]
+// longRequest:
+//   after: 60         # seconds (default 30)
+//   when: finished    # or exceeded, to fire while the request is still running
+//   hooks: ["run-command:notify-send Poe \"Reply ready after $POE_REQUEST_SECONDS s\""]
+// stream:
+//   default: [redact-secrets, "strip-tags:reflection"]
+// postTurn:
+//   default: ["save-turn:~/.config/poe/notes/poe.md"]
+//   prompts:
+//     Reviewer: ["run-command:jq -r .reply >> review-log.txt"]
+// export:           # run over the whole transcript by /export and /observe
+//   default: ["redact-secrets:[secret]"]   # redact-secrets when not set, [] for none
+export interface HooksConfig {
+  postResponse?: ProfileHooksConfig;
+  preToolCall?: ToolCallHooksConfig;
+  postToolCall?: ToolCallHooksConfig;
+  longRequest?: LongRequestHooksConfig;
+  postTurn?: ProfileHooksConfig;
+  stream?: ProfileHooksConfig;
+  export?: ProfileHooksConfig;
+}
+
+// Hooks for every prompt profile, or per profile by name
+export interface ProfileHooksConfig {
+  default?: string[];
+  prompts?: Record<string, string[]>;
+}
+
+export interface ToolCallHooksConfig {
+  default?: string[];
+  tools?: Record<string, string[]>;
+}
+
+export interface LongRequestHooksConfig {
+  after?: number;
+  when?: 'finished' | 'exceeded';
+  hooks?: string[];
+}
+
+const DEFAULT_LONG_REQUEST_SECONDS = 30;
+// Transcripts are redacted on the way out unless hooks.yaml says otherwise
+const DEFAULT_EXPORT_HOOKS = ['redact-secrets'];
+
+function profileHooks(hooks: ProfileHooksConfig | undefined, promptName?: string | null): string[] {
+  if (!hooks) {
+    return [];
+  }
+  if (promptName && hooks.prompts?.[promptName]) {
+    return hooks.prompts[promptName];
+  }
+  return hooks.default || [];
+}
+
+// A deny-command pattern that isn't a valid regex refuses every call it
+// covers; report it when the file is read instead of on the first call
+function checkDenyPatterns(hooks: ToolCallHooksConfig | undefined): void {
+  const lists = [hooks?.default, ...Object.values(hooks?.tools || {})];
+  for (const spec of lists.flat()) {
+    if (typeof spec !== 'string') {
+      continue;
+    }
+    const { name, arg } = parseHookSpec(spec);
+    if (name !== 'deny-command' || !arg) {
+      continue;
+    }
+    try {
+      new RegExp(arg);
+    } catch (error) {
+      console.error(`Invalid deny-command pattern in hooks.yaml, calls it applies to are refused: ${arg}`, error);
+    }
+  }
+}
+
+class HookConfigManager {
+  private config: HooksConfig = {};
+
+  async loadConfig(): Promise<HooksConfig> {
+    try {
+      const result = await window.electronAPI.configRead('hooks.yaml');
+      this.config = result.success && result.content
+        ? (yaml.load(result.content) as HooksConfig) || {}
+        : {};
+      checkDenyPatterns(this.config.preToolCall);
+    } catch (error) {
+      console.error('Failed to load hooks config:', error);
+      this.config = {};
+    }
+    return this.config;
+  }
+
+  getConfig(): HooksConfig {
+    return this.config;
+  }
+
+  /**
+   * Post-response hooks for a prompt profile, falling back to the default list
+   */
+  getPostResponseHooks(promptName?: string | null): string[] {
+    return profileHooks(this.config.postResponse, promptName);
+  }
+
+  /**
+   * Post-turn hooks for a prompt profile, falling back to the default list
+   */
+  getPostTurnHooks(promptName?: string | null): string[] {
+    return profileHooks(this.config.postTurn, promptName);
+  }
+
+  /**
+   * Stream hooks for a prompt profile, falling back to the default list
+   */
+  getStreamHooks(promptName?: string | null): string[] {
+    return profileHooks(this.config.stream, promptName);
+  }
+
+  /**
+   * Post-response hooks run over a transcript before it is exported or shared
+   */
+  getExportHooks(promptName?: string | null): string[] {
+    return this.config.export ? profileHooks(this.config.export, promptName) : DEFAULT_EXPORT_HOOKS;
+  }
+
+  /**
+   * Tool-call hooks for a tool, falling back to the default list
+   */
+  getToolCallHooks(kind: 'preToolCall' | 'postToolCall', toolName: string): string[] {
+    const hooks = this.config[kind];
+    if (!hooks) {
+      return [];
+    }
+    return hooks.tools?.[toolName] || hooks.default || [];
+  }
+
+  /**
+   * Long-request hooks and when they fire
+   */
+  getLongRequestHooks(): { afterMs: number; when: 'finished' | 'exceeded'; specs: string[] } {
+    const longRequest = this.config.longRequest;
+    const after = Number(longRequest?.after);
+    return {
+      afterMs: (Number.isFinite(after) && after >= 0 ? after : DEFAULT_LONG_REQUEST_SECONDS) * 1000,
+      when: longRequest?.when === 'exceeded' ? 'exceeded' : 'finished',
+      specs: longRequest?.hooks || [],
+    };
+  }
+}
+
+export const hookConfigManager = new HookConfigManager();
@@ -0,0 +1,430 @@
+import type { ChatMessage } from '../types/chat';
+
+export interface PostResponseContext {
+  providerId?: string;
+  modelId?: string;
+  promptName?: string | null;
+  thinking?: string; // The response's reasoning, as left by earlier hooks
+}
+
+export interface PostResponseHook {
+  name: string;
+  description: string;
+  // arg comes from "name:arg" in the hook config, e.g. "max-length:4000"
+  run: (content: string, context: PostResponseContext, arg?: string) => string | Promise<string>;
+  // Optional pass over the reasoning, for hooks that log or redact it too
+  runThinking?: (thinking: string, context: PostResponseContext, arg?: string) => string | Promise<string>;
+}
+
+// A finished assistant message as seen by the hooks
+export interface PostResponseResult {
+  content: string;
+  thinking?: string;
+}
+
+export interface StreamContext {
+  providerId?: string;
+  modelId?: string;
+  promptName?: string | null;
+}
+
+// Sees a response's content as it streams. push() returns the text to show
+// now: nothing drops the chunk or holds it back, several entries emit several
+// chunks. Whatever is still held back comes out of flush() when the response
+// ends, so text can be buffered until a whole sentence or tag has arrived.
+export interface StreamProcessor {
+  push: (text: string) => string[];
+  flush?: () => string[];
+}
+
+// Makes a processor for each response, so it can keep state across chunks
+export interface StreamHook {
+  name: string;
+  description: string;
+  create: (context: StreamContext, arg?: string) => StreamProcessor;
+}
+
+// The configured stream hooks for one response, chained in order
+export interface StreamPipeline {
+  push: (text: string) => string[];
+  flush: () => string[];
+}
+
+export interface ToolCallContext {
+  toolName: string;
+  toolCallId: string;
+  projectPath: string;
+}
+
+// Returning args replaces the call's arguments; returning deny refuses the call with that reason
+export interface PreToolCallDecision {
+  args?: Record<string, unknown>;
+  deny?: string;
+}
+
+export interface PreToolCallHook {
+  name: string;
+  description: string;
+  run: (
+    args: Record<string, unknown>,
+    context: ToolCallContext,
+    arg?: string
+  ) => PreToolCallDecision | void | Promise<PreToolCallDecision | void>;
+}
+
+// Returns the result that is fed back to the model
+export interface PostToolCallHook {
+  name: string;
+  description: string;
+  run: (
+    result: unknown,
+    context: ToolCallContext & { args: Record<string, unknown> },
+    arg?: string
+  ) => unknown | Promise<unknown>;
+}
+
+export interface LongRequestContext {
+  durationMs: number;
+  // running when the hook fires on crossing the limit; stopped when the user stopped it
+  status: 'running' | 'done' | 'error' | 'stopped';
+  providerId?: string;
+  modelId?: string;
+  projectPath: string;
+  preview?: string; // Start of the reply, once there is one
+}
+
+// Fires once for a request that runs longer than longRequest.after
+export interface LongRequestHook {
+  name: string;
+  description: string;
+  run: (context: LongRequestContext, arg?: string) => void | Promise<void>;
+}
+
+export interface TurnToolCall {
+  name: string;
+  args: Record<string, unknown>;
+  result?: string; // Missing when the turn ended before the tool answered
+}
+
+// A finished turn: the user's message and everything done to answer it
+export interface Turn {
+  prompt: string;
+  reply: string; // The final answer; empty if the turn ended without one
+  thinking?: string;
+  toolCalls: TurnToolCall[];
+  messages: ChatMessage[]; // The user's message and every message after it
+  status: 'done' | 'error' | 'stopped';
+  startedAt: number;
+  durationMs: number;
+}
+
+export interface TurnContext {
+  providerId?: string;
+  modelId?: string;
+  promptName?: string | null;
+  projectPath: string;
+  sessionId: string;
+  sessionName?: string;
+}
+
+// Fires once each turn is complete, through any tool calls. Turn hooks only
+// observe the conversation; nothing they return changes it.
+export interface PostTurnHook {
+  name: string;
+  description: string;
+  run: (turn: Turn, context: TurnContext, arg?: string) => void | Promise<void>;
+}
+
+/**
+ * Split a configured hook reference like "max-length:4000" into name and argument
+ */
+export function parseHookSpec(spec: string): { name: string; arg?: string } {
+  const index = spec.indexOf(':');
+  if (index < 0) {
+    return { name: spec.trim() };
+  }
+  return { name: spec.substring(0, index).trim(), arg: spec.substring(index + 1).trim() };
+}
+
+class HookRegistry {
+  private postResponseHooks: Map<string, PostResponseHook> = new Map();
+  private preToolCallHooks: Map<string, PreToolCallHook> = new Map();
+  private postToolCallHooks: Map<string, PostToolCallHook> = new Map();
+  private longRequestHooks: Map<string, LongRequestHook> = new Map();
+  private postTurnHooks: Map<string, PostTurnHook> = new Map();
+  private streamHooks: Map<string, StreamHook> = new Map();
+
+  registerPostResponseHook(hook: PostResponseHook) {
+    this.postResponseHooks.set(hook.name, hook);
+  }
+
+  unregisterPostResponseHook(name: string) {
+    this.postResponseHooks.delete(name);
+  }
+
+  getPostResponseHooks(): PostResponseHook[] {
+    return Array.from(this.postResponseHooks.values());
+  }
+
+  registerStreamHook(hook: StreamHook) {
+    this.streamHooks.set(hook.name, hook);
+  }
+
+  unregisterStreamHook(name: string) {
+    this.streamHooks.delete(name);
+  }
+
+  registerPreToolCallHook(hook: PreToolCallHook) {
+    this.preToolCallHooks.set(hook.name, hook);
+  }
+
+  unregisterPreToolCallHook(name: string) {
+    this.preToolCallHooks.delete(name);
+  }
+
+  registerPostToolCallHook(hook: PostToolCallHook) {
+    this.postToolCallHooks.set(hook.name, hook);
+  }
+
+  unregisterPostToolCallHook(name: string) {
+    this.postToolCallHooks.delete(name);
+  }
+
+  registerLongRequestHook(hook: LongRequestHook) {
+    this.longRequestHooks.set(hook.name, hook);
+  }
+
+  unregisterLongRequestHook(name: string) {
+    this.longRequestHooks.delete(name);
+  }
+
+  registerPostTurnHook(hook: PostTurnHook) {
+    this.postTurnHooks.set(hook.name, hook);
+  }
+
+  unregisterPostTurnHook(name: string) {
+    this.postTurnHooks.delete(name);
+  }
+
+  /**
+   * Run the named post-response hooks in order. Unknown or failing hooks are
+   * skipped so a bad config never loses the response.
+   */
+  async runPostResponse(specs: string[], response: PostResponseResult, context: PostResponseContext): Promise<PostResponseResult> {
+    let { content, thinking } = response;
+
+    for (const spec of specs) {
+      const { name, arg } = parseHookSpec(spec);
+      const hook = this.postResponseHooks.get(name);
+      if (!hook) {
+        console.warn(`Unknown post-response hook "${name}", skipping`);
+        continue;
+      }
+
+      if (thinking && hook.runThinking) {
+        try {
+          thinking = await hook.runThinking(thinking, context, arg);
+        } catch (error) {
+          console.error(`Post-response hook "${name}" failed on thinking:`, error);
+        }
+      }
+
+      if (content) {
+        try {
+          content = await hook.run(content, { ...context, thinking }, arg);
+        } catch (error) {
+          console.error(`Post-response hook "${name}" failed:`, error);
+        }
+      }
+    }
+
+    return { content, thinking };
+  }
+
+  /**
+   * Run the named post-response hooks over a whole transcript before it leaves
+   * the app: every message's content and thinking, and tool call arguments.
+   * Returns changed copies and the ids of the messages that changed.
+   */
+  async runTranscript(specs: string[], messages: ChatMessage[], context: PostResponseContext): Promise<{ messages: ChatMessage[]; changedIds: string[] }> {
+    const changedIds: string[] = [];
+    const result: ChatMessage[] = [];
+    for (const message of messages) {
+      const { content, thinking } = await this.runPostResponse(specs, { content: message.content, thinking: message.thinking }, context);
+      const toolCalls = message.tool_calls && await Promise.all(message.tool_calls.map(async tc => {
+        const args = (await this.runPostResponse(specs, { content: tc.function.arguments }, context)).content;
+        return args === tc.function.arguments ? tc : { ...tc, function: { ...tc.function, arguments: args } };
+      }));
+      const changed = content !== message.content || thinking !== message.thinking
+        || (toolCalls || []).some((tc, i) => tc !== message.tool_calls![i]);
+      if (changed) {
+        changedIds.push(message.id);
+      }
+      result.push(changed ? { ...message, content, ...(thinking !== undefined && { thinking }), ...(toolCalls && { tool_calls: toolCalls }) } : message);
+    }
+    return { messages: result, changedIds };
+  }
+
+  /**
+   * Chain the named stream hooks for one response, each one's output feeding
+   * the next. Returns null when none of them exist. A processor that throws
+   * passes its chunk through unchanged.
+   */
+  createStreamPipeline(specs: string[], context: StreamContext): StreamPipeline | null {
+    const stages: Array<{ name: string; processor: StreamProcessor }> = [];
+    for (const spec of specs) {
+      const { name, arg } = parseHookSpec(spec);
+      const hook = this.streamHooks.get(name);
+      if (!hook) {
+        console.warn(`Unknown stream hook "${name}", skipping`);
+        continue;
+      }
+      try {
+        stages.push({ name, processor: hook.create(context, arg) });
+      } catch (error) {
+        console.error(`Stream hook "${name}" failed to start:`, error);
+      }
+    }
+    if (stages.length === 0) {
+      return null;
+    }
+
+    const pushThrough = (from: number, texts: string[]): string[] => {
+      let current = texts;
+      for (const { name, processor } of stages.slice(from)) {
+        current = current.flatMap(text => {
+          try {
+            return processor.push(text);
+          } catch (error) {
+            console.error(`Stream hook "${name}" failed:`, error);
+            return [text];
+          }
+        });
+      }
+      return current.filter(Boolean);
+    };
+
+    return {
+      push: (text) => pushThrough(0, [text]),
+      // Each stage's held-back text still goes through the stages after it
+      flush: () => stages.flatMap(({ name, processor }, i) => {
+        try {
+          return pushThrough(i + 1, processor.flush?.() ?? []);
+        } catch (error) {
+          console.error(`Stream hook "${name}" failed to flush:`, error);
+          return [];
+        }
+      }),
+    };
+  }
+
+  /**
+   * Run the named pre-tool-call hooks in order. The first hook to deny stops the
+   * call. These hooks guard what tools may do, so one that is unknown or throws
+   * denies the call too.
+   */
+  async runPreToolCall(
+    specs: string[],
+    args: Record<string, unknown>,
+    context: ToolCallContext
+  ): Promise<{ args: Record<string, unknown>; denied?: string }> {
+    let current = args;
+
+    for (const spec of specs) {
+      const { name, arg } = parseHookSpec(spec);
+      const hook = this.preToolCallHooks.get(name);
+      if (!hook) {
+        console.error(`Unknown pre-tool-call hook "${name}", refusing the call`);
+        return { args: current, denied: `Unknown pre-tool-call hook "${name}"` };
+      }
+
+      try {
+        const decision = await hook.run(current, context, arg);
+        if (decision?.deny) {
+          return { args: current, denied: `Blocked by ${name} hook: ${decision.deny}` };
+        }
+        if (decision?.args) {
+          current = decision.args;
+        }
+      } catch (error) {
+        console.error(`Pre-tool-call hook "${name}" failed:`, error);
+        return {
+          args: current,
+          denied: `Blocked because the ${name} hook failed: ${error instanceof Error ? error.message : 'Unknown error'}`,
+        };
+      }
+    }
+
+    return { args: current };
+  }
+
+  /**
+   * Run the named post-tool-call hooks in order over a tool result
+   */
+  async runPostToolCall(
+    specs: string[],
+    result: unknown,
+    context: ToolCallContext & { args: Record<string, unknown> }
+  ): Promise<unknown> {
+    let current = result;
+
+    for (const spec of specs) {
+      const { name, arg } = parseHookSpec(spec);
+      const hook = this.postToolCallHooks.get(name);
+      if (!hook) {
+        console.warn(`Unknown post-tool-call hook "${name}", skipping`);
+        continue;
+      }
+
+      try {
+        current = await hook.run(current, context, arg);
+      } catch (error) {
+        console.error(`Post-tool-call hook "${name}" failed:`, error);
+      }
+    }
+
+    return current;
+  }
+
+  /**
+   * Run the named long-request hooks in order
+   */
+  async runLongRequest(specs: string[], context: LongRequestContext): Promise<void> {
+    for (const spec of specs) {
+      const { name, arg } = parseHookSpec(spec);
+      const hook = this.longRequestHooks.get(name);
+      if (!hook) {
+        console.warn(`Unknown long-request hook "${name}", skipping`);
+        continue;
+      }
+
+      try {
+        await hook.run(context, arg);
+      } catch (error) {
+        console.error(`Long-request hook "${name}" failed:`, error);
+      }
+    }
+  }
+
+  /**
+   * Run the named post-turn hooks in order
+   */
+  async runPostTurn(specs: string[], turn: Turn, context: TurnContext): Promise<void> {
+    for (const spec of specs) {
+      const { name, arg } = parseHookSpec(spec);
+      const hook = this.postTurnHooks.get(name);
+      if (!hook) {
+        console.warn(`Unknown post-turn hook "${name}", skipping`);
+        continue;
+      }
+
+      try {
+        await hook.run(turn, context, arg);
+      } catch (error) {
+        console.error(`Post-turn hook "${name}" failed:`, error);
+      }
+    }
+  }
+}
+
+export const hookRegistry = new HookRegistry();
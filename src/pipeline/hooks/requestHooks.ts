@@ -0,0 +1,28 @@
+import type { LongRequestHook } from '../HookRegistry';
+
+// Run a shell command, e.g. "run-command:notify-send Poe 'Reply ready'". The
+// request is described in the environment rather than the command line, so a
+// reply's text never ends up parsed by the shell.
+export const RunCommandHook: LongRequestHook = {
+  name: 'run-command',
+  description: 'Run a shell command with POE_REQUEST_SECONDS, POE_REQUEST_STATUS, POE_MODEL and POE_REPLY set (run-command:COMMAND)',
+  run: async (context, arg) => {
+    if (!arg) {
+      return;
+    }
+    const result = await window.electronAPI.hookRunCommand(arg, {
+      cwd: context.projectPath || undefined,
+      env: {
+        POE_REQUEST_SECONDS: String(Math.round(context.durationMs / 1000)),
+        POE_REQUEST_STATUS: context.status,
+        POE_PROVIDER: context.providerId ?? '',
+        POE_MODEL: context.modelId ?? '',
+        POE_PROJECT: context.projectPath,
+        POE_REPLY: context.preview ?? '',
+      },
+    });
+    if (!result.success) {
+      throw new Error(result.error ?? 'Failed to run command');
+    }
+  },
+};
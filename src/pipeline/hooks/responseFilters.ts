@@ -0,0 +1,109 @@
+import type { PostResponseHook } from '../HookRegistry';
+
+const DEFAULT_MAX_LENGTH = 4000;
+
+// Openers like "I apologize for the confusion." and closers like "I hope this helps!"
+const APOLOGY_OPENERS = /^\s*((I('m| am) sorry|Sorry|I apologi[sz]e|My apologies|Apologies)[^.!?\n]*[.!?]\s*)+/i;
+const BOILERPLATE_CLOSERS = /(\s*(I hope (this|that) helps|Let me know if you (have any|need any|want)|Feel free to (ask|reach out)|Happy coding)[^\n]*)+\s*$/i;
+
+export const TrimApologiesHook: PostResponseHook = {
+  name: 'trim-apologies',
+  description: 'Remove boilerplate apologies at the start and sign-offs at the end',
+  run: (content) => {
+    const trimmed = content.replace(APOLOGY_OPENERS, '').replace(BOILERPLATE_CLOSERS, '');
+    // Never reduce a response to nothing
+    return trimmed.trim() ? trimmed : content;
+  },
+};
+
+export const StripThinkingRemnantsHook: PostResponseHook = {
+  name: 'strip-thinking-remnants',
+  description: 'Remove leftover reasoning markers that leaked into the response',
+  run: (content) => {
+    let result = content
+      // Complete <think> blocks that weren't split out
+      .replace(/<think>[\s\S]*?<\/think>\s*/g, '')
+      // Harmony channel headers, e.g. <|channel|>final<|message|>
+      .replace(/<\|(start|end|channel|message|return)\|>[^<\n]*?(?=<\||$)/gm, '')
+      .replace(/<\|(start|end|channel|message|return)\|>/g, '')
+      // "Thinking..." / "...done thinking." status lines
+      .replace(/^\s*(Thinking\.\.\.|\.\.\.done thinking\.?)\s*$/gim, '');
+
+    // A stray closing tag means everything before it was reasoning
+    const closeIndex = result.indexOf('</think>');
+    if (closeIndex >= 0) {
+      result = result.substring(closeIndex + '</think>'.length);
+    }
+
+    return result.trim() ? result.trimStart() : content;
+  },
+};
+
+export const MaxLengthHook: PostResponseHook = {
+  name: 'max-length',
+  description: 'Truncate responses longer than N characters (max-length:N)',
+  run: (content, _context, arg) => {
+    const limit = arg ? parseInt(arg, 10) : DEFAULT_MAX_LENGTH;
+    if (!limit || isNaN(limit) || content.length <= limit) {
+      return content;
+    }
+
+    // Prefer cutting at a paragraph, then a sentence boundary
+    const head = content.substring(0, limit);
+    const paragraph = head.lastIndexOf('\n\n');
+    const sentence = Math.max(head.lastIndexOf('. '), head.lastIndexOf('.\n'));
+    const cut = paragraph > limit * 0.5 ? paragraph : sentence > limit * 0.5 ? sentence + 1 : limit;
+
+    return `${content.substring(0, cut).trimEnd()}\n\n…[truncated ${content.length - cut} characters]`;
+  },
+};
+
+// Credentials that commonly end up pasted into or echoed by a response
+const SECRET_PATTERNS: Array<{ label: string; pattern: RegExp }> = [
+  { label: 'private key', pattern: /-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----/g },
+  { label: 'API key', pattern: /\bsk-(ant-|proj-)?[A-Za-z0-9_-]{20,}/g },
+  { label: 'GitHub token', pattern: /\b(ghp|gho|ghu|ghs|ghr|github_pat)_[A-Za-z0-9_]{20,}/g },
+  { label: 'Slack token', pattern: /\bxox[abposr]-[A-Za-z0-9-]{10,}/g },
+  { label: 'AWS access key', pattern: /\bAKIA[0-9A-Z]{16}\b/g },
+  { label: 'Google API key', pattern: /\bAIza[0-9A-Za-z_-]{35}\b/g },
+  { label: 'bearer token', pattern: /(\bBearer\s+)[A-Za-z0-9._~+/-]{20,}=*/g },
+];
+
+// Patterns run in order over what earlier ones left, so a key is only counted once
+const maskSecrets = (text: string, replacement: string, onMatch?: (label: string) => void): string =>
+  SECRET_PATTERNS.reduce(
+    (result, { label, pattern }) => result.replace(pattern, (_match, bearer?: string) => {
+      onMatch?.(label);
+      return typeof bearer === 'string' && /^Bearer/i.test(bearer) ? `${bearer}${replacement}` : replacement;
+    }),
+    text
+  );
+
+export const redactSecrets = (text: string, replacement = '[REDACTED]'): string => maskSecrets(text, replacement);
+
+/**
+ * The kind of each secret redactSecrets would mask in text, e.g. ["GitHub token"]
+ */
+export const findSecrets = (text: string): string[] => {
+  const found: string[] = [];
+  maskSecrets(text, '[REDACTED]', label => found.push(label));
+  return found;
+};
+
+export const RedactSecretsHook: PostResponseHook = {
+  name: 'redact-secrets',
+  description: 'Mask API keys, tokens, and private keys in the response and its thinking (redact-secrets:TEXT)',
+  run: (content, _context, arg) => redactSecrets(content, arg || undefined),
+  runThinking: (thinking, _context, arg) => redactSecrets(thinking, arg || undefined),
+};
+
+export const SmartQuotesHook: PostResponseHook = {
+  name: 'smart-quotes',
+  description: 'Convert typographic quotes and dashes to plain ASCII',
+  run: (content) => content
+    .replace(/[‘’‚′]/g, "'")
+    .replace(/[“”„″]/g, '"')
+    .replace(/–/g, '-')
+    .replace(/—/g, '--')
+    .replace(/…/g, '...'),
+};
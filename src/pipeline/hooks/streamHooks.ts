@@ -0,0 +1,116 @@
+import type { StreamHook, StreamProcessor } from '../HookRegistry';
+import { redactSecrets } from './responseFilters';
+
+/**
+ * The end of the last match of a global pattern in text, or 0
+ */
+export function lastBoundary(text: string, pattern: RegExp): number {
+  let end = 0;
+  for (const match of text.matchAll(pattern)) {
+    end = match.index + match[0].length;
+  }
+  return end;
+}
+
+/**
+ * A processor that holds text back until boundary() says how much of it is
+ * complete, e.g. up to the last sentence end, and passes each complete piece
+ * through transform
+ */
+export function bufferUntil(boundary: (text: string) => number, transform: (segment: string) => string = segment => segment): StreamProcessor {
+  let pending = '';
+  return {
+    push: (text) => {
+      pending += text;
+      const end = boundary(pending);
+      if (end <= 0) {
+        return [];
+      }
+      const segment = pending.substring(0, end);
+      pending = pending.substring(end);
+      return [transform(segment)];
+    },
+    flush: () => {
+      const rest = pending;
+      pending = '';
+      return rest ? [transform(rest)] : [];
+    },
+  };
+}
+
+// Secrets have no spaces, so text is released a word at a time, except for a
+// "Bearer" waiting for its token and a PEM block waiting for its END line
+function redactionBoundary(text: string): number {
+  const begin = text.lastIndexOf('-----BEGIN');
+  if (begin >= 0 && !/-----END [A-Z ]*-----/.test(text.substring(begin))) {
+    return begin;
+  }
+  const end = lastBoundary(text, /\s+/g);
+  const bearer = text.substring(0, end).match(/\bBearer\s+$/i);
+  return bearer?.index !== undefined ? bearer.index : end;
+}
+
+export const RedactSecretsStreamHook: StreamHook = {
+  name: 'redact-secrets',
+  description: 'Mask API keys, tokens and private keys before they are shown (redact-secrets:TEXT)',
+  create: (_context, arg) => bufferUntil(redactionBoundary, segment => redactSecrets(segment, arg || undefined)),
+};
+
+// Remove <tag>...</tag> spans, e.g. "strip-tags:reflection,scratchpad". Tags
+// split across chunks are held back until it's clear what they are.
+export const StripTagsStreamHook: StreamHook = {
+  name: 'strip-tags',
+  description: 'Remove the named tags and everything inside them as the response streams (strip-tags:TAG,TAG)',
+  create: (_context, arg) => {
+    const names = (arg || '').split(',').map(name => name.trim()).filter(Boolean);
+    let pending = '';
+    let closing: string | null = null; // The close tag being waited for, inside a span
+
+    const process = (final: boolean): string[] => {
+      let output = '';
+      while (pending) {
+        if (closing) {
+          const index = pending.indexOf(closing);
+          if (index < 0) {
+            // Keep only what could be the start of the close tag
+            pending = final ? '' : pending.substring(Math.max(0, pending.length - closing.length + 1));
+            break;
+          }
+          pending = pending.substring(index + closing.length);
+          closing = null;
+          continue;
+        }
+
+        const open = names
+          .map(name => ({ name, index: pending.indexOf(`<${name}>`) }))
+          .filter(found => found.index >= 0)
+          .sort((a, b) => a.index - b.index)[0];
+        if (open) {
+          output += pending.substring(0, open.index);
+          pending = pending.substring(open.index + open.name.length + 2);
+          closing = `</${open.name}>`;
+          continue;
+        }
+
+        // Hold back a "<" that might begin one of the open tags
+        const lt = pending.lastIndexOf('<');
+        const partial = !final && lt >= 0 && names.some(name => `<${name}>`.startsWith(pending.substring(lt)));
+        output += partial ? pending.substring(0, lt) : pending;
+        pending = partial ? pending.substring(lt) : '';
+        break;
+      }
+      return output ? [output] : [];
+    };
+
+    if (names.length === 0) {
+      return { push: text => [text] };
+    }
+    return {
+      push: (text) => {
+        pending += text;
+        return process(false);
+      },
+      flush: () => process(true),
+    };
+  },
+};
@@ -0,0 +1,38 @@
+import type { PreToolCallHook, PostToolCallHook } from '../HookRegistry';
+import { redactSecrets } from './responseFilters';
+
+// Refuse shell commands matching a regex, e.g. "deny-command:rm -rf /|git push --force"
+export const DenyCommandHook: PreToolCallHook = {
+  name: 'deny-command',
+  description: 'Block calls whose command argument matches a pattern (deny-command:REGEX)',
+  run: (args, _context, arg) => {
+    const command = args.command;
+    if (!arg || typeof command !== 'string') {
+      return;
+    }
+    if (new RegExp(arg).test(command)) {
+      return { deny: `command matches /${arg}/` };
+    }
+  },
+};
+
+const redactValue = (value: unknown, replacement?: string): unknown => {
+  if (typeof value === 'string') {
+    return redactSecrets(value, replacement);
+  }
+  if (Array.isArray(value)) {
+    return value.map(item => redactValue(item, replacement));
+  }
+  if (value && typeof value === 'object') {
+    return Object.fromEntries(
+      Object.entries(value as Record<string, unknown>).map(([key, item]) => [key, redactValue(item, replacement)])
+    );
+  }
+  return value;
+};
+
+export const RedactToolSecretsHook: PostToolCallHook = {
+  name: 'redact-secrets',
+  description: 'Mask API keys, tokens, and private keys in tool output before the model sees it (redact-secrets:TEXT)',
+  run: (result, _context, arg) => redactValue(result, arg || undefined),
+};
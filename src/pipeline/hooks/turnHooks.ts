@@ -0,0 +1,68 @@
+import type { PostTurnHook, Turn, TurnContext } from '../HookRegistry';
+
+const PREVIEW_CHARS = 200;
+
+// The turn as JSON on stdin, without the raw messages, which repeat it
+function turnJson(turn: Turn, context: TurnContext): string {
+  return JSON.stringify({ ...turn, messages: undefined, ...context });
+}
+
+// Run a shell command with the turn as JSON on stdin, e.g.
+// "run-command:jq -r .reply >> replies.txt". A short summary is also in the
+// environment for commands that don't read their input.
+export const RunTurnCommandHook: PostTurnHook = {
+  name: 'run-command',
+  description: 'Run a shell command with the turn as JSON on stdin and POE_TURN_STATUS, POE_MODEL and POE_REPLY set (run-command:COMMAND)',
+  run: async (turn, context, arg) => {
+    if (!arg) {
+      return;
+    }
+    const result = await window.electronAPI.hookRunCommand(arg, {
+      cwd: context.projectPath || undefined,
+      env: {
+        POE_TURN_STATUS: turn.status,
+        POE_TURN_SECONDS: String(Math.round(turn.durationMs / 1000)),
+        POE_TURN_TOOLS: turn.toolCalls.map(call => call.name).join(','),
+        POE_PROVIDER: context.providerId ?? '',
+        POE_MODEL: context.modelId ?? '',
+        POE_PROJECT: context.projectPath,
+        POE_SESSION: context.sessionId,
+        POE_REPLY: turn.reply.substring(0, PREVIEW_CHARS),
+      },
+      input: turnJson(turn, context),
+    });
+    if (!result.success) {
+      throw new Error(result.error ?? 'Failed to run command');
+    }
+  },
+};
+
+function formatTurnMarkdown(turn: Turn, context: TurnContext): string {
+  const started = new Date(turn.startedAt);
+  const pad = (n: number) => String(n).padStart(2, '0');
+  const when = `${started.getFullYear()}-${pad(started.getMonth() + 1)}-${pad(started.getDate())} ${pad(started.getHours())}:${pad(started.getMinutes())}`;
+  const model = [context.providerId, context.modelId].filter(Boolean).join('/');
+  const lines = [`## ${when}${model ? ` · ${model}` : ''}${context.sessionName ? ` · ${context.sessionName}` : ''}`, '', `> ${turn.prompt.replace(/\n/g, '\n> ')}`, ''];
+  if (turn.toolCalls.length > 0) {
+    lines.push(`Tools: ${turn.toolCalls.map(call => call.name).join(', ')}`, '');
+  }
+  lines.push(turn.reply || `_No reply (${turn.status})_`, '', '');
+  return lines.join('\n');
+}
+
+// Append the turn to a Markdown notes file, e.g.
+// "save-turn:~/.config/poe/notes/poe.md". Relative paths are from the project,
+// which must be trusted; other files must be in ~/.config/poe/notes.
+export const SaveTurnHook: PostTurnHook = {
+  name: 'save-turn',
+  description: 'Append the prompt and reply to a Markdown file (save-turn:PATH)',
+  run: async (turn, context, arg) => {
+    if (!arg) {
+      return;
+    }
+    const result = await window.electronAPI.hookAppendFile(arg, formatTurnMarkdown(turn, context), context.projectPath || undefined);
+    if (!result.success) {
+      throw new Error(result.error ?? `Failed to write ${arg}`);
+    }
+  },
+};
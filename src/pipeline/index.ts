@@ -0,0 +1,40 @@
+import { hookRegistry } from './HookRegistry';
+import { hookConfigManager } from './HookConfigManager';
+import {
+  TrimApologiesHook,
+  StripThinkingRemnantsHook,
+  MaxLengthHook,
+  SmartQuotesHook,
+  RedactSecretsHook,
+} from './hooks/responseFilters';
+import { DenyCommandHook, RedactToolSecretsHook } from './hooks/toolCallHooks';
+import { RunCommandHook } from './hooks/requestHooks';
+import { RunTurnCommandHook, SaveTurnHook } from './hooks/turnHooks';
+import { RedactSecretsStreamHook, StripTagsStreamHook } from './hooks/streamHooks';
+
+// Register all built-in hooks
+export function initializeHooks() {
+  // Post-response filters
+  hookRegistry.registerPostResponseHook(TrimApologiesHook);
+  hookRegistry.registerPostResponseHook(StripThinkingRemnantsHook);
+  hookRegistry.registerPostResponseHook(MaxLengthHook);
+  hookRegistry.registerPostResponseHook(SmartQuotesHook);
+  hookRegistry.registerPostResponseHook(RedactSecretsHook);
+
+  // Stream hooks
+  hookRegistry.registerStreamHook(RedactSecretsStreamHook);
+  hookRegistry.registerStreamHook(StripTagsStreamHook);
+
+  // Tool-call hooks
+  hookRegistry.registerPreToolCallHook(DenyCommandHook);
+  hookRegistry.registerPostToolCallHook(RedactToolSecretsHook);
+
+  // Long-request hooks
+  hookRegistry.registerLongRequestHook(RunCommandHook);
+
+  // Post-turn hooks
+  hookRegistry.registerPostTurnHook(RunTurnCommandHook);
+  hookRegistry.registerPostTurnHook(SaveTurnHook);
+}
+
+export { hookRegistry, hookConfigManager };
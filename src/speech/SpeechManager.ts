@@ -0,0 +1,122 @@
+// Text-to-speech for finished assistant messages. Backends are registered by
+// name and picked with the "tts" preference:
+//
+// { "enabled": true, "backend": "piper", "rate": 1.1, "piper": { "model": "~/voices/en_US-amy-medium.onnx" } }
+
+export interface SpeechSettings {
+  enabled?: boolean;
+  backend?: string;
+  voice?: string; // system backend: voice name as listed by the OS
+  rate?: number;
+  piper?: {
+    binary?: string; // defaults to "piper" on PATH
+    model?: string;
+  };
+}
+
+export interface SpeechBackend {
+  name: string;
+  description: string;
+  // Resolves when playback finishes or is stopped
+  speak: (text: string, settings: SpeechSettings) => Promise<void>;
+  stop: () => void;
+}
+
+const DEFAULT_BACKEND = 'system';
+
+/**
+ * Reduce markdown to the words worth reading aloud. Code blocks are skipped.
+ */
+export function toSpeakableText(markdown: string): string {
+  return markdown
+    .replace(/<think>[\s\S]*?<\/think>/g, '')
+    .replace(/```[\s\S]*?(```|$)/g, ' ')
+    .replace(/`([^`]+)`/g, '$1')
+    .replace(/!\[[^\]]*\]\([^)]*\)/g, '')
+    .replace(/\[([^\]]+)\]\([^)]*\)/g, '$1')
+    .replace(/^\s{0,3}(#{1,6}|>|[-*+]|\d+\.)\s+/gm, '')
+    .replace(/(\*\*|__|\*|_|~~)(?=\S)([\s\S]*?\S)\1/g, '$2')
+    .replace(/^\s*\|?[-:| ]+\|?\s*$/gm, '')
+    .replace(/\|/g, ', ')
+    .replace(/\n{2,}/g, '\n')
+    .trim();
+}
+
+class SpeechManager {
+  private backends: Map<string, SpeechBackend> = new Map();
+  private settings: SpeechSettings = {};
+  private current: SpeechBackend | null = null;
+
+  registerBackend(backend: SpeechBackend) {
+    this.backends.set(backend.name, backend);
+  }
+
+  getBackendNames(): string[] {
+    return Array.from(this.backends.keys());
+  }
+
+  async loadSettings(): Promise<void> {
+    const result = await window.electronAPI.preferencesGet('tts');
+    if (result.success && result.value && typeof result.value === 'object') {
+      this.settings = result.value as SpeechSettings;
+    }
+  }
+
+  getSettings(): SpeechSettings {
+    return this.settings;
+  }
+
+  isEnabled(): boolean {
+    return this.settings.enabled === true;
+  }
+
+  async updateSettings(updates: Partial<SpeechSettings>): Promise<void> {
+    if (updates.backend && !this.backends.has(updates.backend)) {
+      throw new Error(`Unknown speech backend "${updates.backend}". Available: ${this.getBackendNames().join(', ')}`);
+    }
+    this.settings = { ...this.settings, ...updates };
+    if (!this.settings.enabled) {
+      this.stop();
+    }
+    await window.electronAPI.preferencesSet('tts', this.settings);
+  }
+
+  /**
+   * Read a message aloud, interrupting anything already playing
+   */
+  async speak(markdown: string): Promise<void> {
+    if (!this.isEnabled()) {
+      return;
+    }
+    const text = toSpeakableText(markdown);
+    if (!text) {
+      return;
+    }
+
+    const backend = this.backends.get(this.settings.backend || DEFAULT_BACKEND);
+    if (!backend) {
+      console.error(`Speech backend "${this.settings.backend}" is not registered`);
+      return;
+    }
+
+    this.stop();
+    this.current = backend;
+    try {
+      await backend.speak(text, this.settings);
+    } catch (error) {
+      console.error(`Speech backend ${backend.name} failed:`, error);
+      throw error;
+    } finally {
+      if (this.current === backend) {
+        this.current = null;
+      }
+    }
+  }
+
+  stop() {
+    this.current?.stop();
+    this.current = null;
+  }
+}
+
+export const speechManager = new SpeechManager();
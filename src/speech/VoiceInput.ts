@@ -0,0 +1,114 @@
+import { startRecording, type Recording } from './recorder';
+
+// Push-to-talk dictation. Recording is toggled with /voice or Ctrl+Shift+Space;
+// the transcript goes to the input box for review instead of being sent.
+// Transcription settings are the "stt" preference:
+//
+// { "backend": "whisper-cpp", "whisperCpp": { "binary": "whisper-cli", "model": "~/models/ggml-base.en.bin" } }
+// { "backend": "http", "http": { "url": "http://localhost:8000/v1/audio/transcriptions", "model": "whisper-1" } }
+
+export type VoiceInputState = 'idle' | 'recording' | 'transcribing';
+
+export interface TranscriptionSettings {
+  backend?: 'whisper-cpp' | 'http';
+  language?: string;
+  whisperCpp?: {
+    binary?: string;
+    model?: string;
+  };
+  http?: {
+    url?: string;
+    model?: string;
+    apiKey?: string;
+  };
+}
+
+type Listener = (state: VoiceInputState) => void;
+type TranscriptListener = (text: string) => void;
+
+class VoiceInput {
+  private state: VoiceInputState = 'idle';
+  private recording: Recording | null = null;
+  private listeners: Set<Listener> = new Set();
+  private transcriptListeners: Set<TranscriptListener> = new Set();
+
+  getState(): VoiceInputState {
+    return this.state;
+  }
+
+  subscribe(listener: Listener): () => void {
+    this.listeners.add(listener);
+    return () => this.listeners.delete(listener);
+  }
+
+  onTranscript(listener: TranscriptListener): () => void {
+    this.transcriptListeners.add(listener);
+    return () => this.transcriptListeners.delete(listener);
+  }
+
+  private setState(state: VoiceInputState) {
+    this.state = state;
+    this.listeners.forEach(listener => listener(state));
+  }
+
+  async start(): Promise<void> {
+    if (this.state !== 'idle') {
+      return;
+    }
+    try {
+      this.recording = await startRecording();
+    } catch (error) {
+      throw new Error(`Could not access the microphone: ${error instanceof Error ? error.message : 'permission denied'}`);
+    }
+    this.setState('recording');
+  }
+
+  /**
+   * Stop recording and transcribe. Resolves with the text (also delivered to
+   * transcript listeners).
+   */
+  async stop(): Promise<string> {
+    if (this.state !== 'recording' || !this.recording) {
+      return '';
+    }
+    const recording = this.recording;
+    this.recording = null;
+    this.setState('transcribing');
+
+    try {
+      const audio = await recording.stop();
+      const prefs = await window.electronAPI.preferencesGet('stt');
+      const settings = (prefs.success && prefs.value ? prefs.value : {}) as TranscriptionSettings;
+      const result = await window.electronAPI.sttTranscribe(audio, settings);
+      if (!result.success) {
+        throw new Error(result.error || 'Transcription failed');
+      }
+      const text = (result.text || '').trim();
+      if (text) {
+        this.transcriptListeners.forEach(listener => listener(text));
+      }
+      return text;
+    } finally {
+      this.setState('idle');
+    }
+  }
+
+  async toggle(): Promise<string | void> {
+    if (this.state === 'idle') {
+      return this.start();
+    }
+    if (this.state === 'recording') {
+      return this.stop();
+    }
+  }
+
+  cancel() {
+    this.recording?.cancel();
+    this.recording = null;
+    if (this.state === 'recording') {
+      this.setState('idle');
+    }
+  }
+}
+
+export const voiceInput = new VoiceInput();
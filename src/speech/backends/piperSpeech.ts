@@ -0,0 +1,39 @@
+import type { SpeechBackend } from '../SpeechManager';
+
+let audio: HTMLAudioElement | null = null;
+let finish: (() => void) | null = null;
+
+// Local neural TTS: the main process runs piper and returns the WAV
+export const PiperSpeechBackend: SpeechBackend = {
+  name: 'piper',
+  description: 'Piper neural TTS (requires the piper binary and a voice model)',
+  speak: async (text, settings) => {
+    if (!settings.piper?.model) {
+      throw new Error('Set tts.piper.model to a piper voice (.onnx) to use the piper backend');
+    }
+
+    const result = await window.electronAPI.ttsSynthesize(text, {
+      binary: settings.piper.binary,
+      model: settings.piper.model,
+      rate: settings.rate,
+    });
+    if (!result.success || !result.audio) {
+      throw new Error(result.error || 'piper produced no audio');
+    }
+
+    await new Promise<void>((resolve, reject) => {
+      const player = new Audio(`data:audio/wav;base64,${result.audio}`);
+      audio = player;
+      finish = resolve;
+      player.onended = () => resolve();
+      player.onerror = () => reject(new Error('Failed to play synthesized audio'));
+      player.play().catch(reject);
+    });
+  },
+  stop: () => {
+    audio?.pause();
+    audio = null;
+    finish?.();
+    finish = null;
+  },
+};
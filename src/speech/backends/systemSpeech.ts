@@ -0,0 +1,32 @@
+import type { SpeechBackend } from '../SpeechManager';
+
+// The OS voices Chromium exposes through the Web Speech API
+export const SystemSpeechBackend: SpeechBackend = {
+  name: 'system',
+  description: 'Operating system voices (Web Speech API)',
+  speak: (text, settings) => new Promise((resolve, reject) => {
+    const utterance = new SpeechSynthesisUtterance(text);
+    if (settings.voice) {
+      const voice = window.speechSynthesis.getVoices().find(v => v.name === settings.voice);
+      if (voice) {
+        utterance.voice = voice;
+      }
+    }
+    if (settings.rate) {
+      utterance.rate = settings.rate;
+    }
+    utterance.onend = () => resolve();
+    utterance.onerror = (event) => {
+      // Cancelling playback reports "interrupted"/"canceled", which isn't a failure
+      if (event.error === 'interrupted' || event.error === 'canceled') {
+        resolve();
+      } else {
+        reject(new Error(`Speech synthesis failed: ${event.error}`));
+      }
+    };
+    window.speechSynthesis.speak(utterance);
+  }),
+  stop: () => {
+    window.speechSynthesis.cancel();
+  },
+};
@@ -0,0 +1,12 @@
+import { speechManager } from './SpeechManager';
+import { SystemSpeechBackend } from './backends/systemSpeech';
+import { PiperSpeechBackend } from './backends/piperSpeech';
+
+// Register all built-in speech backends
+export function initializeSpeech() {
+  speechManager.registerBackend(SystemSpeechBackend);
+  speechManager.registerBackend(PiperSpeechBackend);
+}
+
+export { speechManager };
+export { voiceInput, type VoiceInputState } from './VoiceInput';
@@ -0,0 +1,107 @@
+// Microphone capture as 16 kHz mono 16-bit WAV, the input whisper models expect
+
+const TARGET_SAMPLE_RATE = 16000;
+
+export interface Recording {
+  // Stop capturing and return the audio as base64 WAV
+  stop: () => Promise<string>;
+  // Stop capturing and discard the audio
+  cancel: () => void;
+}
+
+function encodeWav(samples: Float32Array, sampleRate: number): ArrayBuffer {
+  const buffer = new ArrayBuffer(44 + samples.length * 2);
+  const view = new DataView(buffer);
+  const writeString = (offset: number, text: string) => {
+    for (let i = 0; i < text.length; i++) {
+      view.setUint8(offset + i, text.charCodeAt(i));
+    }
+  };
+
+  writeString(0, 'RIFF');
+  view.setUint32(4, 36 + samples.length * 2, true);
+  writeString(8, 'WAVE');
+  writeString(12, 'fmt ');
+  view.setUint32(16, 16, true);
+  view.setUint16(20, 1, true); // PCM
+  view.setUint16(22, 1, true); // mono
+  view.setUint32(24, sampleRate, true);
+  view.setUint32(28, sampleRate * 2, true);
+  view.setUint16(32, 2, true);
+  view.setUint16(34, 16, true);
+  writeString(36, 'data');
+  view.setUint32(40, samples.length * 2, true);
+
+  for (let i = 0; i < samples.length; i++) {
+    const s = Math.max(-1, Math.min(1, samples[i]));
+    view.setInt16(44 + i * 2, s < 0 ? s * 0x8000 : s * 0x7fff, true);
+  }
+  return buffer;
+}
+
+async function resample(samples: Float32Array, fromRate: number): Promise<Float32Array> {
+  if (fromRate === TARGET_SAMPLE_RATE || samples.length === 0) {
+    return samples;
+  }
+  const length = Math.ceil((samples.length * TARGET_SAMPLE_RATE) / fromRate);
+  const offline = new OfflineAudioContext(1, length, TARGET_SAMPLE_RATE);
+  const source = offline.createBufferSource();
+  const input = offline.createBuffer(1, samples.length, fromRate);
+  input.copyToChannel(samples, 0);
+  source.buffer = input;
+  source.connect(offline.destination);
+  source.start();
+  const rendered = await offline.startRendering();
+  return rendered.getChannelData(0);
+}
+
+function toBase64(buffer: ArrayBuffer): string {
+  const bytes = new Uint8Array(buffer);
+  let binary = '';
+  const chunk = 0x8000;
+  for (let i = 0; i < bytes.length; i += chunk) {
+    binary += String.fromCharCode(...bytes.subarray(i, i + chunk));
+  }
+  return btoa(binary);
+}
+
+export async function startRecording(): Promise<Recording> {
+  const stream = await navigator.mediaDevices.getUserMedia({
+    audio: { channelCount: 1, echoCancellation: true, noiseSuppression: true },
+  });
+  const context = new AudioContext();
+  const source = context.createMediaStreamSource(stream);
+  // ScriptProcessor is deprecated but needs no separate worklet module
+  const processor = context.createScriptProcessor(4096, 1, 1);
+  const chunks: Float32Array[] = [];
+
+  processor.onaudioprocess = (event) => {
+    chunks.push(new Float32Array(event.inputBuffer.getChannelData(0)));
+  };
+  source.connect(processor);
+  processor.connect(context.destination);
+
+  const release = () => {
+    processor.disconnect();
+    source.disconnect();
+    stream.getTracks().forEach(track => track.stop());
+    context.close();
+  };
+
+  return {
+    stop: async () => {
+      const sampleRate = context.sampleRate;
+      release();
+      const total = chunks.reduce((sum, c) => sum + c.length, 0);
+      const samples = new Float32Array(total);
+      let offset = 0;
+      for (const c of chunks) {
+        samples.set(c, offset);
+        offset += c.length;
+      }
+      const resampled = await resample(samples, sampleRate);
+      return toBase64(encodeWav(resampled, TARGET_SAMPLE_RATE));
+    },
+    cancel: release,
+  };
+}
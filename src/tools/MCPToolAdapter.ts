@@ -1,10 +1,12 @@
-import type { Tool, ToolDefinition, ParameterSchema } from '../types/chat';
+import type { Tool, ToolDefinition, ToolExecutionContext, ParameterSchema } from '../types/chat';
 import type { MCPToolInfo, ToolPermission } from '../types/mcp';
+import { viewForData } from '../utils/toolResultView';
 
 export interface MCPToolMetadata {
   serverName: string;
   enabled: boolean;
   permission: ToolPermission;
+  remote: boolean; // Reached over the network (SSE), so off in offline mode
 }
 
 /**
@@ -24,6 +26,8 @@ function convertMCPSchemaToToolDefinition(
         description?: string;
         enum?: string[];
         items?: unknown;
+        format?: string;
+        writeOnly?: boolean;
       };
       
       const baseProperty: ParameterSchema = {
@@ -66,11 +70,23 @@ export function createMCPTool(
 ): Tool {
   const definition = convertMCPSchemaToToolDefinition(mcpTool, metadata.serverName);
 
+  // Servers mark secrets in their schema as writeOnly or with format "password"
+  const sensitiveParams = Object.entries(mcpTool.inputSchema.properties || {})
+    .filter(([, value]) => {
+      const prop = value as { format?: string; writeOnly?: boolean };
+      return prop.writeOnly === true || prop.format === 'password';
+    })
+    .map(([key]) => key);
+
   return {
     definition,
+    ...(sensitiveParams.length > 0 && { sensitiveParams }),
     requiresMainProcess: false,
+    // MCP servers are external processes that can do anything
+    modifiesWorkspace: true,
+    ...(metadata.remote && { requiresNetwork: true }),
     defaultPermission: metadata.permission,
-    execute: async (params: Record<string, unknown>) => {
+    execute: async (params: Record<string, unknown>, context?: ToolExecutionContext) => {
       // If permission is 'ask', we need to show a confirmation dialog
       // For now, we'll handle this in the chat container
       // The actual MCP call happens through the IPC
@@ -78,7 +94,8 @@ export function createMCPTool(
       const result = await window.electronAPI.mcpCallTool(
         metadata.serverName,
         mcpTool.name,
-        params
+        params,
+        context?.toolCallId
       );
 
       if (!result.success) {
@@ -93,6 +110,7 @@ export function createMCPTool(
           text?: string;
           [key: string]: unknown;
         }>;
+        structuredContent?: unknown;
         isError?: boolean;
       };
 
@@ -105,16 +123,28 @@ export function createMCPTool(
         );
       }
 
-      // Extract text content from the result
+      // Extract text content from the result. Images are returned separately
+      // (shown in the transcript) and leave a placeholder in the text.
       const textContent = mcpResult.content
-        .filter(c => c.type === 'text')
-        .map(c => c.text)
+        .filter(c => c.type === 'text' || c.type === 'image')
+        .map(c => c.type === 'image' ? `[image: ${c.mimeType || 'unknown type'}]` : c.text)
         .join('\n');
 
+      const images = mcpResult.content
+        .filter(c => c.type === 'image' && typeof c.data === 'string')
+        .map(c => ({ mimeType: (c.mimeType as string) || 'application/octet-stream', data: c.data as string }));
+
       return {
         success: true,
         content: textContent,
-        raw: mcpResult,
+        // Keep the base64 payload out of the raw copy the model sees
+        raw: {
+          ...mcpResult,
+          content: mcpResult.content.map(c => c.type === 'image' ? { type: 'image', mimeType: c.mimeType } : c),
+        },
+        ...(images.length > 0 && { images }),
+        // Servers that return structuredContent get it shown as a table or JSON
+        ...(mcpResult.structuredContent !== undefined && { view: viewForData(mcpResult.structuredContent) }),
       };
     },
   };
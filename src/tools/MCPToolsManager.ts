@@ -148,10 +148,12 @@ class MCPToolsManager {
         const fullToolName = `${status.name}__${toolInfo.name}`;
 
         try {
+          const serverConfig = this.config.mcpServers[status.name];
           const tool = createMCPTool(toolInfo, {
             serverName: status.name,
             enabled: toolConfig.enabled,
             permission: toolConfig.permission,
+            remote: serverConfig?.transport === 'sse' || (!serverConfig?.transport && !!serverConfig?.url),
           });
 
           toolRegistry.register(tool);
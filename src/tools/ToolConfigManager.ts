@@ -6,6 +6,20 @@ export interface ToolConfig {
   permission: ToolPermission;
   isBuiltIn: boolean;
   serverName?: string; // For MCP tools
+  timeout?: number; // Seconds before a call fails; unset uses the tool's default
+  cacheTtl?: number; // Seconds identical calls reuse a result; 0 disables, unset uses the tool's default
+  sensitiveParams?: string[]; // Masked in the transcript and logs, added to the ones the tool declares
+  maxResultChars?: number; // Longer results are shortened for the model; 0 disables, unset uses the toolResultLimit preference
+}
+
+// How a tool's settings are written to tools.json and mcp.json
+interface StoredToolConfig {
+  enabled: boolean;
+  permission: ToolPermission;
+  timeout?: number;
+  cacheTtl?: number;
+  sensitiveParams?: string[];
+  maxResultChars?: number;
 }
 
 class ToolConfigManager {
@@ -22,18 +36,22 @@ class ToolConfigManager {
       // Load MCP config (backend now returns YAML)
       const mcpResult = await window.electronAPI.configRead('mcp.json');
       if (mcpResult.success && mcpResult.content) {
-        const mcpData = yaml.load(mcpResult.content) as { toolSettings?: Record<string, Record<string, { enabled: boolean; permission: ToolPermission }>> };
+        const mcpData = yaml.load(mcpResult.content) as { toolSettings?: Record<string, Record<string, StoredToolConfig>> };
 
         // Load MCP tool settings
         if (mcpData.toolSettings) {
           for (const [serverName, tools] of Object.entries(mcpData.toolSettings)) {
-            for (const [toolName, config] of Object.entries(tools as Record<string, { enabled: boolean; permission: ToolPermission }>)) {
+            for (const [toolName, config] of Object.entries(tools as Record<string, StoredToolConfig>)) {
               const fullName = `${serverName}__${toolName}`;
               this.configs.set(fullName, {
                 enabled: config.enabled,
                 permission: config.permission,
                 isBuiltIn: false,
                 serverName,
+                ...(typeof config.timeout === 'number' && { timeout: config.timeout }),
+                ...(typeof config.cacheTtl === 'number' && { cacheTtl: config.cacheTtl }),
+                ...(Array.isArray(config.sensitiveParams) && { sensitiveParams: config.sensitiveParams }),
+                ...(typeof config.maxResultChars === 'number' && { maxResultChars: config.maxResultChars }),
               });
             }
           }
@@ -45,11 +63,15 @@ class ToolConfigManager {
       if (builtInResult.success && builtInResult.content) {
         this.lastToolsJsonContent = builtInResult.content;
         const builtInData = JSON.parse(builtInResult.content);
-        for (const [toolName, config] of Object.entries(builtInData as Record<string, { enabled: boolean; permission: ToolPermission }>)) {
+        for (const [toolName, config] of Object.entries(builtInData as Record<string, StoredToolConfig>)) {
           this.configs.set(toolName, {
             enabled: config.enabled,
             permission: config.permission,
             isBuiltIn: true,
+            ...(typeof config.timeout === 'number' && { timeout: config.timeout }),
+            ...(typeof config.cacheTtl === 'number' && { cacheTtl: config.cacheTtl }),
+            ...(Array.isArray(config.sensitiveParams) && { sensitiveParams: config.sensitiveParams }),
+            ...(typeof config.maxResultChars === 'number' && { maxResultChars: config.maxResultChars }),
           });
         }
         configsLoaded = true;
@@ -87,6 +109,9 @@ class ToolConfigManager {
       move: 'ask',
       rm: 'ask',
       mkdir: 'ask',
+      watch_path: 'ask',
+      web_search: 'ask',
+      fetch_url: 'ask',
     };
 
     // Set defaults in memory
@@ -120,14 +145,18 @@ class ToolConfigManager {
 
     try {
       // Separate built-in and MCP configs
-      const builtInConfigs: Record<string, { enabled: boolean; permission: ToolPermission }> = {};
-      const mcpConfigs: Record<string, Record<string, { enabled: boolean; permission: ToolPermission }>> = {};
+      const builtInConfigs: Record<string, StoredToolConfig> = {};
+      const mcpConfigs: Record<string, Record<string, StoredToolConfig>> = {};
 
       for (const [toolName, config] of this.configs.entries()) {
         if (config.isBuiltIn) {
           builtInConfigs[toolName] = {
             enabled: config.enabled,
             permission: config.permission,
+            ...(typeof config.timeout === 'number' && { timeout: config.timeout }),
+            ...(typeof config.cacheTtl === 'number' && { cacheTtl: config.cacheTtl }),
+            ...(Array.isArray(config.sensitiveParams) && { sensitiveParams: config.sensitiveParams }),
+            ...(typeof config.maxResultChars === 'number' && { maxResultChars: config.maxResultChars }),
           };
         } else if (config.serverName) {
           if (!mcpConfigs[config.serverName]) {
@@ -138,6 +167,10 @@ class ToolConfigManager {
           mcpConfigs[config.serverName][shortName] = {
             enabled: config.enabled,
             permission: config.permission,
+            ...(typeof config.timeout === 'number' && { timeout: config.timeout }),
+            ...(typeof config.cacheTtl === 'number' && { cacheTtl: config.cacheTtl }),
+            ...(Array.isArray(config.sensitiveParams) && { sensitiveParams: config.sensitiveParams }),
+            ...(typeof config.maxResultChars === 'number' && { maxResultChars: config.maxResultChars }),
           };
         }
       }
@@ -150,16 +183,16 @@ class ToolConfigManager {
       }
 
       // Save MCP configs (merge with existing mcp.yaml, but use cached content if available)
-      let mcpData: { mcpServers?: Record<string, unknown>; toolSettings: Record<string, Record<string, { enabled: boolean; permission: ToolPermission }>> } = { mcpServers: {}, toolSettings: mcpConfigs };
+      let mcpData: { mcpServers?: Record<string, unknown>; toolSettings: Record<string, Record<string, StoredToolConfig>> } = { mcpServers: {}, toolSettings: mcpConfigs };
       if (this.lastMcpJsonContent) {
         try {
-          const existing = yaml.load(this.lastMcpJsonContent) as { mcpServers?: Record<string, unknown>; toolSettings?: Record<string, Record<string, { enabled: boolean; permission: ToolPermission }>> };
+          const existing = yaml.load(this.lastMcpJsonContent) as { mcpServers?: Record<string, unknown>; toolSettings?: Record<string, Record<string, StoredToolConfig>> };
           mcpData = { ...existing, toolSettings: mcpConfigs };
         } catch (e) {
           // If cached content is invalid, read fresh
           const mcpResult = await window.electronAPI.configRead('mcp.json');
           if (mcpResult.success && mcpResult.content) {
-            const existing = yaml.load(mcpResult.content) as { mcpServers?: Record<string, unknown>; toolSettings?: Record<string, Record<string, { enabled: boolean; permission: ToolPermission }>> };
+            const existing = yaml.load(mcpResult.content) as { mcpServers?: Record<string, unknown>; toolSettings?: Record<string, Record<string, StoredToolConfig>> };
             mcpData = { ...existing, toolSettings: mcpConfigs };
             this.lastMcpJsonContent = mcpResult.content;
           }
@@ -168,7 +201,7 @@ class ToolConfigManager {
         // No cached content, read fresh
         const mcpResult = await window.electronAPI.configRead('mcp.json');
         if (mcpResult.success && mcpResult.content) {
-          const existing = yaml.load(mcpResult.content) as { mcpServers?: Record<string, unknown>; toolSettings?: Record<string, Record<string, { enabled: boolean; permission: ToolPermission }>> };
+          const existing = yaml.load(mcpResult.content) as { mcpServers?: Record<string, unknown>; toolSettings?: Record<string, Record<string, StoredToolConfig>> };
           mcpData = { ...existing, toolSettings: mcpConfigs };
           this.lastMcpJsonContent = mcpResult.content;
         }
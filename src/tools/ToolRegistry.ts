@@ -1,8 +1,49 @@
 import type { Tool, ToolDefinition } from '../types/chat';
 import { toolConfigManager } from './ToolConfigManager';
+import { askUser } from './userInput';
+import { argumentErrorResult, bindToolArguments } from './toolArguments';
+import { recordEngineEvent } from '../utils/engineEvents';
+
+// For tools without a timeout in tools.json or mcp.json
+const DEFAULT_TOOL_TIMEOUT_MS = 5 * 60 * 1000;
+// Bash enforces the timeout it is called with; this leaves it time to report it
+const BASH_TIMEOUT_GRACE_MS = 5000;
+
+// JSON with object keys sorted, so argument order doesn't change a cache key
+const stableStringify = (value: unknown): string => {
+  if (Array.isArray(value)) {
+    return `[${value.map(stableStringify).join(',')}]`;
+  }
+  if (value && typeof value === 'object') {
+    const entries = Object.entries(value as Record<string, unknown>).sort(([a], [b]) => a.localeCompare(b));
+    return `{${entries.map(([key, v]) => `${JSON.stringify(key)}:${stableStringify(v)}`).join(',')}}`;
+  }
+  return JSON.stringify(value) ?? 'null';
+};
+
+interface CachedResult {
+  result: unknown;
+  expiresAt: number;
+}
 
 class ToolRegistry {
   private tools: Map<string, Tool> = new Map();
+  private offlineMode = false;
+  // Until the trust decision is read, a workspace is restricted
+  private workspaceTrusted = false;
+  private resultCache: Map<string, CachedResult> = new Map();
+
+  setOfflineMode(enabled: boolean) {
+    this.offlineMode = enabled;
+  }
+
+  setWorkspaceTrusted(trusted: boolean) {
+    this.workspaceTrusted = trusted;
+  }
+
+  private isAvailable(tool: Tool): boolean {
+    return !(this.offlineMode && tool.requiresNetwork) && !(!this.workspaceTrusted && tool.modifiesWorkspace);
+  }
 
   register(tool: Tool) {
     this.tools.set(tool.definition.function.name, tool);
@@ -20,6 +61,9 @@ class ToolRegistry {
     // Only return definitions for enabled tools
     return Array.from(this.tools.values())
       .filter(t => {
+        if (!this.isAvailable(t)) {
+          return false;
+        }
         const toolName = t.definition.function.name;
         const config = toolConfigManager.getConfig(toolName, t.defaultPermission);
         return config.enabled;
@@ -31,7 +75,8 @@ class ToolRegistry {
     return Array.from(this.tools.values());
   }
 
-  async execute(toolName: string, params: Record<string, unknown>, projectPath?: string): Promise<unknown> {
+  // toolCallId lets tools that stream progress (bash) tag their live output
+  async execute(toolName: string, rawParams: Record<string, unknown>, projectPath?: string, toolCallId?: string): Promise<unknown> {
     const tool = this.tools.get(toolName);
     if (!tool) {
       throw new Error(`Tool "${toolName}" not found in registry`);
@@ -43,6 +88,43 @@ class ToolRegistry {
       throw new Error(`Tool "${toolName}" is disabled`);
     }
 
+    if (!this.workspaceTrusted && tool.modifiesWorkspace) {
+      throw new Error(`Tool "${toolName}" is disabled because this workspace isn't trusted (/trust yes enables it)`);
+    }
+
+    if (!this.isAvailable(tool)) {
+      throw new Error(`Tool "${toolName}" needs network access and is unavailable in offline mode`);
+    }
+
+    const { args: params, errors } = bindToolArguments(tool.definition, rawParams);
+    if (errors.length > 0) {
+      return argumentErrorResult(toolName, errors);
+    }
+
+    const cacheTtlMs = (config.cacheTtl ?? tool.cacheTtl ?? 0) * 1000;
+    if (cacheTtlMs <= 0) {
+      return await this.run(tool, toolName, params, projectPath, toolCallId);
+    }
+
+    const cacheKey = `${toolName}\n${projectPath ?? ''}\n${stableStringify(params)}`;
+    const cached = this.resultCache.get(cacheKey);
+    if (cached && cached.expiresAt > Date.now()) {
+      recordEngineEvent('cache', 'tools', 'Tool result from cache', {
+        tool: toolName,
+        expiresIn: `${Math.ceil((cached.expiresAt - Date.now()) / 1000)}s`,
+      });
+      return cached.result;
+    }
+    const result = await this.run(tool, toolName, params, projectPath, toolCallId);
+    // Failures are worth retrying, so only successful results are kept
+    const failed = !!result && typeof result === 'object' && (result as { success?: unknown }).success === false;
+    if (!failed) {
+      this.resultCache.set(cacheKey, { result, expiresAt: Date.now() + cacheTtlMs });
+    }
+    return result;
+  }
+
+  private async run(tool: Tool, toolName: string, params: Record<string, unknown>, projectPath?: string, toolCallId?: string): Promise<unknown> {
     if (tool.requiresMainProcess) {
       // Internal tools require projectPath
       if (!projectPath) {
@@ -62,7 +144,7 @@ class ToolRegistry {
         case 'grep':
           return await window.electronAPI.internalToolGrep(projectPath, params as any);
         case 'bash':
-          return await window.electronAPI.internalToolBash(projectPath, params as any);
+          return await window.electronAPI.internalToolBash(projectPath, params as any, toolCallId);
         case 'ls':
           return await window.electronAPI.internalToolLs(projectPath, params as any);
         case 'rm':
@@ -71,6 +153,12 @@ class ToolRegistry {
           return await window.electronAPI.internalToolMove(projectPath, params as any);
         case 'mkdir':
           return await window.electronAPI.internalToolMkdir(projectPath, params as any);
+        case 'web_search':
+          return await window.electronAPI.internalToolWebSearch(params as any, toolCallId);
+        case 'fetch_url':
+          return await window.electronAPI.internalToolFetchUrl(params as any, toolCallId);
+        case 'watch_path':
+          return await window.electronAPI.watchStart(projectPath, params as any);
         default:
           // For other tools that require main process (future expansion)
           return await window.electronAPI.executeTool(toolName, params);
@@ -78,7 +166,45 @@ class ToolRegistry {
     }
 
     // Execute in renderer process
-    return await tool.execute(params);
+    return await tool.execute(params, {
+      toolCallId,
+      ...(toolCallId && { askUser: (question: string, choices?: string[]) => askUser(toolCallId, question, choices) }),
+    });
+  }
+
+  // Declared by the tool or listed in its settings
+  getSensitiveParams(toolName: string): string[] {
+    const declared = this.tools.get(toolName)?.sensitiveParams || [];
+    const configured = toolConfigManager.getConfig(toolName).sensitiveParams || [];
+    return configured.length > 0 ? Array.from(new Set([...declared, ...configured])) : declared;
+  }
+
+  // Number of unexpired cached results
+  getCacheSize(): number {
+    const now = Date.now();
+    for (const [key, entry] of this.resultCache) {
+      if (entry.expiresAt <= now) {
+        this.resultCache.delete(key);
+      }
+    }
+    return this.resultCache.size;
+  }
+
+  clearCache() {
+    this.resultCache.clear();
+  }
+
+  // How long a call may run before it fails with a timeout error
+  getTimeoutMs(toolName: string, params: Record<string, unknown>): number {
+    const configured = toolConfigManager.getConfig(toolName).timeout;
+    if (configured) {
+      return configured * 1000;
+    }
+    if (toolName === 'bash') {
+      const requested = typeof params.timeout === 'number' ? params.timeout : 120000;
+      return Math.min(requested, 600000) + BASH_TIMEOUT_GRACE_MS;
+    }
+    return DEFAULT_TOOL_TIMEOUT_MS;
   }
 
   requiresPermission(toolName: string): boolean {
@@ -9,6 +9,9 @@ import { LsTool } from './tools/LsTool';
 import { MoveTool } from './tools/MoveTool';
 import { RmTool } from './tools/RmTool';
 import { MkdirTool } from './tools/MkdirTool';
+import { WatchPathTool } from './tools/WatchPathTool';
+import { WebSearchTool } from './tools/WebSearchTool';
+import { FetchUrlTool } from './tools/FetchUrlTool';
 
 // Register all tools
 export function initializeTools() {
@@ -25,6 +28,13 @@ export function initializeTools() {
   toolRegistry.register(MoveTool);
   toolRegistry.register(RmTool);
   toolRegistry.register(MkdirTool);
+
+  // Reports changes back into the conversation
+  toolRegistry.register(WatchPathTool);
+
+  // Network
+  toolRegistry.register(WebSearchTool);
+  toolRegistry.register(FetchUrlTool);
 }
 
 export { toolRegistry };
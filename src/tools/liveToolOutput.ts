@@ -0,0 +1,55 @@
+import { useEffect, useState } from 'react';
+
+// Output streamed by running tools, keyed by tool call id. Entries are kept
+// until the tool result replaces them in the transcript.
+
+export interface LiveToolOutput {
+  stdout: string;
+  stderr: string;
+}
+
+const outputs = new Map<string, LiveToolOutput>();
+const listeners = new Map<string, Set<(output: LiveToolOutput) => void>>();
+let unsubscribeIpc: (() => void) | null = null;
+
+function ensureSubscribed() {
+  if (unsubscribeIpc) {
+    return;
+  }
+  unsubscribeIpc = window.electronAPI.onToolOutput(({ toolCallId, stream, chunk }) => {
+    const previous = outputs.get(toolCallId) || { stdout: '', stderr: '' };
+    const next = { ...previous, [stream]: previous[stream] + chunk };
+    outputs.set(toolCallId, next);
+    listeners.get(toolCallId)?.forEach(listener => listener(next));
+  });
+}
+
+/**
+ * Live output for a tool call that is still running, or null before any arrives
+ */
+export function useLiveToolOutput(toolCallId: string | undefined, active: boolean): LiveToolOutput | null {
+  const [output, setOutput] = useState<LiveToolOutput | null>(() => (toolCallId ? outputs.get(toolCallId) ?? null : null));
+
+  useEffect(() => {
+    if (!toolCallId) {
+      return;
+    }
+    if (!active) {
+      outputs.delete(toolCallId);
+      return;
+    }
+
+    ensureSubscribed();
+    const set = listeners.get(toolCallId) || new Set();
+    set.add(setOutput);
+    listeners.set(toolCallId, set);
+    return () => {
+      set.delete(setOutput);
+      if (set.size === 0) {
+        listeners.delete(toolCallId);
+      }
+    };
+  }, [toolCallId, active]);
+
+  return active ? output : null;
+}
@@ -0,0 +1,33 @@
+import { toolRegistry } from './ToolRegistry';
+
+// Tools can mark parameters as sensitive (tokens, passwords). The tool still
+// receives the real values; the transcript, exports and logs show this instead.
+export const REDACTED = '••••••';
+
+/**
+ * Copy of a tool call's arguments with its sensitive parameters masked
+ */
+export function redactToolArgs(toolName: string, args: Record<string, unknown>): Record<string, unknown> {
+  const sensitive = toolRegistry.getSensitiveParams(toolName);
+  if (sensitive.length === 0 || !args || typeof args !== 'object') {
+    return args;
+  }
+  return Object.fromEntries(
+    Object.entries(args).map(([key, value]) => [key, sensitive.includes(key) && value !== undefined ? REDACTED : value])
+  );
+}
+
+/**
+ * Same as redactToolArgs for arguments still in their JSON string form. Strings
+ * that don't parse are returned as they are.
+ */
+export function redactToolArgsJson(toolName: string, args: string): string {
+  if (toolRegistry.getSensitiveParams(toolName).length === 0) {
+    return args;
+  }
+  try {
+    return JSON.stringify(redactToolArgs(toolName, JSON.parse(args)));
+  } catch {
+    return args;
+  }
+}
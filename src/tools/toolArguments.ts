@@ -0,0 +1,127 @@
+import type { ParameterSchema, ToolDefinition } from '../types/chat';
+
+// Arguments from the model are checked against the tool's parameter schema
+// before the tool runs. Obvious slips are corrected (the number 5 sent as "5");
+// anything else comes back to the model as a list of errors it can act on.
+
+export interface ToolArgumentError {
+  path: string; // "timeout", "files[2]"
+  message: string;
+}
+
+const typeOf = (value: unknown): string => {
+  if (value === null) {
+    return 'null';
+  }
+  if (Array.isArray(value)) {
+    return 'array';
+  }
+  if (typeof value === 'number' && Number.isInteger(value)) {
+    return 'integer';
+  }
+  return typeof value;
+};
+
+// The value converted to `type` when the model sent it as a string, or undefined
+function coerce(value: unknown, type: string): unknown {
+  if (typeof value !== 'string') {
+    return undefined;
+  }
+  const trimmed = value.trim();
+  if ((type === 'number' || type === 'integer') && trimmed !== '' && !Number.isNaN(Number(trimmed))) {
+    const number = Number(trimmed);
+    return type === 'integer' && !Number.isInteger(number) ? undefined : number;
+  }
+  if (type === 'boolean' && (trimmed === 'true' || trimmed === 'false')) {
+    return trimmed === 'true';
+  }
+  if ((type === 'array' || type === 'object') && /^[[{]/.test(trimmed)) {
+    try {
+      const parsed = JSON.parse(trimmed);
+      return typeOf(parsed) === type ? parsed : undefined;
+    } catch {
+      return undefined;
+    }
+  }
+  return undefined;
+}
+
+function matchesType(value: unknown, type: string): boolean {
+  const actual = typeOf(value);
+  return actual === type || (type === 'number' && actual === 'integer');
+}
+
+function checkValue(value: unknown, schema: ParameterSchema, path: string, errors: ToolArgumentError[]): unknown {
+  // Schemas from MCP servers can list several types (["string", "null"]); those are left to the server
+  if (typeof schema.type !== 'string') {
+    return value;
+  }
+
+  let checked = value;
+  if (!matchesType(checked, schema.type)) {
+    const coerced = coerce(checked, schema.type);
+    if (coerced === undefined) {
+      errors.push({ path, message: `expected ${schema.type}, got ${typeOf(value)}` });
+      return value;
+    }
+    checked = coerced;
+  }
+
+  if (schema.enum && !schema.enum.includes(checked as string)) {
+    errors.push({ path, message: `must be one of ${schema.enum.map(v => JSON.stringify(v)).join(', ')}` });
+  }
+  if (schema.type === 'array' && schema.items && Array.isArray(checked)) {
+    checked = checked.map((item, index) => checkValue(item, schema.items as ParameterSchema, `${path}[${index}]`, errors));
+  }
+  if (schema.type === 'object' && schema.properties && checked && typeof checked === 'object') {
+    checked = checkObject(checked as Record<string, unknown>, schema.properties, schema.required || [], `${path}.`, errors);
+  }
+  return checked;
+}
+
+function checkObject(
+  value: Record<string, unknown>,
+  properties: Record<string, ParameterSchema>,
+  required: string[],
+  prefix: string,
+  errors: ToolArgumentError[]
+): Record<string, unknown> {
+  const result: Record<string, unknown> = { ...value };
+  for (const name of required) {
+    if (value[name] === undefined || value[name] === null) {
+      errors.push({ path: `${prefix}${name}`, message: 'is required' });
+    }
+  }
+  for (const [name, schema] of Object.entries(properties)) {
+    // Unknown properties are passed through; models add them often and tools ignore them
+    if (value[name] !== undefined && value[name] !== null) {
+      result[name] = checkValue(value[name], schema, `${prefix}${name}`, errors);
+    }
+  }
+  return result;
+}
+
+/**
+ * Check arguments against a tool's parameter schema. Returns the arguments with
+ * string-encoded numbers, booleans and JSON converted, and every problem found.
+ */
+export function bindToolArguments(
+  definition: ToolDefinition,
+  args: Record<string, unknown>
+): { args: Record<string, unknown>; errors: ToolArgumentError[] } {
+  const errors: ToolArgumentError[] = [];
+  const { properties, required } = definition.function.parameters;
+  const bound = checkObject(args || {}, properties || {}, required || [], '', errors);
+  return { args: bound, errors };
+}
+
+/**
+ * Result returned to the model instead of running the tool
+ */
+export function argumentErrorResult(toolName: string, errors: ToolArgumentError[]) {
+  return {
+    success: false,
+    error: `Invalid arguments for ${toolName}: ${errors.map(e => `${e.path} ${e.message}`).join('; ')}`,
+    validation_errors: errors,
+  };
+}
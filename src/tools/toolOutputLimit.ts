@@ -0,0 +1,144 @@
+// Tool results over the size limit are cut down before they go into the
+// conversation: the longest strings in the result keep their start and end
+// around a marker saying how much was left out. The full result stays in
+// memory, until the app restarts, for /toolout.
+
+export const DEFAULT_TOOL_RESULT_CHARS = 32000;
+
+// Never cut a string below this, so the model still sees what it was
+const MIN_KEPT_CHARS = 400;
+const MAX_KEPT_OUTPUTS = 50;
+
+export interface TruncatedOutput {
+  toolCallId: string;
+  toolName: string;
+  result: unknown; // The whole result, as the post-tool-call hooks left it
+  originalChars: number;
+  keptChars: number;
+}
+
+const fullOutputs = new Map<string, TruncatedOutput>();
+
+const formatCount = (n: number) => n.toLocaleString('en-US');
+
+/**
+ * About `keep` characters of text: the first two thirds and the last third,
+ * cut at line breaks where one is near
+ */
+export function headTail(text: string, keep: number): string {
+  if (text.length <= keep) {
+    return text;
+  }
+  let headEnd = Math.floor(keep * 2 / 3);
+  let tailStart = text.length - (keep - headEnd);
+  const newlineBefore = text.lastIndexOf('\n', headEnd);
+  if (newlineBefore > headEnd * 0.8) {
+    headEnd = newlineBefore + 1;
+  }
+  const newlineAfter = text.indexOf('\n', tailStart);
+  if (newlineAfter >= 0 && newlineAfter < tailStart + (text.length - tailStart) * 0.2) {
+    tailStart = newlineAfter + 1;
+  }
+  const omitted = text.substring(headEnd, tailStart);
+  const lines = omitted.split('\n').length - 1;
+  const what = lines > 0 ? `${formatCount(lines)} lines, ${formatCount(omitted.length)} characters` : `${formatCount(omitted.length)} characters`;
+  return `${text.substring(0, headEnd)}\n[… ${what} omitted …]\n${text.substring(tailStart)}`;
+}
+
+/**
+ * Whether text went through headTail
+ */
+export const isShortened = (text: string): boolean => /\n\[… [\d,]+ (?:lines, [\d,]+ )?characters omitted …\]\n/.test(text);
+
+type Path = Array<string | number>;
+
+function longestString(value: unknown, path: Path = []): { path: Path; text: string } | null {
+  if (typeof value === 'string') {
+    return { path, text: value };
+  }
+  let longest: { path: Path; text: string } | null = null;
+  const entries: Array<[string | number, unknown]> = Array.isArray(value)
+    ? value.map((item, i) => [i, item])
+    : value && typeof value === 'object' ? Object.entries(value as Record<string, unknown>) : [];
+  for (const [key, item] of entries) {
+    const found = longestString(item, [...path, key]);
+    if (found && (!longest || found.text.length > longest.text.length)) {
+      longest = found;
+    }
+  }
+  return longest;
+}
+
+function withString(value: unknown, path: Path, text: string): unknown {
+  if (path.length === 0) {
+    return text;
+  }
+  const [key, ...rest] = path;
+  if (Array.isArray(value)) {
+    return value.map((item, i) => (i === key ? withString(item, rest, text) : item));
+  }
+  const record = value as Record<string, unknown>;
+  return { ...record, [key]: withString(record[key], rest, text) };
+}
+
+/**
+ * The result as it should go to the model: unchanged when its JSON fits in
+ * maxChars (0 for no limit), otherwise with its longest strings shortened
+ */
+export function limitToolResult(result: unknown, maxChars: number): { result: unknown; truncated: boolean; originalChars: number; keptChars: number } {
+  const serialized = JSON.stringify(result) ?? '';
+  if (maxChars <= 0 || serialized.length <= maxChars) {
+    return { result, truncated: false, originalChars: serialized.length, keptChars: serialized.length };
+  }
+
+  let limited = result;
+  let size = serialized.length;
+  // Escaping makes a string longer in JSON than it is, so it may take a few passes
+  for (let pass = 0; pass < 10 && size > maxChars; pass++) {
+    const longest = longestString(limited);
+    if (!longest || longest.text.length <= MIN_KEPT_CHARS) {
+      break;
+    }
+    const keep = Math.max(MIN_KEPT_CHARS, longest.text.length - (size - maxChars) - 100);
+    limited = withString(limited, longest.path, headTail(longest.text, keep));
+    size = (JSON.stringify(limited) ?? '').length;
+  }
+  if (size > maxChars) {
+    // Too many small values, e.g. a huge list: cut the JSON itself
+    limited = { truncated_result: headTail(serialized, Math.max(MIN_KEPT_CHARS, maxChars - 200)) };
+    size = JSON.stringify(limited).length;
+  }
+  return { result: limited, truncated: true, originalChars: serialized.length, keptChars: size };
+}
+
+export function keepFullOutput(output: TruncatedOutput) {
+  fullOutputs.set(output.toolCallId, output);
+  // Oldest first, so the first key is the one to drop
+  while (fullOutputs.size > MAX_KEPT_OUTPUTS) {
+    fullOutputs.delete(fullOutputs.keys().next().value as string);
+  }
+}
+
+export function getFullOutput(toolCallId: string): TruncatedOutput | undefined {
+  return fullOutputs.get(toolCallId);
+}
+
+/**
+ * A result as text for reading: a shell command's output, a file's content,
+ * or the JSON laid out
+ */
+export function toolOutputText(result: unknown): string {
+  if (typeof result === 'string') {
+    return result;
+  }
+  if (result && typeof result === 'object') {
+    const record = result as Record<string, unknown>;
+    if (typeof record.stdout === 'string' || typeof record.stderr === 'string') {
+      return [record.stdout, record.stderr].filter((part): part is string => typeof part === 'string' && part.length > 0).join('\n');
+    }
+    if (typeof record.content === 'string') {
+      return record.content;
+    }
+  }
+  return JSON.stringify(result, null, 2) ?? '';
+}
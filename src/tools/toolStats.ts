@@ -0,0 +1,50 @@
+// Calls, failures and timings per tool since the window opened, shown by
+// /tools stats. Timings cover the tool itself, not permission prompts or hooks.
+
+export interface ToolStats {
+  calls: number;
+  failures: number;
+  totalMs: number;
+  maxMs: number;
+  lastError?: string;
+}
+
+/**
+ * Error of a tool that reported failure in its result rather than by throwing
+ */
+export function resultError(result: unknown): string | undefined {
+  if (!result || typeof result !== 'object' || (result as { success?: unknown }).success !== false) {
+    return undefined;
+  }
+  const error = (result as { error?: unknown }).error;
+  return typeof error === 'string' && error ? error : 'Tool reported failure';
+}
+
+class ToolStatsTracker {
+  private stats: Map<string, ToolStats> = new Map();
+
+  record(toolName: string, durationMs: number, error?: string) {
+    const stats = this.stats.get(toolName) || { calls: 0, failures: 0, totalMs: 0, maxMs: 0 };
+    stats.calls++;
+    stats.totalMs += durationMs;
+    stats.maxMs = Math.max(stats.maxMs, durationMs);
+    if (error !== undefined) {
+      stats.failures++;
+      stats.lastError = error;
+    }
+    this.stats.set(toolName, stats);
+  }
+
+  // Most used first
+  getAll(): Array<{ toolName: string; stats: ToolStats }> {
+    return Array.from(this.stats.entries())
+      .map(([toolName, stats]) => ({ toolName, stats: { ...stats } }))
+      .sort((a, b) => b.stats.calls - a.stats.calls || a.toolName.localeCompare(b.toolName));
+  }
+
+  reset() {
+    this.stats.clear();
+  }
+}
+
+export const toolStats = new ToolStatsTracker();
@@ -28,6 +28,7 @@ export const BashTool: Tool = {
   },
 
   requiresMainProcess: true,
+  modifiesWorkspace: true,
   defaultPermission: 'ask',
 
   async execute() {
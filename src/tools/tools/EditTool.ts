@@ -32,6 +32,7 @@ export const EditTool: Tool = {
   },
 
   requiresMainProcess: true,
+  modifiesWorkspace: true,
   defaultPermission: 'ask',
 
   async execute() {
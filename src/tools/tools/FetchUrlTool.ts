@@ -0,0 +1,38 @@
+import type { Tool } from '../../types/chat';
+
+export const FetchUrlTool: Tool = {
+  definition: {
+    type: 'function',
+    function: {
+      name: 'fetch_url',
+      description: 'Downloads a web page and returns its readable text, with scripts, navigation, headers and footers removed. Use it to read documentation or a page found with web_search. Plain text and JSON are returned as-is. Long pages are truncated; "truncated" is true when text was left out.',
+      parameters: {
+        type: 'object',
+        properties: {
+          url: {
+            type: 'string',
+            description: 'The http or https URL to fetch',
+          },
+          max_length: {
+            type: 'integer',
+            description: 'Maximum number of characters of text to return (default 20000, at most 100000)',
+          },
+          timeout: {
+            type: 'number',
+            description: 'Seconds to wait for the page (default 20)',
+          },
+        },
+        required: ['url'],
+      },
+    },
+  },
+
+  requiresMainProcess: true,
+  requiresNetwork: true,
+  defaultPermission: 'ask',
+
+  async execute() {
+    // This will be executed in the main process via IPC
+    throw new Error('Fetch URL tool must be executed in main process');
+  },
+};
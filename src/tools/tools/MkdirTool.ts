@@ -20,6 +20,7 @@ export const MkdirTool: Tool = {
   },
 
   requiresMainProcess: true,
+  modifiesWorkspace: true,
   defaultPermission: 'ask',
 
   async execute() {
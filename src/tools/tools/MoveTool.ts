@@ -24,6 +24,7 @@ export const MoveTool: Tool = {
   },
 
   requiresMainProcess: true,
+  modifiesWorkspace: true,
   defaultPermission: 'ask',
 
   async execute() {
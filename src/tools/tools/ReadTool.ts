@@ -5,7 +5,7 @@ export const ReadTool: Tool = {
     type: 'function',
     function: {
       name: 'read',
-      description: 'Reads a file from the project directory. The path parameter must start with / representing the root of the project directory. For example: /src/App.tsx or /README.md',
+      description: 'Reads a file from the project directory. The path parameter must start with / representing the root of the project directory. For example: /src/App.tsx or /README.md. Files over 1 MB must be read in parts with offset and limit, and binary files are rejected.',
       parameters: {
         type: 'object',
         properties: {
@@ -24,6 +24,7 @@ export const RmTool: Tool = {
   },
 
   requiresMainProcess: true,
+  modifiesWorkspace: true,
   defaultPermission: 'ask',
 
   async execute() {
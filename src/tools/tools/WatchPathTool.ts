@@ -0,0 +1,41 @@
+import type { Tool } from '../../types/chat';
+
+export const WatchPathTool: Tool = {
+  definition: {
+    type: 'function',
+    function: {
+      name: 'watch_path',
+      description: 'Watches a file or directory in the project and reports changes back into the conversation. For a file, the lines appended since the last change are included (useful for build and test logs). The path must start with / representing the root of the project directory. Returns immediately with the watch id; changes arrive later as messages.',
+      parameters: {
+        type: 'object',
+        properties: {
+          path: {
+            type: 'string',
+            description: 'The file or directory to watch (relative to project root, must start with /)',
+          },
+          pattern: {
+            type: 'string',
+            description: 'Optional case-insensitive regex. For a file, only appended lines that match are reported; for a directory, only changed paths that match.',
+          },
+          react: {
+            type: 'boolean',
+            description: 'When true, each change is sent to you as a new message so you can respond to it. When false (default), the user is only notified.',
+          },
+          instructions: {
+            type: 'string',
+            description: 'What to do when a change is reported, e.g. "Explain the first error and suggest a fix"',
+          },
+        },
+        required: ['path'],
+      },
+    },
+  },
+
+  requiresMainProcess: true,
+  defaultPermission: 'ask',
+
+  async execute() {
+    // This will be executed in the main process via IPC
+    throw new Error('Watch path tool must be executed in main process');
+  },
+};
@@ -0,0 +1,36 @@
+import type { Tool } from '../../types/chat';
+
+export const WebSearchTool: Tool = {
+  definition: {
+    type: 'function',
+    function: {
+      name: 'web_search',
+      description: 'Searches the web and returns the title, URL and a snippet of each result. Use it for current events and anything that may have changed since your training data. The search backend (SearxNG, Brave or DuckDuckGo) is configured by the user.',
+      parameters: {
+        type: 'object',
+        properties: {
+          query: {
+            type: 'string',
+            description: 'The search query',
+          },
+          max_results: {
+            type: 'integer',
+            description: 'Maximum number of results to return (default 5, at most 20)',
+          },
+        },
+        required: ['query'],
+      },
+    },
+  },
+
+  requiresMainProcess: true,
+  requiresNetwork: true,
+  defaultPermission: 'ask',
+  // Repeating a search within a few minutes returns the same results
+  cacheTtl: 300,
+
+  async execute() {
+    // This will be executed in the main process via IPC
+    throw new Error('Web search tool must be executed in main process');
+  },
+};
@@ -5,7 +5,7 @@ export const WriteTool: Tool = {
     type: 'function',
     function: {
       name: 'write',
-      description: 'Writes content to a file in the project directory. Creates the file if it does not exist, or overwrites it if it does. The path parameter must start with / representing the root of the project directory.',
+      description: 'Writes content to a file in the project directory. Creates the file if it does not exist, or overwrites it if it does. The path parameter must start with / representing the root of the project directory. Content is limited to 5 MB.',
       parameters: {
         type: 'object',
         properties: {
@@ -24,6 +24,7 @@ export const WriteTool: Tool = {
   },
 
   requiresMainProcess: true,
+  modifiesWorkspace: true,
   defaultPermission: 'ask',
 
   async execute() {
@@ -0,0 +1,111 @@
+import { useEffect, useState } from 'react';
+
+// Questions a running tool asks the user ("Which environment?"), shown with the
+// tool call in the transcript. Renderer tools ask through
+// ToolExecutionContext.askUser; MCP servers send elicitation requests, which the
+// main process forwards here.
+
+export interface UserInputRequest {
+  id: string;
+  toolCallId: string;
+  question: string;
+  choices?: string[]; // Offered as buttons; the user types an answer when unset
+}
+
+interface PendingRequest extends UserInputRequest {
+  resolve: (answer: string | null) => void;
+}
+
+const pending = new Map<string, PendingRequest>();
+const listeners = new Set<() => void>();
+let unsubscribeIpc: (() => void) | null = null;
+let nextId = 0;
+
+function notify() {
+  listeners.forEach(listener => listener());
+}
+
+/**
+ * Ask the user a question on behalf of a running tool call. Resolves to the
+ * answer, or null if the user dismissed the question or the call was cancelled.
+ */
+export function askUser(toolCallId: string, question: string, choices?: string[]): Promise<string | null> {
+  return new Promise(resolve => {
+    const id = `input-${++nextId}`;
+    pending.set(id, {
+      id,
+      toolCallId,
+      question,
+      ...(choices && choices.length > 0 && { choices }),
+      resolve,
+    });
+    notify();
+  });
+}
+
+export function answerUserInput(id: string, answer: string | null) {
+  const request = pending.get(id);
+  if (!request) {
+    return;
+  }
+  pending.delete(id);
+  request.resolve(answer);
+  notify();
+}
+
+/**
+ * Dismiss every question still open for a tool call, as when it is cancelled
+ */
+export function cancelUserInput(toolCallId: string) {
+  for (const request of Array.from(pending.values())) {
+    if (request.toolCallId === toolCallId) {
+      answerUserInput(request.id, null);
+    }
+  }
+}
+
+export function isWaitingForUser(toolCallId: string): boolean {
+  return Array.from(pending.values()).some(request => request.toolCallId === toolCallId);
+}
+
+/**
+ * Call `listener` whenever questions are asked or answered. Returns the unsubscribe function.
+ */
+export function subscribeUserInput(listener: () => void): () => void {
+  listeners.add(listener);
+  return () => {
+    listeners.delete(listener);
+  };
+}
+
+// Questions from MCP servers arrive over IPC and are answered the same way
+function ensureSubscribed() {
+  if (unsubscribeIpc) {
+    return;
+  }
+  unsubscribeIpc = window.electronAPI.onToolInputRequest(({ requestId, toolCallId, question, choices }) => {
+    askUser(toolCallId, question, choices).then(answer => {
+      window.electronAPI.toolInputRespond(requestId, answer).catch(() => undefined);
+    });
+  });
+}
+
+function findRequest(toolCallId: string | undefined): UserInputRequest | null {
+  const request = Array.from(pending.values()).find(r => r.toolCallId === toolCallId);
+  return request ? { id: request.id, toolCallId: request.toolCallId, question: request.question, choices: request.choices } : null;
+}
+
+/**
+ * The oldest open question for a tool call, or null
+ */
+export function useUserInputRequest(toolCallId: string | undefined): UserInputRequest | null {
+  const [request, setRequest] = useState<UserInputRequest | null>(() => findRequest(toolCallId));
+
+  useEffect(() => {
+    ensureSubscribed();
+    setRequest(findRequest(toolCallId));
+    return subscribeUserInput(() => setRequest(findRequest(toolCallId)));
+  }, [toolCallId]);
+
+  return toolCallId ? request : null;
+}
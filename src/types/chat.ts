@@ -18,9 +18,125 @@ export interface ChatMessage {
   tool_call_id?: string;
   timestamp: number;
   thinking?: string; // For models that support reasoning/thinking
+  doneReason?: string; // Why the provider stopped generating (e.g. "stop", "length")
+  stopped?: boolean; // Generation was stopped by the user, /continue resumes it
+  modelOverride?: { providerId: string; modelId: string }; // Answered by a model other than the session default
+  rating?: MessageRating; // Review annotation added with /rate
+  pinned?: boolean; // Set with /pin; still sent when older turns are dropped or summarized
+  images?: MessageImage[]; // Attached by the user and sent to the model, or returned by a tool and only shown
+  contextSummary?: ContextSummary; // Set on the system message that stands in for summarized turns
+  mergedFrom?: { sessionId: string; sessionName: string }; // Copied in from another session with /merge
+  resultView?: ToolResultView; // Rich view of a tool result; the model still gets the text content
+  generationStats?: GenerationStats; // Token counts and speed reported for this response
+  fromWatch?: boolean; // Sent by a watch started with react, not typed by the user
+}
+
+export interface GenerationStats {
+  promptTokens: number;
+  completionTokens: number;
+  evalDurationMs?: number; // Time spent generating, when the provider reports it
+  totalDurationMs?: number;
+}
+
+// Structured data a tool can return next to its text content, shown in the
+// transcript in a form that suits it instead of as raw text
+export type ToolResultView =
+  | { kind: 'json'; data: unknown }
+  | { kind: 'table'; columns: string[]; rows: unknown[][] }
+  | { kind: 'files'; files: Array<{ path: string; type?: 'file' | 'directory'; size?: number }> }
+  | { kind: 'diff'; oldContent: string; newContent: string; fileName?: string };
+
+// What happens when the conversation outgrows the context window: drop the oldest
+// turns, stop sending, or replace the oldest turns with a model-written summary
+export type ContextMode = 'rolling' | 'halt' | 'summarize';
+
+export const CONTEXT_MODES: ContextMode[] = ['rolling', 'halt', 'summarize'];
+
+// The summarized messages stay in the transcript but are no longer sent to the model
+export interface ContextSummary {
+  messageIds: string[];
+  providerId: string;
+  modelId: string;
+}
+
+// How reasoning is shown in the transcript: expanded, collapsed behind a toggle, or not at all
+export type ThinkingDisplay = 'expanded' | 'collapsed' | 'hidden';
+
+// Persisted as the "thinking" preference. Limits are in characters, 0 means no limit;
+// both keep the end of the reasoning, which is usually the part that matters.
+export interface ThinkingSettings {
+  display: ThinkingDisplay;
+  saveToHistory: boolean; // Keep reasoning in saved sessions
+  renderLimit: number; // Shown in the transcript, the rest behind "show earlier"
+  retainLimit: number; // Kept once a reply finishes, and so in saved sessions
+}
+
+export const DEFAULT_THINKING_SETTINGS: ThinkingSettings = {
+  display: 'collapsed',
+  saveToHistory: true,
+  renderLimit: 2000,
+  retainLimit: 0,
+};
+
+export interface MessageImage {
+  mimeType: string;
+  data?: string; // base64, for images small enough to keep in the session
+  path?: string; // temp file for images that can't be shown inline
+}
+
+// Sampling and context options sent to Ollama as `options`
+export interface GenerationOptions {
+  temperature?: number;
+  top_p?: number;
+  top_k?: number;
+  min_p?: number;
+  num_ctx?: number;
+  num_predict?: number;
+  repeat_penalty?: number;
+  seed?: number;
+  stop?: string[]; // Added to the model's stop sequences
+}
+
+// What the engine did, for the /events pane (see electron/logger.ts): connections
+// to servers, retries, cache hits, hooks that changed something, rate-limit
+// waits, and requests adapted to the model
+export const ENGINE_EVENT_KINDS = ['connection', 'retry', 'cache', 'hook', 'rate-limit', 'model'] as const;
+export type EngineEventKind = typeof ENGINE_EVENT_KINDS[number];
+
+// A main-process log entry, shown in the /debug pane; entries with an event
+// kind are also shown in the /events pane
+export interface LogEntry {
+  time: string;
+  level: 'debug' | 'info' | 'warn' | 'error';
+  scope: string;
+  message: string;
+  fields?: Record<string, unknown>;
+  event?: EngineEventKind;
+}
+
+// Per-session settings saved alongside the messages
+export interface SessionSettings {
+  env?: Record<string, string>; // Set with /env, passed to tool processes but never to the model
+  systemPrompt?: string; // Set with /system set, used instead of the selected prompt
+  options?: GenerationOptions; // Set with /set, override the model's configured options
+}
+
+export interface MessageRating {
+  value: 'good' | 'bad';
+  note?: string;
+  ratedAt: number;
 }
 
 // Provider configuration types
+
+// What the server says about an installed model (Ollama's /api/tags), shown in the model picker
+export interface ModelDetails {
+  size?: number; // Bytes on disk
+  parameterSize?: string; // e.g. "8.0B"
+  quantization?: string; // e.g. "Q4_K_M"
+  family?: string;
+}
+
 export interface ModelConfig {
   id: string;
   name: string;
@@ -28,12 +144,23 @@ export interface ModelConfig {
   contextLength: number;
   embeddingDimension?: number | null;
   supportsTools?: boolean; // Whether this model supports function/tool calling
+  supportsThinking?: boolean; // Whether it can be asked for separate reasoning (native thinking)
+  supportsImages?: boolean; // Whether it takes image attachments
+  thinking?: 'auto' | 'native' | 'tags' | 'harmony' | 'none'; // How the model reports reasoning (default: auto)
+  thinkingTags?: { open: string; close: string; startInThinking?: boolean }; // Inline reasoning tags other than <think>
+  api?: 'chat' | 'generate'; // Ollama endpoint to use (default: chat)
+  raw?: boolean; // generate API: send the prompt verbatim, without any template
+  template?: string; // Ollama template replacing the model's own (served through the generate API)
+  stop?: string[]; // Stop sequences for fine-tunes whose template Ollama doesn't know
+  promptFormat?: 'plain' | 'chatml'; // generate API: how history is flattened into the prompt
+  options?: GenerationOptions; // Ollama options for this model, e.g. { temperature: 0.2, num_ctx: 8192 }
+  details?: ModelDetails; // From the server's model list, not the config
 }
 
 export interface ProviderConfig {
   id: string;
   name: string;
-  type: 'ollama' | 'lmstudio' | 'gemini' | 'claude';
+  type: 'ollama' | 'lmstudio' | 'openai' | 'gemini' | 'claude';
   baseURL: string;
   apiKey?: string | null;
   models: ModelConfig[];
@@ -61,13 +188,8 @@ export interface ChatRequest {
   maxTokens?: number;
 }
 
-export interface ChatChunk {
-  type: 'content' | 'tool_call' | 'done' | 'error';
-  content?: string;
-  tool_call?: ToolCall;
-  error?: string;
-  done?: boolean;
-}
+// Streamed chunks follow the versioned chat event schema
+export type { ChatEvent as ChatChunk } from './events';
 
 export interface ChatResponse {
   message: ChatMessage;
@@ -101,11 +223,67 @@ export interface ToolDefinition {
   };
 }
 
+export interface ToolExecutionContext {
+  toolCallId?: string; // Lets the main process cancel the work behind the call
+  // Ask the user mid-call ("Which environment?"), optionally offering choices.
+  // Resolves to null when the user dismisses the question.
+  askUser?: (question: string, choices?: string[]) => Promise<string | null>;
+}
+
 export interface Tool {
   definition: ToolDefinition;
-  execute: (params: Record<string, unknown>) => Promise<unknown>;
+  execute: (params: Record<string, unknown>, context?: ToolExecutionContext) => Promise<unknown>;
   requiresMainProcess?: boolean;
+  // Tools that reach the network are disabled in offline mode
+  requiresNetwork?: boolean;
+  // Tools that write files or run processes are disabled until the workspace is trusted
+  modifiesWorkspace?: boolean;
   defaultPermission?: 'allow' | 'ask';
+  // Seconds a result is reused for identical arguments, for tools without side
+  // effects whose calls are slow or expensive. Overridden by cacheTtl in tools.json or mcp.json.
+  cacheTtl?: number;
+  // Parameters whose values are masked wherever the call is shown or logged
+  sensitiveParams?: string[];
+}
+
+// /trust decision for a project directory (mirrors electron/main.ts)
+export type WorkspaceTrust = 'trusted' | 'restricted';
+
+// A file or directory watched with watch_path or /watch (mirrors electron/path-watcher.ts)
+export interface WatchInfo {
+  id: string;
+  path: string;
+  isDirectory: boolean;
+  pattern?: string;
+  react: boolean; // Ask the model to respond to each change
+  instructions?: string;
+  startedAt: string;
+}
+
+export interface WatchEvent {
+  watch: WatchInfo;
+  files: string[];
+  excerpt?: string; // Lines appended to a watched file
+}
+
+// The project's retrieval index (mirrors electron/rag)
+export interface RagStatus {
+  indexed: boolean;
+  enabled: boolean; // Retrieval for chat requests, from the rag preference
+  model: string | null; // "providerId/modelId" the index was built with
+  files: number;
+  chunks: number;
+  dimension: number;
+  updatedAt: string | null;
+  sizeBytes: number;
+}
+
+export interface RagIndexResult {
+  files: number;
+  embedded: number; // New or changed since the last run
+  removed: number;
+  chunks: number;
+  skipped: string[];
 }
 
 export interface ToolExecutionResult {
@@ -15,6 +15,12 @@ interface WorkingDirectoryChangeResult {
   error: string | null;
 }
 
+interface ExportSaveResult {
+  success: boolean;
+  filePath: string | null;
+  error: string | null;
+}
+
 interface ConfigReadResult {
   success: boolean;
   content: string | null;
@@ -26,6 +32,19 @@ interface ConfigWriteResult {
   error: string | null;
 }
 
+interface WorkspaceTrustResult {
+  success: boolean;
+  trust: import('./chat').WorkspaceTrust | null; // null when there's no decision yet
+  decidedFor: string | null; // The directory the decision was made for, the project or a parent
+  error: string | null;
+}
+
+interface ImageReadResult {
+  success: boolean;
+  image: (import('./chat').MessageImage & { data: string }) | null;
+  error: string | null;
+}
+
 export interface ElectronAPI {
   // Window controls
   minimizeWindow: () => Promise<void>
@@ -35,18 +54,38 @@ export interface ElectronAPI {
   store: (input: string) => Promise<string>
   search: (query: string, count?: number) => Promise<VectorRecord[]>
   demoVectorDatabase: () => Promise<void>
+  getLaunchOptions: () => Promise<{ directory: string | null; session: string | null; resume: boolean; print: string | null; model: string | null; safe: boolean } | null>
+  getSafeMode: () => Promise<boolean>
   // Directory selection functions
   selectDirectory: () => Promise<string | null>
   expandPath: (inputPath: string) => Promise<string>
   validateDirectory: (dirPath: string) => Promise<DirectoryValidationResult>
   changeWorkingDirectory: (dirPath: string) => Promise<WorkingDirectoryChangeResult>
+  // Export functions
+  // Without filePath the user picks the location; a relative filePath is resolved against baseDir
+  exportSaveFile: (defaultName: string, content: string, filters: Array<{ name: string; extensions: string[] }>, filePath?: string, baseDir?: string) => Promise<ExportSaveResult>
+  transcriptRecord: (record: Record<string, unknown>) => Promise<{ success: boolean; error: string | null }>
+  clipboardWriteText: (text: string) => Promise<{ success: boolean; error: string | null }>
+  clipboardReadText: () => Promise<{ success: boolean; text: string | null; error: string | null }>
+  hookRunCommand: (command: string, options: { cwd?: string; env?: Record<string, string>; input?: string }) => Promise<{ success: boolean; error: string | null }>
+  hookAppendFile: (filePath: string, content: string, cwd?: string) => Promise<{ success: boolean; filePath: string | null; error: string | null }>
+  imageSaveTemp: (data: string, mimeType: string) => Promise<ExportSaveResult>
+  imageReadFile: (projectPath: string | null, filePath: string) => Promise<ImageReadResult>
+  snippetWriteFiles: (projectPath: string | null, files: import('../utils/snippets').Snippet[], overwrite: boolean) => Promise<{ success: boolean; written: string[]; existing: string[]; error: string | null }>
+  ttsSynthesize: (text: string, options: { binary?: string; model: string; rate?: number }) => Promise<{ success: boolean; audio: string | null; error: string | null }>
+  sttTranscribe: (audio: string, settings: import('../speech/VoiceInput').TranscriptionSettings) => Promise<{ success: boolean; text: string | null; error: string | null }>
   // Config file functions
   configRead: (filename: string) => Promise<ConfigReadResult>
   configWrite: (filename: string, content: string) => Promise<ConfigWriteResult>
+  // providers.yaml with ${VAR} references resolved; configRead returns the raw text
+  configReadProviders: () => Promise<{ success: boolean; providers: import('./chat').ProviderConfig[]; error: string | null }>
   configInitDefaults: (filename: string, template: string) => Promise<ConfigWriteResult>
   // Project MCP override functions
   projectMcpOverridesRead: (projectPath: string) => Promise<ConfigReadResult>
   projectMcpOverridesWrite: (projectPath: string, content: string) => Promise<ConfigWriteResult>
+  // Workspace trust, for tools that write files or run processes
+  workspaceTrustGet: (projectPath: string) => Promise<WorkspaceTrustResult>
+  workspaceTrustSet: (projectPath: string, trust: import('./chat').WorkspaceTrust | null) => Promise<WorkspaceTrustResult>
   // Project context mode functions
   projectContextModeRead: (projectPath: string) => Promise<{ success: boolean; mode: string; error: string | null }>
   projectContextModeWrite: (projectPath: string, mode: string) => Promise<ConfigWriteResult>
@@ -56,20 +95,55 @@ export interface ElectronAPI {
     model: string;
     messages: unknown[];
     tools?: unknown[];
+    projectPath?: string;
+    options?: import('./chat').GenerationOptions;
   }) => Promise<{ success: boolean; error?: string }>
+  // The provider request chatSendMessage would make, with any API key masked
+  chatPreview: (params: {
+    provider: string;
+    model: string;
+    messages: unknown[];
+    tools?: unknown[];
+    projectPath?: string;
+    options?: import('./chat').GenerationOptions;
+  }) => Promise<{
+    success: boolean;
+    preview: { url: string; body: Record<string, unknown>; leftOut: string[] } | null;
+    error: string | null;
+  }>
   chatCancel: () => Promise<{ success: boolean; error?: string }>
+  chatWarmUp: (params: {
+    provider: string;
+    model: string;
+  }) => Promise<{ success: boolean; warmed: boolean; error?: string }>
   chatGetContextLength: (params: {
     provider: string;
     model: string;
   }) => Promise<{ success: boolean; contextLength?: number; error?: string }>
+  chatModelCapabilities: (params: {
+    provider: string;
+    model: string;
+    refresh?: boolean; // Ask the server again instead of using what it said before
+  }) => Promise<{
+    success: boolean;
+    capabilities: { tools: boolean; thinking: boolean | null; images: boolean | null; contextLength: number | null; detected: boolean } | null;
+    error: string | null;
+  }>
+  chatComplete: (params: {
+    provider: string;
+    model: string;
+    messages: Array<{ role: 'system' | 'user' | 'assistant'; content: string }>;
+  }) => Promise<{ success: boolean; content: string; error: string | null }>
+  chatListModels: (params: { provider: string }) => Promise<{ success: boolean; models: import('./chat').ModelConfig[]; error: string | null }>
   onChatChunk: (callback: (chunk: unknown) => void) => void
   removeChatChunkListener: () => void
   executeTool: (toolName: string, params: Record<string, unknown>) => Promise<unknown>
   getHomeDir: () => Promise<string>
 
   // Session storage functions
-  sessionSave: (projectPath: string, sessionId: string, messages: unknown[], sessionName?: string, isCustomName?: boolean, providerId?: string, modelId?: string) => Promise<{ success: boolean; error: string | null }>
-  sessionLoad: (projectPath: string, sessionId: string) => Promise<{ success: boolean; messages: unknown[] | null; lastModified?: string; name?: string; isCustomName?: boolean; providerId?: string | null; modelId?: string | null; error: string | null }>
+  sessionSave: (projectPath: string, sessionId: string, messages: unknown[], sessionName?: string, isCustomName?: boolean, providerId?: string, modelId?: string, settings?: import('./chat').SessionSettings) => Promise<{ success: boolean; error: string | null }>
+  sessionLoad: (projectPath: string, sessionId: string) => Promise<{ success: boolean; messages: unknown[] | null; lastModified?: string; name?: string; isCustomName?: boolean; providerId?: string | null; modelId?: string | null; settings?: import('./chat').SessionSettings; error: string | null }>
+  toolEnvSet: (projectPath: string, env: Record<string, string>) => Promise<{ success: boolean; error: string | null }>
   sessionList: (projectPath: string) => Promise<{ success: boolean; sessions: Array<{ id: string; lastModified: string; messageCount: number; name: string; isCustomName: boolean }>; error: string | null }>
   sessionDelete: (projectPath: string, sessionId: string) => Promise<{ success: boolean; error: string | null }>
   sessionClearAll: (projectPath: string) => Promise<{ success: boolean; error: string | null }>
@@ -83,26 +157,23 @@ export interface ElectronAPI {
   // Preferences functions
   preferencesGet: (key: string) => Promise<{ success: boolean; value: unknown; error: string | null }>
   preferencesSet: (key: string, value: unknown) => Promise<{ success: boolean; error: string | null }>
+  inputHistoryRead: () => Promise<{ success: boolean; entries: string[]; error: string | null }>
+  inputHistoryAdd: (entry: string) => Promise<{ success: boolean; error: string | null }>
 
   // Prompt management functions
+  templatesList: () => Promise<{ success: boolean; templates: Array<{ name: string; description?: string }>; directory: string | null; error: string | null }>
+  templatesRead: (name: string) => Promise<{ success: boolean; template: import('../utils/promptTemplates').PromptTemplate | null; error: string | null }>
   promptsList: () => Promise<{ success: boolean; prompts: string[]; error: string | null }>
   promptsRead: (name: string) => Promise<{ success: boolean; content: string | null; error: string | null }>
   promptsWrite: (name: string, content: string) => Promise<{ success: boolean; error: string | null }>
   promptsDelete: (name: string) => Promise<{ success: boolean; error: string | null }>
+  promptsOpen: (name: string) => Promise<{ success: boolean; error: string | null }>
 
   // MCP functions
-  mcpStartServer: (name: string, config: {
-    command: string;
-    args: string[];
-    env?: Record<string, string>;
-  }) => Promise<{ success: boolean; error: string | null }>
+  mcpStartServer: (name: string, config: import('./mcp').MCPServerConfig) => Promise<{ success: boolean; error: string | null }>
   mcpStopServer: (name: string) => Promise<{ success: boolean; error: string | null }>
-  mcpRestartServer: (name: string, config: {
-    command: string;
-    args: string[];
-    env?: Record<string, string>;
-  }) => Promise<{ success: boolean; error: string | null }>
-  mcpCallTool: (serverName: string, toolName: string, args: Record<string, unknown>) => Promise<{
+  mcpRestartServer: (name: string, config: import('./mcp').MCPServerConfig) => Promise<{ success: boolean; error: string | null }>
+  mcpCallTool: (serverName: string, toolName: string, args: Record<string, unknown>, toolCallId?: string) => Promise<{
     success: boolean;
     result: unknown;
     error: string | null;
@@ -139,12 +210,7 @@ export interface ElectronAPI {
     }>;
     startedAt?: string;
   }>>
-  mcpReconcileServers: (newConfig: Record<string, {
-    command: string;
-    args: string[];
-    env?: Record<string, string>;
-    projectPath?: string;
-  }>) => Promise<{
+  mcpReconcileServers: (newConfig: Record<string, import('./mcp').MCPServerConfig>) => Promise<{
     success: boolean;
     error: string | null;
   }>
@@ -162,6 +228,12 @@ export interface ElectronAPI {
     offset?: number;
     error?: string;
   }>
+  applyReadFile: (projectPath: string, filePath: string) => Promise<{
+    success: boolean;
+    content?: string;
+    exists?: boolean;
+    error?: string;
+  }>
   internalToolWrite: (projectPath: string, params: {
     file_path: string;
     content: string;
@@ -212,7 +284,7 @@ export interface ElectronAPI {
     command: string;
     description?: string;
     timeout?: number;
-  }) => Promise<{
+  }, toolCallId?: string) => Promise<{
     success: boolean;
     stdout?: string;
     stderr?: string;
@@ -220,6 +292,38 @@ export interface ElectronAPI {
     exit_code?: number;
     error?: string;
   }>
+  toolCancel: (toolCallId: string) => Promise<{ success: boolean; error?: string }>
+  onToolInputRequest: (callback: (request: { requestId: string; toolCallId: string; question: string; choices?: string[] }) => void) => () => void
+  toolInputRespond: (requestId: string, answer: string | null) => Promise<{ success: boolean; error?: string }>
+  observerStart: (port?: number) => Promise<{ success: boolean; url: string | null; error: string | null }>
+  observerStop: () => Promise<{ success: boolean; stopped: boolean; error: string | null }>
+  observerStatus: () => Promise<{ success: boolean; running: boolean; url: string | null; observers: number; error: string | null }>
+  observerPublish: (messages: unknown[]) => Promise<{ success: boolean; error: string | null }>
+  ragStatus: (projectPath: string) => Promise<{ success: boolean; status: import('./chat').RagStatus | null; error: string | null }>
+  ragIndex: (projectPath: string, targetPath: string) => Promise<{ success: boolean; result: import('./chat').RagIndexResult | null; error: string | null }>
+  ragClear: (projectPath: string) => Promise<{ success: boolean; removed: boolean; error: string | null }>
+  watchStart: (projectPath: string, params: {
+    path: string;
+    pattern?: string;
+    react?: boolean;
+    instructions?: string;
+  }) => Promise<{ success: boolean; watch: import('./chat').WatchInfo | null; error: string | null }>
+  watchStop: (watchId: string) => Promise<{ success: boolean; stopped: number; error: string | null }>
+  watchList: () => Promise<import('./chat').WatchInfo[]>
+  onWatchEvent: (callback: (event: import('./chat').WatchEvent) => void) => () => void
+  // Main-process log, for the /debug pane
+  logsRecent: () => Promise<{ success: boolean; entries: import('./chat').LogEntry[]; file: string | null; error: string | null }>
+  logsFollow: (follow: boolean) => Promise<{ success: boolean; error: string | null }>
+  onLogEntry: (callback: (entry: import('./chat').LogEntry) => void) => () => void
+  engineEventsRecent: () => Promise<{ success: boolean; events: import('./chat').LogEntry[]; error: string | null }>
+  engineEventRecord: (params: {
+    kind: import('./chat').EngineEventKind;
+    scope: string;
+    message: string;
+    fields?: Record<string, unknown>;
+  }) => Promise<{ success: boolean; error: string | null }>
+  onEngineEvent: (callback: (entry: import('./chat').LogEntry) => void) => () => void
+  onToolOutput: (callback: (output: { toolCallId: string; stream: 'stdout' | 'stderr'; chunk: string }) => void) => () => void
   internalToolLs: (projectPath: string, params: {
     path?: string;
     show_hidden?: boolean;
@@ -263,6 +367,29 @@ export interface ElectronAPI {
     path?: string;
     error?: string;
   }>
+  internalToolWebSearch: (params: {
+    query: string;
+    max_results?: number;
+  }, toolCallId?: string) => Promise<{
+    success: boolean;
+    query?: string;
+    provider?: string;
+    results?: Array<{ title: string; url: string; snippet: string }>;
+    error?: string;
+  }>
+  internalToolFetchUrl: (params: {
+    url: string;
+    max_length?: number;
+    timeout?: number;
+  }, toolCallId?: string) => Promise<{
+    success: boolean;
+    url?: string;
+    title?: string;
+    content?: string;
+    length?: number;
+    truncated?: boolean;
+    error?: string;
+  }>
 }
 
 declare global {
@@ -0,0 +1,82 @@
+// Chat event schema for the "chat-chunk" stream.
+//
+// Every event emitted by the main process is a plain JSON object carrying the
+// schema version and a per-request sequence number, so any frontend (the
+// renderer, a test harness, a remote observer) can consume and replay the
+// stream without knowing about provider internals.
+import type { ToolCall } from './chat';
+
+export const CHAT_EVENT_SCHEMA_VERSION = 1;
+
+export interface ChatEventUsage {
+  prompt_tokens: number;
+  completion_tokens: number;
+  total_tokens: number;
+  eval_duration_ms?: number;
+  prompt_eval_duration_ms?: number;
+  total_duration_ms?: number;
+}
+
+// Transient states reported before or between content events; adapted says
+// what was left out of the request because the model can't take it
+export type ChatStreamStatus = 'loading_model' | 'throttled' | 'retrying' | 'adapted';
+
+export type ChatEventPayload =
+  | { type: 'content'; content: string }
+  | { type: 'thinking'; thinking: string }
+  | { type: 'tool_call'; tool_call: ToolCall }
+  | { type: 'usage'; usage: ChatEventUsage }
+  | { type: 'status'; status: ChatStreamStatus; message: string }
+  | { type: 'done'; done_reason?: string }
+  | { type: 'error'; error: string }
+  | { type: 'cancelled' };
+
+export type ChatEventType = ChatEventPayload['type'];
+
+export type ChatEvent = ChatEventPayload & {
+  version: number;
+  requestId: string;
+  seq: number;
+  timestamp: number;
+};
+
+const CHAT_EVENT_TYPES: ReadonlySet<string> = new Set<ChatEventType>([
+  'content',
+  'thinking',
+  'tool_call',
+  'usage',
+  'status',
+  'done',
+  'error',
+  'cancelled',
+]);
+
+/**
+ * Check that an unknown value is a chat event this build understands.
+ */
+export function isChatEvent(value: unknown): value is ChatEvent {
+  if (!value || typeof value !== 'object') {
+    return false;
+  }
+  const event = value as Partial<ChatEvent>;
+  return (
+    event.version === CHAT_EVENT_SCHEMA_VERSION &&
+    typeof event.type === 'string' &&
+    CHAT_EVENT_TYPES.has(event.type) &&
+    typeof event.seq === 'number'
+  );
+}
+
+/**
+ * Create an emitter that stamps payloads with the schema envelope for a single request.
+ */
+export function createChatEventStamper(requestId: string) {
+  let seq = 0;
+  return (payload: ChatEventPayload): ChatEvent => ({
+    ...payload,
+    version: CHAT_EVENT_SCHEMA_VERSION,
+    requestId,
+    seq: seq++,
+    timestamp: Date.now(),
+  });
+}
@@ -2,11 +2,17 @@
 
 export type ToolPermission = 'ask' | 'allow';
 
+export type MCPTransport = 'stdio' | 'sse';
+
+// Local servers set command/args; remote servers set url (transport defaults to sse when url is given)
 export interface MCPServerConfig {
-  command: string;
-  args: string[];
+  command?: string;
+  args?: string[];
   env?: Record<string, string>;
   projectPath?: string; // Optional working directory for the MCP server process
+  transport?: MCPTransport;
+  url?: string;
+  headers?: Record<string, string>; // Sent with every request to a remote server
 }
 
 export interface MCPToolConfig {
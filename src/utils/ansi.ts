@@ -0,0 +1,186 @@
+// Terminal escape sequences in tool output: colored compiler errors, git diff
+// --color, test runners, progress bars. Styles from SGR sequences are kept for
+// display; everything else (cursor movement, erasing, OSC titles and links) is
+// dropped. A carriage return redraws its line as a terminal would, so a progress
+// bar shows its last state rather than every frame. Shared by the main process
+// (plain text for the model) and the renderer (styled tool output).
+
+export interface AnsiStyle {
+  fg?: string; // CSS color
+  bg?: string;
+  bold?: boolean;
+  dim?: boolean;
+  italic?: boolean;
+  underline?: boolean;
+  strike?: boolean;
+  inverse?: boolean;
+}
+
+export interface AnsiSegment {
+  text: string;
+  style: AnsiStyle;
+}
+
+// Catppuccin Mocha's terminal colors, normal then bright
+const PALETTE = [
+  '#45475a', '#f38ba8', '#a6e3a1', '#f9e2af', '#89b4fa', '#f5c2e7', '#94e2d5', '#bac2de',
+  '#585b70', '#f38ba8', '#a6e3a1', '#f9e2af', '#89b4fa', '#f5c2e7', '#94e2d5', '#a6adc8',
+];
+
+// CSI (with its parameters and final byte), OSC up to BEL or ST, an escape cut
+// off at the end of the text, charset selection and other two-byte escapes
+const ESCAPE_PATTERN = /\x1b\[([0-?]*)[ -/]*([@-~])|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b(?:\[[0-?]*[ -/]*|\][^\n]*)?$|\x1b[()][0-9A-Za-z]|\x1b[@-Z\\-_]/g;
+
+export function hasAnsi(text: string): boolean {
+  return text.includes('\x1b') || /\r(?!\n)/.test(text);
+}
+
+// xterm's 256-color table: the 16 palette colors, a 6x6x6 cube, then grays
+function color256(index: number): string | undefined {
+  if (!Number.isInteger(index) || index < 0 || index > 255) {
+    return undefined;
+  }
+  if (index < 16) {
+    return PALETTE[index];
+  }
+  if (index < 232) {
+    const level = (value: number) => (value === 0 ? 0 : 55 + value * 40);
+    const n = index - 16;
+    return `rgb(${level(Math.floor(n / 36))}, ${level(Math.floor(n / 6) % 6)}, ${level(n % 6)})`;
+  }
+  const gray = 8 + (index - 232) * 10;
+  return `rgb(${gray}, ${gray}, ${gray})`;
+}
+
+// 38/48 are followed by 5;N or 2;R;G;B. Returns the color and how many codes it used.
+function extendedColor(codes: number[], start: number): { color?: string; used: number } {
+  if (codes[start] === 5) {
+    return { color: color256(codes[start + 1]), used: 2 };
+  }
+  if (codes[start] === 2) {
+    const [r, g, b] = codes.slice(start + 1, start + 4).map(value => Math.max(0, Math.min(255, value || 0)));
+    return { color: `rgb(${r}, ${g}, ${b})`, used: 4 };
+  }
+  return { used: codes.length - start };
+}
+
+function applySgr(style: AnsiStyle, params: string): AnsiStyle {
+  // Colon sub-parameters (38:2::R:G:B) are treated like semicolons
+  const codes = params === '' ? [0] : params.replace(/::/g, ':').split(/[;:]/).map(Number);
+  let next = { ...style };
+
+  for (let i = 0; i < codes.length; i++) {
+    const code = codes[i];
+    if (code === 0 || Number.isNaN(code)) {
+      next = {};
+    } else if (code === 1) {
+      next.bold = true;
+    } else if (code === 2) {
+      next.dim = true;
+    } else if (code === 3) {
+      next.italic = true;
+    } else if (code === 4) {
+      next.underline = true;
+    } else if (code === 7) {
+      next.inverse = true;
+    } else if (code === 9) {
+      next.strike = true;
+    } else if (code === 22) {
+      next.bold = false;
+      next.dim = false;
+    } else if (code === 23) {
+      next.italic = false;
+    } else if (code === 24) {
+      next.underline = false;
+    } else if (code === 27) {
+      next.inverse = false;
+    } else if (code === 29) {
+      next.strike = false;
+    } else if (code >= 30 && code <= 37) {
+      next.fg = PALETTE[code - 30];
+    } else if (code >= 90 && code <= 97) {
+      next.fg = PALETTE[code - 90 + 8];
+    } else if (code === 39) {
+      next.fg = undefined;
+    } else if (code >= 40 && code <= 47) {
+      next.bg = PALETTE[code - 40];
+    } else if (code >= 100 && code <= 107) {
+      next.bg = PALETTE[code - 100 + 8];
+    } else if (code === 49) {
+      next.bg = undefined;
+    } else if (code === 38 || code === 48) {
+      const { color, used } = extendedColor(codes, i + 1);
+      if (code === 38) {
+        next.fg = color;
+      } else {
+        next.bg = color;
+      }
+      i += used;
+    }
+  }
+  return next;
+}
+
+/**
+ * Split text into lines of styled segments, with styles carried across lines
+ * the way a terminal carries them
+ */
+export function parseAnsiLines(text: string): AnsiSegment[][] {
+  if (!hasAnsi(text)) {
+    return text.split('\n').map(line => (line ? [{ text: line, style: {} }] : []));
+  }
+
+  const lines: AnsiSegment[][] = [];
+  let line: AnsiSegment[] = [];
+  let style: AnsiStyle = {};
+  // A carriage return clears the line once more text follows on it
+  let returned = false;
+
+  const addText = (chunk: string) => {
+    const parts = chunk.split(/(\n|\r)/);
+    for (const part of parts) {
+      if (part === '\n') {
+        lines.push(line);
+        line = [];
+        returned = false;
+      } else if (part === '\r') {
+        returned = true;
+      } else if (part) {
+        if (returned) {
+          line = [];
+          returned = false;
+        }
+        const last = line[line.length - 1];
+        if (last && last.style === style) {
+          last.text += part;
+        } else {
+          line.push({ text: part, style });
+        }
+      }
+    }
+  };
+
+  const normalized = text.replace(/\r\n/g, '\n');
+  let index = 0;
+  ESCAPE_PATTERN.lastIndex = 0;
+  for (let match = ESCAPE_PATTERN.exec(normalized); match; match = ESCAPE_PATTERN.exec(normalized)) {
+    addText(normalized.substring(index, match.index));
+    if (match[2] === 'm') {
+      style = applySgr(style, match[1]);
+    }
+    index = match.index + match[0].length;
+  }
+  addText(normalized.substring(index));
+  lines.push(line);
+  return lines;
+}
+
+/**
+ * The text a terminal would show, without styles
+ */
+export function stripAnsi(text: string): string {
+  if (!hasAnsi(text)) {
+    return text;
+  }
+  return parseAnsiLines(text).map(line => line.map(segment => segment.text).join('')).join('\n');
+}
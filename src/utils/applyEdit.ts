@@ -0,0 +1,130 @@
+import type { ChatMessage } from '../types/chat';
+import type { FencedBlock } from './codeFence';
+import { snippetNameHint } from './snippets';
+import { getFullOutput, isShortened } from '../tools/toolOutputLimit';
+
+// /apply: the files a conversation has attached with the read tool, and which
+// of a reply's code blocks is a new version of one of them. Paths are the
+// read tool's, from the project root and starting with "/".
+
+export interface AttachedFile {
+  path: string;
+  content: string | null; // The file as the model saw it, when it read all of it
+}
+
+export interface ProposedEdit {
+  path: string;
+  content: string;
+  attached: AttachedFile | null;
+}
+
+const normalizePath = (path: string) => `/${path.replace(/\\/g, '/').replace(/^\.?\/+/, '')}`;
+
+function readContent(result: Record<string, unknown>): string | null {
+  if (!result.success || result.truncated || result.offset || result.lines_returned !== result.total_lines || typeof result.content !== 'string') {
+    return null;
+  }
+  return result.content.split('\n').map(line => line.replace(/^\s*\d+\t/, '')).join('\n');
+}
+
+// What a read returned, from the full result when the conversation only has a shortened one
+function parseReadResult(toolCallId: string, message: ChatMessage | undefined): string | null {
+  const full = getFullOutput(toolCallId)?.result;
+  if (full && typeof full === 'object') {
+    return readContent(full as Record<string, unknown>);
+  }
+  if (!message || isShortened(message.content)) {
+    return null;
+  }
+  try {
+    return readContent(JSON.parse(message.content));
+  } catch {
+    return null;
+  }
+}
+
+/**
+ * The files read in the conversation, most recently read first
+ */
+export function attachedFiles(messages: ChatMessage[]): AttachedFile[] {
+  const results = new Map(messages.filter(m => m.role === 'tool' && m.tool_call_id).map(m => [m.tool_call_id, m]));
+  const files = new Map<string, AttachedFile>();
+  for (const message of [...messages].reverse()) {
+    for (const toolCall of [...(message.tool_calls || [])].reverse()) {
+      if (toolCall.function.name !== 'read') {
+        continue;
+      }
+      let filePath: unknown;
+      try {
+        filePath = JSON.parse(toolCall.function.arguments || '{}').file_path;
+      } catch {
+        continue;
+      }
+      if (typeof filePath !== 'string' || !filePath) {
+        continue;
+      }
+      const path = normalizePath(filePath);
+      const content = parseReadResult(toolCall.id, results.get(toolCall.id));
+      const known = files.get(path);
+      if (!known) {
+        files.set(path, { path, content });
+      } else if (known.content === null) {
+        // The latest read was partial; an earlier one saw the whole file
+        known.content = content;
+      }
+    }
+  }
+  return [...files.values()];
+}
+
+function findAttached(files: AttachedFile[], name: string): AttachedFile | undefined {
+  const path = normalizePath(name);
+  return files.find(file => file.path === path) ?? files.find(file => file.path.endsWith(path));
+}
+
+/**
+ * The code block to apply and the file it replaces. With a target, that's
+ * the only block or the one naming it; otherwise the last block naming an
+ * attached file, or a single unnamed block for the file read most recently.
+ */
+export function proposedEdit(blocks: FencedBlock[], files: AttachedFile[], target?: string): ProposedEdit | null {
+  const withNewline = (code: string) => (code.endsWith('\n') ? code : `${code}\n`);
+  const hints = blocks.map(block => snippetNameHint(block));
+
+  if (target) {
+    const attached = findAttached(files, target) ?? null;
+    const path = attached?.path ?? normalizePath(target);
+    const index = blocks.length === 1 ? 0 : hints.findIndex(hint => hint && path.endsWith(normalizePath(hint)));
+    return index < 0 ? null : { path, content: withNewline(blocks[index].code), attached };
+  }
+
+  for (let index = blocks.length - 1; index >= 0; index--) {
+    const attached = hints[index] ? findAttached(files, hints[index]!) : undefined;
+    if (attached) {
+      return { path: attached.path, content: withNewline(blocks[index].code), attached };
+    }
+  }
+  if (blocks.length === 1 && !hints[0] && files.length > 0) {
+    return { path: files[0].path, content: withNewline(blocks[0].code), attached: files[0] };
+  }
+  return null;
+}
+
+/**
+ * Reasons to look twice before applying: the file changed since the model
+ * read it, or the block looks like an excerpt rather than the whole file
+ */
+export function applyWarnings(edit: ProposedEdit, current: string, exists: boolean): string[] {
+  const warnings: string[] = [];
+  if (!exists) {
+    warnings.push(`${edit.path} doesn't exist yet and will be created`);
+  } else if (edit.attached?.content != null && edit.attached.content.replace(/\r\n/g, '\n') !== current.replace(/\r\n/g, '\n')) {
+    warnings.push(`${edit.path} has changed since the model read it`);
+  }
+  // A comment starting with "...", a line of just "...", or words to that effect
+  const placeholder = /^\s*(?:(?:\/\/|#|--|\/\*|<!--)\s*(?:\.\.\.|…)|(?:\.\.\.|…)\s*$)|\b(?:rest of (?:the )?(?:file|code)|existing code|remains? unchanged)\b/im;
+  if (placeholder.test(edit.content)) {
+    warnings.push('The proposed version seems to leave parts out ("...", "rest of the file"), which would delete them');
+  }
+  return warnings;
+}
@@ -0,0 +1,27 @@
+// Limits for images attached to a message, shared by the main process (reading
+// a file given to /attach) and the renderer (dropped or pasted images).
+
+// Image types every multimodal provider accepts as an attachment, by extension
+export const ATTACHMENT_EXTENSIONS: Record<string, string> = {
+  '.png': 'image/png',
+  '.jpg': 'image/jpeg',
+  '.jpeg': 'image/jpeg',
+  '.gif': 'image/gif',
+  '.webp': 'image/webp',
+};
+
+// The smallest per-image limit among the providers (Anthropic's)
+const MAX_ATTACHMENT_BYTES = 5 * 1024 * 1024;
+
+export const isAttachableType = (mimeType: string): boolean =>
+  Object.values(ATTACHMENT_EXTENSIONS).includes(mimeType.toLowerCase());
+
+/**
+ * Why an image of this many bytes can't be attached, or null if it can
+ */
+export function checkAttachmentSize(bytes: number): string | null {
+  if (bytes <= MAX_ATTACHMENT_BYTES) {
+    return null;
+  }
+  return `Image is ${(bytes / 1024 / 1024).toFixed(1)} MB; the limit is ${MAX_ATTACHMENT_BYTES / 1024 / 1024} MB`;
+}
@@ -0,0 +1,226 @@
+// Language detection and fencing for code and logs embedded in tool results.
+// Shared by the main process (formatting tool results for the model) and the
+// renderer (highlighting tool output in the transcript).
+
+import { stripAnsi } from './ansi';
+
+const EXTENSION_LANGUAGES: Record<string, string> = {
+  'ts': 'typescript',
+  'tsx': 'tsx',
+  'js': 'javascript',
+  'jsx': 'jsx',
+  'mjs': 'javascript',
+  'cjs': 'javascript',
+  'py': 'python',
+  'rb': 'ruby',
+  'go': 'go',
+  'rs': 'rust',
+  'java': 'java',
+  'kt': 'kotlin',
+  'swift': 'swift',
+  'c': 'c',
+  'cpp': 'cpp',
+  'h': 'c',
+  'hpp': 'cpp',
+  'cs': 'csharp',
+  'php': 'php',
+  'sh': 'bash',
+  'bash': 'bash',
+  'zsh': 'bash',
+  'json': 'json',
+  'yaml': 'yaml',
+  'yml': 'yaml',
+  'toml': 'toml',
+  'xml': 'xml',
+  'html': 'html',
+  'css': 'css',
+  'scss': 'scss',
+  'sass': 'sass',
+  'md': 'markdown',
+  'sql': 'sql',
+  'diff': 'diff',
+  'patch': 'diff',
+  'log': 'log',
+  'dockerfile': 'docker',
+  'makefile': 'makefile',
+};
+
+/**
+ * Helper to get the highlighting language for a file path
+ */
+export function getLanguageFromPath(filePath: string | undefined): string {
+  if (!filePath) return 'text';
+  const baseName = filePath.split(/[\\/]/).pop()?.toLowerCase() || '';
+  const ext = baseName.includes('.') ? baseName.split('.').pop() || '' : baseName;
+  return EXTENSION_LANGUAGES[ext] || 'text';
+}
+
+// Content heuristics, checked in order. Each matches against the first lines of the text.
+const CONTENT_PATTERNS: Array<{ language: string; pattern: RegExp }> = [
+  { language: 'diff', pattern: /^(diff --git |--- a\/|\+\+\+ b\/|@@ -\d+(,\d+)? \+\d+(,\d+)? @@)/m },
+  { language: 'python', pattern: /^Traceback \(most recent call last\):|^\s*(def |class \w+.*:|from \S+ import |import \w+$)/m },
+  { language: 'go', pattern: /^package \w+$|^func (\(\w+ \*?\w+\) )?\w+\(|^goroutine \d+ \[/m },
+  { language: 'rust', pattern: /^\s*(fn \w+|use \w+::|impl |pub (fn|struct|enum) )/m },
+  { language: 'typescript', pattern: /^\s*(import .* from ['"]|export (default |const |function |interface |type )|interface \w+ \{)/m },
+  { language: 'javascript', pattern: /^\s*(const|let|var) \w+ = |^\s*function \w+\(|^\s+at .+ \(.+:\d+:\d+\)$/m },
+  { language: 'html', pattern: /^\s*<(!DOCTYPE html|html|head|body|div)[\s>]/im },
+  { language: 'xml', pattern: /^\s*<\?xml /m },
+  { language: 'bash', pattern: /^#!\/(usr\/)?bin\/(env )?(ba|z)?sh|^\$ \S+/m },
+  { language: 'log', pattern: /^\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}|^\s*(ERROR|WARN(ING)?|INFO|DEBUG)[\s:\]]/m },
+  { language: 'yaml', pattern: /^[\w-]+:( .+)?\n(\s+[\w-]+:|\s*- )/m },
+];
+
+/**
+ * Guess the language of a block of text. Returns 'text' when nothing matches.
+ */
+export function detectLanguage(text: string, pathHint?: string): string {
+  const fromPath = getLanguageFromPath(pathHint);
+  if (fromPath !== 'text') {
+    return fromPath;
+  }
+
+  const trimmed = text.trim();
+  if (!trimmed) {
+    return 'text';
+  }
+
+  if ((trimmed.startsWith('{') || trimmed.startsWith('[')) && isJson(trimmed)) {
+    return 'json';
+  }
+
+  // Only look at the head of large outputs
+  const head = trimmed.split('\n').slice(0, 40).join('\n');
+  return CONTENT_PATTERNS.find(({ pattern }) => pattern.test(head))?.language || 'text';
+}
+
+function isJson(text: string): boolean {
+  try {
+    JSON.parse(text);
+    return true;
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * Wrap text in a markdown fence that can't be closed by backticks inside the text
+ */
+export function fenceCode(text: string, language: string): string {
+  const longestRun = Math.max(2, ...(text.match(/`+/g) || []).map(run => run.length));
+  const fence = '`'.repeat(longestRun + 1);
+  const tag = language === 'text' ? '' : language;
+  return `${fence}${tag}\n${text.replace(/\n$/, '')}\n${fence}`;
+}
+
+/**
+ * A file extension for a fence's language tag, e.g. "python" -> "py"
+ */
+export function extensionForLanguage(language: string): string | null {
+  const tag = language.toLowerCase();
+  const aliases: Record<string, string> = { bash: 'sh', zsh: 'sh', shell: 'sh', console: 'sh', 'c++': 'cpp', golang: 'go', text: 'txt', plaintext: 'txt' };
+  if (aliases[tag]) {
+    return aliases[tag];
+  }
+  if (tag in EXTENSION_LANGUAGES) {
+    return tag === 'dockerfile' || tag === 'makefile' ? null : tag;
+  }
+  return Object.keys(EXTENSION_LANGUAGES).find(ext => EXTENSION_LANGUAGES[ext] === tag) ?? null;
+}
+
+export interface FencedBlock {
+  info: string; // What follows the opening fence, e.g. "ts title=src/app.ts"
+  code: string;
+  before: string; // The last line of text above the fence, which often names the file
+}
+
+/**
+ * The fenced code blocks in markdown text, in order. An unclosed fence runs
+ * to the end, as it renders.
+ */
+export function extractFencedBlocks(markdown: string): FencedBlock[] {
+  const blocks: FencedBlock[] = [];
+  let fence: string | null = null;
+  let info = '';
+  let before = '';
+  let lines: string[] = [];
+  for (const line of markdown.split('\n')) {
+    const match = line.match(/^ {0,3}(`{3,}|~{3,})/);
+    if (fence === null) {
+      if (match) {
+        fence = match[1];
+        info = line.trim().substring(match[1].length).trim();
+        lines = [];
+      } else if (line.trim()) {
+        before = line.trim();
+      }
+    } else if (match && match[1][0] === fence[0] && match[1].length >= fence.length && !line.trim().substring(match[1].length).trim()) {
+      blocks.push({ info, code: lines.join('\n'), before });
+      fence = null;
+      before = '';
+    } else {
+      lines.push(line);
+    }
+  }
+  if (fence !== null) {
+    blocks.push({ info, code: lines.join('\n'), before });
+  }
+  return blocks;
+}
+
+/**
+ * The contents of the fenced code blocks in markdown text, in order
+ */
+export function extractCodeBlocks(markdown: string): string[] {
+  return extractFencedBlocks(markdown).map(block => block.code);
+}
+
+// Result fields that carry file contents or command output
+const TEXT_FIELDS = ['content', 'stdout', 'stderr', 'output', 'text', 'diff'];
+
+/**
+ * Format a stored tool result (JSON string) for the model: scalar fields stay
+ * as JSON, multi-line text fields are moved into language-tagged fences.
+ * Command output is sent as a terminal would show it, without escape codes.
+ */
+export function formatToolResultForModel(content: string, args: Record<string, unknown> = {}): string {
+  let parsed: unknown;
+  try {
+    parsed = JSON.parse(content);
+  } catch {
+    const text = stripAnsi(content);
+    return text.includes('\n') ? fenceCode(text, detectLanguage(text)) : text;
+  }
+
+  if (typeof parsed === 'string') {
+    const text = stripAnsi(parsed);
+    return text.includes('\n') ? fenceCode(text, detectLanguage(text)) : text;
+  }
+
+  if (!parsed || typeof parsed !== 'object' || Array.isArray(parsed)) {
+    return content;
+  }
+
+  const pathHint = [args.file_path, args.path].find((value): value is string => typeof value === 'string');
+  const fields: Record<string, unknown> = {};
+  const blocks: string[] = [];
+  let stripped = false;
+
+  for (const [key, raw] of Object.entries(parsed as Record<string, unknown>)) {
+    // File contents are sent as they are; only command output is cleaned up
+    const value = TEXT_FIELDS.includes(key) && typeof raw === 'string' && key !== 'content' ? stripAnsi(raw) : raw;
+    stripped = stripped || value !== raw;
+    if (TEXT_FIELDS.includes(key) && typeof value === 'string' && value.includes('\n')) {
+      // stdout/stderr are output, not the file's contents
+      const language = key === 'content' ? detectLanguage(value, pathHint) : detectLanguage(value);
+      blocks.push(`${key}:\n${fenceCode(value, language)}`);
+    } else {
+      fields[key] = value;
+    }
+  }
+
+  if (blocks.length === 0) {
+    return stripped ? JSON.stringify(fields) : content;
+  }
+
+  return [JSON.stringify(fields), ...blocks].join('\n\n');
+}
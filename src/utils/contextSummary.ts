@@ -0,0 +1,173 @@
+import type { ChatMessage, ContextSummary } from '../types/chat';
+import { withPinned } from './messageUtils';
+
+// Persisted as the "contextSummarizer" preference, used by the "summarize" context mode
+export interface ContextSummarizerSettings {
+  model?: string; // "providerId/modelId"; defaults to the session's model
+  keepRecent: number; // Most recent messages always sent verbatim
+  threshold: number; // Context usage (percent) at which older turns are summarized
+}
+
+export const DEFAULT_SUMMARIZER_SETTINGS: ContextSummarizerSettings = {
+  keepRecent: 6,
+  threshold: 80,
+};
+
+// Tool output is usually the bulk of a transcript and rarely needed word for word
+const MAX_TOOL_RESULT_CHARS = 2000;
+
+const SUMMARY_PROMPT = `You compress the earlier part of a conversation between a user and an AI assistant so it can continue with less context.
+Write a concise summary that keeps: the user's goals and constraints, decisions made, facts established, file paths, commands and identifiers that matter, results of tool calls, and any open questions or unfinished work.
+Leave out pleasantries and anything superseded later on. Write in the third person ("The user asked..."), as plain prose or short bullet points, without a preamble.`;
+
+export async function readSummarizerSettings(): Promise<ContextSummarizerSettings> {
+  const result = await window.electronAPI.preferencesGet('contextSummarizer');
+  const stored = result.success && result.value && typeof result.value === 'object'
+    ? result.value as Partial<ContextSummarizerSettings>
+    : {};
+  return { ...DEFAULT_SUMMARIZER_SETTINGS, ...stored };
+}
+
+/**
+ * Ids of messages replaced by a summary, including older summaries that a newer one folded in
+ */
+export const getSummarizedIds = (messages: ChatMessage[]): Set<string> => {
+  const ids = new Set<string>();
+  for (const message of messages) {
+    message.contextSummary?.messageIds.forEach(id => ids.add(id));
+  }
+  return ids;
+};
+
+/**
+ * Current summary message, if any
+ */
+export const getActiveSummary = (messages: ChatMessage[]): ChatMessage | null => {
+  const summarized = getSummarizedIds(messages);
+  for (let i = messages.length - 1; i >= 0; i--) {
+    if (messages[i].contextSummary && !summarized.has(messages[i].id)) {
+      return messages[i];
+    }
+  }
+  return null;
+};
+
+const formatSummarySection = (summary: string): string =>
+  `## Summary of the earlier conversation\n\n${summary}`;
+
+/**
+ * Replace summarized messages with their summary before sending, except pinned
+ * ones. The summary is appended to the system prompt, or becomes the first
+ * system message if there is none.
+ */
+export const applySummaries = (
+  messages: ChatMessage[],
+  systemPrompt: ChatMessage | null
+): { messages: ChatMessage[]; systemPrompt: ChatMessage | null } => {
+  const summary = getActiveSummary(messages);
+  if (!summary) {
+    return { messages, systemPrompt };
+  }
+
+  const summarized = getSummarizedIds(messages);
+  const remaining = withPinned(
+    messages.filter(m => !m.contextSummary && !summarized.has(m.id)),
+    messages.filter(m => !m.contextSummary)
+  );
+  const section = formatSummarySection(summary.content);
+
+  if (systemPrompt) {
+    return {
+      messages: remaining,
+      systemPrompt: { ...systemPrompt, content: `${systemPrompt.content}\n\n${section}` },
+    };
+  }
+  return {
+    messages: [{ ...summary, content: section }, ...remaining],
+    systemPrompt: null,
+  };
+};
+
+/**
+ * Oldest conversation messages that can be summarized while keeping the last
+ * `keepRecent` verbatim. The split lands on a user message so tool calls stay
+ * with their results.
+ */
+export const selectMessagesToSummarize = (messages: ChatMessage[], keepRecent: number): ChatMessage[] => {
+  const summarized = getSummarizedIds(messages);
+  const candidates = messages.filter(m => m.role !== 'system' && !summarized.has(m.id));
+
+  let split = candidates.length - Math.max(keepRecent, 1);
+  while (split > 0 && candidates[split].role !== 'user') {
+    split--;
+  }
+  return split > 0 ? candidates.slice(0, split) : [];
+};
+
+const formatTranscript = (messages: ChatMessage[]): string => {
+  const lines: string[] = [];
+  for (const message of messages) {
+    if (message.role === 'tool') {
+      const result = message.content.length > MAX_TOOL_RESULT_CHARS
+        ? `${message.content.substring(0, MAX_TOOL_RESULT_CHARS)}… [truncated]`
+        : message.content;
+      lines.push(`Tool result: ${result}`);
+      continue;
+    }
+    const speaker = message.role === 'user' ? 'User' : 'Assistant';
+    if (message.content) {
+      lines.push(`${speaker}: ${message.content}`);
+    }
+    for (const toolCall of message.tool_calls || []) {
+      lines.push(`${speaker} called ${toolCall.function.name} with ${toolCall.function.arguments}`);
+    }
+  }
+  return lines.join('\n\n');
+};
+
+/**
+ * Summarize `messages` (folding in the previous summary) with the given model and
+ * return the system message that replaces them
+ */
+export async function summarizeMessages(
+  messages: ChatMessage[],
+  previousSummary: ChatMessage | null,
+  providerId: string,
+  modelId: string
+): Promise<ChatMessage> {
+  const transcript = formatTranscript(messages);
+  const content = previousSummary
+    ? `Summary so far:\n\n${previousSummary.content}\n\nConversation since then:\n\n${transcript}`
+    : `Conversation:\n\n${transcript}`;
+
+  const result = await window.electronAPI.chatComplete({
+    provider: providerId,
+    model: modelId,
+    messages: [
+      { role: 'system', content: SUMMARY_PROMPT },
+      { role: 'user', content },
+    ],
+  });
+  if (!result.success) {
+    throw new Error(result.error || 'Summarizer request failed');
+  }
+  if (!result.content) {
+    throw new Error('Summarizer returned an empty summary');
+  }
+
+  const contextSummary: ContextSummary = {
+    messageIds: [
+      ...(previousSummary ? [previousSummary.id] : []),
+      ...messages.map(m => m.id),
+    ],
+    providerId,
+    modelId,
+  };
+  return {
+    id: `summary-${Date.now()}`,
+    role: 'system',
+    content: result.content,
+    timestamp: Date.now(),
+    contextSummary,
+  };
+}
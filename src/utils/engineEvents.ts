@@ -0,0 +1,10 @@
+import type { EngineEventKind } from '../types/chat';
+
+/**
+ * Add an event from the renderer's side of the engine (tool result cache,
+ * hooks) to the main process's event log, for the /events pane
+ */
+export function recordEngineEvent(kind: EngineEventKind, scope: string, message: string, fields?: Record<string, unknown>) {
+  window.electronAPI.engineEventRecord({ kind, scope, message, fields })
+    .catch(error => console.error('Failed to record engine event:', error));
+}
@@ -0,0 +1,277 @@
+import type { ChatMessage } from '../types/chat';
+import { renderTranscriptHtml, type TranscriptMeta } from '../components/chat/TranscriptHtml';
+import { redactToolArgsJson } from '../tools/redaction';
+
+export type ExportFormat = 'html' | 'markdown' | 'json' | 'eval' | 'finetune' | 'modelfile';
+
+export const EXPORT_FORMATS: ExportFormat[] = ['html', 'markdown', 'json', 'eval', 'finetune', 'modelfile'];
+
+const FORMAT_FILTERS: Record<ExportFormat, { name: string; extensions: string[] }> = {
+  html: { name: 'HTML', extensions: ['html'] },
+  markdown: { name: 'Markdown', extensions: ['md'] },
+  json: { name: 'JSON', extensions: ['json'] },
+  eval: { name: 'JSON Lines', extensions: ['jsonl'] },
+  finetune: { name: 'JSON Lines', extensions: ['jsonl'] },
+  modelfile: { name: 'Ollama Modelfile', extensions: ['Modelfile'] },
+};
+
+/**
+ * Export format for a file name, from its extension
+ */
+export function formatForPath(filePath: string): ExportFormat | null {
+  const extension = filePath.split('.').pop()?.toLowerCase();
+  if (extension === 'markdown') {
+    return 'markdown';
+  }
+  if (extension === 'modelfile' || /(^|[\\/])Modelfile$/.test(filePath)) {
+    return 'modelfile';
+  }
+  const format = EXPORT_FORMATS.find(f => FORMAT_FILTERS[f].extensions.includes(extension || ''));
+  return format ?? null;
+}
+
+/**
+ * Build a filesystem-safe default file name from the session name
+ */
+export function exportFileName(title: string, format: ExportFormat): string {
+  const base = title.trim().replace(/[^\w.-]+/g, '-').replace(/^-+|-+$/g, '') || 'transcript';
+  if (format === 'eval' || format === 'finetune') {
+    return `${base}-${format}.${FORMAT_FILTERS[format].extensions[0]}`;
+  }
+  return `${base}.${FORMAT_FILTERS[format].extensions[0]}`;
+}
+
+/**
+ * One JSON line per rated assistant message: the conversation up to that point,
+ * the response, which model produced it, and the rating. Lines from several
+ * sessions can be concatenated to compare models on the same prompts.
+ */
+export function formatEvalDataset(messages: ChatMessage[], meta: TranscriptMeta): string {
+  const lines: string[] = [];
+
+  messages.forEach((message, index) => {
+    if (message.role !== 'assistant' || !message.rating) {
+      return;
+    }
+
+    const prompt = messages.slice(0, index).map(m => ({
+      role: m.role,
+      content: m.content,
+      ...(m.tool_calls && { tool_calls: m.tool_calls }),
+      ...(m.tool_call_id && { tool_call_id: m.tool_call_id }),
+    }));
+
+    lines.push(JSON.stringify({
+      session: meta.title,
+      provider: message.modelOverride?.providerId ?? meta.providerId ?? null,
+      model: message.modelOverride?.modelId ?? meta.modelId ?? null,
+      prompt,
+      response: message.content,
+      rating: message.rating.value,
+      note: message.rating.note ?? null,
+      rated_at: new Date(message.rating.ratedAt).toISOString(),
+    }));
+  });
+
+  return lines.length > 0 ? `${lines.join('\n')}\n` : '';
+}
+
+// Turns worth training on: user and assistant text, with the exchanges ending in
+// a response rated bad left out. Tool calls and results are dropped.
+function curatedTurns(messages: ChatMessage[]): ChatMessage[] {
+  const turns: ChatMessage[] = [];
+  let pending: ChatMessage[] = [];
+  for (const message of messages) {
+    if (message.role === 'tool' || message.contextSummary || !message.content.trim()) {
+      continue;
+    }
+    if (message.role !== 'assistant') {
+      pending.push(message);
+      continue;
+    }
+    if (message.rating?.value !== 'bad') {
+      turns.push(...pending, message);
+    }
+    pending = [];
+  }
+  return turns;
+}
+
+/**
+ * OpenAI chat fine-tuning data: one JSON line holding the whole conversation.
+ * Responses rated bad keep their place in the history with weight 0, so the
+ * model isn't trained on them; tool calls and results are kept as they were sent.
+ */
+export function formatFineTuneJsonl(messages: ChatMessage[], meta: TranscriptMeta): string {
+  const example = [
+    ...(meta.systemPrompt ? [{ role: 'system', content: meta.systemPrompt }] : []),
+    ...messages
+      .filter(m => !m.contextSummary && (m.content.trim() || m.tool_calls))
+      .map(m => ({
+        role: m.role,
+        content: m.content,
+        ...(m.tool_calls && { tool_calls: m.tool_calls }),
+        ...(m.tool_call_id && { tool_call_id: m.tool_call_id }),
+        ...(m.role === 'assistant' && { weight: m.rating?.value === 'bad' ? 0 : 1 }),
+      })),
+  ];
+  if (!example.some(m => m.role === 'assistant')) {
+    return '';
+  }
+  return `${JSON.stringify({ messages: example })}\n`;
+}
+
+// Modelfile strings in triple quotes end at the first """, which can't be escaped
+const modelfileString = (text: string) => `"""${text.replace(/"""/g, '"\u200b""')}"""`;
+
+/**
+ * An Ollama Modelfile that starts from the session's model with the conversation
+ * as MESSAGE history, for "ollama create <name> -f <file>"
+ */
+export function formatModelfile(messages: ChatMessage[], meta: TranscriptMeta): string {
+  const turns = curatedTurns(messages);
+  if (!turns.some(m => m.role === 'assistant')) {
+    return '';
+  }
+  const lines = [
+    `# ${meta.title}, exported ${new Date(meta.exportedAt).toISOString()}`,
+    `FROM ${meta.modelId || '<model>'}`,
+  ];
+  if (meta.systemPrompt) {
+    lines.push(`SYSTEM ${modelfileString(meta.systemPrompt)}`);
+  }
+  for (const turn of turns) {
+    lines.push(`MESSAGE ${turn.role} ${modelfileString(turn.content)}`);
+  }
+  return `${lines.join('\n')}\n`;
+}
+
+// A fence longer than any backtick run in the text, so code in it can't close the block
+function fenced(text: string, language = ''): string {
+  const longestRun = Math.max(0, ...(text.match(/`+/g) || []).map(run => run.length));
+  const fence = '`'.repeat(Math.max(3, longestRun + 1));
+  return `${fence}${language}\n${text}\n${fence}`;
+}
+
+function formatToolArguments(args: string): string {
+  try {
+    return JSON.stringify(JSON.parse(args), null, 2);
+  } catch {
+    return args;
+  }
+}
+
+/**
+ * Markdown for reading or sharing: headings per message, reasoning in collapsible
+ * sections, and each tool call followed by its result
+ */
+export function formatMarkdown(messages: ChatMessage[], meta: TranscriptMeta): string {
+  const results = new Map(messages.filter(m => m.role === 'tool' && m.tool_call_id).map(m => [m.tool_call_id, m]));
+  const details = [
+    new Date(meta.exportedAt).toLocaleString(),
+    meta.providerId && meta.modelId ? `${meta.providerId}/${meta.modelId}` : null,
+    meta.workingDirectory ? `\`${meta.workingDirectory}\`` : null,
+  ].filter(Boolean);
+  const sections = [`# ${meta.title}`, `_${details.join(' · ')}_`];
+
+  for (const message of messages) {
+    if (message.role === 'tool') {
+      continue;
+    }
+
+    const model = message.modelOverride ? ` (${message.modelOverride.providerId}/${message.modelOverride.modelId})` : '';
+    const heading = message.contextSummary
+      ? `## Summary of ${message.contextSummary.messageIds.length} earlier messages`
+      : `## ${message.role.charAt(0).toUpperCase()}${message.role.slice(1)}${model}`;
+    const parts = [heading];
+
+    if (message.thinking) {
+      parts.push(`<details>\n<summary>Thinking</summary>\n\n${message.thinking}\n\n</details>`);
+    }
+    if (message.content) {
+      parts.push(message.content);
+    }
+    for (const toolCall of message.tool_calls || []) {
+      parts.push(`**Tool call:** \`${toolCall.function.name}\`\n\n${fenced(formatToolArguments(toolCall.function.arguments), 'json')}`);
+      const result = results.get(toolCall.id);
+      parts.push(result
+        ? `**Result:**\n\n${fenced(result.content)}`
+        : '**Result:** _none_');
+    }
+    if (message.rating) {
+      parts.push(`_Rated ${message.rating.value}${message.rating.note ? `: ${message.rating.note}` : ''}_`);
+    }
+    sections.push(parts.join('\n\n'));
+  }
+
+  return `${sections.join('\n\n')}\n`;
+}
+
+/**
+ * The full message history with session details, for archiving or other tools
+ */
+export function formatJson(messages: ChatMessage[], meta: TranscriptMeta): string {
+  return `${JSON.stringify({
+    title: meta.title,
+    provider: meta.providerId ?? null,
+    model: meta.modelId ?? null,
+    workingDirectory: meta.workingDirectory ?? null,
+    exportedAt: new Date(meta.exportedAt).toISOString(),
+    messages,
+  }, null, 2)}\n`;
+}
+
+/**
+ * Serialize a conversation in the given format, with sensitive tool arguments masked
+ */
+export function formatTranscript(transcript: ChatMessage[], format: ExportFormat, meta: TranscriptMeta): string {
+  const messages = transcript.map(m => m.tool_calls
+    ? {
+      ...m,
+      tool_calls: m.tool_calls.map(tc => ({
+        ...tc,
+        function: { ...tc.function, arguments: redactToolArgsJson(tc.function.name, tc.function.arguments) },
+      })),
+    }
+    : m);
+  switch (format) {
+    case 'html':
+      return renderTranscriptHtml(messages, meta);
+    case 'markdown':
+      return formatMarkdown(messages, meta);
+    case 'json':
+      return formatJson(messages, meta);
+    case 'eval':
+      return formatEvalDataset(messages, meta);
+    case 'finetune':
+      return formatFineTuneJsonl(messages, meta);
+    case 'modelfile':
+      return formatModelfile(messages, meta);
+  }
+}
+
+/**
+ * Write the transcript to `filePath` (relative paths are in the project directory),
+ * or ask where to save it. Returns the saved path, or null if cancelled.
+ */
+export async function exportTranscript(messages: ChatMessage[], format: ExportFormat, meta: TranscriptMeta, filePath?: string): Promise<string | null> {
+  const content = formatTranscript(messages, format, meta);
+  if (format === 'eval' && !content) {
+    throw new Error('No rated messages to export. Rate responses with /rate first.');
+  }
+  if ((format === 'finetune' || format === 'modelfile') && !content) {
+    throw new Error('No responses to train on yet');
+  }
+  const result = await window.electronAPI.exportSaveFile(
+    exportFileName(meta.title, format),
+    content,
+    [FORMAT_FILTERS[format]],
+    filePath,
+    meta.workingDirectory
+  );
+
+  if (!result.success) {
+    throw new Error(result.error || 'Failed to save export');
+  }
+  return result.filePath;
+}
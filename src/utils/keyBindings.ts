@@ -0,0 +1,204 @@
+// Persisted as the "keyBindings" preference. Keys are written as modifiers
+// and a key joined with "+": "Enter", "Ctrl+Enter", "Mod+Shift+L", "Escape".
+// Mod is Cmd on macOS and Ctrl elsewhere. `commands` binds keys to slash
+// commands, e.g. { "Mod+Shift+L": "/clear" }. Shared with the main process,
+// which sets the Quit menu item's accelerator from it.
+
+export interface KeyBindings {
+  send: string;
+  newline: string;
+  cancel: string; // While a response streams: drop it
+  stop: string; // While a response streams: end it and keep what came in
+  quit: string;
+  commands: Record<string, string>;
+}
+
+export const DEFAULT_KEY_BINDINGS: KeyBindings = {
+  send: 'Enter',
+  newline: 'Shift+Enter',
+  cancel: 'Escape',
+  stop: 'S',
+  quit: 'Mod+Q',
+  commands: {},
+};
+
+export const KEY_ACTIONS = ['send', 'newline', 'cancel', 'stop', 'quit'] as const;
+
+interface KeyCombo {
+  key: string; // As in KeyboardEvent.key, lowercased when it's a single character
+  ctrl: boolean;
+  shift: boolean;
+  alt: boolean;
+  meta: boolean;
+  mod: boolean;
+}
+
+interface KeyPress {
+  key: string;
+  ctrlKey: boolean;
+  shiftKey: boolean;
+  altKey: boolean;
+  metaKey: boolean;
+}
+
+const KEY_NAMES: Record<string, string> = {
+  enter: 'Enter',
+  return: 'Enter',
+  esc: 'Escape',
+  escape: 'Escape',
+  tab: 'Tab',
+  space: ' ',
+  backspace: 'Backspace',
+  delete: 'Delete',
+  del: 'Delete',
+  up: 'ArrowUp',
+  down: 'ArrowDown',
+  left: 'ArrowLeft',
+  right: 'ArrowRight',
+  home: 'Home',
+  end: 'End',
+  pageup: 'PageUp',
+  pagedown: 'PageDown',
+  plus: '+',
+};
+
+const MODIFIERS: Record<string, keyof Omit<KeyCombo, 'key'>> = {
+  ctrl: 'ctrl',
+  control: 'ctrl',
+  shift: 'shift',
+  alt: 'alt',
+  option: 'alt',
+  meta: 'meta',
+  cmd: 'meta',
+  command: 'meta',
+  mod: 'mod',
+  cmdorctrl: 'mod',
+};
+
+/**
+ * A binding split into its modifiers and key, or null when it isn't one
+ */
+export function parseKey(binding: string): KeyCombo | null {
+  const parts = binding.trim().split('+');
+  // "Ctrl++" ends with an empty part for the plus key
+  if (parts.length > 1 && parts[parts.length - 1] === '' && parts[parts.length - 2] === '') {
+    parts.splice(-2, 2, '+');
+  }
+  const name = parts.pop()?.trim();
+  if (!name) {
+    return null;
+  }
+  const combo: KeyCombo = { key: '', ctrl: false, shift: false, alt: false, meta: false, mod: false };
+  for (const part of parts) {
+    const modifier = MODIFIERS[part.trim().toLowerCase()];
+    if (!modifier) {
+      return null;
+    }
+    combo[modifier] = true;
+  }
+  const named = KEY_NAMES[name.toLowerCase()] ?? (/^f([1-9]|1[0-9]|2[0-4])$/i.test(name) ? name.toUpperCase() : null);
+  if (named) {
+    combo.key = named;
+  } else if (name.length === 1) {
+    combo.key = name.toLowerCase();
+  } else {
+    return null;
+  }
+  return combo;
+}
+
+/**
+ * Whether a key press is the binding. A symbol typed with Shift ("?", "+")
+ * matches without the binding saying Shift, and so does a letter bound on its
+ * own ("S" matches s and S). With Ctrl, Alt or Meta, Shift has to match, so
+ * "Mod+K" and "Mod+Shift+K" stay apart.
+ */
+export function matchesKey(binding: string, e: KeyPress, isMac: boolean): boolean {
+  const combo = parseKey(binding);
+  if (!combo) {
+    return false;
+  }
+  const key = e.key.length === 1 ? e.key.toLowerCase() : e.key;
+  const symbol = combo.key.length === 1 && !/[a-z0-9 ]/.test(combo.key);
+  const plainLetter = /^[a-z]$/.test(combo.key) && !combo.ctrl && !combo.alt && !combo.meta && !combo.mod;
+  return key === combo.key &&
+    e.ctrlKey === (combo.ctrl || (combo.mod && !isMac)) &&
+    e.metaKey === (combo.meta || (combo.mod && isMac)) &&
+    e.altKey === combo.alt &&
+    ((symbol || plainLetter) && !combo.shift ? true : e.shiftKey === combo.shift);
+}
+
+/**
+ * A binding as shown to the user, e.g. "⌘+Shift+L" on macOS
+ */
+export function formatKey(binding: string, isMac: boolean): string {
+  const combo = parseKey(binding);
+  if (!combo) {
+    return binding;
+  }
+  const parts: string[] = [];
+  if (combo.ctrl || (combo.mod && !isMac)) parts.push('Ctrl');
+  if (combo.alt) parts.push(isMac ? '⌥' : 'Alt');
+  if (combo.shift) parts.push('Shift');
+  if (combo.meta || (combo.mod && isMac)) parts.push(isMac ? '⌘' : 'Meta');
+  const key = combo.key === ' ' ? 'Space' : combo.key === 'Escape' ? 'Esc' : combo.key.length === 1 ? combo.key.toUpperCase() : combo.key;
+  return [...parts, key].join('+');
+}
+
+/**
+ * A binding as an Electron menu accelerator, or null when it can't be one
+ */
+export function toAccelerator(binding: string): string | null {
+  const combo = parseKey(binding);
+  if (!combo || combo.key === ' ') {
+    return null;
+  }
+  const parts: string[] = [];
+  if (combo.mod) parts.push('CmdOrCtrl');
+  if (combo.ctrl) parts.push('Ctrl');
+  if (combo.meta) parts.push('Command');
+  if (combo.alt) parts.push('Alt');
+  if (combo.shift) parts.push('Shift');
+  const key = combo.key === 'Escape' ? 'Esc' : combo.key === 'Enter' ? 'Return' : combo.key.startsWith('Arrow') ? combo.key.slice(5) : combo.key.length === 1 ? combo.key.toUpperCase() : combo.key;
+  return [...parts, key].join('+');
+}
+
+/**
+ * The stored preference over the defaults, with what couldn't be used
+ */
+export function resolveKeyBindings(stored: unknown): { bindings: KeyBindings; problems: string[] } {
+  const bindings: KeyBindings = { ...DEFAULT_KEY_BINDINGS, commands: {} };
+  const problems: string[] = [];
+  if (!stored || typeof stored !== 'object') {
+    return { bindings, problems };
+  }
+  const record = stored as Record<string, unknown>;
+  for (const action of KEY_ACTIONS) {
+    const value = record[action];
+    if (value === undefined) {
+      continue;
+    }
+    if (typeof value === 'string' && parseKey(value)) {
+      bindings[action] = value;
+    } else {
+      problems.push(`${action}: "${String(value)}" is not a key`);
+    }
+  }
+  if (record.commands && typeof record.commands === 'object') {
+    for (const [key, command] of Object.entries(record.commands as Record<string, unknown>)) {
+      if (!parseKey(key)) {
+        problems.push(`commands: "${key}" is not a key`);
+      } else if (typeof command !== 'string' || !command.trim().startsWith('/')) {
+        problems.push(`commands: ${key} should run a slash command such as "/clear"`);
+      } else {
+        bindings.commands[key] = command.trim();
+      }
+    }
+  }
+  if (formatKey(bindings.send, false) === formatKey(bindings.newline, false)) {
+    problems.push(`send and newline are both ${bindings.send}; Enter sends and Shift+Enter starts a new line instead`);
+    bindings.send = DEFAULT_KEY_BINDINGS.send;
+    bindings.newline = DEFAULT_KEY_BINDINGS.newline;
+  }
+  return { bindings, problems };
+}
@@ -0,0 +1,9 @@
+// English name of a BCP 47 language code ("pt-BR" is "Brazilian Portuguese"),
+// or the code itself when it isn't one
+export const languageName = (code: string): string => {
+  try {
+    return new Intl.DisplayNames(['en'], { type: 'language' }).of(code) ?? code;
+  } catch {
+    return code;
+  }
+};
@@ -0,0 +1,18 @@
+// The text last selected in the window, for {{selection}} in /prompt
+// templates. Typing the command moves focus to the input box, which clears the
+// page selection, so the selection is remembered as it changes and changes
+// while an input has focus are ignored.
+
+let lastSelection = '';
+
+if (typeof document !== 'undefined') {
+  document.addEventListener('selectionchange', () => {
+    const active = document.activeElement;
+    if (active instanceof HTMLInputElement || active instanceof HTMLTextAreaElement) {
+      return;
+    }
+    lastSelection = window.getSelection()?.toString() ?? '';
+  });
+}
+
+export const getLastSelection = () => lastSelection;
@@ -22,6 +22,117 @@ export const ensureSystemPromptFirst = (messages: ChatMessage[], systemPrompt: C
   return [...systemMessages, ...otherMessages];
 };
 
+/**
+ * Messages shown in the transcript (tool results render inside their tool call)
+ */
+const isNumberedMessage = (message: ChatMessage): boolean =>
+  message.role === 'user' || message.role === 'assistant';
+
+/**
+ * 1-based number of a message as shown in the transcript, or null for tool/system messages
+ */
+export const getMessageNumber = (messages: ChatMessage[], messageId: string): number | null => {
+  let number = 0;
+  for (const message of messages) {
+    if (isNumberedMessage(message)) {
+      number++;
+      if (message.id === messageId) {
+        return number;
+      }
+    }
+  }
+  return null;
+};
+
+/**
+ * Find a message by its transcript number
+ */
+export const findMessageByNumber = (messages: ChatMessage[], number: number): ChatMessage | null => {
+  return messages.filter(isNumberedMessage)[number - 1] ?? null;
+};
+
+/**
+ * Parse a transcript range for /merge: "5", "3-7", or "3-" (to the end)
+ */
+export const parseMessageRange = (range: string): { from: number; to: number } | null => {
+  const match = range.match(/^(\d+)(?:-(\d*))?$/);
+  if (!match) {
+    return null;
+  }
+  const from = parseInt(match[1], 10);
+  const to = match[2] === undefined ? from : match[2] === '' ? Infinity : parseInt(match[2], 10);
+  return from >= 1 && to >= from ? { from, to } : null;
+};
+
+/**
+ * Messages numbered `from` to `to` in the transcript, with the tool results that
+ * belong to the included tool calls
+ */
+export const selectMessageRange = (messages: ChatMessage[], from: number, to: number): ChatMessage[] => {
+  const selected: ChatMessage[] = [];
+  const toolCallIds = new Set<string>();
+  let number = 0;
+  for (const message of messages) {
+    if (isNumberedMessage(message)) {
+      number++;
+      if (number >= from && number <= to) {
+        selected.push(message);
+        message.tool_calls?.forEach(tc => toolCallIds.add(tc.id));
+      }
+    } else if (message.role === 'tool' && message.tool_call_id && toolCallIds.has(message.tool_call_id)) {
+      selected.push(message);
+    }
+  }
+  return selected;
+};
+
+/**
+ * `kept` with the pinned messages of `all` it left out put back in their
+ * place, along with the results of their tool calls
+ */
+export const withPinned = (kept: ChatMessage[], all: ChatMessage[]): ChatMessage[] => {
+  const keptIds = new Set(kept.map(m => m.id));
+  const toolCallIds = new Set<string>();
+  const restored = new Set<string>();
+  for (const message of all) {
+    if (message.pinned && !keptIds.has(message.id)) {
+      restored.add(message.id);
+      message.tool_calls?.forEach(tc => toolCallIds.add(tc.id));
+    } else if (message.role === 'tool' && message.tool_call_id && toolCallIds.has(message.tool_call_id) && !keptIds.has(message.id)) {
+      restored.add(message.id);
+    }
+  }
+  if (restored.size === 0) {
+    return kept;
+  }
+  return all.filter(m => keptIds.has(m.id) || restored.has(m.id));
+};
+
+/**
+ * Copies of messages from another session for /merge. Message and tool call ids
+ * are renamed so merging twice can't collide.
+ */
+export const copyMessagesForMerge = (
+  messages: ChatMessage[],
+  source: { sessionId: string; sessionName: string }
+): ChatMessage[] => {
+  const stamp = Date.now();
+  const toolCallId = (id: string) => `${id}-merged-${stamp}`;
+  return messages.map((message, index) => ({
+    id: `merged-${stamp}-${index}`,
+    role: message.role,
+    content: message.content,
+    timestamp: message.timestamp,
+    ...(message.thinking && { thinking: message.thinking }),
+    ...(message.images && { images: message.images }),
+    ...(message.tool_calls && {
+      tool_calls: message.tool_calls.map(tc => ({ ...tc, id: toolCallId(tc.id) })),
+    }),
+    ...(message.tool_call_id && { tool_call_id: toolCallId(message.tool_call_id) }),
+    mergedFrom: source,
+  }));
+};
+
 /**
  * Helper function to get display name for a session
  */
@@ -36,21 +147,49 @@ export const getSessionDisplayName = (sessionId: string, customName: string, isC
   return `Session ${sessionId.substring(0, 8)}`;
 };
 
+/**
+ * Resolve a session reference given on the command line or to /load: an exact
+ * id, a unique id prefix, or a session name (case-insensitive)
+ */
+export const findSessionByRef = <T extends { id: string; name: string }>(sessions: T[], ref: string): T | undefined => {
+  const needle = ref.trim();
+  if (!needle) {
+    return undefined;
+  }
+  const exact = sessions.find(s => s.id === needle);
+  if (exact) {
+    return exact;
+  }
+  const byName = sessions.find(s => s.name.toLowerCase() === needle.toLowerCase());
+  if (byName) {
+    return byName;
+  }
+  const byPrefix = sessions.filter(s => s.id.startsWith(needle));
+  return byPrefix.length === 1 ? byPrefix[0] : undefined;
+};
+
+/**
+ * Rough estimation of a single message's tokens: 1 token ≈ 4 characters
+ */
+export const estimateMessageTokens = (message: ChatMessage): number => {
+  let totalChars = message.content.length;
+  // Add some overhead for role, formatting, and tool calls
+  if (message.tool_calls && message.tool_calls.length > 0) {
+    for (const toolCall of message.tool_calls) {
+      totalChars += toolCall.function.name.length;
+      totalChars += toolCall.function.arguments.length;
+    }
+  }
+  return Math.ceil(totalChars / 4);
+};
+
 /**
  * Rough estimation of token usage (since Ollama doesn't report tokens)
  */
 export const estimateTokenUsage = (messages: ChatMessage[]): number => {
-  // Rough estimate: 1 token ≈ 4 characters
-  let totalChars = 0;
+  let total = 0;
   for (const message of messages) {
-    totalChars += message.content.length;
-    // Add some overhead for role, formatting, and tool calls
-    if (message.tool_calls && message.tool_calls.length > 0) {
-      for (const toolCall of message.tool_calls) {
-        totalChars += toolCall.function.name.length;
-        totalChars += toolCall.function.arguments.length;
-      }
-    }
+    total += estimateMessageTokens(message);
   }
-  return Math.ceil(totalChars / 4);
+  return total;
 };
@@ -0,0 +1,68 @@
+import type { ProviderConfig, ModelConfig } from '../types/chat';
+
+const LOCAL_HOSTS = new Set(['localhost', '127.0.0.1', '::1', '[::1]']);
+
+/**
+ * Whether a URL points at this machine (loopback host)
+ */
+export const isLocalURL = (url: string): boolean => {
+  try {
+    const hostname = new URL(url).hostname;
+    return LOCAL_HOSTS.has(hostname) || hostname.startsWith('127.');
+  } catch {
+    return false;
+  }
+};
+
+/**
+ * Whether a provider is an Ollama server on this machine (the only kind allowed in offline mode)
+ */
+export const isLocalProvider = (provider: { type: string; baseURL: string }): boolean => {
+  return provider.type === 'ollama' && isLocalURL(provider.baseURL);
+};
+
+export interface ModelSelection {
+  provider: ProviderConfig;
+  model: ModelConfig;
+}
+
+/**
+ * Find a chat model by "providerId/modelId", model id, or model name
+ */
+export const findModelByRef = (providers: ProviderConfig[], ref: string): ModelSelection | null => {
+  const enabled = providers.filter(p => p.enabled);
+  const needle = ref.trim().toLowerCase();
+
+  const slashIndex = needle.indexOf('/');
+  if (slashIndex > 0) {
+    const provider = enabled.find(p => p.id.toLowerCase() === needle.substring(0, slashIndex));
+    const modelRef = needle.substring(slashIndex + 1);
+    const model = provider?.models.find(m => m.type === 'chat' && m.id.toLowerCase() === modelRef);
+    if (provider && model) {
+      return { provider, model };
+    }
+  }
+
+  for (const provider of enabled) {
+    const model = provider.models.find(m =>
+      m.type === 'chat' && (m.id.toLowerCase() === needle || m.name.toLowerCase() === needle)
+    );
+    if (model) {
+      return { provider, model };
+    }
+  }
+
+  return null;
+};
+
+/**
+ * Split "@model-name: prompt" into the model reference and the prompt.
+ * Model ids may contain colons (e.g. "llama3:8b"), so the reference ends at ": ".
+ */
+export const parseModelOverride = (input: string): { modelRef: string; text: string } | null => {
+  const match = input.trim().match(/^@(\S+?):\s+([\s\S]+)$/);
+  if (!match) {
+    return null;
+  }
+  return { modelRef: match[1], text: match[2].trim() };
+};
@@ -0,0 +1,96 @@
+// Message templates for /prompt: Markdown files in ~/.config/poe/templates,
+// one per template, named after the file. A template may start with front
+// matter:
+//
+//   ---
+//   description: Review a piece of code
+//   send: true
+//   ---
+//   Review this for bugs:
+//
+//   {{.Selection}}
+//
+// Variables use Go template syntax, with or without the leading dot, in any
+// case ({{.Date}}, {{date}}):
+//
+//   args       everything typed after the template name
+//   arg1, ...  one argument each, "quoted strings" kept together
+//   selection  the text last selected in the window
+//   clipboard  the clipboard's text
+//   date       today, as 2024-05-31
+//   time       the time, as 14:05
+//   project    the open project's directory
+//
+// Without send: true the expanded text goes into the input box for review;
+// `/prompt --send <name>` sends it either way.
+
+export interface PromptTemplate {
+  description?: string;
+  send: boolean;
+  body: string;
+}
+
+export type TemplateValues = Record<string, string>;
+
+const VARIABLE_PATTERN = /\{\{-?\s*\.?([A-Za-z][A-Za-z0-9_]*)\s*-?\}\}/g;
+
+const KNOWN_VARIABLES = ['args', 'selection', 'clipboard', 'date', 'time', 'project'];
+
+/**
+ * Split a template file into its front matter and body
+ */
+export function parsePromptTemplate(content: string): PromptTemplate {
+  const match = content.match(/^---\r?\n([\s\S]*?)\r?\n---\r?\n?/);
+  if (!match) {
+    return { send: false, body: content };
+  }
+
+  const fields: Record<string, string> = {};
+  for (const line of match[1].split(/\r?\n/)) {
+    const field = line.match(/^\s*([A-Za-z]+)\s*:\s*(.*?)\s*$/);
+    if (field) {
+      fields[field[1].toLowerCase()] = field[2].replace(/^(["'])(.*)\1$/, '$2');
+    }
+  }
+  return {
+    description: fields.description || undefined,
+    send: fields.send === 'true',
+    body: content.substring(match[0].length),
+  };
+}
+
+/**
+ * The variables a template uses, lowercased, so only those are looked up
+ */
+export function templateVariables(body: string): string[] {
+  return [...new Set(Array.from(body.matchAll(VARIABLE_PATTERN), m => m[1].toLowerCase()))];
+}
+
+/**
+ * Substitute the variables in a template body. Unknown variables are an error
+ * rather than left in the message.
+ */
+export function expandPromptTemplate(body: string, values: TemplateValues): string {
+  const unknown = templateVariables(body).filter(name => !KNOWN_VARIABLES.includes(name) && !/^arg\d+$/.test(name));
+  if (unknown.length > 0) {
+    throw new Error(`Unknown template variable${unknown.length > 1 ? 's' : ''}: ${unknown.join(', ')}`);
+  }
+  return body.replace(VARIABLE_PATTERN, (_, name: string) => values[name.toLowerCase()] ?? '').trim();
+}
+
+/**
+ * Values for the argument and date variables. The selection, clipboard and
+ * project come from the window.
+ */
+export function templateArgumentValues(args: string[], rawArgs: string, now = new Date()): TemplateValues {
+  const pad = (n: number) => String(n).padStart(2, '0');
+  const values: TemplateValues = {
+    args: rawArgs,
+    date: `${now.getFullYear()}-${pad(now.getMonth() + 1)}-${pad(now.getDate())}`,
+    time: `${pad(now.getHours())}:${pad(now.getMinutes())}`,
+  };
+  args.forEach((arg, i) => {
+    values[`arg${i + 1}`] = arg;
+  });
+  return values;
+}
@@ -0,0 +1,70 @@
+import type { ChatMessage } from '../types/chat';
+import type { ModelSelection } from './modelUtils';
+import { getActiveSummary } from './contextSummary';
+
+// Persisted as the "sessionRecap" preference. When a session with some
+// history is opened, a line or two on where it left off is asked for and
+// shown above the conversation until the next prompt is sent.
+export interface SessionRecapSettings {
+  enabled: boolean;
+  model?: string; // "providerId/modelId"; defaults to the session's model
+  minMessages: number; // Shorter sessions are easy enough to read back
+}
+
+const DEFAULT_RECAP_SETTINGS: SessionRecapSettings = {
+  enabled: true,
+  minMessages: 4,
+};
+
+// The end of a session says most about where it stood
+const RECAP_MESSAGES = 12;
+const MAX_MESSAGE_CHARS = 800;
+const MAX_RECAP_CHARS = 400;
+
+const RECAP_PROMPT = `You help a user pick up a conversation with an AI assistant that they left earlier.
+In one or two short sentences, say what they were working on and where it stood when they left: what was done, what was still open.
+Start with "Previously:", address the user as "you", write in the language of the conversation, and reply with nothing else.`;
+
+export async function readSessionRecapSettings(): Promise<SessionRecapSettings> {
+  const result = await window.electronAPI.preferencesGet('sessionRecap');
+  const stored = result.success && result.value && typeof result.value === 'object'
+    ? result.value as Partial<SessionRecapSettings>
+    : {};
+  return { ...DEFAULT_RECAP_SETTINGS, ...stored };
+}
+
+/**
+ * Prompts and replies with text, the ones a recap is written from
+ */
+export const recapMessages = (messages: ChatMessage[]): ChatMessage[] =>
+  messages.filter(m => (m.role === 'user' || m.role === 'assistant') && m.content.trim());
+
+function recapTranscript(messages: ChatMessage[]): string {
+  const excerpt = (text: string) => (text.length > MAX_MESSAGE_CHARS ? `${text.substring(0, MAX_MESSAGE_CHARS)}…` : text);
+  const lines = recapMessages(messages)
+    .slice(-RECAP_MESSAGES)
+    .map(m => `${m.role === 'user' ? 'User' : 'Assistant'}: ${excerpt(m.content.trim())}`);
+  // Turns already folded into a summary are only there as that summary
+  const summary = getActiveSummary(messages);
+  return summary ? [`Summary of the earlier conversation: ${excerpt(summary.content)}`, ...lines].join('\n\n') : lines.join('\n\n');
+}
+
+export async function generateSessionRecap(messages: ChatMessage[], selection: ModelSelection): Promise<string> {
+  const result = await window.electronAPI.chatComplete({
+    provider: selection.provider.id,
+    model: selection.model.id,
+    messages: [
+      { role: 'system', content: RECAP_PROMPT },
+      { role: 'user', content: recapTranscript(messages) },
+    ],
+  });
+  if (!result.success) {
+    throw new Error(result.error || 'Recap request failed');
+  }
+  const recap = (result.content || '').replace(/<think>[\s\S]*?<\/think>/gi, '').trim();
+  if (!recap) {
+    throw new Error('The model returned an empty recap');
+  }
+  const withLabel = /^previously\b/i.test(recap) ? recap : `Previously: ${recap}`;
+  return withLabel.length > MAX_RECAP_CHARS ? `${withLabel.substring(0, MAX_RECAP_CHARS - 1).trimEnd()}…` : withLabel;
+}
@@ -0,0 +1,106 @@
+import type { ChatMessage, ModelConfig, ProviderConfig } from '../types/chat';
+import { findModelByRef, isLocalProvider, type ModelSelection } from './modelUtils';
+
+// Persisted as the "sessionTitles" preference. After the first exchange of an
+// unnamed session, a short title is asked for in the background and becomes
+// the session's name, as shown in /sessions and used for export file names.
+export interface SessionTitleSettings {
+  enabled: boolean;
+  model?: string; // "providerId/modelId"; defaults to the session's model
+}
+
+const DEFAULT_TITLE_SETTINGS: SessionTitleSettings = {
+  enabled: true,
+};
+
+const MAX_TITLE_CHARS = 60;
+// Enough of the exchange to tell what it's about
+const MAX_EXCERPT_CHARS = 1500;
+
+const TITLE_PROMPT = `You name conversations between a user and an AI assistant.
+Reply with a title of at most six words that says what the conversation is about, in the language of the conversation.
+No quotes, no trailing punctuation and nothing else.`;
+
+export async function readSessionTitleSettings(): Promise<SessionTitleSettings> {
+  const result = await window.electronAPI.preferencesGet('sessionTitles');
+  const stored = result.success && result.value && typeof result.value === 'object'
+    ? result.value as Partial<SessionTitleSettings>
+    : {};
+  return { ...DEFAULT_TITLE_SETTINGS, ...stored };
+}
+
+/**
+ * The first prompt and the reply to it, once the reply has come in
+ */
+export function firstExchange(messages: ChatMessage[]): { prompt: string; reply: string } | null {
+  const start = messages.findIndex(m => m.role === 'user' && m.content.trim());
+  if (start < 0) {
+    return null;
+  }
+  const reply = messages.slice(start + 1).find(m => m.role === 'assistant' && m.content.trim());
+  return reply ? { prompt: messages[start].content, reply: reply.content } : null;
+}
+
+/**
+ * Tidy a model's reply into a title: the first line, without quotes, a
+ * "Title:" label, Markdown or a trailing full stop
+ */
+export function cleanTitle(text: string): string {
+  const line = text
+    .replace(/<think>[\s\S]*?<\/think>/gi, '')
+    .split('\n')
+    .map(l => l.trim())
+    .find(Boolean) ?? '';
+  const title = line
+    .replace(/^(?:title|session)\s*:\s*/i, '')
+    .replace(/^[#*_\s]+|[*_\s]+$/g, '')
+    .replace(/^(["'“‘`])(.*)(["'”’`])$/, '$2')
+    .replace(/[.。]+$/, '')
+    .trim();
+  return title.length > MAX_TITLE_CHARS ? `${title.substring(0, MAX_TITLE_CHARS - 1).trimEnd()}…` : title;
+}
+
+/**
+ * The model that writes titles: the one the preference names, or the
+ * session's. In offline mode only a local model is used.
+ */
+export function titleModel(
+  settings: SessionTitleSettings,
+  providers: ProviderConfig[],
+  currentProvider: ProviderConfig | null,
+  currentModel: ModelConfig | null,
+  offlineMode: boolean
+): ModelSelection | null {
+  const selection = settings.model
+    ? findModelByRef(providers, settings.model)
+    : currentProvider && currentModel ? { provider: currentProvider, model: currentModel } : null;
+  if (!selection || (offlineMode && !isLocalProvider(selection.provider))) {
+    return null;
+  }
+  return selection;
+}
+
+export async function generateSessionTitle(messages: ChatMessage[], selection: ModelSelection): Promise<string> {
+  const exchange = firstExchange(messages);
+  if (!exchange) {
+    throw new Error('There is no answered prompt to name the session after');
+  }
+  const excerpt = (text: string) => (text.length > MAX_EXCERPT_CHARS ? `${text.substring(0, MAX_EXCERPT_CHARS)}…` : text);
+
+  const result = await window.electronAPI.chatComplete({
+    provider: selection.provider.id,
+    model: selection.model.id,
+    messages: [
+      { role: 'system', content: TITLE_PROMPT },
+      { role: 'user', content: `User: ${excerpt(exchange.prompt)}\n\nAssistant: ${excerpt(exchange.reply)}` },
+    ],
+  });
+  if (!result.success) {
+    throw new Error(result.error || 'Title request failed');
+  }
+  const title = cleanTitle(result.content || '');
+  if (!title) {
+    throw new Error('The model returned an empty title');
+  }
+  return title;
+}
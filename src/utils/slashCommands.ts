@@ -0,0 +1,64 @@
+export interface ParsedSlashCommand {
+  name: string;
+  args: string[];
+  rawArgs: string;
+}
+
+/**
+ * Split command arguments on whitespace, keeping "quoted strings" together
+ */
+const ARG_PATTERN = /"([^"]*)"|'([^']*)'|(\S+)/g;
+
+export const splitCommandArgs = (input: string): string[] => {
+  const args: string[] = [];
+  const pattern = new RegExp(ARG_PATTERN);
+  let match: RegExpExecArray | null;
+  while ((match = pattern.exec(input)) !== null) {
+    args.push(match[1] ?? match[2] ?? match[3]);
+  }
+  return args;
+};
+
+/**
+ * The arguments after the first `count`, as typed (quotes and spacing kept)
+ */
+export const commandArgsAfter = (input: string, count: number): string => {
+  const pattern = new RegExp(ARG_PATTERN);
+  for (let i = 0; i < count; i++) {
+    if (pattern.exec(input) === null) {
+      return '';
+    }
+  }
+  return input.substring(pattern.lastIndex).trim();
+};
+
+/**
+ * Parse "/name arg1 arg2" input. Returns null for regular messages.
+ * A leading "//" escapes the slash so the message is sent as "/...".
+ */
+export const parseSlashCommand = (input: string): ParsedSlashCommand | null => {
+  const trimmed = input.trim();
+  if (!trimmed.startsWith('/') || trimmed.startsWith('//')) {
+    return null;
+  }
+
+  const match = trimmed.match(/^\/([a-zA-Z][\w-]*)(?:\s+([\s\S]*))?$/);
+  if (!match) {
+    return null;
+  }
+
+  const rawArgs = (match[2] || '').trim();
+  return {
+    name: match[1].toLowerCase(),
+    args: splitCommandArgs(rawArgs),
+    rawArgs,
+  };
+};
+
+/**
+ * Remove the escape from "//message" so it is sent as "/message"
+ */
+export const unescapeSlashMessage = (input: string): string => {
+  const trimmed = input.trim();
+  return trimmed.startsWith('//') ? trimmed.slice(1) : input;
+};
@@ -0,0 +1,116 @@
+import { extensionForLanguage, type FencedBlock } from './codeFence';
+
+// Where /snip writes each code block of a reply. The file name comes from the
+// first of:
+//
+//   the fence info       ```ts src/app.ts, ```python title="app.py", ```app.py
+//   the block's first    // src/app.ts, # file: app.py, <!-- index.html -->
+//   line, as a comment
+//   the line above it    Create `src/app.ts`:, **app.py**
+//
+// and otherwise snippet-N with an extension for the fence's language. Names
+// taken from the reply stay inside the target directory: absolute paths and
+// ".." are not used. The target itself is relative to the project, which must
+// be trusted.
+
+export interface Snippet {
+  path: string;
+  content: string;
+}
+
+const BARE_FILE_NAMES = ['Dockerfile', 'Makefile', 'Containerfile'];
+
+function looksLikeFile(name: string): boolean {
+  if (!name || /^[a-z]+:\/\//i.test(name) || /^([\\/]|[A-Za-z]:)/.test(name)) {
+    return false;
+  }
+  const segments = name.split(/[\\/]/);
+  if (segments.some(segment => segment === '..' || segment === '')) {
+    return false;
+  }
+  const base = segments[segments.length - 1];
+  return BARE_FILE_NAMES.includes(base) || /^[\w.-]*[\w-]\.[A-Za-z0-9]+$/.test(base);
+}
+
+const unquote = (text: string) => text.replace(/^(["'`])(.*)\1$/, '$2');
+
+function nameFromInfo(info: string): string | null {
+  const attribute = info.match(/\b(?:title|file|filename|path)=("[^"]*"|'[^']*'|\S+)/i);
+  if (attribute && looksLikeFile(unquote(attribute[1]))) {
+    return unquote(attribute[1]);
+  }
+  // "ts:src/app.ts", or a name among the words
+  const words = info.split(/\s+/).flatMap((word, i) => (i === 0 ? word.split(':') : [word]));
+  return words.map(unquote).find(looksLikeFile) ?? null;
+}
+
+function nameFromFirstLine(code: string): string | null {
+  const firstLine = code.split('\n', 1)[0];
+  const match = firstLine.match(/^\s*(?:\/\/|#|--|;|\/\*|<!--)\s*(?:file(?:name)?:\s*)?(\S+?)\s*(?:\*\/|-->)?\s*$/i);
+  return match && looksLikeFile(match[1]) ? match[1] : null;
+}
+
+function nameFromContext(before: string): string | null {
+  for (const match of before.matchAll(/`([^`\s]+)`|\*\*([^*\s]+)\*\*/g)) {
+    const name = (match[1] ?? match[2]).replace(/:$/, '');
+    if (looksLikeFile(name)) {
+      return name;
+    }
+  }
+  return null;
+}
+
+function fallbackName(block: FencedBlock, index: number): string {
+  const language = block.info.split(/[\s:]/, 1)[0].toLowerCase();
+  if (language === 'dockerfile' || language === 'docker') {
+    return 'Dockerfile';
+  }
+  return `snippet-${index + 1}.${extensionForLanguage(language) ?? 'txt'}`;
+}
+
+/**
+ * The file a code block names in its fence, its first line or the text above
+ * it, or null when it doesn't name one
+ */
+export function snippetNameHint(block: FencedBlock): string | null {
+  return nameFromInfo(block.info) ?? nameFromFirstLine(block.code) ?? nameFromContext(block.before);
+}
+
+/**
+ * A file name for a code block, from the fence, its first line or the text above it
+ */
+export function inferSnippetName(block: FencedBlock, index: number): string {
+  return snippetNameHint(block) ?? fallbackName(block, index);
+}
+
+/**
+ * The files to write for a reply's code blocks. With one block, target is the
+ * file to write it to; with several, or ending in a slash, it's the directory
+ * the inferred names go in.
+ */
+export function snippetFiles(blocks: FencedBlock[], target?: string): Snippet[] {
+  const withNewline = (code: string) => (code.endsWith('\n') ? code : `${code}\n`);
+  if (target && blocks.length === 1 && !/[\\/]$/.test(target)) {
+    return [{ path: target, content: withNewline(blocks[0].code) }];
+  }
+
+  const dir = target ? target.replace(/[\\/]+$/, '') : '';
+  const used = new Map<string, number>();
+  return blocks.map((block, index) => {
+    let name = inferSnippetName(block, index);
+    // The same name twice, e.g. a file shown before and after a change
+    const count = (used.get(name) ?? 0) + 1;
+    used.set(name, count);
+    if (count > 1) {
+      name = name.replace(/(\.[^./\\]+)?$/, extension => `-${count}${extension}`);
+    }
+    return { path: dir ? `${dir}/${name}` : name, content: withNewline(block.code) };
+  });
+}
+
+/**
+ * The notice shown once snippets are written
+ */
+export function describeSavedSnippets(paths: string[]): string {
+  return `Saved ${paths.length === 1 ? '1 code block' : `${paths.length} code blocks`}:\n${paths.map(p => `  ${p}`).join('\n')}`;
+}
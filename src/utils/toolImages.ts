@@ -0,0 +1,53 @@
+import type { MessageImage } from '../types/chat';
+
+// Types Chromium can render in an <img>
+const INLINE_IMAGE_TYPES = new Set([
+  'image/png',
+  'image/jpeg',
+  'image/gif',
+  'image/webp',
+  'image/bmp',
+  'image/svg+xml',
+]);
+
+// Larger images would bloat the saved session, so they go to a temp file instead
+const MAX_INLINE_IMAGE_BYTES = 2 * 1024 * 1024;
+
+export interface RawToolImage {
+  mimeType: string;
+  data: string;
+}
+
+/**
+ * Base64-encoded size in bytes
+ */
+const decodedSize = (data: string): number => Math.floor((data.length * 3) / 4);
+
+export const canShowInline = (image: RawToolImage): boolean =>
+  INLINE_IMAGE_TYPES.has(image.mimeType.toLowerCase()) && decodedSize(image.data) <= MAX_INLINE_IMAGE_BYTES;
+
+/**
+ * Split images off a tool result so only the text goes to the model. Images that
+ * can't be shown inline are written to a temp file and referenced by path.
+ */
+export async function extractToolImages(result: unknown): Promise<{ result: unknown; images: MessageImage[] }> {
+  if (!result || typeof result !== 'object' || !Array.isArray((result as { images?: unknown }).images)) {
+    return { result, images: [] };
+  }
+
+  const { images: rawImages, ...rest } = result as { images: RawToolImage[] };
+  const images: MessageImage[] = [];
+  for (const image of rawImages) {
+    if (canShowInline(image)) {
+      images.push({ mimeType: image.mimeType, data: image.data });
+      continue;
+    }
+    const saved = await window.electronAPI.imageSaveTemp(image.data, image.mimeType);
+    if (saved.success && saved.filePath) {
+      images.push({ mimeType: image.mimeType, path: saved.filePath });
+    } else {
+      console.error('Failed to save tool image:', saved.error);
+    }
+  }
+  return { result: rest, images };
+}
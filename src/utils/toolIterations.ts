@@ -0,0 +1,45 @@
+import type { ChatMessage } from '../types/chat';
+
+// Rounds of tool calls the model may make in reply to one message before it is
+// stopped; a model that keeps calling tools would otherwise never hand back control
+const DEFAULT_MAX_TOOL_ITERATIONS = 25;
+
+// From the "maxToolIterations" preference; 0 means no limit
+async function readMaxToolIterations(): Promise<number> {
+  const result = await window.electronAPI.preferencesGet('maxToolIterations');
+  return result.success && typeof result.value === 'number' ? Math.max(0, result.value) : DEFAULT_MAX_TOOL_ITERATIONS;
+}
+
+/**
+ * Assistant messages with tool calls since the last message the user sent.
+ * Messages a watch sent count as a round too, rather than ending the count, so
+ * a model that keeps setting itself off through a watch is stopped as well.
+ */
+const countToolIterations = (messages: ChatMessage[]): number => {
+  let count = 0;
+  for (let i = messages.length - 1; i >= 0; i--) {
+    const message = messages[i];
+    if (message.role === 'user') {
+      if (!message.fromWatch) {
+        break;
+      }
+      count++;
+    } else if (message.role === 'assistant' && message.tool_calls?.length) {
+      count++;
+    }
+  }
+  return count;
+};
+
+/**
+ * Notice to show instead of sending tool results back to the model, or null
+ * while the turn is under the limit
+ */
+export async function checkToolIterationLimit(messages: ChatMessage[]): Promise<string | null> {
+  const maxIterations = await readMaxToolIterations();
+  const iterations = countToolIterations(messages);
+  if (maxIterations === 0 || iterations < maxIterations) {
+    return null;
+  }
+  return `Stopped after ${iterations} rounds of tool calls without an answer (maxToolIterations preference: ${maxIterations}). Send a message to let the model carry on.`;
+}
@@ -0,0 +1,53 @@
+import type { ToolResultView } from '../types/chat';
+
+const isRecord = (value: unknown): value is Record<string, unknown> =>
+  !!value && typeof value === 'object' && !Array.isArray(value);
+
+export function isToolResultView(value: unknown): value is ToolResultView {
+  if (!isRecord(value)) {
+    return false;
+  }
+  switch (value.kind) {
+    case 'json':
+      return 'data' in value;
+    case 'table':
+      return Array.isArray(value.columns) && Array.isArray(value.rows) && value.rows.every(Array.isArray);
+    case 'files':
+      return Array.isArray(value.files) && value.files.every(f => isRecord(f) && typeof f.path === 'string');
+    case 'diff':
+      return typeof value.oldContent === 'string' && typeof value.newContent === 'string';
+    default:
+      return false;
+  }
+}
+
+/**
+ * View for structured data: a table when it is a list of flat records, JSON otherwise
+ */
+export function viewForData(data: unknown): ToolResultView {
+  const rows = Array.isArray(data) ? data : null;
+  const isFlat = (record: Record<string, unknown>) =>
+    Object.values(record).every(v => v === null || typeof v !== 'object');
+
+  if (rows && rows.length > 0 && rows.every(r => isRecord(r) && isFlat(r))) {
+    const columns = Array.from(new Set(rows.flatMap(r => Object.keys(r as Record<string, unknown>))));
+    return {
+      kind: 'table',
+      columns,
+      rows: rows.map(r => columns.map(column => (r as Record<string, unknown>)[column] ?? null)),
+    };
+  }
+  return { kind: 'json', data };
+}
+
+/**
+ * Split the view off a tool result so the model only gets the content. Results
+ * without a valid `view` are returned unchanged.
+ */
+export function extractResultView(result: unknown): { result: unknown; view?: ToolResultView } {
+  if (!isRecord(result) || !('view' in result)) {
+    return { result };
+  }
+  const { view, ...rest } = result;
+  return isToolResultView(view) ? { result: rest, view } : { result: rest };
+}
@@ -0,0 +1,90 @@
+import type { ChatMessage } from '../types/chat';
+import { hookConfigManager, hookRegistry } from '../pipeline';
+import type { PostResponseContext } from '../pipeline/HookRegistry';
+import { findSecrets } from '../pipeline/hooks/responseFilters';
+import { getMessageNumber } from './messageUtils';
+
+// The stream and post-response hooks only see replies as they arrive, so a
+// key pasted into a prompt or printed by a tool is still in the history.
+// Exports, /observe and the transcript archive run the export hooks over every
+// message (and an export's system prompt) first.
+
+export interface RedactedTranscript {
+  messages: ChatMessage[];
+  systemPrompt?: string;
+  report: string | null; // What was masked, for the notice; null when nothing changed
+}
+
+// For feeds that redact the history again after every change (/observe, the
+// transcript archive): hooks.yaml is read once per prompt profile, and each
+// message object is only run through the hooks the first time it is seen.
+export interface RedactionCache {
+  promptName?: string | null;
+  specs: string[] | null;
+  messages: WeakMap<ChatMessage, { message: ChatMessage; changed: boolean }>;
+}
+
+export const createRedactionCache = (): RedactionCache => ({ specs: null, messages: new WeakMap() });
+
+const countLabels = (labels: string[]): string => {
+  const counts = new Map<string, number>();
+  labels.forEach(label => counts.set(label, (counts.get(label) ?? 0) + 1));
+  return Array.from(counts, ([label, count]) => `${count} ${label}${count === 1 ? '' : 's'}`).join(', ');
+};
+
+function messageText(message: ChatMessage): string {
+  return [message.content, message.thinking ?? '', ...(message.tool_calls || []).map(tc => tc.function.arguments)].join('\n');
+}
+
+export async function redactTranscript(
+  messages: ChatMessage[],
+  context: PostResponseContext,
+  options: { systemPrompt?: string | null; cache?: RedactionCache } = {}
+): Promise<RedactedTranscript> {
+  const cache = options.cache ?? createRedactionCache();
+  if (!cache.specs || cache.promptName !== context.promptName) {
+    await hookConfigManager.loadConfig();
+    cache.specs = hookConfigManager.getExportHooks(context.promptName);
+    cache.promptName = context.promptName;
+    cache.messages = new WeakMap();
+  }
+  const specs = cache.specs;
+  const systemPrompt = options.systemPrompt ?? undefined;
+  if (specs.length === 0) {
+    return { messages, systemPrompt, report: null };
+  }
+
+  const pending = messages.filter(m => !cache.messages.has(m));
+  if (pending.length > 0) {
+    const result = await hookRegistry.runTranscript(specs, pending, context);
+    pending.forEach((message, i) => cache.messages.set(message, {
+      message: result.messages[i],
+      changed: result.changedIds.includes(message.id),
+    }));
+  }
+  const redactedPrompt = systemPrompt && (await hookRegistry.runPostResponse(specs, { content: systemPrompt }, context)).content;
+
+  const changed = messages.filter(m => cache.messages.get(m)?.changed);
+  const redacted = {
+    messages: messages.map(m => cache.messages.get(m)?.message ?? m),
+    systemPrompt: redactedPrompt,
+  };
+  const promptChanged = redactedPrompt !== systemPrompt;
+  if (changed.length === 0 && !promptChanged) {
+    return { ...redacted, report: null };
+  }
+
+  const secrets = [...changed.flatMap(m => findSecrets(messageText(m))), ...(promptChanged ? findSecrets(systemPrompt!) : [])];
+  const where = changed
+    .map(m => getMessageNumber(messages, m.id))
+    .filter((n): n is number => n !== null)
+    .map(n => `#${n}`);
+  if (promptChanged) {
+    where.unshift('the system prompt');
+  }
+  const what = secrets.length > 0 ? `Masked ${countLabels(secrets)}` : `Export hooks (${specs.join(', ')}) changed the text`;
+  return {
+    ...redacted,
+    report: where.length > 0 ? `${what} in ${where.join(', ')}` : what,
+  };
+}
@@ -0,0 +1,149 @@
+// Normal-mode keys for the input box's vim mode. h and l move along the line,
+// j and k scroll the conversation above it (Ctrl+D/Ctrl+U by half a page, gg
+// and G to either end), dd clears the input. Keys for the input box itself
+// (Enter to send, Tab, arrows, other shortcuts) are left to it.
+
+export type VimMode = 'normal' | 'insert';
+
+export type HistoryScroll = 'down' | 'up' | 'half-down' | 'half-up' | 'top' | 'bottom';
+
+export interface VimState {
+  text: string;
+  caret: number;
+  pending: string; // The first key of a two-key command: d, c or g
+}
+
+export interface VimResult {
+  text?: string;
+  caret?: number;
+  mode?: VimMode;
+  scroll?: HistoryScroll;
+  pending: string;
+}
+
+const lineStart = (text: string, caret: number) => text.lastIndexOf('\n', caret - 1) + 1;
+
+const lineEnd = (text: string, caret: number) => {
+  const end = text.indexOf('\n', caret);
+  return end < 0 ? text.length : end;
+};
+
+// Normal mode sits on a character, so the caret stops before the end of a line
+const lastOnLine = (text: string, caret: number) => Math.max(lineStart(text, caret), lineEnd(text, caret) - 1);
+
+/**
+ * Where the caret goes when insert mode is left: back onto the last character typed
+ */
+export function leaveInsert(text: string, caret: number): number {
+  return Math.max(lineStart(text, caret), Math.min(caret - 1, lastOnLine(text, caret)));
+}
+
+function nextWord(text: string, caret: number): number {
+  const match = /\w+|[^\w\s]+/g;
+  match.lastIndex = caret;
+  for (let found = match.exec(text); found; found = match.exec(text)) {
+    if (found.index > caret) {
+      return found.index;
+    }
+  }
+  return Math.max(0, text.length - 1);
+}
+
+function previousWord(text: string, caret: number): number {
+  let start = 0;
+  for (const found of text.matchAll(/\w+|[^\w\s]+/g)) {
+    if (found.index >= caret) {
+      break;
+    }
+    start = found.index;
+  }
+  return start;
+}
+
+/**
+ * The effect of a key pressed in normal mode, or null when the input box
+ * should handle it as usual
+ */
+export function normalModeKey(state: VimState, pressed: string, ctrl: boolean): VimResult | null {
+  const { text, caret, pending } = state;
+  // Backspace and Delete would otherwise edit the text under the block caret
+  const key = pressed === 'Backspace' ? 'h' : pressed === 'Delete' ? 'x' : pressed;
+
+  if (ctrl) {
+    if (key === 'd') return { scroll: 'half-down', pending: '' };
+    if (key === 'u') return { scroll: 'half-up', pending: '' };
+    return null;
+  }
+  if (key.length !== 1) {
+    // Enter, Tab, arrows, function keys; Escape drops a half-typed command
+    return key === 'Escape' && pending ? { pending: '' } : null;
+  }
+
+  if (pending) {
+    const command = pending + key;
+    if (command === 'dd') return { text: '', caret: 0, pending: '' };
+    if (command === 'cc') return { text: '', caret: 0, mode: 'insert', pending: '' };
+    if (command === 'gg') return { scroll: 'top', pending: '' };
+    // Anything else cancels the command
+    return { pending: '' };
+  }
+
+  const start = lineStart(text, caret);
+  const end = lineEnd(text, caret);
+  switch (key) {
+    case 'h':
+      return { caret: Math.max(start, caret - 1), pending: '' };
+    case 'l':
+      return { caret: Math.min(lastOnLine(text, caret), caret + 1), pending: '' };
+    case 'j':
+      return { scroll: 'down', pending: '' };
+    case 'k':
+      return { scroll: 'up', pending: '' };
+    case 'G':
+      return { scroll: 'bottom', pending: '' };
+    case 'w':
+      return { caret: nextWord(text, caret), pending: '' };
+    case 'b':
+      return { caret: previousWord(text, caret), pending: '' };
+    case '0':
+      return { caret: start, pending: '' };
+    case '^':
+      return { caret: start + (text.substring(start, end).match(/^\s*/)?.[0].length ?? 0), pending: '' };
+    case '$':
+      return { caret: lastOnLine(text, caret), pending: '' };
+    case 'i':
+      return { mode: 'insert', pending: '' };
+    case 'a':
+      return { mode: 'insert', caret: Math.min(end, caret + 1), pending: '' };
+    case 'I':
+      return { mode: 'insert', caret: start, pending: '' };
+    case 'A':
+      return { mode: 'insert', caret: end, pending: '' };
+    case 'o':
+      return { mode: 'insert', text: `${text.substring(0, end)}\n${text.substring(end)}`, caret: end + 1, pending: '' };
+    case 'O':
+      return { mode: 'insert', text: `${text.substring(0, start)}\n${text.substring(start)}`, caret: start, pending: '' };
+    case 'x': {
+      if (caret >= end) {
+        return { pending: '' };
+      }
+      const next = text.substring(0, caret) + text.substring(caret + 1);
+      return { text: next, caret: Math.min(caret, lastOnLine(next, caret)), pending: '' };
+    }
+    case 'D': {
+      const next = text.substring(0, caret) + text.substring(end);
+      return { text: next, caret: lastOnLine(next, Math.min(caret, next.length)), pending: '' };
+    }
+    case 'C':
+      return { text: text.substring(0, caret) + text.substring(end), mode: 'insert', pending: '' };
+    case 'S':
+      return { text: '', caret: 0, mode: 'insert', pending: '' };
+    case 'd':
+    case 'c':
+    case 'g':
+      return { pending: key };
+    default:
+      // Other keys do nothing in normal mode rather than typing
+      return { pending: '' };
+  }
+}